@@ -0,0 +1,403 @@
+// Command api-server runs the API test framework. Each role can be deployed
+// and scaled on its own, all roles share the configuration of the
+// environment:
+//
+//	api-server serve      serves the API
+//	api-server worker     executes the tests dispatched to WORKER_REGION
+//	api-server scheduler  fires schedules and runs compaction, TLS audits,
+//	                      partition maintenance and worker scaling
+//	api-server migrate    applies the pending migrations and exits
+//
+// Without a role, the migrations are applied and every role runs in one
+// process.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"api-test-framework/internal/config"
+	"api-test-framework/internal/database"
+	"api-test-framework/internal/discovery"
+	"api-test-framework/internal/kubernetes"
+	"api-test-framework/internal/logging"
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/objectstore"
+	"api-test-framework/internal/secrets"
+	"api-test-framework/internal/services"
+	"api-test-framework/internal/testrunner"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+// Roles of the process, selected by the first argument
+const (
+	roleServe     = "serve"
+	roleWorker    = "worker"
+	roleScheduler = "scheduler"
+	roleMigrate   = "migrate"
+	roleAll       = "all"
+)
+
+// Intervals of the background tasks without a setting of their own
+const (
+	partitionMaintenanceInterval = 24 * time.Hour
+	shutdownTimeout              = 30 * time.Second
+)
+
+func main() {
+	role := roleAll
+	if len(os.Args) > 1 {
+		role = os.Args[1]
+	}
+	switch role {
+	case roleServe, roleWorker, roleScheduler, roleMigrate, roleAll:
+	default:
+		fmt.Fprintf(os.Stderr, "unknown role %q\nusage: %s [serve|worker|scheduler|migrate]\n", role, os.Args[0])
+		os.Exit(2)
+	}
+
+	cfg := config.Load()
+	logger, err := logging.New(os.Stdout, cfg.Logging.Level, cfg.Logging.Format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, role, cfg, logger); err != nil {
+		logger.Error("api-server failed", "role", role, "error", err)
+		os.Exit(1)
+	}
+}
+
+// run connects to the databases and runs a role until ctx is done
+func run(ctx context.Context, role string, cfg *config.Config, logger *slog.Logger) error {
+	app, err := newApp(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer app.close()
+
+	switch role {
+	case roleMigrate:
+		return app.migrate()
+	case roleServe:
+		return app.serve(ctx)
+	case roleWorker:
+		return app.work(ctx)
+	case roleScheduler:
+		return app.schedule(ctx)
+	}
+
+	if err := app.migrate(); err != nil {
+		return err
+	}
+	roles := []func(context.Context) error{app.serve, app.schedule}
+	if cfg.Worker.Region != "" && app.redis != nil {
+		roles = append(roles, app.work)
+	}
+	return runAll(ctx, roles...)
+}
+
+// runAll runs roles concurrently until ctx is done or one of them fails,
+// which stops the others
+func runAll(ctx context.Context, roles ...func(context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make(chan error, len(roles))
+	var wg sync.WaitGroup
+	for _, role := range roles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := role(ctx); err != nil {
+				errs <- err
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// app holds the connections and services shared by the roles
+type app struct {
+	cfg    *config.Config
+	logger *slog.Logger
+	db     *gorm.DB
+	redis  *redis.Client
+
+	testRuns   *services.TestRunService
+	fixtures   *services.FixtureService
+	suites     *services.SuiteService
+	schedules  *services.ScheduleService
+	workers    *services.WorkerService
+	tlsAudit   *services.TLSAuditService
+	compaction *services.CompactionService
+	tests      *services.TestService
+}
+
+// newApp connects to PostgreSQL and Redis and creates the services
+// configured by cfg. Redis is optional: without it the scheduler does not
+// elect a leader, run events are not streamed and tests are not dispatched
+// to other regions.
+func newApp(cfg *config.Config, logger *slog.Logger) (*app, error) {
+	database.SetLogger(logger)
+	db, err := database.Init(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.InitSecrets(cfg); err != nil {
+		return nil, err
+	}
+	replica, err := database.InitReplica(cfg, db)
+	if err != nil {
+		return nil, err
+	}
+	redisClient, err := database.InitRedis(cfg)
+	if err != nil {
+		logger.Warn("Redis is unavailable, continuing without it", "error", err)
+		redisClient = nil
+	}
+
+	if cfg.Worker.EgressSource != "" || cfg.Worker.EgressProxy != "" {
+		if err := testrunner.SetEgress(testrunner.Egress{Source: cfg.Worker.EgressSource, ProxyURL: cfg.Worker.EgressProxy}); err != nil {
+			return nil, fmt.Errorf("egress: %v", err)
+		}
+	}
+
+	fixtures := services.NewFixtureService(db, cfg.Fixtures.MaxSizeBytes)
+	fixtures.SetLogger(logger)
+	switch cfg.Fixtures.Storage {
+	case "database":
+	case "s3":
+		store, err := objectstore.NewS3Store(cfg.Fixtures.S3Endpoint, cfg.Fixtures.S3Region, cfg.Fixtures.S3Bucket, cfg.Fixtures.S3AccessKey, cfg.Fixtures.S3SecretKey, cfg.Fixtures.S3PathStyle)
+		if err != nil {
+			return nil, fmt.Errorf("fixture storage: %v", err)
+		}
+		fixtures.UseStore(store)
+	default:
+		return nil, fmt.Errorf("unsupported FIXTURE_STORAGE %q, expected database or s3", cfg.Fixtures.Storage)
+	}
+
+	capture := services.ResponseCapture{MaxBodyBytes: cfg.Responses.MaxBodyBytes, Compress: cfg.Responses.Compress}
+	switch cfg.Responses.Storage {
+	case "database":
+	case "s3":
+		store, err := objectstore.NewS3Store(cfg.Responses.S3Endpoint, cfg.Responses.S3Region, cfg.Responses.S3Bucket, cfg.Responses.S3AccessKey, cfg.Responses.S3SecretKey, cfg.Responses.S3PathStyle)
+		if err != nil {
+			return nil, fmt.Errorf("response storage: %v", err)
+		}
+		capture.Store = store
+	default:
+		return nil, fmt.Errorf("unsupported RESPONSE_STORAGE %q, expected database or s3", cfg.Responses.Storage)
+	}
+
+	testRuns := services.NewTestRunService(db, nil, redisClient)
+	testRuns.SetLogger(logger)
+	testRuns.UseReadReplica(replica)
+	testRuns.SetFixtures(fixtures)
+	testRuns.SetResponseCapture(capture)
+	testRuns.SetRedactionRules(models.RedactionRules{Headers: cfg.Responses.RedactHeaders, Paths: cfg.Responses.RedactPaths})
+	testRuns.SetReportBaseURL(cfg.Server.PublicURL)
+	testRuns.SetRegion(cfg.Worker.Region)
+	testRuns.SetPool(cfg.Worker.Pool)
+	testRuns.SetWorkerIdleExit(cfg.Worker.IdleExit)
+	testRuns.SetServiceResolver(discovery.NewResolver(cfg.Discovery.ClusterDomain, cfg.Discovery.ConsulAddress, cfg.Discovery.ConsulToken))
+	testRuns.SetSecretResolver(secrets.NewResolver(secrets.VaultConfig{
+		Address:   cfg.Secrets.VaultAddress,
+		Token:     cfg.Secrets.VaultToken,
+		Namespace: cfg.Secrets.VaultNamespace,
+		CacheTTL:  cfg.Secrets.VaultCacheTTL,
+	}))
+
+	compaction := services.NewCompactionService(db, cfg.Compaction.After)
+	compaction.SetLogger(logger)
+	if capture.Store != nil {
+		compaction.UseResponseStore(capture.Store)
+	}
+
+	tests := services.NewTestService(db)
+	tests.UseReadReplica(replica)
+
+	schedules := services.NewScheduleService(db, redisClient, testRuns)
+	schedules.SetLogger(logger)
+	workers := services.NewWorkerService(db, redisClient)
+	workers.SetLogger(logger)
+	tlsAudit := services.NewTLSAuditService(db, cfg.TLSAudit.ExpiryAlertDays)
+	tlsAudit.SetLogger(logger)
+
+	return &app{
+		cfg:        cfg,
+		logger:     logger,
+		db:         db,
+		redis:      redisClient,
+		testRuns:   testRuns,
+		fixtures:   fixtures,
+		suites:     services.NewSuiteService(db, testRuns),
+		schedules:  schedules,
+		workers:    workers,
+		tlsAudit:   tlsAudit,
+		compaction: compaction,
+		tests:      tests,
+	}, nil
+}
+
+// close releases the connections of the app
+func (a *app) close() {
+	if a.redis != nil {
+		a.redis.Close()
+	}
+	if sqlDB, err := a.db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// migrate applies the pending migrations and, when secrets are encrypted,
+// rewrites the secrets stored as plaintext or under a previous key
+func (a *app) migrate() error {
+	if err := database.Migrate(a.db, a.cfg.Database.PartitionMonthsAhead); err != nil {
+		return err
+	}
+	if a.cfg.Secrets.KMSKeyID == "" && a.cfg.Secrets.EncryptionKey == "" {
+		return nil
+	}
+	rewritten, err := database.EncryptStoredSecrets(a.db)
+	if err != nil {
+		return err
+	}
+	a.logger.Info("encrypted stored secrets", "count", rewritten)
+	return nil
+}
+
+// serve serves the API until ctx is done, then lets in-flight requests finish
+func (a *app) serve(ctx context.Context) error {
+	server := &http.Server{
+		Addr:    net.JoinHostPort(a.cfg.Server.Host, a.cfg.Server.Port),
+		Handler: a.router(),
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		a.logger.Info("serving the API", "address", server.Addr)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("server: %v", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("server shutdown: %v", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server: %v", err)
+	}
+	return nil
+}
+
+// work executes the tests dispatched to the region, or egress pool, of the
+// instance until ctx is done or the worker was idle for WORKER_IDLE_EXIT_SECONDS
+func (a *app) work(ctx context.Context) error {
+	if a.cfg.Worker.Region == "" {
+		return fmt.Errorf("worker: WORKER_REGION is required")
+	}
+	if a.redis == nil {
+		return fmt.Errorf("worker: Redis is required to receive tests")
+	}
+	a.logger.Info("executing the tests of the region", "region", a.cfg.Worker.Region, "pool", a.cfg.Worker.Pool)
+	a.testRuns.RunRegionWorker(ctx)
+	return nil
+}
+
+// schedule runs the background tasks until ctx is done: firing schedules,
+// compacting runs, auditing TLS, creating result partitions and, with
+// SCALER_MODE, scaling the workers of a region
+func (a *app) schedule(ctx context.Context) error {
+	scaler, err := a.workerScaler()
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	background := func(task func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			task()
+		}()
+	}
+
+	if a.cfg.Scheduler.Enabled {
+		background(func() { a.schedules.Run(ctx, a.cfg.Scheduler.PollInterval) })
+	}
+	background(func() { a.compaction.Run(ctx, a.cfg.Compaction.Interval) })
+	background(func() { a.tlsAudit.Run(ctx, a.cfg.TLSAudit.Interval) })
+	background(func() {
+		database.RunPartitionMaintenance(ctx, a.db, a.cfg.Database.PartitionMonthsAhead, partitionMaintenanceInterval)
+	})
+	if scaler != nil {
+		background(func() { scaler.Run(ctx, a.cfg.Scaler.Interval) })
+	}
+
+	wg.Wait()
+	return nil
+}
+
+// workerScaler creates the worker scaler of SCALER_MODE, nil when scaling is
+// disabled
+func (a *app) workerScaler() (*services.WorkerScaler, error) {
+	scaling := a.cfg.Scaler
+	options := services.WorkerScalerOptions{
+		Region:         scaling.Region,
+		MinWorkers:     scaling.MinWorkers,
+		MaxWorkers:     scaling.MaxWorkers,
+		WorkerCapacity: scaling.WorkerCapacity,
+		ScaleDownDelay: scaling.ScaleDownDelay,
+	}
+	switch scaling.Mode {
+	case "":
+		return nil, nil
+	case "deployment":
+		options.Deployment = scaling.Deployment
+	case "job":
+		template, err := kubernetes.LoadManifest(scaling.JobTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("worker scaler: %v", err)
+		}
+		options.JobTemplate = template
+	default:
+		return nil, fmt.Errorf("unsupported SCALER_MODE %q, expected deployment or job", scaling.Mode)
+	}
+
+	kube, err := kubernetes.NewInClusterClient(scaling.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("worker scaler: %v", err)
+	}
+	scaler, err := services.NewWorkerScaler(a.workers, kube, options)
+	if err != nil {
+		return nil, fmt.Errorf("worker scaler: %v", err)
+	}
+	scaler.SetLogger(a.logger)
+	return scaler, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"api-test-framework/internal/handlers"
+	"api-test-framework/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// router creates the API router. With AUTH_ENABLED, every route but the
+// public ones requires an API key or a JWT with the role of the route.
+func (a *app) router() *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(gin.Recovery(), a.requestLogger())
+
+	authService := services.NewAuthService(a.db, a.cfg.Auth.JWTSecret, a.cfg.Auth.TokenTTL, a.cfg.Auth.AdminAPIKey)
+	projectService := services.NewProjectService(a.db)
+	projectService.SetUsageRates(services.UsageRates{
+		PerTest:      a.cfg.Usage.CostPerTest,
+		PerRunMinute: a.cfg.Usage.CostPerRunMinute,
+		PerGB:        a.cfg.Usage.CostPerGB,
+		Currency:     a.cfg.Usage.Currency,
+	})
+
+	authHandler := handlers.NewAuthHandler(authService)
+	environmentHandler := handlers.NewEnvironmentHandler(services.NewEnvironmentService(a.db))
+	fixtureHandler := handlers.NewFixtureHandler(a.fixtures)
+	gateHandler := handlers.NewGateHandler(a.testRuns, a.suites)
+	hookHandler := handlers.NewHookHandler(services.NewHookService(a.db, a.testRuns, a.suites))
+	projectHandler := handlers.NewProjectHandler(projectService)
+	scheduleHandler := handlers.NewScheduleHandler(a.schedules)
+	serviceHandler := handlers.NewServiceHandler(services.NewServiceService(a.db), a.tlsAudit, a.suites)
+	statsHandler := handlers.NewStatsHandler(a.testRuns, a.cfg.Metrics.Window)
+	suiteHandler := handlers.NewSuiteHandler(a.suites)
+	testHandler := handlers.NewTestHandler(a.tests)
+	testRunHandler := handlers.NewTestRunHandler(a.testRuns)
+	workerHandler := handlers.NewWorkerHandler(a.workers)
+
+	if a.cfg.Auth.Enabled {
+		router.Use(authHandler.Middleware())
+	} else {
+		a.logger.Warn("AUTH_ENABLED is false, the API accepts unauthenticated requests")
+	}
+
+	router.GET("/health", a.health)
+	router.GET("/health/db", a.databaseHealth)
+	router.GET("/health/redis", a.redisHealth)
+	router.GET("/metrics", statsHandler.Metrics)
+
+	api := router.Group("/api/v1")
+	{
+		api.GET("/auth/me", authHandler.CurrentUser)
+		api.POST("/auth/token", authHandler.IssueToken)
+		api.GET("/api-keys", authHandler.ListAPIKeys)
+		api.POST("/api-keys", authHandler.CreateAPIKey)
+		api.DELETE("/api-keys/:id", authHandler.DeleteAPIKey)
+		api.GET("/users", authHandler.ListUsers)
+		api.POST("/users", authHandler.CreateUser)
+		api.GET("/users/:id", authHandler.GetUser)
+		api.PUT("/users/:id", authHandler.UpdateUser)
+		api.DELETE("/users/:id", authHandler.DeleteUser)
+
+		api.GET("/projects", projectHandler.ListProjects)
+		api.POST("/projects", projectHandler.CreateProject)
+		api.GET("/projects/usage", projectHandler.GetUsageReport)
+		api.GET("/projects/:id", projectHandler.GetProject)
+		api.PUT("/projects/:id", projectHandler.UpdateProject)
+		api.DELETE("/projects/:id", projectHandler.DeleteProject)
+		api.GET("/projects/:id/quota", projectHandler.GetQuotaUsage)
+
+		api.GET("/services", serviceHandler.ListServices)
+		api.POST("/services", serviceHandler.CreateService)
+		api.GET("/services/:id", serviceHandler.GetService)
+		api.PUT("/services/:id", serviceHandler.UpdateService)
+		api.DELETE("/services/:id", serviceHandler.DeleteService)
+		api.POST("/services/:id/tls-audit", serviceHandler.AuditTLS)
+		api.GET("/services/:id/impact", serviceHandler.GetImpact)
+		api.POST("/services/:id/impact-run", serviceHandler.RunImpact)
+		api.POST("/services/:id/smoke-suite", testHandler.RegenerateSmokeSuite)
+		api.GET("/services/:id/tests/export", testHandler.ExportTests)
+
+		api.GET("/environments", environmentHandler.ListEnvironments)
+		api.POST("/environments", environmentHandler.CreateEnvironment)
+		api.GET("/environments/:id", environmentHandler.GetEnvironment)
+		api.PUT("/environments/:id", environmentHandler.UpdateEnvironment)
+		api.DELETE("/environments/:id", environmentHandler.DeleteEnvironment)
+
+		api.GET("/tests", testHandler.ListTests)
+		api.POST("/tests", testHandler.CreateTest)
+		api.GET("/tests/archived", testHandler.ListArchivedTests)
+		api.POST("/tests/from-curl", testHandler.CreateTestFromCurl)
+		api.POST("/tests/import/postman", testHandler.ImportPostmanCollection)
+		api.POST("/tests/import/har", testHandler.ImportHAR)
+		api.POST("/tests/import/openapi", testHandler.ImportOpenAPI)
+		api.POST("/tests/bulk", testHandler.ImportTestBundle)
+		api.POST("/tests/validate", testHandler.ValidateTest)
+		api.POST("/tests/execute", testRunHandler.ExecuteTestSpec)
+		api.POST("/tests/preview", testRunHandler.PreviewRequest)
+		api.GET("/tests/:id", testHandler.GetTest)
+		api.PUT("/tests/:id", testHandler.UpdateTest)
+		api.DELETE("/tests/:id", testHandler.DeleteTest)
+		api.POST("/tests/:id/promote", testRunHandler.PromoteResponse)
+		api.GET("/tests/:id/export/curl", testRunHandler.ExportCurl)
+
+		api.GET("/fixtures", fixtureHandler.ListFixtures)
+		api.POST("/fixtures", fixtureHandler.CreateFixture)
+		api.GET("/fixtures/:id", fixtureHandler.GetFixture)
+		api.DELETE("/fixtures/:id", fixtureHandler.DeleteFixture)
+		api.POST("/fixtures/:id/versions", fixtureHandler.UploadFixtureVersion)
+		api.GET("/fixtures/:id/content", fixtureHandler.DownloadFixture)
+
+		api.GET("/suites", suiteHandler.ListSuites)
+		api.POST("/suites", suiteHandler.CreateSuite)
+		api.GET("/suites/:id", suiteHandler.GetSuite)
+		api.PUT("/suites/:id", suiteHandler.UpdateSuite)
+		api.DELETE("/suites/:id", suiteHandler.DeleteSuite)
+		api.POST("/suites/:id/run", suiteHandler.RunSuite)
+
+		api.GET("/schedules", scheduleHandler.ListSchedules)
+		api.POST("/schedules", scheduleHandler.CreateSchedule)
+		api.GET("/schedules/:id", scheduleHandler.GetSchedule)
+		api.PUT("/schedules/:id", scheduleHandler.UpdateSchedule)
+		api.DELETE("/schedules/:id", scheduleHandler.DeleteSchedule)
+
+		api.GET("/hooks", hookHandler.ListHooks)
+		api.POST("/hooks", hookHandler.CreateHook)
+		api.GET("/hooks/:id", hookHandler.GetHook)
+		api.PUT("/hooks/:id", hookHandler.UpdateHook)
+		api.DELETE("/hooks/:id", hookHandler.DeleteHook)
+		api.POST("/hooks/:id/rotate-token", hookHandler.RotateHookToken)
+		api.POST("/hooks/:id/trigger", hookHandler.TriggerHook)
+
+		api.POST("/gate", gateHandler.StartGate)
+		api.GET("/gate/:id", gateHandler.WaitGate)
+
+		api.GET("/test-runs", testRunHandler.ListTestRuns)
+		api.POST("/test-runs", testRunHandler.StartTestRun)
+		api.GET("/test-runs/compare", testRunHandler.CompareRuns)
+		api.GET("/test-runs/:id", testRunHandler.GetTestRun)
+		api.POST("/test-runs/:id/cancel", testRunHandler.CancelTestRun)
+		api.POST("/test-runs/:id/rerun-failed", testRunHandler.RerunFailed)
+		api.GET("/test-runs/:id/stream", testRunHandler.StreamTestRun)
+		api.GET("/test-runs/:id/summary", testRunHandler.GetRunSummary)
+		api.GET("/test-runs/:id/debug-log", testRunHandler.GetDebugLog)
+		api.GET("/test-runs/:id/security-findings", testRunHandler.GetSecurityFindings)
+		api.GET("/test-runs/:id/config", testRunHandler.GetRunConfig)
+		api.GET("/test-runs/:id/report", testRunHandler.GetTestRunReport)
+		api.GET("/test-runs/:id/results", testRunHandler.GetTestResults)
+		api.GET("/test-runs/:id/results/:resultId/assertions", testRunHandler.GetAssertionResults)
+		api.GET("/test-runs/:id/deprecations", testRunHandler.GetDeprecationReport)
+		api.GET("/test-runs/:id/regions", testRunHandler.CompareRegions)
+		api.GET("/test-runs/:id/scenario", testRunHandler.GetScenarioGraph)
+
+		api.GET("/results/search", testRunHandler.SearchResults)
+		api.GET("/results/:id/response", testRunHandler.GetResultResponse)
+		api.POST("/results/:id/replay", testRunHandler.ReplayResult)
+
+		api.POST("/execute", testRunHandler.ExecuteRequest)
+		api.POST("/tools/diagnose", testRunHandler.Diagnose)
+		api.GET("/queue", testRunHandler.GetQueueStatus)
+		api.GET("/regions", testRunHandler.ListRegions)
+
+		api.GET("/workers", workerHandler.ListWorkers)
+		api.POST("/workers", workerHandler.RegisterWorker)
+		api.GET("/workers/capacity", workerHandler.GetCapacity)
+		api.POST("/workers/:id/heartbeat", workerHandler.Heartbeat)
+		api.DELETE("/workers/:id", workerHandler.DeregisterWorker)
+
+		api.GET("/stats/services", statsHandler.GetServiceStats)
+		api.GET("/stats/http-clients", statsHandler.GetClientPoolStats)
+	}
+
+	return router
+}
+
+// requestLogger logs every request once it was answered
+func (a *app) requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		level := slog.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		a.logger.Log(c.Request.Context(), level, "request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	}
+}
+
+// health handles GET /health
+func (a *app) health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// databaseHealth handles GET /health/db
+func (a *app) databaseHealth(c *gin.Context) {
+	sqlDB, err := a.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(c.Request.Context())
+	}
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Database is unavailable",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// redisHealth handles GET /health/redis
+func (a *app) redisHealth(c *gin.Context) {
+	if a.redis == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Redis is unavailable",
+			"details": "Redis is not configured or was unreachable at startup",
+		})
+		return
+	}
+	if err := a.redis.Ping(c.Request.Context()).Err(); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Redis is unavailable",
+			"details": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
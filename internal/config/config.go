@@ -3,19 +3,35 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	Redis      RedisConfig
+	Fixtures   FixturesConfig
+	Responses  ResponsesConfig
+	Scheduler  SchedulerConfig
+	Compaction CompactionConfig
+	TLSAudit   TLSAuditConfig
+	Worker     WorkerConfig
+	Scaler     ScalerConfig
+	Discovery  DiscoveryConfig
+	Metrics    MetricsConfig
+	Logging    LoggingConfig
+	Auth       AuthConfig
+	Secrets    SecretsConfig
+	Usage      UsageConfig
 }
 
 type ServerConfig struct {
-	Port string
-	Host string
+	Port      string
+	Host      string
+	PublicURL string // external URL of the API, used for links in notifications
 }
 
 type DatabaseConfig struct {
@@ -25,6 +41,10 @@ type DatabaseConfig struct {
 	Password string
 	Name     string
 	SSLMode  string
+	// ReplicaDSN optionally points reporting queries at a read-only replica
+	ReplicaDSN string
+	// PartitionMonthsAhead is how many months of test_results partitions are created in advance
+	PartitionMonthsAhead int
 }
 
 type RedisConfig struct {
@@ -34,6 +54,142 @@ type RedisConfig struct {
 	DB       int
 }
 
+type FixturesConfig struct {
+	MaxSizeBytes int64
+	// Storage is "database" to keep fixture contents in PostgreSQL or "s3" for
+	// an S3-compatible object storage
+	Storage     string
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool // required by MinIO and most self-hosted storages
+}
+
+type ResponsesConfig struct {
+	// MaxBodyBytes is the size above which captured response bodies are
+	// compressed, offloaded or truncated; 0 stores them as they are
+	MaxBodyBytes int
+	// Compress gzips the bodies above MaxBodyBytes
+	Compress bool
+	// Storage is "database" to keep large bodies in PostgreSQL or "s3" to
+	// offload them to an S3-compatible object storage
+	Storage     string
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool
+	// RedactHeaders and RedactPaths mask sensitive data of every service's
+	// responses before they are stored, e.g. X-Patient-Token or patient.ssn
+	RedactHeaders []string
+	RedactPaths   []string
+}
+
+type SchedulerConfig struct {
+	Enabled      bool
+	PollInterval time.Duration
+}
+
+type WorkerConfig struct {
+	// Region tests are executed from by this instance, e.g. eu-west-1
+	Region string
+	// IdleExit stops the worker once no test arrived for this long, 0 keeps it running
+	IdleExit time.Duration
+	// Pool is the egress pool of the worker, which then executes the tests
+	// sent from the pool instead of the tests of its region
+	Pool string
+	// EgressSource is the IP address or network interface tests connect from
+	EgressSource string
+	// EgressProxy is an http, https or socks5 proxy tests are sent through
+	EgressProxy string
+}
+
+type ScalerConfig struct {
+	// Mode is "deployment" to scale a worker deployment, "job" to spawn worker
+	// Jobs, or empty to disable scaling
+	Mode           string
+	Namespace      string // defaults to the namespace of the pod
+	Region         string // region whose workers are scaled, defaults to WORKER_REGION
+	Deployment     string
+	JobTemplate    string // path of the Job manifest, YAML or JSON
+	MinWorkers     int
+	MaxWorkers     int
+	WorkerCapacity int
+	Interval       time.Duration
+	ScaleDownDelay time.Duration
+}
+
+type DiscoveryConfig struct {
+	// ClusterDomain is the DNS domain of the Kubernetes cluster services are discovered in
+	ClusterDomain string
+	ConsulAddress string
+	ConsulToken   string
+}
+
+type MetricsConfig struct {
+	// Window is the span of test results the Prometheus service metrics cover
+	Window time.Duration
+}
+
+type LoggingConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error
+	Level string
+	// Format is "json" for one JSON object per record or "text" for key=value lines
+	Format string
+}
+
+type AuthConfig struct {
+	// Enabled requires an API key or a JWT on the API routes
+	Enabled bool
+	// JWTSecret signs and verifies the HS256 tokens; empty disables tokens
+	JWTSecret string
+	TokenTTL  time.Duration
+	// AdminAPIKey is a static key with the admin role, to create the first users
+	AdminAPIKey string
+}
+
+type SecretsConfig struct {
+	// EncryptionKey is a base64-encoded 32-byte key encrypting service auth
+	// secrets at rest; empty stores them as plaintext
+	EncryptionKey string
+	// KMSKeyID selects an AWS KMS key instead of EncryptionKey
+	KMSKeyID     string
+	KMSRegion    string
+	KMSEndpoint  string
+	KMSAccessKey string
+	KMSSecretKey string
+	// Vault server "vault:" secret references are read from
+	VaultAddress   string
+	VaultToken     string
+	VaultNamespace string
+	VaultCacheTTL  time.Duration
+}
+
+type CompactionConfig struct {
+	// After is the age of finished runs that get compacted, 0 disables compaction
+	After    time.Duration
+	Interval time.Duration
+}
+
+type TLSAuditConfig struct {
+	// Interval between audits of the TLS setup of services, 0 disables audits
+	Interval time.Duration
+	// ExpiryAlertDays is how many days before a certificate expires services are alerted
+	ExpiryAlertDays int
+}
+
+type UsageConfig struct {
+	// Rates usage reports price executed tests, run minutes and stored GB at;
+	// all zero reports no cost
+	CostPerTest      float64
+	CostPerRunMinute float64
+	CostPerGB        float64
+	Currency         string
+}
+
 func Load() *Config {
 	// Load .env file if it exists
 	if err := godotenv.Load(".env.local"); err == nil {
@@ -44,16 +200,19 @@ func Load() *Config {
 
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:      getEnv("SERVER_PORT", "8080"),
+			Host:      getEnv("SERVER_HOST", "0.0.0.0"),
+			PublicURL: getEnv("PUBLIC_BASE_URL", ""),
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "user"),
-			Password: getEnv("DB_PASSWORD", "password"),
-			Name:     getEnv("DB_NAME", "api_test_framework"),
-			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
+			Host:                 getEnv("DB_HOST", "localhost"),
+			Port:                 getEnv("DB_PORT", "5432"),
+			User:                 getEnv("DB_USER", "user"),
+			Password:             getEnv("DB_PASSWORD", "password"),
+			Name:                 getEnv("DB_NAME", "api_test_framework"),
+			SSLMode:              getEnv("DB_SSL_MODE", "disable"),
+			ReplicaDSN:           getEnv("DB_REPLICA_DSN", ""),
+			PartitionMonthsAhead: getEnvAsInt("DB_PARTITION_MONTHS_AHEAD", 3),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -61,6 +220,96 @@ func Load() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
+		Fixtures: FixturesConfig{
+			MaxSizeBytes: int64(getEnvAsInt("FIXTURE_MAX_SIZE_MB", 10)) << 20,
+			Storage:      getEnv("FIXTURE_STORAGE", "database"),
+			S3Endpoint:   getEnv("FIXTURE_S3_ENDPOINT", ""),
+			S3Region:     getEnv("FIXTURE_S3_REGION", getEnv("AWS_REGION", "us-east-1")),
+			S3Bucket:     getEnv("FIXTURE_S3_BUCKET", ""),
+			S3AccessKey:  getEnv("FIXTURE_S3_ACCESS_KEY_ID", getEnv("AWS_ACCESS_KEY_ID", "")),
+			S3SecretKey:  getEnv("FIXTURE_S3_SECRET_ACCESS_KEY", getEnv("AWS_SECRET_ACCESS_KEY", "")),
+			S3PathStyle:  getEnvAsBool("FIXTURE_S3_PATH_STYLE", false),
+		},
+		Responses: ResponsesConfig{
+			MaxBodyBytes:  getEnvAsInt("RESPONSE_MAX_BODY_KB", 0) << 10,
+			Compress:      getEnvAsBool("RESPONSE_COMPRESS", false),
+			Storage:       getEnv("RESPONSE_STORAGE", "database"),
+			S3Endpoint:    getEnv("RESPONSE_S3_ENDPOINT", ""),
+			S3Region:      getEnv("RESPONSE_S3_REGION", getEnv("AWS_REGION", "us-east-1")),
+			S3Bucket:      getEnv("RESPONSE_S3_BUCKET", ""),
+			S3AccessKey:   getEnv("RESPONSE_S3_ACCESS_KEY_ID", getEnv("AWS_ACCESS_KEY_ID", "")),
+			S3SecretKey:   getEnv("RESPONSE_S3_SECRET_ACCESS_KEY", getEnv("AWS_SECRET_ACCESS_KEY", "")),
+			S3PathStyle:   getEnvAsBool("RESPONSE_S3_PATH_STYLE", false),
+			RedactHeaders: getEnvAsList("REDACT_HEADERS"),
+			RedactPaths:   getEnvAsList("REDACT_PATHS"),
+		},
+		Scheduler: SchedulerConfig{
+			Enabled:      getEnvAsBool("SCHEDULER_ENABLED", true),
+			PollInterval: time.Duration(getEnvAsInt("SCHEDULER_POLL_INTERVAL_SECONDS", 15)) * time.Second,
+		},
+		Worker: WorkerConfig{
+			Region:       getEnv("WORKER_REGION", ""),
+			IdleExit:     time.Duration(getEnvAsInt("WORKER_IDLE_EXIT_SECONDS", 0)) * time.Second,
+			Pool:         getEnv("WORKER_POOL", ""),
+			EgressSource: getEnv("WORKER_EGRESS_SOURCE", ""),
+			EgressProxy:  getEnv("WORKER_EGRESS_PROXY", ""),
+		},
+		Scaler: ScalerConfig{
+			Mode:           getEnv("SCALER_MODE", ""),
+			Namespace:      getEnv("SCALER_NAMESPACE", ""),
+			Region:         getEnv("SCALER_REGION", getEnv("WORKER_REGION", "")),
+			Deployment:     getEnv("SCALER_DEPLOYMENT", ""),
+			JobTemplate:    getEnv("SCALER_JOB_TEMPLATE", ""),
+			MinWorkers:     getEnvAsInt("SCALER_MIN_WORKERS", 0),
+			MaxWorkers:     getEnvAsInt("SCALER_MAX_WORKERS", 10),
+			WorkerCapacity: getEnvAsInt("SCALER_WORKER_CAPACITY", 16),
+			Interval:       time.Duration(getEnvAsInt("SCALER_INTERVAL_SECONDS", 15)) * time.Second,
+			ScaleDownDelay: time.Duration(getEnvAsInt("SCALER_SCALE_DOWN_DELAY_SECONDS", 300)) * time.Second,
+		},
+		Discovery: DiscoveryConfig{
+			ClusterDomain: getEnv("DISCOVERY_CLUSTER_DOMAIN", "cluster.local"),
+			ConsulAddress: getEnv("CONSUL_HTTP_ADDR", "http://127.0.0.1:8500"),
+			ConsulToken:   getEnv("CONSUL_HTTP_TOKEN", ""),
+		},
+		Metrics: MetricsConfig{
+			Window: time.Duration(getEnvAsInt("METRICS_WINDOW_MINUTES", 60)) * time.Minute,
+		},
+		Logging: LoggingConfig{
+			Level:  getEnv("LOG_LEVEL", "info"),
+			Format: getEnv("LOG_FORMAT", "json"),
+		},
+		Auth: AuthConfig{
+			Enabled:     getEnvAsBool("AUTH_ENABLED", false),
+			JWTSecret:   getEnv("AUTH_JWT_SECRET", ""),
+			TokenTTL:    time.Duration(getEnvAsInt("AUTH_TOKEN_TTL_MINUTES", 60)) * time.Minute,
+			AdminAPIKey: getEnv("AUTH_ADMIN_API_KEY", ""),
+		},
+		Secrets: SecretsConfig{
+			EncryptionKey:  getEnv("SECRETS_ENCRYPTION_KEY", ""),
+			KMSKeyID:       getEnv("SECRETS_KMS_KEY_ID", ""),
+			KMSRegion:      getEnv("SECRETS_KMS_REGION", getEnv("AWS_REGION", "us-east-1")),
+			KMSEndpoint:    getEnv("SECRETS_KMS_ENDPOINT", ""),
+			KMSAccessKey:   getEnv("SECRETS_KMS_ACCESS_KEY_ID", getEnv("AWS_ACCESS_KEY_ID", "")),
+			KMSSecretKey:   getEnv("SECRETS_KMS_SECRET_ACCESS_KEY", getEnv("AWS_SECRET_ACCESS_KEY", "")),
+			VaultAddress:   getEnv("VAULT_ADDR", ""),
+			VaultToken:     getEnv("VAULT_TOKEN", ""),
+			VaultNamespace: getEnv("VAULT_NAMESPACE", ""),
+			VaultCacheTTL:  time.Duration(getEnvAsInt("VAULT_CACHE_SECONDS", 300)) * time.Second,
+		},
+		Compaction: CompactionConfig{
+			After:    time.Duration(getEnvAsInt("COMPACT_RUNS_AFTER_DAYS", 30)) * 24 * time.Hour,
+			Interval: time.Duration(getEnvAsInt("COMPACTION_INTERVAL_MINUTES", 60)) * time.Minute,
+		},
+		TLSAudit: TLSAuditConfig{
+			Interval:        time.Duration(getEnvAsInt("TLS_AUDIT_INTERVAL_MINUTES", 1440)) * time.Minute,
+			ExpiryAlertDays: getEnvAsInt("TLS_EXPIRY_ALERT_DAYS", 14),
+		},
+		Usage: UsageConfig{
+			CostPerTest:      getEnvAsFloat("USAGE_COST_PER_TEST", 0),
+			CostPerRunMinute: getEnvAsFloat("USAGE_COST_PER_RUN_MINUTE", 0),
+			CostPerGB:        getEnvAsFloat("USAGE_COST_PER_GB", 0),
+			Currency:         getEnv("USAGE_CURRENCY", "USD"),
+		},
 	}
 }
 
@@ -79,3 +328,32 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList splits a comma-separated variable, skipping empty items
+func getEnvAsList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
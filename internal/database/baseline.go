@@ -0,0 +1,311 @@
+package database
+
+import "time"
+
+// The tables of the baseline migration, frozen as the models were when
+// migrations were versioned. The baseline creates and upgrades tables from
+// these copies rather than from the models, so the schema it produces never
+// changes: later changes of the models get their own migration. Only the
+// columns, indexes and constraints matter here, so JSON columns are plain
+// strings.
+
+type baselineProject struct {
+	ID            string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name          string `gorm:"uniqueIndex;not null"`
+	Description   string
+	LeakDetection string `gorm:"type:jsonb;default:'{}'"`
+	Quotas        string `gorm:"type:jsonb;default:'{}'"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (baselineProject) TableName() string { return "projects" }
+
+type baselineService struct {
+	ID              string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name            string `gorm:"uniqueIndex;not null"`
+	Description     string
+	BaseURL         string `gorm:"not null"`
+	Protocol        string `gorm:"default:'http'"`
+	GRPC            string `gorm:"type:jsonb;default:'{}'"`
+	TLS             string `gorm:"type:jsonb;default:'{}'"`
+	Discovery       string `gorm:"type:jsonb;default:'{}'"`
+	AuthConfig      string `gorm:"type:jsonb;default:'{}'"`
+	Variables       string `gorm:"type:jsonb;default:'{}'"`
+	APIVersioning   string `gorm:"type:jsonb;default:'{}'"`
+	LatencyBudgetMs int    `gorm:"default:0"`
+	TimeoutMs       int    `gorm:"default:0"`
+	Region          string
+	Notifications   string           `gorm:"type:jsonb;default:'{}'"`
+	TLSAudit        *string          `gorm:"type:jsonb"`
+	ProjectID       *string          `gorm:"type:uuid;index"`
+	Project         *baselineProject `gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	IsActive        bool `gorm:"default:true"`
+}
+
+func (baselineService) TableName() string { return "services" }
+
+type baselineEnvironment struct {
+	ID          string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+	Variables   string           `gorm:"type:jsonb;default:'{}'"`
+	ProjectID   *string          `gorm:"type:uuid;index"`
+	Project     *baselineProject `gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (baselineEnvironment) TableName() string { return "environments" }
+
+type baselineSchedule struct {
+	ID             string           `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name           string           `gorm:"uniqueIndex;not null"`
+	CronExpression string           `gorm:"not null"`
+	Timezone       string           `gorm:"default:'UTC'"`
+	ServiceID      *string          `gorm:"type:uuid"`
+	Service        *baselineService `gorm:"constraint:OnDelete:RESTRICT"`
+	TestIDs        string           `gorm:"type:jsonb;default:'[]'"`
+	EnvironmentID  *string          `gorm:"type:uuid"`
+	Variables      string           `gorm:"type:jsonb;default:'{}'"`
+	MaxConcurrency int              `gorm:"default:1"`
+	Enabled        bool             `gorm:"not null"`
+	NextRunAt      *time.Time       `gorm:"index"`
+	LastRunAt      *time.Time
+	LastRunID      *string          `gorm:"type:uuid"`
+	ProjectID      *string          `gorm:"type:uuid;index"`
+	Project        *baselineProject `gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (baselineSchedule) TableName() string { return "schedules" }
+
+type baselineHook struct {
+	ID              string           `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name            string           `gorm:"uniqueIndex;not null"`
+	Token           string           `gorm:"not null"`
+	SuiteID         *string          `gorm:"type:uuid"`
+	ServiceID       *string          `gorm:"type:uuid"`
+	Service         *baselineService `gorm:"constraint:OnDelete:RESTRICT"`
+	TestIDs         string           `gorm:"type:jsonb;default:'[]'"`
+	EnvironmentID   *string          `gorm:"type:uuid"`
+	Variables       string           `gorm:"type:jsonb;default:'{}'"`
+	Metadata        string           `gorm:"type:jsonb;default:'{}'"`
+	Events          string           `gorm:"type:jsonb;default:'[]'"`
+	MaxConcurrency  int              `gorm:"default:1"`
+	Enabled         bool             `gorm:"not null"`
+	LastTriggeredAt *time.Time
+	LastRunID       *string          `gorm:"type:uuid"`
+	ProjectID       *string          `gorm:"type:uuid;index"`
+	Project         *baselineProject `gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (baselineHook) TableName() string { return "hooks" }
+
+type baselineTestCase struct {
+	ID          string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	ServiceID   string `gorm:"type:uuid;not null;index"`
+	Name        string `gorm:"not null"`
+	Description string
+	TestSpec    string `gorm:"type:jsonb;not null"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	IsActive    bool            `gorm:"default:true"`
+	Tags        string          `gorm:"type:jsonb;default:'[]'"`
+	Service     baselineService `gorm:"foreignKey:ServiceID;references:ID;constraint:OnDelete:RESTRICT"`
+}
+
+func (baselineTestCase) TableName() string { return "test_cases" }
+
+type baselineArchivedTestCase struct {
+	ID          string `gorm:"primarykey;type:uuid"`
+	ServiceID   string `gorm:"type:uuid;index"`
+	ServiceName string
+	Name        string
+	Description string
+	TestSpec    string `gorm:"type:jsonb"`
+	Tags        string `gorm:"type:jsonb;default:'[]'"`
+	IsActive    bool
+	CreatedAt   time.Time
+	ArchivedAt  time.Time `gorm:"index"`
+}
+
+func (baselineArchivedTestCase) TableName() string { return "archived_test_cases" }
+
+type baselineTestSuite struct {
+	ID          string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+	ServiceID   *string          `gorm:"type:uuid"`
+	Service     *baselineService `gorm:"constraint:OnDelete:RESTRICT"`
+	TestIDs     string           `gorm:"type:jsonb;default:'[]'"`
+	BeforeAll   string           `gorm:"type:jsonb;default:'[]'"`
+	AfterAll    string           `gorm:"type:jsonb;default:'[]'"`
+	BeforeEach  string           `gorm:"type:jsonb;default:'[]'"`
+	AfterEach   string           `gorm:"type:jsonb;default:'[]'"`
+	Parallel    *bool
+	MaxParallel int              `gorm:"default:0"`
+	ProjectID   *string          `gorm:"type:uuid;index"`
+	Project     *baselineProject `gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (baselineTestSuite) TableName() string { return "test_suites" }
+
+type baselineTestRun struct {
+	ID                string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name              string
+	Status            string    `gorm:"default:'running';check:status IN ('running', 'completed', 'failed', 'cancelled')"`
+	TotalTests        int       `gorm:"default:0"`
+	PassedTests       int       `gorm:"default:0"`
+	FailedTests       int       `gorm:"default:0"`
+	SkippedTests      int       `gorm:"default:0"`
+	TimedOutTests     int       `gorm:"default:0"`
+	ExecutionTimeMs   int64     `gorm:"default:0"`
+	StartedAt         time.Time `gorm:"index"`
+	CompletedAt       *time.Time
+	MaxConcurrency    int     `gorm:"default:1"`
+	EnvironmentID     *string `gorm:"type:uuid"`
+	VariableOverrides string  `gorm:"type:jsonb;default:'{}'"`
+	ResolvedVariables string  `gorm:"type:jsonb;default:'{}'"`
+	APIVersions       string  `gorm:"type:jsonb;default:'[]'"`
+	LatencyBudgetMs   int     `gorm:"default:0"`
+	TestTimeoutMs     int     `gorm:"default:0"`
+	RunTimeoutMs      int     `gorm:"default:0"`
+	ScheduleID        *string `gorm:"type:uuid;index"`
+	HookID            *string `gorm:"type:uuid;index"`
+	SuiteID           *string `gorm:"type:uuid;index"`
+	RerunOfID         *string `gorm:"type:uuid;index"`
+	RetryPolicy       string  `gorm:"type:jsonb;default:'{}'"`
+	Regions           string  `gorm:"type:jsonb;default:'[]'"`
+	HookResults       string  `gorm:"type:jsonb;default:'[]'"`
+	StatusSummary     string  `gorm:"type:jsonb;default:'{}'"`
+	Compacted         bool    `gorm:"default:false;index"`
+	CompactedAt       *time.Time
+	Config            string               `gorm:"type:jsonb;default:'{}'"`
+	Debug             bool                 `gorm:"default:false"`
+	DebugLog          string               `gorm:"type:jsonb;default:'{}'"`
+	SecurityScan      bool                 `gorm:"default:false"`
+	DetectLeaks       bool                 `gorm:"default:false"`
+	SecurityFindings  string               `gorm:"type:jsonb;default:'[]'"`
+	ProjectID         *string              `gorm:"type:uuid;index"`
+	Project           *baselineProject     `gorm:"constraint:OnDelete:RESTRICT"`
+	TestResults       []baselineTestResult `gorm:"foreignKey:TestRunID"`
+}
+
+func (baselineTestRun) TableName() string { return "test_runs" }
+
+type baselineTestResult struct {
+	ID              string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	TestRunID       string `gorm:"not null;index;index:idx_test_results_run_position,priority:1;index:idx_test_results_run_status,priority:1"`
+	TestCaseID      string `gorm:"type:uuid;index:idx_test_results_case_created,priority:1"`
+	Position        int    `gorm:"default:0;index:idx_test_results_run_position,priority:2"`
+	APIVersion      string
+	Region          string `gorm:"index"`
+	Status          string `gorm:"not null;check:status IN ('passed', 'failed', 'skipped', 'timed_out');index:idx_test_results_run_status,priority:2;index:idx_test_results_status_created,priority:1"`
+	ExecutionTimeMs int    `gorm:"default:0"`
+	ErrorMessage    string
+	FailureType     string `gorm:"index"`
+	FailureDetail   string
+	Iteration       int              `gorm:"default:0"`
+	Attempts        int              `gorm:"default:0"`
+	Flaky           bool             `gorm:"default:false;index"`
+	DataRow         *string          `gorm:"type:jsonb"`
+	ResponseData    string           `gorm:"type:jsonb"`
+	Request         string           `gorm:"type:jsonb"`
+	CreatedAt       time.Time        `gorm:"index:idx_test_results_status_created,priority:2;index:idx_test_results_case_created,priority:2"`
+	TestCase        baselineTestCase `gorm:"foreignKey:TestCaseID;references:ID;constraint:OnDelete:SET NULL"`
+}
+
+func (baselineTestResult) TableName() string { return "test_results" }
+
+type baselineAssertionResult struct {
+	ID           string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	TestResultID string `gorm:"type:uuid;not null;index"`
+	Position     int    `gorm:"default:0"`
+	Variant      string
+	Type         string `gorm:"not null"`
+	Path         string
+	Matcher      string
+	Expected     *string `gorm:"type:jsonb"`
+	Actual       *string `gorm:"type:jsonb"`
+	Passed       bool
+	Message      string
+	CreatedAt    time.Time
+}
+
+func (baselineAssertionResult) TableName() string { return "assertion_results" }
+
+type baselineFixture struct {
+	ID            string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name          string `gorm:"uniqueIndex;not null"`
+	Description   string
+	LatestVersion int              `gorm:"default:0"`
+	ProjectID     *string          `gorm:"type:uuid;index"`
+	Project       *baselineProject `gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Versions      []baselineFixtureVersion `gorm:"foreignKey:FixtureID"`
+}
+
+func (baselineFixture) TableName() string { return "fixtures" }
+
+type baselineFixtureVersion struct {
+	ID          string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	FixtureID   string `gorm:"type:uuid;not null;uniqueIndex:idx_fixture_version"`
+	Version     int    `gorm:"not null;uniqueIndex:idx_fixture_version"`
+	FileName    string
+	ContentType string
+	SizeBytes   int64
+	Checksum    string
+	Content     []byte `gorm:"type:bytea"`
+	StorageKey  string
+	CreatedAt   time.Time
+}
+
+func (baselineFixtureVersion) TableName() string { return "fixture_versions" }
+
+type baselineUser struct {
+	ID        string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Email     string `gorm:"uniqueIndex;not null"`
+	Name      string
+	Role      string `gorm:"not null;default:'viewer';check:role IN ('admin', 'editor', 'viewer')"`
+	Disabled  bool   `gorm:"default:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineAPIKey struct {
+	ID         string        `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	UserID     string        `gorm:"type:uuid;not null;index"`
+	User       *baselineUser `gorm:"constraint:OnDelete:CASCADE"`
+	Name       string
+	Prefix     string
+	KeyHash    string `gorm:"uniqueIndex;not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func (baselineAPIKey) TableName() string { return "api_keys" }
+
+type baselineWorker struct {
+	ID              string `gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name            string `gorm:"uniqueIndex;not null"`
+	Region          string `gorm:"index;not null"`
+	Pool            string `gorm:"index"`
+	Capacity        int    `gorm:"not null"`
+	ActiveJobs      int
+	LastHeartbeatAt time.Time `gorm:"index"`
+	CreatedAt       time.Time
+}
+
+func (baselineWorker) TableName() string { return "workers" }
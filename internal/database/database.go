@@ -2,9 +2,12 @@ package database
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 
 	"api-test-framework/internal/config"
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/secrets"
 
 	"github.com/go-redis/redis/v8"
 	"gorm.io/driver/postgres"
@@ -12,8 +15,17 @@ import (
 )
 
 var DB *gorm.DB
+var ReplicaDB *gorm.DB
 var RedisClient *redis.Client
 
+// logger logs the connections, migrations and partition maintenance
+var logger = slog.Default()
+
+// SetLogger sets the logger of the package
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
 // Init initializes the PostgreSQL database connection
 func Init(cfg *config.Config) (*gorm.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
@@ -25,6 +37,97 @@ func Init(cfg *config.Config) (*gorm.DB, error) {
 		cfg.Database.SSLMode,
 	)
 
+	db, err := open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	DB = db
+	logger.Info("database connection established")
+	return db, nil
+}
+
+// InitSecrets enables the encryption of service auth secrets at rest, with
+// envelope encryption under an AWS KMS key when SECRETS_KMS_KEY_ID is set,
+// otherwise under SECRETS_ENCRYPTION_KEY. Without either, secrets are stored
+// as plaintext. It must run before services are read or saved.
+func InitSecrets(cfg *config.Config) error {
+	var kek secrets.KeyWrapper
+	switch {
+	case cfg.Secrets.KMSKeyID != "":
+		key, err := secrets.NewKMSKey(cfg.Secrets.KMSKeyID, cfg.Secrets.KMSRegion, cfg.Secrets.KMSEndpoint, cfg.Secrets.KMSAccessKey, cfg.Secrets.KMSSecretKey)
+		if err != nil {
+			return fmt.Errorf("secrets: %v", err)
+		}
+		kek = key
+	case cfg.Secrets.EncryptionKey != "":
+		key, err := secrets.NewLocalKey(cfg.Secrets.EncryptionKey)
+		if err != nil {
+			return fmt.Errorf("secrets: %v", err)
+		}
+		kek = key
+	default:
+		logger.Warn("SECRETS_ENCRYPTION_KEY is not set, service auth secrets are stored as plaintext")
+		return nil
+	}
+
+	models.SetSecretCipher(secrets.NewEnvelope(kek))
+	return nil
+}
+
+// EncryptStoredSecrets rewrites the auth configs of all services and the
+// auth overrides of all test cases, so secrets stored as plaintext before
+// InitSecrets enabled encryption get encrypted, and all secrets move to the
+// current key. It returns how many services and test cases were rewritten.
+func EncryptStoredSecrets(db *gorm.DB) (int, error) {
+	var services []models.Service
+	if err := db.Select("id", "auth_config").Where("auth_config <> ?", "{}").Find(&services).Error; err != nil {
+		return 0, err
+	}
+	for i, service := range services {
+		if err := db.Model(&models.Service{}).Where("id = ?", service.ID).UpdateColumn("auth_config", service.AuthConfig).Error; err != nil {
+			return i, fmt.Errorf("failed to encrypt the secrets of service %s: %v", service.ID, err)
+		}
+	}
+
+	var testCases []models.TestCase
+	err := db.Select("id", "test_spec").
+		Where("test_spec->'request'->'auth' IS NOT NULL OR test_spec->'conflict' IS NOT NULL").Find(&testCases).Error
+	if err != nil {
+		return len(services), err
+	}
+	for i, testCase := range testCases {
+		spec, err := models.EncryptTestSpec(testCase.TestSpec)
+		if err == nil {
+			err = db.Model(&models.TestCase{}).Where("id = ?", testCase.ID).UpdateColumn("test_spec", spec).Error
+		}
+		if err != nil {
+			return len(services) + i, fmt.Errorf("failed to encrypt the secrets of test case %s: %v", testCase.ID, err)
+		}
+	}
+	return len(services) + len(testCases), nil
+}
+
+// InitReplica initializes the connection to the read-only replica used by
+// reporting queries. Without DB_REPLICA_DSN it returns the primary connection.
+func InitReplica(cfg *config.Config, primary *gorm.DB) (*gorm.DB, error) {
+	if cfg.Database.ReplicaDSN == "" {
+		ReplicaDB = primary
+		return primary, nil
+	}
+
+	db, err := open(cfg.Database.ReplicaDSN)
+	if err != nil {
+		return nil, fmt.Errorf("replica: %v", err)
+	}
+
+	ReplicaDB = db
+	logger.Info("read replica connection established")
+	return db, nil
+}
+
+// open connects to a PostgreSQL database and verifies the connection
+func open(dsn string) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
@@ -39,12 +142,146 @@ func Init(cfg *config.Config) (*gorm.DB, error) {
 	if err := sqlDB.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %v", err)
 	}
-
-	DB = db
-	log.Println("Database connection established successfully")
 	return db, nil
 }
 
+// AutoMigrate applies the pending migrations.
+//
+// Deprecated: use Migrate.
+func AutoMigrate(db *gorm.DB, monthsAhead int) error {
+	return Migrate(db, monthsAhead)
+}
+
+// baseline brings a database to the schema of version 1, frozen in
+// baseline.go, creating it on a fresh database and upgrading databases
+// created by AutoMigrate before migrations were versioned. test_results is
+// created partitioned by month.
+func baseline(db *gorm.DB) error {
+	if err := createPartitionedResults(db); err != nil {
+		return fmt.Errorf("failed to create partitioned test_results: %v", err)
+	}
+	if err := dropOutdatedStatusCheck(db); err != nil {
+		return fmt.Errorf("failed to migrate test_results status check: %v", err)
+	}
+	if err := migrateServiceReferences(db); err != nil {
+		return fmt.Errorf("failed to migrate service references: %v", err)
+	}
+
+	return db.AutoMigrate(
+		&baselineProject{},
+		&baselineService{},
+		&baselineEnvironment{},
+		&baselineTestCase{},
+		&baselineArchivedTestCase{},
+		&baselineTestRun{},
+		&baselineTestResult{},
+		&baselineAssertionResult{},
+		&baselineFixture{},
+		&baselineFixtureVersion{},
+		&baselineSchedule{},
+		&baselineHook{},
+		&baselineTestSuite{},
+		&baselineWorker{},
+		&baselineUser{},
+		&baselineAPIKey{},
+	)
+}
+
+// resultStatusCheck is the check constraint GORM creates for the status of
+// test results
+const resultStatusCheck = "chk_test_results_status"
+
+// dropOutdatedStatusCheck drops the status check of test_results when it
+// predates the timed_out status. AutoMigrate only creates missing
+// constraints, so it adds the check back with the current statuses.
+func dropOutdatedStatusCheck(db *gorm.DB) error {
+	definition, err := constraintDefinition(db, resultsTable, resultStatusCheck)
+	if err != nil || definition == "" || strings.Contains(definition, "timed_out") {
+		return err
+	}
+	return db.Exec(`ALTER TABLE ` + resultsTable + ` DROP CONSTRAINT ` + resultStatusCheck).Error
+}
+
+// constraintDefinition returns the definition of a constraint of a table,
+// empty when the table has no such constraint
+func constraintDefinition(db *gorm.DB, table, constraint string) (string, error) {
+	var definition string
+	err := db.Raw(`SELECT pg_get_constraintdef(c.oid) FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		WHERE c.conname = ? AND t.relname = ? AND pg_table_is_visible(t.oid)`, constraint, table).Scan(&definition).Error
+	return definition, err
+}
+
+// serviceReferences are the foreign keys referencing services and test cases
+// with the delete action they require: 'r' restricts, 'n' sets null
+var serviceReferences = []struct {
+	table, constraint, action string
+}{
+	{"test_cases", "fk_test_cases_service", "r"},
+	{"test_suites", "fk_test_suites_service", "r"},
+	{"schedules", "fk_schedules_service", "r"},
+	{"hooks", "fk_hooks_service", "r"},
+	{resultsTable, "fk_test_results_test_case", "n"},
+}
+
+// migrateServiceReferences prepares the foreign keys of services and test
+// cases for AutoMigrate. References left dangling by services deleted before
+// the keys existed are resolved the way deleting a service does now: test
+// cases are archived, their results detached, and suites, schedules and
+// hooks lose their service. Keys with an outdated delete action are dropped
+// for AutoMigrate to create them again.
+func migrateServiceReferences(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&baselineTestCase{}) || !db.Migrator().HasTable(&baselineService{}) {
+		return nil
+	}
+	if err := db.AutoMigrate(&baselineArchivedTestCase{}); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		orphaned := `NOT EXISTS (SELECT 1 FROM services WHERE services.id = test_cases.service_id)`
+		statements := []string{
+			`INSERT INTO archived_test_cases (id, service_id, service_name, name, description, test_spec, tags, is_active, created_at, archived_at)
+				SELECT id, service_id, '', name, description, test_spec, tags, is_active, created_at, now() FROM test_cases
+				WHERE ` + orphaned + ` ON CONFLICT (id) DO NOTHING`,
+		}
+		if tx.Migrator().HasTable(resultsTable) {
+			statements = append(statements,
+				`ALTER TABLE `+resultsTable+` ALTER COLUMN test_case_id DROP NOT NULL`,
+				`UPDATE `+resultsTable+` SET test_case_id = NULL WHERE test_case_id IS NOT NULL AND NOT EXISTS
+					(SELECT 1 FROM test_cases WHERE test_cases.id = `+resultsTable+`.test_case_id AND NOT `+orphaned+`)`)
+		}
+		statements = append(statements, `DELETE FROM test_cases WHERE `+orphaned)
+		for _, table := range []string{"test_suites", "schedules", "hooks"} {
+			if tx.Migrator().HasTable(table) {
+				statements = append(statements, `UPDATE `+table+` SET service_id = NULL WHERE service_id IS NOT NULL AND NOT EXISTS
+					(SELECT 1 FROM services WHERE services.id = `+table+`.service_id)`)
+			}
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		for _, reference := range serviceReferences {
+			var action string
+			err := tx.Raw(`SELECT c.confdeltype::text FROM pg_constraint c
+				JOIN pg_class t ON t.oid = c.conrelid
+				WHERE c.conname = ? AND t.relname = ? AND pg_table_is_visible(t.oid)`, reference.constraint, reference.table).Scan(&action).Error
+			if err != nil {
+				return err
+			}
+			if action != "" && action != reference.action {
+				if err := tx.Exec(`ALTER TABLE ` + reference.table + ` DROP CONSTRAINT ` + reference.constraint).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
 // InitRedis initializes the Redis connection
 func InitRedis(cfg *config.Config) (*redis.Client, error) {
 	client := redis.NewClient(&redis.Options{
@@ -60,6 +297,6 @@ func InitRedis(cfg *config.Config) (*redis.Client, error) {
 	}
 
 	RedisClient = client
-	log.Println("Redis connection established successfully")
+	logger.Info("Redis connection established")
 	return client, nil
 }
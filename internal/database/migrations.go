@@ -0,0 +1,152 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationLock is the key of the advisory lock serializing the migrations
+// of instances starting at the same time
+const migrationLock = 0x6d696772
+
+// migration is a versioned change of the schema. Migrations are applied in
+// the order of their versions, each once and in its own transaction, and
+// recorded in schema_migrations.
+//
+// Released migrations are never edited: a change of the models gets a new
+// migration, e.g. ALTER TABLE ... ADD COLUMN IF NOT EXISTS. The baseline
+// creates the frozen schema of version 1, never the current models, so every
+// database reaches the current schema through the same migrations. They
+// still tolerate objects that exist, as databases created before migrations
+// were versioned may have them already.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *gorm.DB) error
+}
+
+// migrations are the migrations of the schema, by ascending version
+var migrations = []migration{
+	{1, "baseline", baseline},
+	{2, "lookup_indexes", lookupIndexes},
+	{3, "suite_test_exports", func(tx *gorm.DB) error {
+		return tx.Exec(`ALTER TABLE test_suites ADD COLUMN IF NOT EXISTS test_exports jsonb DEFAULT '{}'`).Error
+	}},
+	{4, "service_redaction", func(tx *gorm.DB) error {
+		return tx.Exec(`ALTER TABLE services ADD COLUMN IF NOT EXISTS redaction jsonb DEFAULT '{}'`).Error
+	}},
+	{5, "service_dependencies", func(tx *gorm.DB) error {
+		return tx.Exec(`ALTER TABLE services ADD COLUMN IF NOT EXISTS depends_on jsonb DEFAULT '[]'`).Error
+	}},
+	{6, "suite_tags", func(tx *gorm.DB) error {
+		return tx.Exec(`ALTER TABLE test_suites ADD COLUMN IF NOT EXISTS tags jsonb DEFAULT '[]'`).Error
+	}},
+	{7, "run_status_cancelled", allowCancelledRuns},
+	{8, "run_error", func(tx *gorm.DB) error {
+		return tx.Exec(`ALTER TABLE test_runs ADD COLUMN IF NOT EXISTS error text`).Error
+	}},
+}
+
+// SchemaMigration records an applied migration
+type SchemaMigration struct {
+	Version   int       `json:"version" gorm:"primarykey;autoIncrement:false"`
+	Name      string    `json:"name" gorm:"not null"`
+	AppliedAt time.Time `json:"applied_at" gorm:"not null"`
+}
+
+// TableName returns the table of applied migrations
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrate applies the pending migrations, then creates the partitions of
+// test_results for the current month and the next monthsAhead months.
+func Migrate(db *gorm.DB, monthsAhead int) error {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+	for _, m := range migrations {
+		applied, err := applyMigration(db, m)
+		if err != nil {
+			return fmt.Errorf("migration %d %s failed: %v", m.version, m.name, err)
+		}
+		if applied {
+			logger.Info("applied migration", "version", m.version, "name", m.name)
+		}
+	}
+	return EnsureResultPartitions(db, time.Now(), monthsAhead)
+}
+
+// applyMigration applies a migration unless it was applied already, and
+// reports whether it did. The advisory lock keeps other instances from
+// applying it at the same time; it is released with the transaction.
+func applyMigration(db *gorm.DB, m migration) (bool, error) {
+	applied := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`SELECT pg_advisory_xact_lock(?)`, migrationLock).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&SchemaMigration{}).Where("version = ?", m.version).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := m.up(tx); err != nil {
+			return err
+		}
+		applied = true
+		return tx.Create(&SchemaMigration{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error
+	})
+	return applied, err
+}
+
+// AppliedMigrations lists the migrations applied to a database, by version
+func AppliedMigrations(db *gorm.DB) ([]SchemaMigration, error) {
+	var applied []SchemaMigration
+	if err := db.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// LatestMigration returns the version of the newest migration, which an
+// up-to-date database has applied
+func LatestMigration() int {
+	return migrations[len(migrations)-1].version
+}
+
+// lookupIndexes adds the indexes of the most frequent lookups: results by
+// run, test cases by service and runs by start time
+func lookupIndexes(tx *gorm.DB) error {
+	for _, statement := range []string{
+		`CREATE INDEX IF NOT EXISTS idx_test_results_test_run_id ON ` + resultsTable + ` (test_run_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_test_cases_service_id ON test_cases (service_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_test_runs_started_at ON test_runs (started_at)`,
+	} {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runStatusCheck is the check constraint GORM creates for the status of runs
+const runStatusCheck = "chk_test_runs_status"
+
+// allowCancelledRuns recreates the status check of test_runs when it
+// predates the cancelled status. AutoMigrate never replaces an existing
+// constraint, so databases created before runs could be cancelled kept
+// rejecting them.
+func allowCancelledRuns(tx *gorm.DB) error {
+	definition, err := constraintDefinition(tx, "test_runs", runStatusCheck)
+	if err != nil || definition == "" || strings.Contains(definition, "cancelled") {
+		return err
+	}
+	return tx.Exec(`ALTER TABLE test_runs DROP CONSTRAINT ` + runStatusCheck +
+		`, ADD CONSTRAINT ` + runStatusCheck + ` CHECK (status IN ('running', 'completed', 'failed', 'cancelled'))`).Error
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// resultsTable is the table partitioned by month
+const resultsTable = "test_results"
+
+// createPartitionedResults creates test_results as a table partitioned by
+// month of created_at when it does not exist yet. Only the partition key and
+// primary key are declared here; AutoMigrate adds the remaining columns.
+// An existing unpartitioned table is left as it is.
+func createPartitionedResults(db *gorm.DB) error {
+	if db.Migrator().HasTable(resultsTable) {
+		if !isPartitioned(db, resultsTable) {
+			logger.Warn("table is not partitioned, see the README to convert it", "table", resultsTable)
+		}
+		return nil
+	}
+
+	return db.Exec(`CREATE TABLE ` + resultsTable + ` (
+		id uuid NOT NULL DEFAULT gen_random_uuid(),
+		created_at timestamptz NOT NULL,
+		PRIMARY KEY (id, created_at)
+	) PARTITION BY RANGE (created_at)`).Error
+}
+
+// isPartitioned reports whether a table is a partitioned table
+func isPartitioned(db *gorm.DB, table string) bool {
+	var count int64
+	db.Raw(`SELECT count(*) FROM pg_partitioned_table p
+		JOIN pg_class c ON c.oid = p.partrelid
+		WHERE c.relname = ? AND pg_table_is_visible(c.oid)`, table).Scan(&count)
+	return count > 0
+}
+
+// EnsureResultPartitions creates the monthly partitions of test_results from
+// the month of now through monthsAhead months later, and a default partition
+// for rows outside of them. It does nothing when the table is not partitioned.
+func EnsureResultPartitions(db *gorm.DB, now time.Time, monthsAhead int) error {
+	if !isPartitioned(db, resultsTable) {
+		return nil
+	}
+
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + resultsTable + `_default PARTITION OF ` + resultsTable + ` DEFAULT`).Error; err != nil {
+		return fmt.Errorf("failed to create default partition: %v", err)
+	}
+
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= monthsAhead; i++ {
+		from := month.AddDate(0, i, 0)
+		to := from.AddDate(0, 1, 0)
+		name := fmt.Sprintf("%s_y%04dm%02d", resultsTable, from.Year(), int(from.Month()))
+		sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+			name, resultsTable, from.Format("2006-01-02"), to.Format("2006-01-02"))
+		if err := db.Exec(sql).Error; err != nil {
+			return fmt.Errorf("failed to create partition %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// RunPartitionMaintenance keeps the partitions of the coming months in place,
+// checking every interval until ctx is done
+func RunPartitionMaintenance(ctx context.Context, db *gorm.DB, monthsAhead int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := EnsureResultPartitions(db.WithContext(ctx), time.Now(), monthsAhead); err != nil {
+			logger.Error("partition maintenance failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// Discovery providers
+const (
+	ProviderKubernetes = "kubernetes"
+	ProviderConsul     = "consul"
+)
+
+// Resolver resolves the base URL of services through Kubernetes DNS or the
+// Consul catalog
+type Resolver struct {
+	clusterDomain string
+	consulAddress string
+	consulToken   string
+	httpClient    *http.Client
+	resolver      *net.Resolver
+}
+
+// NewResolver creates a resolver. clusterDomain defaults to cluster.local and
+// consulAddress to the local agent.
+func NewResolver(clusterDomain, consulAddress, consulToken string) *Resolver {
+	if clusterDomain == "" {
+		clusterDomain = "cluster.local"
+	}
+	if consulAddress == "" {
+		consulAddress = "http://127.0.0.1:8500"
+	}
+	// CONSUL_HTTP_ADDR is commonly given without scheme
+	if !strings.Contains(consulAddress, "://") {
+		consulAddress = "http://" + consulAddress
+	}
+	return &Resolver{
+		clusterDomain: strings.Trim(clusterDomain, "."),
+		consulAddress: strings.TrimSuffix(consulAddress, "/"),
+		consulToken:   consulToken,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		resolver:      net.DefaultResolver,
+	}
+}
+
+// Resolve returns the base URL of a service in an environment
+func (r *Resolver) Resolve(ctx context.Context, config models.ServiceDiscovery, environment string) (string, error) {
+	if config.Name == "" {
+		return "", fmt.Errorf("service discovery requires the name of the service")
+	}
+
+	var host string
+	var err error
+	switch config.Provider {
+	case ProviderKubernetes:
+		host, err = r.resolveKubernetes(ctx, config, config.NamespaceFor(environment))
+	case ProviderConsul:
+		host, err = r.resolveConsul(ctx, config, config.NamespaceFor(environment))
+	default:
+		return "", fmt.Errorf("unknown discovery provider %q", config.Provider)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	scheme := config.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	baseURL := scheme + "://" + host
+	if path := strings.Trim(config.Path, "/"); path != "" {
+		baseURL += "/" + path
+	}
+	return baseURL, nil
+}
+
+// resolveKubernetes returns the cluster DNS name of a service, with its port.
+// The name is looked up so services that do not exist fail the resolution;
+// named ports are resolved through the SRV records of the service.
+func (r *Resolver) resolveKubernetes(ctx context.Context, config models.ServiceDiscovery, namespace string) (string, error) {
+	if namespace == "" {
+		namespace = "default"
+	}
+	host := fmt.Sprintf("%s.%s.svc.%s", config.Name, namespace, r.clusterDomain)
+
+	if _, err := r.resolver.LookupHost(ctx, host); err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+
+	if config.Port == "" {
+		return host, nil
+	}
+	if _, err := strconv.Atoi(config.Port); err == nil {
+		return net.JoinHostPort(host, config.Port), nil
+	}
+
+	_, records, err := r.resolver.LookupSRV(ctx, config.Port, "tcp", host)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve port %s of %s: %v", config.Port, host, err)
+	}
+	if len(records) == 0 {
+		return "", fmt.Errorf("service %s has no port named %s", host, config.Port)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(records[0].Port))), nil
+}
+
+// consulServiceEntry is an instance returned by the Consul health endpoint
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+// resolveConsul returns the address of the first healthy instance of a
+// service in the Consul catalog. A port number in the config overrides the
+// registered port.
+func (r *Resolver) resolveConsul(ctx context.Context, config models.ServiceDiscovery, namespace string) (string, error) {
+	query := neturl.Values{"passing": {"true"}}
+	if config.Datacenter != "" {
+		query.Set("dc", config.Datacenter)
+	}
+	if config.Tag != "" {
+		query.Set("tag", config.Tag)
+	}
+	if namespace != "" {
+		query.Set("ns", namespace)
+	}
+	endpoint := fmt.Sprintf("%s/v1/health/service/%s?%s", r.consulAddress, neturl.PathEscape(config.Name), query.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request: %v", err)
+	}
+	if r.consulToken != "" {
+		req.Header.Set("X-Consul-Token", r.consulToken)
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("consul request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("consul returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var entries []consulServiceEntry
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return "", fmt.Errorf("invalid consul response: %v", err)
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("consul has no healthy instance of service %s", config.Name)
+	}
+
+	// Instances registered without an address use the address of their node
+	entry := entries[0]
+	address := entry.Service.Address
+	if address == "" {
+		address = entry.Node.Address
+	}
+	port := strconv.Itoa(entry.Service.Port)
+	if _, err := strconv.Atoi(config.Port); err == nil {
+		port = config.Port
+	}
+	if port == "0" {
+		return address, nil
+	}
+	return net.JoinHostPort(address, port), nil
+}
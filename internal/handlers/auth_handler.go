@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// principalKey is the gin context key of the authenticated caller
+const principalKey = "principal"
+
+// publicRoutes authenticate on their own or not at all
+var publicRoutes = map[string]bool{
+	"GET /health":                    true,
+	"GET /health/db":                 true,
+	"GET /health/redis":              true,
+	"POST /api/v1/hooks/:id/trigger": true, // authenticated by the hook token
+}
+
+// routeRoles are the roles required by routes that differ from the default:
+// viewer for reads, editor for changes
+var routeRoles = map[string]string{
+	"POST /api/v1/tests/validate":        models.RoleViewer,
+	"POST /api/v1/tests/preview":         models.RoleViewer,
+	"POST /api/v1/auth/token":            models.RoleViewer,
+	"GET /api/v1/auth/me":                models.RoleViewer,
+	"GET /api/v1/api-keys":               models.RoleViewer, // admins may pass user_id
+	"POST /api/v1/api-keys":              models.RoleViewer,
+	"DELETE /api/v1/api-keys/:id":        models.RoleViewer,
+	"POST /api/v1/projects":              models.RoleAdmin,
+	"PUT /api/v1/projects/:id":           models.RoleAdmin,
+	"DELETE /api/v1/projects/:id":        models.RoleAdmin,
+	"POST /api/v1/workers":               models.RoleAdmin,
+	"DELETE /api/v1/workers/:id":         models.RoleAdmin,
+	"POST /api/v1/workers/:id/heartbeat": models.RoleAdmin,
+}
+
+// requiredRole returns the role a route requires, empty for public routes.
+// User management is reserved to admins.
+func requiredRole(method, route string) string {
+	key := method + " " + route
+	if publicRoutes[key] {
+		return ""
+	}
+	if role, ok := routeRoles[key]; ok {
+		return role
+	}
+	if strings.HasPrefix(route, "/api/v1/users") {
+		return models.RoleAdmin
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return models.RoleViewer
+	}
+	return models.RoleEditor
+}
+
+// AuthHandler handles authentication, users and API keys
+type AuthHandler struct {
+	authService *services.AuthService
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+	return &AuthHandler{authService: authService}
+}
+
+// Middleware authenticates API requests with an API key, in X-API-Key or as
+// a bearer token, or with a JWT bearer token, and rejects callers whose role
+// is below the one the route requires (see requiredRole). It is installed on
+// the router when AUTH_ENABLED is true.
+func (h *AuthHandler) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		role := requiredRole(c.Request.Method, route)
+		// Unknown routes are left to answer 404
+		if route == "" || role == "" {
+			c.Next()
+			return
+		}
+
+		credential := c.GetHeader("X-API-Key")
+		if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+			credential = strings.TrimSpace(token)
+		}
+		principal, err := h.authService.Authenticate(c.Request.Context(), credential)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrUnauthenticated) {
+				status = http.StatusUnauthorized
+				c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			}
+			c.AbortWithStatusJSON(status, gin.H{
+				"error":   "Authentication failed",
+				"details": err.Error(),
+			})
+			return
+		}
+		if !principal.HasRole(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"details": "this route requires the " + role + " role, the caller is " + principal.Role,
+			})
+			return
+		}
+
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// principalOf returns the authenticated caller of a request, nil when
+// authentication is disabled
+func principalOf(c *gin.Context) *services.Principal {
+	if value, ok := c.Get(principalKey); ok {
+		if principal, ok := value.(*services.Principal); ok {
+			return principal
+		}
+	}
+	return nil
+}
+
+// authenticatedUser returns the user ID of the caller, answering 400 when
+// the caller is not a user
+func authenticatedUser(c *gin.Context) (string, bool) {
+	principal := principalOf(c)
+	if principal == nil || principal.UserID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Not a user",
+			"details": "this route requires the API key or token of a user",
+		})
+		return "", false
+	}
+	return principal.UserID, true
+}
+
+// CurrentUser handles GET /api/v1/auth/me
+func (h *AuthHandler) CurrentUser(c *gin.Context) {
+	principal := principalOf(c)
+	if principal == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Not authenticated",
+			"details": "authentication is disabled",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": principal,
+	})
+}
+
+// IssueToken handles POST /api/v1/auth/token
+// It exchanges the API key of a user for a short-lived JWT. Callers
+// authenticated with a JWT are rejected, so tokens cannot be renewed
+// without the key.
+func (h *AuthHandler) IssueToken(c *gin.Context) {
+	if _, ok := authenticatedUser(c); !ok {
+		return
+	}
+
+	token, expiresAt, err := h.authService.IssueToken(principalOf(c))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrTokensDisabled):
+			status = http.StatusNotImplemented
+		case errors.Is(err, services.ErrTokenExchange):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to issue token",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": gin.H{
+			"token":      token,
+			"token_type": "Bearer",
+			"expires_at": expiresAt,
+		},
+	})
+}
+
+// ListUsers handles GET /api/v1/users
+func (h *AuthHandler) ListUsers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	users, total, err := h.authService.ListUsers(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve users",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": users,
+		"meta": gin.H{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// CreateUser handles POST /api/v1/users
+func (h *AuthHandler) CreateUser(c *gin.Context) {
+	var user models.User
+	if err := c.ShouldBindJSON(&user); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.authService.CreateUser(&user); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidUser) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to create user",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": user,
+	})
+}
+
+// GetUser handles GET /api/v1/users/:id
+func (h *AuthHandler) GetUser(c *gin.Context) {
+	user, err := h.authService.GetUser(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "User not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": user,
+	})
+}
+
+// UpdateUser handles PUT /api/v1/users/:id
+// Omitted fields keep their values.
+func (h *AuthHandler) UpdateUser(c *gin.Context) {
+	var update services.UserUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	user, err := h.authService.UpdateUser(c.Param("id"), update)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrInvalidUser):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to update user",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": user,
+	})
+}
+
+// DeleteUser handles DELETE /api/v1/users/:id
+func (h *AuthHandler) DeleteUser(c *gin.Context) {
+	if err := h.authService.DeleteUser(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete user",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User deleted successfully",
+	})
+}
+
+// keyOwner returns the user whose API keys a request manages: the caller,
+// or the user_id given by an admin
+func keyOwner(c *gin.Context, userID string) (string, bool) {
+	if principal := principalOf(c); userID != "" && principal != nil && principal.HasRole(models.RoleAdmin) {
+		return userID, true
+	}
+	if userID != "" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"details": "only admins manage the API keys of other users",
+		})
+		return "", false
+	}
+	return authenticatedUser(c)
+}
+
+// ListAPIKeys handles GET /api/v1/api-keys
+// Admins list the keys of another user with user_id.
+func (h *AuthHandler) ListAPIKeys(c *gin.Context) {
+	userID, ok := keyOwner(c, c.Query("user_id"))
+	if !ok {
+		return
+	}
+
+	keys, err := h.authService.ListAPIKeys(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve API keys",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": keys,
+	})
+}
+
+// CreateAPIKey handles POST /api/v1/api-keys
+// The key is only part of this response. Admins create keys for another
+// user with user_id, e.g. the first key of a new user.
+func (h *AuthHandler) CreateAPIKey(c *gin.Context) {
+	var request struct {
+		Name      string     `json:"name"`
+		UserID    string     `json:"user_id"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	userID, ok := keyOwner(c, request.UserID)
+	if !ok {
+		return
+	}
+
+	key, apiKey, err := h.authService.CreateAPIKey(userID, request.Name, request.ExpiresAt)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrInvalidUser):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to create API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": apiKey,
+		"key":  key,
+	})
+}
+
+// DeleteAPIKey handles DELETE /api/v1/api-keys/:id
+// Admins revoke a key of another user with user_id.
+func (h *AuthHandler) DeleteAPIKey(c *gin.Context) {
+	userID, ok := keyOwner(c, c.Query("user_id"))
+	if !ok {
+		return
+	}
+
+	if err := h.authService.DeleteAPIKey(userID, c.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to revoke API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "API key revoked successfully",
+	})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+// maxDocumentSize limits imported documents (OpenAPI specs, HAR files, ...)
+const maxDocumentSize = 10 << 20
+
+// readFormFile reads an uploaded document, enforcing the size limit
+func readFormFile(fileHeader *multipart.FileHeader) ([]byte, error) {
+	if fileHeader.Size > maxDocumentSize {
+		return nil, fmt.Errorf("file exceeds the limit of %d bytes", maxDocumentSize)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
+// fetchDocument downloads a document from a URL, enforcing the size limit
+func fetchDocument(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned status %d", url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxDocumentSize {
+		return nil, fmt.Errorf("document exceeds the limit of %d bytes", maxDocumentSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnvironmentHandler handles environment-related HTTP requests
+type EnvironmentHandler struct {
+	environmentService *services.EnvironmentService
+}
+
+// NewEnvironmentHandler creates a new environment handler
+func NewEnvironmentHandler(environmentService *services.EnvironmentService) *EnvironmentHandler {
+	return &EnvironmentHandler{environmentService: environmentService}
+}
+
+// ListEnvironments handles GET /api/v1/environments
+// project_id restricts the list to a project.
+func (h *EnvironmentHandler) ListEnvironments(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	environments, total, err := h.environmentService.ListEnvironments(c.Query("project_id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve environments",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": environments,
+		"meta": gin.H{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// CreateEnvironment handles POST /api/v1/environments
+func (h *EnvironmentHandler) CreateEnvironment(c *gin.Context) {
+	var environment models.Environment
+	if err := c.ShouldBindJSON(&environment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.environmentService.CreateEnvironment(&environment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create environment",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": environment,
+	})
+}
+
+// GetEnvironment handles GET /api/v1/environments/:id
+func (h *EnvironmentHandler) GetEnvironment(c *gin.Context) {
+	id := c.Param("id")
+
+	environment, err := h.environmentService.GetEnvironment(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Environment not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": environment,
+	})
+}
+
+// UpdateEnvironment handles PUT /api/v1/environments/:id
+func (h *EnvironmentHandler) UpdateEnvironment(c *gin.Context) {
+	id := c.Param("id")
+
+	var environment models.Environment
+	if err := c.ShouldBindJSON(&environment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	updatedEnvironment, err := h.environmentService.UpdateEnvironment(id, &environment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update environment",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": updatedEnvironment,
+	})
+}
+
+// DeleteEnvironment handles DELETE /api/v1/environments/:id
+func (h *EnvironmentHandler) DeleteEnvironment(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.environmentService.DeleteEnvironment(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete environment",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Environment deleted successfully",
+	})
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FixtureHandler handles fixture-related HTTP requests
+type FixtureHandler struct {
+	fixtureService *services.FixtureService
+}
+
+// NewFixtureHandler creates a new fixture handler
+func NewFixtureHandler(fixtureService *services.FixtureService) *FixtureHandler {
+	return &FixtureHandler{fixtureService: fixtureService}
+}
+
+// ListFixtures handles GET /api/v1/fixtures
+// project_id restricts the list to a project.
+func (h *FixtureHandler) ListFixtures(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	fixtures, total, err := h.fixtureService.ListFixtures(c.Query("project_id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve fixtures",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": fixtures,
+		"meta": gin.H{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// CreateFixture handles POST /api/v1/fixtures (multipart: file, name, description, checksum)
+func (h *FixtureHandler) CreateFixture(c *gin.Context) {
+	name := c.PostForm("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": "name is required",
+		})
+		return
+	}
+
+	upload, ok := h.readUpload(c)
+	if !ok {
+		return
+	}
+
+	fixture := &models.Fixture{
+		Name:        name,
+		Description: c.PostForm("description"),
+	}
+	if err := h.fixtureService.CreateFixture(fixture, upload); err != nil {
+		h.writeUploadError(c, "Failed to create fixture", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": fixture,
+	})
+}
+
+// UploadFixtureVersion handles POST /api/v1/fixtures/:id/versions (multipart: file, checksum)
+func (h *FixtureHandler) UploadFixtureVersion(c *gin.Context) {
+	id := c.Param("id")
+
+	upload, ok := h.readUpload(c)
+	if !ok {
+		return
+	}
+
+	version, err := h.fixtureService.AddVersion(id, upload)
+	if err != nil {
+		h.writeUploadError(c, "Failed to upload fixture version", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": version,
+	})
+}
+
+// GetFixture handles GET /api/v1/fixtures/:id
+func (h *FixtureHandler) GetFixture(c *gin.Context) {
+	id := c.Param("id")
+
+	fixture, err := h.fixtureService.GetFixture(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Fixture not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": fixture,
+	})
+}
+
+// DownloadFixture handles GET /api/v1/fixtures/:id/content?version=N
+func (h *FixtureHandler) DownloadFixture(c *gin.Context) {
+	id := c.Param("id")
+	version, _ := strconv.Atoi(c.DefaultQuery("version", "0"))
+
+	fixtureVersion, err := h.fixtureService.GetFixtureVersion(id, version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Fixture version not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=\""+fixtureVersion.FileName+"\"")
+	c.Header("X-Checksum-Sha256", fixtureVersion.Checksum)
+	c.Data(http.StatusOK, fixtureVersion.ContentType, fixtureVersion.Content)
+}
+
+// DeleteFixture handles DELETE /api/v1/fixtures/:id
+func (h *FixtureHandler) DeleteFixture(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.fixtureService.DeleteFixture(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete fixture",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Fixture deleted successfully",
+	})
+}
+
+// readUpload reads the "file" form field, enforcing the configured size limit
+func (h *FixtureHandler) readUpload(c *gin.Context) (*services.FixtureUpload, bool) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": "file is required",
+		})
+		return nil, false
+	}
+
+	maxSize := h.fixtureService.MaxSizeBytes()
+	if maxSize > 0 && fileHeader.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "Fixture too large",
+			"details": "maximum size is " + strconv.FormatInt(maxSize, 10) + " bytes",
+		})
+		return nil, false
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read uploaded file",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read uploaded file",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	return &services.FixtureUpload{
+		FileName:    fileHeader.Filename,
+		ContentType: fileHeader.Header.Get("Content-Type"),
+		Content:     content,
+		Checksum:    c.PostForm("checksum"),
+	}, true
+}
+
+// writeUploadError maps fixture service errors to HTTP responses
+func (h *FixtureHandler) writeUploadError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, services.ErrInvalidFixture) {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
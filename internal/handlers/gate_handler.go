@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Bounds of how long a gate request blocks
+const (
+	defaultGateWait = 30 * time.Second
+	maxGateWait     = 10 * time.Minute
+)
+
+// GateHandler lets deployment pipelines gate promotion on a test run with a
+// single blocking call
+type GateHandler struct {
+	testRunService *services.TestRunService
+	suiteService   *services.SuiteService
+}
+
+// NewGateHandler creates a new gate handler
+func NewGateHandler(testRunService *services.TestRunService, suiteService *services.SuiteService) *GateHandler {
+	return &GateHandler{testRunService: testRunService, suiteService: suiteService}
+}
+
+// GateRequest starts the run of a gate: a suite or the tests selected as when
+// starting a run, waiting up to WaitSeconds for the verdict
+type GateRequest struct {
+	services.StartTestRunOptions
+	SuiteID     string `json:"suite_id"`
+	WaitSeconds int    `json:"wait_seconds"`
+}
+
+// StartGate handles POST /api/v1/gate
+func (h *GateHandler) StartGate(c *gin.Context) {
+	var request GateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	wait, err := gateWait(request.WaitSeconds)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid wait",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var testRun *models.TestRun
+	if request.SuiteID != "" {
+		testRun, err = h.suiteService.RunSuite(requestContext(c), request.SuiteID, request.StartTestRunOptions)
+	} else {
+		testRun, err = h.testRunService.StartTestRun(requestContext(c), request.StartTestRunOptions)
+	}
+	if quotaExceeded(c, err, "Failed to start gated run") {
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, services.ErrInvalidTimeout) || errors.Is(err, services.ErrProjectMismatch) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to start gated run",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.respond(c, testRun.ID, wait)
+}
+
+// WaitGate handles GET /api/v1/gate/:id
+// It waits for the verdict on a run started by POST /api/v1/gate, or any
+// other run. The wait query parameter is in seconds.
+func (h *GateHandler) WaitGate(c *gin.Context) {
+	seconds, _ := strconv.Atoi(c.Query("wait"))
+	wait, err := gateWait(seconds)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid wait",
+			"details": err.Error(),
+		})
+		return
+	}
+	h.respond(c, c.Param("id"), wait)
+}
+
+// respond waits for the verdict on a run. Passed runs answer 200 and failed
+// runs 422, so pipelines can gate on the status code alone; runs still
+// executing when the wait elapses answer 202 with the URL to wait on.
+func (h *GateHandler) respond(c *gin.Context, testRunID string, wait time.Duration) {
+	result, err := h.testRunService.WaitForRun(c.Request.Context(), testRunID, wait)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to wait for test run",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	switch result.Verdict {
+	case services.GatePassed:
+		c.JSON(http.StatusOK, gin.H{"data": result})
+	case services.GateFailed:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"data": result})
+	default:
+		waitURL := fmt.Sprintf("/api/v1/gate/%s?wait=%d", testRunID, int(wait.Seconds()))
+		c.Header("Location", waitURL)
+		c.JSON(http.StatusAccepted, gin.H{"data": result, "wait_url": waitURL})
+	}
+}
+
+// gateWait returns the wait of a gate request given in seconds
+func gateWait(seconds int) (time.Duration, error) {
+	if seconds == 0 {
+		return defaultGateWait, nil
+	}
+	wait := time.Duration(seconds) * time.Second
+	if seconds < 0 || wait > maxGateWait {
+		return 0, fmt.Errorf("wait must be between 1 and %d seconds", int(maxGateWait.Seconds()))
+	}
+	return wait, nil
+}
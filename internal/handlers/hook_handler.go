@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxHookPayload bounds the payload of a hook call
+const maxHookPayload = 5 << 20
+
+// HookHandler handles inbound webhook HTTP requests
+type HookHandler struct {
+	hookService *services.HookService
+}
+
+// NewHookHandler creates a new hook handler
+func NewHookHandler(hookService *services.HookService) *HookHandler {
+	return &HookHandler{hookService: hookService}
+}
+
+// ListHooks handles GET /api/v1/hooks
+// project_id restricts the list to a project.
+func (h *HookHandler) ListHooks(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	hooks, total, err := h.hookService.ListHooks(c.Query("project_id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve hooks",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": hooks,
+		"meta": gin.H{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// CreateHook handles POST /api/v1/hooks
+// The token of the hook is only part of this response.
+func (h *HookHandler) CreateHook(c *gin.Context) {
+	// Hooks are enabled unless the request disables them
+	hook := models.Hook{Enabled: true}
+	if err := c.ShouldBindJSON(&hook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	token, err := h.hookService.CreateHook(&hook)
+	if err != nil {
+		c.JSON(hookErrorStatus(err), gin.H{
+			"error":   "Failed to create hook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data":  hook,
+		"token": token,
+	})
+}
+
+// GetHook handles GET /api/v1/hooks/:id
+func (h *HookHandler) GetHook(c *gin.Context) {
+	id := c.Param("id")
+
+	hook, err := h.hookService.GetHook(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Hook not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": hook,
+	})
+}
+
+// UpdateHook handles PUT /api/v1/hooks/:id
+// Fields missing from the request keep their current values.
+func (h *HookHandler) UpdateHook(c *gin.Context) {
+	id := c.Param("id")
+
+	hook, err := h.hookService.GetHook(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Hook not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := c.ShouldBindJSON(hook); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	hook.ID = id
+
+	if err := h.hookService.UpdateHook(hook); err != nil {
+		c.JSON(hookErrorStatus(err), gin.H{
+			"error":   "Failed to update hook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": hook,
+	})
+}
+
+// DeleteHook handles DELETE /api/v1/hooks/:id
+func (h *HookHandler) DeleteHook(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.hookService.DeleteHook(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete hook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Hook deleted successfully",
+	})
+}
+
+// RotateHookToken handles POST /api/v1/hooks/:id/rotate-token
+func (h *HookHandler) RotateHookToken(c *gin.Context) {
+	id := c.Param("id")
+
+	hook, token, err := h.hookService.RotateHookToken(id)
+	if err != nil {
+		c.JSON(hookErrorStatus(err), gin.H{
+			"error":   "Failed to rotate hook token",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  hook,
+		"token": token,
+	})
+}
+
+// TriggerHook handles POST /api/v1/hooks/:id/trigger
+// Callers authenticate with the token of the hook, sent as bearer token or in
+// the X-Hook-Token or X-Gitlab-Token header, or as the secret of a GitHub
+// webhook signature. The body is the event payload.
+func (h *HookHandler) TriggerHook(c *gin.Context) {
+	id := c.Param("id")
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxHookPayload+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read payload",
+			"details": err.Error(),
+		})
+		return
+	}
+	if len(payload) > maxHookPayload {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error":   "Payload too large",
+			"details": "hook payloads are limited to 5 MB",
+		})
+		return
+	}
+
+	trigger := services.HookTrigger{
+		Token:     c.GetHeader("X-Hook-Token"),
+		Signature: c.GetHeader("X-Hub-Signature-256"),
+		Event:     c.Query("event"),
+		Payload:   payload,
+	}
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		trigger.Token = token
+	}
+	if token := c.GetHeader("X-Gitlab-Token"); token != "" {
+		trigger.Token = token
+	}
+	for _, header := range []string{"X-GitHub-Event", "X-Gitlab-Event"} {
+		if event := c.GetHeader(header); event != "" {
+			trigger.Event = event
+		}
+	}
+
+	testRun, err := h.hookService.TriggerHook(requestContext(c), id, trigger)
+	if quotaExceeded(c, err, "Failed to trigger hook") {
+		return
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrHookEventIgnored) {
+			c.JSON(http.StatusAccepted, gin.H{
+				"message": "Event ignored",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(hookErrorStatus(err), gin.H{
+			"error":   "Failed to trigger hook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": testRun,
+	})
+}
+
+// hookErrorStatus maps hook errors to their status and anything else to 500
+func hookErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrHookUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrHookDisabled):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidHook), errors.Is(err, services.ErrInvalidTimeout), errors.Is(err, services.ErrProjectMismatch):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ProjectHandler handles project-related HTTP requests
+type ProjectHandler struct {
+	projectService *services.ProjectService
+}
+
+// NewProjectHandler creates a new project handler
+func NewProjectHandler(projectService *services.ProjectService) *ProjectHandler {
+	return &ProjectHandler{projectService: projectService}
+}
+
+// ListProjects handles GET /api/v1/projects
+func (h *ProjectHandler) ListProjects(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	projects, total, err := h.projectService.ListProjects(limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve projects",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": projects,
+		"meta": gin.H{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// CreateProject handles POST /api/v1/projects
+func (h *ProjectHandler) CreateProject(c *gin.Context) {
+	var project models.Project
+	if err := c.ShouldBindJSON(&project); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.projectService.CreateProject(&project); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidProject) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to create project",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": project,
+	})
+}
+
+// GetProject handles GET /api/v1/projects/:id
+func (h *ProjectHandler) GetProject(c *gin.Context) {
+	project, err := h.projectService.GetProject(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Project not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": project,
+	})
+}
+
+// UpdateProject handles PUT /api/v1/projects/:id
+// Omitted fields keep their values.
+func (h *ProjectHandler) UpdateProject(c *gin.Context) {
+	var update services.ProjectUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	updatedProject, err := h.projectService.UpdateProject(c.Param("id"), update)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrInvalidProject):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to update project",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": updatedProject,
+	})
+}
+
+// DeleteProject handles DELETE /api/v1/projects/:id
+// Projects are only deleted once their resources are deleted or moved.
+func (h *ProjectHandler) DeleteProject(c *gin.Context) {
+	if err := h.projectService.DeleteProject(c.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrProjectNotEmpty):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to delete project",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Project deleted successfully",
+	})
+}
+
+// GetQuotaUsage handles GET /api/v1/projects/:id/quota
+// It reports the running runs, virtual users and tests started today of the
+// project next to its quotas.
+func (h *ProjectHandler) GetQuotaUsage(c *gin.Context) {
+	usage, err := h.projectService.GetQuotaUsage(c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to retrieve quota usage",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": usage,
+	})
+}
+
+// quotaExceeded responds with 429 to a run rejected by a quota of its
+// project, and reports whether err was such a rejection
+func quotaExceeded(c *gin.Context, err error, message string) bool {
+	var quotaErr *services.QuotaError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+	if quotaErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(quotaErr.RetryAfter.Seconds())+1))
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":   message,
+		"details": err.Error(),
+		"quota":   quotaErr.Quota,
+	})
+	return true
+}
+
+// GetUsageReport handles GET /api/v1/projects/usage
+// Query parameters: from and to (RFC 3339, the last 30 days by default),
+// interval (day, week or month; day by default) and project_id.
+func (h *ProjectHandler) GetUsageReport(c *gin.Context) {
+	now := time.Now().UTC()
+	query := services.UsageQuery{
+		ProjectID: c.Query("project_id"),
+		From:      now.AddDate(0, 0, -30),
+		To:        now,
+		Interval:  c.DefaultQuery("interval", "day"),
+	}
+	for param, target := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if raw := c.Query(param); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid " + param + " parameter",
+					"details": err.Error(),
+				})
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	report, err := h.projectService.GetUsageReport(query)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidUsageQuery) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to compute usage report",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": report,
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleHandler handles schedule-related HTTP requests
+type ScheduleHandler struct {
+	scheduleService *services.ScheduleService
+}
+
+// NewScheduleHandler creates a new schedule handler
+func NewScheduleHandler(scheduleService *services.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{scheduleService: scheduleService}
+}
+
+// ListSchedules handles GET /api/v1/schedules
+// project_id restricts the list to a project.
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	schedules, total, err := h.scheduleService.ListSchedules(c.Query("project_id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve schedules",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": schedules,
+		"meta": gin.H{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// CreateSchedule handles POST /api/v1/schedules
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	// Schedules are enabled unless the request disables them
+	schedule := models.Schedule{Enabled: true}
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.scheduleService.CreateSchedule(&schedule); err != nil {
+		c.JSON(scheduleErrorStatus(err), gin.H{
+			"error":   "Failed to create schedule",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": schedule,
+	})
+}
+
+// GetSchedule handles GET /api/v1/schedules/:id
+func (h *ScheduleHandler) GetSchedule(c *gin.Context) {
+	id := c.Param("id")
+
+	schedule, err := h.scheduleService.GetSchedule(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Schedule not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": schedule,
+	})
+}
+
+// UpdateSchedule handles PUT /api/v1/schedules/:id
+// Fields missing from the request keep their current values.
+func (h *ScheduleHandler) UpdateSchedule(c *gin.Context) {
+	id := c.Param("id")
+
+	schedule, err := h.scheduleService.GetSchedule(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Schedule not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := c.ShouldBindJSON(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	schedule.ID = id
+
+	if err := h.scheduleService.UpdateSchedule(schedule); err != nil {
+		c.JSON(scheduleErrorStatus(err), gin.H{
+			"error":   "Failed to update schedule",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": schedule,
+	})
+}
+
+// DeleteSchedule handles DELETE /api/v1/schedules/:id
+func (h *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.scheduleService.DeleteSchedule(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete schedule",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Schedule deleted successfully",
+	})
+}
+
+// scheduleErrorStatus maps validation errors to 400 and anything else to 500
+func scheduleErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidSchedule) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -1,31 +1,37 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"api-test-framework/internal/models"
 	"api-test-framework/internal/services"
+	"api-test-framework/internal/testrunner"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // ServiceHandler handles service-related HTTP requests
 type ServiceHandler struct {
-	serviceService *services.ServiceService
+	serviceService  *services.ServiceService
+	tlsAuditService *services.TLSAuditService
+	suiteService    *services.SuiteService
 }
 
 // NewServiceHandler creates a new service handler
-func NewServiceHandler(serviceService *services.ServiceService) *ServiceHandler {
-	return &ServiceHandler{serviceService: serviceService}
+func NewServiceHandler(serviceService *services.ServiceService, tlsAuditService *services.TLSAuditService, suiteService *services.SuiteService) *ServiceHandler {
+	return &ServiceHandler{serviceService: serviceService, tlsAuditService: tlsAuditService, suiteService: suiteService}
 }
 
 // ListServices handles GET /api/v1/services
+// project_id restricts the list to a project.
 func (h *ServiceHandler) ListServices(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	services, total, err := h.serviceService.ListServices(limit, offset)
+	services, total, err := h.serviceService.ListServices(c.Query("project_id"), limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to retrieve services",
@@ -56,7 +62,11 @@ func (h *ServiceHandler) CreateService(c *gin.Context) {
 	}
 
 	if err := h.serviceService.CreateService(&service); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidService) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
 			"error": "Failed to create service",
 			"details": err.Error(),
 		})
@@ -69,6 +79,7 @@ func (h *ServiceHandler) CreateService(c *gin.Context) {
 }
 
 // GetService handles GET /api/v1/services/:id
+// The response includes the latest TLS audit of https services.
 func (h *ServiceHandler) GetService(c *gin.Context) {
 	id := c.Param("id")
 
@@ -101,7 +112,11 @@ func (h *ServiceHandler) UpdateService(c *gin.Context) {
 
 	updatedService, err := h.serviceService.UpdateService(id, &service)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidService) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
 			"error": "Failed to update service",
 			"details": err.Error(),
 		})
@@ -114,11 +129,23 @@ func (h *ServiceHandler) UpdateService(c *gin.Context) {
 }
 
 // DeleteService handles DELETE /api/v1/services/:id
+// The cascade query parameter decides what happens to its test cases: block
+// (the default) answers 409 while any is active, archive archives them all.
 func (h *ServiceHandler) DeleteService(c *gin.Context) {
 	id := c.Param("id")
 
-	if err := h.serviceService.DeleteService(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	deletion, err := h.serviceService.DeleteService(id, c.Query("cascade"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrInvalidCascade):
+			status = http.StatusBadRequest
+		case errors.Is(err, services.ErrServiceInUse):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
 			"error": "Failed to delete service",
 			"details": err.Error(),
 		})
@@ -127,5 +154,95 @@ func (h *ServiceHandler) DeleteService(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Service deleted successfully",
+		"data": deletion,
+	})
+}
+
+// AuditTLS handles POST /api/v1/services/:id/tls-audit
+// It audits the TLS setup of the service now instead of waiting for the
+// periodic audit.
+func (h *ServiceHandler) AuditTLS(c *gin.Context) {
+	audit, err := h.tlsAuditService.AuditService(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, testrunner.ErrNoTLS):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to audit TLS",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": audit,
+	})
+}
+
+// GetImpact handles GET /api/v1/services/:id/impact
+// It lists the services consuming the service, directly or through others,
+// and the suites covering them.
+func (h *ServiceHandler) GetImpact(c *gin.Context) {
+	impact, err := h.serviceService.GetImpact(c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to compute impact",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": impact,
+	})
+}
+
+// RunImpact handles POST /api/v1/services/:id/impact-run
+// It starts a run of every suite covering a consumer of the service. The
+// body optionally selects an environment, variables and API versions, as
+// for running a suite; suites whose run could not start are listed as failed.
+func (h *ServiceHandler) RunImpact(c *gin.Context) {
+	var request services.StartTestRunOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	impactRun, err := h.suiteService.RunImpact(requestContext(c), c.Param("id"), request)
+	if quotaExceeded(c, err, "Failed to start impact run") {
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrNoImpactedSuites):
+			status = http.StatusConflict
+		case errors.Is(err, services.ErrInvalidTimeout), errors.Is(err, services.ErrProjectMismatch):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to start impact run",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": impactRun,
 	})
 }
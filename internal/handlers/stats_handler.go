@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/services"
+	"api-test-framework/internal/testrunner"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxStatsWindow bounds the window of the stats API
+const maxStatsWindow = 90 * 24 * time.Hour
+
+// StatsHandler exposes the reliability of services derived from test results,
+// as JSON and in the Prometheus text format
+type StatsHandler struct {
+	testRunService *services.TestRunService
+	metricsWindow  time.Duration
+}
+
+// NewStatsHandler creates a new stats handler. metricsWindow is the window of
+// results the Prometheus metrics are computed over.
+func NewStatsHandler(testRunService *services.TestRunService, metricsWindow time.Duration) *StatsHandler {
+	if metricsWindow <= 0 {
+		metricsWindow = time.Hour
+	}
+	return &StatsHandler{testRunService: testRunService, metricsWindow: metricsWindow}
+}
+
+// GetServiceStats handles GET /api/v1/stats/services
+// The window query parameter is a duration such as 24h (the default) or 30m;
+// service_id restricts the stats to one service.
+func (h *StatsHandler) GetServiceStats(c *gin.Context) {
+	window := 24 * time.Hour
+	if raw := c.Query("window"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 || parsed > maxStatsWindow {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid window",
+				"details": fmt.Sprintf("window must be a positive duration of at most %s, e.g. 24h", maxStatsWindow),
+			})
+			return
+		}
+		window = parsed
+	}
+
+	stats, err := h.testRunService.GetServiceStats(c.Request.Context(), time.Now().Add(-window), c.Query("service_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute service stats",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": stats,
+		"meta": gin.H{
+			"total":  len(stats),
+			"window": window.String(),
+		},
+	})
+}
+
+// Metrics handles GET /metrics
+// It exposes the service stats of the metrics window to Prometheus.
+func (h *StatsHandler) Metrics(c *gin.Context) {
+	stats, err := h.testRunService.GetServiceStats(c.Request.Context(), time.Now().Add(-h.metricsWindow), "")
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to compute service stats: %v\n", err)
+		return
+	}
+
+	var b strings.Builder
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	metric("api_test_metrics_window_seconds", "gauge", "Window of test results the service metrics are computed over.")
+	fmt.Fprintf(&b, "api_test_metrics_window_seconds %g\n", h.metricsWindow.Seconds())
+
+	metric("api_test_service_availability_ratio", "gauge", "Share of executed tests for which the service was reachable and answered without a 5xx.")
+	for _, s := range stats {
+		if s.Availability != nil {
+			fmt.Fprintf(&b, "api_test_service_availability_ratio{%s} %g\n", serviceLabels(s), *s.Availability)
+		}
+	}
+
+	metric("api_test_service_error_ratio", "gauge", "Share of executed tests that failed or timed out.")
+	for _, s := range stats {
+		if s.ErrorRate != nil {
+			fmt.Fprintf(&b, "api_test_service_error_ratio{%s} %g\n", serviceLabels(s), *s.ErrorRate)
+		}
+	}
+
+	metric("api_test_service_results", "gauge", "Test results of the service by status.")
+	for _, s := range stats {
+		for _, status := range []struct {
+			name  string
+			count int64
+		}{{"passed", s.Passed}, {"failed", s.Failed}, {"skipped", s.Skipped}, {"timed_out", s.TimedOut}} {
+			fmt.Fprintf(&b, "api_test_service_results{%s,status=\"%s\"} %d\n", serviceLabels(s), status.name, status.count)
+		}
+	}
+
+	metric("api_test_service_failures", "gauge", "Failed and timed out tests of the service by failure type.")
+	for _, s := range stats {
+		failureTypes := make([]string, 0, len(s.Failures))
+		for failureType := range s.Failures {
+			failureTypes = append(failureTypes, failureType)
+		}
+		sort.Strings(failureTypes)
+		for _, failureType := range failureTypes {
+			fmt.Fprintf(&b, "api_test_service_failures{%s,failure_type=\"%s\"} %d\n", serviceLabels(s), escapeLabel(failureType), s.Failures[failureType])
+		}
+	}
+
+	metric("api_test_service_response_time_seconds", "gauge", "Response time quantiles of the executed tests of the service.")
+	for _, s := range stats {
+		if s.Passed+s.Failed+s.TimedOut == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "api_test_service_response_time_seconds{%s,quantile=\"0.5\"} %g\n", serviceLabels(s), s.P50Ms/1000)
+		fmt.Fprintf(&b, "api_test_service_response_time_seconds{%s,quantile=\"0.95\"} %g\n", serviceLabels(s), s.P95Ms/1000)
+	}
+
+	metric("api_test_service_last_result_timestamp_seconds", "gauge", "Unix time of the latest test result of the service.")
+	for _, s := range stats {
+		if s.LastResultAt != nil {
+			fmt.Fprintf(&b, "api_test_service_last_result_timestamp_seconds{%s} %d\n", serviceLabels(s), s.LastResultAt.Unix())
+		}
+	}
+
+	// Client pool usage is local to this instance
+	clients := testrunner.DefaultClientPool.Stats()
+	metric("api_test_http_clients", "gauge", "HTTP clients in the client pool of this instance.")
+	fmt.Fprintf(&b, "api_test_http_clients %d\n", len(clients))
+
+	metric("api_test_http_client_requests_total", "counter", "Requests sent by the pooled HTTP clients of this instance.")
+	for _, client := range clients {
+		fmt.Fprintf(&b, "api_test_http_client_requests_total{origin=\"%s\"} %d\n", escapeLabel(client.Origin), client.Requests)
+	}
+
+	metric("api_test_http_client_connections_total", "counter", "Requests of the pooled HTTP clients by whether they opened a connection or reused a kept-alive one.")
+	for _, client := range clients {
+		fmt.Fprintf(&b, "api_test_http_client_connections_total{origin=\"%s\",state=\"opened\"} %d\n", escapeLabel(client.Origin), client.ConnectionsOpened)
+		fmt.Fprintf(&b, "api_test_http_client_connections_total{origin=\"%s\",state=\"reused\"} %d\n", escapeLabel(client.Origin), client.ConnectionsReused)
+	}
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
+
+// GetClientPoolStats handles GET /api/v1/stats/http-clients
+// It reports the pooled HTTP clients of the instance serving the request.
+func (h *StatsHandler) GetClientPoolStats(c *gin.Context) {
+	clients := testrunner.DefaultClientPool.Stats()
+	c.JSON(http.StatusOK, gin.H{
+		"data": clients,
+		"meta": gin.H{"total": len(clients)},
+	})
+}
+
+// serviceLabels returns the labels identifying a service in metrics
+func serviceLabels(s services.ServiceStats) string {
+	return fmt.Sprintf("service=\"%s\",service_id=\"%s\"", escapeLabel(s.ServiceName), escapeLabel(s.ServiceID))
+}
+
+// escapeLabel escapes a Prometheus label value
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SuiteHandler handles suite-related HTTP requests
+type SuiteHandler struct {
+	suiteService *services.SuiteService
+}
+
+// NewSuiteHandler creates a new suite handler
+func NewSuiteHandler(suiteService *services.SuiteService) *SuiteHandler {
+	return &SuiteHandler{suiteService: suiteService}
+}
+
+// ListSuites handles GET /api/v1/suites
+// project_id restricts the list to a project.
+func (h *SuiteHandler) ListSuites(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	suites, total, err := h.suiteService.ListSuites(c.Query("project_id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve suites",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": suites,
+		"meta": gin.H{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// CreateSuite handles POST /api/v1/suites
+func (h *SuiteHandler) CreateSuite(c *gin.Context) {
+	var suite models.TestSuite
+	if err := c.ShouldBindJSON(&suite); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.suiteService.CreateSuite(&suite); err != nil {
+		c.JSON(suiteErrorStatus(err), gin.H{
+			"error":   "Failed to create suite",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": suite,
+	})
+}
+
+// GetSuite handles GET /api/v1/suites/:id
+func (h *SuiteHandler) GetSuite(c *gin.Context) {
+	id := c.Param("id")
+
+	suite, err := h.suiteService.GetSuite(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Suite not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": suite,
+	})
+}
+
+// UpdateSuite handles PUT /api/v1/suites/:id
+// Fields missing from the request keep their current values.
+func (h *SuiteHandler) UpdateSuite(c *gin.Context) {
+	id := c.Param("id")
+
+	suite, err := h.suiteService.GetSuite(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Suite not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := c.ShouldBindJSON(suite); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	suite.ID = id
+
+	if err := h.suiteService.UpdateSuite(suite); err != nil {
+		c.JSON(suiteErrorStatus(err), gin.H{
+			"error":   "Failed to update suite",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": suite,
+	})
+}
+
+// DeleteSuite handles DELETE /api/v1/suites/:id
+func (h *SuiteHandler) DeleteSuite(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.suiteService.DeleteSuite(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete suite",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Suite deleted successfully",
+	})
+}
+
+// RunSuite handles POST /api/v1/suites/:id/run
+// The body optionally selects an environment, variables and API versions.
+func (h *SuiteHandler) RunSuite(c *gin.Context) {
+	id := c.Param("id")
+
+	var request services.StartTestRunOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	testRun, err := h.suiteService.RunSuite(requestContext(c), id, request)
+	if quotaExceeded(c, err, "Failed to run suite") {
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, services.ErrInvalidTimeout) || errors.Is(err, services.ErrProjectMismatch) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to run suite",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": testRun,
+	})
+}
+
+// suiteErrorStatus maps validation errors to 400 and anything else to 500
+func suiteErrorStatus(err error) int {
+	if errors.Is(err, services.ErrInvalidSuite) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -2,14 +2,21 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"unicode"
 
 	"api-test-framework/internal/models"
 	"api-test-framework/internal/services"
+	"api-test-framework/internal/testrunner"
 	"api-test-framework/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // TestHandler handles test-related HTTP requests
@@ -22,13 +29,17 @@ func NewTestHandler(testService *services.TestService) *TestHandler {
 	return &TestHandler{testService: testService}
 }
 
-// ListTests handles GET /api/v1/tests
+// ListTests handles GET /api/v1/tests. The comma-separated tags and
+// exclude_tags select the tests carrying any of tags and none of exclude_tags;
+// project_id selects the tests of the services of a project.
 func (h *TestHandler) ListTests(c *gin.Context) {
 	serviceID := c.Query("service_id")
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	tags := strings.Split(c.Query("tags"), ",")
+	excludeTags := strings.Split(c.Query("exclude_tags"), ",")
 
-	tests, total, err := h.testService.ListTests(serviceID, limit, offset)
+	tests, total, err := h.testService.ListTests(c.Query("project_id"), serviceID, tags, excludeTags, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to retrieve tests",
@@ -47,6 +58,32 @@ func (h *TestHandler) ListTests(c *gin.Context) {
 	})
 }
 
+// ListArchivedTests handles GET /api/v1/tests/archived
+// It lists the test cases archived when their services were deleted;
+// service_id selects those of one deleted service.
+func (h *TestHandler) ListArchivedTests(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	tests, total, err := h.testService.ListArchivedTests(c.Query("service_id"), limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve archived tests",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": tests,
+		"meta": gin.H{
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
 // CreateTest handles POST /api/v1/tests
 func (h *TestHandler) CreateTest(c *gin.Context) {
 	var testCase models.TestCase
@@ -59,6 +96,9 @@ func (h *TestHandler) CreateTest(c *gin.Context) {
 	}
 
 	if err := h.testService.CreateTest(&testCase); err != nil {
+		if invalidTestSpec(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create test",
 			"details": err.Error(),
@@ -81,14 +121,19 @@ func (h *TestHandler) CreateTest(c *gin.Context) {
 	})
 }
 
-// CreateTestFromCurl handles POST /api/v1/tests/from-curl
+// CreateTestFromCurl handles POST /api/v1/tests/from-curl. Besides curl_command,
+// it takes a snippet in another format: an HTTPie command or a fetch() call
+// copied from browser devtools, detected unless format is given.
 func (h *TestHandler) CreateTestFromCurl(c *gin.Context) {
 	var request struct {
 		ServiceID   string `json:"service_id" binding:"required"`
 		Name        string `json:"name" binding:"required"`
 		Description string `json:"description"`
-		CurlCommand string `json:"curl_command" binding:"required"`
+		CurlCommand string `json:"curl_command"`
+		Snippet     string `json:"snippet"`
+		Format      string `json:"format"` // curl, httpie, fetch or auto (default)
 		Assertions  []map[string]interface{} `json:"assertions"`
+		Fixtures    map[string]string        `json:"fixtures"` // fixture IDs of the files of -F fields, by path
 	}
 
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -99,17 +144,33 @@ func (h *TestHandler) CreateTestFromCurl(c *gin.Context) {
 		return
 	}
 
-	// Parse the curl command
-	curlRequest, err := utils.ParseCurlCommand(request.CurlCommand)
+	snippet, format := request.Snippet, request.Format
+	if snippet == "" {
+		snippet = request.CurlCommand
+		if format == "" {
+			format = utils.SnippetFormatCurl
+		}
+	}
+	if snippet == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": "curl_command or snippet is required",
+		})
+		return
+	}
+
+	// Parse the curl command, HTTPie command or fetch() call
+	curlRequest, err := utils.ParseRequestSnippet(snippet, format)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid curl command",
+			"error": "Invalid request snippet",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	// Convert to test spec
+	// Convert to test spec, with the files of form fields sent from fixtures
+	warnings := curlRequest.AttachFixtures(request.Fixtures)
 	testSpec := curlRequest.ToTestSpec(request.Name, request.Description)
 	
 	// Override service_name with the actual service
@@ -139,6 +200,9 @@ func (h *TestHandler) CreateTestFromCurl(c *gin.Context) {
 	}
 
 	if err := h.testService.CreateTest(testCase); err != nil {
+		if invalidTestSpec(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to create test",
 			"details": err.Error(),
@@ -159,9 +223,366 @@ func (h *TestHandler) CreateTestFromCurl(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{
 		"data": createdTestCase,
 		"parsed_curl": curlRequest,
+		"warnings": warnings,
+	})
+}
+
+// ImportPostmanCollection handles POST /api/v1/tests/import/postman
+func (h *TestHandler) ImportPostmanCollection(c *gin.Context) {
+	var request struct {
+		ServiceID  string          `json:"service_id" binding:"required"`
+		Collection json.RawMessage `json:"collection" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	imported, err := utils.ParsePostmanCollection(request.Collection)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid Postman collection",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.createImportedTests(c, request.ServiceID, imported, false)
+}
+
+// ImportHAR handles POST /api/v1/tests/import/har. The HAR file is uploaded as
+// the "file" form field or sent inline as "har"; hosts restricts the imported
+// entries and static assets are skipped unless include_static is set.
+func (h *TestHandler) ImportHAR(c *gin.Context) {
+	var request struct {
+		ServiceID     string          `json:"service_id" form:"service_id" binding:"required"`
+		HAR           json.RawMessage `json:"har" form:"-"`
+		Hosts         []string        `json:"hosts" form:"hosts"`
+		IncludeStatic bool            `json:"include_static" form:"include_static"`
+	}
+
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	document := []byte(request.HAR)
+	if fileHeader, fileErr := c.FormFile("file"); fileErr == nil {
+		var err error
+		if document, err = readFormFile(fileHeader); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Failed to read HAR file",
+				"details": err.Error(),
+			})
+			return
+		}
+	} else if len(document) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": "either a file upload or har is required",
+		})
+		return
+	}
+
+	baseURL, err := h.testService.GetServiceBaseURL(request.ServiceID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Service not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	imported, err := utils.ParseHAR(document, utils.HAROptions{
+		AllowedHosts:  request.Hosts,
+		IncludeStatic: request.IncludeStatic,
+		BaseURL:       baseURL,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid HAR file",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.createImportedTests(c, request.ServiceID, imported, false)
+}
+
+// ImportOpenAPI handles POST /api/v1/tests/import/openapi. The document is
+// uploaded as the "file" form field or fetched from "url"; tests are added to
+// service_id, or to a new service created from the document. The smoke suite
+// of the service is regenerated with one test per operation.
+func (h *TestHandler) ImportOpenAPI(c *gin.Context) {
+	var request struct {
+		URL         string `json:"url" form:"url"`
+		ServiceID   string `json:"service_id" form:"service_id"`
+		ServiceName string `json:"service_name" form:"service_name"`
+		BaseURL     string `json:"base_url" form:"base_url"`
+	}
+
+	if err := c.ShouldBind(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var document []byte
+	var err error
+	if fileHeader, fileErr := c.FormFile("file"); fileErr == nil {
+		document, err = readFormFile(fileHeader)
+	} else if request.URL != "" {
+		document, err = fetchDocument(c.Request.Context(), request.URL)
+	} else {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": "either a file upload or url is required",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read OpenAPI document",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	imported, err := utils.ParseOpenAPI(document)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid OpenAPI document",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if request.ServiceID != "" {
+		h.createImportedTests(c, request.ServiceID, &imported.ImportResult, true)
+		return
+	}
+
+	service := &models.Service{
+		Name:        request.ServiceName,
+		Description: imported.Description,
+		BaseURL:     request.BaseURL,
+	}
+	if service.Name == "" {
+		service.Name = imported.ServiceName
+	}
+	if service.BaseURL == "" {
+		service.BaseURL = imported.BaseURL
+	}
+	if service.Name == "" || service.BaseURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": "service_name and base_url are required when the document has no title or servers",
+		})
+		return
+	}
+
+	testCases := importedTestCases(&imported.ImportResult)
+	smokeSuite, err := h.testService.ImportService(service, testCases, imported.Variables, true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to import tests",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"service":     service,
+		"data":        testCases,
+		"smoke_suite": smokeSuite,
+		"warnings":    imported.Warnings,
+		"meta": gin.H{
+			"imported": len(testCases),
+			"warnings": len(imported.Warnings),
+		},
 	})
 }
 
+// createImportedTests stores the converted tests of an import and reports
+// what was created and what could not be converted. Imports of API specs
+// regenerate the smoke suite of the service with smoke.
+func (h *TestHandler) createImportedTests(c *gin.Context, serviceID string, imported *utils.ImportResult, smoke bool) {
+	testCases := importedTestCases(imported)
+	smokeSuite, err := h.testService.ImportTests(serviceID, testCases, imported.Variables, smoke)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to import tests",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response := gin.H{
+		"data":      testCases,
+		"warnings":  imported.Warnings,
+		"variables": imported.Variables,
+		"meta": gin.H{
+			"imported": len(testCases),
+			"warnings": len(imported.Warnings),
+		},
+	}
+	if smoke {
+		response["smoke_suite"] = smokeSuite
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// importedTestCases builds test case records from converted tests; tests whose
+// spec cannot be encoded are reported as warnings
+func importedTestCases(imported *utils.ImportResult) []models.TestCase {
+	testCases := make([]models.TestCase, 0, len(imported.Tests))
+	for _, test := range imported.Tests {
+		testSpecJSON, err := json.Marshal(test.TestSpec)
+		if err != nil {
+			imported.Warnings = append(imported.Warnings, utils.ImportWarning{Item: test.Name, Message: err.Error()})
+			continue
+		}
+		testCases = append(testCases, models.TestCase{
+			Name:        test.Name,
+			Description: test.Description,
+			TestSpec:    string(testSpecJSON),
+		})
+	}
+	return testCases
+}
+
+// RegenerateSmokeSuite handles POST /api/v1/services/:id/smoke-suite
+// It regenerates the smoke suite of the service from the endpoints its tests
+// call, e.g. of services without an OpenAPI document.
+func (h *TestHandler) RegenerateSmokeSuite(c *gin.Context) {
+	suite, err := h.testService.RegenerateSmokeSuite(c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrNoSmokeEndpoints):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to regenerate smoke suite",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": suite,
+	})
+}
+
+// ImportTestBundle handles POST /api/v1/tests/bulk. The bundle, in JSON or
+// YAML, is the request body or uploaded as the "file" form field; service_id
+// imports its tests into an existing service instead of the one it names.
+func (h *TestHandler) ImportTestBundle(c *gin.Context) {
+	var document []byte
+	var err error
+	if fileHeader, fileErr := c.FormFile("file"); fileErr == nil {
+		document, err = readFormFile(fileHeader)
+	} else {
+		document, err = io.ReadAll(io.LimitReader(c.Request.Body, maxDocumentSize+1))
+		if err == nil && len(document) > maxDocumentSize {
+			err = fmt.Errorf("bundle exceeds the limit of %d bytes", maxDocumentSize)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to read bundle",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	bundle, err := utils.DecodeBundle(document)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid bundle",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	imported, err := h.testService.ImportBundle(bundle, c.Query("service_id"))
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to import bundle",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": imported,
+		"meta": gin.H{
+			"added":   len(imported.Added),
+			"updated": len(imported.Updated),
+		},
+	})
+}
+
+// ExportTests handles GET /api/v1/services/:id/tests/export. The bundle is
+// JSON unless format=yaml, and is sent as a file download.
+func (h *TestHandler) ExportTests(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", utils.BundleFormatJSON))
+	bundle, err := h.testService.ExportBundle(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Service not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	data, err := utils.EncodeBundle(bundle, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to export tests",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	contentType, extension := "application/json", "json"
+	if format != utils.BundleFormatJSON {
+		contentType, extension = "application/yaml", "yaml"
+	}
+	filename := fmt.Sprintf("%s.tests.%s", bundleFileName(bundle.Service.Name), extension)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// bundleFileName turns a service name into a file name
+func bundleFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '-'
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "service"
+	}
+	return name
+}
+
 // GetTest handles GET /api/v1/tests/:id
 func (h *TestHandler) GetTest(c *gin.Context) {
 	id := c.Param("id")
@@ -195,6 +616,9 @@ func (h *TestHandler) UpdateTest(c *gin.Context) {
 
 	updatedTestCase, err := h.testService.UpdateTest(id, &testCase)
 	if err != nil {
+		if invalidTestSpec(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to update test",
 			"details": err.Error(),
@@ -207,6 +631,46 @@ func (h *TestHandler) UpdateTest(c *gin.Context) {
 	})
 }
 
+// ValidateTest handles POST /api/v1/tests/validate. The body is a test spec,
+// checked strictly without saving it; the field errors of an invalid spec
+// are listed in the response.
+func (h *TestHandler) ValidateTest(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	fieldErrors := testrunner.ValidateSpec(body)
+	if fieldErrors == nil {
+		fieldErrors = []testrunner.FieldError{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"valid":  len(fieldErrors) == 0,
+			"errors": fieldErrors,
+		},
+	})
+}
+
+// invalidTestSpec responds with the field errors of a test spec that failed
+// validation, and reports whether err was such an error
+func invalidTestSpec(c *gin.Context, err error) bool {
+	var specErr *testrunner.SpecValidationError
+	if !errors.As(err, &specErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": "Invalid test spec",
+		"details": err.Error(),
+		"fields": specErr.Errors,
+	})
+	return true
+}
+
 // DeleteTest handles DELETE /api/v1/tests/:id
 func (h *TestHandler) DeleteTest(c *gin.Context) {
 	id := c.Param("id")
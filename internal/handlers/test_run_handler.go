@@ -1,12 +1,23 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"api-test-framework/internal/services"
+	"api-test-framework/internal/testrunner"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // TestRunHandler handles test run-related HTTP requests
@@ -19,14 +30,15 @@ func NewTestRunHandler(testRunService *services.TestRunService) *TestRunHandler
 	return &TestRunHandler{testRunService: testRunService}
 }
 
+// requestContext returns the context of an API request carrying its W3C
+// traceparent header, so executed requests join the caller's trace
+func requestContext(c *gin.Context) context.Context {
+	return testrunner.ContextWithTraceParent(c.Request.Context(), c.GetHeader("traceparent"))
+}
+
 // StartTestRun handles POST /api/v1/test-runs
 func (h *TestRunHandler) StartTestRun(c *gin.Context) {
-	var request struct {
-		ServiceID string   `json:"service_id"`
-		TestIDs   []string `json:"test_ids"`
-		Name      string   `json:"name"`
-	}
-
+	var request services.StartTestRunOptions
 	if err := c.ShouldBindJSON(&request); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid request body",
@@ -35,9 +47,16 @@ func (h *TestRunHandler) StartTestRun(c *gin.Context) {
 		return
 	}
 
-	testRun, err := h.testRunService.StartTestRun(request.ServiceID, request.TestIDs, request.Name)
+	testRun, err := h.testRunService.StartTestRun(requestContext(c), request)
+	if quotaExceeded(c, err, "Failed to start test run") {
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidTimeout) || errors.Is(err, services.ErrProjectMismatch) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
 			"error": "Failed to start test run",
 			"details": err.Error(),
 		})
@@ -49,11 +68,140 @@ func (h *TestRunHandler) StartTestRun(c *gin.Context) {
 	})
 }
 
+// GetQueueStatus handles GET /api/v1/queue
+// It reports why running runs have not progressed: their tests waiting for
+// workers with their position and estimated start, the job queues of the
+// regions and egress pools, and the schedules due within the hour.
+// project_id restricts the runs and schedules to a project.
+func (h *TestRunHandler) GetQueueStatus(c *gin.Context) {
+	status, err := h.testRunService.GetQueueStatus(c.Request.Context(), c.Query("project_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve queue status",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// The status is a snapshot, meant to be polled
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"data": status,
+	})
+}
+
+// RerunFailed handles POST /api/v1/test-runs/:id/rerun-failed
+// It starts a run of the test cases that failed in the run, linked to it
+// through rerun_of_id. The optional body overrides name, environment_id
+// and variables.
+func (h *TestRunHandler) RerunFailed(c *gin.Context) {
+	var request services.RerunOptions
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	testRun, err := h.testRunService.RerunFailed(requestContext(c), c.Param("id"), request)
+	if quotaExceeded(c, err, "Failed to re-run failed tests") {
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrRunNotFinished), errors.Is(err, services.ErrNoFailedTests):
+			status = http.StatusConflict
+		case errors.Is(err, services.ErrInvalidTimeout):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to re-run failed tests",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": testRun,
+	})
+}
+
+// CancelTestRun handles POST /api/v1/test-runs/:id/cancel
+func (h *TestRunHandler) CancelTestRun(c *gin.Context) {
+	id := c.Param("id")
+
+	testRun, err := h.testRunService.CancelTestRun(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, services.ErrRunNotRunning) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to cancel test run",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"data": testRun,
+	})
+}
+
+// StreamTestRun handles GET /api/v1/test-runs/:id/stream, streaming run
+// progress as server-sent events until the run completes or the client leaves
+func (h *TestRunHandler) StreamTestRun(c *gin.Context) {
+	id := c.Param("id")
+
+	events, err := h.testRunService.SubscribeRunEvents(c.Request.Context(), id)
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, services.ErrStreamingUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to stream test run",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return event.Type != services.RunEventRunCompleted
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", gin.H{"timestamp": time.Now()})
+			return true
+		}
+	})
+}
+
+// streamHeartbeatInterval keeps idle event streams open through proxies
+const streamHeartbeatInterval = 15 * time.Second
+
 // GetTestRun handles GET /api/v1/test-runs/:id
 func (h *TestRunHandler) GetTestRun(c *gin.Context) {
 	id := c.Param("id")
 
-	testRun, err := h.testRunService.GetTestRun(id)
+	testRun, err := h.testRunService.GetTestRun(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Test run not found",
@@ -67,11 +215,227 @@ func (h *TestRunHandler) GetTestRun(c *gin.Context) {
 	})
 }
 
+// Bounds of how long a summary request blocks
+const (
+	defaultSummaryWait = 10 * time.Minute
+	maxSummaryWait     = 30 * time.Minute
+)
+
+// GetRunSummary handles GET /api/v1/test-runs/:id/summary
+// wait=true blocks until the run finishes or timeout (a duration such as 600s
+// or a number of seconds, default 10 minutes) elapses. format=text returns
+// KEY=value lines a shell can source instead of JSON. Unlike the gate, the
+// status is 200 whatever the verdict; scripts exit with exit_code.
+func (h *TestRunHandler) GetRunSummary(c *gin.Context) {
+	var wait time.Duration
+	if c.Query("wait") == "true" {
+		var err error
+		if wait, err = summaryWait(c.Query("timeout")); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid timeout",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "text" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Unsupported summary format",
+			"details": "format must be json or text",
+		})
+		return
+	}
+
+	summary, err := h.testRunService.GetPipelineSummary(c.Request.Context(), c.Param("id"), wait)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to summarize test run",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(summaryText(summary)))
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// summaryWait returns the timeout of a waiting summary request
+func summaryWait(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return defaultSummaryWait, nil
+	}
+	wait, err := time.ParseDuration(timeout)
+	if err != nil {
+		seconds, atoiErr := strconv.Atoi(timeout)
+		if atoiErr != nil {
+			return 0, fmt.Errorf("timeout must be a duration such as 600s or a number of seconds")
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	if wait <= 0 || wait > maxSummaryWait {
+		return 0, fmt.Errorf("timeout must be positive and at most %s", maxSummaryWait)
+	}
+	return wait, nil
+}
+
+// summaryText renders a summary as KEY=value lines, failure categories as
+// FAILURES_<TYPE> in the order of their names
+func summaryText(summary *services.PipelineSummary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "RUN_ID=%s\n", summary.RunID)
+	fmt.Fprintf(&b, "STATUS=%s\n", summary.Status)
+	fmt.Fprintf(&b, "VERDICT=%s\n", summary.Verdict)
+	fmt.Fprintf(&b, "EXIT_CODE=%d\n", summary.ExitCode)
+	fmt.Fprintf(&b, "TOTAL=%d\n", summary.Total)
+	fmt.Fprintf(&b, "PASSED=%d\n", summary.Passed)
+	fmt.Fprintf(&b, "FAILED=%d\n", summary.Failed)
+	fmt.Fprintf(&b, "SKIPPED=%d\n", summary.Skipped)
+	fmt.Fprintf(&b, "TIMED_OUT=%d\n", summary.TimedOut)
+	fmt.Fprintf(&b, "DURATION_MS=%d\n", summary.DurationMs)
+
+	categories := make([]string, 0, len(summary.FailureCategories))
+	for category := range summary.FailureCategories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		fmt.Fprintf(&b, "FAILURES_%s=%d\n", strings.ToUpper(category), summary.FailureCategories[category])
+	}
+	return b.String()
+}
+
+// GetDebugLog handles GET /api/v1/test-runs/:id/debug-log
+// Only runs started with "debug": true capture a debug log.
+func (h *TestRunHandler) GetDebugLog(c *gin.Context) {
+	debugLog, err := h.testRunService.GetDebugLog(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, services.ErrNoDebugLog):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrRunNotFinished):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Debug log not available",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": debugLog.Entries,
+		"meta": gin.H{
+			"total":   len(debugLog.Entries),
+			"dropped": debugLog.Dropped,
+		},
+	})
+}
+
+// GetSecurityFindings handles GET /api/v1/test-runs/:id/security-findings
+// Only runs started with "security_scan": true scan their endpoints.
+func (h *TestRunHandler) GetSecurityFindings(c *gin.Context) {
+	findings, err := h.testRunService.GetSecurityFindings(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, services.ErrNoSecurityScan):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrRunNotFinished):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Security findings not available",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	bySeverity := map[string]int{}
+	for _, finding := range findings {
+		bySeverity[finding.Severity]++
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": findings,
+		"meta": gin.H{
+			"total":       len(findings),
+			"by_severity": bySeverity,
+		},
+	})
+}
+
+// GetRunConfig handles GET /api/v1/test-runs/:id/config
+// Runs started before snapshots were recorded have an empty config.
+func (h *TestRunHandler) GetRunConfig(c *gin.Context) {
+	config, err := h.testRunService.GetRunConfig(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Test run not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": config,
+	})
+}
+
+// GetTestRunReport handles GET /api/v1/test-runs/:id/report
+// format=html (the default) downloads a self-contained HTML report, format=json
+// returns the same report data.
+func (h *TestRunHandler) GetTestRunReport(c *gin.Context) {
+	id := c.Param("id")
+
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Unsupported report format",
+			"details": "format must be html or json",
+		})
+		return
+	}
+
+	report, err := h.testRunService.GetRunReport(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Test run not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, gin.H{"data": report})
+		return
+	}
+
+	var page bytes.Buffer
+	if err := services.RenderHTMLReport(&page, report); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to render report",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"test-run-%s.html\"", id))
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
 // GetTestResults handles GET /api/v1/test-runs/:id/results
 func (h *TestRunHandler) GetTestResults(c *gin.Context) {
 	id := c.Param("id")
 
-	testResults, err := h.testRunService.GetTestResults(id)
+	testResults, err := h.testRunService.GetTestResults(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to retrieve test results",
@@ -85,12 +449,148 @@ func (h *TestRunHandler) GetTestResults(c *gin.Context) {
 	})
 }
 
+// GetAssertionResults handles GET /api/v1/test-runs/:id/results/:resultId/assertions
+func (h *TestRunHandler) GetAssertionResults(c *gin.Context) {
+	id := c.Param("id")
+	resultID := c.Param("resultId")
+
+	assertionResults, err := h.testRunService.GetAssertionResults(c.Request.Context(), id, resultID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Test result not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	failed := 0
+	for _, assertionResult := range assertionResults {
+		if !assertionResult.Passed {
+			failed++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": assertionResults,
+		"meta": gin.H{
+			"total":  len(assertionResults),
+			"passed": len(assertionResults) - failed,
+			"failed": failed,
+		},
+	})
+}
+
+// GetDeprecationReport handles GET /api/v1/test-runs/:id/deprecations
+func (h *TestRunHandler) GetDeprecationReport(c *gin.Context) {
+	id := c.Param("id")
+
+	report, err := h.testRunService.GetDeprecationReport(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to build deprecation report",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": report,
+		"meta": gin.H{
+			"total": len(report),
+		},
+	})
+}
+
+// CompareRegions handles GET /api/v1/test-runs/:id/regions
+// It compares the latency of every test case across the regions it ran from.
+func (h *TestRunHandler) CompareRegions(c *gin.Context) {
+	id := c.Param("id")
+
+	comparisons, err := h.testRunService.CompareRegions(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compare regions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": comparisons,
+		"meta": gin.H{
+			"total": len(comparisons),
+		},
+	})
+}
+
+// CompareRuns handles GET /api/v1/test-runs/compare?base=:id&head=:id
+// Optional query parameters: threshold_percent, threshold_ms and ignore_paths,
+// a comma-separated list of body paths left out of response comparisons.
+func (h *TestRunHandler) CompareRuns(c *gin.Context) {
+	baseID, headID := c.Query("base"), c.Query("head")
+	if baseID == "" || headID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid comparison",
+			"details": "base and head run IDs are required",
+		})
+		return
+	}
+
+	opts := services.RunComparisonOptions{}
+	opts.ThresholdPercent, _ = strconv.ParseFloat(c.Query("threshold_percent"), 64)
+	opts.ThresholdMs, _ = strconv.Atoi(c.Query("threshold_ms"))
+	for _, path := range strings.Split(c.Query("ignore_paths"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			opts.IgnorePaths = append(opts.IgnorePaths, path)
+		}
+	}
+
+	comparison, err := h.testRunService.CompareRuns(c.Request.Context(), baseID, headID, opts)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrRunNotFinished):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to compare test runs",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": comparison})
+}
+
+// ListRegions handles GET /api/v1/regions
+// It lists the regions with a live worker.
+func (h *TestRunHandler) ListRegions(c *gin.Context) {
+	regions, err := h.testRunService.LiveRegions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list regions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": regions,
+		"meta": gin.H{
+			"total": len(regions),
+		},
+	})
+}
+
 // ListTestRuns handles GET /api/v1/test-runs
+// project_id restricts the list to a project.
 func (h *TestRunHandler) ListTestRuns(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	testRuns, total, err := h.testRunService.ListTestRuns(limit, offset)
+	testRuns, total, err := h.testRunService.ListTestRuns(c.Request.Context(), c.Query("project_id"), limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to retrieve test runs",
@@ -108,3 +608,386 @@ func (h *TestRunHandler) ListTestRuns(c *gin.Context) {
 		},
 	})
 }
+
+// GetResultResponse handles GET /api/v1/results/:id/response
+// It returns the captured response body with its original Content-Type,
+// as an attachment unless it is JSON.
+func (h *TestRunHandler) GetResultResponse(c *gin.Context) {
+	id := c.Param("id")
+
+	captured, err := h.testRunService.GetResultResponse(c.Request.Context(), id, c.Query("variant"))
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, services.ErrInvalidVariant) {
+			status = http.StatusBadRequest
+		} else if errors.Is(err, services.ErrResponseCompacted) {
+			status = http.StatusGone
+		} else if errors.Is(err, services.ErrResponseUnavailable) {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to retrieve captured response",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(captured.ContentType)
+	if err != nil {
+		mediaType = captured.ContentType
+	}
+
+	// The payload is served as captured, so only its original type can be negotiated
+	if !acceptsMediaType(c.GetHeader("Accept"), mediaType) {
+		c.JSON(http.StatusNotAcceptable, gin.H{
+			"error":   "Captured response is not available in the requested representation",
+			"details": "captured Content-Type is " + captured.ContentType,
+		})
+		return
+	}
+
+	filename := "response-" + id
+	if extensions, _ := mime.ExtensionsByType(mediaType); len(extensions) > 0 {
+		filename += extensions[0]
+	}
+
+	// Captured bodies come from the services under test, so browsers must
+	// neither sniff nor run them; only JSON is shown inline
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	if c.Query("download") == "true" || !isJSON {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("X-Captured-Status", strconv.Itoa(captured.StatusCode))
+	c.Data(http.StatusOK, captured.ContentType, captured.Body)
+}
+
+// ExecuteRequest handles POST /api/v1/execute
+// It runs a one-off request and its assertions without storing a test case
+// and returns the full execution result.
+func (h *TestRunHandler) ExecuteRequest(c *gin.Context) {
+	var request services.AdHocRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.testRunService.ExecuteAdHoc(requestContext(c), request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to execute request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// Diagnose handles POST /api/v1/tools/diagnose
+// It checks DNS resolution, TCP connection, TLS handshake and a HEAD request
+// against a service from the region its tests execute from, or from an
+// egress pool. Failed steps are part of the diagnosis, not errors.
+func (h *TestRunHandler) Diagnose(c *gin.Context) {
+	var request services.DiagnoseRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	diagnosis, err := h.testRunService.Diagnose(c.Request.Context(), request)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrMissingBaseURL):
+			status = http.StatusBadRequest
+		case errors.Is(err, services.ErrNoRegionWorker), errors.Is(err, services.ErrNoPoolWorker):
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to diagnose",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": diagnosis})
+}
+
+// ExecuteTestSpec handles POST /api/v1/tests/execute
+// It runs a complete test spec without saving it and returns the detailed
+// result, with its assertion counts in meta.
+func (h *TestRunHandler) ExecuteTestSpec(c *gin.Context) {
+	var request services.TestSpecRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.testRunService.ExecuteTestSpec(requestContext(c), request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to execute test spec",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": result,
+		"meta": gin.H{"assertions": services.CountAssertions(result)},
+	})
+}
+
+// GetScenarioGraph handles GET /api/v1/test-runs/:id/scenario
+// It returns the suite steps and test results of a run in the order they
+// started, linked by the variables they passed on, for sequence diagrams.
+func (h *TestRunHandler) GetScenarioGraph(c *gin.Context) {
+	graph, err := h.testRunService.GetScenarioGraph(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to retrieve scenario",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": graph})
+}
+
+// PreviewRequest handles POST /api/v1/tests/preview
+// It returns the fully resolved request of a test case or raw test spec
+// without sending it, to debug variable substitution and authentication.
+func (h *TestRunHandler) PreviewRequest(c *gin.Context) {
+	var opts services.PreviewRequestOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	preview, err := h.testRunService.PreviewRequest(requestContext(c), opts)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to preview request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": preview})
+}
+
+// PromoteResponse handles POST /api/v1/tests/:id/promote
+// It executes the test case's request once and rewrites its status and body
+// assertions from the live response. {"mode": "structure"} (the default)
+// asserts the fields exist, {"mode": "values"} asserts their values, and
+// {"dry_run": true} returns the assertions without saving them.
+func (h *TestRunHandler) PromoteResponse(c *gin.Context) {
+	var opts services.PromoteOptions
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	result, err := h.testRunService.PromoteResponse(requestContext(c), c.Param("id"), opts)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrPromoteUnsupported):
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to promote response",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// ExportCurl handles GET /api/v1/tests/:id/export/curl
+// It renders the resolved request of a test case as a curl command, for
+// ?service_id, ?environment_id, ?base_url, ?api_version and ?data_row like a
+// preview. ?format=text returns the command alone.
+func (h *TestRunHandler) ExportCurl(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "text" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Unsupported export format",
+			"details": "format must be json or text",
+		})
+		return
+	}
+	opts := services.PreviewRequestOptions{
+		AdHocTarget: services.AdHocTarget{
+			ServiceID:     c.Query("service_id"),
+			EnvironmentID: c.Query("environment_id"),
+			BaseURL:       c.Query("base_url"),
+			APIVersion:    c.Query("api_version"),
+		},
+	}
+	if dataRow := c.Query("data_row"); dataRow != "" {
+		row, err := strconv.Atoi(dataRow)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid data_row",
+				"details": err.Error(),
+			})
+			return
+		}
+		opts.DataRow = row
+	}
+
+	export, err := h.testRunService.ExportCurl(requestContext(c), c.Param("id"), opts)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrPreviewUnsupported):
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to export test as curl",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if format == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(export.Command+"\n"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": export})
+}
+
+// ReplayResult handles POST /api/v1/results/:id/replay
+// It re-sends the captured request, optionally against {"environment_id"},
+// and compares the fresh outcome with the stored result.
+func (h *TestRunHandler) ReplayResult(c *gin.Context) {
+	var request struct {
+		EnvironmentID string `json:"environment_id"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	comparison, err := h.testRunService.ReplayResult(requestContext(c), c.Param("id"), request.EnvironmentID)
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, services.ErrReplayUnavailable) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to replay test result",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": comparison})
+}
+
+// acceptsMediaType reports whether an Accept header allows the given media type
+func acceptsMediaType(accept, mediaType string) bool {
+	if accept == "" {
+		return true
+	}
+	mainType := strings.SplitN(mediaType, "/", 2)[0]
+	for _, part := range strings.Split(accept, ",") {
+		accepted, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if accepted == "*/*" || accepted == mediaType || accepted == mainType+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchResults handles GET /api/v1/results/search
+// Query parameters: run_id or from/to (RFC 3339), path and/or value, text, limit.
+func (h *TestRunHandler) SearchResults(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	query := services.ResultSearchQuery{
+		TestRunID: c.Query("run_id"),
+		Path:      c.Query("path"),
+		Value:     c.Query("value"),
+		Text:      c.Query("text"),
+		Limit:     limit,
+	}
+
+	for param, target := range map[string]**time.Time{"from": &query.From, "to": &query.To} {
+		if raw := c.Query(param); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid " + param + " parameter",
+					"details": err.Error(),
+				})
+				return
+			}
+			*target = &parsed
+		}
+	}
+
+	matches, err := h.testRunService.SearchResults(c.Request.Context(), query)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidSearch) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to search results",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": matches,
+		"meta": gin.H{
+			"total": len(matches),
+			"limit": query.Limit,
+		},
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"api-test-framework/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkerHandler handles worker registration and capacity HTTP requests
+type WorkerHandler struct {
+	workerService *services.WorkerService
+}
+
+// NewWorkerHandler creates a new worker handler
+func NewWorkerHandler(workerService *services.WorkerService) *WorkerHandler {
+	return &WorkerHandler{workerService: workerService}
+}
+
+// HeartbeatRequest represents the request body of a worker heartbeat
+type HeartbeatRequest struct {
+	ActiveJobs int `json:"active_jobs"`
+}
+
+// RegisterWorker handles POST /api/v1/workers
+func (h *WorkerHandler) RegisterWorker(c *gin.Context) {
+	var registration services.WorkerRegistration
+	if err := c.ShouldBindJSON(&registration); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	worker, err := h.workerService.Register(c.Request.Context(), registration)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to register worker",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": worker,
+	})
+}
+
+// Heartbeat handles POST /api/v1/workers/:id/heartbeat
+// Unknown workers get 404 and must register again.
+func (h *WorkerHandler) Heartbeat(c *gin.Context) {
+	var req HeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	worker, err := h.workerService.Heartbeat(c.Request.Context(), c.Param("id"), req.ActiveJobs)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrWorkerNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to record heartbeat",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": worker,
+	})
+}
+
+// DeregisterWorker handles DELETE /api/v1/workers/:id
+func (h *WorkerHandler) DeregisterWorker(c *gin.Context) {
+	if err := h.workerService.Deregister(c.Request.Context(), c.Param("id")); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrWorkerNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to deregister worker",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Worker deregistered successfully",
+	})
+}
+
+// ListWorkers handles GET /api/v1/workers
+// It lists the live workers, optionally of the region given as query parameter.
+func (h *WorkerHandler) ListWorkers(c *gin.Context) {
+	workers, err := h.workerService.ListWorkers(c.Request.Context(), c.Query("region"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list workers",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": workers,
+		"meta": gin.H{
+			"total": len(workers),
+		},
+	})
+}
+
+// GetCapacity handles GET /api/v1/workers/capacity
+// It reports the workers, capacity and queued tests of every live region.
+func (h *WorkerHandler) GetCapacity(c *gin.Context) {
+	capacities, err := h.workerService.Capacities(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to retrieve worker capacity",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": capacities,
+		"meta": gin.H{
+			"total": len(capacities),
+		},
+	})
+}
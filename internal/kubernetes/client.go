@@ -0,0 +1,195 @@
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// serviceAccountDir holds the credentials mounted into pods
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Client is a minimal Kubernetes API client covering the deployment scale
+// and Job resources used to scale workers
+type Client struct {
+	apiURL     string
+	token      string
+	namespace  string
+	httpClient *http.Client
+}
+
+// NewInClusterClient creates a client from the service account of the pod it
+// runs in. An empty namespace selects the namespace of the pod.
+func NewInClusterClient(namespace string) (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %v", err)
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("invalid cluster CA certificate")
+	}
+	if namespace == "" {
+		current, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pod namespace: %v", err)
+		}
+		namespace = strings.TrimSpace(string(current))
+	}
+
+	return &Client{
+		apiURL:    "https://" + net.JoinHostPort(host, port),
+		token:     strings.TrimSpace(string(token)),
+		namespace: namespace,
+		httpClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+// Namespace returns the namespace the client manages resources in
+func (c *Client) Namespace() string {
+	return c.namespace
+}
+
+// DeploymentReplicas returns the desired replicas of a deployment
+func (c *Client) DeploymentReplicas(ctx context.Context, name string) (int, error) {
+	var scale struct {
+		Spec struct {
+			Replicas int `json:"replicas"`
+		} `json:"spec"`
+	}
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/deployments/%s/scale", c.namespace, name)
+	if err := c.do(ctx, http.MethodGet, path, "", nil, &scale); err != nil {
+		return 0, err
+	}
+	return scale.Spec.Replicas, nil
+}
+
+// ScaleDeployment sets the desired replicas of a deployment
+func (c *Client) ScaleDeployment(ctx context.Context, name string, replicas int) error {
+	patch := map[string]interface{}{"spec": map[string]interface{}{"replicas": replicas}}
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/deployments/%s/scale", c.namespace, name)
+	return c.do(ctx, http.MethodPatch, path, "application/merge-patch+json", patch, nil)
+}
+
+// ActiveJobs counts the Jobs matching a label selector that have not finished
+func (c *Client) ActiveJobs(ctx context.Context, labelSelector string) (int, error) {
+	var list struct {
+		Items []struct {
+			Status struct {
+				// CompletionTime is only set for Jobs that completed successfully
+				CompletionTime *time.Time `json:"completionTime"`
+				Conditions     []struct {
+					Type   string `json:"type"`
+					Status string `json:"status"`
+				} `json:"conditions"`
+			} `json:"status"`
+		} `json:"items"`
+	}
+	path := fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs?labelSelector=%s", c.namespace, neturl.QueryEscape(labelSelector))
+	if err := c.do(ctx, http.MethodGet, path, "", nil, &list); err != nil {
+		return 0, err
+	}
+
+	active := 0
+	for _, job := range list.Items {
+		finished := job.Status.CompletionTime != nil
+		for _, condition := range job.Status.Conditions {
+			if (condition.Type == "Complete" || condition.Type == "Failed") && condition.Status == "True" {
+				finished = true
+			}
+		}
+		if !finished {
+			active++
+		}
+	}
+	return active, nil
+}
+
+// CreateJob creates a Job from a manifest
+func (c *Client) CreateJob(ctx context.Context, manifest map[string]interface{}) error {
+	path := fmt.Sprintf("/apis/batch/v1/namespaces/%s/jobs", c.namespace)
+	return c.do(ctx, http.MethodPost, path, "application/json", manifest, nil)
+}
+
+// do sends a request to the API server and decodes the response into out
+func (c *Client) do(ctx context.Context, method, path, contentType string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.apiURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("kubernetes request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &status) == nil && status.Message != "" {
+			return fmt.Errorf("kubernetes returned status %d: %s", resp.StatusCode, status.Message)
+		}
+		return fmt.Errorf("kubernetes returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("invalid kubernetes response: %v", err)
+		}
+	}
+	return nil
+}
+
+// LoadManifest reads a resource manifest from a YAML or JSON file
+func LoadManifest(path string) (map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	var manifest map[string]interface{}
+	if err := yaml.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("manifest %s is empty", path)
+	}
+	return manifest, nil
+}
@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// New creates a logger writing records of level and above to w. level is
+// debug, info, warn or error; format is json or text.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: use debug, info, warn or error", level)
+	}
+
+	options := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case "json", "":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q: use json or text", format)
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying logger
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx, nil if there is none
+func FromContext(ctx context.Context) *slog.Logger {
+	logger, _ := ctx.Value(contextKey{}).(*slog.Logger)
+	return logger
+}
+
+// Capture keeps the records logged through its handlers in memory, at debug
+// level whatever the level of the handler it wraps, up to a limit of entries
+type Capture struct {
+	mu      sync.Mutex
+	limit   int
+	entries []models.LogEntry
+	dropped int
+}
+
+// NewCapture creates a capture keeping up to limit entries
+func NewCapture(limit int) *Capture {
+	return &Capture{limit: limit}
+}
+
+// Handler returns a handler capturing records before passing those enabled
+// by next on to it
+func (c *Capture) Handler(next slog.Handler) slog.Handler {
+	return &captureHandler{capture: c, next: next}
+}
+
+// DebugLog returns the captured entries in the order they were logged
+func (c *Capture) DebugLog() models.DebugLog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return models.DebugLog{
+		Entries: append([]models.LogEntry(nil), c.entries...),
+		Dropped: c.dropped,
+	}
+}
+
+// add records an entry unless the limit was reached
+func (c *Capture) add(entry models.LogEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.limit {
+		c.dropped++
+		return
+	}
+	c.entries = append(c.entries, entry)
+}
+
+// captureHandler is the slog.Handler of a Capture
+type captureHandler struct {
+	capture *Capture
+	next    slog.Handler
+	attrs   []slog.Attr // attributes added with WithAttrs, keys already prefixed
+	group   string      // prefix of the keys of later attributes
+}
+
+// Enabled implements slog.Handler; every record is captured
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle implements slog.Handler
+func (h *captureHandler) Handle(ctx context.Context, record slog.Record) error {
+	entry := models.LogEntry{
+		Time:    record.Time,
+		Level:   record.Level.String(),
+		Message: record.Message,
+	}
+	if len(h.attrs) > 0 || record.NumAttrs() > 0 {
+		entry.Attrs = map[string]interface{}{}
+		for _, attr := range h.attrs {
+			addAttr(entry.Attrs, "", attr)
+		}
+		record.Attrs(func(attr slog.Attr) bool {
+			addAttr(entry.Attrs, h.group, attr)
+			return true
+		})
+	}
+	h.capture.add(entry)
+
+	if h.next.Enabled(ctx, record.Level) {
+		return h.next.Handle(ctx, record)
+	}
+	return nil
+}
+
+// WithAttrs implements slog.Handler
+func (h *captureHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	prefixed := append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		prefixed = append(prefixed, slog.Attr{Key: h.group + attr.Key, Value: attr.Value})
+	}
+	return &captureHandler{capture: h.capture, next: h.next.WithAttrs(attrs), attrs: prefixed, group: h.group}
+}
+
+// WithGroup implements slog.Handler
+func (h *captureHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &captureHandler{capture: h.capture, next: h.next.WithGroup(name), attrs: h.attrs, group: h.group + name + "."}
+}
+
+// addAttr adds an attribute to the attributes of an entry, flattening groups
+// into dotted keys and values into JSON-friendly ones
+func addAttr(attrs map[string]interface{}, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindGroup:
+		// Groups without a key are inlined
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix += attr.Key + "."
+		}
+		for _, member := range value.Group() {
+			addAttr(attrs, groupPrefix, member)
+		}
+	case slog.KindDuration:
+		attrs[prefix+attr.Key] = value.Duration().String()
+	case slog.KindTime:
+		attrs[prefix+attr.Key] = value.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			attrs[prefix+attr.Key] = err.Error()
+		} else {
+			attrs[prefix+attr.Key] = value.Any()
+		}
+	default:
+		attrs[prefix+attr.Key] = value.Any()
+	}
+}
@@ -2,9 +2,14 @@ package models
 
 import (
 	"database/sql/driver"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"reflect"
 	"time"
 
+	"api-test-framework/internal/secrets"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -23,90 +28,1356 @@ type AuthConfig struct {
 	Extra        map[string]string `json:"extra,omitempty"`
 }
 
-// Value implements driver.Valuer interface
+// MaskedSecret replaces the secrets of auth configs in API responses. Sent
+// back in an update, it keeps the stored secret.
+const MaskedSecret = "********"
+
+// SecretCipher encrypts the secrets of auth configs at rest
+type SecretCipher interface {
+	Encrypt(plaintext string) (string, error)
+	Decrypt(value string) (string, error)
+}
+
+// secretCipher encrypts the secrets of auth configs, nil stores them as plaintext
+var secretCipher SecretCipher
+
+// SetSecretCipher enables the encryption of auth config secrets at rest.
+// Secrets stored as plaintext before are still read, and get encrypted when
+// their service is saved again.
+func SetSecretCipher(cipher SecretCipher) {
+	secretCipher = cipher
+}
+
+// secretFields returns the fields of an auth config holding secrets
+func (a *AuthConfig) secretFields() []*string {
+	return []*string{&a.Token, &a.KeyValue, &a.Password, &a.ClientSecret}
+}
+
+// credentialFields returns the fields of an auth config that may reference
+// external secrets
+func (a *AuthConfig) credentialFields() []*string {
+	return []*string{&a.Token, &a.KeyValue, &a.Username, &a.Password, &a.ClientID, &a.ClientSecret}
+}
+
+// ResolveReferences returns the auth config with the secret references of
+// its credentials, e.g. "vault:kv/billing#token" or "env:BILLING_TOKEN",
+// replaced by their values
+func (a AuthConfig) ResolveReferences(resolve func(ref string) (string, error)) (AuthConfig, error) {
+	for _, field := range a.credentialFields() {
+		if !secrets.IsReference(*field) {
+			continue
+		}
+		value, err := resolve(*field)
+		if err != nil {
+			return a, err
+		}
+		*field = value
+	}
+	return a, nil
+}
+
+// Masked returns the auth config with its secrets replaced by MaskedSecret.
+// Secret references are not secrets and stay visible.
+func (a AuthConfig) Masked() AuthConfig {
+	for _, field := range a.secretFields() {
+		if *field != "" && !secrets.IsReference(*field) {
+			*field = MaskedSecret
+		}
+	}
+	return a
+}
+
+// Unmask returns the auth config with the secrets left masked restored from
+// the stored config, so clients can send back a config they retrieved
+func (a AuthConfig) Unmask(stored AuthConfig) AuthConfig {
+	storedFields := stored.secretFields()
+	for i, field := range a.secretFields() {
+		if *field == MaskedSecret {
+			*field = *storedFields[i]
+		}
+	}
+	return a
+}
+
+// Secrets returns the secret values of the auth config as they appear in
+// requests, including the encoded credentials of basic auth. References
+// must be resolved first.
+func (a AuthConfig) Secrets() []string {
+	var values []string
+	for _, field := range a.secretFields() {
+		if *field != "" && !secrets.IsReference(*field) {
+			values = append(values, *field)
+		}
+	}
+	if a.Username != "" && a.Password != "" && !secrets.IsReference(a.Username) && !secrets.IsReference(a.Password) {
+		values = append(values, base64.StdEncoding.EncodeToString([]byte(a.Username+":"+a.Password)))
+	}
+	return values
+}
+
+// Value implements driver.Valuer interface. Secrets are encrypted when a
+// cipher is set; secret references are stored as they are.
 func (a AuthConfig) Value() (driver.Value, error) {
 	if a.Type == "" {
 		return "{}", nil
 	}
+	encrypted, err := a.encrypted()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encrypted)
+}
+
+// Scan implements sql.Scanner interface. Encrypted secrets are decrypted.
+func (a *AuthConfig) Scan(value interface{}) error {
+	*a = AuthConfig{}
+	if err := scanJSON(value, a); err != nil {
+		return err
+	}
+	decrypted, err := a.decrypted()
+	if err != nil {
+		return err
+	}
+	*a = decrypted
+	return nil
+}
+
+// encrypted returns the auth config with its secrets encrypted when a cipher
+// is set. Secret references are left as they are.
+func (a AuthConfig) encrypted() (AuthConfig, error) {
+	if secretCipher == nil {
+		return a, nil
+	}
+	for _, field := range a.secretFields() {
+		if secrets.IsReference(*field) {
+			continue
+		}
+		encrypted, err := secretCipher.Encrypt(*field)
+		if err != nil {
+			return a, fmt.Errorf("failed to encrypt auth config: %v", err)
+		}
+		*field = encrypted
+	}
+	return a, nil
+}
+
+// decrypted returns the auth config with its encrypted secrets decrypted
+func (a AuthConfig) decrypted() (AuthConfig, error) {
+	for _, field := range a.secretFields() {
+		if !secrets.IsEncrypted(*field) {
+			continue
+		}
+		if secretCipher == nil {
+			return a, fmt.Errorf("auth config secrets are encrypted, but no encryption key is configured")
+		}
+		decrypted, err := secretCipher.Decrypt(*field)
+		if err != nil {
+			return a, err
+		}
+		*field = decrypted
+	}
+	return a, nil
+}
+
+// specAuthPaths are the paths of the auth overrides of a test spec: the
+// request and the updates of a conflict check
+var specAuthPaths = [][]string{
+	{"request", "auth"},
+	{"conflict", "update", "auth"},
+	{"conflict", "second_update", "auth"},
+}
+
+// rewriteSpecAuth applies rewrite to the auth overrides of a test spec given
+// as JSON, with the index of their path in specAuthPaths. The other fields
+// are kept as they are, and specs that are not valid JSON are returned
+// unchanged.
+func rewriteSpecAuth(spec string, rewrite func(path int, auth AuthConfig) (AuthConfig, error)) (string, error) {
+	document := json.RawMessage(spec)
+	changed := false
+	for i, path := range specAuthPaths {
+		rewritten, ok, err := rewriteAuthAt(document, path, func(auth AuthConfig) (AuthConfig, error) {
+			return rewrite(i, auth)
+		})
+		if err != nil {
+			return spec, err
+		}
+		if ok {
+			document, changed = rewritten, true
+		}
+	}
+	if !changed {
+		return spec, nil
+	}
+	return string(document), nil
+}
+
+// rewriteAuthAt rewrites the auth config at path within a JSON document,
+// reporting whether it changed
+func rewriteAuthAt(document json.RawMessage, path []string, rewrite func(AuthConfig) (AuthConfig, error)) (json.RawMessage, bool, error) {
+	if len(path) == 0 {
+		var auth AuthConfig
+		if string(document) == "null" || json.Unmarshal(document, &auth) != nil {
+			return document, false, nil
+		}
+		rewritten, err := rewrite(auth)
+		if err != nil || reflect.DeepEqual(rewritten, auth) {
+			return document, false, err
+		}
+		encoded, err := json.Marshal(rewritten)
+		return encoded, err == nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(document, &fields) != nil {
+		return document, false, nil
+	}
+	child, ok := fields[path[0]]
+	if !ok {
+		return document, false, nil
+	}
+	rewritten, changed, err := rewriteAuthAt(child, path[1:], rewrite)
+	if err != nil || !changed {
+		return document, false, err
+	}
+	fields[path[0]] = rewritten
+	encoded, err := json.Marshal(fields)
+	return encoded, err == nil, err
+}
+
+// EncryptTestSpec returns a test spec, given as JSON, with the secrets of its
+// auth overrides encrypted when a cipher is set, as stored
+func EncryptTestSpec(spec string) (string, error) {
+	if secretCipher == nil {
+		return spec, nil
+	}
+	return rewriteSpecAuth(spec, func(_ int, auth AuthConfig) (AuthConfig, error) {
+		return auth.encrypted()
+	})
+}
+
+// DecryptTestSpec returns a stored test spec with the encrypted secrets of
+// its auth overrides decrypted
+func DecryptTestSpec(spec string) (string, error) {
+	return rewriteSpecAuth(spec, func(_ int, auth AuthConfig) (AuthConfig, error) {
+		return auth.decrypted()
+	})
+}
+
+// MaskTestSpec returns a test spec with the secrets of its auth overrides
+// replaced by MaskedSecret
+func MaskTestSpec(spec string) string {
+	masked, _ := rewriteSpecAuth(spec, func(_ int, auth AuthConfig) (AuthConfig, error) {
+		return auth.Masked(), nil
+	})
+	return masked
+}
+
+// UnmaskTestSpec returns a test spec with the secrets of its auth overrides
+// left masked restored from the stored spec, so clients can send back a test
+// case they retrieved
+func UnmaskTestSpec(spec, stored string) string {
+	storedAuth := make([]AuthConfig, len(specAuthPaths))
+	rewriteSpecAuth(stored, func(path int, auth AuthConfig) (AuthConfig, error) {
+		storedAuth[path] = auth
+		return auth, nil
+	})
+	unmasked, _ := rewriteSpecAuth(spec, func(path int, auth AuthConfig) (AuthConfig, error) {
+		return auth.Unmask(storedAuth[path]), nil
+	})
+	return unmasked
+}
+
+// rewriteAuth returns the test spec with rewrite applied to its auth
+// overrides, leaving the spec it was copied from untouched
+func (t TestSpec) rewriteAuth(rewrite func(AuthConfig) (AuthConfig, error)) (TestSpec, error) {
+	requests := []*RequestSpec{&t.Request}
+	if t.Conflict != nil {
+		conflict := *t.Conflict
+		t.Conflict = &conflict
+		requests = append(requests, &conflict.Update)
+		if conflict.SecondUpdate != nil {
+			second := *conflict.SecondUpdate
+			conflict.SecondUpdate = &second
+			requests = append(requests, &second)
+		}
+	}
+	for _, request := range requests {
+		if request.Auth == nil {
+			continue
+		}
+		auth, err := rewrite(*request.Auth)
+		if err != nil {
+			return t, err
+		}
+		request.Auth = &auth
+	}
+	return t, nil
+}
+
+// Variables represents a set of named template variables stored as JSONB
+type Variables map[string]string
+
+// Value implements driver.Valuer interface
+func (v Variables) Value() (driver.Value, error) {
+	if len(v) == 0 {
+		return "{}", nil
+	}
+	return json.Marshal(v)
+}
+
+// Scan implements sql.Scanner interface
+func (v *Variables) Scan(value interface{}) error {
+	*v = Variables{}
+	return scanJSON(value, v)
+}
+
+// ResolvedVariable records the value a variable resolved to and the scope it came from
+type ResolvedVariable struct {
+	Value  string `json:"value"`
+	Source string `json:"source"` // "service", "discovery", "environment", "run_override"
+}
+
+// VariableReport maps a service ID to the variables resolved for it during a run
+type VariableReport map[string]map[string]ResolvedVariable
+
+// Value implements driver.Valuer interface
+func (r VariableReport) Value() (driver.Value, error) {
+	if len(r) == 0 {
+		return "{}", nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan implements sql.Scanner interface
+func (r *VariableReport) Scan(value interface{}) error {
+	*r = VariableReport{}
+	return scanJSON(value, r)
+}
+
+// APIVersioning describes how a service selects its API version
+type APIVersioning struct {
+	Strategy   string   `json:"strategy,omitempty"`    // "header", "path" or "query"
+	HeaderName string   `json:"header_name,omitempty"` // header strategy, defaults to "Accept-Version"
+	QueryParam string   `json:"query_param,omitempty"` // query strategy, defaults to "version"
+	Default    string   `json:"default,omitempty"`     // version pinned when a run does not select one
+	Supported  []string `json:"supported,omitempty"`   // versions a run may select; empty allows any
+}
+
+// Value implements driver.Valuer interface
+func (a APIVersioning) Value() (driver.Value, error) {
+	if a.Strategy == "" {
+		return "{}", nil
+	}
 	return json.Marshal(a)
 }
 
 // Scan implements sql.Scanner interface
-func (a *AuthConfig) Scan(value interface{}) error {
-	if value == nil {
-		*a = AuthConfig{}
+func (a *APIVersioning) Scan(value interface{}) error {
+	*a = APIVersioning{}
+	return scanJSON(value, a)
+}
+
+// SupportsVersion reports whether a run may select the given version
+func (a APIVersioning) SupportsVersion(version string) bool {
+	if len(a.Supported) == 0 {
+		return true
+	}
+	for _, supported := range a.Supported {
+		if supported == version {
+			return true
+		}
+	}
+	return false
+}
+
+// StringList represents a list of strings stored as JSONB
+type StringList []string
+
+// Value implements driver.Valuer interface
+func (l StringList) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(l)
+}
+
+// Scan implements sql.Scanner interface
+func (l *StringList) Scan(value interface{}) error {
+	*l = StringList{}
+	return scanJSON(value, l)
+}
+
+// JSONValue holds an arbitrary JSON document stored as JSONB
+type JSONValue json.RawMessage
+
+// NewJSONValue encodes v as a JSONValue; values that cannot be encoded are stored as null
+func NewJSONValue(v interface{}) JSONValue {
+	data, err := json.Marshal(v)
+	if err != nil {
 		return nil
 	}
+	return data
+}
 
+// Value implements driver.Valuer interface
+func (j JSONValue) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return "null", nil
+	}
+	return string(j), nil
+}
+
+// Scan implements sql.Scanner interface
+func (j *JSONValue) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		*j = append(JSONValue{}, v...)
+	case string:
+		*j = JSONValue(v)
+	default:
+		*j = nil
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler interface
+func (j JSONValue) MarshalJSON() ([]byte, error) {
+	if len(j) == 0 {
+		return []byte("null"), nil
+	}
+	return j, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (j *JSONValue) UnmarshalJSON(data []byte) error {
+	*j = append(JSONValue{}, data...)
+	return nil
+}
+
+// StatusCodeSummary counts the HTTP status codes observed in responses
+type StatusCodeSummary struct {
+	Codes      map[string]int `json:"codes"`                // e.g. "200": 12
+	Classes    map[string]int `json:"classes"`              // e.g. "2xx": 12, "5xx": 1
+	Unexpected map[string]int `json:"unexpected,omitempty"` // codes that failed a status_code assertion
+	NoResponse int            `json:"no_response"`          // executions that never received a response
+}
+
+// StatusTaxonomy aggregates observed status codes for a run and per service ID
+type StatusTaxonomy struct {
+	Total    StatusCodeSummary            `json:"total"`
+	Services map[string]StatusCodeSummary `json:"services"`
+}
+
+// Value implements driver.Valuer interface
+func (t StatusTaxonomy) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// Scan implements sql.Scanner interface
+func (t *StatusTaxonomy) Scan(value interface{}) error {
+	*t = StatusTaxonomy{}
+	return scanJSON(value, t)
+}
+
+// NotificationConfig configures where a service reports runs that finish
+// with failures
+type NotificationConfig struct {
+	Slack *SlackNotification `json:"slack,omitempty"`
+}
+
+// SlackNotification posts to Slack through an incoming webhook, or through
+// chat.postMessage with a bot token and channel
+type SlackNotification struct {
+	WebhookURL string `json:"webhook_url,omitempty"`
+	BotToken   string `json:"bot_token,omitempty"`
+	Channel    string `json:"channel,omitempty"`
+	Mention    string `json:"mention,omitempty"` // e.g. "<!here>" or "<@U123>", prepended to the message
+}
+
+// Value implements driver.Valuer interface
+func (n NotificationConfig) Value() (driver.Value, error) {
+	if n.Slack == nil {
+		return "{}", nil
+	}
+	return json.Marshal(n)
+}
+
+// Scan implements sql.Scanner interface
+func (n *NotificationConfig) Scan(value interface{}) error {
+	*n = NotificationConfig{}
+	return scanJSON(value, n)
+}
+
+// GRPCConfig configures how the methods of a gRPC service are resolved.
+// Without a descriptor set the service is queried through server reflection.
+type GRPCConfig struct {
+	// DescriptorFixtureID references a fixture holding a binary FileDescriptorSet,
+	// e.g. from protoc --include_imports --descriptor_set_out
+	DescriptorFixtureID string `json:"descriptor_fixture_id,omitempty"`
+	DescriptorVersion   int    `json:"descriptor_version,omitempty"` // fixture version, 0 selects the latest
+}
+
+// Value implements driver.Valuer interface
+func (g GRPCConfig) Value() (driver.Value, error) {
+	return json.Marshal(g)
+}
+
+// Scan implements sql.Scanner interface
+func (g *GRPCConfig) Scan(value interface{}) error {
+	*g = GRPCConfig{}
+	return scanJSON(value, g)
+}
+
+// TLSConfig adjusts how the TLS certificates of a service are verified, e.g.
+// for test environments with self-signed certificates
+type TLSConfig struct {
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"` // accept any certificate
+	ServerName         string `json:"server_name,omitempty"`          // name verified instead of the host of the base URL
+	ExpiryAlertDays    int    `json:"expiry_alert_days,omitempty"`    // days before certificate expiry to alert, 0 uses TLS_EXPIRY_ALERT_DAYS
+}
+
+// Value implements driver.Valuer interface
+func (t TLSConfig) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// Scan implements sql.Scanner interface
+func (t *TLSConfig) Scan(value interface{}) error {
+	*t = TLSConfig{}
+	return scanJSON(value, t)
+}
+
+// TLSAudit is the latest check of the TLS configuration of a service
+type TLSAudit struct {
+	CheckedAt       time.Time         `json:"checked_at"`
+	Address         string            `json:"address"`                      // host:port that was checked
+	Version         string            `json:"version,omitempty"`            // negotiated protocol version, e.g. TLS 1.3
+	CipherSuite     string            `json:"cipher_suite,omitempty"`       // negotiated cipher suite
+	Certificates    []CertificateInfo `json:"certificates,omitempty"`       // chain presented by the server, leaf first
+	Verified        bool              `json:"verified"`                     // the chain is valid for the server name
+	VerifyError     string            `json:"verify_error,omitempty"`
+	ExpiresAt       *time.Time        `json:"expires_at,omitempty"`         // earliest expiry of the presented chain
+	DaysUntilExpiry int               `json:"days_until_expiry"`
+	Issues          []string          `json:"issues,omitempty"`             // weaknesses found, e.g. a deprecated version
+	Error           string            `json:"error,omitempty"`              // why the check failed, e.g. connection refused
+	AlertedExpiry   *time.Time        `json:"alerted_expiry,omitempty"`     // expiry the last alert was sent for
+}
+
+// CertificateInfo describes a certificate of a TLS chain
+type CertificateInfo struct {
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SerialNumber       string    `json:"serial_number"`
+	DNSNames           []string  `json:"dns_names,omitempty"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+}
+
+// Value implements driver.Valuer interface
+func (t TLSAudit) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// Scan implements sql.Scanner interface
+func (t *TLSAudit) Scan(value interface{}) error {
+	*t = TLSAudit{}
+	return scanJSON(value, t)
+}
+
+// ServiceDiscovery resolves the base URL of a service when a run starts, so
+// internal services need no hard-coded URL. With the "kubernetes" provider
+// the URL is the cluster DNS name of the service; with "consul" it is the
+// address of a healthy instance from the Consul catalog.
+type ServiceDiscovery struct {
+	Provider   string            `json:"provider,omitempty"` // "kubernetes" or "consul", empty disables discovery
+	Name       string            `json:"name,omitempty"`     // name of the service in the registry
+	Namespace  string            `json:"namespace,omitempty"`
+	Namespaces map[string]string `json:"namespaces,omitempty"` // namespace per environment name, overrides Namespace
+	Port       string            `json:"port,omitempty"`       // port number, or port name resolved through DNS SRV records
+	Scheme     string            `json:"scheme,omitempty"`     // default http
+	Path       string            `json:"path,omitempty"`       // base path appended to the discovered address
+	Datacenter string            `json:"datacenter,omitempty"` // Consul datacenter, default the agent's
+	Tag        string            `json:"tag,omitempty"`        // only Consul instances with this tag
+}
+
+// Value implements driver.Valuer interface
+func (d ServiceDiscovery) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+// Scan implements sql.Scanner interface
+func (d *ServiceDiscovery) Scan(value interface{}) error {
+	*d = ServiceDiscovery{}
+	return scanJSON(value, d)
+}
+
+// NamespaceFor returns the namespace of the service in an environment
+func (d ServiceDiscovery) NamespaceFor(environment string) string {
+	if namespace, ok := d.Namespaces[environment]; ok && environment != "" {
+		return namespace
+	}
+	return d.Namespace
+}
+
+// RetryPolicy retries tests that fail transiently. RetryOn lists failure
+// types or categories (network, timeout, 5xx, 4xx, assertion_failure, ...)
+// and defaults to network failures and 5xx responses.
+type RetryPolicy struct {
+	MaxAttempts       int      `json:"max_attempts"`                 // total attempts including the first, 1 disables retries
+	BackoffMs         int      `json:"backoff_ms,omitempty"`         // delay before the first retry, default 500
+	BackoffMultiplier float64  `json:"backoff_multiplier,omitempty"` // growth of the delay per retry, default 2
+	RetryOn           []string `json:"retry_on,omitempty"`
+}
+
+// Value implements driver.Valuer interface
+func (r RetryPolicy) Value() (driver.Value, error) {
+	if r.MaxAttempts == 0 {
+		return "{}", nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan implements sql.Scanner interface
+func (r *RetryPolicy) Scan(value interface{}) error {
+	*r = RetryPolicy{}
+	return scanJSON(value, r)
+}
+
+// scanJSON unmarshals a JSONB column value into dest, ignoring empty values
+func scanJSON(value interface{}, dest interface{}) error {
 	switch v := value.(type) {
 	case []byte:
 		if len(v) == 0 {
-			*a = AuthConfig{}
 			return nil
 		}
-		return json.Unmarshal(v, a)
+		return json.Unmarshal(v, dest)
 	case string:
 		if v == "" {
-			*a = AuthConfig{}
 			return nil
 		}
-		return json.Unmarshal([]byte(v), a)
+		return json.Unmarshal([]byte(v), dest)
 	default:
-		*a = AuthConfig{}
 		return nil
 	}
 }
 
+// Project groups services, environments, suites, schedules, hooks, fixtures
+// and runs, so that several teams can share one deployment without seeing
+// each other's resources. Test cases belong to the project of their service.
+type Project struct {
+	ID          string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	Description string    `json:"description"`
+	LeakDetection LeakDetection `json:"leak_detection" gorm:"type:jsonb;default:'{}'"` // scans the responses of its runs for secrets
+	Quotas      ProjectQuotas `json:"quotas" gorm:"type:jsonb;default:'{}'"` // limits the share of the deployment its runs use
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// ProjectQuotas limits the runs of a project, so one team cannot starve a
+// shared deployment. Zero values are unlimited.
+type ProjectQuotas struct {
+	MaxConcurrentRuns int `json:"max_concurrent_runs,omitempty"`
+	MaxTestsPerDay    int `json:"max_tests_per_day,omitempty"` // test executions started per UTC day
+	MaxVirtualUsers   int `json:"max_virtual_users,omitempty"` // parallel test executions (max_concurrency) summed over running runs
+}
+
+// Value implements driver.Valuer interface
+func (q ProjectQuotas) Value() (driver.Value, error) {
+	return json.Marshal(q)
+}
+
+// Scan implements sql.Scanner interface
+func (q *ProjectQuotas) Scan(value interface{}) error {
+	*q = ProjectQuotas{}
+	return scanJSON(value, q)
+}
+
+// LeakDetection configures the detection of secrets and personal data in
+// the responses captured by the runs of a project
+type LeakDetection struct {
+	Enabled   bool              `json:"enabled"`
+	Detectors []string          `json:"detectors,omitempty"` // built-in detectors applied, all when empty
+	Patterns  map[string]string `json:"patterns,omitempty"`  // additional detectors: name -> regular expression
+	Allow     []string          `json:"allow,omitempty"`     // values never reported, e.g. documented test keys
+}
+
+// Value implements driver.Valuer interface
+func (l LeakDetection) Value() (driver.Value, error) {
+	return json.Marshal(l)
+}
+
+// Scan implements sql.Scanner interface
+func (l *LeakDetection) Scan(value interface{}) error {
+	*l = LeakDetection{}
+	return scanJSON(value, l)
+}
+
 // Service represents a microservice that can be tested
 type Service struct {
 	ID          string     `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
 	Name        string     `json:"name" gorm:"uniqueIndex;not null"`
 	Description string     `json:"description"`
 	BaseURL     string     `json:"base_url" gorm:"not null"`
+	Protocol    string     `json:"protocol" gorm:"default:'http'"` // default protocol of the tests, "http" or "grpc"
+	GRPC        GRPCConfig `json:"grpc" gorm:"type:jsonb;default:'{}'"`
+	TLS         TLSConfig  `json:"tls" gorm:"type:jsonb;default:'{}'"` // certificate verification of HTTP and websocket tests
+	Discovery   ServiceDiscovery `json:"discovery" gorm:"type:jsonb;default:'{}'"` // resolves the base URL at run time instead of BaseURL
 	AuthConfig  AuthConfig `json:"auth_config" gorm:"type:jsonb;default:'{}'"`
+	Variables   Variables  `json:"variables" gorm:"type:jsonb;default:'{}'"`
+	APIVersioning APIVersioning `json:"api_versioning" gorm:"type:jsonb;default:'{}'"`
+	LatencyBudgetMs int        `json:"latency_budget_ms" gorm:"default:0"` // response time budget inherited by every test, 0 disables
+	TimeoutMs   int        `json:"timeout_ms" gorm:"default:0"` // deadline of each of its tests, 0 uses the default of 30s
+	Region      string     `json:"region,omitempty"` // region the service is deployed in, e.g. eu-west-1; tests run from a worker in or near it
+	Notifications NotificationConfig `json:"notifications" gorm:"type:jsonb;default:'{}'"`
+	Redaction   RedactionRules `json:"redaction" gorm:"type:jsonb;default:'{}'"` // masks sensitive data of its responses before they are stored
+	DependsOn   StringList `json:"depends_on" gorm:"type:jsonb;default:'[]'"` // IDs of the services it consumes, whose failures impact it
+	TLSAudit    *TLSAudit  `json:"tls_audit,omitempty" gorm:"type:jsonb"` // latest TLS check of an https base URL, set by the TLS audit
+	ProjectID   *string    `json:"project_id,omitempty" gorm:"type:uuid;index"` // project the service belongs to
+	Project     *Project   `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
 	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
 	IsActive    bool       `json:"is_active" gorm:"default:true"`
 }
 
+// MarshalJSON implements json.Marshaler interface. The secrets of the auth
+// config are masked, so they never leave the API.
+func (s Service) MarshalJSON() ([]byte, error) {
+	type service Service
+	masked := service(s)
+	masked.AuthConfig = s.AuthConfig.Masked()
+	return json.Marshal(masked)
+}
+
+// RedactionRules mask sensitive data in captured responses, e.g. PHI of a
+// FHIR service, on top of the headers and fields that are always masked
+type RedactionRules struct {
+	Headers []string `json:"headers,omitempty"` // response header names, case-insensitive, e.g. X-Patient-Token
+	Paths   []string `json:"paths,omitempty"`   // dotted paths in the body, e.g. patient.ssn or entry.*.resource.birthDate
+}
+
+// Value implements driver.Valuer interface
+func (r RedactionRules) Value() (driver.Value, error) {
+	return json.Marshal(r)
+}
+
+// Scan implements sql.Scanner interface
+func (r *RedactionRules) Scan(value interface{}) error {
+	*r = RedactionRules{}
+	return scanJSON(value, r)
+}
+
+// Environment represents a named set of variables (e.g. staging, production)
+// applied on top of service variables when a run targets it
+type Environment struct {
+	ID          string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	Description string    `json:"description"`
+	Variables   Variables `json:"variables" gorm:"type:jsonb;default:'{}'"`
+	ProjectID   *string    `json:"project_id,omitempty" gorm:"type:uuid;index"` // project the environment belongs to
+	Project     *Project   `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Schedule starts test runs automatically on a cron schedule
+type Schedule struct {
+	ID             string     `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name           string     `json:"name" gorm:"uniqueIndex;not null"`
+	CronExpression string     `json:"cron_expression" gorm:"not null"` // five-field cron expression or macro such as @hourly
+	Timezone       string     `json:"timezone" gorm:"default:'UTC'"`   // IANA zone the expression is evaluated in
+	ServiceID      *string    `json:"service_id" gorm:"type:uuid"`
+	Service        *Service   `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	TestIDs        StringList `json:"test_ids" gorm:"type:jsonb;default:'[]'"`
+	EnvironmentID  *string    `json:"environment_id" gorm:"type:uuid"`
+	Variables      Variables  `json:"variables" gorm:"type:jsonb;default:'{}'"`
+	MaxConcurrency int        `json:"max_concurrency" gorm:"default:1"`
+	Enabled        bool       `json:"enabled" gorm:"not null"`
+	NextRunAt      *time.Time `json:"next_run_at" gorm:"index"`
+	LastRunAt      *time.Time `json:"last_run_at"`
+	LastRunID      *string    `json:"last_run_id" gorm:"type:uuid"`
+	ProjectID      *string    `json:"project_id,omitempty" gorm:"type:uuid;index"` // project of the schedule and of the runs it starts
+	Project        *Project   `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Hook is an inbound webhook that CI/CD systems call to start a predefined
+// test run. Callers authenticate with the hook's token, either directly or as
+// the secret signing GitHub webhook payloads.
+type Hook struct {
+	ID             string     `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name           string     `json:"name" gorm:"uniqueIndex;not null"`
+	Token          string     `json:"-" gorm:"not null"` // returned only when the hook is created or its token rotated
+	SuiteID        *string    `json:"suite_id" gorm:"type:uuid"` // runs the suite instead of service_id and test_ids
+	ServiceID      *string    `json:"service_id" gorm:"type:uuid"`
+	Service        *Service   `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	TestIDs        StringList `json:"test_ids" gorm:"type:jsonb;default:'[]'"`
+	EnvironmentID  *string    `json:"environment_id" gorm:"type:uuid"`
+	Variables      Variables  `json:"variables" gorm:"type:jsonb;default:'{}'"`
+	Metadata       Variables  `json:"metadata" gorm:"type:jsonb;default:'{}'"` // run variable name -> gjson path in the payload, e.g. "commit": "head_commit.id"
+	Events         StringList `json:"events" gorm:"type:jsonb;default:'[]'"`   // accepted event types, e.g. push; all when empty
+	MaxConcurrency int        `json:"max_concurrency" gorm:"default:1"`
+	Enabled        bool       `json:"enabled" gorm:"not null"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+	LastRunID      *string    `json:"last_run_id" gorm:"type:uuid"`
+	ProjectID      *string    `json:"project_id,omitempty" gorm:"type:uuid;index"` // project of the hook and of the runs it starts
+	Project        *Project   `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
 // TestCase represents a test case for a service
 type TestCase struct {
 	ID          string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
-	ServiceID   string    `json:"service_id" gorm:"not null"`
+	ServiceID   string    `json:"service_id" gorm:"type:uuid;not null;index"`
 	Name        string    `json:"name" gorm:"not null"`
 	Description string    `json:"description"`
 	TestSpec    string    `json:"test_spec" gorm:"type:jsonb;not null"`
 	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt   time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 	IsActive    bool      `json:"is_active" gorm:"default:true"`
-	Service     Service   `json:"service" gorm:"foreignKey:ServiceID;references:ID"`
+	Tags        StringList `json:"tags" gorm:"type:jsonb;default:'[]'"` // labels such as smoke or regression selecting the test in lists and runs
+	Service     Service   `json:"service" gorm:"foreignKey:ServiceID;references:ID;constraint:OnDelete:RESTRICT"` // services are deleted once their test cases are archived
+}
+
+// MarshalJSON implements json.Marshaler interface. The secrets of the auth
+// overrides of the spec are masked, like those of services.
+func (tc TestCase) MarshalJSON() ([]byte, error) {
+	type testCase TestCase
+	masked := testCase(tc)
+	masked.TestSpec = MaskTestSpec(tc.TestSpec)
+	return json.Marshal(masked)
+}
+
+// ArchivedTestCase is a test case of a deleted service. It keeps the ID the
+// test case had and the name of its service, so the runs that executed it
+// stay explainable.
+type ArchivedTestCase struct {
+	ID          string     `json:"id" gorm:"primarykey;type:uuid"`
+	ServiceID   string     `json:"service_id" gorm:"type:uuid;index"` // the deleted service
+	ServiceName string     `json:"service_name"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	TestSpec    string     `json:"test_spec" gorm:"type:jsonb"`
+	Tags        StringList `json:"tags" gorm:"type:jsonb;default:'[]'"`
+	IsActive    bool       `json:"is_active"` // whether the test case was active when it was archived
+	CreatedAt   time.Time  `json:"created_at"`
+	ArchivedAt  time.Time  `json:"archived_at" gorm:"index"`
+}
+
+// MarshalJSON implements json.Marshaler interface. The secrets of the auth
+// overrides of the spec are masked, as for test cases.
+func (tc ArchivedTestCase) MarshalJSON() ([]byte, error) {
+	type archivedTestCase ArchivedTestCase
+	masked := archivedTestCase(tc)
+	masked.TestSpec = MaskTestSpec(tc.TestSpec)
+	return json.Marshal(masked)
+}
+
+// AfterFind decrypts the secrets of the auth overrides of the spec, archived
+// encrypted with their test case
+func (tc *ArchivedTestCase) AfterFind(tx *gorm.DB) error {
+	spec, err := DecryptTestSpec(tc.TestSpec)
+	if err != nil {
+		return err
+	}
+	tc.TestSpec = spec
+	return nil
+}
+
+// TestSuite groups test cases into a unit executed in an explicit order, with
+// optional setup and teardown request steps around the whole suite and
+// around every test case
+type TestSuite struct {
+	ID          string     `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name        string     `json:"name" gorm:"uniqueIndex;not null"`
+	Description string     `json:"description"`
+	ServiceID   *string    `json:"service_id" gorm:"type:uuid"` // default service of the steps
+	Service     *Service   `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	TestIDs     StringList `json:"test_ids" gorm:"type:jsonb;default:'[]'"` // test cases in execution order
+	BeforeAll   SuiteSteps `json:"before_all" gorm:"type:jsonb;default:'[]'"`
+	AfterAll    SuiteSteps `json:"after_all" gorm:"type:jsonb;default:'[]'"`
+	BeforeEach  SuiteSteps `json:"before_each" gorm:"type:jsonb;default:'[]'"`
+	AfterEach   SuiteSteps `json:"after_each" gorm:"type:jsonb;default:'[]'"`
+	TestExports TestExports `json:"test_exports" gorm:"type:jsonb;default:'{}'"` // variables the test cases pass on to the later ones
+	Parallel    *bool      `json:"parallel,omitempty"`               // false keeps its test cases from running concurrently in any run
+	MaxParallel int        `json:"max_parallel" gorm:"default:0"`    // test cases running at once in any run, 0 for no limit
+	Tags        StringList `json:"tags" gorm:"type:jsonb;default:'[]'"` // labels; smoke:auto marks the generated smoke suite of a service
+	ProjectID   *string    `json:"project_id,omitempty" gorm:"type:uuid;index"` // project the suite belongs to
+	Project     *Project   `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// ParallelLimit returns how many test cases of the suite may run at once in
+// any run, 0 when the suite sets no limit
+func (s TestSuite) ParallelLimit() int {
+	if s.Parallel != nil && !*s.Parallel {
+		return 1
+	}
+	return s.MaxParallel
+}
+
+// SuiteStep is a setup or teardown request of a suite, e.g. seeding or
+// cleaning up data. It runs against its own service or the suite's service.
+// The response of a step with an ID is available to later requests as
+// {{steps.<id>.<path>}}, e.g. {{steps.createPatient.body.id}}; Export
+// publishes values of it as plain variables. A step with poll_until repeats
+// its request until the condition holds, and a step with only wait_ms pauses
+// the steps, e.g. while an asynchronous job runs.
+type SuiteStep struct {
+	ID        string            `json:"id,omitempty"`
+	ServiceID string            `json:"service_id,omitempty"`
+	Export    map[string]string `json:"export,omitempty"`   // variable name -> gjson path in the response, e.g. body.id
+	If        *StepCondition    `json:"if,omitempty"`       // the step only runs when the condition holds
+	Else      *SuiteStep        `json:"else,omitempty"`     // runs instead when the condition does not hold, may have its own condition
+	ForEach   *StepLoop         `json:"for_each,omitempty"` // repeats the step for every item of an array
+	WaitMs    int               `json:"wait_ms,omitempty"`  // a step without a request only waits this long
+	Parallel  *StepFanOut       `json:"parallel,omitempty"` // a step without a request runs these steps concurrently
+	TestSpec
+}
+
+// StepFanOut runs independent suite steps concurrently and joins before the
+// next step. Repeat sends every step several times at once, and
+// ExpectStatusCounts checks how many requests got each status code, e.g.
+// {"200": 1, "409": 4} when only one of five concurrent updates may win.
+type StepFanOut struct {
+	Steps              []SuiteStep `json:"steps"`
+	Repeat             int         `json:"repeat,omitempty"`               // copies of every step sent at once, defaults to 1
+	ExpectStatusCounts map[int]int `json:"expect_status_counts,omitempty"` // status code -> number of requests
+}
+
+// StepLoop repeats a suite step for every item of a JSON array, usually from
+// the response of an earlier step such as steps.search.body.entry. Each
+// iteration sees the item as {{<as>}}, its fields as {{<as>.<path>}} and its
+// position as {{<as>_index}}.
+type StepLoop struct {
+	Over          string `json:"over"`                     // variable holding the array
+	As            string `json:"as,omitempty"`             // name of the item variable, defaults to item
+	MaxIterations int    `json:"max_iterations,omitempty"` // larger arrays fail the step, defaults to 100
+}
+
+// StepCondition makes a suite step conditional on a variable, usually the
+// response of an earlier step such as steps.createOrder.body.status
+type StepCondition struct {
+	Variable string      `json:"variable"`
+	Matcher  string      `json:"matcher,omitempty"` // exists, not_exists, equals, not_equals, contains or regex; equals when expected is set, exists otherwise
+	Expected interface{} `json:"expected,omitempty"`
+}
+
+// SuiteSteps is a list of suite steps stored as JSONB
+type SuiteSteps []SuiteStep
+
+// Value implements driver.Valuer interface
+func (s SuiteSteps) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	return json.Marshal(s)
+}
+
+// Scan implements sql.Scanner interface
+func (s *SuiteSteps) Scan(value interface{}) error {
+	*s = nil
+	return scanJSON(value, s)
+}
+
+// TestExports maps the ID of a test case of a suite to the variables it
+// exports: variable name -> gjson path in its response, e.g. body.id. The
+// later test cases and steps of the run see them, whichever service they
+// target.
+type TestExports map[string]map[string]string
+
+// Value implements driver.Valuer interface
+func (e TestExports) Value() (driver.Value, error) {
+	if len(e) == 0 {
+		return "{}", nil
+	}
+	return json.Marshal(e)
+}
+
+// Scan implements sql.Scanner interface
+func (e *TestExports) Scan(value interface{}) error {
+	*e = TestExports{}
+	return scanJSON(value, e)
+}
+
+// HookResult is the outcome of a suite step executed by a run
+type HookResult struct {
+	Phase        string `json:"phase"`              // before_all, after_all, before_each or after_each
+	Name         string `json:"name"`
+	StepID       string `json:"step_id,omitempty"`      // id of the step, see SuiteStep
+	TestCaseID   string `json:"test_case_id,omitempty"` // test case an each-step ran for
+	Status       string `json:"status"`             // passed, failed, or skipped when its condition did not hold
+	StatusCode   int    `json:"status_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	FailureType  string `json:"failure_type,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	DurationMs   int64  `json:"duration_ms"`
+	Uses         []string `json:"uses,omitempty"`    // variables the step references
+	Exports      []string `json:"exports,omitempty"` // variables the step exported
+	Branch       string   `json:"branch,omitempty"`    // then or else, for conditional steps
+	Condition    string   `json:"condition,omitempty"` // how the conditions of the step evaluated
+	Iterations   int      `json:"iterations,omitempty"` // items a loop step ran for
+	Polls        int      `json:"polls,omitempty"`      // requests a poll_until step sent
+	Parallel     HookResults `json:"parallel,omitempty"` // outcomes of the steps of a fan-out step
+}
+
+// HookResults is a list of hook results stored as JSONB
+type HookResults []HookResult
+
+// Value implements driver.Valuer interface
+func (h HookResults) Value() (driver.Value, error) {
+	if h == nil {
+		return "[]", nil
+	}
+	return json.Marshal(h)
+}
+
+// Scan implements sql.Scanner interface
+func (h *HookResults) Scan(value interface{}) error {
+	*h = nil
+	return scanJSON(value, h)
 }
 
 // TestRun represents a test execution run
 type TestRun struct {
 	ID             string        `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
 	Name           string        `json:"name"`
-	Status         string        `json:"status" gorm:"default:'running';check:status IN ('running', 'completed', 'failed')"`
+	Status         string        `json:"status" gorm:"default:'running';check:status IN ('running', 'completed', 'failed', 'cancelled')"`
 	TotalTests     int           `json:"total_tests" gorm:"default:0"`
 	PassedTests    int           `json:"passed_tests" gorm:"default:0"`
 	FailedTests    int           `json:"failed_tests" gorm:"default:0"`
+	SkippedTests   int           `json:"skipped_tests" gorm:"default:0"`
+	TimedOutTests  int           `json:"timed_out_tests" gorm:"default:0"`
 	ExecutionTimeMs int64        `json:"execution_time_ms" gorm:"default:0"`
-	StartedAt      time.Time     `json:"started_at" gorm:"autoCreateTime"`
+	StartedAt      time.Time     `json:"started_at" gorm:"autoCreateTime;index"`
 	CompletedAt    *time.Time    `json:"completed_at"`
+	Error          string        `json:"error,omitempty"` // why the run failed other than through its tests, e.g. results that could not be stored
+	MaxConcurrency int           `json:"max_concurrency" gorm:"default:1"`
+	EnvironmentID  *string       `json:"environment_id" gorm:"type:uuid"`
+	VariableOverrides Variables  `json:"variable_overrides" gorm:"type:jsonb;default:'{}'"`
+	ResolvedVariables VariableReport `json:"resolved_variables" gorm:"type:jsonb;default:'{}'"`
+	APIVersions    StringList    `json:"api_versions" gorm:"type:jsonb;default:'[]'"` // versions every test case runs against
+	LatencyBudgetMs int          `json:"latency_budget_ms" gorm:"default:0"` // suite-level budget overriding service budgets
+	TestTimeoutMs  int           `json:"test_timeout_ms" gorm:"default:0"` // deadline of each test case overriding service timeouts, 0 inherits
+	RunTimeoutMs   int           `json:"run_timeout_ms" gorm:"default:0"`  // deadline of the whole run, 0 uses the default of 5 minutes
+	ScheduleID     *string       `json:"schedule_id,omitempty" gorm:"type:uuid;index"` // schedule that started the run
+	HookID         *string       `json:"hook_id,omitempty" gorm:"type:uuid;index"`     // webhook that started the run
+	SuiteID        *string       `json:"suite_id,omitempty" gorm:"type:uuid;index"`    // suite executed by the run
+	RerunOfID      *string       `json:"rerun_of_id,omitempty" gorm:"type:uuid;index"` // run whose failed test cases this run re-executes
+	RetryPolicy    RetryPolicy   `json:"retry_policy" gorm:"type:jsonb;default:'{}'"`  // default retry policy of the test cases
+	Regions        StringList    `json:"regions" gorm:"type:jsonb;default:'[]'"`       // regions every test case runs from, to compare latency
+	HookResults    HookResults   `json:"hook_results,omitempty" gorm:"type:jsonb;default:'[]'"` // setup and teardown steps of a suite run
+	StatusSummary  StatusTaxonomy `json:"status_summary" gorm:"type:jsonb;default:'{}'"` // observed status codes, computed when the run ends
+	Compacted      bool          `json:"compacted" gorm:"default:false;index"` // response payloads were dropped to save space
+	CompactedAt    *time.Time    `json:"compacted_at,omitempty"`
+	Config         RunConfig     `json:"config" gorm:"type:jsonb;default:'{}'"` // configuration resolved when the run started
+	Debug          bool          `json:"debug" gorm:"default:false"`           // debug output is captured into DebugLog
+	DebugLog       DebugLog      `json:"-" gorm:"type:jsonb;default:'{}'"`      // served by the debug log endpoint only
+	SecurityScan   bool          `json:"security_scan" gorm:"default:false"`   // the endpoint of every test case is scanned, see SecurityFindings
+	DetectLeaks    bool          `json:"detect_leaks" gorm:"default:false"`    // captured responses are scanned for secrets, see SecurityFindings
+	SecurityFindings SecurityFindings `json:"-" gorm:"type:jsonb;default:'[]'"` // served by the security findings endpoint only
+	ProjectID      *string       `json:"project_id,omitempty" gorm:"type:uuid;index"` // project the run belongs to
+	Project        *Project      `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
 	TestResults    []TestResult  `json:"test_results" gorm:"foreignKey:TestRunID"`
 }
 
+// DebugLog is the log output captured while a run executed with debug
+// enabled, at debug level whatever the configured log level
+type DebugLog struct {
+	Entries []LogEntry `json:"entries"`
+	Dropped int        `json:"dropped,omitempty"` // entries beyond the capture limit
+}
+
+// LogEntry is a captured log record
+type LogEntry struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// Value implements driver.Valuer interface
+func (d DebugLog) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+// Scan implements sql.Scanner interface
+func (d *DebugLog) Scan(value interface{}) error {
+	*d = DebugLog{}
+	return scanJSON(value, d)
+}
+
+// Severities of security findings
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// SecurityFinding is a weakness a security scan found at the endpoint of a
+// test case, or a secret the leak detector found in its response. Findings
+// are reported apart from the functional results.
+type SecurityFinding struct {
+	TestCaseID string `json:"test_case_id"`
+	TestName   string `json:"test_name"`
+	Check      string `json:"check"`    // verb_tampering, missing_auth, oversized_payload, content_type_confusion or secret_leak
+	Severity   string `json:"severity"` // low, medium or high
+	Method     string `json:"method"`   // method of the scan request
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"` // 0 when no response was received
+	Detector   string `json:"detector,omitempty"`    // leak detector that matched, e.g. ssn
+	Path       string `json:"path,omitempty"`        // path of the leaked value in the response data
+	Message    string `json:"message"`
+}
+
+// SecurityFindings is the list of findings of a security scan
+type SecurityFindings []SecurityFinding
+
+// Value implements driver.Valuer interface
+func (f SecurityFindings) Value() (driver.Value, error) {
+	if len(f) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(f)
+}
+
+// Scan implements sql.Scanner interface
+func (f *SecurityFindings) Scan(value interface{}) error {
+	*f = SecurityFindings{}
+	return scanJSON(value, f)
+}
+
+// RunConfig is the configuration a run resolved when it started. It is
+// written once, so the results of a run stay interpretable after its
+// environment, services or test cases changed. Secrets are never included;
+// variable values are reported by the run's ResolvedVariables.
+type RunConfig struct {
+	CapturedAt  time.Time            `json:"captured_at"`
+	Environment *EnvironmentSnapshot `json:"environment,omitempty"`
+	Services    []ServiceSnapshot    `json:"services"`
+	Executor    ExecutorSettings     `json:"executor"`
+	TestCases   []TestCaseSnapshot   `json:"test_cases"`
+}
+
+// EnvironmentSnapshot identifies the environment a run targeted
+type EnvironmentSnapshot struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	VariableNames []string  `json:"variable_names"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ServiceSnapshot records the settings of a service used by a run
+type ServiceSnapshot struct {
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	BaseURL           string    `json:"base_url"` // resolved base URL, after environment, overrides and discovery
+	Protocol          string    `json:"protocol"`
+	AuthType          string    `json:"auth_type"`
+	APIVersion        string    `json:"api_version,omitempty"` // default API version
+	LatencyBudgetMs   int       `json:"latency_budget_ms"`
+	TimeoutMs         int       `json:"timeout_ms"`
+	Region            string    `json:"region,omitempty"`
+	DiscoveryProvider string    `json:"discovery_provider,omitempty"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// ExecutorSettings records how a run executed its test cases
+type ExecutorSettings struct {
+	MaxConcurrency  int         `json:"max_concurrency"`
+	TestTimeoutMs   int64       `json:"test_timeout_ms"` // 0 when test cases use the timeouts of their spec or service
+	RunTimeoutMs    int64       `json:"run_timeout_ms"`
+	RetryPolicy     RetryPolicy `json:"retry_policy"`
+	LatencyBudgetMs int         `json:"latency_budget_ms"`
+	APIVersions     []string    `json:"api_versions,omitempty"`
+	Regions         []string    `json:"regions,omitempty"`
+	Region          string      `json:"region,omitempty"` // region of the instance that started the run
+	SuiteLimits     map[string]int `json:"suite_limits,omitempty"` // parallel limits of the suites of its test cases by suite ID
+}
+
+// TestCaseSnapshot identifies the version of a test case executed by a run.
+// SpecSHA256 changes whenever the test spec changes.
+type TestCaseSnapshot struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	ServiceID  string    `json:"service_id"`
+	SpecSHA256 string    `json:"spec_sha256"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Value implements driver.Valuer interface
+func (c RunConfig) Value() (driver.Value, error) {
+	if c.CapturedAt.IsZero() {
+		return "{}", nil
+	}
+	return json.Marshal(c)
+}
+
+// Scan implements sql.Scanner interface
+func (c *RunConfig) Scan(value interface{}) error {
+	*c = RunConfig{}
+	return scanJSON(value, c)
+}
+
 // TestResult represents the result of a single test execution
 type TestResult struct {
 	ID             string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
-	TestRunID      string    `json:"test_run_id" gorm:"not null"`
-	TestCaseID     string    `json:"test_case_id" gorm:"not null"`
-	Status         string    `json:"status" gorm:"not null;check:status IN ('passed', 'failed', 'skipped')"`
+	TestRunID      string    `json:"test_run_id" gorm:"not null;index;index:idx_test_results_run_position,priority:1;index:idx_test_results_run_status,priority:1"`
+	TestCaseID     string    `json:"test_case_id" gorm:"type:uuid;index:idx_test_results_case_created,priority:1"` // empty once the test case was deleted or archived
+	Position       int       `json:"position" gorm:"default:0;index:idx_test_results_run_position,priority:2"` // order of the test case within the run
+	APIVersion     string    `json:"api_version,omitempty"`     // API version the test case ran against
+	Region         string    `json:"region,omitempty" gorm:"index"` // region of the worker that executed the test
+	Status         string    `json:"status" gorm:"not null;check:status IN ('passed', 'failed', 'skipped', 'timed_out');index:idx_test_results_run_status,priority:2;index:idx_test_results_status_created,priority:1"`
 	ExecutionTimeMs int      `json:"execution_time_ms" gorm:"default:0"`
 	ErrorMessage   string    `json:"error_message"`
+	FailureType    string    `json:"failure_type,omitempty" gorm:"index"` // why the test failed, e.g. timeout or assertion_failure
+	FailureDetail  string    `json:"failure_detail,omitempty"`            // underlying network error, e.g. "lookup api.internal: no such host"
+	Iteration      int       `json:"iteration,omitempty" gorm:"default:0"` // 1-based data row of a data-driven test, 0 otherwise
+	Attempts       int       `json:"attempts" gorm:"default:0"`            // executions including retries, 0 when not executed
+	Flaky          bool      `json:"flaky" gorm:"default:false;index"`     // passed only after a retry
+	DataRow        JSONValue `json:"data_row,omitempty" gorm:"type:jsonb"`  // parameters of the iteration
 	ResponseData   string    `json:"response_data" gorm:"type:jsonb"`
-	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime"`
-	TestCase       TestCase  `json:"test_case" gorm:"foreignKey:TestCaseID;references:ID"`
+	Request        RequestSnapshot `json:"-" gorm:"type:jsonb"` // resolved request, kept for replays
+	CreatedAt      time.Time `json:"created_at" gorm:"autoCreateTime;index:idx_test_results_status_created,priority:2;index:idx_test_results_case_created,priority:2"` // partition key
+	TestCase       TestCase  `json:"test_case" gorm:"foreignKey:TestCaseID;references:ID;constraint:OnDelete:SET NULL"`
+	// Results are partitioned by created_at, so their id alone cannot be referenced by a foreign key
+	AssertionResults []AssertionResult `json:"assertion_results,omitempty" gorm:"foreignKey:TestResultID;constraint:-"`
+}
+
+// RequestSnapshot records the request a test result executed, after variable
+// substitution, so the exact same request can be replayed later
+type RequestSnapshot struct {
+	BaseURL    string   `json:"base_url"`
+	APIVersion string   `json:"api_version,omitempty"`
+	TestSpec   TestSpec `json:"test_spec"`
+}
+
+// Captured reports whether a request was recorded; results stored before
+// snapshots existed, or that failed before sending, have none
+func (r RequestSnapshot) Captured() bool {
+	return r.TestSpec.Request.Method != ""
+}
+
+// Value implements driver.Valuer interface. The secrets of the auth
+// overrides are encrypted when a cipher is set.
+func (r RequestSnapshot) Value() (driver.Value, error) {
+	if !r.Captured() {
+		return "{}", nil
+	}
+	testSpec, err := r.TestSpec.rewriteAuth(AuthConfig.encrypted)
+	if err != nil {
+		return nil, err
+	}
+	r.TestSpec = testSpec
+	return json.Marshal(r)
+}
+
+// Scan implements sql.Scanner interface. Encrypted secrets are decrypted.
+func (r *RequestSnapshot) Scan(value interface{}) error {
+	*r = RequestSnapshot{}
+	if err := scanJSON(value, r); err != nil {
+		return err
+	}
+	testSpec, err := r.TestSpec.rewriteAuth(AuthConfig.decrypted)
+	if err != nil {
+		return err
+	}
+	r.TestSpec = testSpec
+	return nil
+}
+
+// AssertionResult represents the outcome of a single assertion of a test result
+type AssertionResult struct {
+	ID           string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	TestResultID string    `json:"test_result_id" gorm:"type:uuid;not null;index"`
+	Position     int       `json:"position" gorm:"default:0"` // order of the assertion within the test result
+	Variant      string    `json:"variant,omitempty"`         // request matrix variant the assertion ran for
+	Type         string    `json:"type" gorm:"not null"`
+	Path         string    `json:"path,omitempty"`
+	Matcher      string    `json:"matcher,omitempty"`
+	Expected     JSONValue `json:"expected" gorm:"type:jsonb"`
+	Actual       JSONValue `json:"actual" gorm:"type:jsonb"`
+	Passed       bool      `json:"passed"`
+	Message      string    `json:"message,omitempty"`
+	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// Fixture represents a named binary file (PDF, image, CCDA, ...) used as test input
+type Fixture struct {
+	ID            string           `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name          string           `json:"name" gorm:"uniqueIndex;not null"`
+	Description   string           `json:"description"`
+	LatestVersion int              `json:"latest_version" gorm:"default:0"`
+	ProjectID     *string          `json:"project_id,omitempty" gorm:"type:uuid;index"` // project the fixture belongs to
+	Project       *Project         `json:"-" gorm:"constraint:OnDelete:RESTRICT"`
+	CreatedAt     time.Time        `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time        `json:"updated_at" gorm:"autoUpdateTime"`
+	Versions      []FixtureVersion `json:"versions,omitempty" gorm:"foreignKey:FixtureID"`
+}
+
+// FixtureVersion represents an immutable uploaded revision of a fixture
+type FixtureVersion struct {
+	ID          string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	FixtureID   string    `json:"fixture_id" gorm:"type:uuid;not null;uniqueIndex:idx_fixture_version"`
+	Version     int       `json:"version" gorm:"not null;uniqueIndex:idx_fixture_version"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	Checksum    string    `json:"checksum"` // hex-encoded SHA-256 of the content
+	Content     []byte    `json:"-" gorm:"type:bytea"`
+	StorageKey  string    `json:"storage_key,omitempty"` // key of the content in object storage; empty when stored in Content
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// Roles of the users of the API, from the least to the most privileged
+const (
+	RoleViewer = "viewer" // reads services, tests, runs and reports
+	RoleEditor = "editor" // also creates, changes and runs tests
+	RoleAdmin  = "admin"  // also manages users, projects and workers
+)
+
+// User is a person or system calling the API, authenticated with its API
+// keys or with JWTs issued for it
+type User struct {
+	ID        string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Email     string    `json:"email" gorm:"uniqueIndex;not null"`
+	Name      string    `json:"name"`
+	Role      string    `json:"role" gorm:"not null;default:'viewer';check:role IN ('admin', 'editor', 'viewer')"`
+	Disabled  bool      `json:"disabled" gorm:"default:false"` // rejects its keys and tokens without deleting them
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// APIKey authenticates a user. Only a hash of the key is stored; the key
+// itself is returned once, when it is created.
+type APIKey struct {
+	ID         string     `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	UserID     string     `json:"user_id" gorm:"type:uuid;not null;index"`
+	User       *User      `json:"-" gorm:"constraint:OnDelete:CASCADE"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`                         // first characters of the key, to recognize it
+	KeyHash    string     `json:"-" gorm:"uniqueIndex;not null"` // hex SHA-256 of the key
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// Worker represents a registered worker process executing the tests
+// dispatched to its region, or to its egress pool. Workers report their
+// load with heartbeats.
+type Worker struct {
+	ID              string    `json:"id" gorm:"primarykey;type:uuid;default:gen_random_uuid()"`
+	Name            string    `json:"name" gorm:"uniqueIndex;not null"` // e.g. the pod name
+	Region          string    `json:"region" gorm:"index;not null"`
+	Pool            string    `json:"pool,omitempty" gorm:"index"` // egress pool; its workers only execute the tests sent from it
+	Capacity        int       `json:"capacity" gorm:"not null"`    // tests executed in parallel
+	ActiveJobs      int       `json:"active_jobs"`
+	LastHeartbeatAt time.Time `json:"last_heartbeat_at" gorm:"index"`
+	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
 }
 
 // TestSpec represents the specification for a test case
@@ -114,8 +1385,82 @@ type TestSpec struct {
 	Name        string            `json:"name"`
 	Description string            `json:"description"`
 	ServiceName string            `json:"service_name"`
+	Protocol    string            `json:"protocol,omitempty"` // selects the executor, defaults to "http"
 	Request     RequestSpec       `json:"request"`
 	Assertions  []AssertionSpec   `json:"assertions"`
+	Variants    []VariantSpec     `json:"variants,omitempty"`
+	LatencyBudgetMs *int          `json:"latency_budget_ms,omitempty"` // overrides inherited budgets; 0 disables them
+	Data        []map[string]interface{} `json:"data,omitempty"`    // parameter rows; the test runs once per row
+	Dataset     *DatasetRef       `json:"dataset,omitempty"`         // uploaded CSV/JSON fixture providing the rows
+	Retry       *RetryPolicy      `json:"retry,omitempty"`           // overrides the retry policy of the run
+	PollUntil   *PollCondition    `json:"poll_until,omitempty"`      // repeats the request until the condition holds
+	TimeoutMs   int               `json:"timeout_ms,omitempty"`      // deadline of the test including retries, overrides run and service timeouts
+	Conflict    *ConflictSpec     `json:"conflict,omitempty"`        // checks optimistic concurrency after the request
+	TimeMatrix  *TimeMatrix       `json:"time_matrix,omitempty"`     // repeats the request across timezones and clock skews
+	EgressPool  string            `json:"egress_pool,omitempty"`     // worker pool the request is sent from, e.g. to test IP allow-lists
+}
+
+// TimeMatrix repeats the request of a test as variants, once per timezone
+// sent in the timezone header and once per clock skew applied to the time
+// sent in the skew header, and checks that the dates at ConsistentPaths
+// denote the same instant in every response. Dates without a zone, such as
+// birth dates, must be identical.
+type TimeMatrix struct {
+	Timezones       []string `json:"timezones,omitempty"`        // IANA zones, e.g. UTC, America/New_York, Asia/Kolkata
+	TimezoneHeader  string   `json:"timezone_header,omitempty"`  // defaults to Time-Zone
+	ClockSkews      []string `json:"clock_skews,omitempty"`      // durations such as -5m or 2h added to the current time
+	SkewHeader      string   `json:"skew_header,omitempty"`      // defaults to Date, e.g. Accept-Datetime
+	ConsistentPaths []string `json:"consistent_paths,omitempty"` // gjson paths of dates in the response, e.g. body.meta.lastUpdated
+}
+
+// ConflictSpec turns a test into an optimistic-concurrency check. The request
+// of the test reads a resource, then two clients send conflicting updates
+// concurrently with the version captured from the read; exactly one update
+// must succeed and the other be rejected with a conflict status. Updates see
+// the version as {{version}}.
+type ConflictSpec struct {
+	Update           RequestSpec  `json:"update"`                      // update sent by both clients
+	SecondUpdate     *RequestSpec `json:"second_update,omitempty"`     // update of the second client, defaults to update
+	VersionPath      string       `json:"version_path,omitempty"`      // gjson path of the version in the read response, defaults to headers.ETag
+	VersionHeader    string       `json:"version_header,omitempty"`    // header carrying the version on updates, defaults to If-Match for header versions
+	ConflictStatuses []int        `json:"conflict_statuses,omitempty"` // statuses of the rejected update, defaults to 409 and 412
+}
+
+// PollCondition repeats the request of a test every interval until the
+// response satisfies the condition or the timeout expires, e.g. until an
+// asynchronous job reports completion. The assertions of the test then run
+// against the final response. Path is a gjson path on the response body,
+// matched like a json_path assertion; StatusCode, if set, must match as well.
+type PollCondition struct {
+	Path       string      `json:"path,omitempty"`
+	Matcher    string      `json:"matcher,omitempty"` // exists, equals, contains or regex; equals when expected is set, exists otherwise
+	Expected   interface{} `json:"expected,omitempty"`
+	StatusCode int         `json:"status_code,omitempty"`
+	IntervalMs int         `json:"interval_ms,omitempty"` // delay between requests, default 1000
+	TimeoutMs  int         `json:"timeout_ms,omitempty"`  // time to wait for the condition, default 20000
+}
+
+// FixtureRef references a version of a stored fixture
+type FixtureRef struct {
+	FixtureID string `json:"fixture_id"`
+	Version   int    `json:"version,omitempty"` // fixture version, 0 selects the latest
+}
+
+// DatasetRef references a fixture holding the rows of a data-driven test,
+// either a CSV file with a header row or a JSON array of objects
+type DatasetRef struct {
+	FixtureID string `json:"fixture_id"`
+	Version   int    `json:"version,omitempty"` // fixture version, 0 selects the latest
+}
+
+// VariantSpec describes one entry of a request matrix. The request is repeated
+// once per variant with the variant headers (e.g. Accept-Language, Accept)
+// merged over the request headers, and the variant assertions evaluated in
+// addition to the test-level assertions.
+type VariantSpec struct {
+	Name       string            `json:"name"`
+	Headers    map[string]string `json:"headers"`
+	Assertions []AssertionSpec   `json:"assertions,omitempty"`
 }
 
 // RequestSpec represents the HTTP request specification
@@ -124,14 +1469,86 @@ type RequestSpec struct {
 	URL     string            `json:"url"`
 	Headers map[string]string `json:"headers"`
 	Body    interface{}       `json:"body"`
+	Auth    *AuthConfig       `json:"auth,omitempty"` // overrides the service auth config; type "none" disables auth
+	Multipart []MultipartPart `json:"multipart,omitempty"`
+	BodyFixture *FixtureRef   `json:"body_fixture,omitempty"` // fixture sent as the request body, instead of Body
+	GraphQL   *GraphQLRequest `json:"graphql,omitempty"` // operation of a test with protocol "graphql"
+	WebSocket *WebSocketScript `json:"websocket,omitempty"` // conversation of a test with protocol "websocket"
+}
+
+// WebSocketScript describes the conversation of a websocket test: the
+// messages sent over the connection and the frames expected, in order
+type WebSocketScript struct {
+	Subprotocols []string        `json:"subprotocols,omitempty"`
+	Steps        []WebSocketStep `json:"steps"`
+}
+
+// WebSocketStep sends a message, waits for frames, or both in that order.
+// Strings are sent as text frames as they are, other values encoded as JSON.
+type WebSocketStep struct {
+	Send    interface{}       `json:"send,omitempty"`
+	Receive *WebSocketReceive `json:"receive,omitempty"`
+}
+
+// WebSocketReceive waits for frames and asserts on each of them. Assertion
+// paths address the JSON payload of a frame; an empty path the whole payload.
+type WebSocketReceive struct {
+	Count      int             `json:"count,omitempty"`      // frames to wait for, defaults to 1
+	None       bool            `json:"none,omitempty"`       // expect no frame within the timeout
+	TimeoutMs  int             `json:"timeout_ms,omitempty"` // defaults to 5000
+	Assertions []AssertionSpec `json:"assertions,omitempty"`
+}
+
+// GraphQLRequest describes a GraphQL operation. It is sent as the JSON body
+// {"query", "operationName", "variables"} of a POST request to the request URL.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// MultipartPart represents one part of a multipart/form-data request body.
+// A part carries either a plain form value or a reference to a stored fixture;
+// a value may carry a file name and content type of its own.
+type MultipartPart struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	FixtureID   string `json:"fixture_id,omitempty"`
+	Version     int    `json:"version,omitempty"` // fixture version, 0 selects the latest
+	FileName    string `json:"file_name,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
 }
 
 // AssertionSpec represents a single assertion to validate
 type AssertionSpec struct {
-	Type     string      `json:"type"`
-	Path     string      `json:"path,omitempty"`
-	Matcher  string      `json:"matcher,omitempty"`
-	Expected interface{} `json:"expected"`
+	Type       string             `json:"type"`
+	Path       string             `json:"path,omitempty"`
+	Matcher    string             `json:"matcher,omitempty"`
+	Expected   interface{}        `json:"expected"`
+	Namespaces map[string]string  `json:"namespaces,omitempty"` // prefixes used by xpath assertions
+	Fixture    *FixtureRef        `json:"fixture,omitempty"`    // stored example compared by semantic_diff assertions
+	Tolerance  *DiffTolerance     `json:"tolerance,omitempty"`  // leniency of semantic_diff assertions
+	Normalize  *TextNormalization `json:"normalize,omitempty"`  // cosmetic differences ignored by string comparisons
+	Message    string             `json:"message,omitempty"`    // explanation prefixed to the failure message
+}
+
+// TextNormalization makes the string comparisons of an assertion ignore
+// cosmetic differences. It applies to the actual and expected values alike.
+type TextNormalization struct {
+	Trim       bool   `json:"trim,omitempty"`       // remove leading and trailing whitespace
+	CaseFold   bool   `json:"case_fold,omitempty"`  // compare case-insensitively, whatever the locale
+	Whitespace bool   `json:"whitespace,omitempty"` // collapse runs of whitespace into a single space
+	Unicode    string `json:"unicode,omitempty"`    // Unicode normalization form: NFC, NFD, NFKC or NFKD
+}
+
+// DiffTolerance relaxes the comparison of a semantic_diff assertion. Ignored
+// paths are dotted paths into the compared value, where * matches any key
+// or array index, e.g. "meta.lastUpdated" or "entry.*.id".
+type DiffTolerance struct {
+	IgnoreOrder      bool     `json:"ignore_order,omitempty"`       // arrays match in any order
+	Numeric          float64  `json:"numeric,omitempty"`            // absolute difference allowed between numbers
+	AllowExtraFields bool     `json:"allow_extra_fields,omitempty"` // objects may have fields the example lacks
+	IgnorePaths      []string `json:"ignore_paths,omitempty"`
 }
 
 // BeforeCreate hooks for GORM
@@ -147,6 +1564,41 @@ func (s *Service) BeforeUpdate(tx *gorm.DB) error {
 	return nil
 }
 
+func (e *Environment) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (s *Schedule) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (ts *TestSuite) BeforeCreate(tx *gorm.DB) error {
+	if ts.ID == "" {
+		ts.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (f *Fixture) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return nil
+}
+
+func (fv *FixtureVersion) BeforeCreate(tx *gorm.DB) error {
+	if fv.ID == "" {
+		fv.ID = uuid.New().String()
+	}
+	return nil
+}
+
 func (tc *TestCase) BeforeCreate(tx *gorm.DB) error {
 	if tc.ID == "" {
 		tc.ID = uuid.New().String()
@@ -159,6 +1611,33 @@ func (tc *TestCase) BeforeUpdate(tx *gorm.DB) error {
 	return nil
 }
 
+// BeforeSave encrypts the secrets of the auth overrides of the spec, like
+// those of service auth configs. Updates with explicit values bypass it and
+// must encrypt the spec with EncryptTestSpec.
+func (tc *TestCase) BeforeSave(tx *gorm.DB) error {
+	spec, err := EncryptTestSpec(tc.TestSpec)
+	if err != nil {
+		return err
+	}
+	tc.TestSpec = spec
+	return nil
+}
+
+// AfterSave restores the plaintext spec of a saved test case
+func (tc *TestCase) AfterSave(tx *gorm.DB) error {
+	return tc.AfterFind(tx)
+}
+
+// AfterFind decrypts the secrets of the auth overrides of the spec
+func (tc *TestCase) AfterFind(tx *gorm.DB) error {
+	spec, err := DecryptTestSpec(tc.TestSpec)
+	if err != nil {
+		return err
+	}
+	tc.TestSpec = spec
+	return nil
+}
+
 func (tr *TestRun) BeforeCreate(tx *gorm.DB) error {
 	if tr.ID == "" {
 		tr.ID = uuid.New().String()
@@ -172,3 +1651,10 @@ func (tr *TestResult) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+func (ar *AssertionResult) BeforeCreate(tx *gorm.DB) error {
+	if ar.ID == "" {
+		ar.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+
+	"api-test-framework/internal/models"
+)
+
+// RunSummary describes a finished test run as reported to a notification channel
+type RunSummary struct {
+	RunID       string
+	RunName     string
+	ServiceName string
+	Status      string
+	Passed      int
+	Failed      int
+	Skipped     int
+	TimedOut    int
+	DurationMs  int64
+	FailedTests []FailedTest
+	ReportURL   string // link to the run report, empty when no public URL is configured
+}
+
+// FailedTest is a failed test case of a run
+type FailedTest struct {
+	Name         string
+	APIVersion   string
+	FailureType  string
+	ErrorMessage string
+}
+
+// Channel delivers run summaries to an external system
+type Channel interface {
+	Name() string
+	Notify(ctx context.Context, summary RunSummary) error
+}
+
+// Alert is a notice about a service outside of test runs, e.g. an expiring
+// certificate
+type Alert struct {
+	ServiceName string
+	Title       string
+	Details     []string
+}
+
+// Alerter is a Channel that also delivers alerts
+type Alerter interface {
+	Alert(ctx context.Context, alert Alert) error
+}
+
+// ForService returns the channels configured for a service. Invalid channel
+// configurations are returned as errors and do not prevent the others.
+func ForService(config models.NotificationConfig) ([]Channel, []error) {
+	var channels []Channel
+	var errs []error
+
+	if config.Slack != nil {
+		slack, err := NewSlack(*config.Slack)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("slack: %v", err))
+		} else {
+			channels = append(channels, slack)
+		}
+	}
+	return channels, errs
+}
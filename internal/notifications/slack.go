@@ -0,0 +1,180 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// slackPostMessageURL is the Web API method used with bot tokens
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// maxListedFailures caps the failed tests listed in a single message
+const maxListedFailures = 15
+
+// Slack posts run summaries to a Slack channel
+type Slack struct {
+	config     models.SlackNotification
+	httpClient *http.Client
+}
+
+// NewSlack creates a Slack channel from an incoming webhook URL, or from a
+// bot token and channel
+func NewSlack(config models.SlackNotification) (*Slack, error) {
+	if config.WebhookURL == "" && (config.BotToken == "" || config.Channel == "") {
+		return nil, fmt.Errorf("webhook_url or bot_token and channel are required")
+	}
+	return &Slack{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// Name implements Channel interface
+func (s *Slack) Name() string {
+	return "slack"
+}
+
+// Notify implements Channel interface
+func (s *Slack) Notify(ctx context.Context, summary RunSummary) error {
+	return s.post(ctx, slackMessage(summary, s.config.Mention))
+}
+
+// Alert implements Alerter interface
+func (s *Slack) Alert(ctx context.Context, alert Alert) error {
+	return s.post(ctx, slackAlertMessage(alert, s.config.Mention))
+}
+
+// post sends a message to the webhook or channel
+func (s *Slack) post(ctx context.Context, text string) error {
+	payload := map[string]interface{}{
+		"text": text,
+	}
+	url := s.config.WebhookURL
+	if url == "" {
+		url = slackPostMessageURL
+		payload["channel"] = s.config.Channel
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode slack message: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build slack request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if s.config.WebhookURL == "" {
+		req.Header.Set("Authorization", "Bearer "+s.config.BotToken)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	// The Web API reports errors in the body of a 200 response
+	if s.config.WebhookURL == "" {
+		var result struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(respBody, &result); err != nil {
+			return fmt.Errorf("failed to decode slack response: %v", err)
+		}
+		if !result.OK {
+			return fmt.Errorf("slack rejected the message: %s", result.Error)
+		}
+	}
+	return nil
+}
+
+// slackMessage formats a run summary as Slack mrkdwn
+func slackMessage(summary RunSummary, mention string) string {
+	var b strings.Builder
+	if mention != "" {
+		b.WriteString(mention + " ")
+	}
+
+	name := slackEscape(summary.RunName)
+	if name == "" {
+		name = summary.RunID
+	}
+	if summary.ReportURL != "" {
+		name = fmt.Sprintf("<%s|%s>", summary.ReportURL, name)
+	}
+	fmt.Fprintf(&b, ":x: Test run *%s* %s", name, summary.Status)
+	if summary.ServiceName != "" {
+		fmt.Fprintf(&b, " for service *%s*", slackEscape(summary.ServiceName))
+	}
+	fmt.Fprintf(&b, "\n%d passed, %d failed, %d skipped", summary.Passed, summary.Failed, summary.Skipped)
+	if summary.TimedOut > 0 {
+		fmt.Fprintf(&b, ", %d timed out", summary.TimedOut)
+	}
+	fmt.Fprintf(&b, " in %.1fs\n", float64(summary.DurationMs)/1000)
+
+	for i, test := range summary.FailedTests {
+		if i == maxListedFailures {
+			fmt.Fprintf(&b, "…and %d more\n", len(summary.FailedTests)-maxListedFailures)
+			break
+		}
+		b.WriteString("• " + slackEscape(test.Name))
+		if test.APIVersion != "" {
+			fmt.Fprintf(&b, " (%s)", slackEscape(test.APIVersion))
+		}
+		if test.FailureType != "" {
+			fmt.Fprintf(&b, " `%s`", test.FailureType)
+		}
+		if test.ErrorMessage != "" {
+			b.WriteString(": " + slackEscape(truncate(test.ErrorMessage, 200)))
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// slackAlertMessage formats an alert as Slack mrkdwn
+func slackAlertMessage(alert Alert, mention string) string {
+	var b strings.Builder
+	if mention != "" {
+		b.WriteString(mention + " ")
+	}
+	fmt.Fprintf(&b, ":warning: %s", slackEscape(alert.Title))
+	if alert.ServiceName != "" {
+		fmt.Fprintf(&b, " for service *%s*", slackEscape(alert.ServiceName))
+	}
+	for _, detail := range alert.Details {
+		b.WriteString("\n• " + slackEscape(detail))
+	}
+	return b.String()
+}
+
+// slackEscape escapes the control characters of Slack mrkdwn
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// truncate shortens text to at most limit runes
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
@@ -0,0 +1,152 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// KMSKey is a key encryption key in AWS KMS. Data keys are encrypted and
+// decrypted by KMS, so the key itself never leaves it.
+type KMSKey struct {
+	keyID      string
+	region     string
+	endpoint   *neturl.URL
+	accessKey  string
+	secretKey  string
+	httpClient *http.Client
+}
+
+// NewKMSKey creates a key encryption key for a KMS key ID, ARN or alias.
+// endpoint defaults to the KMS endpoint of the region.
+func NewKMSKey(keyID, region, endpoint, accessKey, secretKey string) (*KMSKey, error) {
+	if keyID == "" {
+		return nil, fmt.Errorf("a KMS key ID is required")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://kms.%s.amazonaws.com", region)
+	}
+	parsed, err := neturl.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid KMS endpoint %q", endpoint)
+	}
+	return &KMSKey{
+		keyID:      keyID,
+		region:     region,
+		endpoint:   parsed,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		httpClient: &http.Client{Timeout: keyTimeout},
+	}, nil
+}
+
+// WrapKey implements KeyWrapper interface
+func (k *KMSKey) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var result struct {
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+	if err := k.call(ctx, "Encrypt", map[string]interface{}{"KeyId": k.keyID, "Plaintext": dataKey}, &result); err != nil {
+		return nil, err
+	}
+	return result.CiphertextBlob, nil
+}
+
+// UnwrapKey implements KeyWrapper interface
+func (k *KMSKey) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var result struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+	if err := k.call(ctx, "Decrypt", map[string]interface{}{"KeyId": k.keyID, "CiphertextBlob": wrapped}, &result); err != nil {
+		return nil, err
+	}
+	return result.Plaintext, nil
+}
+
+// call invokes a KMS action. Byte slices are base64-encoded by encoding/json
+// as KMS expects.
+func (k *KMSKey) call(ctx context.Context, action string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.endpoint.String()+"/", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build KMS request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService."+action)
+	k.sign(req, body, time.Now().UTC())
+
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("KMS request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("KMS %s returned status %d: %s", action, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if err := json.Unmarshal(respBody, output); err != nil {
+		return fmt.Errorf("invalid KMS %s response: %v", action, err)
+	}
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 authorization of a KMS request
+func (k *KMSKey) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	host := k.endpoint.Host
+
+	req.Host = host
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := "content-type;host;x-amz-date;x-amz-target"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		"",
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + host,
+		"x-amz-date:" + amzDate,
+		"x-amz-target:" + req.Header.Get("X-Amz-Target"),
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/kms/aws4_request", date, k.region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+k.secretKey), date)
+	key = hmacSHA256(key, k.region)
+	key = hmacSHA256(key, "kms")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", k.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,176 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnresolved is returned for secret references that cannot be resolved,
+// e.g. unset environment variables or missing Vault keys
+var ErrUnresolved = errors.New("failed to resolve secret reference")
+
+// Schemes of secret references
+const (
+	envScheme   = "env:"
+	vaultScheme = "vault:"
+)
+
+// IsReference reports whether a value references an external secret:
+// "env:NAME" for an environment variable of the process executing the test,
+// or "vault:mount/path#key" for a key of a Vault KV secret
+func IsReference(value string) bool {
+	return (strings.HasPrefix(value, envScheme) && len(value) > len(envScheme)) ||
+		(strings.HasPrefix(value, vaultScheme) && strings.Contains(value, "#"))
+}
+
+// VaultConfig configures the Vault server secret references are read from
+type VaultConfig struct {
+	Address   string // e.g. https://vault.internal:8200, empty disables vault references
+	Token     string
+	Namespace string        // Vault Enterprise namespace
+	CacheTTL  time.Duration // how long secrets read from Vault are reused
+}
+
+// Resolver resolves secret references at execution time. Vault secrets are
+// cached for the configured TTL, so a run does not read a secret per test.
+type Resolver struct {
+	vault      VaultConfig
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	cache map[string]cachedSecret // Vault secrets by mount and path
+}
+
+// cachedSecret is the data of a Vault secret with the time it was read
+type cachedSecret struct {
+	data   map[string]interface{}
+	readAt time.Time
+}
+
+// NewResolver creates a resolver reading vault references from a Vault
+// server; without an address only env references resolve
+func NewResolver(vault VaultConfig) *Resolver {
+	return &Resolver{
+		vault:      vault,
+		httpClient: &http.Client{Timeout: keyTimeout},
+		cache:      map[string]cachedSecret{},
+	}
+}
+
+// Resolve returns the value of a secret reference
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, envScheme):
+		name := strings.TrimPrefix(ref, envScheme)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("%w %s: environment variable %s is not set", ErrUnresolved, ref, name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, vaultScheme):
+		return r.resolveVault(ctx, ref)
+	}
+	return "", fmt.Errorf("%w %s: unknown scheme, expected env: or vault:", ErrUnresolved, ref)
+}
+
+// resolveVault returns a key of a Vault KV secret, "vault:mount/path#key".
+// KV version 2 is tried first, then version 1.
+func (r *Resolver) resolveVault(ctx context.Context, ref string) (string, error) {
+	if r.vault.Address == "" {
+		return "", fmt.Errorf("%w %s: Vault is not configured", ErrUnresolved, ref)
+	}
+	location, key, _ := strings.Cut(strings.TrimPrefix(ref, vaultScheme), "#")
+	mount, path, ok := strings.Cut(strings.Trim(location, "/"), "/")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("%w %s: expected vault:mount/path#key", ErrUnresolved, ref)
+	}
+
+	data, err := r.readVault(ctx, mount, path)
+	if err != nil {
+		return "", fmt.Errorf("%w %s: %v", ErrUnresolved, ref, err)
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("%w %s: the secret has no key %s", ErrUnresolved, ref, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded), nil
+}
+
+// readVault returns the data of a Vault KV secret, from the cache while it
+// is fresh
+func (r *Resolver) readVault(ctx context.Context, mount, path string) (map[string]interface{}, error) {
+	location := mount + "/" + path
+	r.mu.Lock()
+	cached, ok := r.cache[location]
+	r.mu.Unlock()
+	if ok && time.Since(cached.readAt) < r.vault.CacheTTL {
+		return cached.data, nil
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	found, err := r.getVault(ctx, mount+"/data/"+path, &body)
+	if err != nil {
+		return nil, err
+	}
+	data, _ := body.Data["data"].(map[string]interface{})
+	if !found || data == nil {
+		// KV version 1 keeps the keys directly in data
+		body.Data = nil
+		if found, err = r.getVault(ctx, location, &body); err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("secret %s not found", location)
+		}
+		data = body.Data
+	}
+
+	r.mu.Lock()
+	r.cache[location] = cachedSecret{data: data, readAt: time.Now()}
+	r.mu.Unlock()
+	return data, nil
+}
+
+// getVault reads a path of the Vault HTTP API, reporting false when it does
+// not exist
+func (r *Resolver) getVault(ctx context.Context, path string, output interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(r.vault.Address, "/")+"/v1/"+path, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("X-Vault-Token", r.vault.Token)
+	if r.vault.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.vault.Namespace)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("Vault request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("Vault returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, output); err != nil {
+		return false, fmt.Errorf("invalid Vault response: %v", err)
+	}
+	return true, nil
+}
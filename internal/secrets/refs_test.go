@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsReference(t *testing.T) {
+	cases := map[string]bool{
+		"env:BILLING_TOKEN":       true,
+		"vault:kv/billing#token":  true,
+		"env:":                    false,
+		"vault:kv/billing":        false,
+		"Bearer env:BILLING":      false,
+		"environment:BILLING_KEY": false,
+	}
+	for value, want := range cases {
+		if got := IsReference(value); got != want {
+			t.Errorf("IsReference(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestResolver(t *testing.T) {
+	t.Setenv("BILLING_TOKEN", "env-token")
+	reads := 0
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		reads++
+		switch r.URL.Path {
+		case "/v1/kv/data/billing":
+			w.Write([]byte(`{"data": {"data": {"token": "vault-token", "port": 8443}, "metadata": {"version": 3}}}`))
+		case "/v1/legacy/billing":
+			w.Write([]byte(`{"data": {"password": "kv1-password"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer vault.Close()
+	resolver := NewResolver(VaultConfig{Address: vault.URL, Token: "root", CacheTTL: time.Minute})
+	ctx := context.Background()
+
+	resolved := map[string]string{
+		"env:BILLING_TOKEN":             "env-token",
+		"vault:kv/billing#token":        "vault-token",
+		"vault:kv/billing#port":         "8443",
+		"vault:legacy/billing#password": "kv1-password",
+	}
+	for ref, want := range resolved {
+		got, err := resolver.Resolve(ctx, ref)
+		if err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+	// kv/billing once, legacy/billing after the KV version 2 path was not found
+	if reads != 3 {
+		t.Errorf("Vault was read %d times, want 3", reads)
+	}
+
+	unresolved := []string{"env:UNSET_BILLING_TOKEN", "vault:kv/billing#missing", "vault:kv/unknown#token", "vault:kv#token"}
+	for _, ref := range unresolved {
+		if _, err := resolver.Resolve(ctx, ref); !errors.Is(err, ErrUnresolved) {
+			t.Errorf("Resolve(%q) error = %v, want ErrUnresolved", ref, err)
+		}
+	}
+	if _, err := NewResolver(VaultConfig{}).Resolve(ctx, "vault:kv/billing#token"); !errors.Is(err, ErrUnresolved) {
+		t.Errorf("Resolve without Vault error = %v, want ErrUnresolved", err)
+	}
+}
@@ -0,0 +1,209 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDecrypt is returned for encrypted values that cannot be decrypted, e.g.
+// because they were encrypted with another key
+var ErrDecrypt = errors.New("failed to decrypt secret")
+
+// encryptedPrefix starts every encrypted value, so plaintext values stored
+// before encryption was enabled are recognized
+const encryptedPrefix = "enc:v1:"
+
+// keyTimeout bounds wrapping and unwrapping a data key, e.g. with KMS
+const keyTimeout = 10 * time.Second
+
+// KeyWrapper encrypts the data keys of an envelope with a key encryption
+// key, e.g. a local key or a KMS key
+type KeyWrapper interface {
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// Envelope encrypts secrets with envelope encryption: values are sealed with
+// AES-256-GCM under a data key, and the data key is stored next to them,
+// encrypted by the key encryption key. One data key is generated per process
+// and unwrapped data keys are cached, so the key encryption key, e.g. in
+// KMS, is only used once per data key.
+type Envelope struct {
+	kek KeyWrapper
+
+	mu         sync.Mutex
+	dataKey    []byte
+	wrappedKey string
+	dataKeys   map[string][]byte // unwrapped data keys by their wrapped form
+}
+
+// NewEnvelope creates an envelope encrypting data keys with kek
+func NewEnvelope(kek KeyWrapper) *Envelope {
+	return &Envelope{kek: kek, dataKeys: map[string][]byte{}}
+}
+
+// IsEncrypted reports whether a value was encrypted by an Envelope
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
+
+// Encrypt encrypts a secret into a printable value. Empty values stay empty
+// and values already encrypted are returned as they are.
+func (e *Envelope) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" || IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+	dataKey, wrappedKey, err := e.currentKey()
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + wrappedKey + ":" + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value encrypted by Encrypt. Values that are not
+// encrypted are returned as they are.
+func (e *Envelope) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	wrappedKey, sealedText, ok := strings.Cut(strings.TrimPrefix(value, encryptedPrefix), ":")
+	if !ok {
+		return "", fmt.Errorf("%w: malformed value", ErrDecrypt)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(sealedText)
+	if err != nil {
+		return "", fmt.Errorf("%w: malformed value", ErrDecrypt)
+	}
+	dataKey, err := e.unwrapKey(wrappedKey)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("%w: malformed value", ErrDecrypt)
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecrypt, err)
+	}
+	return string(plaintext), nil
+}
+
+// currentKey returns the data key of this process, generating and wrapping
+// it on first use
+func (e *Envelope) currentKey() ([]byte, string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.dataKey != nil {
+		return e.dataKey, e.wrappedKey, nil
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), keyTimeout)
+	defer cancel()
+	wrapped, err := e.kek.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to wrap data key: %v", err)
+	}
+	e.dataKey = dataKey
+	e.wrappedKey = base64.RawURLEncoding.EncodeToString(wrapped)
+	e.dataKeys[e.wrappedKey] = dataKey
+	return e.dataKey, e.wrappedKey, nil
+}
+
+// unwrapKey returns the data key of a wrapped key, unwrapping it once
+func (e *Envelope) unwrapKey(wrappedKey string) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if dataKey, ok := e.dataKeys[wrappedKey]; ok {
+		return dataKey, nil
+	}
+
+	wrapped, err := base64.RawURLEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed data key", ErrDecrypt)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), keyTimeout)
+	defer cancel()
+	dataKey, err := e.kek.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to unwrap data key: %v", ErrDecrypt, err)
+	}
+	e.dataKeys[wrappedKey] = dataKey
+	return dataKey, nil
+}
+
+// newGCM creates an AES-GCM cipher with a 256-bit key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// LocalKey is a key encryption key held in the configuration
+type LocalKey struct {
+	key []byte
+}
+
+// NewLocalKey creates a key encryption key from 32 base64-encoded bytes,
+// e.g. generated with `openssl rand -base64 32`
+func NewLocalKey(encoded string) (*LocalKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %v", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	return &LocalKey{key: key}, nil
+}
+
+// WrapKey implements KeyWrapper interface
+func (k *LocalKey) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	aead, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, nil), nil
+}
+
+// UnwrapKey implements KeyWrapper interface
+func (k *LocalKey) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	aead, err := newGCM(k.key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], nil)
+}
@@ -0,0 +1,118 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // 32 bytes
+
+func TestEnvelope(t *testing.T) {
+	kek, err := NewLocalKey(testKey)
+	if err != nil {
+		t.Fatalf("NewLocalKey failed: %v", err)
+	}
+	envelope := NewEnvelope(kek)
+
+	encrypted, err := envelope.Encrypt("s3cr3t")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if !IsEncrypted(encrypted) || strings.Contains(encrypted, "s3cr3t") {
+		t.Fatalf("value is not encrypted: %s", encrypted)
+	}
+	again, _ := envelope.Encrypt("s3cr3t")
+	if again == encrypted {
+		t.Errorf("encrypting twice gave the same value")
+	}
+	if twice, _ := envelope.Encrypt(encrypted); twice != encrypted {
+		t.Errorf("encrypted values are not encrypted again, got %s", twice)
+	}
+
+	// Another process with the same key decrypts the values
+	decrypted, err := NewEnvelope(kek).Decrypt(encrypted)
+	if err != nil || decrypted != "s3cr3t" {
+		t.Errorf("Decrypt = %q, %v", decrypted, err)
+	}
+	if plain, err := envelope.Decrypt("plaintext"); err != nil || plain != "plaintext" {
+		t.Errorf("plaintext values pass through, got %q, %v", plain, err)
+	}
+	if empty, err := envelope.Encrypt(""); err != nil || empty != "" {
+		t.Errorf("empty values stay empty, got %q, %v", empty, err)
+	}
+
+	otherKey, _ := NewLocalKey(base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210")))
+	if _, err := NewEnvelope(otherKey).Decrypt(encrypted); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt with another key, got %v", err)
+	}
+	tampered := encrypted[:len(encrypted)-2] + "AA"
+	if _, err := envelope.Decrypt(tampered); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("expected ErrDecrypt for a tampered value, got %v", err)
+	}
+
+	for _, key := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := NewLocalKey(key); err == nil {
+			t.Errorf("expected an error for key %q", key)
+		}
+	}
+}
+
+func TestKMSKey(t *testing.T) {
+	// The fake KMS wraps keys by reversing them
+	calls := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/kms/aws4_request") {
+			t.Errorf("unexpected authorization: %s", r.Header.Get("Authorization"))
+		}
+		var input struct {
+			KeyId          string
+			Plaintext      []byte
+			CiphertextBlob []byte
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+		if input.KeyId != "alias/test" {
+			t.Errorf("key id = %s", input.KeyId)
+		}
+		reverse := func(b []byte) []byte {
+			out := make([]byte, len(b))
+			for i := range b {
+				out[len(b)-1-i] = b[i]
+			}
+			return out
+		}
+		action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "TrentService.")
+		calls[action]++
+		switch action {
+		case "Encrypt":
+			json.NewEncoder(w).Encode(map[string][]byte{"CiphertextBlob": reverse(input.Plaintext)})
+		case "Decrypt":
+			json.NewEncoder(w).Encode(map[string][]byte{"Plaintext": reverse(input.CiphertextBlob)})
+		default:
+			http.Error(w, `{"__type":"UnknownOperationException"}`, http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	kek, err := NewKMSKey("alias/test", "eu-west-1", server.URL, "AKID", "secret")
+	if err != nil {
+		t.Fatalf("NewKMSKey failed: %v", err)
+	}
+	first, err := NewEnvelope(kek).Encrypt("token-1")
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	envelope := NewEnvelope(kek)
+	for i := 0; i < 3; i++ {
+		if decrypted, err := envelope.Decrypt(first); err != nil || decrypted != "token-1" {
+			t.Fatalf("Decrypt = %q, %v", decrypted, err)
+		}
+	}
+	if calls["Encrypt"] != 1 || calls["Decrypt"] != 1 {
+		t.Errorf("data keys are wrapped and unwrapped once, got %v", calls)
+	}
+}
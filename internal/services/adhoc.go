@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// ErrMissingBaseURL is returned when an ad-hoc request has no target to resolve relative URLs against
+var ErrMissingBaseURL = errors.New("base_url or service_id is required for relative URLs")
+
+// AdHocTarget selects what an unsaved request runs against. With a service
+// the service base URL, variables, auth and API versioning apply exactly as
+// in a test run; base_url and variables override them.
+type AdHocTarget struct {
+	ServiceID     string            `json:"service_id"`
+	EnvironmentID string            `json:"environment_id"`
+	BaseURL       string            `json:"base_url"`
+	APIVersion    string            `json:"api_version"`
+	Variables     map[string]string `json:"variables"`
+}
+
+// AdHocRequest describes a one-off request executed without a stored test case
+type AdHocRequest struct {
+	AdHocTarget
+	Name       string                 `json:"name"`
+	Protocol   string                 `json:"protocol"`
+	Request    models.RequestSpec     `json:"request"`
+	Assertions []models.AssertionSpec `json:"assertions"`
+	Variants   []models.VariantSpec   `json:"variants"`
+}
+
+// TestSpecRequest describes a complete test spec executed without saving
+// it, e.g. while editing it. DataRow selects the data row the spec runs
+// with, the first by default.
+type TestSpecRequest struct {
+	AdHocTarget
+	DataRow int `json:"data_row"`
+	models.TestSpec
+}
+
+// ExecuteAdHoc runs a one-off request and its assertions with the same
+// executor as test runs. Nothing is persisted.
+func (s *TestRunService) ExecuteAdHoc(ctx context.Context, request AdHocRequest) (*testrunner.TestResult, error) {
+	testSpec := models.TestSpec{
+		Name:       request.Name,
+		Request:    request.Request,
+		Assertions: request.Assertions,
+		Variants:   request.Variants,
+		Protocol:   request.Protocol,
+	}
+	return s.executeUnsaved(ctx, request.AdHocTarget, &testSpec)
+}
+
+// ExecuteTestSpec runs a test spec as a run would execute it, including its
+// retry policy, poll condition and timeout, without saving the spec or its
+// result.
+func (s *TestRunService) ExecuteTestSpec(ctx context.Context, request TestSpecRequest) (*testrunner.TestResult, error) {
+	testSpec := request.TestSpec
+	if err := s.applyDataRow(&testSpec, request.DataRow); err != nil {
+		return nil, err
+	}
+	return s.executeUnsaved(ctx, request.AdHocTarget, &testSpec)
+}
+
+// applyDataRow applies a data row of a spec with data rows, the first when
+// row is 0
+func (s *TestRunService) applyDataRow(testSpec *models.TestSpec, row int) error {
+	rows, err := testrunner.DataRows(testSpec, s.fixtures)
+	if err != nil {
+		return err
+	}
+	if row < 0 || row > len(rows) {
+		return fmt.Errorf("data_row %d is out of range, the spec has %d data rows", row, len(rows))
+	}
+	if len(rows) > 0 {
+		testrunner.ApplyDataRow(testSpec, rows[max(row, 1)-1])
+	}
+	return nil
+}
+
+// executeUnsaved resolves the target of an unsaved test spec and executes it
+func (s *TestRunService) executeUnsaved(ctx context.Context, target AdHocTarget, testSpec *models.TestSpec) (*testrunner.TestResult, error) {
+	executor, service, err := s.prepareUnsaved(ctx, target, testSpec)
+	if err != nil {
+		return nil, err
+	}
+	if testSpec.Retry != nil {
+		executor = retryingExecutor{Executor: executor, policy: *testSpec.Retry}
+	}
+
+	executedSpec, resolved, err := s.resolveSpecSecrets(ctx, testSpec)
+	if err != nil {
+		return nil, err
+	}
+	result := executeSpec(ctx, executor, executedSpec, testTimeoutFor(testSpec.TimeoutMs, 0, service.TimeoutMs))
+	result.ResponseData = redactSecrets(result.ResponseData, s.withResolvedAuth(ctx, *service), executedSpec, resolved...)
+	result.ResponseData = s.redactResponse(result.ResponseData, *service)
+	return result, nil
+}
+
+// prepareUnsaved resolves the target of an unsaved test spec, substitutes
+// its variables and creates the executor for it
+func (s *TestRunService) prepareUnsaved(ctx context.Context, target AdHocTarget, testSpec *models.TestSpec) (testrunner.Executor, *models.Service, error) {
+	if testSpec.Request.Method == "" || testSpec.Request.URL == "" {
+		return nil, nil, fmt.Errorf("request method and url are required")
+	}
+	if testSpec.Name == "" {
+		testSpec.Name = testSpec.Request.Method + " " + testSpec.Request.URL
+	}
+	if testSpec.Assertions == nil {
+		testSpec.Assertions = []models.AssertionSpec{}
+	}
+
+	var service models.Service
+	var environment *models.Environment
+	if target.EnvironmentID != "" {
+		environment = &models.Environment{}
+		if err := s.db.WithContext(ctx).First(environment, "id = ?", target.EnvironmentID).Error; err != nil {
+			return nil, nil, fmt.Errorf("environment not found: %v", err)
+		}
+	}
+	if target.ServiceID != "" {
+		if err := s.db.WithContext(ctx).First(&service, "id = ?", target.ServiceID).Error; err != nil {
+			return nil, nil, fmt.Errorf("service not found: %v", err)
+		}
+	}
+
+	vars := variableValues(resolveVariables(service, environment, target.Variables))
+	if target.BaseURL != "" {
+		vars["base_url"] = target.BaseURL
+	}
+	apiVersion := target.APIVersion
+	if apiVersion == "" {
+		apiVersion = service.APIVersioning.Default
+	}
+	if apiVersion != "" {
+		vars["api_version"] = apiVersion
+	}
+	testrunner.ApplyVariables(testSpec, secretPlaceholders(vars))
+	testrunner.ApplyLatencyBudget(testSpec, service.LatencyBudgetMs, 0)
+
+	if vars["base_url"] == "" && !isAbsoluteURL(testSpec.Request.URL) {
+		return nil, nil, ErrMissingBaseURL
+	}
+
+	executor, err := s.newExecutor(ctx, testSpec.Protocol, service, vars["base_url"], apiVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	return executor, &service, nil
+}
+
+// retryingExecutor executes test specs with a retry policy
+type retryingExecutor struct {
+	testrunner.Executor
+	policy models.RetryPolicy
+}
+
+// ExecuteTest implements testrunner.Executor
+func (e retryingExecutor) ExecuteTest(ctx context.Context, testSpec *models.TestSpec) *testrunner.TestResult {
+	return testrunner.ExecuteWithRetry(ctx, e.Executor, testSpec, e.policy)
+}
+
+// AssertionCounts summarizes the assertion results of an execution, those
+// of its variants included
+type AssertionCounts struct {
+	Total  int `json:"total"`
+	Passed int `json:"passed"`
+	Failed int `json:"failed"`
+}
+
+// CountAssertions counts the assertion results of an execution
+func CountAssertions(result *testrunner.TestResult) AssertionCounts {
+	var counts AssertionCounts
+	add := func(assertions []testrunner.AssertionResult) {
+		for _, assertion := range assertions {
+			counts.Total++
+			if assertion.Passed {
+				counts.Passed++
+			} else {
+				counts.Failed++
+			}
+		}
+	}
+	add(result.AssertionResults)
+	for _, variant := range result.VariantResults {
+		add(variant.AssertionResults)
+	}
+	return counts
+}
+
+// isAbsoluteURL reports whether a request URL carries its own scheme and host
+func isAbsoluteURL(url string) bool {
+	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
+}
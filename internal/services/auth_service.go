@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// Authentication and user management errors
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrInvalidUser     = errors.New("invalid user")
+	ErrTokensDisabled  = errors.New("tokens disabled")
+	ErrTokenExchange   = errors.New("tokens are only issued for API keys")
+)
+
+// apiKeyPrefix starts every API key, so leaked keys are easy to recognize
+const apiKeyPrefix = "atf_"
+
+// Ways a principal authenticated
+const (
+	AuthMethodAPIKey   = "api_key"
+	AuthMethodJWT      = "jwt"
+	AuthMethodAdminKey = "admin_key"
+)
+
+// roleRanks orders the roles from the least to the most privileged
+var roleRanks = map[string]int{
+	models.RoleViewer: 1,
+	models.RoleEditor: 2,
+	models.RoleAdmin:  3,
+}
+
+// Principal is the authenticated caller of an API request
+type Principal struct {
+	UserID string `json:"user_id,omitempty"` // empty for the admin key of the configuration
+	Email  string `json:"email,omitempty"`
+	Role   string `json:"role"`
+	Method string `json:"method"` // api_key, jwt or admin_key
+}
+
+// HasRole reports whether the principal has role or a more privileged one
+func (p *Principal) HasRole(role string) bool {
+	return roleRanks[p.Role] >= roleRanks[role]
+}
+
+// UserUpdate holds the fields of a user to change; nil fields are kept
+type UserUpdate struct {
+	Name     *string `json:"name"`
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+// AuthService authenticates API callers and manages users and their API keys
+type AuthService struct {
+	db          *gorm.DB
+	jwtSecret   []byte
+	tokenTTL    time.Duration
+	adminAPIKey string
+}
+
+// NewAuthService creates a new auth service. Without jwtSecret no tokens are
+// issued or accepted; without adminAPIKey only API keys of users and tokens
+// authenticate.
+func NewAuthService(db *gorm.DB, jwtSecret string, tokenTTL time.Duration, adminAPIKey string) *AuthService {
+	return &AuthService{db: db, jwtSecret: []byte(jwtSecret), tokenTTL: tokenTTL, adminAPIKey: adminAPIKey}
+}
+
+// Authenticate resolves the credential of a request: the admin key of the
+// configuration, a JWT issued by IssueToken or the API key of a user.
+// Disabled users and expired keys and tokens are rejected.
+func (s *AuthService) Authenticate(ctx context.Context, credential string) (*Principal, error) {
+	if credential == "" {
+		return nil, fmt.Errorf("%w: missing API key or bearer token", ErrUnauthenticated)
+	}
+	if s.adminAPIKey != "" && subtle.ConstantTimeCompare([]byte(credential), []byte(s.adminAPIKey)) == 1 {
+		return &Principal{Role: models.RoleAdmin, Method: AuthMethodAdminKey}, nil
+	}
+
+	db := s.db.WithContext(ctx)
+	if strings.Count(credential, ".") == 2 {
+		if len(s.jwtSecret) == 0 {
+			return nil, fmt.Errorf("%w: bearer tokens are not accepted without AUTH_JWT_SECRET", ErrUnauthenticated)
+		}
+		claims, err := utils.ParseJWT(credential, s.jwtSecret, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+		}
+		var user models.User
+		if err := db.First(&user, "id = ?", claims.Subject).Error; err != nil {
+			return nil, fmt.Errorf("%w: unknown user", ErrUnauthenticated)
+		}
+		return userPrincipal(&user, AuthMethodJWT)
+	}
+
+	var key models.APIKey
+	if err := db.Preload("User").First(&key, "key_hash = ?", hashAPIKey(credential)).Error; err != nil {
+		return nil, fmt.Errorf("%w: unknown API key", ErrUnauthenticated)
+	}
+	now := time.Now()
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, fmt.Errorf("%w: API key %s expired", ErrUnauthenticated, key.Prefix)
+	}
+	if err := db.Model(&key).UpdateColumn("last_used_at", now).Error; err != nil {
+		return nil, err
+	}
+	return userPrincipal(key.User, AuthMethodAPIKey)
+}
+
+// userPrincipal returns the principal of an enabled user
+func userPrincipal(user *models.User, method string) (*Principal, error) {
+	if user == nil || user.Disabled {
+		return nil, fmt.Errorf("%w: user is disabled", ErrUnauthenticated)
+	}
+	return &Principal{UserID: user.ID, Email: user.Email, Role: user.Role, Method: method}, nil
+}
+
+// IssueToken issues a JWT for the user of a principal, valid for the
+// configured token lifetime. The role is read from the user on every
+// request, so role changes apply to tokens already issued.
+func (s *AuthService) IssueToken(principal *Principal) (string, time.Time, error) {
+	if len(s.jwtSecret) == 0 {
+		return "", time.Time{}, fmt.Errorf("%w: set AUTH_JWT_SECRET to issue tokens", ErrTokensDisabled)
+	}
+	if principal.UserID == "" {
+		return "", time.Time{}, fmt.Errorf("%w: tokens are issued to users, not to the admin key", ErrInvalidUser)
+	}
+	// A token cannot renew itself, so tokens stop being issued once the API
+	// key they derive from is revoked or expires
+	if principal.Method != AuthMethodAPIKey {
+		return "", time.Time{}, fmt.Errorf("%w: authenticate with an API key, not a %s credential", ErrTokenExchange, principal.Method)
+	}
+	now := time.Now()
+	expiresAt := now.Add(s.tokenTTL)
+	token, err := utils.SignJWT(utils.JWTClaims{
+		Subject:   principal.UserID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}, s.jwtSecret)
+	return token, expiresAt, err
+}
+
+// CreateUser validates and creates a user, a viewer unless it has a role
+func (s *AuthService) CreateUser(user *models.User) error {
+	user.Email = strings.TrimSpace(user.Email)
+	if _, err := mail.ParseAddress(user.Email); err != nil {
+		return fmt.Errorf("%w: email %q is not a valid address", ErrInvalidUser, user.Email)
+	}
+	if user.Role == "" {
+		user.Role = models.RoleViewer
+	}
+	if err := validateRole(user.Role); err != nil {
+		return err
+	}
+	return s.db.Create(user).Error
+}
+
+// GetUser retrieves a user by ID
+func (s *AuthService) GetUser(id string) (*models.User, error) {
+	var user models.User
+	if err := s.db.First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListUsers retrieves all users with pagination
+func (s *AuthService) ListUsers(limit, offset int) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	if err := s.db.Model(&models.User{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := s.db.Order("email").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// UpdateUser changes the name, role or disabled flag of a user
+func (s *AuthService) UpdateUser(id string, update UserUpdate) (*models.User, error) {
+	user, err := s.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if update.Name != nil {
+		updates["name"] = *update.Name
+	}
+	if update.Role != nil {
+		if err := validateRole(*update.Role); err != nil {
+			return nil, err
+		}
+		updates["role"] = *update.Role
+	}
+	if update.Disabled != nil {
+		updates["disabled"] = *update.Disabled
+	}
+	if len(updates) > 0 {
+		if err := s.db.Model(user).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return s.GetUser(id)
+}
+
+// DeleteUser deletes a user along with its API keys
+func (s *AuthService) DeleteUser(id string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.APIKey{}, "user_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, "id = ?", id).Error
+	})
+}
+
+// CreateAPIKey creates an API key of a user and returns the key, which
+// cannot be retrieved later
+func (s *AuthService) CreateAPIKey(userID, name string, expiresAt *time.Time) (string, *models.APIKey, error) {
+	if _, err := s.GetUser(userID); err != nil {
+		return "", nil, err
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidUser)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %v", err)
+	}
+	key := apiKeyPrefix + hex.EncodeToString(secret)
+	apiKey := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:len(apiKeyPrefix)+8],
+		KeyHash:   hashAPIKey(key),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.db.Create(apiKey).Error; err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+// ListAPIKeys retrieves the API keys of a user
+func (s *AuthService) ListAPIKeys(userID string) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := s.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// DeleteAPIKey revokes an API key of a user
+func (s *AuthService) DeleteAPIKey(userID, id string) error {
+	result := s.db.Delete(&models.APIKey{}, "id = ? AND user_id = ?", id, userID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// validateRole checks that role is one of the roles of the API
+func validateRole(role string) error {
+	if _, ok := roleRanks[role]; !ok {
+		return fmt.Errorf("%w: role must be %s, %s or %s", ErrInvalidUser, models.RoleAdmin, models.RoleEditor, models.RoleViewer)
+	}
+	return nil
+}
+
+// hashAPIKey returns the hash an API key is stored and looked up by
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
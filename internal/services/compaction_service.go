@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrResponseCompacted is returned for the response of a result whose run was compacted
+var ErrResponseCompacted = errors.New("captured response was removed when the run was compacted")
+
+// compactionBatchSize is the number of runs compacted per query
+const compactionBatchSize = 50
+
+// CompactionService reduces the stored detail of old runs. Compacting a run
+// drops the raw response payloads of its results and the actual values of
+// its passed assertions; statuses, durations, failure details and assertion
+// outcomes are kept, and the run is marked as compacted.
+type CompactionService struct {
+	db        *gorm.DB
+	after     time.Duration
+	responses ResponseStore // holds offloaded response bodies, see UseResponseStore
+	logger    *slog.Logger
+}
+
+// NewCompactionService creates a compaction service compacting runs that
+// finished more than after ago
+func NewCompactionService(db *gorm.DB, after time.Duration) *CompactionService {
+	return &CompactionService{db: db, after: after, logger: slog.Default()}
+}
+
+// SetLogger sets the logger of the service
+func (s *CompactionService) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// UseResponseStore deletes the response bodies offloaded to a store along
+// with the response payloads of the runs compacted
+func (s *CompactionService) UseResponseStore(store ResponseStore) {
+	s.responses = store
+}
+
+// Run compacts old runs every interval until ctx is done. It does nothing
+// when no age was configured.
+func (s *CompactionService) Run(ctx context.Context, interval time.Duration) {
+	if s.after <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if compacted, err := s.CompactRuns(ctx, time.Now().Add(-s.after)); err != nil {
+			s.logger.Error("run compaction failed", "error", err)
+		} else if compacted > 0 {
+			s.logger.Info("compacted test runs", "count", compacted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CompactRuns compacts the finished runs completed before the given time and
+// returns how many were compacted
+func (s *CompactionService) CompactRuns(ctx context.Context, before time.Time) (int, error) {
+	compacted := 0
+	for {
+		var runIDs []string
+		if err := s.db.WithContext(ctx).Model(&models.TestRun{}).
+			Where("status <> ? AND compacted = ? AND completed_at < ?", "running", false, before).
+			Order("completed_at").Limit(compactionBatchSize).
+			Pluck("id", &runIDs).Error; err != nil {
+			return compacted, err
+		}
+		if len(runIDs) == 0 {
+			return compacted, nil
+		}
+
+		for _, id := range runIDs {
+			if err := s.CompactRun(ctx, id); err != nil {
+				return compacted, fmt.Errorf("failed to compact run %s: %v", id, err)
+			}
+			compacted++
+		}
+	}
+}
+
+// CompactRun compacts a single finished run. Compacting a run twice has no effect.
+func (s *CompactionService) CompactRun(ctx context.Context, testRunID string) error {
+	var offloaded []string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var testRun models.TestRun
+		if err := tx.First(&testRun, "id = ?", testRunID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		update := tx.Model(&models.TestRun{}).
+			Where("id = ? AND status <> ? AND compacted = ?", testRunID, "running", false).
+			Updates(map[string]interface{}{"compacted": true, "compacted_at": now, "debug_log": models.DebugLog{}})
+		if update.Error != nil {
+			return update.Error
+		}
+		if update.RowsAffected == 0 {
+			// Still running, or already compacted
+			return nil
+		}
+
+		// Results are never older than their run, which keeps the update to
+		// the partitions of the months the run wrote to
+		results := tx.Model(&models.TestResult{}).Where("test_run_id = ? AND created_at >= ?", testRunID, testRun.StartedAt)
+		if s.responses != nil {
+			if err := results.Session(&gorm.Session{}).Where("response_data->'body_capture'->>'storage_key' IS NOT NULL").
+				Pluck("response_data->'body_capture'->>'storage_key'", &offloaded).Error; err != nil {
+				return err
+			}
+		}
+		if err := results.Session(&gorm.Session{}).
+			Update("response_data", gorm.Expr("jsonb_strip_nulls(jsonb_build_object('status_code', response_data->'status_code'))")).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&models.AssertionResult{}).
+			Where("passed = ? AND test_result_id IN (?)", true, results.Session(&gorm.Session{}).Select("id")).
+			Update("actual", gorm.Expr("NULL")).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// Objects left behind only cost storage, so failures are logged
+	for _, key := range offloaded {
+		deleteCtx, cancel := context.WithTimeout(ctx, responseStoreTimeout)
+		if err := s.responses.Delete(deleteCtx, key); err != nil {
+			s.logger.Warn("failed to delete offloaded response body", "key", key, "error", err)
+		}
+		cancel()
+	}
+	return nil
+}
+
+// isCompacted reports whether a test run was compacted
+func (s *TestRunService) isCompacted(ctx context.Context, testRunID string) bool {
+	var compacted []bool
+	s.db.WithContext(ctx).Model(&models.TestRun{}).Where("id = ?", testRunID).Pluck("compacted", &compacted)
+	return len(compacted) > 0 && compacted[0]
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"api-test-framework/internal/models"
+)
+
+// serviceNamespace prefixes the variables of the other services of a run,
+// e.g. {{services.billing.base_url}}
+const serviceNamespace = "services."
+
+// runVariables returns the variables a request against a service substitutes:
+// those resolved for the service, and those of every service of the run as
+// services.<name>.<variable>, so a request can point another service at
+// the host of the next one, e.g. with a callback URL
+func runVariables(testRun *models.TestRun, serviceID string) map[string]string {
+	vars := variableValues(testRun.ResolvedVariables[serviceID])
+	if len(testRun.Config.Services) < 2 {
+		return vars
+	}
+	values := make(map[string]string, len(vars))
+	for _, service := range testRun.Config.Services {
+		for key, variable := range testRun.ResolvedVariables[service.ID] {
+			values[serviceNamespace+service.Name+"."+key] = variable.Value
+		}
+	}
+	for key, value := range vars {
+		values[key] = value
+	}
+	return values
+}
+
+// suiteServices returns the IDs of the services a run of a suite sends
+// requests to: those of its test cases and of its steps
+func suiteServices(suite *models.TestSuite, testCases []models.TestCase) []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, testCase := range testCases {
+		if !seen[testCase.ServiceID] {
+			seen[testCase.ServiceID] = true
+			ids = append(ids, testCase.ServiceID)
+		}
+	}
+	for _, id := range suiteStepServices(suite) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// sharedBaseURLApplies reports whether an unprefixed base_url of the
+// environment or the run applies to a service of a suite run. A suite
+// spanning several services would otherwise send the requests of all of
+// them to one host, so it only applies to the suite's own service; the
+// others need a base_url prefixed with their name, e.g. billing.base_url.
+func sharedBaseURLApplies(suite *models.TestSuite, services []string, serviceID string) bool {
+	if suite == nil || len(services) < 2 {
+		return true
+	}
+	return suite.ServiceID != nil && *suite.ServiceID == serviceID
+}
+
+// withoutSharedBaseURL returns copies of the environment and run variables
+// without their unprefixed base_url
+func withoutSharedBaseURL(environment *models.Environment, overrides models.Variables) (*models.Environment, models.Variables) {
+	strip := func(vars models.Variables) models.Variables {
+		if _, ok := vars["base_url"]; !ok {
+			return vars
+		}
+		stripped := make(models.Variables, len(vars))
+		for key, value := range vars {
+			if key != "base_url" {
+				stripped[key] = value
+			}
+		}
+		return stripped
+	}
+	if environment != nil {
+		copied := *environment
+		copied.Variables = strip(environment.Variables)
+		environment = &copied
+	}
+	return environment, strip(overrides)
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+	"api-test-framework/internal/utils"
+)
+
+// CurlExport is the resolved request of a test case as a curl command.
+// Redacted lists the headers and query parameters whose credentials were
+// masked and have to be filled in before running the command.
+type CurlExport struct {
+	TestCaseID          string   `json:"test_case_id"`
+	Command             string   `json:"command"`
+	UnresolvedVariables []string `json:"unresolved_variables,omitempty"`
+	Redacted            []string `json:"redacted,omitempty"`
+}
+
+// ExportCurl renders the request a test case would send as a curl command,
+// resolved like a preview: variables substituted, authentication and API
+// versioning applied. Credentials and sensitive body fields are redacted.
+// Multipart bodies become -F options, so curl encodes them itself.
+func (s *TestRunService) ExportCurl(ctx context.Context, testCaseID string, opts PreviewRequestOptions) (*CurlExport, error) {
+	opts.TestCaseID = testCaseID
+	opts.TestSpec = nil
+	preview, testSpec, err := s.resolveRequest(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	preview = redactPreview(preview)
+
+	request := models.RequestSpec{
+		Method:  preview.Method,
+		URL:     preview.URL,
+		Headers: preview.Headers,
+		Body:    preview.Body,
+	}
+	protocol := strings.ToLower(testSpec.Protocol)
+	if (protocol == "" || protocol == testrunner.ProtocolHTTP) && len(testSpec.Request.Multipart) > 0 {
+		// The preview holds the encoded body with its boundary
+		for name := range request.Headers {
+			if strings.EqualFold(name, "Content-Type") {
+				delete(request.Headers, name)
+			}
+		}
+		request.Body = nil
+		request.Multipart = testSpec.Request.Multipart
+	}
+
+	var redacted []string
+	for name, value := range request.Headers {
+		if strings.Contains(value, redactedValue) {
+			redacted = append(redacted, name)
+		}
+	}
+	if preview.Credential != "" && strings.Contains(preview.URL, redactedValue) {
+		redacted = append(redacted, preview.Credential)
+	}
+	sort.Strings(redacted)
+
+	return &CurlExport{
+		TestCaseID:          testCaseID,
+		Command:             utils.ToCurl(request),
+		UnresolvedVariables: preview.UnresolvedVariables,
+		Redacted:            redacted,
+	}, nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoImpactedSuites is returned for an impact run of a service none of
+// whose consumers is covered by a suite
+var ErrNoImpactedSuites = errors.New("no suites cover the consumers of the service")
+
+// ServiceImpact lists the services consuming a service, directly or through
+// other services, and the suites to run when its tests fail
+type ServiceImpact struct {
+	ServiceID   string            `json:"service_id"`
+	ServiceName string            `json:"service_name"`
+	DependsOn   []ServiceRef      `json:"depends_on"` // services it consumes
+	Dependents  []ImpactedService `json:"dependents"` // by distance, then name
+	Suites      []ImpactedSuite   `json:"suites"`     // suites covering any dependent
+}
+
+// ServiceRef names a service of the dependency graph
+type ServiceRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// ImpactedService is a consumer of the service an impact is computed for
+type ImpactedService struct {
+	ServiceRef
+	Depth    int      `json:"depth"`         // 1 for direct consumers
+	Via      string   `json:"via,omitempty"` // name of the service it consumes on the way, for indirect consumers
+	SuiteIDs []string `json:"suite_ids"`
+}
+
+// ImpactedSuite is a suite covering a consumer of the service
+type ImpactedSuite struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Services []string `json:"services"` // names of the consumers it covers
+}
+
+// GetImpact walks the dependency graph from a service to every service
+// consuming it, directly or through others, and finds the suites covering
+// them: those targeting a consumer and those running its test cases
+func (s *ServiceService) GetImpact(id string) (*ServiceImpact, error) {
+	var graph []models.Service
+	if err := s.db.Select("id, name, depends_on").Find(&graph).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve services: %v", err)
+	}
+	byID := make(map[string]models.Service, len(graph))
+	consumers := map[string][]string{}
+	for _, service := range graph {
+		byID[service.ID] = service
+		for _, dependency := range service.DependsOn {
+			consumers[dependency] = append(consumers[dependency], service.ID)
+		}
+	}
+	service, ok := byID[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	impact := &ServiceImpact{ServiceID: service.ID, ServiceName: service.Name, DependsOn: []ServiceRef{}, Dependents: []ImpactedService{}, Suites: []ImpactedSuite{}}
+	for _, dependency := range service.DependsOn {
+		if upstream, ok := byID[dependency]; ok {
+			impact.DependsOn = append(impact.DependsOn, ServiceRef{ID: upstream.ID, Name: upstream.Name})
+		}
+	}
+
+	// Breadth first, so each consumer is reported at its shortest distance;
+	// cycles end at services already visited
+	visited := map[string]bool{id: true}
+	queue := []string{id}
+	for depth := 1; len(queue) > 0; depth++ {
+		var next []string
+		for _, upstream := range queue {
+			for _, consumer := range consumers[upstream] {
+				if visited[consumer] {
+					continue
+				}
+				visited[consumer] = true
+				dependent := ImpactedService{ServiceRef: ServiceRef{ID: consumer, Name: byID[consumer].Name}, Depth: depth, SuiteIDs: []string{}}
+				if upstream != id {
+					dependent.Via = byID[upstream].Name
+				}
+				impact.Dependents = append(impact.Dependents, dependent)
+				next = append(next, consumer)
+			}
+		}
+		queue = next
+	}
+	sort.SliceStable(impact.Dependents, func(i, j int) bool {
+		a, b := impact.Dependents[i], impact.Dependents[j]
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+		return a.Name < b.Name
+	})
+	index := make(map[string]int, len(impact.Dependents))
+	for i, dependent := range impact.Dependents {
+		index[dependent.ID] = i
+	}
+	if len(impact.Dependents) == 0 {
+		return impact, nil
+	}
+
+	dependentIDs := make([]string, len(impact.Dependents))
+	for i, dependent := range impact.Dependents {
+		dependentIDs[i] = dependent.ID
+	}
+	var testCases []models.TestCase
+	if err := s.db.Select("id, service_id").Where("service_id IN ?", dependentIDs).Find(&testCases).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve test cases: %v", err)
+	}
+	testService := make(map[string]string, len(testCases))
+	for _, testCase := range testCases {
+		testService[testCase.ID] = testCase.ServiceID
+	}
+
+	var suites []models.TestSuite
+	if err := s.db.Select("id, name, service_id, test_ids").Order("name").Find(&suites).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve suites: %v", err)
+	}
+	for _, suite := range suites {
+		covered := map[string]bool{}
+		if suite.ServiceID != nil {
+			if _, ok := index[*suite.ServiceID]; ok {
+				covered[*suite.ServiceID] = true
+			}
+		}
+		for _, testID := range suite.TestIDs {
+			if serviceID, ok := testService[testID]; ok {
+				covered[serviceID] = true
+			}
+		}
+		if len(covered) == 0 {
+			continue
+		}
+		impacted := ImpactedSuite{ID: suite.ID, Name: suite.Name}
+		for _, dependent := range impact.Dependents {
+			if covered[dependent.ID] {
+				impacted.Services = append(impacted.Services, dependent.Name)
+				impact.Dependents[index[dependent.ID]].SuiteIDs = append(impact.Dependents[index[dependent.ID]].SuiteIDs, suite.ID)
+			}
+		}
+		impact.Suites = append(impact.Suites, impacted)
+	}
+	return impact, nil
+}
+
+// ImpactRun reports the runs an impact run started, and the suites it could
+// not start a run of
+type ImpactRun struct {
+	Runs   []models.TestRun   `json:"runs"`
+	Failed []ImpactRunFailure `json:"failed,omitempty"`
+}
+
+// ImpactRunFailure tells why the run of a suite did not start
+type ImpactRunFailure struct {
+	SuiteID string `json:"suite_id"`
+	Name    string `json:"name"`
+	Error   string `json:"error"`
+}
+
+// RunImpact starts a run of every suite covering a consumer of a service,
+// e.g. once the tests of the service failed, with the environment and
+// options of opts. Suites whose run cannot start, e.g. because of a quota,
+// are reported as failed; the error of the first is returned when no run
+// started at all.
+func (s *SuiteService) RunImpact(ctx context.Context, serviceID string, opts StartTestRunOptions) (*ImpactRun, error) {
+	impact, err := NewServiceService(s.db).GetImpact(serviceID)
+	if err != nil {
+		return nil, err
+	}
+	if len(impact.Suites) == 0 {
+		return nil, fmt.Errorf("%w %s", ErrNoImpactedSuites, impact.ServiceName)
+	}
+
+	result := &ImpactRun{Runs: []models.TestRun{}}
+	var firstErr error
+	for _, suite := range impact.Suites {
+		suiteOpts := opts
+		suiteOpts.Name = fmt.Sprintf("Impact of %s: %s", impact.ServiceName, suite.Name)
+		testRun, err := s.RunSuite(ctx, suite.ID, suiteOpts)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to run suite %s: %w", suite.Name, err)
+			}
+			result.Failed = append(result.Failed, ImpactRunFailure{SuiteID: suite.ID, Name: suite.Name, Error: err.Error()})
+			continue
+		}
+		result.Runs = append(result.Runs, *testRun)
+	}
+	if len(result.Runs) == 0 {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+// validateDependencies checks that a service depends on existing services
+// other than itself, each listed once
+func validateDependencies(db *gorm.DB, id string, dependsOn models.StringList) error {
+	seen := make(map[string]bool, len(dependsOn))
+	for _, dependency := range dependsOn {
+		if dependency == id && id != "" {
+			return fmt.Errorf("%w: a service cannot depend on itself", ErrInvalidService)
+		}
+		if seen[dependency] {
+			return fmt.Errorf("%w: dependency %s is listed twice", ErrInvalidService, dependency)
+		}
+		seen[dependency] = true
+	}
+	if len(dependsOn) == 0 {
+		return nil
+	}
+	var found int64
+	if err := db.Model(&models.Service{}).Where("id IN ?", []string(dependsOn)).Count(&found).Error; err != nil {
+		return err
+	}
+	if int(found) != len(dependsOn) {
+		return fmt.Errorf("%w: %d of the services in depends_on do not exist", ErrInvalidService, len(dependsOn)-int(found))
+	}
+	return nil
+}
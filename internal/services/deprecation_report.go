@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// DeprecatedEndpoint reports an endpoint that announced its deprecation during a run
+type DeprecatedEndpoint struct {
+	TestResultID string `json:"test_result_id"`
+	TestCaseID   string `json:"test_case_id"`
+	TestName     string `json:"test_name"`
+	ServiceID    string `json:"service_id"`
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	APIVersion   string `json:"api_version,omitempty"`
+	Variant      string `json:"variant,omitempty"`
+	testrunner.DeprecationNotice
+}
+
+// GetDeprecationReport lists the endpoints whose responses carried Deprecation
+// or Sunset headers during a test run, soonest sunset first
+func (s *TestRunService) GetDeprecationReport(ctx context.Context, testRunID string) ([]DeprecatedEndpoint, error) {
+	var testResults []models.TestResult
+	if err := s.reader.WithContext(ctx).Preload("TestCase").Where("test_run_id = ?", testRunID).Order("position").Find(&testResults).Error; err != nil {
+		return nil, err
+	}
+
+	report := []DeprecatedEndpoint{}
+	for _, testResult := range testResults {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(testResult.ResponseData), &data); err != nil {
+			continue
+		}
+
+		var testSpec models.TestSpec
+		json.Unmarshal([]byte(testResult.TestCase.TestSpec), &testSpec)
+
+		endpoint := DeprecatedEndpoint{
+			TestResultID: testResult.ID,
+			TestCaseID:   testResult.TestCaseID,
+			TestName:     testResult.TestCase.Name,
+			ServiceID:    testResult.TestCase.ServiceID,
+			Method:       testSpec.Request.Method,
+			URL:          testSpec.Request.URL,
+			APIVersion:   testResult.APIVersion,
+		}
+
+		if notice := deprecationNotice(data); notice != nil {
+			endpoint.DeprecationNotice = *notice
+			report = append(report, endpoint)
+		}
+
+		variants, _ := data["variants"].([]interface{})
+		for _, variant := range variants {
+			variantData, _ := variant.(map[string]interface{})
+			response, _ := variantData["response_data"].(map[string]interface{})
+			if notice := deprecationNotice(response); notice != nil {
+				variantEndpoint := endpoint
+				variantEndpoint.Variant, _ = variantData["name"].(string)
+				variantEndpoint.DeprecationNotice = *notice
+				report = append(report, variantEndpoint)
+			}
+		}
+	}
+
+	// Endpoints with a known sunset come first, soonest first
+	sort.SliceStable(report, func(i, j int) bool {
+		a, b := report[i].SunsetAt, report[j].SunsetAt
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		return a.Before(*b)
+	})
+
+	return report, nil
+}
+
+// deprecationNotice detects a deprecation in captured response data
+func deprecationNotice(data map[string]interface{}) *testrunner.DeprecationNotice {
+	headers, ok := data["headers"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	header := http.Header{}
+	for name, values := range headers {
+		list, _ := values.([]interface{})
+		for _, value := range list {
+			if v, ok := value.(string); ok {
+				header.Add(name, v)
+			}
+		}
+	}
+	return testrunner.DetectDeprecation(header)
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// diagnoseTimeout bounds connectivity diagnostics, all of their steps included
+const diagnoseTimeout = 45 * time.Second
+
+// DiagnoseRequest selects the target of connectivity diagnostics. With a
+// service its base URL is resolved as in a test run, discovery included,
+// and URL is relative to it; without one URL must be absolute.
+type DiagnoseRequest struct {
+	ServiceID     string            `json:"service_id"`
+	EnvironmentID string            `json:"environment_id"`
+	Variables     map[string]string `json:"variables"`
+	URL           string            `json:"url"`         // default the base URL of the service
+	Region        string            `json:"region"`      // region to diagnose from, default the region of the service
+	EgressPool    string            `json:"egress_pool"` // egress pool to diagnose from instead of a region
+}
+
+// Diagnose checks the connectivity to a service, or a URL, from the worker
+// of the region its tests execute from, or of an egress pool: DNS
+// resolution, TCP connection, TLS handshake and a HEAD request, with the
+// timing and error of each step.
+func (s *TestRunService) Diagnose(ctx context.Context, request DiagnoseRequest) (*testrunner.Diagnosis, error) {
+	db := s.db.WithContext(ctx)
+	var service models.Service
+	var environment *models.Environment
+	if request.EnvironmentID != "" {
+		environment = &models.Environment{}
+		if err := db.First(environment, "id = ?", request.EnvironmentID).Error; err != nil {
+			return nil, fmt.Errorf("environment not found: %w", err)
+		}
+	}
+	if request.ServiceID != "" {
+		if err := db.First(&service, "id = ?", request.ServiceID).Error; err != nil {
+			return nil, fmt.Errorf("service not found: %w", err)
+		}
+	}
+
+	target := request.URL
+	if !isAbsoluteURL(target) && !strings.HasPrefix(target, "ws://") && !strings.HasPrefix(target, "wss://") {
+		baseURL := variableValues(s.resolveServiceVariables(ctx, service, environment, request.Variables))["base_url"]
+		if baseURL == "" {
+			return nil, ErrMissingBaseURL
+		}
+		if target != "" {
+			baseURL = strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(target, "/")
+		}
+		target = baseURL
+	}
+
+	// An egress pool or an explicit region must have a worker; the region of
+	// the service falls back to a region nearby as for its tests
+	region, local := s.region, request.EgressPool == s.pool
+	if request.EgressPool != "" {
+		if err := s.routePool(ctx, request.EgressPool); err != nil {
+			return nil, err
+		}
+	} else {
+		targetRegion, exact := service.Region, false
+		if request.Region != "" {
+			targetRegion, exact = request.Region, true
+		}
+		var err error
+		if region, err = s.routeRegion(ctx, targetRegion, exact); err != nil {
+			return nil, err
+		}
+		local = region == s.region
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, diagnoseTimeout)
+	defer cancel()
+	if local {
+		diagnosis := testrunner.Diagnose(ctx, target, service.TLS)
+		diagnosis.Region = s.region
+		diagnosis.Pool = s.pool
+		return diagnosis, nil
+	}
+
+	payload, err := s.dispatchRegionJob(ctx, region, regionJob{
+		Pool:      request.EgressPool,
+		ServiceID: service.ID,
+		Diagnose:  &diagnoseJob{URL: target, TLS: service.TLS},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var diagnosis testrunner.Diagnosis
+	if err := json.Unmarshal(payload, &diagnosis); err != nil {
+		return nil, fmt.Errorf("invalid diagnosis: %v", err)
+	}
+	return &diagnosis, nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// EnvironmentService handles environment operations
+type EnvironmentService struct {
+	db *gorm.DB
+}
+
+// NewEnvironmentService creates a new environment service
+func NewEnvironmentService(db *gorm.DB) *EnvironmentService {
+	return &EnvironmentService{db: db}
+}
+
+// CreateEnvironment creates a new environment
+func (s *EnvironmentService) CreateEnvironment(environment *models.Environment) error {
+	return s.db.Create(environment).Error
+}
+
+// GetEnvironment retrieves an environment by ID
+func (s *EnvironmentService) GetEnvironment(id string) (*models.Environment, error) {
+	var environment models.Environment
+	if err := s.db.First(&environment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &environment, nil
+}
+
+// ListEnvironments retrieves the environments of a project, or all
+// environments without projectID, with pagination
+func (s *EnvironmentService) ListEnvironments(projectID string, limit, offset int) ([]models.Environment, int64, error) {
+	var environments []models.Environment
+	var total int64
+
+	query := inProject(s.db.Model(&models.Environment{}), projectID)
+
+	// Get total count
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results
+	if err := query.Order("name").Limit(limit).Offset(offset).Find(&environments).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return environments, total, nil
+}
+
+// UpdateEnvironment updates an existing environment and returns the updated environment
+func (s *EnvironmentService) UpdateEnvironment(id string, environment *models.Environment) (*models.Environment, error) {
+	var existingEnvironment models.Environment
+	if err := s.db.First(&existingEnvironment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&existingEnvironment).Updates(environment).Error; err != nil {
+		return nil, err
+	}
+
+	var updatedEnvironment models.Environment
+	if err := s.db.First(&updatedEnvironment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+
+	return &updatedEnvironment, nil
+}
+
+// DeleteEnvironment deletes an environment
+func (s *EnvironmentService) DeleteEnvironment(id string) error {
+	return s.db.Delete(&models.Environment{}, "id = ?", id).Error
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidFixture is returned when an upload is empty, too large or fails checksum verification
+var ErrInvalidFixture = errors.New("invalid fixture")
+
+// FixtureService handles fixture uploads, versioning and retrieval
+type FixtureService struct {
+	db           *gorm.DB
+	maxSizeBytes int64
+	store        FixtureStore // keeps the contents of new versions, see UseStore
+	logger       *slog.Logger
+}
+
+// FixtureStore keeps fixture contents outside the database, e.g. in object storage
+type FixtureStore interface {
+	Put(ctx context.Context, key string, content []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// fixtureStoreTimeout bounds a single transfer from or to the fixture store
+const fixtureStoreTimeout = 2 * time.Minute
+
+// FixtureUpload describes an uploaded fixture file
+type FixtureUpload struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+	Checksum    string // optional SHA-256 supplied by the client for integrity verification
+}
+
+// NewFixtureService creates a new fixture service
+func NewFixtureService(db *gorm.DB, maxSizeBytes int64) *FixtureService {
+	return &FixtureService{db: db, maxSizeBytes: maxSizeBytes, logger: slog.Default()}
+}
+
+// SetLogger sets the logger of the service
+func (s *FixtureService) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// UseStore keeps the contents of new fixture versions in a store instead of
+// the database. Versions uploaded before keep their content in the database.
+func (s *FixtureService) UseStore(store FixtureStore) {
+	s.store = store
+}
+
+// MaxSizeBytes returns the maximum accepted fixture size
+func (s *FixtureService) MaxSizeBytes() int64 {
+	return s.maxSizeBytes
+}
+
+// CreateFixture creates a new fixture with the upload as its first version
+func (s *FixtureService) CreateFixture(fixture *models.Fixture, upload *FixtureUpload) error {
+	version, err := s.newVersion(upload)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		fixture.LatestVersion = 1
+		if err := tx.Create(fixture).Error; err != nil {
+			return err
+		}
+
+		version.FixtureID = fixture.ID
+		version.Version = 1
+		if err := s.storeContent(version); err != nil {
+			return err
+		}
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+
+		fixture.Versions = []models.FixtureVersion{*version}
+		return nil
+	})
+}
+
+// AddVersion uploads a new version of an existing fixture
+func (s *FixtureService) AddVersion(fixtureID string, upload *FixtureUpload) (*models.FixtureVersion, error) {
+	version, err := s.newVersion(upload)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var fixture models.Fixture
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&fixture, "id = ?", fixtureID).Error; err != nil {
+			return err
+		}
+
+		version.FixtureID = fixture.ID
+		version.Version = fixture.LatestVersion + 1
+		if err := s.storeContent(version); err != nil {
+			return err
+		}
+		if err := tx.Create(version).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&fixture).Update("latest_version", version.Version).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// GetFixture retrieves a fixture and the metadata of all its versions
+func (s *FixtureService) GetFixture(id string) (*models.Fixture, error) {
+	var fixture models.Fixture
+	err := s.db.Preload("Versions", func(db *gorm.DB) *gorm.DB {
+		return db.Omit("content").Order("version DESC")
+	}).First(&fixture, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &fixture, nil
+}
+
+// ListFixtures retrieves the fixtures of a project, or all fixtures without
+// projectID, with pagination
+func (s *FixtureService) ListFixtures(projectID string, limit, offset int) ([]models.Fixture, int64, error) {
+	var fixtures []models.Fixture
+	var total int64
+
+	query := inProject(s.db.Model(&models.Fixture{}), projectID)
+
+	// Get total count
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results
+	if err := query.Order("name").Limit(limit).Offset(offset).Find(&fixtures).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return fixtures, total, nil
+}
+
+// GetFixtureVersion retrieves a fixture version including its content.
+// Version 0 selects the latest version.
+func (s *FixtureService) GetFixtureVersion(fixtureID string, version int) (*models.FixtureVersion, error) {
+	query := s.db.Where("fixture_id = ?", fixtureID)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	} else {
+		query = query.Order("version DESC")
+	}
+
+	var fixtureVersion models.FixtureVersion
+	if err := query.First(&fixtureVersion).Error; err != nil {
+		return nil, err
+	}
+	if err := s.loadContent(&fixtureVersion); err != nil {
+		return nil, err
+	}
+	return &fixtureVersion, nil
+}
+
+// LoadFixture implements testrunner.FixtureLoader
+func (s *FixtureService) LoadFixture(fixtureID string, version int) (*models.FixtureVersion, error) {
+	return s.GetFixtureVersion(fixtureID, version)
+}
+
+// DeleteFixture deletes a fixture and all of its versions. Contents kept in
+// the store are removed once the records are gone.
+func (s *FixtureService) DeleteFixture(id string) error {
+	var keys []string
+	if err := s.db.Model(&models.FixtureVersion{}).Where("fixture_id = ? AND storage_key <> ''", id).Pluck("storage_key", &keys).Error; err != nil {
+		return err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.FixtureVersion{}, "fixture_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Fixture{}, "id = ?", id).Error
+	})
+	if err != nil || s.store == nil {
+		return err
+	}
+
+	for _, key := range keys {
+		ctx, cancel := context.WithTimeout(context.Background(), fixtureStoreTimeout)
+		if err := s.store.Delete(ctx, key); err != nil {
+			s.logger.Warn("failed to delete fixture content", "key", key, "error", err)
+		}
+		cancel()
+	}
+	return nil
+}
+
+// storeContent moves the content of a new version to the store, if one is
+// configured. Contents are addressed by fixture and checksum, so uploading
+// identical content again reuses the stored object.
+func (s *FixtureService) storeContent(version *models.FixtureVersion) error {
+	if s.store == nil {
+		return nil
+	}
+	key := fmt.Sprintf("fixtures/%s/%s", version.FixtureID, version.Checksum)
+	ctx, cancel := context.WithTimeout(context.Background(), fixtureStoreTimeout)
+	defer cancel()
+	if err := s.store.Put(ctx, key, version.Content, version.ContentType); err != nil {
+		return fmt.Errorf("failed to store fixture content: %v", err)
+	}
+	version.StorageKey = key
+	version.Content = nil
+	return nil
+}
+
+// loadContent fetches the content of a version kept in the store
+func (s *FixtureService) loadContent(version *models.FixtureVersion) error {
+	if version.StorageKey == "" {
+		return nil
+	}
+	if s.store == nil {
+		return fmt.Errorf("fixture %s version %d is kept in object storage, which is not configured", version.FixtureID, version.Version)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), fixtureStoreTimeout)
+	defer cancel()
+	content, err := s.store.Get(ctx, version.StorageKey)
+	if err != nil {
+		return fmt.Errorf("failed to load fixture content: %v", err)
+	}
+	version.Content = content
+	return nil
+}
+
+// newVersion validates an upload and builds the version record for it
+func (s *FixtureService) newVersion(upload *FixtureUpload) (*models.FixtureVersion, error) {
+	size := int64(len(upload.Content))
+	if size == 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidFixture)
+	}
+	if s.maxSizeBytes > 0 && size > s.maxSizeBytes {
+		return nil, fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes", ErrInvalidFixture, size, s.maxSizeBytes)
+	}
+
+	sum := sha256.Sum256(upload.Content)
+	checksum := hex.EncodeToString(sum[:])
+	if upload.Checksum != "" && !strings.EqualFold(upload.Checksum, checksum) {
+		return nil, fmt.Errorf("%w: checksum mismatch, expected %s, got %s", ErrInvalidFixture, upload.Checksum, checksum)
+	}
+
+	contentType := upload.ContentType
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(upload.Content)
+	}
+
+	return &models.FixtureVersion{
+		FileName:    upload.FileName,
+		ContentType: contentType,
+		SizeBytes:   size,
+		Checksum:    checksum,
+		Content:     upload.Content,
+	}, nil
+}
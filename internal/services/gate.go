@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// Gate verdicts
+const (
+	GatePassed  = "passed"
+	GateFailed  = "failed"
+	GatePending = "pending"
+)
+
+// gatePollInterval is how often a waiting gate checks the status of its run
+const gatePollInterval = time.Second
+
+// GateResult is the verdict of a deployment gate on a test run
+type GateResult struct {
+	Verdict  string          `json:"verdict"` // passed, failed, or pending while the run executes
+	Passed   bool            `json:"passed"`
+	Run      *models.TestRun `json:"run"`
+	Failures []GateFailure   `json:"failures,omitempty"`
+}
+
+// GateFailure is a failed or timed out test of a gated run
+type GateFailure struct {
+	TestCaseID   string `json:"test_case_id"`
+	TestName     string `json:"test_name"`
+	APIVersion   string `json:"api_version,omitempty"`
+	Status       string `json:"status"`
+	FailureType  string `json:"failure_type,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// WaitForRun blocks until a run finishes or wait elapses, and returns the
+// verdict of the gate. A run passes only when it completed; failed and
+// cancelled runs fail the gate. The status is read from the database, so any
+// instance can wait for a run executed by another.
+func (s *TestRunService) WaitForRun(ctx context.Context, testRunID string, wait time.Duration) (*GateResult, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		var testRun models.TestRun
+		if err := s.db.WithContext(ctx).Omit("config", "resolved_variables", "debug_log", "security_findings").First(&testRun, "id = ?", testRunID).Error; err != nil {
+			return nil, err
+		}
+		if testRun.Status != "running" {
+			return s.gateVerdict(ctx, &testRun)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return &GateResult{Verdict: GatePending, Run: &testRun}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(min(gatePollInterval, remaining)):
+		}
+	}
+}
+
+// gateVerdict returns the verdict on a finished run with its failed tests
+func (s *TestRunService) gateVerdict(ctx context.Context, testRun *models.TestRun) (*GateResult, error) {
+	if testRun.Status == "completed" {
+		return &GateResult{Verdict: GatePassed, Passed: true, Run: testRun}, nil
+	}
+
+	var failed []models.TestResult
+	err := s.db.WithContext(ctx).Preload("TestCase").
+		Select("id", "test_case_id", "api_version", "status", "failure_type", "error_message", "position").
+		Where("test_run_id = ? AND status IN ?", testRun.ID, []string{"failed", "timed_out"}).
+		Order("position").Find(&failed).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := &GateResult{Verdict: GateFailed, Run: testRun, Failures: make([]GateFailure, 0, len(failed))}
+	for _, testResult := range failed {
+		result.Failures = append(result.Failures, GateFailure{
+			TestCaseID:   testResult.TestCaseID,
+			TestName:     testResult.TestCase.Name,
+			APIVersion:   testResult.APIVersion,
+			Status:       testResult.Status,
+			FailureType:  testResult.FailureType,
+			ErrorMessage: testResult.ErrorMessage,
+		})
+	}
+	return result, nil
+}
+
+// Exit codes of a pipeline summary, for scripts to exit with
+const (
+	ExitPassed  = 0
+	ExitFailed  = 1
+	ExitPending = 2
+)
+
+// uncategorizedFailure counts failed tests recorded without a failure type
+const uncategorizedFailure = "uncategorized"
+
+// PipelineSummary is a compact verdict on a run for CI scripts
+type PipelineSummary struct {
+	RunID             string         `json:"run_id"`
+	Status            string         `json:"status"`
+	Verdict           string         `json:"verdict"`
+	ExitCode          int            `json:"exit_code"`
+	Total             int            `json:"total"`
+	Passed            int            `json:"passed"`
+	Failed            int            `json:"failed"`
+	Skipped           int            `json:"skipped"`
+	TimedOut          int            `json:"timed_out"`
+	DurationMs        int64          `json:"duration_ms"`
+	FailureCategories map[string]int `json:"failure_categories"` // failed and timed out tests by failure type
+	Failures          []GateFailure  `json:"failures,omitempty"`
+}
+
+// GetPipelineSummary waits up to wait for a run to finish, as WaitForRun
+// does, and summarizes its verdict. A wait of 0 reports the current state.
+func (s *TestRunService) GetPipelineSummary(ctx context.Context, testRunID string, wait time.Duration) (*PipelineSummary, error) {
+	result, err := s.WaitForRun(ctx, testRunID, wait)
+	if err != nil {
+		return nil, err
+	}
+
+	run := result.Run
+	summary := &PipelineSummary{
+		RunID:             run.ID,
+		Status:            run.Status,
+		Verdict:           result.Verdict,
+		Total:             run.TotalTests,
+		Passed:            run.PassedTests,
+		Failed:            run.FailedTests,
+		Skipped:           run.SkippedTests,
+		TimedOut:          run.TimedOutTests,
+		DurationMs:        run.ExecutionTimeMs,
+		FailureCategories: map[string]int{},
+		Failures:          result.Failures,
+	}
+	switch result.Verdict {
+	case GatePassed:
+		summary.ExitCode = ExitPassed
+	case GateFailed:
+		summary.ExitCode = ExitFailed
+	default:
+		summary.ExitCode = ExitPending
+		summary.DurationMs = time.Since(run.StartedAt).Milliseconds()
+	}
+	for _, failure := range result.Failures {
+		category := failure.FailureType
+		if category == "" {
+			category = uncategorizedFailure
+		}
+		summary.FailureCategories[category]++
+	}
+	return summary, nil
+}
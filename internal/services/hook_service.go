@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"github.com/tidwall/gjson"
+	"gorm.io/gorm"
+)
+
+// Hook errors
+var (
+	ErrInvalidHook      = errors.New("invalid hook")
+	ErrHookUnauthorized = errors.New("invalid hook token or signature")
+	ErrHookDisabled     = errors.New("hook is disabled")
+	ErrHookEventIgnored = errors.New("event is not handled by the hook")
+)
+
+// HookTrigger is an inbound call of a hook. Callers prove they know the
+// token of the hook by sending it or by signing the payload with it.
+type HookTrigger struct {
+	Token     string // token sent as bearer token, X-Hook-Token or X-Gitlab-Token
+	Signature string // X-Hub-Signature-256 of a GitHub webhook, "sha256=<hex>"
+	Event     string // X-GitHub-Event, X-Gitlab-Event or the event query parameter
+	Payload   []byte
+}
+
+// HookService manages inbound webhooks and starts the runs they describe
+type HookService struct {
+	db             *gorm.DB
+	testRunService *TestRunService
+	suiteService   *SuiteService
+}
+
+// NewHookService creates a new hook service
+func NewHookService(db *gorm.DB, testRunService *TestRunService, suiteService *SuiteService) *HookService {
+	return &HookService{db: db, testRunService: testRunService, suiteService: suiteService}
+}
+
+// CreateHook validates and creates a hook with a new token, which is returned
+// once and not exposed afterwards
+func (s *HookService) CreateHook(hook *models.Hook) (string, error) {
+	if err := validateHook(hook); err != nil {
+		return "", err
+	}
+	token, err := newHookToken()
+	if err != nil {
+		return "", err
+	}
+	hook.Token = token
+	if err := s.db.Create(hook).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GetHook retrieves a hook by ID
+func (s *HookService) GetHook(id string) (*models.Hook, error) {
+	var hook models.Hook
+	if err := s.db.First(&hook, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// ListHooks retrieves the hooks of a project, or all hooks without
+// projectID, with pagination
+func (s *HookService) ListHooks(projectID string, limit, offset int) ([]models.Hook, int64, error) {
+	var hooks []models.Hook
+	var total int64
+
+	query := inProject(s.db.Model(&models.Hook{}), projectID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("name").Limit(limit).Offset(offset).Find(&hooks).Error; err != nil {
+		return nil, 0, err
+	}
+	return hooks, total, nil
+}
+
+// UpdateHook validates and saves a modified hook; its token is kept
+func (s *HookService) UpdateHook(hook *models.Hook) error {
+	if err := validateHook(hook); err != nil {
+		return err
+	}
+	return s.db.Omit("token").Save(hook).Error
+}
+
+// DeleteHook deletes a hook
+func (s *HookService) DeleteHook(id string) error {
+	return s.db.Delete(&models.Hook{}, "id = ?", id).Error
+}
+
+// RotateHookToken replaces the token of a hook; callers using the old token
+// are rejected from then on
+func (s *HookService) RotateHookToken(id string) (*models.Hook, string, error) {
+	hook, err := s.GetHook(id)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := newHookToken()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.db.Model(hook).Update("token", token).Error; err != nil {
+		return nil, "", err
+	}
+	return hook, token, nil
+}
+
+// TriggerHook authenticates a call of a hook and starts its run. Values the
+// metadata mapping of the hook finds in the payload become run variables,
+// overriding the variables of the hook.
+func (s *HookService) TriggerHook(ctx context.Context, id string, trigger HookTrigger) (*models.TestRun, error) {
+	hook, err := s.GetHook(id)
+	if err != nil {
+		return nil, err
+	}
+	if !authenticateHook(hook, trigger) {
+		return nil, ErrHookUnauthorized
+	}
+	if !hook.Enabled {
+		return nil, ErrHookDisabled
+	}
+	if !hookAccepts(hook, trigger.Event) {
+		return nil, fmt.Errorf("%w: %s", ErrHookEventIgnored, trigger.Event)
+	}
+
+	variables := models.Variables{}
+	for name, value := range hook.Variables {
+		variables[name] = value
+	}
+	if len(hook.Metadata) > 0 {
+		if !gjson.ValidBytes(trigger.Payload) {
+			return nil, fmt.Errorf("%w: the metadata mapping requires a JSON payload", ErrInvalidHook)
+		}
+		for name, path := range hook.Metadata {
+			if value := gjson.GetBytes(trigger.Payload, path); value.Exists() {
+				variables[name] = value.String()
+			}
+		}
+	}
+
+	now := time.Now()
+	opts := StartTestRunOptions{
+		Name:           fmt.Sprintf("%s (triggered %s)", hook.Name, now.UTC().Format(time.RFC3339)),
+		TestIDs:        hook.TestIDs,
+		Variables:      variables,
+		MaxConcurrency: hook.MaxConcurrency,
+		HookID:         hook.ID,
+	}
+	if hook.ServiceID != nil {
+		opts.ServiceID = *hook.ServiceID
+	}
+	if hook.EnvironmentID != nil {
+		opts.EnvironmentID = *hook.EnvironmentID
+	}
+	if hook.ProjectID != nil {
+		opts.ProjectID = *hook.ProjectID
+	}
+
+	var testRun *models.TestRun
+	if hook.SuiteID != nil && *hook.SuiteID != "" {
+		testRun, err = s.suiteService.RunSuite(ctx, *hook.SuiteID, opts)
+	} else {
+		testRun, err = s.testRunService.StartTestRun(ctx, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.db.Model(&models.Hook{}).Where("id = ?", hook.ID).Updates(map[string]interface{}{"last_triggered_at": now, "last_run_id": testRun.ID})
+	return testRun, nil
+}
+
+// authenticateHook reports whether a call knows the token of a hook: it
+// sends the token or signs the payload with it as GitHub does
+func authenticateHook(hook *models.Hook, trigger HookTrigger) bool {
+	if trigger.Signature != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Token))
+		mac.Write(trigger.Payload)
+		expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		return hmac.Equal([]byte(trigger.Signature), []byte(expected))
+	}
+	return trigger.Token != "" && subtle.ConstantTimeCompare([]byte(trigger.Token), []byte(hook.Token)) == 1
+}
+
+// hookAccepts reports whether a hook starts a run for an event. GitHub pings
+// a webhook when it is created; pings never start a run.
+func hookAccepts(hook *models.Hook, event string) bool {
+	if event == "ping" {
+		return false
+	}
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, accepted := range hook.Events {
+		if strings.EqualFold(accepted, event) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHook checks that a hook selects the tests it runs
+func validateHook(hook *models.Hook) error {
+	if hook.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidHook)
+	}
+	hasSuite := hook.SuiteID != nil && *hook.SuiteID != ""
+	hasTests := (hook.ServiceID != nil && *hook.ServiceID != "") || len(hook.TestIDs) > 0
+	if hasSuite == hasTests {
+		return fmt.Errorf("%w: either suite_id or service_id and/or test_ids is required", ErrInvalidHook)
+	}
+	for name, path := range hook.Metadata {
+		if name == "" || path == "" {
+			return fmt.Errorf("%w: metadata maps variable names to payload paths", ErrInvalidHook)
+		}
+	}
+	if hook.MaxConcurrency < 1 {
+		hook.MaxConcurrency = 1
+	}
+	return nil
+}
+
+// newHookToken returns a random token of 32 bytes
+func newHookToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate hook token: %v", err)
+	}
+	return hex.EncodeToString(token), nil
+}
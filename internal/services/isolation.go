@@ -0,0 +1,118 @@
+package services
+
+import (
+	"sync"
+
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// suiteLimits returns the parallel limits of the suites limiting any of the
+// test cases of a run, and the limited suites of every test case
+func suiteLimits(db *gorm.DB, testCases []models.TestCase) (map[string]int, map[string][]string, error) {
+	var suites []models.TestSuite
+	if err := db.Where("parallel = ? OR max_parallel > 0", false).Find(&suites).Error; err != nil {
+		return nil, nil, err
+	}
+
+	inRun := make(map[string]bool, len(testCases))
+	for _, testCase := range testCases {
+		inRun[testCase.ID] = true
+	}
+
+	limits := map[string]int{}
+	membership := map[string][]string{}
+	for _, suite := range suites {
+		limit := suite.ParallelLimit()
+		if limit <= 0 {
+			continue
+		}
+		for _, id := range suite.TestIDs {
+			if inRun[id] {
+				limits[suite.ID] = limit
+				membership[id] = append(membership[id], suite.ID)
+			}
+		}
+	}
+	return limits, membership, nil
+}
+
+// runScheduler hands out the items of a run to its workers in order, holding
+// back items whose suites already run as many test cases as they allow, so
+// state-mutating suites are isolated even in a parallel run. An item that
+// is held back does not hold back the items after it.
+type runScheduler struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []runItem
+	pending []int          // items not started yet, in order
+	limits  map[string]int // parallel limit by suite ID
+	running map[string]int // items running by suite ID
+	stopped bool
+}
+
+// newRunScheduler creates the scheduler of the items of a run
+func newRunScheduler(items []runItem, limits map[string]int) *runScheduler {
+	r := &runScheduler{
+		items:   items,
+		pending: make([]int, len(items)),
+		limits:  limits,
+		running: map[string]int{},
+	}
+	r.cond = sync.NewCond(&r.mu)
+	for i := range items {
+		r.pending[i] = i
+	}
+	return r
+}
+
+// next blocks until an item may start and returns its index, or returns
+// false once every item started or the scheduler was stopped
+func (r *runScheduler) next() (int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		if r.stopped || len(r.pending) == 0 {
+			return 0, false
+		}
+		for k, i := range r.pending {
+			if r.startable(i) {
+				r.pending = append(r.pending[:k], r.pending[k+1:]...)
+				for _, suiteID := range r.items[i].suites {
+					r.running[suiteID]++
+				}
+				return i, true
+			}
+		}
+		r.cond.Wait()
+	}
+}
+
+// startable reports whether every limited suite of an item has room for it
+func (r *runScheduler) startable(i int) bool {
+	for _, suiteID := range r.items[i].suites {
+		if limit := r.limits[suiteID]; limit > 0 && r.running[suiteID] >= limit {
+			return false
+		}
+	}
+	return true
+}
+
+// done releases the suites of a finished item
+func (r *runScheduler) done(i int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, suiteID := range r.items[i].suites {
+		r.running[suiteID]--
+	}
+	r.cond.Broadcast()
+}
+
+// stop makes next return false, leaving the items not started yet
+func (r *runScheduler) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = true
+	r.cond.Broadcast()
+}
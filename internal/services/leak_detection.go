@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+
+	"github.com/tidwall/gjson"
+	"gorm.io/gorm"
+)
+
+// leakDetectorKey is the context key of the leak detector of a run
+type leakDetectorKey struct{}
+
+// withLeakDetector returns a context scanning captured responses with detector
+func withLeakDetector(ctx context.Context, detector *testrunner.LeakDetector) context.Context {
+	return context.WithValue(ctx, leakDetectorKey{}, detector)
+}
+
+// leakDetector returns the leak detector of a run, nil when neither the run
+// nor its project asks for leak detection. The project configuration applies
+// to runs requesting detection too, so its allow list is honored.
+func (s *TestRunService) leakDetector(db *gorm.DB, testRun *models.TestRun, requested bool) (*testrunner.LeakDetector, error) {
+	config := models.LeakDetection{}
+	if testRun.ProjectID != nil {
+		var project models.Project
+		if err := db.Select("id", "leak_detection").First(&project, "id = ?", *testRun.ProjectID).Error; err != nil {
+			return nil, err
+		}
+		config = project.LeakDetection
+	}
+	if !requested && !config.Enabled {
+		return nil, nil
+	}
+	testRun.DetectLeaks = true
+	return testrunner.NewLeakDetector(config.Detectors, config.Patterns, config.Allow)
+}
+
+// detectLeaks scans the captured response of a test case for secrets and
+// personal data, recording what it finds as secret_leak findings
+func (s *TestRunService) detectLeaks(ctx context.Context, testCase models.TestCase, testSpec *models.TestSpec, result *testrunner.TestResult) {
+	detector, ok := ctx.Value(leakDetectorKey{}).(*testrunner.LeakDetector)
+	if !ok || result.ResponseData == "" {
+		return
+	}
+	scan, ok := ctx.Value(securityScanKey{}).(*securityScan)
+	if !ok {
+		return
+	}
+
+	leaks := detector.Detect(result.ResponseData)
+	if len(leaks) == 0 {
+		return
+	}
+	statusCode := int(gjson.Get(result.ResponseData, "status_code").Int())
+	findings := make([]models.SecurityFinding, len(leaks))
+	for i, leak := range leaks {
+		findings[i] = models.SecurityFinding{
+			Check:      testrunner.CheckSecretLeak,
+			Severity:   leak.Severity,
+			Method:     testSpec.Request.Method,
+			URL:        testSpec.Request.URL,
+			StatusCode: statusCode,
+			Detector:   leak.Detector,
+			Path:       leak.Path,
+			Message:    fmt.Sprintf("the response contains what looks like %s at %s (%s)", leak.Description, leak.Path, leak.Excerpt),
+		}
+	}
+	s.runLogger(ctx).Debug("detected leaks in response", "leaks", len(leaks))
+	scan.add(testCase, findings)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// ErrPreviewUnsupported is returned when the protocol of a test cannot preview its request
+var ErrPreviewUnsupported = errors.New("requests of this protocol cannot be previewed")
+
+// PreviewRequestOptions selects the test spec to preview: a stored test case,
+// run against its service unless a service_id is given, or a raw spec.
+// DataRow selects the data row, the first by default.
+type PreviewRequestOptions struct {
+	AdHocTarget
+	TestCaseID string           `json:"test_case_id"`
+	TestSpec   *models.TestSpec `json:"test_spec"`
+	DataRow    int              `json:"data_row"`
+}
+
+// PreviewRequest resolves the request a test spec would send, applying
+// variable substitution, authentication and API versioning, without
+// sending it. Credentials and sensitive body fields are redacted.
+func (s *TestRunService) PreviewRequest(ctx context.Context, opts PreviewRequestOptions) (*testrunner.RequestPreview, error) {
+	preview, _, err := s.resolveRequest(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return redactPreview(preview), nil
+}
+
+// resolveRequest resolves the request of the selected test spec without
+// redacting it, and returns the spec with its variables applied
+func (s *TestRunService) resolveRequest(ctx context.Context, opts PreviewRequestOptions) (*testrunner.RequestPreview, *models.TestSpec, error) {
+	var testSpec models.TestSpec
+	switch {
+	case opts.TestCaseID != "" && opts.TestSpec != nil:
+		return nil, nil, fmt.Errorf("test_case_id and test_spec are mutually exclusive")
+	case opts.TestCaseID != "":
+		var testCase models.TestCase
+		if err := s.db.WithContext(ctx).First(&testCase, "id = ?", opts.TestCaseID).Error; err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal([]byte(testCase.TestSpec), &testSpec); err != nil {
+			return nil, nil, fmt.Errorf("invalid test spec: %v", err)
+		}
+		if opts.ServiceID == "" {
+			opts.ServiceID = testCase.ServiceID
+		}
+	case opts.TestSpec != nil:
+		testSpec = *opts.TestSpec
+	default:
+		return nil, nil, fmt.Errorf("test_case_id or test_spec is required")
+	}
+
+	if err := s.applyDataRow(&testSpec, opts.DataRow); err != nil {
+		return nil, nil, err
+	}
+	executor, _, err := s.prepareUnsaved(ctx, opts.AdHocTarget, &testSpec)
+	if err != nil {
+		return nil, nil, err
+	}
+	previewer, ok := executor.(testrunner.Previewer)
+	if !ok {
+		return nil, nil, ErrPreviewUnsupported
+	}
+	preview, err := previewer.PreviewRequest(ctx, &testSpec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return preview, &testSpec, nil
+}
+
+// redactPreview masks the credentials, sensitive headers and sensitive body
+// fields of a previewed request. The scheme of an Authorization header is
+// kept so the kind of credentials remains visible.
+func redactPreview(preview *testrunner.RequestPreview) *testrunner.RequestPreview {
+	for name, value := range preview.Headers {
+		if !sensitiveHeaders[strings.ToLower(name)] && !strings.EqualFold(name, preview.Credential) {
+			continue
+		}
+		if scheme, _, found := strings.Cut(value, " "); found && strings.EqualFold(name, "Authorization") {
+			preview.Headers[name] = scheme + " " + redactedValue
+		} else {
+			preview.Headers[name] = redactedValue
+		}
+	}
+
+	if preview.Credential != "" {
+		if parsed, err := neturl.Parse(preview.URL); err == nil {
+			query := parsed.Query()
+			if query.Has(preview.Credential) {
+				query.Set(preview.Credential, redactedValue)
+				parsed.RawQuery = query.Encode()
+				preview.URL = parsed.String()
+			}
+		}
+	}
+
+	switch preview.Body.(type) {
+	case map[string]interface{}, []interface{}:
+		preview.Body = redactValue(preview.Body)
+	}
+	return preview
+}
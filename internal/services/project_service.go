@@ -0,0 +1,185 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidProject is returned when a project fails validation
+var ErrInvalidProject = errors.New("invalid project")
+
+// ErrProjectNotEmpty is returned when deleting a project that still owns resources
+var ErrProjectNotEmpty = errors.New("project not empty")
+
+// ErrProjectMismatch is returned when a run combines resources of different projects
+var ErrProjectMismatch = errors.New("project mismatch")
+
+// projectResources are the models owned by projects, by the name reported
+// when a project cannot be deleted
+var projectResources = []struct {
+	name  string
+	model interface{}
+}{
+	{"services", &models.Service{}},
+	{"environments", &models.Environment{}},
+	{"suites", &models.TestSuite{}},
+	{"schedules", &models.Schedule{}},
+	{"hooks", &models.Hook{}},
+	{"fixtures", &models.Fixture{}},
+	{"test runs", &models.TestRun{}},
+}
+
+// ProjectService handles project operations
+type ProjectService struct {
+	db         *gorm.DB
+	usageRates UsageRates
+}
+
+// NewProjectService creates a new project service
+func NewProjectService(db *gorm.DB) *ProjectService {
+	return &ProjectService{db: db}
+}
+
+// ProjectUpdate holds the fields of a project to change; nil fields are kept
+type ProjectUpdate struct {
+	Name          *string               `json:"name"`
+	Description   *string               `json:"description"`
+	LeakDetection *models.LeakDetection `json:"leak_detection"`
+	Quotas        *models.ProjectQuotas `json:"quotas"`
+}
+
+// CreateProject validates and creates a new project
+func (s *ProjectService) CreateProject(project *models.Project) error {
+	if err := validateLeakDetection(project.LeakDetection); err != nil {
+		return err
+	}
+	if err := validateQuotas(project.Quotas); err != nil {
+		return err
+	}
+	return s.db.Create(project).Error
+}
+
+// GetProject retrieves a project by ID
+func (s *ProjectService) GetProject(id string) (*models.Project, error) {
+	var project models.Project
+	if err := s.db.First(&project, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// ListProjects retrieves all projects with pagination
+func (s *ProjectService) ListProjects(limit, offset int) ([]models.Project, int64, error) {
+	var projects []models.Project
+	var total int64
+
+	if err := s.db.Model(&models.Project{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := s.db.Order("name").Limit(limit).Offset(offset).Find(&projects).Error; err != nil {
+		return nil, 0, err
+	}
+	return projects, total, nil
+}
+
+// UpdateProject updates an existing project and returns the updated project
+func (s *ProjectService) UpdateProject(id string, update ProjectUpdate) (*models.Project, error) {
+	project, err := s.GetProject(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if update.Name != nil {
+		updates["name"] = *update.Name
+	}
+	if update.Description != nil {
+		updates["description"] = *update.Description
+	}
+	if update.LeakDetection != nil {
+		if err := validateLeakDetection(*update.LeakDetection); err != nil {
+			return nil, err
+		}
+		updates["leak_detection"] = *update.LeakDetection
+	}
+	if update.Quotas != nil {
+		if err := validateQuotas(*update.Quotas); err != nil {
+			return nil, err
+		}
+		updates["quotas"] = *update.Quotas
+	}
+	if len(updates) > 0 {
+		if err := s.db.Model(project).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return s.GetProject(id)
+}
+
+// validateLeakDetection checks the detectors and patterns of a leak detection configuration
+func validateLeakDetection(config models.LeakDetection) error {
+	if _, err := testrunner.NewLeakDetector(config.Detectors, config.Patterns, config.Allow); err != nil {
+		return fmt.Errorf("%w: leak_detection: %v", ErrInvalidProject, err)
+	}
+	return nil
+}
+
+// DeleteProject deletes a project. Projects still owning services,
+// environments, suites, schedules, hooks, fixtures or runs are kept and
+// ErrProjectNotEmpty tells which.
+func (s *ProjectService) DeleteProject(id string) error {
+	if _, err := s.GetProject(id); err != nil {
+		return err
+	}
+	for _, resource := range projectResources {
+		var count int64
+		if err := s.db.Model(resource.model).Where("project_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: the project still has %d %s", ErrProjectNotEmpty, count, resource.name)
+		}
+	}
+	return s.db.Delete(&models.Project{}, "id = ?", id).Error
+}
+
+// sharedProject returns the project of the services of test cases when they
+// all belong to the same one, nil otherwise
+func sharedProject(testCases []models.TestCase) *string {
+	var projectID *string
+	for i, testCase := range testCases {
+		switch {
+		case testCase.Service.ProjectID == nil:
+			return nil
+		case i == 0:
+			projectID = testCase.Service.ProjectID
+		case *testCase.Service.ProjectID != *projectID:
+			return nil
+		}
+	}
+	return projectID
+}
+
+// inProject restricts a query of a model owned by projects to the records
+// of a project. An empty projectID selects the records of every project.
+func inProject(query *gorm.DB, projectID string) *gorm.DB {
+	if projectID == "" {
+		return query
+	}
+	return query.Where("project_id = ?", projectID)
+}
+
+// testCasesInProject restricts a test case query to the test cases of the
+// services of a project. An empty projectID selects every test case.
+func testCasesInProject(query *gorm.DB, projectID string) *gorm.DB {
+	if projectID == "" {
+		return query
+	}
+	services := query.Session(&gorm.Session{NewDB: true}).Model(&models.Service{}).Select("id").Where("project_id = ?", projectID)
+	return query.Where("test_cases.service_id IN (?)", services)
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// ErrPromoteUnsupported is returned for test cases whose responses cannot be promoted to assertions
+var ErrPromoteUnsupported = errors.New("responses of this test cannot be promoted to assertions")
+
+// Modes of promoted assertions
+const (
+	PromoteStructure = "structure" // exists assertions for every field
+	PromoteValues    = "values"    // equals assertions for scalar values, exists for the others
+)
+
+// Limits of promoted body assertions
+const (
+	defaultPromoteDepth      = 5
+	defaultPromoteArrayItems = 3
+	maxPromotedAssertions    = 200
+)
+
+// promotedTypes are the assertion types promotion generates and replaces;
+// other assertions of a test case, such as json_schema or response_time,
+// are kept
+var promotedTypes = map[string]bool{
+	"status_code":  true,
+	"status_class": true,
+	"exists":       true,
+	"equals":       true,
+	"json_path":    true,
+}
+
+// PromoteOptions controls how a live response is turned into assertions.
+// The request runs against the test case's service unless a service_id is
+// given, with the target fields of ad-hoc requests. With DryRun the
+// assertions are returned without updating the test case.
+type PromoteOptions struct {
+	AdHocTarget
+	Mode          string `json:"mode"`
+	DataRow       int    `json:"data_row"`
+	MaxDepth      int    `json:"max_depth"`
+	MaxArrayItems int    `json:"max_array_items"`
+	DryRun        bool   `json:"dry_run"`
+}
+
+// PromoteResult is the outcome of promoting a response into assertions
+type PromoteResult struct {
+	TestCase   *models.TestCase       `json:"test_case"`
+	StatusCode int                    `json:"status_code"`
+	Mode       string                 `json:"mode"`
+	Assertions []models.AssertionSpec `json:"assertions"` // all assertions of the test case after promotion
+	Generated  int                    `json:"generated"`
+	Kept       int                    `json:"kept"`
+	Truncated  bool                   `json:"truncated"` // the body had more fields than were asserted
+	DryRun     bool                   `json:"dry_run"`
+}
+
+// PromoteResponse executes the request of a test case once and rewrites
+// its status and body assertions from the actual response: exists
+// assertions for every field of the body in structure mode, and equals
+// assertions for its scalar values in values mode. Assertions of other
+// types are kept. Sensitive fields are only asserted to exist.
+func (s *TestRunService) PromoteResponse(ctx context.Context, testCaseID string, opts PromoteOptions) (*PromoteResult, error) {
+	switch opts.Mode {
+	case "":
+		opts.Mode = PromoteStructure
+	case PromoteStructure, PromoteValues:
+	default:
+		return nil, fmt.Errorf("unknown mode %q, expected %s or %s", opts.Mode, PromoteStructure, PromoteValues)
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = defaultPromoteDepth
+	}
+	if opts.MaxArrayItems <= 0 {
+		opts.MaxArrayItems = defaultPromoteArrayItems
+	}
+
+	var testCase models.TestCase
+	if err := s.db.WithContext(ctx).First(&testCase, "id = ?", testCaseID).Error; err != nil {
+		return nil, err
+	}
+	var stored models.TestSpec
+	if err := json.Unmarshal([]byte(testCase.TestSpec), &stored); err != nil {
+		return nil, fmt.Errorf("invalid test spec: %v", err)
+	}
+	switch strings.ToLower(stored.Protocol) {
+	case "", testrunner.ProtocolHTTP, testrunner.ProtocolGraphQL:
+	default:
+		return nil, fmt.Errorf("%w: protocol %s", ErrPromoteUnsupported, stored.Protocol)
+	}
+	if len(stored.Variants) > 0 {
+		return nil, fmt.Errorf("%w: request matrix tests have one response per variant", ErrPromoteUnsupported)
+	}
+	if opts.ServiceID == "" {
+		opts.ServiceID = testCase.ServiceID
+	}
+
+	// Only the response matters, so the existing assertions cannot fail the
+	// execution and no retries hide the actual behaviour
+	testSpec := stored
+	testSpec.Assertions = []models.AssertionSpec{}
+	testSpec.Retry = nil
+	if err := s.applyDataRow(&testSpec, opts.DataRow); err != nil {
+		return nil, err
+	}
+	result, err := s.executeUnsaved(ctx, opts.AdHocTarget, &testSpec)
+	if err != nil {
+		return nil, err
+	}
+	statusCode := capturedStatusCode(result.ResponseData)
+	if statusCode == 0 {
+		return nil, fmt.Errorf("no response received: %s", result.ErrorMessage)
+	}
+
+	generator := &assertionGenerator{opts: opts}
+	generator.add(models.AssertionSpec{Type: "status_code", Expected: statusCode})
+	var response map[string]interface{}
+	if json.Unmarshal([]byte(result.ResponseData), &response) == nil {
+		if body, ok := response["body"]; ok {
+			generator.body(body, "body", 0)
+		}
+	}
+
+	assertions := generator.assertions
+	kept := 0
+	for _, assertion := range stored.Assertions {
+		if !promotedTypes[assertion.Type] {
+			assertions = append(assertions, assertion)
+			kept++
+		}
+	}
+
+	promoted := &PromoteResult{
+		TestCase:   &testCase,
+		StatusCode: statusCode,
+		Mode:       opts.Mode,
+		Assertions: assertions,
+		Generated:  len(generator.assertions),
+		Kept:       kept,
+		Truncated:  generator.truncated,
+		DryRun:     opts.DryRun,
+	}
+	if opts.DryRun {
+		return promoted, nil
+	}
+
+	stored.Assertions = assertions
+	encoded, err := json.Marshal(stored)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode test spec: %v", err)
+	}
+	spec, err := models.EncryptTestSpec(string(encoded))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Model(&testCase).Update("test_spec", spec).Error; err != nil {
+		return nil, fmt.Errorf("failed to update test case: %v", err)
+	}
+	testCase.TestSpec = string(encoded)
+	return promoted, nil
+}
+
+// assertionGenerator collects the assertions promoted from a response body
+type assertionGenerator struct {
+	opts       PromoteOptions
+	assertions []models.AssertionSpec
+	truncated  bool
+}
+
+// add appends an assertion unless the limit of promoted assertions is reached
+func (g *assertionGenerator) add(assertion models.AssertionSpec) bool {
+	if len(g.assertions) >= maxPromotedAssertions {
+		g.truncated = true
+		return false
+	}
+	g.assertions = append(g.assertions, assertion)
+	return true
+}
+
+// body generates the assertions of a value at a path of the response data.
+// Objects and arrays are asserted to exist and descended into up to the
+// maximum depth, arrays up to the maximum number of items.
+func (g *assertionGenerator) body(value interface{}, path string, depth int) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if depth >= g.opts.MaxDepth {
+			g.truncated = true
+			return
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := path + "." + escapePathKey(key)
+			if sensitiveFields[strings.ToLower(key)] {
+				g.add(models.AssertionSpec{Type: "exists", Path: child, Expected: true})
+				continue
+			}
+			g.field(value[key], child, depth+1)
+		}
+	case []interface{}:
+		if depth >= g.opts.MaxDepth {
+			g.truncated = true
+			return
+		}
+		for i, item := range value {
+			if i >= g.opts.MaxArrayItems {
+				g.truncated = true
+				break
+			}
+			g.field(item, path+"."+strconv.Itoa(i), depth+1)
+		}
+	}
+}
+
+// field generates the assertions of a field or array item
+func (g *assertionGenerator) field(value interface{}, path string, depth int) {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		if g.add(models.AssertionSpec{Type: "exists", Path: path, Expected: true}) {
+			g.body(value, path, depth)
+		}
+	case nil:
+		// equals assertions ignore a null expectation, and exists holds
+		g.add(models.AssertionSpec{Type: "exists", Path: path, Expected: true})
+	default:
+		if g.opts.Mode == PromoteValues {
+			g.add(models.AssertionSpec{Type: "equals", Path: path, Expected: value})
+		} else {
+			g.add(models.AssertionSpec{Type: "exists", Path: path, Expected: true})
+		}
+	}
+}
+
+// escapePathKey escapes the characters of an object key that have a meaning
+// in gjson paths
+func escapePathKey(key string) string {
+	var builder strings.Builder
+	for _, r := range key {
+		if strings.ContainsRune(`.*?|#@!\`, r) {
+			builder.WriteByte('\\')
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+const (
+	// maxQueueScan bounds the jobs read from a queue to locate the runs
+	// waiting in it
+	maxQueueScan = 10000
+	// queueLatencyWindow is the span of results the execution time of
+	// queued tests is estimated from
+	queueLatencyWindow = time.Hour
+	// defaultJobDuration estimates tests of queues without recent results
+	defaultJobDuration = time.Second
+	// scheduledHorizon is how far ahead due schedules are reported
+	scheduledHorizon = time.Hour
+)
+
+// Run states of the queue status
+const (
+	QueueStateExecuting        = "executing"          // no test of the run waits for a worker
+	QueueStateWaitingForWorker = "waiting_for_worker" // tests wait behind others for a free worker
+	QueueStateNoWorker         = "no_worker"          // tests wait in a queue no live worker serves
+)
+
+// QueueStatus is a snapshot of the work waiting to execute, so users can
+// tell why a run has not progressed
+type QueueStatus struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Runs        []RunQueueStatus `json:"runs"`
+	Queues      []JobQueueStatus `json:"queues"`
+	Scheduled   []ScheduledRun   `json:"scheduled"`
+}
+
+// JobQueueStatus is the state of the job queue of a region or egress pool
+type JobQueueStatus struct {
+	RegionCapacity
+	AvgJobMs        int64  `json:"avg_job_ms"`                  // recent execution time of its tests
+	EstimatedWaitMs *int64 `json:"estimated_wait_ms,omitempty"` // until a test queued now starts, absent without live workers
+}
+
+// RunQueueStatus tells where a running run stands
+type RunQueueStatus struct {
+	TestRunID        string     `json:"test_run_id"`
+	Name             string     `json:"name"`
+	ProjectID        *string    `json:"project_id,omitempty"`
+	StartedAt        time.Time  `json:"started_at"`
+	TotalTests       int        `json:"total_tests"`
+	CompletedTests   int64      `json:"completed_tests"`
+	QueuedJobs       int64      `json:"queued_jobs"`        // tests waiting in the queues of workers
+	Queue            string     `json:"queue,omitempty"`    // queue of its next test to start, e.g. region eu-west-1
+	Position         *int64     `json:"position,omitempty"` // tests ahead of it in that queue
+	EstimatedStartAt *time.Time `json:"estimated_start_at,omitempty"`
+	State            string     `json:"state"`
+	Reason           string     `json:"reason,omitempty"`
+}
+
+// ScheduledRun is a run a schedule is due to start
+type ScheduledRun struct {
+	ScheduleID string    `json:"schedule_id"`
+	Name       string    `json:"name"`
+	ProjectID  *string   `json:"project_id,omitempty"`
+	NextRunAt  time.Time `json:"next_run_at"`
+}
+
+// queuedRun is the earliest position of a run in a queue and its number of
+// queued tests there
+type queuedRun struct {
+	position int64
+	jobs     int64
+}
+
+// GetQueueStatus reports the running runs with their progress and the tests
+// they have waiting for workers, the job queues of the regions and egress
+// pools, and the schedules due within the next hour; optionally of the runs
+// and schedules of a single project. Queues are only known with Redis.
+func (s *TestRunService) GetQueueStatus(ctx context.Context, projectID string) (*QueueStatus, error) {
+	db := s.db.WithContext(ctx)
+	now := time.Now()
+	status := &QueueStatus{GeneratedAt: now, Runs: []RunQueueStatus{}, Queues: []JobQueueStatus{}, Scheduled: []ScheduledRun{}}
+
+	var runs []models.TestRun
+	if err := inProject(db.Select("id, name, project_id, started_at, total_tests"), projectID).
+		Where("status = ?", "running").Order("started_at").Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve running runs: %v", err)
+	}
+	completed := map[string]int64{}
+	if len(runs) > 0 {
+		ids := make([]string, len(runs))
+		for i, run := range runs {
+			ids[i] = run.ID
+		}
+		var counts []struct {
+			TestRunID string
+			Count     int64
+		}
+		if err := db.Table("test_results").Select("test_run_id, COUNT(*) AS count").
+			Where("test_run_id IN ?", ids).Group("test_run_id").Scan(&counts).Error; err != nil {
+			return nil, fmt.Errorf("failed to count results: %v", err)
+		}
+		for _, count := range counts {
+			completed[count.TestRunID] = count.Count
+		}
+	}
+
+	queues, waiting, err := s.jobQueues(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status.Queues = queues
+
+	for _, run := range runs {
+		runStatus := RunQueueStatus{
+			TestRunID:      run.ID,
+			Name:           run.Name,
+			ProjectID:      run.ProjectID,
+			StartedAt:      run.StartedAt,
+			TotalTests:     run.TotalTests,
+			CompletedTests: completed[run.ID],
+			State:          QueueStateExecuting,
+		}
+		// The run waits for the queue its next test starts from first;
+		// queues without workers come last
+		var next *JobQueueStatus
+		var nextWait time.Duration
+		for i := range queues {
+			queued, ok := waiting[i][run.ID]
+			if !ok {
+				continue
+			}
+			runStatus.QueuedJobs += queued.jobs
+			wait, known := queues[i].estimatedWait(queued.position)
+			if !known {
+				wait = math.MaxInt64
+			}
+			if next == nil || wait < nextWait || (wait == nextWait && queued.position < *runStatus.Position) {
+				position := queued.position
+				next, nextWait = &queues[i], wait
+				runStatus.Queue, runStatus.Position = next.name(), &position
+			}
+		}
+		if next != nil {
+			if next.Workers == 0 {
+				runStatus.State = QueueStateNoWorker
+				runStatus.Reason = "no live worker serves " + next.name()
+			} else {
+				startAt := now.Add(nextWait)
+				runStatus.State = QueueStateWaitingForWorker
+				runStatus.EstimatedStartAt = &startAt
+				runStatus.Reason = fmt.Sprintf("%d tests ahead in %s, whose %d workers execute %d tests at once", *runStatus.Position, next.name(), next.Workers, next.Capacity)
+			}
+		}
+		status.Runs = append(status.Runs, runStatus)
+	}
+
+	var schedules []models.Schedule
+	if err := inProject(db.Select("id, name, project_id, next_run_at"), projectID).
+		Where("enabled = ? AND next_run_at <= ?", true, now.Add(scheduledHorizon)).
+		Order("next_run_at").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve due schedules: %v", err)
+	}
+	for _, schedule := range schedules {
+		status.Scheduled = append(status.Scheduled, ScheduledRun{ScheduleID: schedule.ID, Name: schedule.Name, ProjectID: schedule.ProjectID, NextRunAt: *schedule.NextRunAt})
+	}
+	return status, nil
+}
+
+// jobQueues returns the job queues of the regions and egress pools with live
+// workers or pending jobs, and per queue the runs with tests waiting in it
+func (s *TestRunService) jobQueues(ctx context.Context) ([]JobQueueStatus, []map[string]queuedRun, error) {
+	if s.redisClient == nil {
+		return []JobQueueStatus{}, nil, nil
+	}
+	capacities, err := s.workerService().Capacities(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Queues whose workers are all gone still hold their jobs
+	known := map[string]bool{}
+	for _, capacity := range capacities {
+		known[capacity.queueKey()] = true
+	}
+	for _, pattern := range []string{regionJobsKey("*"), poolJobsKey("*")} {
+		keys := s.redisClient.Scan(ctx, 0, pattern, 100).Iterator()
+		for keys.Next(ctx) {
+			key := keys.Val()
+			if known[key] {
+				continue
+			}
+			queued, err := s.redisClient.LLen(ctx, key).Result()
+			if err != nil || queued == 0 {
+				continue
+			}
+			kind, name, _ := strings.Cut(strings.TrimSuffix(key, ":jobs"), ":")
+			capacity := RegionCapacity{Region: name, QueuedJobs: queued}
+			if kind == "pools" {
+				capacity = RegionCapacity{Pool: name, QueuedJobs: queued}
+			}
+			capacities = append(capacities, capacity)
+		}
+		if err := keys.Err(); err != nil {
+			return nil, nil, fmt.Errorf("failed to list job queues: %v", err)
+		}
+	}
+
+	avgJobMs, err := s.recentJobDurations(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queues := make([]JobQueueStatus, len(capacities))
+	waiting := make([]map[string]queuedRun, len(capacities))
+	for i, capacity := range capacities {
+		queue := JobQueueStatus{RegionCapacity: capacity, AvgJobMs: defaultJobDuration.Milliseconds()}
+		if avg, ok := avgJobMs[capacity.Region]; ok {
+			queue.AvgJobMs = avg
+		}
+		if wait, ok := queue.estimatedWait(queue.QueuedJobs); ok {
+			waitMs := wait.Milliseconds()
+			queue.EstimatedWaitMs = &waitMs
+		}
+		queues[i] = queue
+
+		waiting[i] = map[string]queuedRun{}
+		if capacity.QueuedJobs == 0 {
+			continue
+		}
+		// Jobs are pushed on the left and taken from the right, so the
+		// oldest are at the end of the list
+		payloads, err := s.redisClient.LRange(ctx, capacity.queueKey(), -maxQueueScan, -1).Result()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read job queue of %s: %v", queue.name(), err)
+		}
+		for j, payload := range payloads {
+			var job struct {
+				TestRunID string `json:"test_run_id"`
+			}
+			if json.Unmarshal([]byte(payload), &job) != nil || job.TestRunID == "" {
+				continue
+			}
+			position := int64(len(payloads) - 1 - j)
+			queued, ok := waiting[i][job.TestRunID]
+			if !ok || position < queued.position {
+				queued.position = position
+			}
+			queued.jobs++
+			waiting[i][job.TestRunID] = queued
+		}
+	}
+	return queues, waiting, nil
+}
+
+// recentJobDurations returns the average execution time of the tests each
+// region executed recently, in milliseconds
+func (s *TestRunService) recentJobDurations(ctx context.Context) (map[string]int64, error) {
+	var averages []struct {
+		Region string
+		AvgMs  float64
+	}
+	if err := s.reader.WithContext(ctx).Table("test_results").
+		Select("region, AVG(execution_time_ms) AS avg_ms").
+		Where("created_at >= ? AND region <> '' AND status <> ?", time.Now().Add(-queueLatencyWindow), "skipped").
+		Group("region").Scan(&averages).Error; err != nil {
+		return nil, fmt.Errorf("failed to average execution times: %v", err)
+	}
+	durations := make(map[string]int64, len(averages))
+	for _, average := range averages {
+		durations[average.Region] = int64(average.AvgMs)
+	}
+	return durations, nil
+}
+
+// estimatedWait estimates when a test with position tests ahead of it in the
+// queue starts: the workers execute Capacity tests at once and free a slot
+// every AvgJobMs / Capacity. It is unknown without live workers.
+func (q JobQueueStatus) estimatedWait(position int64) (time.Duration, bool) {
+	if q.Workers == 0 || q.Capacity == 0 {
+		return 0, false
+	}
+	ahead := position + int64(q.ActiveJobs) - int64(q.Capacity) + 1
+	if ahead <= 0 {
+		return 0, true
+	}
+	return time.Duration(ahead) * time.Duration(q.AvgJobMs) * time.Millisecond / time.Duration(q.Capacity), true
+}
+
+// queueKey returns the Redis list holding the pending jobs of the region or
+// egress pool
+func (c RegionCapacity) queueKey() string {
+	if c.Pool != "" {
+		return poolJobsKey(c.Pool)
+	}
+	return regionJobsKey(c.Region)
+}
+
+// name describes the queue, e.g. "region eu-west-1"
+func (c RegionCapacity) name() string {
+	if c.Pool != "" {
+		return "egress pool " + c.Pool
+	}
+	return "region " + c.Region
+}
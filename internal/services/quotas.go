@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuotaExceeded is returned when starting a run would exceed a quota of
+// its project
+var ErrQuotaExceeded = errors.New("project quota exceeded")
+
+// QuotaError reports the quota of a project a run was rejected by
+type QuotaError struct {
+	Quota      string // max_concurrent_runs, max_tests_per_day or max_virtual_users
+	Limit      int
+	Usage      int64         // usage of the project had the run been started
+	RetryAfter time.Duration // until the quota has room again, 0 when unknown
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: %s is %d, the run would use %d", ErrQuotaExceeded, e.Quota, e.Limit, e.Usage)
+}
+
+func (e *QuotaError) Unwrap() error { return ErrQuotaExceeded }
+
+// QuotaUsage reports the usage of a project against its quotas. Running runs
+// and virtual users count the runs admitted past the quotas that are still
+// running; tests today counts the test executions of the runs started since
+// midnight UTC.
+type QuotaUsage struct {
+	ProjectID    string               `json:"project_id"`
+	Quotas       models.ProjectQuotas `json:"quotas"`
+	RunningRuns  int64                `json:"running_runs"`
+	VirtualUsers int64                `json:"virtual_users"`
+	TestsToday   int64                `json:"tests_today"`
+	ResetsAt     time.Time            `json:"resets_at"` // when tests_today restarts from zero
+}
+
+// GetQuotaUsage reports the usage of a project against its quotas
+func (s *ProjectService) GetQuotaUsage(id string) (*QuotaUsage, error) {
+	project, err := s.GetProject(id)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := quotaUsage(s.db, *project)
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// quotaUsage computes the usage of a project. Runs are only created once
+// they are admitted, so runs still being prepared do not count as running.
+func quotaUsage(db *gorm.DB, project models.Project) (QuotaUsage, error) {
+	dayStart := time.Now().UTC().Truncate(24 * time.Hour)
+	usage := QuotaUsage{ProjectID: project.ID, Quotas: project.Quotas, ResetsAt: dayStart.Add(24 * time.Hour)}
+
+	runs := func() *gorm.DB {
+		return db.Model(&models.TestRun{}).Where("project_id = ?", project.ID)
+	}
+
+	var running struct {
+		Runs         int64
+		VirtualUsers int64
+	}
+	if err := runs().Where("status = ? AND total_tests > 0", "running").
+		Select("COUNT(*) AS runs, COALESCE(SUM(max_concurrency), 0) AS virtual_users").
+		Scan(&running).Error; err != nil {
+		return usage, fmt.Errorf("failed to count running runs: %v", err)
+	}
+	usage.RunningRuns, usage.VirtualUsers = running.Runs, running.VirtualUsers
+
+	if err := runs().Where("started_at >= ?", dayStart).
+		Select("COALESCE(SUM(total_tests), 0)").
+		Scan(&usage.TestsToday).Error; err != nil {
+		return usage, fmt.Errorf("failed to count tests started today: %v", err)
+	}
+	return usage, nil
+}
+
+// admitRun creates a run whose test cases are counted, unless it would
+// exceed a quota of its project; rejected runs are never created and are
+// reported with a QuotaError. Admissions lock the project, so concurrent
+// runs cannot both take the last free slot. As the run only exists once it
+// is admitted, no error leaves a run behind that never executes.
+func (s *TestRunService) admitRun(db *gorm.DB, testRun *models.TestRun) error {
+	if testRun.ProjectID == nil {
+		if err := db.Create(testRun).Error; err != nil {
+			return fmt.Errorf("failed to create test run: %v", err)
+		}
+		return nil
+	}
+
+	var quotaErr error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var project models.Project
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&project, "id = ?", *testRun.ProjectID).Error; err != nil {
+			return err
+		}
+		if project.Quotas != (models.ProjectQuotas{}) {
+			usage, err := quotaUsage(tx, project)
+			if err != nil {
+				return err
+			}
+			if quotaErr = exceededQuota(usage, testRun); quotaErr != nil {
+				return nil
+			}
+		}
+		return tx.Create(testRun).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create test run: %v", err)
+	}
+	return quotaErr
+}
+
+// exceededQuota returns the error of the first quota a run would exceed
+// given the usage of the other runs of its project, nil when it fits
+func exceededQuota(usage QuotaUsage, testRun *models.TestRun) error {
+	quotas := usage.Quotas
+	if quotas.MaxConcurrentRuns > 0 && usage.RunningRuns+1 > int64(quotas.MaxConcurrentRuns) {
+		return &QuotaError{Quota: "max_concurrent_runs", Limit: quotas.MaxConcurrentRuns, Usage: usage.RunningRuns + 1}
+	}
+	if vus := usage.VirtualUsers + int64(testRun.MaxConcurrency); quotas.MaxVirtualUsers > 0 && vus > int64(quotas.MaxVirtualUsers) {
+		return &QuotaError{Quota: "max_virtual_users", Limit: quotas.MaxVirtualUsers, Usage: vus}
+	}
+	if tests := usage.TestsToday + int64(testRun.TotalTests); quotas.MaxTestsPerDay > 0 && tests > int64(quotas.MaxTestsPerDay) {
+		return &QuotaError{Quota: "max_tests_per_day", Limit: quotas.MaxTestsPerDay, Usage: tests, RetryAfter: time.Until(usage.ResetsAt)}
+	}
+	return nil
+}
+
+// validateQuotas checks that the quotas of a project are not negative
+func validateQuotas(quotas models.ProjectQuotas) error {
+	if quotas.MaxConcurrentRuns < 0 || quotas.MaxTestsPerDay < 0 || quotas.MaxVirtualUsers < 0 {
+		return fmt.Errorf("%w: quotas must not be negative, 0 is unlimited", ErrInvalidProject)
+	}
+	return nil
+}
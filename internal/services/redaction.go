@@ -0,0 +1,231 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// redactedValue replaces sensitive values in captured response data
+const redactedValue = "[REDACTED]"
+
+// minRedactedSecret is the length below which auth secrets are not redacted
+// from responses, as they would mask unrelated text
+const minRedactedSecret = 4
+
+// sensitiveHeaders lists response headers whose values are never exposed
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// sensitiveFields lists JSON body keys (case-insensitive) whose values are never exposed
+var sensitiveFields = map[string]bool{
+	"password":      true,
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"client_secret": true,
+	"api_key":       true,
+	"secret":        true,
+}
+
+// SetRedactionRules sets the rules masking the responses of every service,
+// on top of the rules of each service
+func (s *TestRunService) SetRedactionRules(rules models.RedactionRules) {
+	s.redaction = rules
+}
+
+// redactResponseData masks sensitive headers and body fields in captured
+// response data ({"status_code", "headers", "body"} or a variants wrapper),
+// as well as the headers and paths of the rules
+func redactResponseData(data map[string]interface{}, rules ...models.RedactionRules) map[string]interface{} {
+	return maskResponseData(data, true, rules)
+}
+
+// maskResponseData masks the headers and paths of the rules in captured
+// response data, and the always sensitive headers and fields with builtIn
+func maskResponseData(data map[string]interface{}, builtIn bool, rules []models.RedactionRules) map[string]interface{} {
+	if headers, ok := data["headers"].(map[string]interface{}); ok {
+		for name := range headers {
+			if (builtIn && sensitiveHeaders[strings.ToLower(name)]) || redactsHeader(rules, name) {
+				headers[name] = []interface{}{redactedValue}
+			}
+		}
+	}
+
+	if body, ok := data["body"]; ok {
+		if builtIn {
+			body = redactValue(body)
+		}
+		for _, rule := range rules {
+			for _, path := range rule.Paths {
+				body = redactPath(body, pathSegments(path))
+			}
+		}
+		data["body"] = body
+	}
+
+	if variants, ok := data["variants"].([]interface{}); ok {
+		for _, variant := range variants {
+			if variantData, ok := variant.(map[string]interface{}); ok {
+				if response, ok := variantData["response_data"].(map[string]interface{}); ok {
+					maskResponseData(response, builtIn, rules)
+				}
+			}
+		}
+	}
+
+	return data
+}
+
+// redactResponse applies the redaction rules of every service and of the
+// service a response was captured from before the response is stored. The
+// always sensitive headers and fields are masked when results are read, so
+// leak detection still finds them.
+func (s *TestRunService) redactResponse(responseData string, service models.Service) string {
+	rules := []models.RedactionRules{s.redaction, service.Redaction}
+	if !hasRedactionRules(rules) || responseData == "" {
+		return responseData
+	}
+	var data map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(responseData))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return responseData
+	}
+	encoded, err := json.Marshal(maskResponseData(data, false, rules))
+	if err != nil {
+		return responseData
+	}
+	return string(encoded)
+}
+
+// hasRedactionRules reports whether any rule masks a header or path
+func hasRedactionRules(rules []models.RedactionRules) bool {
+	for _, rule := range rules {
+		if len(rule.Headers) > 0 || len(rule.Paths) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// redactsHeader reports whether a rule masks a header, case-insensitively
+func redactsHeader(rules []models.RedactionRules, name string) bool {
+	for _, rule := range rules {
+		for _, header := range rule.Headers {
+			if strings.EqualFold(header, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pathSegments splits a dotted body path, e.g. patient.ssn; the body.
+// prefix is optional
+func pathSegments(path string) []string {
+	return strings.Split(strings.TrimPrefix(path, "body."), ".")
+}
+
+// redactPath masks the values at a path in a decoded JSON value. A * segment
+// matches every member or element; arrays are descended into for segments
+// that are not an index, so patient.ssn also masks the ssn of a list of
+// patients.
+func redactPath(value interface{}, segments []string) interface{} {
+	if len(segments) == 0 {
+		return redactedValue
+	}
+	segment := segments[0]
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if segment == "*" || key == segment {
+				v[key] = redactPath(item, segments[1:])
+			}
+		}
+	case []interface{}:
+		if index, err := strconv.Atoi(segment); err == nil {
+			if index >= 0 && index < len(v) {
+				v[index] = redactPath(v[index], segments[1:])
+			}
+			return v
+		}
+		rest := segments
+		if segment == "*" {
+			rest = segments[1:]
+		}
+		for i, item := range v {
+			v[i] = redactPath(item, rest)
+		}
+	}
+	return value
+}
+
+// validateRedactionRules checks that the rules name headers and paths
+func validateRedactionRules(rules models.RedactionRules) error {
+	for _, header := range rules.Headers {
+		if strings.TrimSpace(header) == "" {
+			return fmt.Errorf("%w: redaction headers must not be empty", ErrInvalidService)
+		}
+	}
+	for _, path := range rules.Paths {
+		for _, segment := range pathSegments(path) {
+			if segment == "" {
+				return fmt.Errorf("%w: redaction path '%s' has an empty segment", ErrInvalidService, path)
+			}
+		}
+	}
+	return nil
+}
+
+// redactValue masks sensitive fields anywhere in a decoded JSON value
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if sensitiveFields[strings.ToLower(key)] {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactValue(item)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// redactSecrets replaces the auth secrets of a service, of the auth override
+// of a spec and the other resolved secrets wherever they appear in captured
+// response data, e.g. when a service echoes the request headers
+func redactSecrets(responseData string, service models.Service, testSpec *models.TestSpec, resolved ...string) string {
+	values := append(service.AuthConfig.Secrets(), resolved...)
+	if testSpec.Request.Auth != nil {
+		values = append(values, testSpec.Request.Auth.Secrets()...)
+	}
+	for _, value := range values {
+		if len(value) < minRedactedSecret {
+			continue
+		}
+		responseData = strings.ReplaceAll(responseData, value, redactedValue)
+		// Inside JSON strings the value may be escaped
+		if encoded, err := json.Marshal(value); err == nil {
+			if escaped := string(encoded[1 : len(encoded)-1]); escaped != value {
+				responseData = strings.ReplaceAll(responseData, escaped, redactedValue)
+			}
+		}
+	}
+	return responseData
+}
@@ -0,0 +1,452 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	// regionWorkersKey is a sorted set of regions scored by the last heartbeat of their workers
+	regionWorkersKey = "regions:workers"
+	// poolWorkersKey is a sorted set of egress pools scored by the last heartbeat of their workers
+	poolWorkersKey = "pools:workers"
+	// regionHeartbeat is how often a worker announces its region
+	regionHeartbeat = 10 * time.Second
+	// regionWorkerTTL is how long a region stays live without a heartbeat
+	regionWorkerTTL = 3 * regionHeartbeat
+	// regionPollTimeout bounds a single wait for jobs, so shutdown is noticed
+	regionPollTimeout = 5 * time.Second
+	// regionWorkerConcurrency caps the jobs a worker executes in parallel
+	regionWorkerConcurrency = 16
+)
+
+// ErrNoRegionWorker is returned when no worker serves a region a run requires
+var ErrNoRegionWorker = errors.New("no worker available in region")
+
+// ErrNoPoolWorker is returned when no worker serves the egress pool a test
+// is sent from
+var ErrNoPoolWorker = errors.New("no worker available in egress pool")
+
+// regionJob is a test execution dispatched to the workers of a region. The
+// spec is resolved except for its secret references; the worker resolves
+// them and adds the auth of the service.
+// Jobs with Diagnose run connectivity diagnostics instead. Jobs with a Pool
+// are dispatched to the workers of the egress pool instead of the region.
+type regionJob struct {
+	ID         string             `json:"id"`
+	TestRunID  string             `json:"test_run_id,omitempty"` // run of the test, for the queue status
+	Pool       string             `json:"pool,omitempty"`
+	ServiceID  string             `json:"service_id"`
+	BaseURL    string             `json:"base_url"`
+	APIVersion string             `json:"api_version,omitempty"`
+	Spec       models.TestSpec    `json:"spec"`
+	Retry      models.RetryPolicy `json:"retry"`
+	Deadline   time.Time          `json:"deadline"`
+	Diagnose   *diagnoseJob       `json:"diagnose,omitempty"`
+}
+
+// diagnoseJob is the target of connectivity diagnostics run by a worker
+type diagnoseJob struct {
+	URL string           `json:"url"`
+	TLS models.TLSConfig `json:"tls"`
+}
+
+// regionJobsKey returns the Redis list holding the pending jobs of a region
+func regionJobsKey(region string) string {
+	return fmt.Sprintf("regions:%s:jobs", region)
+}
+
+// poolJobsKey returns the Redis list holding the pending jobs of an egress pool
+func poolJobsKey(pool string) string {
+	return fmt.Sprintf("pools:%s:jobs", pool)
+}
+
+// regionResultKey returns the Redis list the result of a job is pushed to
+func regionResultKey(jobID string) string {
+	return fmt.Sprintf("regions:jobs:%s:result", jobID)
+}
+
+// SetRegion sets the region this instance executes tests from. Tests of
+// services in other regions are dispatched to workers of those regions.
+func (s *TestRunService) SetRegion(region string) {
+	s.region = region
+}
+
+// SetPool sets the egress pool of this instance, whose outbound connections
+// are routed with testrunner.SetEgress. Its region worker then executes the
+// tests sent from the pool instead of the tests of its region.
+func (s *TestRunService) SetPool(pool string) {
+	s.pool = pool
+}
+
+// LiveRegions lists the regions with a worker that sent a heartbeat recently,
+// including the region of this instance
+func (s *TestRunService) LiveRegions(ctx context.Context) ([]string, error) {
+	seen := map[string]bool{}
+	if s.region != "" {
+		seen[s.region] = true
+	}
+	if s.redisClient != nil {
+		since := strconv.FormatInt(time.Now().Add(-regionWorkerTTL).Unix(), 10)
+		regions, err := s.redisClient.ZRangeByScore(ctx, regionWorkersKey, &redis.ZRangeBy{Min: since, Max: "+inf"}).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, region := range regions {
+			seen[region] = true
+		}
+	}
+
+	regions := make([]string, 0, len(seen))
+	for region := range seen {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
+
+// routeRegion picks the region a test targeting a region executes from: the
+// region itself when it has a worker, otherwise a live region of the same
+// area (e.g. eu-central-1 for eu-west-1). An empty region means this instance.
+// With exact set only the region itself is acceptable.
+func (s *TestRunService) routeRegion(ctx context.Context, target string, exact bool) (string, error) {
+	if target == "" || target == s.region {
+		return s.region, nil
+	}
+
+	live, err := s.LiveRegions(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, region := range live {
+		if region == target {
+			return region, nil
+		}
+	}
+	if exact {
+		return "", fmt.Errorf("%w %s", ErrNoRegionWorker, target)
+	}
+
+	// The local region wins among the regions of the same area
+	area := regionArea(target)
+	if s.region != "" && regionArea(s.region) == area {
+		return s.region, nil
+	}
+	for _, region := range live {
+		if regionArea(region) == area {
+			return region, nil
+		}
+	}
+	return s.region, nil
+}
+
+// routePool checks that a test sent from an egress pool can execute: on this
+// instance when it belongs to the pool, otherwise on a live worker of it
+func (s *TestRunService) routePool(ctx context.Context, pool string) error {
+	if pool == s.pool {
+		return nil
+	}
+	if s.redisClient != nil {
+		lastHeartbeat, err := s.redisClient.ZScore(ctx, poolWorkersKey, pool).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err == nil && int64(lastHeartbeat) >= time.Now().Add(-regionWorkerTTL).Unix() {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %s", ErrNoPoolWorker, pool)
+}
+
+// regionArea returns the geographic area of a region, e.g. "eu" for "eu-west-1"
+func regionArea(region string) string {
+	if idx := strings.Index(region, "-"); idx >= 0 {
+		return region[:idx]
+	}
+	return region
+}
+
+// executeInRegion dispatches a resolved test to the workers of a region and
+// waits for its result until ctx is done
+func (s *TestRunService) executeInRegion(ctx context.Context, region string, job regionJob) (*testrunner.TestResult, error) {
+	payload, err := s.dispatchRegionJob(ctx, region, job)
+	if err != nil {
+		return nil, err
+	}
+	var result testrunner.TestResult
+	if err := json.Unmarshal(payload, &result); err != nil {
+		return nil, fmt.Errorf("invalid result of test %s: %v", job.Spec.Name, err)
+	}
+	return &result, nil
+}
+
+// dispatchRegionJob pushes a job to the workers of a region, or of the
+// egress pool of the job, and waits for its encoded result until ctx is done
+func (s *TestRunService) dispatchRegionJob(ctx context.Context, region string, job regionJob) ([]byte, error) {
+	queue, target := regionJobsKey(region), "region "+region
+	if job.Pool != "" {
+		queue, target = poolJobsKey(job.Pool), "egress pool "+job.Pool
+	}
+	job.ID = uuid.New().String()
+	if deadline, ok := ctx.Deadline(); ok {
+		job.Deadline = deadline
+	}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisClient.LPush(ctx, queue, payload).Err(); err != nil {
+		return nil, fmt.Errorf("failed to dispatch test to %s: %v", target, err)
+	}
+
+	wait := defaultTestTimeout
+	if !job.Deadline.IsZero() {
+		// A zero timeout would block forever
+		if wait = time.Until(job.Deadline); wait <= 0 {
+			return nil, fmt.Errorf("%w: no result from %s", context.DeadlineExceeded, target)
+		}
+	}
+	values, err := s.redisClient.BLPop(ctx, wait, regionResultKey(job.ID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("%w: no result from %s", context.DeadlineExceeded, target)
+		}
+		return nil, fmt.Errorf("failed to receive result from %s: %v", target, err)
+	}
+	return []byte(values[1]), nil
+}
+
+// workerService returns a worker service logging to the logger of the service
+func (s *TestRunService) workerService() *WorkerService {
+	workers := NewWorkerService(s.db, s.redisClient)
+	workers.SetLogger(s.logger)
+	return workers
+}
+
+// SetWorkerIdleExit makes RunRegionWorker return once no job arrived for the
+// given duration, for ephemeral workers started on demand. Zero keeps the
+// worker running.
+func (s *TestRunService) SetWorkerIdleExit(idle time.Duration) {
+	s.workerIdleExit = idle
+}
+
+// RunRegionWorker executes the tests dispatched to the region of this
+// instance until ctx is done, registering as worker of the region with
+// heartbeats reporting its load. Instances of an egress pool execute the
+// tests sent from the pool instead. It does nothing without a region or Redis.
+func (s *TestRunService) RunRegionWorker(ctx context.Context) {
+	if s.region == "" || s.redisClient == nil {
+		return
+	}
+
+	workers := s.workerService()
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		name = uuid.New().String()
+	}
+	registration := WorkerRegistration{Name: name, Region: s.region, Pool: s.pool, Capacity: regionWorkerConcurrency}
+	queue := regionJobsKey(s.region)
+	if s.pool != "" {
+		queue = poolJobsKey(s.pool)
+	}
+	worker, err := workers.Register(ctx, registration)
+	if err != nil {
+		s.logger.Error("region worker failed to register", "region", s.region, "error", err)
+		return
+	}
+	defer workers.Deregister(context.Background(), worker.ID)
+
+	slots := make(chan struct{}, regionWorkerConcurrency)
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go func() {
+		ticker := time.NewTicker(regionHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-heartbeatCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			_, err := workers.Heartbeat(heartbeatCtx, worker.ID, len(slots))
+			if errors.Is(err, ErrWorkerNotFound) {
+				// Pruned while unreachable
+				_, err = workers.Register(heartbeatCtx, registration)
+			}
+			if err != nil && heartbeatCtx.Err() == nil {
+				s.logger.Warn("region worker heartbeat failed", "region", s.region, "error", err)
+			}
+		}
+	}()
+
+	lastJob := time.Now()
+	for ctx.Err() == nil {
+		slots <- struct{}{}
+		values, err := s.redisClient.BRPop(ctx, regionPollTimeout, queue).Result()
+		if err != nil {
+			<-slots
+			if err != redis.Nil && ctx.Err() == nil {
+				s.logger.Warn("region worker failed to receive jobs", "region", s.region, "error", err)
+				time.Sleep(time.Second)
+			}
+			if err == redis.Nil && s.workerIdleExit > 0 && len(slots) == 0 && time.Since(lastJob) >= s.workerIdleExit {
+				s.logger.Info("region worker idle, exiting", "region", s.region, "idle", s.workerIdleExit)
+				return
+			}
+			continue
+		}
+		lastJob = time.Now()
+
+		var job regionJob
+		if err := json.Unmarshal([]byte(values[1]), &job); err != nil {
+			<-slots
+			s.logger.Warn("region worker received an invalid job", "region", s.region, "error", err)
+			continue
+		}
+		go func() {
+			defer func() { <-slots }()
+			s.executeRegionJob(ctx, job)
+		}()
+	}
+}
+
+// executeRegionJob executes a dispatched test and pushes its result back
+func (s *TestRunService) executeRegionJob(ctx context.Context, job regionJob) {
+	jobCtx := ctx
+	if !job.Deadline.IsZero() {
+		if time.Now().After(job.Deadline) {
+			// The dispatcher stopped waiting already
+			return
+		}
+		var cancel context.CancelFunc
+		jobCtx, cancel = context.WithDeadline(ctx, job.Deadline)
+		defer cancel()
+	}
+
+	if job.Diagnose != nil {
+		diagnosis := testrunner.Diagnose(jobCtx, job.Diagnose.URL, job.Diagnose.TLS)
+		diagnosis.Region = s.region
+		diagnosis.Pool = s.pool
+		s.returnRegionResult(job.ID, diagnosis)
+		return
+	}
+
+	var result *testrunner.TestResult
+	var service models.Service
+	if err := s.db.WithContext(jobCtx).First(&service, "id = ?", job.ServiceID).Error; err != nil {
+		result = &testrunner.TestResult{TestName: job.Spec.Name, Status: "FAILED", ErrorMessage: fmt.Sprintf("service %s not found: %v", job.ServiceID, err), FailureType: testrunner.FailureSpec}
+	} else if spec, resolved, err := s.resolveSpecSecrets(jobCtx, &job.Spec); err != nil {
+		result = &testrunner.TestResult{TestName: job.Spec.Name, Status: "FAILED", ErrorMessage: err.Error(), FailureType: testrunner.FailureSpec}
+	} else if executor, err := s.newExecutor(jobCtx, job.Spec.Protocol, service, job.BaseURL, job.APIVersion); err != nil {
+		result = &testrunner.TestResult{TestName: job.Spec.Name, Status: "FAILED", ErrorMessage: err.Error(), FailureType: testrunner.FailureSpec}
+	} else {
+		// Secrets resolved by the worker never travel back
+		result = testrunner.ExecuteWithRetry(jobCtx, executor, spec, job.Retry)
+		result.ResponseData = redactSecrets(result.ResponseData, s.withResolvedAuth(jobCtx, service), spec, resolved...)
+	}
+	s.returnRegionResult(job.ID, result)
+}
+
+// returnRegionResult pushes the result of a job back to its dispatcher
+func (s *TestRunService) returnRegionResult(jobID string, result interface{}) {
+	payload, err := json.Marshal(result)
+	if err != nil {
+		s.logger.Error("region worker failed to encode job result", "job_id", jobID, "error", err)
+		return
+	}
+	key := regionResultKey(jobID)
+	pipe := s.redisClient.TxPipeline()
+	pipe.LPush(context.Background(), key, payload)
+	pipe.Expire(context.Background(), key, regionWorkerTTL)
+	if _, err := pipe.Exec(context.Background()); err != nil {
+		s.logger.Error("region worker failed to return job result", "job_id", jobID, "error", err)
+	}
+}
+
+// RegionTiming is the outcome of a test case executed from one region
+type RegionTiming struct {
+	Status          string `json:"status"`
+	ExecutionTimeMs int    `json:"execution_time_ms"`
+	FailureType     string `json:"failure_type,omitempty"`
+}
+
+// RegionComparison compares the executions of a test case from several regions
+type RegionComparison struct {
+	TestCaseID    string                  `json:"test_case_id"`
+	TestName      string                  `json:"test_name"`
+	APIVersion    string                  `json:"api_version,omitempty"`
+	Iteration     int                     `json:"iteration,omitempty"`
+	Regions       map[string]RegionTiming `json:"regions"`
+	FastestRegion string                  `json:"fastest_region,omitempty"`
+	SlowestRegion string                  `json:"slowest_region,omitempty"`
+	SpreadMs      int                     `json:"spread_ms"` // slowest minus fastest passed execution
+}
+
+// CompareRegions compares the latency of every test case of a run across the
+// regions it executed from. Only passed executions take part in the fastest,
+// slowest and spread figures.
+func (s *TestRunService) CompareRegions(ctx context.Context, testRunID string) ([]RegionComparison, error) {
+	var testResults []models.TestResult
+	if err := s.reader.WithContext(ctx).Preload("TestCase").Where("test_run_id = ?", testRunID).Order("position").Find(&testResults).Error; err != nil {
+		return nil, err
+	}
+
+	comparisons := []RegionComparison{}
+	index := map[string]int{}
+	for _, testResult := range testResults {
+		key := fmt.Sprintf("%s|%s|%d", testResult.TestCaseID, testResult.APIVersion, testResult.Iteration)
+		i, ok := index[key]
+		if !ok {
+			i = len(comparisons)
+			index[key] = i
+			comparisons = append(comparisons, RegionComparison{
+				TestCaseID: testResult.TestCaseID,
+				TestName:   testResult.TestCase.Name,
+				APIVersion: testResult.APIVersion,
+				Iteration:  testResult.Iteration,
+				Regions:    map[string]RegionTiming{},
+			})
+		}
+		region := testResult.Region
+		if region == "" {
+			region = "local"
+		}
+		comparisons[i].Regions[region] = RegionTiming{
+			Status:          testResult.Status,
+			ExecutionTimeMs: testResult.ExecutionTimeMs,
+			FailureType:     testResult.FailureType,
+		}
+	}
+
+	for i := range comparisons {
+		comparison := &comparisons[i]
+		for region, timing := range comparison.Regions {
+			if timing.Status != "passed" {
+				continue
+			}
+			if comparison.FastestRegion == "" || timing.ExecutionTimeMs < comparison.Regions[comparison.FastestRegion].ExecutionTimeMs {
+				comparison.FastestRegion = region
+			}
+			if comparison.SlowestRegion == "" || timing.ExecutionTimeMs > comparison.Regions[comparison.SlowestRegion].ExecutionTimeMs {
+				comparison.SlowestRegion = region
+			}
+		}
+		if comparison.FastestRegion != "" {
+			comparison.SpreadMs = comparison.Regions[comparison.SlowestRegion].ExecutionTimeMs - comparison.Regions[comparison.FastestRegion].ExecutionTimeMs
+		}
+	}
+	return comparisons, nil
+}
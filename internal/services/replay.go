@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+
+	"gorm.io/gorm"
+)
+
+// ErrReplayUnavailable is returned when a result has no captured request to replay
+var ErrReplayUnavailable = errors.New("result has no captured request to replay")
+
+// ReplayOutcome summarises one execution of a replayed request
+type ReplayOutcome struct {
+	Status          string      `json:"status"`
+	StatusCode      int         `json:"status_code,omitempty"`
+	ErrorMessage    string      `json:"error_message,omitempty"`
+	FailureType     string      `json:"failure_type,omitempty"`
+	FailureDetail   string      `json:"failure_detail,omitempty"`
+	ExecutionTimeMs int         `json:"execution_time_ms"`
+	ResponseData    interface{} `json:"response_data,omitempty"`
+}
+
+// AssertionChange reports an assertion whose outcome differs between the
+// original result and the replay
+type AssertionChange struct {
+	Position  int    `json:"position"`
+	Variant   string `json:"variant,omitempty"`
+	Type      string `json:"type"`
+	Path      string `json:"path,omitempty"`
+	WasPassed bool   `json:"was_passed"`
+	Passed    bool   `json:"passed"`
+	Message   string `json:"message,omitempty"`
+}
+
+// ReplayComparison compares a stored result with a fresh execution of its request
+type ReplayComparison struct {
+	TestResultID     string                   `json:"test_result_id"`
+	TestCaseID       string                   `json:"test_case_id"`
+	EnvironmentID    string                   `json:"environment_id,omitempty"`
+	BaseURL          string                   `json:"base_url"`
+	APIVersion       string                   `json:"api_version,omitempty"`
+	Request          models.RequestSpec       `json:"request"`
+	Original         ReplayOutcome            `json:"original"`
+	Replay           ReplayOutcome            `json:"replay"`
+	Reproduced       bool                     `json:"reproduced"` // the original failure occurred again
+	StatusChanged    bool                     `json:"status_changed"`
+	AssertionChanges []AssertionChange        `json:"assertion_changes"`
+	Assertions       []models.AssertionResult `json:"assertions"`
+}
+
+// ReplayResult re-executes the request captured in a test result, with the
+// same resolved headers and body, and compares the outcome with the stored
+// result. An environment ID sends the request to the base URL that environment
+// resolves for the service instead of the original one. Nothing is persisted.
+func (s *TestRunService) ReplayResult(ctx context.Context, resultID, environmentID string) (*ReplayComparison, error) {
+	db := s.db.WithContext(ctx)
+
+	var testResult models.TestResult
+	if err := db.Preload("TestCase.Service").Preload("AssertionResults", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).First(&testResult, "id = ?", resultID).Error; err != nil {
+		return nil, err
+	}
+	if !testResult.Request.Captured() {
+		return nil, ErrReplayUnavailable
+	}
+
+	snapshot := testResult.Request
+	service := testResult.TestCase.Service
+	baseURL := snapshot.BaseURL
+	if environmentID != "" {
+		var environment models.Environment
+		if err := db.First(&environment, "id = ?", environmentID).Error; err != nil {
+			return nil, fmt.Errorf("environment not found: %v", err)
+		}
+		var testRun models.TestRun
+		if err := db.Select("variable_overrides").First(&testRun, "id = ?", testResult.TestRunID).Error; err != nil {
+			return nil, fmt.Errorf("test run not found: %v", err)
+		}
+		baseURL = s.resolveServiceVariables(ctx, service, &environment, testRun.VariableOverrides)["base_url"].Value
+	}
+
+	// The snapshot keeps secret references, which resolve to current values
+	spec := snapshot.TestSpec
+	executedSpec, resolved, err := s.resolveSpecSecrets(ctx, &spec)
+	if err != nil {
+		return nil, err
+	}
+	executor, err := s.newExecutor(ctx, spec.Protocol, service, baseURL, snapshot.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	result := executeSpec(ctx, executor, executedSpec, testTimeoutFor(spec.TimeoutMs, 0, service.TimeoutMs))
+	result.ResponseData = redactSecrets(result.ResponseData, s.withResolvedAuth(ctx, service), executedSpec, resolved...)
+	result.ResponseData = s.redactResponse(result.ResponseData, service)
+
+	replayStatus := "passed"
+	if result.Status == "FAILED" {
+		replayStatus = "failed"
+	}
+	assertions := assertionRecords(result)
+
+	comparison := &ReplayComparison{
+		TestResultID:  testResult.ID,
+		TestCaseID:    testResult.TestCaseID,
+		EnvironmentID: environmentID,
+		BaseURL:       baseURL,
+		APIVersion:    snapshot.APIVersion,
+		Request:       redactRequest(snapshot.TestSpec.Request),
+		Original: ReplayOutcome{
+			Status:          testResult.Status,
+			StatusCode:      capturedStatusCode(testResult.ResponseData),
+			ErrorMessage:    testResult.ErrorMessage,
+			FailureType:     testResult.FailureType,
+			FailureDetail:   testResult.FailureDetail,
+			ExecutionTimeMs: testResult.ExecutionTimeMs,
+		},
+		Replay: ReplayOutcome{
+			Status:          replayStatus,
+			StatusCode:      capturedStatusCode(result.ResponseData),
+			ErrorMessage:    result.ErrorMessage,
+			FailureType:     result.FailureType,
+			FailureDetail:   result.FailureDetail,
+			ExecutionTimeMs: int(result.Duration.Milliseconds()),
+			ResponseData:    redactedResponse(result.ResponseData),
+		},
+		Reproduced:       (testResult.Status == "failed" || testResult.Status == "timed_out") && replayStatus == "failed",
+		StatusChanged:    testResult.Status != replayStatus,
+		AssertionChanges: assertionChanges(testResult.AssertionResults, assertions),
+		Assertions:       assertions,
+	}
+	return comparison, nil
+}
+
+// executeSpec executes a test spec outside of a run within timeout; a failing
+// request surfaces as a panic of the assert reporter and is reported as a failure
+func executeSpec(ctx context.Context, executor testrunner.Executor, spec *models.TestSpec, timeout time.Duration) (result *testrunner.TestResult) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			result = &testrunner.TestResult{
+				TestName:     spec.Name,
+				Status:       "FAILED",
+				ErrorMessage: fmt.Sprintf("request failed: %v", r),
+				FailureType:  testrunner.FailureInternal,
+			}
+		}
+	}()
+	return executor.ExecuteTest(ctx, spec)
+}
+
+// assertionChanges matches assertions by variant and position and lists those
+// whose outcome changed
+func assertionChanges(original, replayed []models.AssertionResult) []AssertionChange {
+	type key struct {
+		variant string
+		index   int
+	}
+	index := func(assertions []models.AssertionResult) map[key]models.AssertionResult {
+		indexed := make(map[key]models.AssertionResult, len(assertions))
+		counts := make(map[string]int)
+		for _, assertion := range assertions {
+			indexed[key{assertion.Variant, counts[assertion.Variant]}] = assertion
+			counts[assertion.Variant]++
+		}
+		return indexed
+	}
+
+	previous := index(original)
+	changes := []AssertionChange{}
+	counts := make(map[string]int)
+	for _, assertion := range replayed {
+		k := key{assertion.Variant, counts[assertion.Variant]}
+		counts[assertion.Variant]++
+		before, ok := previous[k]
+		if !ok || before.Passed == assertion.Passed {
+			continue
+		}
+		changes = append(changes, AssertionChange{
+			Position:  assertion.Position,
+			Variant:   assertion.Variant,
+			Type:      assertion.Type,
+			Path:      assertion.Path,
+			WasPassed: before.Passed,
+			Passed:    assertion.Passed,
+			Message:   assertion.Message,
+		})
+	}
+	return changes
+}
+
+// capturedStatusCode returns the status code of captured response data;
+// matrix tests report the first variant's status code
+func capturedStatusCode(responseData string) int {
+	if codes := observedStatusCodes(responseData); len(codes) > 0 {
+		return codes[0]
+	}
+	return 0
+}
+
+// redactedResponse decodes and redacts captured response data for display,
+// also masking the headers and paths of the rules
+func redactedResponse(responseData string, rules ...models.RedactionRules) interface{} {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(responseData), &data); err != nil {
+		return nil
+	}
+	return redactResponseData(data, rules...)
+}
+
+// redactRequest masks sensitive headers and body fields of a replayed request
+func redactRequest(request models.RequestSpec) models.RequestSpec {
+	redacted := request
+	redacted.Headers = make(map[string]string, len(request.Headers))
+	for name, value := range request.Headers {
+		if sensitiveHeaders[strings.ToLower(name)] {
+			value = redactedValue
+		}
+		redacted.Headers[name] = value
+	}
+	if request.Body != nil {
+		if data, err := json.Marshal(request.Body); err == nil {
+			var body interface{}
+			if json.Unmarshal(data, &body) == nil {
+				redacted.Body = redactValue(body)
+			}
+		}
+	}
+	redacted.Auth = nil
+	return redacted
+}
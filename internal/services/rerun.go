@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"api-test-framework/internal/models"
+)
+
+// ErrNoFailedTests is returned when re-running a run none of whose test cases failed
+var ErrNoFailedTests = errors.New("no failed test cases to re-run")
+
+// RerunOptions overrides the configuration a re-run inherits from its run
+type RerunOptions struct {
+	Name          string            `json:"name"`
+	EnvironmentID string            `json:"environment_id"`
+	Variables     map[string]string `json:"variables"`
+}
+
+// RerunFailed starts a run executing only the test cases that failed or
+// timed out in a finished run, with the run's configuration, and links it
+// to that run through rerun_of_id. Failed test cases of a suite run are
+// re-run within the suite, so its setup and teardown steps still apply.
+func (s *TestRunService) RerunFailed(ctx context.Context, testRunID string, opts RerunOptions) (*models.TestRun, error) {
+	db := s.db.WithContext(ctx)
+
+	var testRun models.TestRun
+	if err := db.Omit("debug_log", "security_findings").First(&testRun, "id = ?", testRunID).Error; err != nil {
+		return nil, err
+	}
+	if testRun.Status == "running" {
+		return nil, fmt.Errorf("%w: failed test cases are known once the run finishes", ErrRunNotFinished)
+	}
+
+	var failedIDs []string
+	err := db.Model(&models.TestResult{}).Distinct("test_case_id").
+		Where("test_run_id = ? AND status IN ?", testRunID, []string{"failed", "timed_out"}).
+		Pluck("test_case_id", &failedIDs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve failed test cases: %v", err)
+	}
+	if len(failedIDs) == 0 {
+		return nil, ErrNoFailedTests
+	}
+
+	rerun := StartTestRunOptions{
+		TestIDs:         failedIDs,
+		Name:            opts.Name,
+		Variables:       testRun.VariableOverrides,
+		MaxConcurrency:  testRun.MaxConcurrency,
+		APIVersions:     testRun.APIVersions,
+		LatencyBudgetMs: testRun.LatencyBudgetMs,
+		Regions:         testRun.Regions,
+		TestTimeoutMs:   testRun.TestTimeoutMs,
+		RunTimeoutMs:    testRun.RunTimeoutMs,
+		RerunOf:         testRun.ID,
+	}
+	if rerun.Name == "" {
+		rerun.Name = "Re-run of failed tests of " + testRun.Name
+	}
+	if testRun.EnvironmentID != nil {
+		rerun.EnvironmentID = *testRun.EnvironmentID
+	}
+	if opts.EnvironmentID != "" {
+		rerun.EnvironmentID = opts.EnvironmentID
+	}
+	if opts.Variables != nil {
+		rerun.Variables = opts.Variables
+	}
+	if testRun.RetryPolicy.MaxAttempts > 0 {
+		policy := testRun.RetryPolicy
+		rerun.Retry = &policy
+	}
+
+	if testRun.SuiteID != nil {
+		var suite models.TestSuite
+		// Suites deleted or edited since run the failed test cases on their own
+		if err := db.First(&suite, "id = ?", *testRun.SuiteID).Error; err == nil {
+			failed := make(map[string]bool, len(failedIDs))
+			for _, id := range failedIDs {
+				failed[id] = true
+			}
+			// The suite keeps its order and steps, restricted to the failed
+			// test cases and the earlier test cases exporting variables they
+			// may depend on
+			last := -1
+			for i, id := range suite.TestIDs {
+				if failed[id] {
+					last = i
+				}
+			}
+			var testIDs models.StringList
+			for i, id := range suite.TestIDs {
+				if failed[id] || (i < last && len(suite.TestExports[id]) > 0) {
+					testIDs = append(testIDs, id)
+				}
+			}
+			if len(testIDs) > 0 {
+				suite.TestIDs = testIDs
+				rerun.Suite = &suite
+			}
+		}
+	}
+
+	return s.StartTestRun(ctx, rerun)
+}
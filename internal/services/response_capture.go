@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrResponseUnavailable is returned for an offloaded response body that
+// cannot be read from the response store
+var ErrResponseUnavailable = errors.New("offloaded response body is unavailable")
+
+// responseStoreTimeout bounds a single transfer from or to the response store
+const responseStoreTimeout = time.Minute
+
+// ResponseStore keeps large response bodies outside the database, e.g. in
+// object storage
+type ResponseStore interface {
+	Put(ctx context.Context, key string, content []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// ResponseCapture configures how response bodies larger than MaxBodyBytes
+// are stored: offloaded to Store when set, else gzipped when Compress is
+// set and that makes them fit, else truncated. Bodies are gzipped in the
+// store as well when Compress is set.
+type ResponseCapture struct {
+	MaxBodyBytes int // 0 stores bodies as they are
+	Compress     bool
+	Store        ResponseStore
+}
+
+// bodyCapture is stored as body_capture in the response data of a result
+// whose body was not stored as it is. The status code and headers are kept.
+type bodyCapture struct {
+	SizeBytes   int    `json:"size_bytes"`            // size of the body as JSON
+	Truncated   bool   `json:"truncated,omitempty"`   // body holds the beginning of the body as text
+	Encoding    string `json:"encoding,omitempty"`    // gzip when the data or object is compressed
+	Data        string `json:"data,omitempty"`        // base64 of the compressed body
+	StorageKey  string `json:"storage_key,omitempty"` // object of the body in the response store
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// SetResponseCapture sets how large response bodies are stored
+func (s *TestRunService) SetResponseCapture(capture ResponseCapture) {
+	s.responseCapture = capture
+}
+
+// captureBody returns the response data of a result to store, with a body
+// above the size limit offloaded, compressed or truncated
+func (s *TestRunService) captureBody(testRunID, resultID, responseData string) string {
+	limit := s.responseCapture.MaxBodyBytes
+	if limit <= 0 || len(responseData) <= limit {
+		return responseData
+	}
+	var data map[string]json.RawMessage
+	if json.Unmarshal([]byte(responseData), &data) != nil {
+		return responseData
+	}
+	body, ok := data["body"]
+	if !ok || len(body) <= limit {
+		return responseData
+	}
+
+	capture := bodyCapture{SizeBytes: len(body)}
+	content := []byte(body)
+	if s.responseCapture.Compress {
+		if compressed, err := gzipBytes(content); err == nil {
+			content, capture.Encoding = compressed, "gzip"
+		}
+	}
+
+	switch {
+	case s.responseCapture.Store != nil:
+		ctx, cancel := context.WithTimeout(context.Background(), responseStoreTimeout)
+		defer cancel()
+		key := fmt.Sprintf("responses/%s/%s", testRunID, resultID)
+		contentType := "application/json"
+		if capture.Encoding == "gzip" {
+			contentType = "application/gzip"
+		}
+		if err := s.responseCapture.Store.Put(ctx, key, content, contentType); err != nil {
+			s.runLogger(ctx).Warn("failed to offload response body, truncating it", "test_result_id", resultID, "error", err)
+			truncateBody(data, &capture, body, limit)
+			break
+		}
+		capture.StorageKey = key
+		capture.DownloadURL = "/api/v1/results/" + resultID + "/response"
+		delete(data, "body")
+	case capture.Encoding == "gzip" && base64.StdEncoding.EncodedLen(len(content)) <= limit:
+		capture.Data = base64.StdEncoding.EncodeToString(content)
+		delete(data, "body")
+	default:
+		truncateBody(data, &capture, body, limit)
+	}
+
+	data["body_capture"], _ = json.Marshal(capture)
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return responseData
+	}
+	return string(encoded)
+}
+
+// truncateBody keeps the first limit bytes of a body as text
+func truncateBody(data map[string]json.RawMessage, capture *bodyCapture, body json.RawMessage, limit int) {
+	text := string(body)
+	var str string
+	if json.Unmarshal(body, &str) == nil {
+		text = str
+	}
+	if len(text) > limit {
+		text = text[:limit]
+		// Cut at a character boundary
+		for len(text) > 0 && !utf8.ValidString(text) {
+			text = text[:len(text)-1]
+		}
+	}
+	capture.Truncated, capture.Encoding = true, ""
+	data["body"], _ = json.Marshal(text)
+}
+
+// restoreBody returns stored response data with a compressed or offloaded
+// body put back in place; truncated bodies stay truncated
+func (s *TestRunService) restoreBody(ctx context.Context, responseData string) (string, error) {
+	var data map[string]json.RawMessage
+	if json.Unmarshal([]byte(responseData), &data) != nil || data["body_capture"] == nil {
+		return responseData, nil
+	}
+	var capture bodyCapture
+	if err := json.Unmarshal(data["body_capture"], &capture); err != nil || capture.Truncated {
+		return responseData, nil
+	}
+
+	var content []byte
+	switch {
+	case capture.StorageKey != "":
+		if s.responseCapture.Store == nil {
+			return "", fmt.Errorf("%w: no response store is configured", ErrResponseUnavailable)
+		}
+		ctx, cancel := context.WithTimeout(ctx, responseStoreTimeout)
+		defer cancel()
+		stored, err := s.responseCapture.Store.Get(ctx, capture.StorageKey)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrResponseUnavailable, err)
+		}
+		content = stored
+	case capture.Data != "":
+		decoded, err := base64.StdEncoding.DecodeString(capture.Data)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode compressed response body: %v", err)
+		}
+		content = decoded
+	default:
+		return responseData, nil
+	}
+	if capture.Encoding == "gzip" {
+		decompressed, err := gunzipBytes(content)
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress response body: %v", err)
+		}
+		content = decompressed
+	}
+
+	data["body"] = content
+	delete(data, "body_capture")
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// gzipBytes compresses content
+func gzipBytes(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(content); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipBytes decompresses gzipped content
+func gunzipBytes(content []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
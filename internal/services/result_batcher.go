@@ -0,0 +1,167 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// resultBatchSize is the number of buffered test results that triggers a write
+	resultBatchSize = 100
+	// resultFlushInterval bounds how long a recorded result waits to be written
+	resultFlushInterval = time.Second
+	// assertionBatchSize is the number of assertion results per INSERT statement
+	assertionBatchSize = 500
+	// resultWriteAttempts bounds the writes of the remaining results when a run closes
+	resultWriteAttempts = 3
+)
+
+// pendingResult is a recorded test result waiting to be written, with the
+// run event published once it is stored
+type pendingResult struct {
+	result     *models.TestResult
+	assertions []models.AssertionResult
+	event      RunEvent
+}
+
+// resultBatcher buffers the results of a run and writes them in batches, when
+// the buffer is full and periodically, instead of one transaction per test
+type resultBatcher struct {
+	service   *TestRunService
+	testRunID string
+
+	mu      sync.Mutex
+	pending []pendingResult
+
+	stop      chan struct{}
+	stopped   sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// newResultBatcher creates the result buffer of a run and starts its periodic flush
+func (s *TestRunService) newResultBatcher(testRunID string) *resultBatcher {
+	b := &resultBatcher{
+		service:   s,
+		testRunID: testRunID,
+		stop:      make(chan struct{}),
+	}
+
+	b.stopped.Add(1)
+	go func() {
+		defer b.stopped.Done()
+		ticker := time.NewTicker(resultFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-b.stop:
+				return
+			case <-ticker.C:
+				b.flush()
+			}
+		}
+	}()
+	return b
+}
+
+// add buffers a result, writing the buffer once it holds a full batch
+func (b *resultBatcher) add(result pendingResult) {
+	b.mu.Lock()
+	b.pending = append(b.pending, result)
+	full := len(b.pending) >= resultBatchSize
+	b.mu.Unlock()
+
+	if full {
+		b.flush()
+	}
+}
+
+// flush writes the buffered results and publishes their events. A batch that
+// cannot be written is buffered again for the next flush, unless its run was
+// finished elsewhere.
+func (b *resultBatcher) flush() error {
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := b.service.writeResults(b.testRunID, batch); err != nil {
+		b.service.logger.Error("failed to record results", "test_run_id", b.testRunID, "results", len(batch), "error", err)
+		if errors.Is(err, ErrRunNotRunning) {
+			return nil
+		}
+		b.mu.Lock()
+		b.pending = append(batch, b.pending...)
+		b.mu.Unlock()
+		return err
+	}
+	for _, pending := range batch {
+		b.service.publishRunEvent(pending.event)
+	}
+	return nil
+}
+
+// close stops the periodic flush and writes the remaining results, retrying
+// failed writes. It returns an error when results could still not be
+// written, which must fail the run. The run must be closed before it is
+// finished so its counters match its results.
+func (b *resultBatcher) close() error {
+	b.closeOnce.Do(func() {
+		close(b.stop)
+		b.stopped.Wait()
+		for attempt := 1; ; attempt++ {
+			err := b.flush()
+			if err == nil {
+				return
+			}
+			if attempt == resultWriteAttempts {
+				b.mu.Lock()
+				b.closeErr = fmt.Errorf("failed to store %d test results: %v", len(b.pending), err)
+				b.mu.Unlock()
+				return
+			}
+			time.Sleep(resultFlushInterval)
+		}
+	})
+	return b.closeErr
+}
+
+// writeResults inserts a batch of test results and their assertion results in
+// a single transaction. Results of a run finished elsewhere are dropped; the
+// shared lock keeps the run from finishing during the insert.
+func (s *TestRunService) writeResults(testRunID string, batch []pendingResult) error {
+	results := make([]*models.TestResult, 0, len(batch))
+	var assertions []models.AssertionResult
+	for _, pending := range batch {
+		results = append(results, pending.result)
+		assertions = append(assertions, pending.assertions...)
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var run models.TestRun
+		if err := tx.Clauses(clause.Locking{Strength: "SHARE"}).Select("status").First(&run, "id = ?", testRunID).Error; err != nil {
+			return err
+		}
+		if run.Status != "running" {
+			return fmt.Errorf("%w: test run is %s", ErrRunNotRunning, run.Status)
+		}
+
+		if err := tx.Omit("AssertionResults").CreateInBatches(results, resultBatchSize).Error; err != nil {
+			return err
+		}
+		if len(assertions) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&assertions, assertionBatchSize).Error
+	})
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// ErrInvalidVariant is returned when a variant must be selected or does not exist
+var ErrInvalidVariant = errors.New("invalid variant")
+
+// CapturedResponse represents the response payload captured for a test result
+type CapturedResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// GetTestResult retrieves a single test result by ID
+func (s *TestRunService) GetTestResult(ctx context.Context, id string) (*models.TestResult, error) {
+	var testResult models.TestResult
+	if err := s.db.WithContext(ctx).Preload("TestCase").First(&testResult, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &testResult, nil
+}
+
+// GetResultResponse returns the redacted response payload captured for a
+// test result. Results of variant tests require the variant name.
+func (s *TestRunService) GetResultResponse(ctx context.Context, resultID, variant string) (*CapturedResponse, error) {
+	testResult, err := s.GetTestResult(ctx, resultID)
+	if err != nil {
+		return nil, err
+	}
+	if s.isCompacted(ctx, testResult.TestRunID) {
+		return nil, ErrResponseCompacted
+	}
+
+	// Large bodies may be compressed or offloaded
+	responseData, err := s.restoreBody(ctx, testResult.ResponseData)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(responseData), &data); err != nil {
+		return nil, fmt.Errorf("failed to parse captured response: %v", err)
+	}
+
+	if variants, ok := data["variants"]; ok {
+		data, err = selectVariantResponse(variants, variant)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Results stored before the rules of their service changed are masked
+	// as they are read
+	rules := []models.RedactionRules{s.redaction}
+	if testResult.TestCase.ServiceID != "" {
+		var service models.Service
+		if err := s.db.WithContext(ctx).Select("redaction").First(&service, "id = ?", testResult.TestCase.ServiceID).Error; err == nil {
+			rules = append(rules, service.Redaction)
+		}
+	}
+
+	captured := &CapturedResponse{
+		ContentType: "application/json",
+	}
+	json.Unmarshal(data["status_code"], &captured.StatusCode)
+	var headers map[string][]string
+	json.Unmarshal(data["headers"], &headers)
+	for name, values := range headers {
+		if strings.EqualFold(name, "Content-Type") && len(values) > 0 {
+			captured.ContentType = values[0]
+		}
+	}
+
+	captured.Body, err = capturedBody(data["body"], rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode captured body: %v", err)
+	}
+
+	return captured, nil
+}
+
+// selectVariantResponse picks the response data of the named variant
+func selectVariantResponse(variantsData json.RawMessage, name string) (map[string]json.RawMessage, error) {
+	var variants []struct {
+		Name         string                     `json:"name"`
+		ResponseData map[string]json.RawMessage `json:"response_data"`
+	}
+	if err := json.Unmarshal(variantsData, &variants); err != nil {
+		return nil, fmt.Errorf("failed to parse captured variants: %v", err)
+	}
+
+	names := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		names = append(names, variant.Name)
+		if variant.Name == name {
+			if variant.ResponseData == nil {
+				return nil, fmt.Errorf("%w: variant '%s' has no captured response", ErrInvalidVariant, name)
+			}
+			return variant.ResponseData, nil
+		}
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("%w: result has variants, select one of: %s", ErrInvalidVariant, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("%w: unknown variant '%s', expected one of: %s", ErrInvalidVariant, name, strings.Join(names, ", "))
+}
+
+// capturedBody returns the bytes of a stored response body as they were
+// received. JSON bodies are only encoded again when sensitive fields or the
+// paths of the rules are masked; numbers keep their precision then.
+func capturedBody(body json.RawMessage, rules []models.RedactionRules) ([]byte, error) {
+	if len(body) == 0 || string(body) == "null" {
+		return []byte{}, nil
+	}
+	var text string
+	if json.Unmarshal(body, &text) == nil {
+		return []byte(text), nil
+	}
+
+	// Masking changes the decoded value in place, so it is decoded twice
+	original, err := decodeBody(body)
+	if err != nil {
+		return nil, err
+	}
+	masked, err := decodeBody(body)
+	if err != nil {
+		return nil, err
+	}
+	masked = redactResponseData(map[string]interface{}{"body": masked}, rules...)["body"]
+	if reflect.DeepEqual(original, masked) {
+		return body, nil
+	}
+	return json.Marshal(masked)
+}
+
+// decodeBody decodes a JSON body, keeping numbers as they were written
+func decodeBody(body json.RawMessage) (interface{}, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"github.com/tidwall/gjson"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSearch is returned when a search query lacks the required criteria
+var ErrInvalidSearch = errors.New("invalid search")
+
+// searchBatchSize is the number of results scanned per database round trip
+const searchBatchSize = 200
+
+// ResultSearchQuery describes a search over stored response data
+type ResultSearchQuery struct {
+	TestRunID string
+	From      *time.Time
+	To        *time.Time
+	Path      string // gjson path relative to the stored response ({status_code, headers, body})
+	Value     string // expected value at Path; empty matches any existing value
+	Text      string // case-insensitive snippet searched anywhere in the stored response
+	Limit     int
+}
+
+// ResultSearchMatch represents a stored result matching a search
+type ResultSearchMatch struct {
+	TestResultID string    `json:"test_result_id"`
+	TestRunID    string    `json:"test_run_id"`
+	TestCaseID   string    `json:"test_case_id"`
+	Status       string    `json:"status"`
+	CreatedAt    time.Time `json:"created_at"`
+	Path         string    `json:"path,omitempty"`
+	Value        string    `json:"value,omitempty"`
+	Snippet      string    `json:"snippet,omitempty"`
+}
+
+// SearchResults scans stored response data of a run or a date range for a
+// JSON path/value or a text snippet
+func (s *TestRunService) SearchResults(ctx context.Context, query ResultSearchQuery) ([]ResultSearchMatch, error) {
+	if query.TestRunID == "" && query.From == nil && query.To == nil {
+		return nil, fmt.Errorf("%w: a test run or a date range is required", ErrInvalidSearch)
+	}
+	if query.Path == "" && query.Text == "" {
+		return nil, fmt.Errorf("%w: a path or a text snippet is required", ErrInvalidSearch)
+	}
+	if query.Limit <= 0 {
+		query.Limit = 100
+	}
+
+	db := s.reader.WithContext(ctx).Model(&models.TestResult{})
+	if query.TestRunID != "" {
+		db = db.Where("test_run_id = ?", query.TestRunID)
+	}
+	if query.From != nil {
+		db = db.Where("created_at >= ?", *query.From)
+	}
+	if query.To != nil {
+		db = db.Where("created_at <= ?", *query.To)
+	}
+
+	// Narrow the scan in the database before evaluating paths in Go
+	if query.Text != "" {
+		db = db.Where("response_data::text ILIKE ?", "%"+escapeLike(query.Text)+"%")
+	}
+	if query.Value != "" {
+		db = db.Where("response_data::text ILIKE ?", "%"+escapeLike(query.Value)+"%")
+	}
+
+	matches := make([]ResultSearchMatch, 0)
+	var batch []models.TestResult
+	err := db.Order("created_at DESC").FindInBatches(&batch, searchBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, result := range batch {
+			match, ok := matchResult(result, query)
+			if !ok {
+				continue
+			}
+			matches = append(matches, match)
+			if len(matches) >= query.Limit {
+				return errSearchLimitReached
+			}
+		}
+		return nil
+	}).Error
+	if err != nil && err != errSearchLimitReached {
+		return nil, err
+	}
+
+	return matches, nil
+}
+
+// errSearchLimitReached stops batch iteration once enough matches are collected
+var errSearchLimitReached = errors.New("search limit reached")
+
+// matchResult evaluates the search criteria against a single stored result
+func matchResult(result models.TestResult, query ResultSearchQuery) (ResultSearchMatch, bool) {
+	match := ResultSearchMatch{
+		TestResultID: result.ID,
+		TestRunID:    result.TestRunID,
+		TestCaseID:   result.TestCaseID,
+		Status:       result.Status,
+		CreatedAt:    result.CreatedAt,
+	}
+
+	if query.Path != "" {
+		value := gjson.Get(result.ResponseData, query.Path)
+		if !value.Exists() {
+			return match, false
+		}
+		if query.Value != "" && value.String() != query.Value {
+			return match, false
+		}
+		match.Path = query.Path
+		match.Value = value.String()
+	}
+
+	if query.Text != "" {
+		snippet, ok := findSnippet(result.ResponseData, query.Text)
+		if !ok {
+			return match, false
+		}
+		match.Snippet = snippet
+	}
+
+	return match, true
+}
+
+// findSnippet returns the text surrounding the first case-insensitive occurrence of needle
+func findSnippet(haystack, needle string) (string, bool) {
+	const snippetContext = 60
+
+	index := strings.Index(strings.ToLower(haystack), strings.ToLower(needle))
+	if index < 0 {
+		return "", false
+	}
+
+	start := index - snippetContext
+	if start < 0 {
+		start = 0
+	}
+	end := index + len(needle) + snippetContext
+	if end > len(haystack) {
+		end = len(haystack)
+	}
+	return haystack[start:end], true
+}
+
+// escapeLike escapes LIKE wildcards in user input
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// Defaults of the thresholds beyond which a test counts as slower or faster
+const (
+	defaultCompareThresholdPercent = 20
+	defaultCompareThresholdMs      = 50
+)
+
+// maxBodyDifferences limits the differences reported per changed response body
+const maxBodyDifferences = 5
+
+// RunComparisonOptions tunes a run comparison. A passed test counts as slower
+// or faster when its execution time changed by at least ThresholdPercent and
+// ThresholdMs; IgnorePaths lists dotted body paths, such as timestamps, left
+// out of response comparisons.
+type RunComparisonOptions struct {
+	ThresholdPercent float64
+	ThresholdMs      int
+	IgnorePaths      []string
+}
+
+// ComparedRun identifies one side of a run comparison
+type ComparedRun struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// TestComparison is the execution of a test in the base and head runs. A
+// test is identified by its test case, API version, data row and region.
+type TestComparison struct {
+	TestCaseID      string   `json:"test_case_id"`
+	TestName        string   `json:"test_name"`
+	APIVersion      string   `json:"api_version,omitempty"`
+	Iteration       int      `json:"iteration,omitempty"`
+	Region          string   `json:"region,omitempty"`
+	BaseResultID    string   `json:"base_result_id,omitempty"`
+	HeadResultID    string   `json:"head_result_id,omitempty"`
+	BaseStatus      string   `json:"base_status,omitempty"`
+	HeadStatus      string   `json:"head_status,omitempty"`
+	BaseStatusCode  int      `json:"base_status_code,omitempty"`
+	HeadStatusCode  int      `json:"head_status_code,omitempty"`
+	BaseTimeMs      int      `json:"base_time_ms"`
+	HeadTimeMs      int      `json:"head_time_ms"`
+	DeltaMs         int      `json:"delta_ms"`
+	FailureType     string   `json:"failure_type,omitempty"` // of the head result
+	ErrorMessage    string   `json:"error_message,omitempty"`
+	BodyDifferences []string `json:"body_differences,omitempty"`
+}
+
+// RunComparison lists the tests whose outcome, latency or response changed
+// between a base and a head run, such as before and after a deployment
+type RunComparison struct {
+	Base             ComparedRun      `json:"base"`
+	Head             ComparedRun      `json:"head"`
+	ThresholdPercent float64          `json:"threshold_percent"`
+	ThresholdMs      int              `json:"threshold_ms"`
+	NewlyFailed      []TestComparison `json:"newly_failed"`
+	NewlyPassed      []TestComparison `json:"newly_passed"`
+	Slower           []TestComparison `json:"slower"`
+	Faster           []TestComparison `json:"faster"`
+	ResponseChanged  []TestComparison `json:"response_changed"`
+	Added            []TestComparison `json:"added"`   // executed in the head run only
+	Removed          []TestComparison `json:"removed"` // executed in the base run only
+	Unchanged        int              `json:"unchanged"`
+}
+
+// CompareRuns compares two finished runs test by test: tests that failed or
+// timed out in the head run only, tests that passed in the head run only,
+// passed tests whose execution time changed beyond the thresholds, and tests
+// that passed in both runs but received a different status code or body.
+func (s *TestRunService) CompareRuns(ctx context.Context, baseID, headID string, opts RunComparisonOptions) (*RunComparison, error) {
+	if opts.ThresholdPercent <= 0 {
+		opts.ThresholdPercent = defaultCompareThresholdPercent
+	}
+	if opts.ThresholdMs <= 0 {
+		opts.ThresholdMs = defaultCompareThresholdMs
+	}
+
+	base, baseResults, err := s.comparedRun(ctx, baseID)
+	if err != nil {
+		return nil, err
+	}
+	head, headResults, err := s.comparedRun(ctx, headID)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &RunComparison{
+		Base:             *base,
+		Head:             *head,
+		ThresholdPercent: opts.ThresholdPercent,
+		ThresholdMs:      opts.ThresholdMs,
+		NewlyFailed:      []TestComparison{},
+		NewlyPassed:      []TestComparison{},
+		Slower:           []TestComparison{},
+		Faster:           []TestComparison{},
+		ResponseChanged:  []TestComparison{},
+		Added:            []TestComparison{},
+		Removed:          []TestComparison{},
+	}
+
+	baseByKey := make(map[string]models.TestResult, len(baseResults))
+	for _, testResult := range baseResults {
+		baseByKey[comparisonKey(testResult)] = testResult
+	}
+	tolerance := models.DiffTolerance{IgnorePaths: opts.IgnorePaths}
+
+	for _, headResult := range headResults {
+		key := comparisonKey(headResult)
+		baseResult, ok := baseByKey[key]
+		if !ok {
+			comparison.Added = append(comparison.Added, testComparison(nil, &headResult))
+			continue
+		}
+		delete(baseByKey, key)
+
+		test := testComparison(&baseResult, &headResult)
+		baseFailed := baseResult.Status == "failed" || baseResult.Status == "timed_out"
+		headFailed := headResult.Status == "failed" || headResult.Status == "timed_out"
+		switch {
+		case headFailed && !baseFailed:
+			comparison.NewlyFailed = append(comparison.NewlyFailed, test)
+		case baseFailed && headResult.Status == "passed":
+			comparison.NewlyPassed = append(comparison.NewlyPassed, test)
+		case baseResult.Status == "passed" && headResult.Status == "passed":
+			changed := false
+			if exceedsThreshold(test.BaseTimeMs, test.DeltaMs, opts) {
+				comparison.Slower = append(comparison.Slower, test)
+				changed = true
+			} else if exceedsThreshold(test.BaseTimeMs, -test.DeltaMs, opts) {
+				comparison.Faster = append(comparison.Faster, test)
+				changed = true
+			}
+			// Compressed or offloaded bodies are compared as they were received
+			baseData, err := s.restoreBody(ctx, baseResult.ResponseData)
+			if err != nil {
+				baseData = baseResult.ResponseData
+			}
+			headData, err := s.restoreBody(ctx, headResult.ResponseData)
+			if err != nil {
+				headData = headResult.ResponseData
+			}
+			if differences := responseDifferences(baseData, headData, tolerance); len(differences) > 0 {
+				test.BodyDifferences = differences
+				comparison.ResponseChanged = append(comparison.ResponseChanged, test)
+				changed = true
+			}
+			if !changed {
+				comparison.Unchanged++
+			}
+		default:
+			comparison.Unchanged++
+		}
+	}
+
+	// Keep the order of the base run for tests it executed only
+	for _, baseResult := range baseResults {
+		if _, ok := baseByKey[comparisonKey(baseResult)]; ok {
+			comparison.Removed = append(comparison.Removed, testComparison(&baseResult, nil))
+		}
+	}
+	return comparison, nil
+}
+
+// comparedRun loads a finished run and its results in run order
+func (s *TestRunService) comparedRun(ctx context.Context, testRunID string) (*ComparedRun, []models.TestResult, error) {
+	db := s.reader.WithContext(ctx)
+
+	var testRun models.TestRun
+	if err := db.Omit("debug_log", "security_findings").First(&testRun, "id = ?", testRunID).Error; err != nil {
+		return nil, nil, err
+	}
+	if testRun.Status == "running" {
+		return nil, nil, fmt.Errorf("%w: test run %s is still running", ErrRunNotFinished, testRunID)
+	}
+
+	var testResults []models.TestResult
+	err := db.Preload("TestCase").Omit("request").
+		Where("test_run_id = ?", testRunID).Order("position").Find(&testResults).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve test results: %v", err)
+	}
+
+	run := &ComparedRun{
+		ID:        testRun.ID,
+		Name:      testRun.Name,
+		Status:    testRun.Status,
+		StartedAt: testRun.StartedAt,
+	}
+	return run, testResults, nil
+}
+
+// comparisonKey identifies the execution of a test across runs
+func comparisonKey(testResult models.TestResult) string {
+	return fmt.Sprintf("%s|%s|%d|%s", testResult.TestCaseID, testResult.APIVersion, testResult.Iteration, testResult.Region)
+}
+
+// testComparison describes a test from its base and head results, either of
+// which may be missing
+func testComparison(base, head *models.TestResult) TestComparison {
+	known := head
+	if known == nil {
+		known = base
+	}
+	test := TestComparison{
+		TestCaseID: known.TestCaseID,
+		TestName:   known.TestCase.Name,
+		APIVersion: known.APIVersion,
+		Iteration:  known.Iteration,
+		Region:     known.Region,
+	}
+	if base != nil {
+		test.BaseResultID = base.ID
+		test.BaseStatus = base.Status
+		test.BaseStatusCode = capturedStatusCode(base.ResponseData)
+		test.BaseTimeMs = base.ExecutionTimeMs
+	}
+	if head != nil {
+		test.HeadResultID = head.ID
+		test.HeadStatus = head.Status
+		test.HeadStatusCode = capturedStatusCode(head.ResponseData)
+		test.HeadTimeMs = head.ExecutionTimeMs
+		test.FailureType = head.FailureType
+		test.ErrorMessage = head.ErrorMessage
+	}
+	if base != nil && head != nil {
+		test.DeltaMs = head.ExecutionTimeMs - base.ExecutionTimeMs
+	}
+	return test
+}
+
+// exceedsThreshold reports whether an increase of the execution time from
+// baseMs by deltaMs reaches both thresholds
+func exceedsThreshold(baseMs, deltaMs int, opts RunComparisonOptions) bool {
+	if deltaMs < opts.ThresholdMs {
+		return false
+	}
+	return baseMs == 0 || float64(deltaMs)*100/float64(baseMs) >= opts.ThresholdPercent
+}
+
+// responseDifferences lists how the captured responses of two results
+// differ in status code and body. Bodies are redacted first, so differences
+// never reveal sensitive values.
+func responseDifferences(baseData, headData string, tolerance models.DiffTolerance) []string {
+	var differences []string
+	if baseCode, headCode := capturedStatusCode(baseData), capturedStatusCode(headData); baseCode != headCode {
+		differences = append(differences, fmt.Sprintf("status_code: expected %d, got %d", baseCode, headCode))
+	}
+
+	base, _ := redactedResponse(baseData).(map[string]interface{})
+	head, _ := redactedResponse(headData).(map[string]interface{})
+	baseBody, baseHasBody := base["body"]
+	headBody, headHasBody := head["body"]
+	if baseHasBody || headHasBody {
+		differences = append(differences, testrunner.DiffValues(baseBody, headBody, tolerance)...)
+	}
+
+	if len(differences) > maxBodyDifferences {
+		more := len(differences) - maxBodyDifferences
+		differences = append(differences[:maxBodyDifferences:maxBodyDifferences], fmt.Sprintf("and %d more", more))
+	}
+	return differences
+}
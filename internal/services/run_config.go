@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// runConfig captures the configuration a run starts with: the environment,
+// the settings of every service it uses, how test cases are executed and
+// which version of every test case runs
+func (s *TestRunService) runConfig(testRun *models.TestRun, environment *models.Environment, testCases []models.TestCase, stepServices []models.Service) models.RunConfig {
+	config := models.RunConfig{
+		CapturedAt: time.Now(),
+		Services:   []models.ServiceSnapshot{},
+		TestCases:  make([]models.TestCaseSnapshot, 0, len(testCases)),
+		Executor: models.ExecutorSettings{
+			MaxConcurrency:  testRun.MaxConcurrency,
+			TestTimeoutMs:   int64(testRun.TestTimeoutMs),
+			RunTimeoutMs:    runTimeoutOf(testRun).Milliseconds(),
+			RetryPolicy:     testRun.RetryPolicy,
+			LatencyBudgetMs: testRun.LatencyBudgetMs,
+			APIVersions:     testRun.APIVersions,
+			Regions:         testRun.Regions,
+			Region:          s.region,
+		},
+	}
+
+	if environment != nil {
+		names := make([]string, 0, len(environment.Variables))
+		for name := range environment.Variables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		config.Environment = &models.EnvironmentSnapshot{
+			ID:            environment.ID,
+			Name:          environment.Name,
+			VariableNames: names,
+			UpdatedAt:     environment.UpdatedAt,
+		}
+	}
+
+	seen := map[string]bool{}
+	addService := func(service models.Service) {
+		if seen[service.ID] {
+			return
+		}
+		seen[service.ID] = true
+		protocol := service.Protocol
+		if protocol == "" {
+			protocol = "http"
+		}
+		config.Services = append(config.Services, models.ServiceSnapshot{
+			ID:                service.ID,
+			Name:              service.Name,
+			BaseURL:           testRun.ResolvedVariables[service.ID]["base_url"].Value,
+			Protocol:          protocol,
+			AuthType:          service.AuthConfig.Type,
+			APIVersion:        service.APIVersioning.Default,
+			LatencyBudgetMs:   service.LatencyBudgetMs,
+			TimeoutMs:         service.TimeoutMs,
+			Region:            service.Region,
+			DiscoveryProvider: service.Discovery.Provider,
+			UpdatedAt:         service.UpdatedAt,
+		})
+	}
+
+	for _, testCase := range testCases {
+		addService(testCase.Service)
+		sum := sha256.Sum256([]byte(testCase.TestSpec))
+		config.TestCases = append(config.TestCases, models.TestCaseSnapshot{
+			ID:         testCase.ID,
+			Name:       testCase.Name,
+			ServiceID:  testCase.ServiceID,
+			SpecSHA256: hex.EncodeToString(sum[:]),
+			UpdatedAt:  testCase.UpdatedAt,
+		})
+	}
+	for _, service := range stepServices {
+		addService(service)
+	}
+	return config
+}
+
+// GetRunConfig returns the configuration snapshot of a run
+func (s *TestRunService) GetRunConfig(ctx context.Context, testRunID string) (*models.RunConfig, error) {
+	var testRun models.TestRun
+	if err := s.reader.WithContext(ctx).Select("id", "config").First(&testRun, "id = ?", testRunID).Error; err != nil {
+		return nil, err
+	}
+	return &testRun.Config, nil
+}
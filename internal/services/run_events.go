@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// Run event types published while a test run executes
+const (
+	RunEventSnapshot     = "snapshot"
+	RunEventTestStarted  = "test_started"
+	RunEventTestPassed   = "test_passed"
+	RunEventTestFailed   = "test_failed"
+	RunEventTestSkipped  = "test_skipped"
+	RunEventRunCompleted = "run_completed"
+)
+
+// ErrStreamingUnavailable is returned when live streaming has no Redis backend
+var ErrStreamingUnavailable = errors.New("live streaming requires redis")
+
+// RunEvent represents a progress event of a test run
+type RunEvent struct {
+	Type         string                   `json:"type"`
+	TestRunID    string                   `json:"test_run_id"`
+	TestCaseID   string                   `json:"test_case_id,omitempty"`
+	TestName     string                   `json:"test_name,omitempty"`
+	Position     int                      `json:"position"`
+	APIVersion   string                   `json:"api_version,omitempty"`
+	Region       string                   `json:"region,omitempty"`
+	Iteration    int                      `json:"iteration,omitempty"`
+	Attempts     int                      `json:"attempts,omitempty"`
+	Status       string                   `json:"status,omitempty"`
+	DurationMs   int64                    `json:"duration_ms,omitempty"`
+	ErrorMessage string                   `json:"error_message,omitempty"`
+	FailureType  string                   `json:"failure_type,omitempty"`
+	Assertions   []models.AssertionResult `json:"assertions,omitempty"`
+	Run          *models.TestRun          `json:"run,omitempty"`
+	Timestamp    time.Time                `json:"timestamp"`
+}
+
+// runEventsChannel returns the Redis pub/sub channel carrying the events of a run
+func runEventsChannel(testRunID string) string {
+	return fmt.Sprintf("test-runs:%s:events", testRunID)
+}
+
+// publishRunEvent publishes a run event so any API instance can stream it.
+// Publishing is best effort and never interrupts test execution.
+func (s *TestRunService) publishRunEvent(event RunEvent) {
+	if s.redisClient == nil {
+		return
+	}
+	event.Timestamp = time.Now()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if err := s.redisClient.Publish(context.Background(), runEventsChannel(event.TestRunID), payload).Err(); err != nil {
+		s.logger.Warn("failed to publish run event", "test_run_id", event.TestRunID, "event", event.Type, "error", err)
+	}
+}
+
+// SubscribeRunEvents streams the events of a test run. The first event is a
+// snapshot of the run taken after subscribing, so no progress is missed; the
+// channel is closed after the run_completed event or when ctx is done.
+func (s *TestRunService) SubscribeRunEvents(ctx context.Context, testRunID string) (<-chan RunEvent, error) {
+	if s.redisClient == nil {
+		return nil, ErrStreamingUnavailable
+	}
+
+	pubsub := s.redisClient.Subscribe(ctx, runEventsChannel(testRunID))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to run events: %v", err)
+	}
+
+	testRun, err := s.GetTestRun(ctx, testRunID)
+	if err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	events := make(chan RunEvent, 16)
+	go func() {
+		defer close(events)
+		defer pubsub.Close()
+
+		snapshot := RunEvent{Type: RunEventSnapshot, TestRunID: testRunID, Status: testRun.Status, Run: testRun, Timestamp: time.Now()}
+		select {
+		case events <- snapshot:
+		case <-ctx.Done():
+			return
+		}
+		if testRun.Status != "running" {
+			return
+		}
+
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case message, ok := <-messages:
+				if !ok {
+					return
+				}
+				var event RunEvent
+				if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+				if event.Type == RunEventRunCompleted {
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
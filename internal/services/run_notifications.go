@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/notifications"
+)
+
+// notificationTimeout bounds the delivery of the notifications of one run
+const notificationTimeout = 30 * time.Second
+
+// SetReportBaseURL sets the public URL of the API, used to link run reports
+// from notifications (e.g. "https://tests.example.com")
+func (s *TestRunService) SetReportBaseURL(baseURL string) {
+	s.reportBaseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// notifyRunFailures sends a summary of a failed run to the notification
+// channels of every service with failing tests. Delivery errors are logged
+// and never change the outcome of the run.
+func (s *TestRunService) notifyRunFailures(ctx context.Context, testRunID string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notificationTimeout)
+	defer cancel()
+	db := s.db.WithContext(ctx)
+
+	var testRun models.TestRun
+	if err := db.First(&testRun, "id = ?", testRunID).Error; err != nil || testRun.Status != "failed" {
+		return
+	}
+
+	var failed []models.TestResult
+	if err := db.Preload("TestCase.Service").Where("test_run_id = ? AND status IN ?", testRunID, []string{"failed", "timed_out"}).Order("position").Find(&failed).Error; err != nil {
+		s.runLogger(ctx).Error("failed to load failed results for notifications", "error", err)
+		return
+	}
+
+	// Each service is notified about its own failing tests only
+	byService := make(map[string][]models.TestResult)
+	var order []string
+	for _, result := range failed {
+		serviceID := result.TestCase.ServiceID
+		if _, ok := byService[serviceID]; !ok {
+			order = append(order, serviceID)
+		}
+		byService[serviceID] = append(byService[serviceID], result)
+	}
+
+	for _, serviceID := range order {
+		results := byService[serviceID]
+		service := results[0].TestCase.Service
+
+		channels, errs := notifications.ForService(service.Notifications)
+		for _, err := range errs {
+			s.runLogger(ctx).Warn("invalid notification config", "service", service.Name, "error", err)
+		}
+		if len(channels) == 0 {
+			continue
+		}
+
+		summary := notifications.RunSummary{
+			RunID:       testRun.ID,
+			RunName:     testRun.Name,
+			ServiceName: service.Name,
+			Status:      testRun.Status,
+			Passed:      testRun.PassedTests,
+			Failed:      testRun.FailedTests,
+			Skipped:     testRun.SkippedTests,
+			TimedOut:    testRun.TimedOutTests,
+			DurationMs:  testRun.ExecutionTimeMs,
+			ReportURL:   s.runReportURL(testRun.ID),
+		}
+		for _, result := range results {
+			summary.FailedTests = append(summary.FailedTests, notifications.FailedTest{
+				Name:         result.TestCase.Name,
+				APIVersion:   result.APIVersion,
+				FailureType:  result.FailureType,
+				ErrorMessage: result.ErrorMessage,
+			})
+		}
+
+		for _, channel := range channels {
+			if err := channel.Notify(ctx, summary); err != nil {
+				s.runLogger(ctx).Warn("failed to send notification", "channel", channel.Name(), "error", err)
+			}
+		}
+	}
+}
+
+// runReportURL returns the link to the HTML report of a run, or an empty
+// string when no public URL is configured
+func (s *TestRunService) runReportURL(testRunID string) string {
+	if s.reportBaseURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/api/v1/test-runs/%s/report?format=html", s.reportBaseURL, testRunID)
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+)
+
+//go:embed templates/run_report.html
+var reportTemplates embed.FS
+
+// runReportTemplate renders a test run as a single self-contained HTML page
+var runReportTemplate = template.Must(template.New("run_report.html").Funcs(template.FuncMap{
+	"percent": func(part, total int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(part) * 100 / float64(total)
+	},
+	"formatTime": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}).ParseFS(reportTemplates, "templates/run_report.html"))
+
+// RunReport holds everything a test run report shows
+type RunReport struct {
+	Run           *models.TestRun `json:"run"`
+	GeneratedAt   time.Time       `json:"generated_at"`
+	MaxDurationMs int             `json:"max_duration_ms"`
+	Results       []ReportEntry   `json:"results"`
+}
+
+// ReportEntry is a single test result of a run report. Request and response
+// are pretty-printed JSON with sensitive values redacted.
+type ReportEntry struct {
+	ID              string                   `json:"id"`
+	Position        int                      `json:"position"`
+	Name            string                   `json:"name"`
+	APIVersion      string                   `json:"api_version,omitempty"`
+	Iteration       int                      `json:"iteration,omitempty"`
+	Attempts        int                      `json:"attempts,omitempty"`
+	Flaky           bool                     `json:"flaky,omitempty"`
+	Status          string                   `json:"status"`
+	ExecutionTimeMs int                      `json:"execution_time_ms"`
+	ErrorMessage    string                   `json:"error_message,omitempty"`
+	FailureType     string                   `json:"failure_type,omitempty"`
+	Assertions      []models.AssertionResult `json:"assertions"`
+	Request         string                   `json:"request,omitempty"`
+	Response        string                   `json:"response,omitempty"`
+}
+
+// GetRunReport collects the results of a test run, with their assertions and
+// redacted request/response bodies, in run order
+func (s *TestRunService) GetRunReport(ctx context.Context, testRunID string) (*RunReport, error) {
+	db := s.reader.WithContext(ctx)
+
+	var testRun models.TestRun
+	if err := db.First(&testRun, "id = ?", testRunID).Error; err != nil {
+		return nil, err
+	}
+
+	var testResults []models.TestResult
+	err := db.Preload("TestCase.Service").Preload("AssertionResults", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("test_run_id = ?", testRunID).Order("position").Find(&testResults).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve test results: %v", err)
+	}
+
+	report := &RunReport{
+		Run:         &testRun,
+		GeneratedAt: time.Now(),
+		Results:     make([]ReportEntry, 0, len(testResults)),
+	}
+	for _, testResult := range testResults {
+		entry := ReportEntry{
+			ID:              testResult.ID,
+			Position:        testResult.Position,
+			Name:            testResult.TestCase.Name,
+			APIVersion:      testResult.APIVersion,
+			Iteration:       testResult.Iteration,
+			Attempts:        testResult.Attempts,
+			Flaky:           testResult.Flaky,
+			Status:          testResult.Status,
+			ExecutionTimeMs: testResult.ExecutionTimeMs,
+			ErrorMessage:    testResult.ErrorMessage,
+			FailureType:     testResult.FailureType,
+			Assertions:      testResult.AssertionResults,
+		}
+		if testResult.Request.Captured() {
+			entry.Request = prettyJSON(redactRequest(testResult.Request.TestSpec.Request))
+		}
+		if response := redactedResponse(testResult.ResponseData, s.redaction, testResult.TestCase.Service.Redaction); response != nil {
+			if data, ok := response.(map[string]interface{}); ok && len(data) > 0 {
+				entry.Response = prettyJSON(data)
+			}
+		}
+		if entry.ExecutionTimeMs > report.MaxDurationMs {
+			report.MaxDurationMs = entry.ExecutionTimeMs
+		}
+		report.Results = append(report.Results, entry)
+	}
+	return report, nil
+}
+
+// RenderHTMLReport writes a run report as a single HTML file with embedded
+// styles and scripts, viewable offline
+func RenderHTMLReport(w io.Writer, report *RunReport) error {
+	return runReportTemplate.Execute(w, report)
+}
+
+// prettyJSON formats a value as indented JSON for display
+func prettyJSON(value interface{}) string {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
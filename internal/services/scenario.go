@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// Kinds of scenario nodes
+const (
+	ScenarioNodeStep = "step" // a setup or teardown step of a suite
+	ScenarioNodeTest = "test" // a test result
+)
+
+// ScenarioGraph is the execution of a run as a sequence of steps and tests
+// in the order they started, linked by the variables they passed on
+type ScenarioGraph struct {
+	TestRunID string         `json:"test_run_id"`
+	SuiteID   *string        `json:"suite_id,omitempty"`
+	Status    string         `json:"status"`
+	StartedAt time.Time      `json:"started_at"`
+	Nodes     []ScenarioNode `json:"nodes"`
+	Edges     []ScenarioEdge `json:"edges"`
+}
+
+// ScenarioNode is a step or test of a scenario. Captures lists the
+// variables it made available to later nodes, Uses those it referenced.
+type ScenarioNode struct {
+	ID           string    `json:"id"`
+	Kind         string    `json:"kind"`
+	Phase        string    `json:"phase,omitempty"`
+	Name         string    `json:"name"`
+	StepID       string    `json:"step_id,omitempty"`
+	TestCaseID   string    `json:"test_case_id,omitempty"`
+	Position     int       `json:"position"`
+	Iteration    int       `json:"iteration,omitempty"`
+	APIVersion   string    `json:"api_version,omitempty"`
+	Status       string    `json:"status"`
+	StatusCode   int       `json:"status_code,omitempty"`
+	ErrorMessage string    `json:"error_message,omitempty"`
+	FailureType  string    `json:"failure_type,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	DurationMs   int64     `json:"duration_ms"`
+	Captures     []string  `json:"captures,omitempty"`
+	Uses         []string  `json:"uses,omitempty"`
+	Branch       string    `json:"branch,omitempty"`     // branch a conditional step took
+	Condition    string    `json:"condition,omitempty"`  // how the conditions of a step evaluated
+	Iterations   int       `json:"iterations,omitempty"` // items a loop step ran for
+	Parallel     int       `json:"parallel,omitempty"`   // steps a fan-out step ran concurrently
+}
+
+// ScenarioEdge passes a variable from the node that captured it to a node
+// using it
+type ScenarioEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Variable string `json:"variable"`
+}
+
+// GetScenarioGraph returns the execution graph of a run: its suite steps
+// and test results with their timings and outcomes, and an edge wherever a
+// node used a variable an earlier node captured
+func (s *TestRunService) GetScenarioGraph(ctx context.Context, testRunID string) (*ScenarioGraph, error) {
+	db := s.reader.WithContext(ctx)
+
+	var testRun models.TestRun
+	if err := db.Omit("debug_log", "security_findings").First(&testRun, "id = ?", testRunID).Error; err != nil {
+		return nil, err
+	}
+
+	var testResults []models.TestResult
+	err := db.Preload("TestCase").Omit("request").
+		Where("test_run_id = ?", testRunID).Order("position").Find(&testResults).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve test results: %v", err)
+	}
+
+	graph := &ScenarioGraph{
+		TestRunID: testRun.ID,
+		SuiteID:   testRun.SuiteID,
+		Status:    testRun.Status,
+		StartedAt: testRun.StartedAt,
+		Nodes:     make([]ScenarioNode, 0, len(testRun.HookResults)+len(testResults)),
+		Edges:     []ScenarioEdge{},
+	}
+
+	for i, hook := range testRun.HookResults {
+		node := ScenarioNode{
+			ID:           fmt.Sprintf("step-%d", i+1),
+			Kind:         ScenarioNodeStep,
+			Phase:        hook.Phase,
+			Name:         hook.Name,
+			StepID:       hook.StepID,
+			TestCaseID:   hook.TestCaseID,
+			Status:       hook.Status,
+			StatusCode:   hook.StatusCode,
+			ErrorMessage: hook.ErrorMessage,
+			FailureType:  hook.FailureType,
+			StartedAt:    hook.StartedAt,
+			DurationMs:   hook.DurationMs,
+			Uses:         hook.Uses,
+			Captures:     hook.Exports,
+			Branch:       hook.Branch,
+			Condition:    hook.Condition,
+			Iterations:   hook.Iterations,
+			Parallel:     len(hook.Parallel),
+		}
+		if hook.StepID != "" && hook.Status == "passed" {
+			node.Captures = append([]string{stepNamespace + hook.StepID}, node.Captures...)
+		}
+		for _, sub := range hook.Parallel {
+			// The steps of a fan-out capture their responses like any step
+			if sub.StepID != "" && sub.Status == "passed" {
+				node.Captures = mergeVariables(node.Captures, []string{stepNamespace + sub.StepID})
+			}
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	// Test cases of a suite capture the variables they export
+	var suite *models.TestSuite
+	if testRun.SuiteID != nil {
+		var found models.TestSuite
+		if err := db.Select("id", "test_exports").First(&found, "id = ?", *testRun.SuiteID).Error; err == nil {
+			suite = &found
+		}
+	}
+
+	for _, testResult := range testResults {
+		var captures []string
+		if testResult.Status == "passed" {
+			captures = testExportNames(suite, testResult.TestCaseID)
+		}
+		var uses []string
+		var testSpec models.TestSpec
+		if json.Unmarshal([]byte(testResult.TestCase.TestSpec), &testSpec) == nil {
+			uses = testrunner.Placeholders(testSpec)
+		}
+		duration := time.Duration(testResult.ExecutionTimeMs) * time.Millisecond
+		graph.Nodes = append(graph.Nodes, ScenarioNode{
+			ID:           testResult.ID,
+			Kind:         ScenarioNodeTest,
+			Name:         testResult.TestCase.Name,
+			TestCaseID:   testResult.TestCaseID,
+			Position:     testResult.Position,
+			Iteration:    testResult.Iteration,
+			APIVersion:   testResult.APIVersion,
+			Status:       testResult.Status,
+			StatusCode:   capturedStatusCode(testResult.ResponseData),
+			ErrorMessage: testResult.ErrorMessage,
+			FailureType:  testResult.FailureType,
+			StartedAt:    testResult.CreatedAt.Add(-duration), // results are recorded when they finish
+			DurationMs:   duration.Milliseconds(),
+			Uses:         uses,
+			Captures:     captures,
+		})
+	}
+
+	// Steps of a phase are recorded in order, so a stable sort keeps them
+	// in order when their start times are equal
+	sort.SliceStable(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].StartedAt.Before(graph.Nodes[j].StartedAt)
+	})
+	graph.Edges = scenarioEdges(graph.Nodes)
+	return graph, nil
+}
+
+// scenarioEdges links every variable a node uses to the latest earlier node
+// capturing it. Steps that ran for a test case only pass variables on to
+// that test case and its own steps; test exports reach every later node.
+func scenarioEdges(nodes []ScenarioNode) []ScenarioEdge {
+	edges := []ScenarioEdge{}
+	for i, node := range nodes {
+		for _, variable := range node.Uses {
+			for j := i - 1; j >= 0; j-- {
+				producer := nodes[j]
+				if producer.Kind == ScenarioNodeStep && producer.TestCaseID != "" && producer.TestCaseID != node.TestCaseID {
+					continue
+				}
+				if capturesVariable(producer.Captures, variable) {
+					edges = append(edges, ScenarioEdge{From: producer.ID, To: node.ID, Variable: variable})
+					break
+				}
+			}
+		}
+	}
+	return edges
+}
+
+// capturesVariable reports whether a variable is one of the captures, or
+// a value of a captured step response
+func capturesVariable(captures []string, variable string) bool {
+	for _, captured := range captures {
+		if variable == captured || (strings.HasPrefix(captured, stepNamespace) && strings.HasPrefix(variable, captured+".")) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/utils"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSchedule is returned when a schedule fails validation
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// schedulerLeaderKey is the Redis key holding the instance allowed to fire schedules
+const schedulerLeaderKey = "scheduler:leader"
+
+// renewLeadership extends the leader lease only if this instance still holds it
+var renewLeadership = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+// ScheduleService manages schedules and fires the test runs they describe
+type ScheduleService struct {
+	db             *gorm.DB
+	redisClient    *redis.Client
+	testRunService *TestRunService
+	instanceID     string
+	logger         *slog.Logger
+}
+
+// NewScheduleService creates a new schedule service
+func NewScheduleService(db *gorm.DB, redisClient *redis.Client, testRunService *TestRunService) *ScheduleService {
+	hostname, _ := os.Hostname()
+	return &ScheduleService{
+		db:             db,
+		redisClient:    redisClient,
+		testRunService: testRunService,
+		instanceID:     hostname + "-" + uuid.New().String(),
+		logger:         slog.Default(),
+	}
+}
+
+// SetLogger sets the logger of the service
+func (s *ScheduleService) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// CreateSchedule validates and creates a schedule, computing its first run time
+func (s *ScheduleService) CreateSchedule(schedule *models.Schedule) error {
+	if err := s.prepareSchedule(schedule, time.Now()); err != nil {
+		return err
+	}
+	return s.db.Create(schedule).Error
+}
+
+// GetSchedule retrieves a schedule by ID
+func (s *ScheduleService) GetSchedule(id string) (*models.Schedule, error) {
+	var schedule models.Schedule
+	if err := s.db.First(&schedule, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// ListSchedules retrieves the schedules of a project, or all schedules without
+// projectID, with pagination
+func (s *ScheduleService) ListSchedules(projectID string, limit, offset int) ([]models.Schedule, int64, error) {
+	var schedules []models.Schedule
+	var total int64
+
+	query := inProject(s.db.Model(&models.Schedule{}), projectID)
+
+	// Get total count
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results
+	if err := query.Order("name").Limit(limit).Offset(offset).Find(&schedules).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return schedules, total, nil
+}
+
+// UpdateSchedule validates and saves a modified schedule; the next run time is
+// recomputed from the current expression
+func (s *ScheduleService) UpdateSchedule(schedule *models.Schedule) error {
+	if err := s.prepareSchedule(schedule, time.Now()); err != nil {
+		return err
+	}
+	return s.db.Save(schedule).Error
+}
+
+// DeleteSchedule deletes a schedule
+func (s *ScheduleService) DeleteSchedule(id string) error {
+	return s.db.Delete(&models.Schedule{}, "id = ?", id).Error
+}
+
+// prepareSchedule validates a schedule and sets its next run time
+func (s *ScheduleService) prepareSchedule(schedule *models.Schedule, now time.Time) error {
+	if schedule.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSchedule)
+	}
+	if (schedule.ServiceID == nil || *schedule.ServiceID == "") && len(schedule.TestIDs) == 0 {
+		return fmt.Errorf("%w: service_id or test_ids is required", ErrInvalidSchedule)
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	if schedule.MaxConcurrency < 1 {
+		schedule.MaxConcurrency = 1
+	}
+
+	next, err := nextScheduleRun(schedule, now)
+	if err != nil {
+		return err
+	}
+	schedule.NextRunAt = nil
+	if schedule.Enabled {
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
+
+// nextScheduleRun returns the first run time of a schedule after now
+func nextScheduleRun(schedule *models.Schedule, now time.Time) (time.Time, error) {
+	cron, err := utils.ParseCron(schedule.CronExpression)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	location, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, schedule.Timezone)
+	}
+
+	next := cron.Next(now.In(location))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("%w: cron expression %q never matches", ErrInvalidSchedule, schedule.CronExpression)
+	}
+	return next.UTC(), nil
+}
+
+// Run fires due schedules every interval until ctx is done. With Redis, only
+// the instance holding the leader lease fires schedules, so running several
+// API instances starts each scheduled run once.
+func (s *ScheduleService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if s.acquireLeadership(ctx, 2*interval) {
+			s.fireDueSchedules(ctx, time.Now())
+		}
+
+		select {
+		case <-ctx.Done():
+			s.releaseLeadership()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// acquireLeadership takes or renews the leader lease. Without Redis the
+// instance is always the leader.
+func (s *ScheduleService) acquireLeadership(ctx context.Context, lease time.Duration) bool {
+	if s.redisClient == nil {
+		return true
+	}
+
+	acquired, err := s.redisClient.SetNX(ctx, schedulerLeaderKey, s.instanceID, lease).Result()
+	if err != nil {
+		s.logger.Error("scheduler leader election failed", "error", err)
+		return false
+	}
+	if acquired {
+		return true
+	}
+
+	renewed, err := renewLeadership.Run(ctx, s.redisClient, []string{schedulerLeaderKey}, s.instanceID, lease.Milliseconds()).Int()
+	return err == nil && renewed == 1
+}
+
+// releaseLeadership hands the lease over on shutdown instead of letting it expire
+func (s *ScheduleService) releaseLeadership() {
+	if s.redisClient == nil {
+		return
+	}
+	ctx := context.Background()
+	if leader, err := s.redisClient.Get(ctx, schedulerLeaderKey).Result(); err == nil && leader == s.instanceID {
+		s.redisClient.Del(ctx, schedulerLeaderKey)
+	}
+}
+
+// fireDueSchedules starts a test run for every enabled schedule that is due.
+// Each schedule is claimed by advancing its next run time conditionally, so a
+// run is never started twice even when leadership changes hands.
+func (s *ScheduleService) fireDueSchedules(ctx context.Context, now time.Time) {
+	var schedules []models.Schedule
+	if err := s.db.WithContext(ctx).Where("enabled = ? AND next_run_at <= ?", true, now).Find(&schedules).Error; err != nil {
+		s.logger.Error("failed to load due schedules", "error", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		next, err := nextScheduleRun(&schedule, now)
+		if err != nil {
+			s.logger.Warn("disabling schedule", "schedule_id", schedule.ID, "error", err)
+			s.db.Model(&models.Schedule{}).Where("id = ?", schedule.ID).Updates(map[string]interface{}{"enabled": false, "next_run_at": nil})
+			continue
+		}
+
+		claim := s.db.Model(&models.Schedule{}).
+			Where("id = ? AND next_run_at = ?", schedule.ID, schedule.NextRunAt).
+			Updates(map[string]interface{}{"next_run_at": next, "last_run_at": now})
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+
+		opts := StartTestRunOptions{
+			Name:           fmt.Sprintf("%s (scheduled %s)", schedule.Name, now.UTC().Format(time.RFC3339)),
+			TestIDs:        schedule.TestIDs,
+			Variables:      schedule.Variables,
+			MaxConcurrency: schedule.MaxConcurrency,
+			ScheduleID:     schedule.ID,
+		}
+		if schedule.ServiceID != nil {
+			opts.ServiceID = *schedule.ServiceID
+		}
+		if schedule.EnvironmentID != nil {
+			opts.EnvironmentID = *schedule.EnvironmentID
+		}
+		if schedule.ProjectID != nil {
+			opts.ProjectID = *schedule.ProjectID
+		}
+
+		testRun, err := s.testRunService.StartTestRun(ctx, opts)
+		if err != nil {
+			s.logger.Error("failed to start scheduled run", "schedule_id", schedule.ID, "error", err)
+			continue
+		}
+		s.db.Model(&models.Schedule{}).Where("id = ?", schedule.ID).Update("last_run_id", testRun.ID)
+	}
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/secrets"
+)
+
+// secretPlaceholderPattern matches {{secret:<reference>}} placeholders, e.g.
+// {{secret:vault:kv/billing#token}}, in the JSON of a spec
+var secretPlaceholderPattern = regexp.MustCompile(`\{\{\s*secret:([^{}"\\\s]+)\s*\}\}`)
+
+// SetSecretResolver sets the resolver of secret references, e.g. with the
+// Vault server to read vault: references from
+func (s *TestRunService) SetSecretResolver(resolver *secrets.Resolver) {
+	s.secretResolver = resolver
+}
+
+// secretPlaceholders returns the variables with secret references replaced
+// by {{secret:<reference>}} placeholders. Specs substituted with them can be
+// stored, and get their secrets only from resolveSpecSecrets.
+func secretPlaceholders(vars map[string]string) map[string]string {
+	placeholders := make(map[string]string, len(vars))
+	for key, value := range vars {
+		if secrets.IsReference(value) {
+			value = "{{secret:" + value + "}}"
+		}
+		placeholders[key] = value
+	}
+	return placeholders
+}
+
+// resolveSpecSecrets returns a copy of a spec with its secret placeholders
+// and the secret references of its auth override resolved, and the secrets
+// resolved from placeholders, to redact them from the response. The spec
+// itself is left unchanged, so it can be stored.
+func (s *TestRunService) resolveSpecSecrets(ctx context.Context, spec *models.TestSpec) (*models.TestSpec, []string, error) {
+	encoded, err := json.Marshal(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resolved []string
+	var resolveErr error
+	encoded = secretPlaceholderPattern.ReplaceAllFunc(encoded, func(match []byte) []byte {
+		value, err := s.secretResolver.Resolve(ctx, string(secretPlaceholderPattern.FindSubmatch(match)[1]))
+		if err != nil {
+			if resolveErr == nil {
+				resolveErr = err
+			}
+			return match
+		}
+		resolved = append(resolved, value)
+		// The value is inserted into a JSON string
+		escaped, _ := json.Marshal(value)
+		return escaped[1 : len(escaped)-1]
+	})
+	if resolveErr != nil {
+		return nil, nil, resolveErr
+	}
+
+	var copied models.TestSpec
+	if err := json.Unmarshal(encoded, &copied); err != nil {
+		return nil, nil, err
+	}
+	if copied.Request.Auth != nil {
+		auth, err := s.resolveAuth(ctx, *copied.Request.Auth)
+		if err != nil {
+			return nil, nil, err
+		}
+		copied.Request.Auth = &auth
+	}
+	return &copied, resolved, nil
+}
+
+// resolveAuth returns an auth config with its secret references resolved
+func (s *TestRunService) resolveAuth(ctx context.Context, auth models.AuthConfig) (models.AuthConfig, error) {
+	return auth.ResolveReferences(func(ref string) (string, error) {
+		return s.secretResolver.Resolve(ctx, ref)
+	})
+}
+
+// withResolvedAuth returns a service with the secret references of its auth
+// config resolved, e.g. to redact the secrets from responses. References
+// that cannot be resolved are kept; creating the executor reports them.
+func (s *TestRunService) withResolvedAuth(ctx context.Context, service models.Service) models.Service {
+	if auth, err := s.resolveAuth(ctx, service.AuthConfig); err == nil {
+		service.AuthConfig = auth
+	}
+	return service
+}
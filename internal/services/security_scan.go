@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// ErrNoSecurityScan is returned for the security findings of a run started
+// without a security scan
+var ErrNoSecurityScan = errors.New("no security scan")
+
+// severityRanks orders findings from the most to the least severe
+var severityRanks = map[string]int{
+	models.SeverityHigh:   0,
+	models.SeverityMedium: 1,
+	models.SeverityLow:    2,
+}
+
+// securityScan collects the findings of the test cases of a run
+type securityScan struct {
+	mu       sync.Mutex
+	findings models.SecurityFindings
+}
+
+// securityScanKey is the context key of the security scan of a run
+type securityScanKey struct{}
+
+// withSecurityScan returns a context collecting security findings into scan
+func withSecurityScan(ctx context.Context, scan *securityScan) context.Context {
+	return context.WithValue(ctx, securityScanKey{}, scan)
+}
+
+// add records the findings of a test case
+func (s *securityScan) add(testCase models.TestCase, findings []models.SecurityFinding) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, finding := range findings {
+		finding.TestCaseID = testCase.ID
+		finding.TestName = testCase.Name
+		s.findings = append(s.findings, finding)
+	}
+}
+
+// list returns the findings, the most severe first
+func (s *securityScan) list() models.SecurityFindings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	findings := append(models.SecurityFindings{}, s.findings...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRanks[findings[i].Severity] < severityRanks[findings[j].Severity]
+	})
+	return findings
+}
+
+// scanEndpoint scans the endpoint of a test case after it executed, when
+// the run collects security findings and the executor supports scans. The
+// scan has a deadline of its own, so a test that used up its deadline is
+// still scanned.
+func (s *TestRunService) scanEndpoint(ctx context.Context, executor testrunner.Executor, testCase models.TestCase, testSpec *models.TestSpec, timeout time.Duration) {
+	scan, ok := ctx.Value(securityScanKey{}).(*securityScan)
+	if !ok || ctx.Err() != nil {
+		return
+	}
+	scanner, ok := executor.(testrunner.SecurityScanner)
+	if !ok {
+		s.runLogger(ctx).Debug("security scan not supported", "protocol", testSpec.Protocol)
+		return
+	}
+	scanCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	findings := scanner.ScanEndpoint(scanCtx, testSpec)
+	s.runLogger(ctx).Debug("scanned endpoint", "findings", len(findings))
+	scan.add(testCase, findings)
+}
+
+// GetSecurityFindings returns the findings of a run started with a security
+// scan or leak detection, the most severe first. Findings are stored when
+// the run finishes.
+func (s *TestRunService) GetSecurityFindings(ctx context.Context, id string) (models.SecurityFindings, error) {
+	var testRun models.TestRun
+	if err := s.db.WithContext(ctx).Select("id", "status", "security_scan", "detect_leaks", "security_findings").First(&testRun, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if !testRun.SecurityScan && !testRun.DetectLeaks {
+		return nil, fmt.Errorf("%w: the run was started with neither security_scan nor leak detection enabled", ErrNoSecurityScan)
+	}
+	if testRun.Status == "running" {
+		return nil, fmt.Errorf("%w: security findings are stored when the run finishes", ErrRunNotFinished)
+	}
+	if testRun.SecurityFindings == nil {
+		testRun.SecurityFindings = models.SecurityFindings{}
+	}
+	return testRun.SecurityFindings, nil
+}
@@ -1,11 +1,26 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
 	"api-test-framework/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrServiceInUse is returned when deleting a service others still depend on
+var ErrServiceInUse = errors.New("service in use")
+
+// ErrInvalidService is returned for a service with invalid settings
+var ErrInvalidService = errors.New("invalid service")
+
+// ErrInvalidCascade is returned for an unknown cascade of a service deletion
+var ErrInvalidCascade = errors.New("invalid cascade")
+
 // ServiceService handles service operations
 type ServiceService struct {
 	db *gorm.DB
@@ -18,6 +33,14 @@ func NewServiceService(db *gorm.DB) *ServiceService {
 
 // CreateService creates a new service
 func (s *ServiceService) CreateService(service *models.Service) error {
+	if err := validateRedactionRules(service.Redaction); err != nil {
+		return err
+	}
+	if err := validateDependencies(s.db, service.ID, service.DependsOn); err != nil {
+		return err
+	}
+	// TLS audits are recorded by TLSAuditService only
+	service.TLSAudit = nil
 	return s.db.Create(service).Error
 }
 
@@ -30,18 +53,21 @@ func (s *ServiceService) GetService(id string) (*models.Service, error) {
 	return &service, nil
 }
 
-// ListServices retrieves all services with pagination
-func (s *ServiceService) ListServices(limit, offset int) ([]models.Service, int64, error) {
+// ListServices retrieves the services of a project, or all services without
+// projectID, with pagination
+func (s *ServiceService) ListServices(projectID string, limit, offset int) ([]models.Service, int64, error) {
 	var services []models.Service
 	var total int64
 
+	query := inProject(s.db.Model(&models.Service{}), projectID)
+
 	// Get total count
-	if err := s.db.Model(&models.Service{}).Count(&total).Error; err != nil {
+	if err := query.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated results
-	if err := s.db.Limit(limit).Offset(offset).Find(&services).Error; err != nil {
+	if err := query.Limit(limit).Offset(offset).Find(&services).Error; err != nil {
 		return nil, 0, err
 	}
 
@@ -50,13 +76,22 @@ func (s *ServiceService) ListServices(limit, offset int) ([]models.Service, int6
 
 // UpdateService updates an existing service and returns the updated service
 func (s *ServiceService) UpdateService(id string, service *models.Service) (*models.Service, error) {
+	if err := validateRedactionRules(service.Redaction); err != nil {
+		return nil, err
+	}
+	if err := validateDependencies(s.db, id, service.DependsOn); err != nil {
+		return nil, err
+	}
+
 	// First, get the existing service to preserve the ID
 	var existingService models.Service
 	if err := s.db.First(&existingService, "id = ?", id).Error; err != nil {
 		return nil, err
 	}
 
-	// Update the service with the new data
+	// Update the service with the new data; masked secrets keep their values
+	service.TLSAudit = nil
+	service.AuthConfig = service.AuthConfig.Unmask(existingService.AuthConfig)
 	if err := s.db.Model(&existingService).Updates(service).Error; err != nil {
 		return nil, err
 	}
@@ -70,7 +105,153 @@ func (s *ServiceService) UpdateService(id string, service *models.Service) (*mod
 	return &updatedService, nil
 }
 
-// DeleteService deletes a service
-func (s *ServiceService) DeleteService(id string) error {
-	return s.db.Delete(&models.Service{}, "id = ?", id).Error
+// Cascades of deleting a service with test cases
+const (
+	// CascadeBlock refuses to delete a service with active test cases and
+	// archives its inactive ones
+	CascadeBlock = "block"
+	// CascadeArchive archives every test case of the service
+	CascadeArchive = "archive"
+)
+
+// ServiceDeletion reports what deleting a service did to its test cases
+type ServiceDeletion struct {
+	ArchivedTests   int64 `json:"archived_tests"`
+	DetachedResults int64 `json:"detached_results"` // results of the archived tests, which keep no test case
+}
+
+// serviceDependents are the models targeting a service through their
+// service_id, by the name reported when it cannot be deleted
+var serviceDependents = []struct {
+	name  string
+	model interface{}
+}{
+	{"suites", &models.TestSuite{}},
+	{"schedules", &models.Schedule{}},
+	{"hooks", &models.Hook{}},
+}
+
+// DeleteService deletes a service and removes it from the dependencies of
+// its consumers. Its test cases are moved to the archived
+// test cases and their results detached from them; with CascadeBlock, the
+// default, only when none of them is active. Services targeted by suites,
+// schedules or hooks, whose test cases they name in their test_ids, or
+// executing in a running run, are kept and ErrServiceInUse tells why.
+func (s *ServiceService) DeleteService(id, cascade string) (*ServiceDeletion, error) {
+	switch cascade {
+	case "":
+		cascade = CascadeBlock
+	case CascadeBlock, CascadeArchive:
+	default:
+		return nil, fmt.Errorf("%w: cascade must be %s or %s", ErrInvalidCascade, CascadeBlock, CascadeArchive)
+	}
+
+	deletion := &ServiceDeletion{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var service models.Service
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&service, "id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := serviceInUse(tx, id, cascade); err != nil {
+			return err
+		}
+
+		testCases := tx.Model(&models.TestCase{}).Select("id").Where("service_id = ?", id)
+		archived := tx.Exec(`INSERT INTO archived_test_cases (id, service_id, service_name, name, description, test_spec, tags, is_active, created_at, archived_at)
+			SELECT id, service_id, ?, name, description, test_spec, tags, is_active, created_at, ? FROM test_cases WHERE service_id = ?`,
+			service.Name, time.Now(), id)
+		if archived.Error != nil {
+			return fmt.Errorf("failed to archive test cases: %v", archived.Error)
+		}
+		deletion.ArchivedTests = archived.RowsAffected
+
+		detached := tx.Model(&models.TestResult{}).Where("test_case_id IN (?)", testCases).Update("test_case_id", nil)
+		if detached.Error != nil {
+			return fmt.Errorf("failed to detach results: %v", detached.Error)
+		}
+		deletion.DetachedResults = detached.RowsAffected
+
+		if err := tx.Where("service_id = ?", id).Delete(&models.TestCase{}).Error; err != nil {
+			return err
+		}
+		// Its consumers no longer depend on it
+		if err := tx.Model(&models.Service{}).Where("depends_on @> ?", tagJSON(id)).
+			Update("depends_on", gorm.Expr("depends_on - ?", id)).Error; err != nil {
+			return fmt.Errorf("failed to remove the service from its consumers: %v", err)
+		}
+		return tx.Delete(&service).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+// serviceInUse returns ErrServiceInUse when suites, schedules, hooks or
+// running runs depend on a service or its test cases, or, unless the
+// cascade archives them, when it has active test cases
+func serviceInUse(tx *gorm.DB, id, cascade string) error {
+	var testIDs []string
+	if err := tx.Model(&models.TestCase{}).Where("service_id = ?", id).Pluck("id", &testIDs).Error; err != nil {
+		return err
+	}
+	for _, dependent := range serviceDependents {
+		var count int64
+		if err := tx.Model(dependent.model).Where("service_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %d %s still target the service", ErrServiceInUse, count, dependent.name)
+		}
+
+		// Archived test cases named in test_ids would be left dangling
+		if len(testIDs) == 0 {
+			continue
+		}
+		var lists []models.StringList
+		if err := tx.Model(dependent.model).Where("test_ids <> '[]'").Pluck("test_ids", &lists).Error; err != nil {
+			return err
+		}
+		if count := countReferences(lists, testIDs); count > 0 {
+			return fmt.Errorf("%w: %d %s still name its test cases in test_ids", ErrServiceInUse, count, dependent.name)
+		}
+	}
+
+	executing, _ := json.Marshal([]map[string]string{{"id": id}})
+	var running int64
+	if err := tx.Model(&models.TestRun{}).Where("status = ? AND config->'services' @> ?", "running", string(executing)).Count(&running).Error; err != nil {
+		return err
+	}
+	if running > 0 {
+		return fmt.Errorf("%w: %d running runs execute its test cases", ErrServiceInUse, running)
+	}
+
+	if cascade == CascadeBlock {
+		var active int64
+		if err := tx.Model(&models.TestCase{}).Where("service_id = ? AND is_active = ?", id, true).Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return fmt.Errorf("%w: the service has %d active test cases; deactivate them or delete with cascade=%s", ErrServiceInUse, active, CascadeArchive)
+		}
+	}
+	return nil
+}
+
+// countReferences returns how many of the test_ids lists name any of ids
+func countReferences(lists []models.StringList, ids []string) int {
+	named := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		named[id] = true
+	}
+	count := 0
+	for _, list := range lists {
+		for _, id := range list {
+			if named[id] {
+				count++
+				break
+			}
+		}
+	}
+	return count
 }
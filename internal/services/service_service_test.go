@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+
+	"api-test-framework/internal/models"
+)
+
+func TestCountReferences(t *testing.T) {
+	lists := []models.StringList{
+		{"other-1", "deleted-1"},
+		{"other-2"},
+		{"deleted-2", "deleted-1"},
+		{},
+	}
+
+	cases := []struct {
+		name string
+		ids  []string
+		want int
+	}{
+		{"named by several lists", []string{"deleted-1", "deleted-2"}, 2},
+		{"named once", []string{"other-2"}, 1},
+		{"counted once per list", []string{"deleted-1"}, 2},
+		{"not named", []string{"unused"}, 0},
+		{"no test cases", nil, 0},
+	}
+	for _, tc := range cases {
+		if got := countReferences(lists, tc.ids); got != tc.want {
+			t.Errorf("%s: countReferences() = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
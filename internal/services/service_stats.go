@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// unavailableFailures are the failure types counting against the
+// availability of a service: it could not be reached or answered with a 5xx
+var unavailableFailures = []string{
+	testrunner.FailureConnection,
+	testrunner.FailureDNS,
+	testrunner.FailureTimeout,
+	testrunner.FailureTLS,
+	testrunner.FailureServer,
+}
+
+// ServiceStats reports the reliability of a service as observed by the test
+// results recorded in a time window, from scheduled and on-demand runs alike
+type ServiceStats struct {
+	ServiceID    string           `json:"service_id"`
+	ServiceName  string           `json:"service_name"`
+	Passed       int64            `json:"passed"`
+	Failed       int64            `json:"failed"`
+	Skipped      int64            `json:"skipped"`
+	TimedOut     int64            `json:"timed_out"`
+	Unavailable  int64            `json:"unavailable"`            // failures reaching the service or answered with a 5xx
+	Availability *float64         `json:"availability,omitempty"` // share of executed tests the service was available for
+	ErrorRate    *float64         `json:"error_rate,omitempty"`   // share of executed tests that failed
+	Failures     map[string]int64 `json:"failures"`               // failed and timed out tests per failure type
+	P50Ms        float64          `json:"p50_ms"`
+	P95Ms        float64          `json:"p95_ms"`
+	LastResultAt *time.Time       `json:"last_result_at,omitempty"`
+}
+
+// GetServiceStats computes the reliability of every service with results
+// since a point in time, or of a single service when serviceID is set.
+// Skipped tests count neither for nor against a service; timed out tests
+// count as failures.
+func (s *TestRunService) GetServiceStats(ctx context.Context, since time.Time, serviceID string) ([]ServiceStats, error) {
+	db := s.reader.WithContext(ctx)
+
+	var counts []struct {
+		ServiceID    string
+		Status       string
+		FailureType  string
+		Count        int64
+		LastResultAt time.Time
+	}
+	query := db.Table("test_results").
+		Select("test_cases.service_id, test_results.status, COALESCE(test_results.failure_type, '') AS failure_type, COUNT(*) AS count, MAX(test_results.created_at) AS last_result_at").
+		Joins("JOIN test_cases ON test_cases.id = test_results.test_case_id").
+		Where("test_results.created_at >= ?", since).
+		Group("test_cases.service_id, test_results.status, COALESCE(test_results.failure_type, '')")
+	if serviceID != "" {
+		query = query.Where("test_cases.service_id = ?", serviceID)
+	}
+	if err := query.Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	var latencies []struct {
+		ServiceID string
+		P50       float64
+		P95       float64
+	}
+	query = db.Table("test_results").
+		Select("test_cases.service_id, percentile_cont(0.5) WITHIN GROUP (ORDER BY test_results.execution_time_ms) AS p50, percentile_cont(0.95) WITHIN GROUP (ORDER BY test_results.execution_time_ms) AS p95").
+		Joins("JOIN test_cases ON test_cases.id = test_results.test_case_id").
+		Where("test_results.created_at >= ? AND test_results.status <> ?", since, "skipped").
+		Group("test_cases.service_id")
+	if serviceID != "" {
+		query = query.Where("test_cases.service_id = ?", serviceID)
+	}
+	if err := query.Scan(&latencies).Error; err != nil {
+		return nil, err
+	}
+
+	unavailable := make(map[string]bool, len(unavailableFailures))
+	for _, failureType := range unavailableFailures {
+		unavailable[failureType] = true
+	}
+
+	byService := map[string]*ServiceStats{}
+	for _, count := range counts {
+		stats := byService[count.ServiceID]
+		if stats == nil {
+			stats = &ServiceStats{ServiceID: count.ServiceID, Failures: map[string]int64{}}
+			byService[count.ServiceID] = stats
+		}
+		switch count.Status {
+		case "passed":
+			stats.Passed += count.Count
+		case "failed", "timed_out":
+			if count.Status == "failed" {
+				stats.Failed += count.Count
+			} else {
+				stats.TimedOut += count.Count
+			}
+			if count.FailureType != "" {
+				stats.Failures[count.FailureType] += count.Count
+			}
+			if unavailable[count.FailureType] {
+				stats.Unavailable += count.Count
+			}
+		default:
+			stats.Skipped += count.Count
+		}
+		if lastResultAt := count.LastResultAt; stats.LastResultAt == nil || lastResultAt.After(*stats.LastResultAt) {
+			stats.LastResultAt = &lastResultAt
+		}
+	}
+	for _, latency := range latencies {
+		if stats := byService[latency.ServiceID]; stats != nil {
+			stats.P50Ms = latency.P50
+			stats.P95Ms = latency.P95
+		}
+	}
+
+	if len(byService) == 0 {
+		return []ServiceStats{}, nil
+	}
+	ids := make([]string, 0, len(byService))
+	for id := range byService {
+		ids = append(ids, id)
+	}
+	var services []models.Service
+	if err := db.Select("id", "name").Where("id IN ?", ids).Find(&services).Error; err != nil {
+		return nil, err
+	}
+	for _, service := range services {
+		byService[service.ID].ServiceName = service.Name
+	}
+
+	report := make([]ServiceStats, 0, len(byService))
+	for _, stats := range byService {
+		if executed := stats.Passed + stats.Failed + stats.TimedOut; executed > 0 {
+			availability := float64(executed-stats.Unavailable) / float64(executed)
+			errorRate := float64(stats.Failed+stats.TimedOut) / float64(executed)
+			stats.Availability = &availability
+			stats.ErrorRate = &errorRate
+		}
+		report = append(report, *stats)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].ServiceName < report[j].ServiceName
+	})
+	return report, nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SmokeTag marks the generated smoke tests of a service and its smoke suite
+const SmokeTag = "smoke:auto"
+
+// ErrNoSmokeEndpoints is returned when no test of a service can seed a smoke
+// test, e.g. because none expects a success status
+var ErrNoSmokeEndpoints = errors.New("no endpoints to generate smoke tests from")
+
+// RegenerateSmokeSuite regenerates the smoke suite of a service from the
+// endpoints its tests call: one smoke test per method and path, copied from
+// the first active HTTP test of the endpoint expecting a success status.
+// Services whose tests come from an OpenAPI document get theirs on import.
+func (s *TestService) RegenerateSmokeSuite(serviceID string) (*models.TestSuite, error) {
+	var suite *models.TestSuite
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var service models.Service
+		if err := tx.First(&service, "id = ?", serviceID).Error; err != nil {
+			return err
+		}
+		var testCases []models.TestCase
+		if err := tx.Where("service_id = ? AND is_active = ? AND NOT tags @> ?", serviceID, true, tagJSON(SmokeTag)).
+			Order("created_at").Find(&testCases).Error; err != nil {
+			return fmt.Errorf("failed to retrieve test cases: %v", err)
+		}
+		var err error
+		suite, err = syncSmokeSuite(tx, &service, testCases)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suite, nil
+}
+
+// syncSmokeSuite makes the smoke tests of a service match the endpoints of
+// sources and points its smoke suite at them, creating the suite when the
+// service has none. Smoke tests of endpoints still covered keep their ID,
+// and those of endpoints no longer covered are deleted.
+func syncSmokeSuite(tx *gorm.DB, service *models.Service, sources []models.TestCase) (*models.TestSuite, error) {
+	var endpoints []string
+	desired := map[string]models.TestCase{}
+	for _, source := range sources {
+		spec, ok := smokeSpec(source)
+		if !ok {
+			continue
+		}
+		endpoint := smokeEndpoint(spec.Request)
+		if _, ok := desired[endpoint]; ok {
+			continue
+		}
+		encoded, err := json.Marshal(spec)
+		if err != nil {
+			continue
+		}
+		desired[endpoint] = models.TestCase{
+			ServiceID:   service.ID,
+			Name:        spec.Name,
+			Description: "Generated smoke test of " + endpoint,
+			TestSpec:    string(encoded),
+			IsActive:    true,
+			Tags:        models.StringList{SmokeTag},
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("%w: service %s has no active HTTP test expecting a success status", ErrNoSmokeEndpoints, service.Name)
+	}
+
+	var existing []models.TestCase
+	if err := tx.Where("service_id = ? AND tags @> ?", service.ID, tagJSON(SmokeTag)).Order("created_at").Find(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve smoke tests: %v", err)
+	}
+	current := map[string]models.TestCase{}
+	for _, testCase := range existing {
+		var spec models.TestSpec
+		endpoint := ""
+		if json.Unmarshal([]byte(testCase.TestSpec), &spec) == nil {
+			endpoint = smokeEndpoint(spec.Request)
+		}
+		_, wanted := desired[endpoint]
+		if _, taken := current[endpoint]; !wanted || taken {
+			if err := tx.Delete(&models.TestCase{}, "id = ?", testCase.ID).Error; err != nil {
+				return nil, fmt.Errorf("failed to delete smoke test '%s': %v", testCase.Name, err)
+			}
+			continue
+		}
+		current[endpoint] = testCase
+	}
+
+	testIDs := make(models.StringList, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		testCase := desired[endpoint]
+		if previous, ok := current[endpoint]; ok {
+			spec, err := models.EncryptTestSpec(testCase.TestSpec)
+			if err != nil {
+				return nil, err
+			}
+			if err := tx.Model(&previous).Updates(map[string]interface{}{
+				"name":        testCase.Name,
+				"description": testCase.Description,
+				"test_spec":   spec,
+				"is_active":   true,
+			}).Error; err != nil {
+				return nil, fmt.Errorf("failed to update smoke test '%s': %v", testCase.Name, err)
+			}
+			testIDs = append(testIDs, previous.ID)
+			continue
+		}
+		if err := tx.Create(&testCase).Error; err != nil {
+			return nil, fmt.Errorf("failed to create smoke test '%s': %v", testCase.Name, err)
+		}
+		testIDs = append(testIDs, testCase.ID)
+	}
+
+	var suite models.TestSuite
+	err := tx.Where("service_id = ? AND tags @> ?", service.ID, tagJSON(SmokeTag)).First(&suite).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		suite = models.TestSuite{
+			Name:        service.Name + " (" + SmokeTag + ")",
+			Description: "Generated smoke suite with one happy-path test per endpoint of " + service.Name,
+			ServiceID:   &service.ID,
+			TestIDs:     testIDs,
+			Tags:        models.StringList{SmokeTag},
+			ProjectID:   service.ProjectID,
+		}
+		if err := tx.Create(&suite).Error; err != nil {
+			return nil, fmt.Errorf("failed to create smoke suite: %v", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to retrieve smoke suite: %v", err)
+	default:
+		suite.TestIDs = testIDs
+		if err := tx.Model(&suite).Update("test_ids", testIDs).Error; err != nil {
+			return nil, fmt.Errorf("failed to update smoke suite: %v", err)
+		}
+	}
+	return &suite, nil
+}
+
+// smokeSpec returns the smoke test of an HTTP test expecting a success
+// status: its request, asserting only the status. Data-driven tests, whose
+// requests need the variables of their rows, are left out.
+func smokeSpec(source models.TestCase) (models.TestSpec, bool) {
+	var spec models.TestSpec
+	if json.Unmarshal([]byte(source.TestSpec), &spec) != nil {
+		return spec, false
+	}
+	if (spec.Protocol != "" && spec.Protocol != "http") || spec.Request.URL == "" || len(spec.Data) > 0 || spec.Dataset != nil {
+		return spec, false
+	}
+
+	smoke := models.TestSpec{
+		Name:        "Smoke: " + source.Name,
+		Description: spec.Description,
+		Request:     spec.Request,
+		TimeoutMs:   spec.TimeoutMs,
+		EgressPool:  spec.EgressPool,
+	}
+	for _, assertion := range spec.Assertions {
+		if assertion.Type != "status_code" && assertion.Type != "status_class" {
+			continue
+		}
+		if !expectsSuccess(assertion) {
+			return spec, false
+		}
+		smoke.Assertions = append(smoke.Assertions, assertion)
+	}
+	if len(smoke.Assertions) == 0 {
+		smoke.Assertions = []models.AssertionSpec{{Type: "status_code", Expected: 200}}
+	}
+	return smoke, true
+}
+
+// expectsSuccess reports whether a status assertion expects a 2xx status,
+// given as a code, or as the class 2 or "2xx"
+func expectsSuccess(assertion models.AssertionSpec) bool {
+	var code int
+	switch expected := assertion.Expected.(type) {
+	case float64:
+		code = int(expected)
+	case int:
+		code = expected
+	case string:
+		expected = strings.ToLower(strings.TrimSpace(expected))
+		if expected == "2xx" {
+			return true
+		}
+		parsed, err := strconv.Atoi(expected)
+		if err != nil {
+			return false
+		}
+		code = parsed
+	default:
+		return false
+	}
+	if assertion.Type == "status_class" {
+		return code == 2
+	}
+	return code >= 200 && code < 300
+}
+
+// smokeEndpoint identifies the endpoint of a request by its method and path,
+// e.g. "GET /patients/{{id}}"
+func smokeEndpoint(request models.RequestSpec) string {
+	path, _, _ := strings.Cut(request.URL, "?")
+	if _, rest, ok := strings.Cut(path, "://"); ok {
+		path = "/"
+		if i := strings.Index(rest, "/"); i >= 0 {
+			path = rest[i:]
+		}
+	}
+	method := strings.ToUpper(request.Method)
+	if method == "" {
+		method = "GET"
+	}
+	return method + " " + path
+}
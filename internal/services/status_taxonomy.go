@@ -0,0 +1,125 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"api-test-framework/internal/models"
+)
+
+// newStatusCodeSummary returns an empty summary
+func newStatusCodeSummary() models.StatusCodeSummary {
+	return models.StatusCodeSummary{
+		Codes:      map[string]int{},
+		Classes:    map[string]int{},
+		Unexpected: map[string]int{},
+	}
+}
+
+// countStatus adds an observed status code to a summary; 0 means no response
+func countStatus(summary *models.StatusCodeSummary, statusCode int) {
+	if statusCode == 0 {
+		summary.NoResponse++
+		return
+	}
+	summary.Codes[strconv.Itoa(statusCode)]++
+	summary.Classes[fmt.Sprintf("%dxx", statusCode/100)]++
+}
+
+// buildStatusTaxonomy aggregates every status code observed in the responses
+// of a run, overall and per service, independently of the assertions. Codes
+// that failed a status_code assertion are additionally reported as unexpected.
+func (s *TestRunService) buildStatusTaxonomy(testRunID string) (models.StatusTaxonomy, error) {
+	taxonomy := models.StatusTaxonomy{
+		Total:    newStatusCodeSummary(),
+		Services: map[string]models.StatusCodeSummary{},
+	}
+
+	var rows []struct {
+		ServiceID    string
+		Status       string
+		ResponseData string
+	}
+	err := s.db.Table("test_results").
+		Select("test_cases.service_id, test_results.status, test_results.response_data").
+		Joins("JOIN test_cases ON test_cases.id = test_results.test_case_id").
+		Where("test_results.test_run_id = ?", testRunID).
+		Scan(&rows).Error
+	if err != nil {
+		return taxonomy, err
+	}
+
+	serviceSummary := func(serviceID string) models.StatusCodeSummary {
+		summary, ok := taxonomy.Services[serviceID]
+		if !ok {
+			summary = newStatusCodeSummary()
+		}
+		return summary
+	}
+
+	for _, row := range rows {
+		// Skipped tests never sent a request
+		if row.Status == "skipped" {
+			continue
+		}
+
+		summary := serviceSummary(row.ServiceID)
+		for _, statusCode := range observedStatusCodes(row.ResponseData) {
+			countStatus(&taxonomy.Total, statusCode)
+			countStatus(&summary, statusCode)
+		}
+		taxonomy.Services[row.ServiceID] = summary
+	}
+
+	var unexpected []struct {
+		ServiceID string
+		Actual    string
+	}
+	err = s.db.Table("assertion_results").
+		Select("test_cases.service_id, assertion_results.actual").
+		Joins("JOIN test_results ON test_results.id = assertion_results.test_result_id").
+		Joins("JOIN test_cases ON test_cases.id = test_results.test_case_id").
+		Where("test_results.test_run_id = ? AND assertion_results.type IN ? AND NOT assertion_results.passed", testRunID, []string{"status_code", "status_class"}).
+		Scan(&unexpected).Error
+	if err != nil {
+		return taxonomy, err
+	}
+
+	for _, row := range unexpected {
+		summary := serviceSummary(row.ServiceID)
+		taxonomy.Total.Unexpected[row.Actual]++
+		summary.Unexpected[row.Actual]++
+		taxonomy.Services[row.ServiceID] = summary
+	}
+
+	return taxonomy, nil
+}
+
+// observedStatusCodes extracts the status codes of captured response data,
+// one per variant for request matrix tests. Responses without a status code
+// are reported as 0.
+func observedStatusCodes(responseData string) []int {
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(responseData), &data); err != nil {
+		return []int{0}
+	}
+
+	if variants, ok := data["variants"].([]interface{}); ok {
+		codes := make([]int, 0, len(variants))
+		for _, variant := range variants {
+			variantData, _ := variant.(map[string]interface{})
+			response, _ := variantData["response_data"].(map[string]interface{})
+			codes = append(codes, statusCodeOf(response))
+		}
+		return codes
+	}
+
+	return []int{statusCodeOf(data)}
+}
+
+// statusCodeOf returns the status code of captured response data, or 0
+func statusCodeOf(data map[string]interface{}) int {
+	statusCode, _ := data["status_code"].(float64)
+	return int(statusCode)
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// Branches of conditional suite steps
+const (
+	BranchThen = "then"
+	BranchElse = "else"
+)
+
+// runBranch executes a step or, for a conditional step, the first step of
+// its if/else chain whose condition holds. Conditions are evaluated against
+// the variables the step would run with. When no condition holds and the
+// chain has no unconditional else step, the step is recorded as skipped.
+func (s *TestRunService) runBranch(ctx context.Context, testRun *models.TestRun, suite *models.TestSuite, scope *stepScope, step models.SuiteStep) models.HookResult {
+	if step.If == nil {
+		return s.runLoop(ctx, testRun, suite, scope, step)
+	}
+
+	branch := BranchThen
+	current := step
+	var evaluations, variables []string
+	for current.If != nil {
+		vars := scope.values(runVariables(testRun, stepServiceID(suite, current)))
+		met, evaluation := stepConditionMet(current.If, vars)
+		evaluations = append(evaluations, evaluation)
+		variables = append(variables, current.If.Variable)
+		if met {
+			break
+		}
+		if current.Else == nil {
+			return models.HookResult{
+				Name:      step.Name,
+				StepID:    step.ID,
+				Status:    "skipped",
+				StartedAt: time.Now(),
+				Uses:      mergeVariables(nil, variables),
+				Condition: strings.Join(evaluations, "; "),
+			}
+		}
+		current = *current.Else
+		branch = BranchElse
+	}
+
+	result := s.runLoop(ctx, testRun, suite, scope, current)
+	result.Branch = branch
+	result.Condition = strings.Join(evaluations, "; ")
+	result.Uses = mergeVariables(result.Uses, variables)
+	return result
+}
+
+// stepConditionMet reports whether a step condition holds for the
+// variables, and how it evaluated. Values are compared as they substitute
+// into requests, so numbers and booleans compare by their JSON text.
+func stepConditionMet(condition *models.StepCondition, vars map[string]string) (bool, string) {
+	value, set := vars[condition.Variable]
+	expected := conditionText(condition.Expected)
+	described := fmt.Sprintf("%s is %q", condition.Variable, value)
+	if !set {
+		described = condition.Variable + " is not set"
+	}
+
+	var met bool
+	switch conditionMatcher(condition) {
+	case "exists":
+		met = set
+	case "not_exists":
+		met = !set
+	case "equals":
+		met = set && value == expected
+		described += fmt.Sprintf(", expected %q", expected)
+	case "not_equals":
+		met = !set || value != expected
+		described += fmt.Sprintf(", expected anything but %q", expected)
+	case "contains":
+		met = set && strings.Contains(value, expected)
+		described += fmt.Sprintf(", expected to contain %q", expected)
+	case "regex":
+		pattern, err := regexp.Compile(expected)
+		met = err == nil && set && pattern.MatchString(value)
+		described += fmt.Sprintf(", expected to match %q", expected)
+	}
+	if met {
+		return true, described + ": condition met"
+	}
+	return false, described + ": condition not met"
+}
+
+// conditionMatcher returns the matcher of a condition: equals when a value
+// is expected, exists otherwise
+func conditionMatcher(condition *models.StepCondition) string {
+	if condition.Matcher != "" {
+		return condition.Matcher
+	}
+	if condition.Expected != nil {
+		return "equals"
+	}
+	return "exists"
+}
+
+// conditionText returns the text an expected value compares as: strings
+// as they are, anything else as JSON, like captured step values
+func conditionText(expected interface{}) string {
+	if text, ok := expected.(string); ok {
+		return text
+	}
+	if expected == nil {
+		return ""
+	}
+	data, err := json.Marshal(expected)
+	if err != nil {
+		return fmt.Sprint(expected)
+	}
+	return string(data)
+}
+
+// validateStepCondition checks the condition of a suite step
+func validateStepCondition(condition *models.StepCondition) error {
+	if condition.Variable == "" {
+		return fmt.Errorf("condition needs a variable")
+	}
+	switch conditionMatcher(condition) {
+	case "exists", "not_exists":
+	case "equals", "not_equals", "contains":
+		if condition.Expected == nil {
+			return fmt.Errorf("condition matcher %s needs an expected value", condition.Matcher)
+		}
+	case "regex":
+		if condition.Expected == nil {
+			return fmt.Errorf("condition matcher regex needs an expected pattern")
+		}
+		if _, err := regexp.Compile(conditionText(condition.Expected)); err != nil {
+			return fmt.Errorf("condition has an invalid regex: %v", err)
+		}
+	default:
+		return fmt.Errorf("unknown condition matcher %q", condition.Matcher)
+	}
+	return nil
+}
+
+// stepBranches returns a step followed by the steps of its else chain
+func stepBranches(step models.SuiteStep) []models.SuiteStep {
+	branches := []models.SuiteStep{step}
+	for step.Else != nil {
+		step = *step.Else
+		branches = append(branches, step)
+	}
+	return branches
+}
+
+// mergeVariables adds variable names to a sorted list of names, once each
+func mergeVariables(names, added []string) []string {
+	seen := make(map[string]bool, len(names)+len(added))
+	var merged []string
+	for _, name := range append(append([]string{}, names...), added...) {
+		if !seen[name] {
+			seen[name] = true
+			merged = append(merged, name)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
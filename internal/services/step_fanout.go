@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// maxFanOutRequests limits the steps a fan-out step runs at once, repeats
+// included
+const maxFanOutRequests = 50
+
+// isFanOutStep reports whether a step runs other steps concurrently instead
+// of sending a request
+func isFanOutStep(step models.SuiteStep) bool {
+	return step.Parallel != nil
+}
+
+// runFanOut executes the steps of a fan-out step concurrently and waits for
+// all of them. They are released together so their requests overlap as much
+// as possible, which is what tests of optimistic locking and version
+// conflicts need. The step fails if any of its steps failed or the status
+// codes of their requests do not add up to the expected counts.
+func (s *TestRunService) runFanOut(ctx context.Context, testRun *models.TestRun, suite *models.TestSuite, scope *stepScope, step models.SuiteStep) models.HookResult {
+	fanOut := step.Parallel
+	result := models.HookResult{
+		Name:        step.Name,
+		StepID:      step.ID,
+		Status:      "failed",
+		FailureType: testrunner.FailureAssertion,
+		StartedAt:   time.Now(),
+	}
+
+	copies := fanOutRepeat(fanOut)
+	steps := make([]models.SuiteStep, 0, len(fanOut.Steps)*copies)
+	for i, sub := range fanOut.Steps {
+		if len(fanOut.ExpectStatusCounts) > 0 {
+			sub = countedStep(sub, fanOut.ExpectStatusCounts)
+		}
+		for n := 1; n <= copies; n++ {
+			name := sub.Name
+			if name == "" {
+				name = fmt.Sprintf("parallel #%d", i+1)
+			}
+			if copies > 1 {
+				name = fmt.Sprintf("%s (%d/%d)", name, n, copies)
+			}
+			named := sub
+			named.Name = name
+			steps = append(steps, named)
+		}
+	}
+
+	results := make(models.HookResults, len(steps))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, sub := range steps {
+		wg.Add(1)
+		go func(i int, sub models.SuiteStep) {
+			defer wg.Done()
+			<-start
+			results[i] = s.runBranch(ctx, testRun, suite, scope, sub)
+		}(i, sub)
+	}
+	close(start)
+	wg.Wait()
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+	result.Parallel = results
+
+	var failures []string
+	counts := map[int]int{}
+	for _, executed := range results {
+		result.Uses = mergeVariables(result.Uses, executed.Uses)
+		result.Exports = mergeVariables(result.Exports, executed.Exports)
+		if executed.StatusCode != 0 {
+			counts[executed.StatusCode]++
+		}
+		if executed.Status == "failed" {
+			if len(failures) == 0 {
+				result.FailureType = executed.FailureType
+			}
+			failures = append(failures, fmt.Sprintf("%s: %s", executed.Name, executed.ErrorMessage))
+		}
+	}
+
+	if len(failures) > 0 {
+		described := failures
+		if len(described) > maxLoopFailures {
+			described = append(described[:maxLoopFailures:maxLoopFailures], fmt.Sprintf("and %d more", len(failures)-maxLoopFailures))
+		}
+		result.ErrorMessage = fmt.Sprintf("%d of %d parallel steps failed: %s", len(failures), len(results), strings.Join(described, "; "))
+		return result
+	}
+	if expected := fanOut.ExpectStatusCounts; len(expected) > 0 && !statusCountsMatch(counts, expected) {
+		result.ErrorMessage = fmt.Sprintf("expected status counts %s, got %s", describeStatusCounts(expected), describeStatusCounts(counts))
+		result.FailureType = testrunner.FailureAssertion
+		return result
+	}
+	result.Status = "passed"
+	result.FailureType = ""
+	return result
+}
+
+// fanOutRepeat returns how many copies of every step a fan-out step sends
+func fanOutRepeat(fanOut *models.StepFanOut) int {
+	if fanOut.Repeat > 0 {
+		return fanOut.Repeat
+	}
+	return 1
+}
+
+// countedStep lets a step of a fan-out with expected status counts accept
+// every counted status: the counts decide the outcome, so a 409 lost to a
+// concurrent update does not fail the step. Steps asserting on the status
+// themselves are left as they are.
+func countedStep(step models.SuiteStep, counts map[int]int) models.SuiteStep {
+	for _, assertion := range step.Assertions {
+		if assertion.Type == "status_code" || assertion.Type == "status_class" {
+			return step
+		}
+	}
+	codes := make([]interface{}, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].(int) < codes[j].(int) })
+
+	// The assertions are shared by every copy of the step
+	assertions := make([]models.AssertionSpec, 0, len(step.Assertions)+1)
+	assertions = append(assertions, step.Assertions...)
+	step.Assertions = append(assertions, models.AssertionSpec{Type: "status_code", Expected: codes})
+	return step
+}
+
+// statusCountsMatch reports whether requests got exactly the expected number
+// of every status code, and no other codes
+func statusCountsMatch(counts, expected map[int]int) bool {
+	for code, count := range counts {
+		if expected[code] != count {
+			return false
+		}
+	}
+	for code, count := range expected {
+		if counts[code] != count {
+			return false
+		}
+	}
+	return true
+}
+
+// describeStatusCounts renders status counts as "200: 1, 409: 4"
+func describeStatusCounts(counts map[int]int) string {
+	codes := make([]int, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	described := make([]string, 0, len(codes))
+	for _, code := range codes {
+		described = append(described, fmt.Sprintf("%d: %d", code, counts[code]))
+	}
+	if len(described) == 0 {
+		return "none"
+	}
+	return strings.Join(described, ", ")
+}
+
+// validateFanOutStep checks a fan-out step; its steps are validated by the caller
+func validateFanOutStep(step models.SuiteStep) error {
+	fanOut := step.Parallel
+	if step.Request.Method != "" || step.Request.URL != "" || step.WaitMs != 0 {
+		return fmt.Errorf("parallel is only allowed on steps without a request or wait_ms")
+	}
+	if step.ID != "" || len(step.Export) > 0 {
+		// The steps of the fan-out have their own ids and exports
+		return fmt.Errorf("fan-out steps cannot have an id or exports")
+	}
+	if len(fanOut.Steps) == 0 {
+		return fmt.Errorf("parallel needs at least one step")
+	}
+	if fanOut.Repeat < 0 {
+		return fmt.Errorf("parallel repeat must not be negative")
+	}
+	if total := len(fanOut.Steps) * fanOutRepeat(fanOut); total > maxFanOutRequests {
+		return fmt.Errorf("parallel runs %d steps, more than %d", total, maxFanOutRequests)
+	}
+	for code, count := range fanOut.ExpectStatusCounts {
+		if code < 100 || code > 599 || count < 0 {
+			return fmt.Errorf("expect_status_counts must map status codes to counts")
+		}
+	}
+	for i, sub := range fanOut.Steps {
+		for _, branch := range stepBranches(sub) {
+			if branch.Parallel != nil {
+				return fmt.Errorf("parallel step %d cannot fan out itself", i+1)
+			}
+			if fanOutRepeat(fanOut) > 1 && (branch.ID != "" || len(branch.Export) > 0) {
+				// Every copy would capture the same id and variables
+				return fmt.Errorf("parallel step %d cannot have an id or exports when repeated", i+1)
+			}
+		}
+	}
+	return nil
+}
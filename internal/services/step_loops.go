@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+
+	"github.com/tidwall/gjson"
+)
+
+// Limits of loop steps
+const (
+	defaultLoopIterations = 100
+	maxLoopIterations     = 1000
+	maxLoopFailures       = 3 // failed iterations described in the error message
+)
+
+// defaultLoopVariable names the item of a loop step without an as
+const defaultLoopVariable = "item"
+
+// runLoop executes a step, or a loop step once for every item of its array.
+// Every iteration runs, so a loop asserting on each entry of a list reports
+// all failing entries; the step fails if any iteration failed. Arrays larger
+// than the maximum iterations fail the step before any request is sent. The
+// response of the last iteration is available under the ID of the step.
+func (s *TestRunService) runLoop(ctx context.Context, testRun *models.TestRun, suite *models.TestSuite, scope *stepScope, step models.SuiteStep) models.HookResult {
+	loop := step.ForEach
+	if loop == nil {
+		return s.runStep(ctx, testRun, suite, scope, step)
+	}
+
+	result := models.HookResult{
+		Name:        step.Name,
+		StepID:      step.ID,
+		Status:      "failed",
+		FailureType: testrunner.FailureAssertion,
+		StartedAt:   time.Now(),
+		Uses:        mergeVariables(testrunner.Placeholders(step.TestSpec), []string{loop.Over}),
+	}
+	vars := scope.values(runVariables(testRun, stepServiceID(suite, step)))
+	raw, set := vars[loop.Over]
+	if !set {
+		result.ErrorMessage = fmt.Sprintf("for_each: %s is not set", loop.Over)
+		return result
+	}
+	items := gjson.Parse(raw)
+	if !items.IsArray() {
+		result.ErrorMessage = fmt.Sprintf("for_each: %s is not an array", loop.Over)
+		return result
+	}
+	list := items.Array()
+	if limit := loopIterations(loop); len(list) > limit {
+		result.ErrorMessage = fmt.Sprintf("for_each: %s has %d items, more than max_iterations %d", loop.Over, len(list), limit)
+		return result
+	}
+
+	as := loopVariable(loop)
+	var failures []string
+	var last *stepScope
+	for i, item := range list {
+		if ctx.Err() != nil {
+			failures = append(failures, fmt.Sprintf("iteration %d: %v", i, ctx.Err()))
+			break
+		}
+		// Each iteration sees its own item, layered on the scope of the step
+		iteration := newStepScope(scope)
+		flattenResponse(iteration.exports, as, item)
+		iteration.exports[as+"_index"] = strconv.Itoa(i)
+
+		executed := s.runStep(ctx, testRun, suite, iteration, step)
+		result.Iterations++
+		result.DurationMs += executed.DurationMs
+		result.StatusCode = executed.StatusCode
+		result.Uses = mergeVariables(result.Uses, executed.Uses)
+		if executed.Status == "failed" {
+			if len(failures) == 0 {
+				result.FailureType = executed.FailureType
+			}
+			failures = append(failures, fmt.Sprintf("iteration %d: %s", i, executed.ErrorMessage))
+		}
+		last = iteration
+	}
+
+	if len(failures) > 0 {
+		described := failures
+		if len(described) > maxLoopFailures {
+			described = append(described[:maxLoopFailures:maxLoopFailures], fmt.Sprintf("and %d more", len(failures)-maxLoopFailures))
+		}
+		result.ErrorMessage = fmt.Sprintf("%d of %d iterations failed: %s", len(failures), len(list), strings.Join(described, "; "))
+		return result
+	}
+	if step.ID != "" && last != nil {
+		if err := scope.capture(models.SuiteStep{ID: step.ID}, last.responses[step.ID]); err != nil {
+			result.ErrorMessage = err.Error()
+			return result
+		}
+	}
+	result.Status = "passed"
+	result.FailureType = ""
+	return result
+}
+
+// loopVariable returns the name of the item variable of a loop
+func loopVariable(loop *models.StepLoop) string {
+	if loop.As != "" {
+		return loop.As
+	}
+	return defaultLoopVariable
+}
+
+// loopIterations returns the most items a loop runs for
+func loopIterations(loop *models.StepLoop) int {
+	if loop.MaxIterations > 0 {
+		return loop.MaxIterations
+	}
+	return defaultLoopIterations
+}
+
+// validateStepLoop checks the loop of a suite step
+func validateStepLoop(step models.SuiteStep) error {
+	loop := step.ForEach
+	if loop.Over == "" {
+		return fmt.Errorf("for_each needs the variable of an array in over")
+	}
+	as := loopVariable(loop)
+	if !stepNamePattern.MatchString(as) || reservedVariables[as] || as == "steps" {
+		return fmt.Errorf("for_each cannot name its item %q", as)
+	}
+	if loop.MaxIterations < 0 || loop.MaxIterations > maxLoopIterations {
+		return fmt.Errorf("for_each max_iterations must be between 1 and %d", maxLoopIterations)
+	}
+	if len(step.Export) > 0 {
+		// Every iteration would export the same variables
+		return fmt.Errorf("loop steps cannot export variables")
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"api-test-framework/internal/models"
+
+	"github.com/tidwall/gjson"
+)
+
+// stepNamespace prefixes the variables holding the responses of suite steps
+const stepNamespace = "steps."
+
+// stepNamePattern matches step IDs and exported variable names; neither may
+// contain dots, so exports cannot reach into the steps namespace
+var stepNamePattern = regexp.MustCompile(`^[A-Za-z0-9_\-]+$`)
+
+// reservedVariables cannot be exported by steps as every request depends on them
+var reservedVariables = map[string]bool{"base_url": true, "api_version": true}
+
+// stepScope holds the responses and exports of the suite steps that ran in
+// a scope. The before_all and after_all steps share the scope of the run;
+// before_each and after_each steps get a scope per test case, layered on the
+// run's, so concurrent test cases never see each other's steps.
+type stepScope struct {
+	parent *stepScope
+
+	mu        sync.Mutex
+	responses map[string]string // response data by step ID
+	exports   map[string]string // exported value by variable name
+}
+
+// newStepScope creates a scope layered on parent, which may be nil
+func newStepScope(parent *stepScope) *stepScope {
+	return &stepScope{parent: parent, responses: map[string]string{}, exports: map[string]string{}}
+}
+
+// capture records the response of a step and resolves its exports. An
+// export whose path is missing from the response, or whose name was already
+// exported in the scope, fails the step instead of overwriting the value.
+func (s *stepScope) capture(step models.SuiteStep, responseData string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if step.ID != "" {
+		s.responses[step.ID] = responseData
+	}
+	for name, path := range step.Export {
+		value := gjson.Get(responseData, path)
+		if !value.Exists() {
+			return fmt.Errorf("export %s: path %s not found in the response", name, path)
+		}
+		if _, exported := s.lookupExport(name); exported {
+			return fmt.Errorf("export %s: variable was already exported by an earlier step", name)
+		}
+		s.exports[name] = variableString(value)
+	}
+	return nil
+}
+
+// lookupExport returns a variable exported in the scope or its parents;
+// callers hold s.mu
+func (s *stepScope) lookupExport(name string) (string, bool) {
+	if value, ok := s.exports[name]; ok {
+		return value, true
+	}
+	if s.parent == nil {
+		return "", false
+	}
+	s.parent.mu.Lock()
+	defer s.parent.mu.Unlock()
+	return s.parent.lookupExport(name)
+}
+
+// values returns vars with the exports of the scope and the responses of
+// its steps added; exports take precedence over vars
+func (s *stepScope) values(vars map[string]string) map[string]string {
+	if s == nil {
+		return vars
+	}
+	values := s.parent.values(vars)
+	if s.parent == nil {
+		// Work on a copy, the variables of a service are shared
+		values = make(map[string]string, len(vars))
+		for key, value := range vars {
+			values[key] = value
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, value := range s.exports {
+		values[name] = value
+	}
+	for id, responseData := range s.responses {
+		response := gjson.Parse(responseData)
+		flattenResponse(values, stepNamespace+id, response)
+		// Headers hold lists of values; the name alone refers to the first
+		response.Get("headers").ForEach(func(name, header gjson.Result) bool {
+			values[stepNamespace+id+".headers."+name.String()] = variableString(header.Get("0"))
+			return true
+		})
+	}
+	return values
+}
+
+// flattenResponse adds a variable for every value of captured response
+// data, keyed by its gjson path under prefix: steps.<id>.status_code or
+// steps.<id>.body.entry.0.id. Objects and arrays are added as JSON as well.
+func flattenResponse(values map[string]string, prefix string, value gjson.Result) {
+	values[prefix] = variableString(value)
+	if !value.IsObject() && !value.IsArray() {
+		return
+	}
+	index := 0
+	value.ForEach(func(key, member gjson.Result) bool {
+		name := key.String()
+		if value.IsArray() {
+			name = strconv.Itoa(index)
+			index++
+		}
+		flattenResponse(values, prefix+"."+name, member)
+		return true
+	})
+}
+
+// variableString returns the substitution text of a value: strings without
+// their quotes, anything else as JSON
+func variableString(value gjson.Result) string {
+	if value.Type == gjson.String {
+		return value.String()
+	}
+	return value.Raw
+}
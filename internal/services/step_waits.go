@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// maxStepWait limits the pause of a wait step
+const maxStepWait = 10 * time.Minute
+
+// isWaitStep reports whether a step only waits instead of sending a request
+func isWaitStep(step models.SuiteStep) bool {
+	return step.WaitMs > 0
+}
+
+// runWait pauses for the duration of a wait step. A wait interrupted by the
+// cancellation of the run fails the step.
+func runWait(ctx context.Context, step models.SuiteStep) models.HookResult {
+	result := models.HookResult{
+		Name:      step.Name,
+		StepID:    step.ID,
+		Status:    "passed",
+		StartedAt: time.Now(),
+	}
+
+	timer := time.NewTimer(time.Duration(step.WaitMs) * time.Millisecond)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		result.Status = "failed"
+		result.FailureType = testrunner.FailureTimeout
+		result.ErrorMessage = fmt.Sprintf("wait of %dms interrupted: %v", step.WaitMs, ctx.Err())
+	}
+	result.DurationMs = time.Since(result.StartedAt).Milliseconds()
+	return result
+}
+
+// validateWaitStep checks a step that only waits
+func validateWaitStep(step models.SuiteStep) error {
+	if step.Request.Method != "" || step.Request.URL != "" || step.Parallel != nil {
+		return fmt.Errorf("wait_ms is only allowed on steps without a request or parallel steps")
+	}
+	if time.Duration(step.WaitMs)*time.Millisecond > maxStepWait {
+		return fmt.Errorf("wait_ms must be at most %d", maxStepWait.Milliseconds())
+	}
+	if step.ID != "" || len(step.Export) > 0 || step.ForEach != nil {
+		// A wait has no response to capture or loop over
+		return fmt.Errorf("wait steps cannot have an id, exports or for_each")
+	}
+	return nil
+}
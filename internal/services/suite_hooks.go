@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+)
+
+// Suite step phases
+const (
+	PhaseBeforeAll  = "before_all"
+	PhaseAfterAll   = "after_all"
+	PhaseBeforeEach = "before_each"
+	PhaseAfterEach  = "after_each"
+)
+
+// suiteRun tracks the step outcomes of a run executing a suite
+type suiteRun struct {
+	suite *models.TestSuite
+	scope *stepScope // responses and exports of the before_all and after_all steps
+
+	mu         sync.Mutex
+	results    models.HookResults
+	failed     bool
+	unexported map[string]string // variables of test exports left unset, by the name of the test case
+}
+
+// record appends the outcome of a step
+func (r *suiteRun) record(result models.HookResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+	r.failed = r.failed || result.Status == "failed"
+}
+
+// outcome returns the recorded step outcomes and whether any step failed
+func (r *suiteRun) outcome() (models.HookResults, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append(models.HookResults{}, r.results...), r.failed
+}
+
+// suiteStepServices returns the IDs of the services the steps of a suite run against
+func suiteStepServices(suite *models.TestSuite) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, steps := range []models.SuiteSteps{suite.BeforeAll, suite.AfterAll, suite.BeforeEach, suite.AfterEach} {
+		for _, step := range steps {
+			for _, branch := range stepBranches(step) {
+				branches := []models.SuiteStep{branch}
+				if branch.Parallel != nil {
+					// A fan-out step runs against the services of its steps
+					branches = nil
+					for _, sub := range branch.Parallel.Steps {
+						branches = append(branches, stepBranches(sub)...)
+					}
+				}
+				for _, branch := range branches {
+					id := stepServiceID(suite, branch)
+					if id != "" && !seen[id] {
+						seen[id] = true
+						ids = append(ids, id)
+					}
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// stepServiceID returns the service a step runs against
+func stepServiceID(suite *models.TestSuite, step models.SuiteStep) string {
+	if step.ServiceID != "" {
+		return step.ServiceID
+	}
+	if suite.ServiceID != nil {
+		return *suite.ServiceID
+	}
+	return ""
+}
+
+// runSteps executes the steps of a phase in order, capturing their responses
+// in scope, and records their outcomes. Setup steps stop at the first
+// failure, which is returned; teardown steps always all run so cleanup is as
+// complete as possible. Skipped conditional steps do not fail a phase.
+func (s *TestRunService) runSteps(ctx context.Context, testRun *models.TestRun, run *suiteRun, scope *stepScope, phase string, steps models.SuiteSteps, testCaseID string) *models.HookResult {
+	var firstFailure *models.HookResult
+	for i, step := range steps {
+		result := s.runBranch(ctx, testRun, run.suite, scope, step)
+		result.Phase = phase
+		result.TestCaseID = testCaseID
+		if result.Name == "" {
+			result.Name = fmt.Sprintf("%s #%d", phase, i+1)
+		}
+		run.record(result)
+
+		if result.Status == "failed" && firstFailure == nil {
+			firstFailure = &result
+			if strings.HasPrefix(phase, "before") {
+				break
+			}
+		}
+	}
+	return firstFailure
+}
+
+// runStep executes a single suite step with the variables resolved for its
+// service and the variables of the steps that ran before it in scope
+func (s *TestRunService) runStep(ctx context.Context, testRun *models.TestRun, suite *models.TestSuite, scope *stepScope, step models.SuiteStep) models.HookResult {
+	if isWaitStep(step) {
+		return runWait(ctx, step)
+	}
+	if isFanOutStep(step) {
+		return s.runFanOut(ctx, testRun, suite, scope, step)
+	}
+
+	result := models.HookResult{
+		Name:        step.Name,
+		StepID:      step.ID,
+		Status:      "failed",
+		FailureType: testrunner.FailureSpec,
+		StartedAt:   time.Now(),
+		Uses:        testrunner.Placeholders(step.TestSpec),
+	}
+
+	serviceID := stepServiceID(suite, step)
+	if serviceID == "" {
+		result.ErrorMessage = "step has no service_id and the suite has no default service"
+		return result
+	}
+	var service models.Service
+	if err := s.db.WithContext(ctx).First(&service, "id = ?", serviceID).Error; err != nil {
+		result.ErrorMessage = fmt.Sprintf("service %s not found: %v", serviceID, err)
+		return result
+	}
+
+	// Steps are shared by every execution, so each one works on its own copy
+	var spec models.TestSpec
+	data, _ := json.Marshal(step.TestSpec)
+	if err := json.Unmarshal(data, &spec); err != nil {
+		result.ErrorMessage = fmt.Sprintf("invalid step: %v", err)
+		return result
+	}
+	if spec.Assertions == nil {
+		// Without assertions a step only has to succeed with a status below 400
+		spec.Assertions = []models.AssertionSpec{}
+	}
+	vars := scope.values(runVariables(testRun, serviceID))
+	testrunner.ApplyVariables(&spec, secretPlaceholders(vars))
+
+	resolvedSpec, _, err := s.resolveSpecSecrets(ctx, &spec)
+	if err != nil {
+		result.ErrorMessage = err.Error()
+		return result
+	}
+	executor, err := s.newExecutor(ctx, spec.Protocol, service, vars["base_url"], service.APIVersioning.Default)
+	if err != nil {
+		result.ErrorMessage = err.Error()
+		return result
+	}
+
+	executed := executeSpec(ctx, executor, resolvedSpec, testTimeoutFor(spec.TimeoutMs, testRun.TestTimeoutMs, service.TimeoutMs))
+	result.DurationMs = executed.Duration.Milliseconds()
+	result.StatusCode = capturedStatusCode(executed.ResponseData)
+	result.Polls = executed.Polls
+	result.ErrorMessage = executed.ErrorMessage
+	result.FailureType = executed.FailureType
+	if executed.Status != "FAILED" {
+		if err := scope.capture(step, executed.ResponseData); err != nil {
+			// The response lacks what later requests depend on
+			result.ErrorMessage = err.Error()
+			result.FailureType = testrunner.FailureAssertion
+			return result
+		}
+		result.Status = "passed"
+		result.FailureType = ""
+		for name := range step.Export {
+			result.Exports = append(result.Exports, name)
+		}
+		sort.Strings(result.Exports)
+	}
+	return result
+}
+
+// teardownContext returns the context teardown steps run with: they keep the
+// values of ctx but run even after the run was cancelled or timed out
+func teardownContext(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
+
+// stepFailureReason explains why a test case did not run because of a failing step
+func stepFailureReason(failure *models.HookResult) string {
+	return fmt.Sprintf("%s step '%s' failed: %s", failure.Phase, failure.Name, failure.ErrorMessage)
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSuite is returned when a test suite fails validation
+var ErrInvalidSuite = errors.New("invalid test suite")
+
+// SuiteService handles test suite operations
+type SuiteService struct {
+	db             *gorm.DB
+	testRunService *TestRunService
+}
+
+// NewSuiteService creates a new suite service
+func NewSuiteService(db *gorm.DB, testRunService *TestRunService) *SuiteService {
+	return &SuiteService{db: db, testRunService: testRunService}
+}
+
+// CreateSuite validates and creates a test suite
+func (s *SuiteService) CreateSuite(suite *models.TestSuite) error {
+	if err := s.validateSuite(suite); err != nil {
+		return err
+	}
+	suite.Tags = normalizeTags(suite.Tags)
+	return s.db.Create(suite).Error
+}
+
+// GetSuite retrieves a test suite by ID
+func (s *SuiteService) GetSuite(id string) (*models.TestSuite, error) {
+	var suite models.TestSuite
+	if err := s.db.First(&suite, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &suite, nil
+}
+
+// ListSuites retrieves the test suites of a project, or all test suites
+// without projectID, with pagination
+func (s *SuiteService) ListSuites(projectID string, limit, offset int) ([]models.TestSuite, int64, error) {
+	var suites []models.TestSuite
+	var total int64
+
+	query := inProject(s.db.Model(&models.TestSuite{}), projectID)
+
+	// Get total count
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// Get paginated results
+	if err := query.Order("name").Limit(limit).Offset(offset).Find(&suites).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return suites, total, nil
+}
+
+// UpdateSuite validates and saves a modified test suite
+func (s *SuiteService) UpdateSuite(suite *models.TestSuite) error {
+	if err := s.validateSuite(suite); err != nil {
+		return err
+	}
+	suite.Tags = normalizeTags(suite.Tags)
+	return s.db.Save(suite).Error
+}
+
+// DeleteSuite deletes a test suite
+func (s *SuiteService) DeleteSuite(id string) error {
+	return s.db.Delete(&models.TestSuite{}, "id = ?", id).Error
+}
+
+// RunSuite starts a test run executing the suite as a unit: its test cases
+// in order, wrapped in its setup and teardown steps. The service and test
+// selection of opts are replaced by the suite.
+func (s *SuiteService) RunSuite(ctx context.Context, id string, opts StartTestRunOptions) (*models.TestRun, error) {
+	suite, err := s.GetSuite(id)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Name == "" {
+		opts.Name = suite.Name
+	}
+	opts.Suite = suite
+	return s.testRunService.StartTestRun(ctx, opts)
+}
+
+// validateSuite checks that a suite names its tests and that every step has
+// a service to run against
+func (s *SuiteService) validateSuite(suite *models.TestSuite) error {
+	if suite.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSuite)
+	}
+	if len(suite.TestIDs) == 0 {
+		return fmt.Errorf("%w: test_ids is required", ErrInvalidSuite)
+	}
+
+	if suite.MaxParallel < 0 {
+		return fmt.Errorf("%w: max_parallel must not be negative", ErrInvalidSuite)
+	}
+
+	seen := make(map[string]bool, len(suite.TestIDs))
+	for _, id := range suite.TestIDs {
+		if seen[id] {
+			return fmt.Errorf("%w: test case %s is listed twice", ErrInvalidSuite, id)
+		}
+		seen[id] = true
+	}
+	var found int64
+	if err := s.db.Model(&models.TestCase{}).Where("id IN ?", []string(suite.TestIDs)).Count(&found).Error; err != nil {
+		return err
+	}
+	if int(found) != len(suite.TestIDs) {
+		return fmt.Errorf("%w: %d of the test_ids do not exist", ErrInvalidSuite, len(suite.TestIDs)-int(found))
+	}
+
+	phases := map[string]models.SuiteSteps{
+		PhaseBeforeAll:  suite.BeforeAll,
+		PhaseAfterAll:   suite.AfterAll,
+		PhaseBeforeEach: suite.BeforeEach,
+		PhaseAfterEach:  suite.AfterEach,
+	}
+	stepIDs := map[string]bool{}
+	exported := map[string]bool{}
+	for phase, steps := range phases {
+		for i, step := range steps {
+			// The steps of an else chain are validated like any other step
+			for _, branch := range stepBranches(step) {
+				if err := validateStep(suite, phase, i+1, branch, stepIDs, exported); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return validateTestExports(suite, seen, exported)
+}
+
+// validateTestExports checks the exports of the test cases of a suite,
+// which must run one after another so each sees the exports of the earlier
+// ones
+func validateTestExports(suite *models.TestSuite, testIDs, exported map[string]bool) error {
+	if len(suite.TestExports) == 0 {
+		return nil
+	}
+	if suite.Parallel != nil && *suite.Parallel {
+		return fmt.Errorf("%w: test_exports need the test cases to run in order, parallel must not be true", ErrInvalidSuite)
+	}
+	for testID, exports := range suite.TestExports {
+		if !testIDs[testID] {
+			return fmt.Errorf("%w: test_exports names test case %s, which is not in test_ids", ErrInvalidSuite, testID)
+		}
+		for name, path := range exports {
+			if !stepNamePattern.MatchString(name) || reservedVariables[name] {
+				return fmt.Errorf("%w: test case %s cannot export variable %q", ErrInvalidSuite, testID, name)
+			}
+			if path == "" {
+				return fmt.Errorf("%w: test case %s exports %s without a path", ErrInvalidSuite, testID, name)
+			}
+			if exported[name] {
+				return fmt.Errorf("%w: variable %s is exported more than once", ErrInvalidSuite, name)
+			}
+			exported[name] = true
+		}
+	}
+	return nil
+}
+
+// validateStep checks a suite step, recording its ID and exports so they are
+// unique across the suite
+func validateStep(suite *models.TestSuite, phase string, n int, step models.SuiteStep, stepIDs, exported map[string]bool) error {
+	if step.ID != "" {
+		if !stepNamePattern.MatchString(step.ID) {
+			return fmt.Errorf("%w: %s step %d has an invalid id, use letters, digits, _ and -", ErrInvalidSuite, phase, n)
+		}
+		if stepIDs[step.ID] {
+			return fmt.Errorf("%w: step id %s is used twice", ErrInvalidSuite, step.ID)
+		}
+		stepIDs[step.ID] = true
+	}
+	for name, path := range step.Export {
+		if !stepNamePattern.MatchString(name) || reservedVariables[name] {
+			return fmt.Errorf("%w: %s step %d cannot export variable %q", ErrInvalidSuite, phase, n, name)
+		}
+		if path == "" {
+			return fmt.Errorf("%w: %s step %d exports %s without a path", ErrInvalidSuite, phase, n, name)
+		}
+		// Two steps exporting a name would overwrite each other's value
+		if exported[name] {
+			return fmt.Errorf("%w: variable %s is exported by more than one step", ErrInvalidSuite, name)
+		}
+		exported[name] = true
+	}
+	if step.If != nil {
+		if err := validateStepCondition(step.If); err != nil {
+			return fmt.Errorf("%w: %s step %d: %v", ErrInvalidSuite, phase, n, err)
+		}
+	} else if step.Else != nil {
+		return fmt.Errorf("%w: %s step %d has an else step but no if condition", ErrInvalidSuite, phase, n)
+	}
+	if step.ForEach != nil {
+		if err := validateStepLoop(step); err != nil {
+			return fmt.Errorf("%w: %s step %d: %v", ErrInvalidSuite, phase, n, err)
+		}
+	}
+	if step.WaitMs != 0 {
+		if step.WaitMs < 0 {
+			return fmt.Errorf("%w: %s step %d: wait_ms must not be negative", ErrInvalidSuite, phase, n)
+		}
+		if err := validateWaitStep(step); err != nil {
+			return fmt.Errorf("%w: %s step %d: %v", ErrInvalidSuite, phase, n, err)
+		}
+		return nil
+	}
+	if step.Parallel != nil {
+		if err := validateFanOutStep(step); err != nil {
+			return fmt.Errorf("%w: %s step %d: %v", ErrInvalidSuite, phase, n, err)
+		}
+		for _, sub := range step.Parallel.Steps {
+			for _, branch := range stepBranches(sub) {
+				if err := validateStep(suite, phase, n, branch, stepIDs, exported); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if stepServiceID(suite, step) == "" {
+		return fmt.Errorf("%w: %s step %d needs a service_id, or the suite a default service_id", ErrInvalidSuite, phase, n)
+	}
+	if step.Request.Method == "" || step.Request.URL == "" {
+		return fmt.Errorf("%w: %s step %d needs a request method and url", ErrInvalidSuite, phase, n)
+	}
+	return nil
+}
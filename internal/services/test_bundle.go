@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// BundleImport reports what importing a test bundle changed. Created is set
+// when the bundle created its service.
+type BundleImport struct {
+	Service *models.Service   `json:"service"`
+	Created bool              `json:"created"`
+	Tests   []models.TestCase `json:"tests"`
+	Added   []string          `json:"added"`   // names of the new test cases
+	Updated []string          `json:"updated"` // names of the test cases that existed and were replaced
+}
+
+// ExportBundle returns a service and all its test cases, active or not, as
+// a test bundle
+func (s *TestService) ExportBundle(serviceID string) (*utils.TestBundle, error) {
+	var service models.Service
+	if err := s.reader.First(&service, "id = ?", serviceID).Error; err != nil {
+		return nil, err
+	}
+	var testCases []models.TestCase
+	if err := s.reader.Where("service_id = ?", serviceID).Order("name").Find(&testCases).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve tests: %v", err)
+	}
+
+	bundle := &utils.TestBundle{
+		Version: utils.BundleVersion,
+		Service: utils.BundleService{
+			Name:            service.Name,
+			Description:     service.Description,
+			BaseURL:         service.BaseURL,
+			Protocol:        service.Protocol,
+			TLS:             service.TLS,
+			Discovery:       service.Discovery,
+			Variables:       service.Variables,
+			APIVersioning:   service.APIVersioning,
+			LatencyBudgetMs: service.LatencyBudgetMs,
+			TimeoutMs:       service.TimeoutMs,
+			Region:          service.Region,
+		},
+		Tests: make([]utils.BundleTest, 0, len(testCases)),
+	}
+	for _, testCase := range testCases {
+		test := utils.BundleTest{
+			Name:        testCase.Name,
+			Description: testCase.Description,
+			Tags:        testCase.Tags,
+			TestSpec:    json.RawMessage(models.MaskTestSpec(testCase.TestSpec)),
+		}
+		if !testCase.IsActive {
+			inactive := false
+			test.IsActive = &inactive
+		}
+		bundle.Tests = append(bundle.Tests, test)
+	}
+	return bundle, nil
+}
+
+// ImportBundle stores the test cases of a bundle in a single transaction.
+// They go to serviceID when it is set, otherwise to the service named in the
+// bundle, which is created from the bundle's metadata if it doesn't exist.
+// Test cases are matched by name, so importing a bundle again updates the
+// tests it created instead of duplicating them; test cases missing from the
+// bundle are left alone. The settings of an existing service are not changed.
+func (s *TestService) ImportBundle(bundle *utils.TestBundle, serviceID string) (*BundleImport, error) {
+	result := &BundleImport{Added: []string{}, Updated: []string{}}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var service models.Service
+		switch err := bundleService(tx, bundle, serviceID, &service); {
+		case errors.Is(err, gorm.ErrRecordNotFound) && serviceID == "":
+			service = models.Service{
+				Name:            bundle.Service.Name,
+				Description:     bundle.Service.Description,
+				BaseURL:         bundle.Service.BaseURL,
+				Protocol:        bundle.Service.Protocol,
+				TLS:             bundle.Service.TLS,
+				Discovery:       bundle.Service.Discovery,
+				Variables:       bundle.Service.Variables,
+				APIVersioning:   bundle.Service.APIVersioning,
+				LatencyBudgetMs: bundle.Service.LatencyBudgetMs,
+				TimeoutMs:       bundle.Service.TimeoutMs,
+				Region:          bundle.Service.Region,
+			}
+			if service.Protocol == "" {
+				service.Protocol = "http"
+			}
+			if err := tx.Create(&service).Error; err != nil {
+				return fmt.Errorf("failed to create service: %v", err)
+			}
+			result.Created = true
+		case err != nil:
+			return err
+		}
+		result.Service = &service
+
+		var existing []models.TestCase
+		if err := tx.Where("service_id = ?", service.ID).Find(&existing).Error; err != nil {
+			return fmt.Errorf("failed to retrieve tests: %v", err)
+		}
+		byName := make(map[string]models.TestCase, len(existing))
+		for _, testCase := range existing {
+			byName[testCase.Name] = testCase
+		}
+
+		for _, test := range bundle.Tests {
+			isActive := test.IsActive == nil || *test.IsActive
+			tags := normalizeTags(test.Tags)
+			testCase, found := byName[test.Name]
+			if found {
+				spec, err := models.EncryptTestSpec(models.UnmaskTestSpec(string(test.TestSpec), testCase.TestSpec))
+				if err != nil {
+					return err
+				}
+				updates := map[string]interface{}{
+					"description": test.Description,
+					"test_spec":   spec,
+					"is_active":   isActive,
+					"tags":        tags,
+				}
+				if err := tx.Model(&testCase).Updates(updates).Error; err != nil {
+					return fmt.Errorf("failed to update test '%s': %v", test.Name, err)
+				}
+				result.Updated = append(result.Updated, test.Name)
+			} else {
+				testCase = models.TestCase{
+					ServiceID:   service.ID,
+					Name:        test.Name,
+					Description: test.Description,
+					TestSpec:    string(test.TestSpec),
+					IsActive:    true,
+					Tags:        tags,
+				}
+				if err := tx.Create(&testCase).Error; err != nil {
+					return fmt.Errorf("failed to create test '%s': %v", test.Name, err)
+				}
+				if !isActive {
+					// A false is left out of the insert, so the column default applies
+					if err := tx.Model(&testCase).Update("is_active", false).Error; err != nil {
+						return fmt.Errorf("failed to create test '%s': %v", test.Name, err)
+					}
+				}
+				result.Added = append(result.Added, test.Name)
+			}
+			result.Tests = append(result.Tests, testCase)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// bundleService loads the service a bundle is imported into
+func bundleService(tx *gorm.DB, bundle *utils.TestBundle, serviceID string, service *models.Service) error {
+	if serviceID != "" {
+		if err := tx.First(service, "id = ?", serviceID).Error; err != nil {
+			return fmt.Errorf("service not found: %w", err)
+		}
+		return nil
+	}
+	return tx.First(service, "name = ?", bundle.Service.Name).Error
+}
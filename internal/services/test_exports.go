@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
+
+	"github.com/tidwall/gjson"
+)
+
+// exportTest resolves the exports of a test case of the suite from its
+// response into the scope of the run, where the later test cases and steps
+// see them, whichever service they target. A test case executing several
+// times, e.g. once per data row, replaces the values of its previous
+// execution. A path missing from the response fails the test case.
+func (r *suiteRun) exportTest(testCaseID, responseData string) error {
+	exports := r.suite.TestExports[testCaseID]
+	if len(exports) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(exports))
+	for name, path := range exports {
+		value := gjson.Get(responseData, path)
+		if !value.Exists() {
+			return fmt.Errorf("export %s: path %s not found in the response", name, path)
+		}
+		values[name] = variableString(value)
+	}
+
+	r.scope.mu.Lock()
+	for name, value := range values {
+		r.scope.exports[name] = value
+	}
+	r.scope.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name := range values {
+		delete(r.unexported, name)
+	}
+	return nil
+}
+
+// exportFailed records that a test case which did not pass left its
+// exports unset, so the test cases depending on them are skipped
+func (r *suiteRun) exportFailed(testCase models.TestCase) {
+	exports := r.suite.TestExports[testCase.ID]
+	if len(exports) == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.unexported == nil {
+		r.unexported = map[string]string{}
+	}
+	for name := range exports {
+		r.unexported[name] = testCase.Name
+	}
+}
+
+// missingExport explains why a substituted spec cannot run: it still uses a
+// variable an earlier test case failed to export. It is empty when the spec
+// depends on no such variable.
+func (r *suiteRun) missingExport(spec models.TestSpec) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.unexported) == 0 {
+		return ""
+	}
+	for _, name := range testrunner.Placeholders(spec) {
+		if testName, ok := r.unexported[name]; ok {
+			return fmt.Sprintf("variable %s was not exported, as test case '%s' did not pass", name, testName)
+		}
+	}
+	return ""
+}
+
+// testExportNames returns the variables a test case of a suite exports
+func testExportNames(suite *models.TestSuite, testCaseID string) []string {
+	if suite == nil {
+		return nil
+	}
+	names := make([]string, 0, len(suite.TestExports[testCaseID]))
+	for name := range suite.TestExports[testCaseID] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,233 +1,1203 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
 	"time"
 
+	"api-test-framework/internal/discovery"
+	"api-test-framework/internal/logging"
 	"api-test-framework/internal/models"
+	"api-test-framework/internal/secrets"
 	"api-test-framework/internal/testrunner"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 // TestRunService handles test execution and result management
 type TestRunService struct {
 	db              *gorm.DB
-	testRunner      *testrunner.HTTPExpectExecutor
+	reader          *gorm.DB // serves list and reporting queries, see UseReadReplica
+	testRunner      testrunner.Executor
 	redisClient     *redis.Client
+	tokenProvider   *testrunner.OAuth2TokenProvider
+	fixtures        testrunner.FixtureLoader
+	runsMu          sync.Mutex
+	runs            map[string]context.CancelFunc
+	reportBaseURL   string // public URL used to link reports from notifications
+	region          string // region tests execute from in this process, see SetRegion
+	pool            string // egress pool of this process, see SetPool
+	workerIdleExit  time.Duration // see SetWorkerIdleExit
+	serviceResolver *discovery.Resolver // resolves base URLs of discovered services, see SetServiceResolver
+	secretResolver  *secrets.Resolver   // resolves secret references at execution time, see SetSecretResolver
+	responseCapture ResponseCapture     // how large response bodies are stored, see SetResponseCapture
+	redaction       models.RedactionRules // masks the responses of every service, see SetRedactionRules
+	logger          *slog.Logger
+}
+
+// ErrRunNotRunning is returned when cancelling a run that has already finished
+var ErrRunNotRunning = errors.New("test run is not running")
+
+// ErrNoDebugLog is returned for the debug log of a run started without debug
+var ErrNoDebugLog = errors.New("no debug log")
+
+// ErrRunNotFinished is returned for output only available once a run finished
+var ErrRunNotFinished = errors.New("test run has not finished")
+
+// ErrInvalidTransition is returned when a run cannot move to a status, e.g.
+// because another writer already finished it
+var ErrInvalidTransition = errors.New("invalid test run status transition")
+
+// runTransitions lists the statuses a run may move to from each status;
+// finished runs never change status again
+var runTransitions = map[string][]string{
+	"running": {"completed", "failed", "cancelled"},
 }
 
 // NewTestRunService creates a new test run service
-func NewTestRunService(db *gorm.DB, testRunner *testrunner.HTTPExpectExecutor, redisClient *redis.Client) *TestRunService {
+func NewTestRunService(db *gorm.DB, testRunner testrunner.Executor, redisClient *redis.Client) *TestRunService {
 	return &TestRunService{
 		db:          db,
+		reader:      db,
 		testRunner:  testRunner,
 		redisClient: redisClient,
+		tokenProvider: testrunner.NewOAuth2TokenProvider(redisClient),
+		fixtures:    NewFixtureService(db, 0),
+		runs:        make(map[string]context.CancelFunc),
+		serviceResolver: discovery.NewResolver("", "", ""),
+		secretResolver:  secrets.NewResolver(secrets.VaultConfig{}),
+		logger:      slog.Default(),
+	}
+}
+
+// SetLogger sets the logger of the service; loggers of runs and their test
+// cases are derived from it
+func (s *TestRunService) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// runLogger returns the logger of the run executing in ctx, or the logger of
+// the service outside of runs
+func (s *TestRunService) runLogger(ctx context.Context) *slog.Logger {
+	if logger := logging.FromContext(ctx); logger != nil {
+		return logger
 	}
+	return s.logger
 }
 
-// StartTestRun starts a new test execution run
-func (s *TestRunService) StartTestRun(serviceID string, testIDs []string, name string) (*models.TestRun, error) {
+// SetFixtures sets the fixture service resolving the fixtures referenced by
+// test specs, so fixtures kept in object storage can be loaded
+func (s *TestRunService) SetFixtures(fixtures *FixtureService) {
+	s.fixtures = fixtures
+}
+
+// UseReadReplica serves the run list, result search and reports from a
+// read-only replica so heavy reporting queries do not contend with the writes
+// of executing runs. Queries polled during a run stay on the primary.
+func (s *TestRunService) UseReadReplica(replica *gorm.DB) {
+	s.reader = replica
+}
+
+// StartTestRunOptions describes which tests a run executes and with which configuration
+type StartTestRunOptions struct {
+	ServiceID     string            `json:"service_id"`
+	TestIDs       []string          `json:"test_ids"`
+	ProjectID     string            `json:"project_id"`   // run only the test cases of the services of this project
+	Tags          []string          `json:"tags"`         // run only the test cases carrying any of these tags
+	ExcludeTags   []string          `json:"exclude_tags"` // skip the test cases carrying any of these tags
+	Name          string            `json:"name"`
+	EnvironmentID string            `json:"environment_id"`
+	Variables     map[string]string `json:"variables"`
+	MaxConcurrency int              `json:"max_concurrency"`
+	APIVersions   []string          `json:"api_versions"` // run every test case once per API version
+	LatencyBudgetMs int             `json:"latency_budget_ms"` // suite-level response time budget
+	ScheduleID    string            `json:"-"`                 // set when a schedule starts the run
+	HookID        string            `json:"-"`                 // set when a webhook starts the run
+	Suite         *models.TestSuite `json:"-"`                 // set when a suite is run; replaces service_id and test_ids
+	RerunOf       string            `json:"-"`                 // set when the failed test cases of a run are re-executed
+	Retry         *models.RetryPolicy `json:"retry"`           // default retry policy of the test cases
+	Regions       []string          `json:"regions"`           // run every test case once from each region
+	TestTimeoutMs int               `json:"test_timeout_ms"`   // deadline of each test case, overrides service timeouts
+	RunTimeoutMs  int               `json:"run_timeout_ms"`    // deadline of the whole run
+	Debug         bool              `json:"debug"`             // capture the debug output of the run, see GetDebugLog
+	SecurityScan  bool              `json:"security_scan"`     // scan the endpoint of every test case, see GetSecurityFindings
+	DetectLeaks   bool              `json:"detect_leaks"`      // scan captured responses for secrets, also enabled by the project
+}
+
+// maxDebugLogEntries caps the debug output captured for a run
+const maxDebugLogEntries = 5000
+
+// maxRunConcurrency caps the number of test cases a single run executes in parallel
+const maxRunConcurrency = 64
+
+// StartTestRun starts a new test execution run. The run outlives the request
+// that started it: execution keeps the values of ctx (e.g. the trace parent)
+// but not its cancellation, and is cancelled through CancelTestRun instead.
+func (s *TestRunService) StartTestRun(ctx context.Context, opts StartTestRunOptions) (*models.TestRun, error) {
+	db := s.db.WithContext(ctx)
+
+	if opts.TestTimeoutMs < 0 || time.Duration(opts.TestTimeoutMs)*time.Millisecond > maxTestTimeout {
+		return nil, fmt.Errorf("%w: test_timeout_ms must be between 0 and %d", ErrInvalidTimeout, maxTestTimeout.Milliseconds())
+	}
+	if opts.RunTimeoutMs < 0 || time.Duration(opts.RunTimeoutMs)*time.Millisecond > maxRunTimeout {
+		return nil, fmt.Errorf("%w: run_timeout_ms must be between 0 and %d", ErrInvalidTimeout, maxRunTimeout.Milliseconds())
+	}
+
+	// Resolve the target environment
+	var environment *models.Environment
+	if opts.EnvironmentID != "" {
+		environment = &models.Environment{}
+		if err := db.First(environment, "id = ?", opts.EnvironmentID).Error; err != nil {
+			return nil, fmt.Errorf("environment not found: %v", err)
+		}
+	}
+
+	// Steps may target services none of the test cases belong to
+	var stepServices []models.Service
+	if opts.Suite != nil {
+		if err := db.Where("id IN ?", suiteStepServices(opts.Suite)).Find(&stepServices).Error; err != nil {
+			return nil, fmt.Errorf("failed to retrieve suite step services: %v", err)
+		}
+	}
+
 	// Create test run
 	testRun := &models.TestRun{
-		Name:       name,
+		Name:       opts.Name,
 		Status:     "running",
 		StartedAt:  time.Now(),
+		VariableOverrides: opts.Variables,
+		MaxConcurrency: opts.MaxConcurrency,
+		APIVersions:    opts.APIVersions,
+		LatencyBudgetMs: opts.LatencyBudgetMs,
+		Regions:        opts.Regions,
+		TestTimeoutMs:  opts.TestTimeoutMs,
+		RunTimeoutMs:   opts.RunTimeoutMs,
+		Debug:          opts.Debug,
+		SecurityScan:   opts.SecurityScan,
+	}
+	if opts.ScheduleID != "" {
+		testRun.ScheduleID = &opts.ScheduleID
+	}
+	if opts.HookID != "" {
+		testRun.HookID = &opts.HookID
+	}
+	if opts.RerunOf != "" {
+		testRun.RerunOfID = &opts.RerunOf
+	}
+	if opts.Retry != nil {
+		testRun.RetryPolicy = *opts.Retry
+	}
+	if opts.Suite != nil {
+		// A suite executes its test cases one at a time in its own order
+		// unless it allows them to run in parallel
+		testRun.SuiteID = &opts.Suite.ID
+		if opts.Suite.Parallel == nil || !*opts.Suite.Parallel {
+			testRun.MaxConcurrency = 1
+		} else if testRun.MaxConcurrency < 1 {
+			testRun.MaxConcurrency = opts.Suite.MaxParallel
+		}
+		opts.ServiceID = ""
+		opts.TestIDs = opts.Suite.TestIDs
+	}
+	if testRun.MaxConcurrency < 1 {
+		testRun.MaxConcurrency = 1
+	}
+	if testRun.MaxConcurrency > maxRunConcurrency {
+		testRun.MaxConcurrency = maxRunConcurrency
+	}
+	if environment != nil {
+		testRun.EnvironmentID = &environment.ID
 	}
 
-	if err := s.db.Create(testRun).Error; err != nil {
-		return nil, fmt.Errorf("failed to create test run: %v", err)
+	// The run belongs to the requested project, else to the project of its suite
+	projectID := opts.ProjectID
+	if projectID == "" && opts.Suite != nil && opts.Suite.ProjectID != nil {
+		projectID = *opts.Suite.ProjectID
+	}
+	if projectID != "" && environment != nil && environment.ProjectID != nil && *environment.ProjectID != projectID {
+		return nil, fmt.Errorf("%w: environment %s belongs to another project", ErrProjectMismatch, environment.Name)
+	}
+	if projectID != "" {
+		testRun.ProjectID = &projectID
 	}
 
 	// Get test cases
 	var testCases []models.TestCase
-	query := s.db.Preload("Service")
-	if serviceID != "" {
-		query = query.Where("service_id = ?", serviceID)
+	query := db.Preload("Service")
+	if opts.ServiceID != "" {
+		query = query.Where("service_id = ?", opts.ServiceID)
 	}
-	if len(testIDs) > 0 {
-		query = query.Where("id IN ?", testIDs)
+	if len(opts.TestIDs) > 0 {
+		query = query.Where("id IN ?", opts.TestIDs)
 	}
+	query = testCasesInProject(query, opts.ProjectID)
+	query = filterByTags(query, opts.Tags, opts.ExcludeTags)
 	
 	if err := query.Find(&testCases).Error; err != nil {
 		return nil, fmt.Errorf("failed to retrieve test cases: %v", err)
 	}
 
-	testRun.TotalTests = len(testCases)
-	if err := s.db.Save(testRun).Error; err != nil {
-		return nil, fmt.Errorf("failed to update test run: %v", err)
+	// Suites limiting their parallelism are honored by every run of their test cases
+	limits, membership, err := suiteLimits(db, testCases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve suite parallelism: %v", err)
 	}
 
-	// Execute tests asynchronously with timeout
-	go func() {
-		// Set a timeout of 5 minutes for test execution
-		done := make(chan bool, 1)
-		go func() {
-			s.executeTests(testRun.ID, testCases)
-			done <- true
-		}()
-		
-		select {
-		case <-done:
-			// Test execution completed normally
-		case <-time.After(5 * time.Minute):
-			// Test execution timed out
-			fmt.Printf("Test run %s timed out after 5 minutes\n", testRun.ID)
-			completedAt := time.Now()
-			s.db.Model(&models.TestRun{}).Where("id = ?", testRun.ID).Updates(map[string]interface{}{
-				"status":          "failed",
-				"passed_tests":    0,
-				"failed_tests":    0,
-				"execution_time_ms":  0,
-				"completed_at":    completedAt,
-			})
+	// Without a project, the run belongs to the project its services share
+	if testRun.ProjectID == nil {
+		testRun.ProjectID = sharedProject(testCases)
+	}
+	leaks, err := s.leakDetector(db, testRun, opts.DetectLeaks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure leak detection: %v", err)
+	}
+
+	// Record the variables every service resolves to so the run stays explainable
+	var services []string
+	if opts.Suite != nil {
+		services = suiteServices(opts.Suite, testCases)
+	}
+	resolve := func(service models.Service) {
+		if _, ok := testRun.ResolvedVariables[service.ID]; ok {
+			return
+		}
+		env, overrides := environment, models.Variables(opts.Variables)
+		if !sharedBaseURLApplies(opts.Suite, services, service.ID) {
+			env, overrides = withoutSharedBaseURL(environment, overrides)
 		}
+		testRun.ResolvedVariables[service.ID] = s.resolveServiceVariables(ctx, service, env, overrides)
+	}
+	testRun.ResolvedVariables = models.VariableReport{}
+	for _, testCase := range testCases {
+		resolve(testCase.Service)
+	}
+
+	var suite *suiteRun
+	if opts.Suite != nil {
+		suite = &suiteRun{suite: opts.Suite, scope: newStepScope(nil)}
+		testCases = orderTestCases(testCases, opts.Suite.TestIDs)
+		for _, service := range stepServices {
+			resolve(service)
+		}
+	}
+
+	items := expandRegions(s.expandDataRows(runItems(testCases, testRun.APIVersions)), testRun.Regions)
+	for i := range items {
+		items[i].suites = membership[items[i].testCase.ID]
+	}
+	testRun.TotalTests = len(items)
+	testRun.Config = s.runConfig(testRun, environment, testCases, stepServices)
+	if len(limits) > 0 {
+		testRun.Config.Executor.SuiteLimits = limits
+	}
+	if err := s.admitRun(db, testRun); err != nil {
+		return nil, err
+	}
+
+	// Execute tests asynchronously; the context aborts in-flight requests when
+	// the run is cancelled or exceeds the run timeout
+	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), runTimeoutOf(testRun))
+	if leaks != nil {
+		runCtx = withLeakDetector(runCtx, leaks)
+	}
+	s.registerRun(testRun.ID, cancel)
+	go func() {
+		defer s.unregisterRun(testRun.ID)
+		defer cancel()
+		s.executeTests(runCtx, testRun, suite, items)
 	}()
 
 	return testRun, nil
 }
 
-// executeTests executes all tests for a test run
-func (s *TestRunService) executeTests(testRunID string, testCases []models.TestCase) {
+// orderTestCases sorts test cases by their position in ids
+func orderTestCases(testCases []models.TestCase, ids []string) []models.TestCase {
+	positions := make(map[string]int, len(ids))
+	for i, id := range ids {
+		if _, ok := positions[id]; !ok {
+			positions[id] = i
+		}
+	}
+	sort.SliceStable(testCases, func(i, j int) bool {
+		return positions[testCases[i].ID] < positions[testCases[j].ID]
+	})
+	return testCases
+}
+
+// Timeouts of runs and their test cases
+const (
+	defaultRunTimeout  = 5 * time.Minute  // bounds a run without a run timeout
+	maxRunTimeout      = 24 * time.Hour
+	defaultTestTimeout = 30 * time.Second // bounds a test case unless its spec, run or service sets a timeout
+	maxTestTimeout     = 10 * time.Minute
+)
+
+// ErrInvalidTimeout is returned when a timeout is negative or above its maximum
+var ErrInvalidTimeout = errors.New("invalid timeout")
+
+// runTimeoutOf returns the deadline of a run, counted from its start
+func runTimeoutOf(testRun *models.TestRun) time.Duration {
+	if testRun.RunTimeoutMs <= 0 {
+		return defaultRunTimeout
+	}
+	return time.Duration(testRun.RunTimeoutMs) * time.Millisecond
+}
+
+// testTimeoutFor returns the deadline of a test case including token requests
+// and retries: the timeout of its spec, else the test timeout of its run, else
+// the timeout of its service, else defaultTestTimeout. Timeouts of 0 are
+// inherited and the result never exceeds maxTestTimeout.
+func testTimeoutFor(specMs, runMs, serviceMs int) time.Duration {
+	timeoutMs := serviceMs
+	if runMs > 0 {
+		timeoutMs = runMs
+	}
+	if specMs > 0 {
+		timeoutMs = specMs
+	}
+	if timeoutMs <= 0 {
+		return defaultTestTimeout
+	}
+	return min(time.Duration(timeoutMs)*time.Millisecond, maxTestTimeout)
+}
+
+// CancelTestRun cancels a running test run. In-flight requests are aborted,
+// remaining test cases are recorded as skipped and the run ends as cancelled.
+func (s *TestRunService) CancelTestRun(ctx context.Context, id string) (*models.TestRun, error) {
+	var testRun models.TestRun
+	if err := s.db.WithContext(ctx).First(&testRun, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if testRun.Status != "running" {
+		return nil, fmt.Errorf("%w: test run is %s", ErrRunNotRunning, testRun.Status)
+	}
+
+	s.runsMu.Lock()
+	cancel, ok := s.runs[id]
+	s.runsMu.Unlock()
+
+	if ok {
+		cancel()
+	} else {
+		// The run is not executing in this process (e.g. the instance restarted),
+		// so there is nothing to abort and the record is closed directly
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return transitionRun(tx, id, "running", "cancelled", map[string]interface{}{
+				"completed_at": time.Now(),
+			})
+		})
+		if errors.Is(err, ErrInvalidTransition) {
+			return nil, fmt.Errorf("%w: test run already finished", ErrRunNotRunning)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.publishRunCompleted(id)
+	}
+
+	testRun.Status = "cancelled"
+	return &testRun, nil
+}
+
+// transitionRun moves a run from one status to another, applying updates in
+// the same statement. The update is conditional on the current status, so a
+// run finished by another writer (e.g. a cancellation) is never overwritten;
+// ErrInvalidTransition is returned in that case.
+func transitionRun(tx *gorm.DB, id, from, to string, updates map[string]interface{}) error {
+	allowed := false
+	for _, status := range runTransitions[from] {
+		allowed = allowed || status == to
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+	}
+
+	values := map[string]interface{}{"status": to}
+	for column, value := range updates {
+		values[column] = value
+	}
+	result := tx.Model(&models.TestRun{}).Where("id = ? AND status = ?", id, from).Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: test run %s is no longer %s", ErrInvalidTransition, id, from)
+	}
+	return nil
+}
+
+// finishRun closes a running run with its final status and counters, and
+// publishes the completion when this writer was the one to finish it
+func (s *TestRunService) finishRun(testRunID, status string, updates map[string]interface{}) bool {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		return transitionRun(tx, testRunID, "running", status, updates)
+	})
+	if err != nil {
+		s.logger.Warn("not completing test run", "test_run_id", testRunID, "status", status, "error", err)
+		return false
+	}
+	s.publishRunCompleted(testRunID)
+	return true
+}
+
+// registerRun tracks the cancel function of a run executing in this process
+func (s *TestRunService) registerRun(id string, cancel context.CancelFunc) {
+	s.runsMu.Lock()
+	defer s.runsMu.Unlock()
+	s.runs[id] = cancel
+}
+
+// unregisterRun stops tracking a run once it has finished
+func (s *TestRunService) unregisterRun(id string) {
+	s.runsMu.Lock()
+	defer s.runsMu.Unlock()
+	delete(s.runs, id)
+}
+
+// executeTests executes all tests for a test run using a pool of workers.
+// Results are recorded with their position in the run so they aggregate in
+// the original order regardless of completion order.
+func (s *TestRunService) executeTests(ctx context.Context, testRun *models.TestRun, suite *suiteRun, items []runItem) {
+	testRunID := testRun.ID
+
+	// Runs started with debug capture the debug output of their test cases,
+	// whatever the level logged by the service
+	handler := s.logger.Handler()
+	var capture *logging.Capture
+	if testRun.Debug {
+		capture = logging.NewCapture(maxDebugLogEntries)
+		handler = capture.Handler(handler)
+	}
+	logger := slog.New(handler).With("test_run_id", testRunID)
+	ctx = logging.NewContext(ctx, logger)
+
+	// Runs started with security_scan or detect_leaks collect the findings of their test cases
+	var scan *securityScan
+	if testRun.SecurityScan || testRun.DetectLeaks {
+		scan = &securityScan{}
+		ctx = withSecurityScan(ctx, scan)
+	}
+
 	// Add panic recovery
 	defer func() {
 		if r := recover(); r != nil {
-			fmt.Printf("Panic in executeTests for test run %s: %v\n", testRunID, r)
-			completedAt := time.Now()
-			s.db.Model(&models.TestRun{}).Where("id = ?", testRunID).Updates(map[string]interface{}{
-				"status":          "failed",
-				"passed_tests":    0,
-				"failed_tests":    0,
-				"execution_time_ms":  0,
-				"completed_at":    completedAt,
+			logger.Error("panic while executing test run", "panic", r)
+			s.finishRun(testRunID, "failed", map[string]interface{}{
+				"passed_tests":      0,
+				"failed_tests":      0,
+				"execution_time_ms": 0,
+				"completed_at":      time.Now(),
 			})
 		}
 	}()
 
-	passedTests := 0
-	failedTests := 0
-
-	fmt.Printf("Starting test execution for test run %s with %d test cases\n", testRunID, len(testCases))
+	logger.Info("starting test run", "test_cases", len(items), "concurrency", testRun.MaxConcurrency, "suite_limits", testRun.Config.Executor.SuiteLimits, "debug", testRun.Debug)
 
 	// Handle case where no test cases are found
-	if len(testCases) == 0 {
-		fmt.Printf("No test cases found for test run %s\n", testRunID)
-		completedAt := time.Now()
-		s.db.Model(&models.TestRun{}).Where("id = ?", testRunID).Updates(map[string]interface{}{
-			"status":          "failed",
-			"passed_tests":    0,
-			"failed_tests":    0,
-			"execution_time_ms":  0,
-			"completed_at":    completedAt,
+	if len(items) == 0 {
+		logger.Warn("no test cases found for test run")
+		s.finishRun(testRunID, "failed", map[string]interface{}{
+			"passed_tests":      0,
+			"failed_tests":      0,
+			"execution_time_ms": 0,
+			"completed_at":      time.Now(),
 		})
 		return
 	}
 
-	for i, testCase := range testCases {
-		fmt.Printf("Executing test case %d/%d: %s\n", i+1, len(testCases), testCase.ID)
-		
-		// Parse test spec
-		var testSpec models.TestSpec
-		if err := json.Unmarshal([]byte(testCase.TestSpec), &testSpec); err != nil {
-			fmt.Printf("Failed to parse test spec for test case %s: %v\n", testCase.ID, err)
-			s.recordTestResult(testRunID, testCase.ID, "failed", 0, err.Error(), "")
-			failedTests++
+	// Results are written in batches; the buffer is drained before the run is
+	// finished, also when execution panics
+	results := s.newResultBatcher(testRunID)
+	defer results.close()
+
+	workers := testRun.MaxConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	statuses := make([]string, len(items))
+
+	// A failing before_all step skips every test case of a suite
+	var setupFailure *models.HookResult
+	if suite != nil {
+		setupFailure = s.runSteps(ctx, testRun, suite, suite.scope, PhaseBeforeAll, suite.suite.BeforeAll, "")
+	}
+
+	if setupFailure == nil {
+		// Stop handing out work once the run is cancelled or timed out
+		scheduler := newRunScheduler(items, testRun.Config.Executor.SuiteLimits)
+		stopScheduler := context.AfterFunc(ctx, scheduler.stop)
+		defer stopScheduler()
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					i, ok := scheduler.next()
+					if !ok {
+						return
+					}
+					statuses[i] = s.runTestCase(ctx, testRun, suite, results, items[i])
+					scheduler.done(i)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	// Test cases that were never started are recorded as skipped
+	for i := range items {
+		if statuses[i] != "" {
 			continue
 		}
+		statuses[i] = "skipped"
+		reason := skipReason(ctx, testRun)
+		if setupFailure != nil {
+			reason = stepFailureReason(setupFailure)
+		}
+		s.recordTestResult(results, items[i], testOutcome{status: "skipped", errorMessage: reason})
+	}
 
-		// Create test executor for this service
-		executor := testrunner.NewHTTPExpectExecutor(testCase.Service.BaseURL)
-		
-		// Execute test
-		result := executor.ExecuteTest(&testSpec)
-		
-		// Record result
-		status := "passed"
-		if result.Status == "FAILED" {
-			status = "failed"
-			failedTests++
-		} else {
+	// Teardown runs even when the run was cancelled or timed out
+	var hookResults models.HookResults
+	hooksFailed := false
+	if suite != nil {
+		s.runSteps(teardownContext(ctx), testRun, suite, suite.scope, PhaseAfterAll, suite.suite.AfterAll, "")
+		hookResults, hooksFailed = suite.outcome()
+	}
+
+	// Results that could not be stored fail the run, whatever their status
+	storeErr := results.close()
+	if storeErr != nil {
+		logger.Error("failed to store results of test run", "error", storeErr)
+	}
+
+	passedTests := 0
+	failedTests := 0
+	skippedTests := 0
+	timedOutTests := 0
+	for _, status := range statuses {
+		switch status {
+		case "passed":
 			passedTests++
+		case "skipped":
+			skippedTests++
+		case "timed_out":
+			timedOutTests++
+		default:
+			failedTests++
 		}
-
-		fmt.Printf("Test case %s result: %s\n", testCase.ID, status)
-		s.recordTestResult(testRunID, testCase.ID, status, int(result.Duration.Milliseconds()), result.ErrorMessage, result.ResponseData)
 	}
 
 	// Update test run status
 	completedAt := time.Now()
-	
-	// Calculate execution time only if there are test cases
-	var executionTime int64
-	if len(testCases) > 0 {
-		executionTime = completedAt.Sub(testCases[0].CreatedAt).Milliseconds()
-	}
+	executionTime := completedAt.Sub(testRun.StartedAt).Milliseconds()
 	
 	status := "completed"
-	if failedTests > 0 {
+	switch {
+	case ctx.Err() == context.Canceled:
+		status = "cancelled"
+	case failedTests > 0 || timedOutTests > 0 || hooksFailed || storeErr != nil || ctx.Err() == context.DeadlineExceeded:
 		status = "failed"
 	}
 
-	fmt.Printf("Completing test run %s: %s (passed: %d, failed: %d)\n", testRunID, status, passedTests, failedTests)
+	logger.Info("completing test run", "status", status, "passed", passedTests, "failed", failedTests, "skipped", skippedTests, "timed_out", timedOutTests, "duration", completedAt.Sub(testRun.StartedAt))
 
-	s.db.Model(&models.TestRun{}).Where("id = ?", testRunID).Updates(map[string]interface{}{
-		"status":          status,
+	updates := map[string]interface{}{
 		"passed_tests":    passedTests,
 		"failed_tests":    failedTests,
+		"skipped_tests":   skippedTests,
+		"timed_out_tests": timedOutTests,
 		"execution_time_ms":  executionTime,
 		"completed_at":    completedAt,
+	}
+	if suite != nil {
+		updates["hook_results"] = hookResults
+	}
+	if storeErr != nil {
+		updates["error"] = storeErr.Error()
+	}
+	if taxonomy, err := s.buildStatusTaxonomy(testRunID); err == nil {
+		updates["status_summary"] = taxonomy
+	} else {
+		logger.Warn("failed to build status code summary", "error", err)
+	}
+	if capture != nil {
+		updates["debug_log"] = capture.DebugLog()
+	}
+	if scan != nil {
+		updates["security_findings"] = scan.list()
+	}
+
+	if s.finishRun(testRunID, status, updates) && status == "failed" {
+		s.notifyRunFailures(ctx, testRunID)
+	}
+}
+
+// publishRunCompleted publishes the final state of a test run to its stream
+func (s *TestRunService) publishRunCompleted(testRunID string) {
+	var testRun models.TestRun
+	if err := s.db.First(&testRun, "id = ?", testRunID).Error; err != nil {
+		return
+	}
+	s.publishRunEvent(RunEvent{
+		Type:       RunEventRunCompleted,
+		TestRunID:  testRunID,
+		Status:     testRun.Status,
+		DurationMs: testRun.ExecutionTimeMs,
+		Run:        &testRun,
+	})
+}
+
+// deadlineReason explains why a test case exceeded its deadline
+func deadlineReason(timeout time.Duration) string {
+	return fmt.Sprintf("test case exceeded its deadline of %s", timeout)
+}
+
+// skipReason explains why a test case of a run was skipped
+func skipReason(ctx context.Context, testRun *models.TestRun) string {
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Sprintf("test run timed out after %s", runTimeoutOf(testRun))
+	}
+	return "test run cancelled"
+}
+
+// runItem is a single unit of work of a run: a test case, pinned to an API
+// version when the run targets several versions and to a data row when the
+// test is data-driven
+type runItem struct {
+	position   int
+	testCase   models.TestCase
+	apiVersion string
+	iteration  int                    // 1-based data row, 0 for tests without data
+	row        map[string]interface{} // parameters of the iteration
+	dataErr    error                  // set when the data rows could not be loaded
+	region     string                 // region the test must run from, empty to route by the service region
+	request    models.RequestSnapshot // resolved request, set once variables are substituted
+	suites     []string               // suites limiting how many of their test cases run at once
+}
+
+// runItems expands the test cases of a run into work items, running every
+// test case once per requested API version. Without explicit versions each
+// test case runs once against the default version of its service.
+func runItems(testCases []models.TestCase, apiVersions []string) []runItem {
+	if len(apiVersions) == 0 {
+		apiVersions = []string{""}
+	}
+
+	items := make([]runItem, 0, len(testCases)*len(apiVersions))
+	for _, version := range apiVersions {
+		for _, testCase := range testCases {
+			itemVersion := version
+			if itemVersion == "" {
+				itemVersion = testCase.Service.APIVersioning.Default
+			}
+			items = append(items, runItem{position: len(items), testCase: testCase, apiVersion: itemVersion})
+		}
+	}
+	return items
+}
+
+// expandDataRows repeats the items of data-driven test cases once per data
+// row and renumbers the positions. A test case whose rows cannot be loaded
+// keeps a single item that fails with the load error.
+func (s *TestRunService) expandDataRows(items []runItem) []runItem {
+	type dataRows struct {
+		rows []map[string]interface{}
+		err  error
+	}
+	cache := make(map[string]dataRows)
+
+	expanded := make([]runItem, 0, len(items))
+	for _, item := range items {
+		data, ok := cache[item.testCase.ID]
+		if !ok {
+			var testSpec models.TestSpec
+			if err := json.Unmarshal([]byte(item.testCase.TestSpec), &testSpec); err == nil {
+				data.rows, data.err = testrunner.DataRows(&testSpec, s.fixtures)
+			}
+			cache[item.testCase.ID] = data
+		}
+
+		if data.err != nil || len(data.rows) == 0 {
+			item.dataErr = data.err
+			item.position = len(expanded)
+			expanded = append(expanded, item)
+			continue
+		}
+		for i, row := range data.rows {
+			rowItem := item
+			rowItem.position = len(expanded)
+			rowItem.iteration = i + 1
+			rowItem.row = row
+			expanded = append(expanded, rowItem)
+		}
+	}
+	return expanded
+}
+
+// expandRegions repeats every item once per region and renumbers the
+// positions. Without regions the items are returned unchanged.
+func expandRegions(items []runItem, regions []string) []runItem {
+	if len(regions) == 0 {
+		return items
+	}
+
+	expanded := make([]runItem, 0, len(items)*len(regions))
+	for _, item := range items {
+		for _, region := range regions {
+			regionItem := item
+			regionItem.position = len(expanded)
+			regionItem.region = region
+			expanded = append(expanded, regionItem)
+		}
+	}
+	return expanded
+}
+
+// runTestCase executes a single test case of a run, records its result and
+// returns the recorded status. A panic fails only the affected test case.
+func (s *TestRunService) runTestCase(ctx context.Context, testRun *models.TestRun, suite *suiteRun, results *resultBatcher, item runItem) (status string) {
+	testCase := item.testCase
+	logger := s.runLogger(ctx).With("test_case_id", testCase.ID, "position", item.position)
+	if item.apiVersion != "" {
+		logger = logger.With("api_version", item.apiVersion)
+	}
+	ctx = logging.NewContext(ctx, logger)
+
+	// The test deadline bounds this test case only; the run context still
+	// decides whether the test is skipped because the run ended. It is set
+	// once the spec is parsed, as the spec may override the timeout.
+	testCtx, timeout := ctx, time.Duration(0)
+
+	// Test cases that did not pass leave their exports unset
+	defer func() {
+		if suite != nil && status != "passed" {
+			suite.exportFailed(testCase)
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			// Aborting an in-flight request surfaces as a failure inside the executor
+			if ctx.Err() != nil {
+				status = "skipped"
+				s.recordTestResult(results, item, testOutcome{status: status, errorMessage: skipReason(ctx, testRun)})
+				return
+			}
+			if timeout > 0 && testCtx.Err() == context.DeadlineExceeded {
+				status = "timed_out"
+				s.recordTestResult(results, item, testOutcome{status: status, executionTime: int(timeout.Milliseconds()), errorMessage: deadlineReason(timeout), failureType: testrunner.FailureTimeout})
+				return
+			}
+			status = "failed"
+			logger.Error("panic while executing test case", "panic", r)
+			s.recordTestResult(results, item, testOutcome{status: status, errorMessage: fmt.Sprintf("panic during execution: %v", r), failureType: testrunner.FailureInternal})
+		}
+	}()
+
+	if ctx.Err() != nil {
+		s.recordTestResult(results, item, testOutcome{status: "skipped", errorMessage: skipReason(ctx, testRun)})
+		return "skipped"
+	}
+
+	// Versions the service does not support are skipped rather than failed
+	versioning := testCase.Service.APIVersioning
+	if item.apiVersion != "" && !versioning.SupportsVersion(item.apiVersion) {
+		s.recordTestResult(results, item, testOutcome{status: "skipped", errorMessage: fmt.Sprintf("API version %s is not supported by service %s", item.apiVersion, testCase.Service.Name)})
+		return "skipped"
+	}
+
+	logger.Debug("executing test case", "name", testCase.Name, "total", testRun.TotalTests)
+	s.publishRunEvent(RunEvent{
+		Type:       RunEventTestStarted,
+		TestRunID:  testRun.ID,
+		TestCaseID: testCase.ID,
+		TestName:   testCase.Name,
+		Position:   item.position,
+		APIVersion: item.apiVersion,
+		Region:     item.region,
+		Iteration:  item.iteration,
+		Status:     "running",
+	})
+
+	// Parse test spec
+	var testSpec models.TestSpec
+	if err := json.Unmarshal([]byte(testCase.TestSpec), &testSpec); err != nil {
+		logger.Warn("failed to parse test spec", "error", err)
+		s.recordTestResult(results, item, testOutcome{status: "failed", errorMessage: err.Error(), failureType: testrunner.FailureSpec})
+		return "failed"
+	}
+	if item.dataErr != nil {
+		s.recordTestResult(results, item, testOutcome{status: "failed", errorMessage: item.dataErr.Error(), failureType: testrunner.FailureSpec})
+		return "failed"
+	}
+	if item.row != nil {
+		testrunner.ApplyDataRow(&testSpec, item.row)
+	}
+
+	timeout = testTimeoutFor(testSpec.TimeoutMs, testRun.TestTimeoutMs, testCase.Service.TimeoutMs)
+	var cancel context.CancelFunc
+	testCtx, cancel = context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Suite steps wrap every test case; teardown runs even if setup failed.
+	// The steps of a test case capture their responses in its own scope.
+	var scope *stepScope
+	if suite != nil {
+		scope = newStepScope(suite.scope)
+		defer s.runSteps(teardownContext(ctx), testRun, suite, scope, PhaseAfterEach, suite.suite.AfterEach, testCase.ID)
+		if failure := s.runSteps(testCtx, testRun, suite, scope, PhaseBeforeEach, suite.suite.BeforeEach, testCase.ID); failure != nil {
+			s.recordTestResult(results, item, testOutcome{status: "failed", errorMessage: stepFailureReason(failure), failureType: failure.FailureType})
+			return "failed"
+		}
+	}
+
+	// Substitute the variables resolved for this service and captured by suite steps
+	vars := scope.values(runVariables(testRun, testCase.ServiceID))
+	if item.apiVersion != "" {
+		vars["api_version"] = item.apiVersion
+	}
+	testrunner.ApplyVariables(&testSpec, secretPlaceholders(vars))
+	testrunner.ApplyLatencyBudget(&testSpec, testCase.Service.LatencyBudgetMs, testRun.LatencyBudgetMs)
+	item.request = models.RequestSnapshot{BaseURL: vars["base_url"], APIVersion: item.apiVersion, TestSpec: testSpec}
+	if suite != nil {
+		if reason := suite.missingExport(testSpec); reason != "" {
+			s.recordTestResult(results, item, testOutcome{status: "skipped", errorMessage: reason})
+			return "skipped"
+		}
+	}
+
+	// Tests sent from an egress pool run on a worker of the pool, wherever it
+	// is. Others run from the requested region, or from the region of the
+	// service or one near it.
+	var err error
+	region, local := s.region, testSpec.EgressPool == s.pool
+	if testSpec.EgressPool != "" {
+		if err := s.routePool(testCtx, testSpec.EgressPool); err != nil {
+			s.recordTestResult(results, item, testOutcome{status: "skipped", errorMessage: err.Error()})
+			return "skipped"
+		}
+	} else {
+		exactRegion := item.region != ""
+		targetRegion := item.region
+		if !exactRegion {
+			targetRegion = testCase.Service.Region
+		}
+		region, err = s.routeRegion(testCtx, targetRegion, exactRegion)
+		if err != nil {
+			item.region = targetRegion
+			s.recordTestResult(results, item, testOutcome{status: "skipped", errorMessage: err.Error()})
+			return "skipped"
+		}
+		item.region = region
+		local = region == s.region
+	}
+
+	// Execute test, retrying transient failures; the test deadline covers all attempts
+	retryPolicy := testRun.RetryPolicy
+	if testSpec.Retry != nil {
+		retryPolicy = *testSpec.Retry
+	}
+	var result *testrunner.TestResult
+	var executor testrunner.Executor
+	var resolvedSecrets []string
+	executedSpec := &testSpec
+	if local {
+		// Secret references resolve only on the instance executing the test;
+		// the stored request keeps them
+		executedSpec, resolvedSecrets, err = s.resolveSpecSecrets(testCtx, &testSpec)
+		if err == nil {
+			executor, err = s.newExecutor(ctx, testSpec.Protocol, testCase.Service, vars["base_url"], item.apiVersion)
+		}
+		if err != nil {
+			s.recordTestResult(results, item, testOutcome{status: "failed", errorMessage: err.Error(), failureType: testrunner.FailureSpec})
+			return "failed"
+		}
+		result = testrunner.ExecuteWithRetry(testCtx, executor, executedSpec, retryPolicy)
+	} else {
+		result, err = s.executeInRegion(testCtx, region, regionJob{
+			TestRunID:  testRun.ID,
+			Pool:       testSpec.EgressPool,
+			ServiceID:  testCase.ServiceID,
+			BaseURL:    vars["base_url"],
+			APIVersion: item.apiVersion,
+			Spec:       testSpec,
+			Retry:      retryPolicy,
+		})
+		if err != nil {
+			result = &testrunner.TestResult{Status: "FAILED", ErrorMessage: err.Error(), FailureType: testrunner.ClassifyError(err)}
+		}
+	}
+
+	// Record result
+	status = "passed"
+	if ctx.Err() != nil {
+		status = "skipped"
+		result.ErrorMessage = skipReason(ctx, testRun)
+		result.FailureType = ""
+		result.FailureDetail = ""
+	} else if testCtx.Err() == context.DeadlineExceeded {
+		status = "timed_out"
+		result.ErrorMessage = deadlineReason(timeout)
+		result.FailureType = testrunner.FailureTimeout
+	} else if result.Status == "FAILED" {
+		status = "failed"
+	}
+	if status == "passed" && suite != nil {
+		// The response lacks what later test cases depend on
+		if err := suite.exportTest(testCase.ID, result.ResponseData); err != nil {
+			status = "failed"
+			result.ErrorMessage = err.Error()
+			result.FailureType = testrunner.FailureAssertion
+		}
+	}
+
+	result.ResponseData = redactSecrets(result.ResponseData, s.withResolvedAuth(ctx, testCase.Service), executedSpec, resolvedSecrets...)
+	result.ResponseData = s.redactResponse(result.ResponseData, testCase.Service)
+	logger.Debug("executed test case", "status", status, "duration", result.Duration, "attempts", result.Attempts, "failure_type", result.FailureType)
+	s.recordTestResult(results, item, testOutcome{
+		status:        status,
+		executionTime: int(result.Duration.Milliseconds()),
+		errorMessage:  result.ErrorMessage,
+		failureType:   result.FailureType,
+		failureDetail: result.FailureDetail,
+		attempts:      result.Attempts,
+		responseData:  result.ResponseData,
+		assertions:    assertionRecords(result),
 	})
+
+	// Security scans run locally, separately from the functional result
+	if testRun.SecurityScan && status != "skipped" && local {
+		s.scanEndpoint(ctx, executor, testCase, executedSpec, timeout)
+	}
+	if status != "skipped" {
+		s.detectLeaks(ctx, testCase, &testSpec, result)
+	}
+	return status
 }
 
-// recordTestResult records a single test result
-func (s *TestRunService) recordTestResult(testRunID, testCaseID, status string, executionTime int, errorMessage, responseData string) {
+// newExecutor creates the executor for a protocol, configured with the auth,
+// fixtures and API versioning of a service. Without a protocol the protocol
+// of the service is used. Secret references of the auth are resolved here,
+// on the instance executing the test.
+func (s *TestRunService) newExecutor(ctx context.Context, protocol string, service models.Service, baseURL, apiVersion string) (testrunner.Executor, error) {
+	if protocol == "" {
+		protocol = service.Protocol
+	}
+	authConfig, err := s.resolveAuth(ctx, service.AuthConfig)
+	if err != nil {
+		return nil, err
+	}
+	return testrunner.NewExecutor(protocol, testrunner.ExecutorConfig{
+		BaseURL:       baseURL,
+		ServiceID:     service.ID,
+		AuthConfig:    authConfig,
+		TokenProvider: s.tokenProvider,
+		Fixtures:      s.fixtures,
+		Versioning:    service.APIVersioning,
+		APIVersion:    apiVersion,
+		GRPC:          service.GRPC,
+		TLS:           service.TLS,
+		Logger:        s.runLogger(ctx),
+	})
+}
+
+// testOutcome is the outcome of a single test case to be recorded
+type testOutcome struct {
+	status        string
+	executionTime int
+	errorMessage  string
+	failureType   string // empty unless the test failed
+	failureDetail string
+	attempts      int // executions including retries, 0 when the test was not executed
+	responseData  string
+	assertions    []models.AssertionResult
+}
+
+// recordTestResult records a single test result with its assertion results.
+// The result is buffered and written with the next batch of the run, then
+// published as a run event.
+func (s *TestRunService) recordTestResult(results *resultBatcher, item runItem, outcome testOutcome) {
+	status, assertions := outcome.status, outcome.assertions
+
 	// Ensure responseData is valid JSON for JSONB column
+	responseData := outcome.responseData
 	if responseData == "" {
 		responseData = "{}"
 	}
-	
+
+	failureType, failureDetail := outcome.failureType, outcome.failureDetail
+	if status == "passed" || status == "skipped" {
+		failureType, failureDetail = "", ""
+	}
+
+	// The ID is assigned upfront so the assertions can reference it in the
+	// batch, and large bodies can be stored under it
+	resultID := uuid.New().String()
+	responseData = s.captureBody(results.testRunID, resultID, responseData)
 	testResult := &models.TestResult{
-		TestRunID:     testRunID,
-		TestCaseID:    testCaseID,
+		ID:            resultID,
+		TestRunID:     results.testRunID,
+		TestCaseID:    item.testCase.ID,
+		Position:      item.position,
+		APIVersion:    item.apiVersion,
+		Region:        item.region,
 		Status:        status,
-		ExecutionTimeMs: executionTime,
-		ErrorMessage:  errorMessage,
+		ExecutionTimeMs: outcome.executionTime,
+		ErrorMessage:  outcome.errorMessage,
+		FailureType:   failureType,
+		FailureDetail: failureDetail,
+		Iteration:     item.iteration,
+		Attempts:      outcome.attempts,
+		Flaky:         status == "passed" && outcome.attempts > 1,
 		ResponseData:  responseData,
+		Request:       item.request,
+		CreatedAt:     time.Now(), // when the test finished, not when its batch is written
+	}
+	if item.row != nil {
+		testResult.DataRow = models.NewJSONValue(item.row)
+	}
+	for i := range assertions {
+		assertions[i].TestResultID = testResult.ID
+	}
+
+	eventType := RunEventTestFailed
+	switch status {
+	case "passed":
+		eventType = RunEventTestPassed
+	case "skipped":
+		eventType = RunEventTestSkipped
+	}
+	results.add(pendingResult{
+		result:     testResult,
+		assertions: assertions,
+		event: RunEvent{
+			Type:         eventType,
+			TestRunID:    results.testRunID,
+			TestCaseID:   item.testCase.ID,
+			TestName:     item.testCase.Name,
+			Position:     item.position,
+			APIVersion:   item.apiVersion,
+			Region:       item.region,
+			Iteration:    item.iteration,
+			Attempts:     outcome.attempts,
+			Status:       status,
+			DurationMs:   int64(outcome.executionTime),
+			ErrorMessage: outcome.errorMessage,
+			FailureType:  failureType,
+			Assertions:   assertions,
+		},
+	})
+}
+
+// assertionRecords flattens the assertion results of an execution, including
+// those of every request matrix variant, into records for persistence
+func assertionRecords(result *testrunner.TestResult) []models.AssertionResult {
+	records := []models.AssertionResult{}
+	add := func(variant string, assertionResults []testrunner.AssertionResult) {
+		for _, assertionResult := range assertionResults {
+			records = append(records, models.AssertionResult{
+				Position: len(records),
+				Variant:  variant,
+				Type:     assertionResult.Type,
+				Path:     assertionResult.Path,
+				Matcher:  assertionResult.Matcher,
+				Expected: models.NewJSONValue(assertionResult.Expected),
+				Actual:   models.NewJSONValue(assertionResult.Actual),
+				Passed:   assertionResult.Passed,
+				Message:  assertionResult.Message,
+			})
+		}
 	}
 
-	s.db.Create(testResult)
+	add("", result.AssertionResults)
+	for _, variantResult := range result.VariantResults {
+		add(variantResult.Name, variantResult.AssertionResults)
+	}
+	return records
 }
 
 // GetTestRun retrieves a test run by ID
-func (s *TestRunService) GetTestRun(id string) (*models.TestRun, error) {
+func (s *TestRunService) GetTestRun(ctx context.Context, id string) (*models.TestRun, error) {
 	var testRun models.TestRun
-	if err := s.db.Preload("TestResults.TestCase").First(&testRun, "id = ?", id).Error; err != nil {
+	err := s.db.WithContext(ctx).Preload("TestResults", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Preload("TestResults.TestCase").Omit("debug_log", "security_findings").First(&testRun, "id = ?", id).Error
+	if err != nil {
 		return nil, err
 	}
 	return &testRun, nil
 }
 
+// GetDebugLog returns the debug output captured by a run started with debug.
+// The output is stored when the run finishes.
+func (s *TestRunService) GetDebugLog(ctx context.Context, id string) (*models.DebugLog, error) {
+	var testRun models.TestRun
+	if err := s.db.WithContext(ctx).Select("id", "status", "debug", "debug_log").First(&testRun, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if !testRun.Debug {
+		return nil, fmt.Errorf("%w: the run was not started with debug enabled", ErrNoDebugLog)
+	}
+	if testRun.Status == "running" {
+		return nil, fmt.Errorf("%w: the debug log is stored when the run finishes", ErrRunNotFinished)
+	}
+	if testRun.DebugLog.Entries == nil {
+		testRun.DebugLog.Entries = []models.LogEntry{}
+	}
+	return &testRun.DebugLog, nil
+}
+
 // GetTestResults retrieves test results for a test run
-func (s *TestRunService) GetTestResults(testRunID string) ([]models.TestResult, error) {
+func (s *TestRunService) GetTestResults(ctx context.Context, testRunID string) ([]models.TestResult, error) {
 	var testResults []models.TestResult
-	err := s.db.Preload("TestCase").Where("test_run_id = ?", testRunID).Find(&testResults).Error
+	err := s.db.WithContext(ctx).Preload("TestCase").Where("test_run_id = ?", testRunID).Order("position").Find(&testResults).Error
 	return testResults, err
 }
 
-// ListTestRuns retrieves all test runs with pagination
-func (s *TestRunService) ListTestRuns(limit, offset int) ([]models.TestRun, int64, error) {
+// GetAssertionResults retrieves the assertion results of a test result,
+// ensuring the result belongs to the given test run
+func (s *TestRunService) GetAssertionResults(ctx context.Context, testRunID, testResultID string) ([]models.AssertionResult, error) {
+	db := s.db.WithContext(ctx)
+
+	var testResult models.TestResult
+	if err := db.Select("id").First(&testResult, "id = ? AND test_run_id = ?", testResultID, testRunID).Error; err != nil {
+		return nil, err
+	}
+
+	var assertionResults []models.AssertionResult
+	err := db.Where("test_result_id = ?", testResult.ID).Order("position").Find(&assertionResults).Error
+	return assertionResults, err
+}
+
+// ListTestRuns retrieves the test runs of a project, or all test runs
+// without projectID, with pagination
+func (s *TestRunService) ListTestRuns(ctx context.Context, projectID string, limit, offset int) ([]models.TestRun, int64, error) {
+	db := inProject(s.reader.WithContext(ctx).Model(&models.TestRun{}), projectID)
+
 	var testRuns []models.TestRun
 	var total int64
 
 	// Get total count
-	if err := s.db.Model(&models.TestRun{}).Count(&total).Error; err != nil {
+	if err := db.Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
 	// Get paginated results
-	if err := s.db.Order("started_at DESC").Limit(limit).Offset(offset).Find(&testRuns).Error; err != nil {
+	// Config snapshots are only returned with a single run
+	if err := db.Omit("config", "debug_log", "security_findings").Order("started_at DESC").Limit(limit).Offset(offset).Find(&testRuns).Error; err != nil {
 		return nil, 0, err
 	}
 
@@ -2,30 +2,46 @@ package services
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"api-test-framework/internal/models"
+	"api-test-framework/internal/testrunner"
 
 	"gorm.io/gorm"
 )
 
 // TestService handles test case operations
 type TestService struct {
-	db *gorm.DB
+	db     *gorm.DB
+	reader *gorm.DB // serves list queries, see UseReadReplica
 }
 
 // NewTestService creates a new test service
 func NewTestService(db *gorm.DB) *TestService {
-	return &TestService{db: db}
+	return &TestService{db: db, reader: db}
+}
+
+// UseReadReplica serves list queries from a read-only replica
+func (s *TestService) UseReadReplica(replica *gorm.DB) {
+	s.reader = replica
+}
+
+// ValidateTestSpec strictly validates the JSON of a test spec. Its field
+// errors are returned as a *testrunner.SpecValidationError.
+func ValidateTestSpec(spec string) error {
+	if fieldErrors := testrunner.ValidateSpec([]byte(spec)); len(fieldErrors) > 0 {
+		return &testrunner.SpecValidationError{Errors: fieldErrors}
+	}
+	return nil
 }
 
 // CreateTest creates a new test case
 func (s *TestService) CreateTest(testCase *models.TestCase) error {
-	// Validate test spec JSON
-	var testSpec models.TestSpec
-	if err := json.Unmarshal([]byte(testCase.TestSpec), &testSpec); err != nil {
-		return fmt.Errorf("invalid test spec JSON: %v", err)
+	if err := ValidateTestSpec(testCase.TestSpec); err != nil {
+		return err
 	}
+	testCase.Tags = normalizeTags(testCase.Tags)
 
 	// Check if service exists
 	var service models.Service
@@ -36,6 +52,89 @@ func (s *TestService) CreateTest(testCase *models.TestCase) error {
 	return s.db.Create(testCase).Error
 }
 
+// ImportTests creates the imported test cases for a service in a single
+// transaction. Imported variables are added to the service variables without
+// overwriting existing keys. With smoke, as for API specs, the smoke suite of
+// the service is regenerated from the imported tests and returned.
+func (s *TestService) ImportTests(serviceID string, testCases []models.TestCase, variables map[string]string, smoke bool) (*models.TestSuite, error) {
+	var suite *models.TestSuite
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var service models.Service
+		if err := tx.First(&service, "id = ?", serviceID).Error; err != nil {
+			return fmt.Errorf("service not found: %v", err)
+		}
+		var err error
+		suite, err = importTests(tx, &service, testCases, variables, smoke)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suite, nil
+}
+
+// ImportService creates a new service together with its imported test cases
+// in a single transaction, and its smoke suite with smoke
+func (s *TestService) ImportService(service *models.Service, testCases []models.TestCase, variables map[string]string, smoke bool) (*models.TestSuite, error) {
+	var suite *models.TestSuite
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(service).Error; err != nil {
+			return fmt.Errorf("failed to create service: %v", err)
+		}
+		var err error
+		suite, err = importTests(tx, service, testCases, variables, smoke)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suite, nil
+}
+
+// importTests creates test cases for a service, merges imported variables
+// and, with smoke, regenerates the smoke suite of the service from them
+func importTests(tx *gorm.DB, service *models.Service, testCases []models.TestCase, variables map[string]string, smoke bool) (*models.TestSuite, error) {
+	for i := range testCases {
+		testCases[i].ServiceID = service.ID
+		if err := tx.Create(&testCases[i]).Error; err != nil {
+			return nil, fmt.Errorf("failed to create test '%s': %v", testCases[i].Name, err)
+		}
+	}
+
+	var suite *models.TestSuite
+	if smoke {
+		var err error
+		// Operations without a happy path leave the suite as it is
+		if suite, err = syncSmokeSuite(tx, service, testCases); err != nil && !errors.Is(err, ErrNoSmokeEndpoints) {
+			return nil, err
+		}
+	}
+
+	if len(variables) == 0 {
+		return suite, nil
+	}
+	merged := models.Variables{}
+	for key, value := range variables {
+		merged[key] = value
+	}
+	for key, value := range service.Variables {
+		merged[key] = value
+	}
+	if err := tx.Model(service).Update("variables", merged).Error; err != nil {
+		return nil, err
+	}
+	return suite, nil
+}
+
+// GetServiceBaseURL returns the base URL of the service tests are imported into
+func (s *TestService) GetServiceBaseURL(serviceID string) (string, error) {
+	var service models.Service
+	if err := s.db.Select("base_url").First(&service, "id = ?", serviceID).Error; err != nil {
+		return "", fmt.Errorf("service not found: %v", err)
+	}
+	return service.BaseURL, nil
+}
+
 // GetTest retrieves a test case by ID
 func (s *TestService) GetTest(id string) (*models.TestCase, error) {
 	var testCase models.TestCase
@@ -45,16 +144,19 @@ func (s *TestService) GetTest(id string) (*models.TestCase, error) {
 	return &testCase, nil
 }
 
-// ListTests retrieves all test cases with optional filtering
-func (s *TestService) ListTests(serviceID string, limit, offset int) ([]models.TestCase, int64, error) {
+// ListTests retrieves all test cases with optional filtering by project,
+// service and tags, see filterByTags
+func (s *TestService) ListTests(projectID, serviceID string, tags, excludeTags []string, limit, offset int) ([]models.TestCase, int64, error) {
 	var testCases []models.TestCase
 	var total int64
 
-	query := s.db.Model(&models.TestCase{}).Preload("Service")
+	query := s.reader.Model(&models.TestCase{}).Preload("Service")
 	
 	if serviceID != "" {
 		query = query.Where("service_id = ?", serviceID)
 	}
+	query = testCasesInProject(query, projectID)
+	query = filterByTags(query, tags, excludeTags)
 
 	// Get total count
 	if err := query.Count(&total).Error; err != nil {
@@ -69,12 +171,32 @@ func (s *TestService) ListTests(serviceID string, limit, offset int) ([]models.T
 	return testCases, total, nil
 }
 
+// ListArchivedTests retrieves the archived test cases of deleted services,
+// of one deleted service when serviceID is set, most recently archived first
+func (s *TestService) ListArchivedTests(serviceID string, limit, offset int) ([]models.ArchivedTestCase, int64, error) {
+	var testCases []models.ArchivedTestCase
+	var total int64
+
+	query := s.reader.Model(&models.ArchivedTestCase{})
+	if serviceID != "" {
+		query = query.Where("service_id = ?", serviceID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Order("archived_at DESC, name").Limit(limit).Offset(offset).Find(&testCases).Error; err != nil {
+		return nil, 0, err
+	}
+	return testCases, total, nil
+}
+
 // UpdateTest updates an existing test case and returns the updated test case
 func (s *TestService) UpdateTest(id string, testCase *models.TestCase) (*models.TestCase, error) {
-	// Validate test spec JSON
-	var testSpec models.TestSpec
-	if err := json.Unmarshal([]byte(testCase.TestSpec), &testSpec); err != nil {
-		return nil, fmt.Errorf("invalid test spec JSON: %v", err)
+	if err := ValidateTestSpec(testCase.TestSpec); err != nil {
+		return nil, err
+	}
+	if testCase.Tags != nil {
+		testCase.Tags = normalizeTags(testCase.Tags)
 	}
 
 	// First, get the existing test case to preserve the ID
@@ -83,6 +205,16 @@ func (s *TestService) UpdateTest(id string, testCase *models.TestCase) (*models.
 		return nil, err
 	}
 
+	// Secrets sent back masked keep their stored value. Updates with values
+	// skip the hooks of the model, so the spec is encrypted here.
+	if testCase.TestSpec != "" {
+		spec, err := models.EncryptTestSpec(models.UnmaskTestSpec(testCase.TestSpec, existingTestCase.TestSpec))
+		if err != nil {
+			return nil, err
+		}
+		testCase.TestSpec = spec
+	}
+
 	// Update the test case with the new data
 	if err := s.db.Model(&existingTestCase).Updates(testCase).Error; err != nil {
 		return nil, err
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+
+	"api-test-framework/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// normalizeTags trims tags and drops empty and repeated ones, keeping their
+// order. Tags compare case-sensitively.
+func normalizeTags(tags []string) models.StringList {
+	normalized := models.StringList{}
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// filterByTags restricts a test case query to the test cases carrying any of
+// tags and none of excludeTags. Empty lists select every test case.
+func filterByTags(query *gorm.DB, tags, excludeTags []string) *gorm.DB {
+	if tags = normalizeTags(tags); len(tags) > 0 {
+		conditions := make([]string, len(tags))
+		args := make([]interface{}, len(tags))
+		for i, tag := range tags {
+			conditions[i] = "test_cases.tags @> ?"
+			args[i] = tagJSON(tag)
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+	for _, tag := range normalizeTags(excludeTags) {
+		query = query.Where("NOT test_cases.tags @> ?", tagJSON(tag))
+	}
+	return query
+}
+
+// tagJSON encodes a tag as the JSONB array a tag list contains
+func tagJSON(tag string) string {
+	encoded, _ := json.Marshal([]string{tag})
+	return string(encoded)
+}
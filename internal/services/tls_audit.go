@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+	"api-test-framework/internal/notifications"
+	"api-test-framework/internal/testrunner"
+
+	"gorm.io/gorm"
+)
+
+// TLSAuditService periodically records the TLS version, cipher suite and
+// certificate chain of every service with an https base URL, and alerts the
+// notification channels of a service before its certificates expire.
+type TLSAuditService struct {
+	db        *gorm.DB
+	alertDays int
+	logger    *slog.Logger
+}
+
+// NewTLSAuditService creates a TLS audit service alerting alertDays before
+// certificates expire, unless a service sets its own expiry_alert_days
+func NewTLSAuditService(db *gorm.DB, alertDays int) *TLSAuditService {
+	return &TLSAuditService{db: db, alertDays: alertDays, logger: slog.Default()}
+}
+
+// SetLogger sets the logger of the service
+func (s *TLSAuditService) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// Run audits the services every interval until ctx is done. It does nothing
+// when no interval was configured.
+func (s *TLSAuditService) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if audited, err := s.AuditServices(ctx); err != nil {
+			s.logger.Error("TLS audit failed", "error", err)
+		} else if audited > 0 {
+			s.logger.Info("audited TLS of services", "count", audited)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// AuditServices audits the active services with an https or wss base URL
+// and returns how many were audited. Services resolving their base URL
+// through discovery are skipped.
+func (s *TLSAuditService) AuditServices(ctx context.Context) (int, error) {
+	var services []models.Service
+	if err := s.db.WithContext(ctx).
+		Where("is_active = ? AND (base_url LIKE ? OR base_url LIKE ?)", true, "https://%", "wss://%").
+		Find(&services).Error; err != nil {
+		return 0, err
+	}
+
+	audited := 0
+	for i := range services {
+		if services[i].Discovery.Provider != "" {
+			continue
+		}
+		if _, err := s.audit(ctx, &services[i]); err != nil {
+			return audited, fmt.Errorf("failed to audit service %s: %v", services[i].Name, err)
+		}
+		audited++
+	}
+	return audited, nil
+}
+
+// AuditService audits a single service now
+func (s *TLSAuditService) AuditService(ctx context.Context, id string) (*models.TLSAudit, error) {
+	var service models.Service
+	if err := s.db.WithContext(ctx).First(&service, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if service.Discovery.Provider != "" {
+		return nil, fmt.Errorf("%w: the base URL is resolved through %s discovery", testrunner.ErrNoTLS, service.Discovery.Provider)
+	}
+	return s.audit(ctx, &service)
+}
+
+// audit checks the TLS setup of a service, alerts when its certificates
+// expire soon and stores the audit on the service
+func (s *TLSAuditService) audit(ctx context.Context, service *models.Service) (*models.TLSAudit, error) {
+	audit, err := testrunner.AuditTLS(ctx, service.BaseURL, service.TLS, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// Each expiry date is alerted once, renewed certificates are alerted again
+	if service.TLSAudit != nil {
+		audit.AlertedExpiry = service.TLSAudit.AlertedExpiry
+	}
+	alertDays := s.alertDays
+	if service.TLS.ExpiryAlertDays > 0 {
+		alertDays = service.TLS.ExpiryAlertDays
+	}
+	if audit.ExpiresAt != nil && audit.DaysUntilExpiry <= alertDays {
+		if audit.DaysUntilExpiry >= 0 {
+			audit.Issues = append(audit.Issues, fmt.Sprintf("the certificate chain expires in %d days", audit.DaysUntilExpiry))
+		}
+		if (audit.AlertedExpiry == nil || !audit.AlertedExpiry.Equal(*audit.ExpiresAt)) && s.alertExpiry(ctx, service, audit) {
+			audit.AlertedExpiry = audit.ExpiresAt
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Model(&models.Service{}).Where("id = ?", service.ID).UpdateColumn("tls_audit", audit).Error; err != nil {
+		return nil, err
+	}
+	return audit, nil
+}
+
+// alertExpiry notifies the channels of a service that its certificates
+// expire soon. It reports false when every channel failed, so the next
+// audit tries again.
+func (s *TLSAuditService) alertExpiry(ctx context.Context, service *models.Service, audit *models.TLSAudit) bool {
+	logger := slog.With("service", service.Name, "expires_at", audit.ExpiresAt)
+	channels, errs := notifications.ForService(service.Notifications)
+	for _, err := range errs {
+		logger.Warn("invalid notification config", "error", err)
+	}
+
+	alert := notifications.Alert{
+		ServiceName: service.Name,
+		Title:       fmt.Sprintf("TLS certificate of %s expires in %d days", audit.Address, audit.DaysUntilExpiry),
+		Details: []string{
+			"Expires at " + audit.ExpiresAt.UTC().Format(time.RFC1123),
+		},
+	}
+	if audit.DaysUntilExpiry < 0 {
+		alert.Title = fmt.Sprintf("TLS certificate of %s expired", audit.Address)
+	}
+	if len(audit.Certificates) > 0 {
+		leaf := audit.Certificates[0]
+		alert.Details = append(alert.Details, "Subject "+leaf.Subject, "Issued by "+leaf.Issuer)
+		if len(leaf.DNSNames) > 0 {
+			alert.Details = append(alert.Details, "Names "+strings.Join(leaf.DNSNames, ", "))
+		}
+	}
+
+	logger.Warn("TLS certificate expires soon", "days", audit.DaysUntilExpiry)
+	attempted, alerted := 0, 0
+	for _, channel := range channels {
+		alerter, ok := channel.(notifications.Alerter)
+		if !ok {
+			continue
+		}
+		attempted++
+		if err := alerter.Alert(ctx, alert); err != nil {
+			logger.Warn("failed to send certificate expiry alert", "channel", channel.Name(), "error", err)
+			continue
+		}
+		alerted++
+	}
+	return attempted == 0 || alerted > 0
+}
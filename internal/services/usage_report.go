@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// ErrInvalidUsageQuery is returned for usage report queries with an unknown
+// interval or an empty time range
+var ErrInvalidUsageQuery = errors.New("invalid usage query")
+
+// usageIntervals are the periods usage can be reported by
+var usageIntervals = map[string]bool{"day": true, "week": true, "month": true}
+
+// UsageRates price usage for chargeback; zero rates are free
+type UsageRates struct {
+	PerTest      float64 `json:"per_test,omitempty"`
+	PerRunMinute float64 `json:"per_run_minute,omitempty"`
+	PerGB        float64 `json:"per_gb,omitempty"` // per GB stored
+	Currency     string  `json:"currency,omitempty"`
+}
+
+// UsageQuery selects the runs a usage report covers
+type UsageQuery struct {
+	ProjectID string    // empty reports every project
+	From      time.Time // runs started from, inclusive
+	To        time.Time // runs started until, exclusive
+	Interval  string    // day, week or month
+}
+
+// Usage is the consumption of a project, in total or over a period
+type Usage struct {
+	Runs          int64    `json:"runs"`
+	ExecutedTests int64    `json:"executed_tests"` // passed, failed and timed out tests
+	RunMinutes    float64  `json:"run_minutes"`
+	StoredBytes   int64    `json:"stored_bytes"` // current size of the runs and their results
+	Cost          *float64 `json:"cost,omitempty"`
+}
+
+// UsagePeriod is the usage of the runs started in a period
+type UsagePeriod struct {
+	Start time.Time `json:"start"`
+	Usage
+}
+
+// ProjectUsage is the usage of a project over the periods of a report. Runs
+// without a project are reported with a nil project ID.
+type ProjectUsage struct {
+	ProjectID   *string       `json:"project_id"`
+	ProjectName string        `json:"project_name,omitempty"`
+	Total       Usage         `json:"total"`
+	Periods     []UsagePeriod `json:"periods"`
+}
+
+// UsageReport reports the usage of projects for chargeback and capacity
+// planning
+type UsageReport struct {
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Interval string         `json:"interval"`
+	Rates    *UsageRates    `json:"rates,omitempty"`
+	Projects []ProjectUsage `json:"projects"`
+}
+
+// SetUsageRates sets the rates usage reports price usage at
+func (s *ProjectService) SetUsageRates(rates UsageRates) {
+	s.usageRates = rates
+}
+
+// GetUsageReport reports the runs, executed tests, run minutes and stored
+// bytes of every project, or of one, per period. Usage is attributed to the
+// period its run started in.
+func (s *ProjectService) GetUsageReport(query UsageQuery) (*UsageReport, error) {
+	if !usageIntervals[query.Interval] {
+		return nil, fmt.Errorf("%w: interval must be day, week or month", ErrInvalidUsageQuery)
+	}
+	if !query.To.After(query.From) {
+		return nil, fmt.Errorf("%w: to must be after from", ErrInvalidUsageQuery)
+	}
+
+	var runs []struct {
+		ProjectID       *string
+		Period          time.Time
+		Runs            int64
+		ExecutedTests   int64
+		ExecutionTimeMs int64
+		StoredBytes     int64
+	}
+	runQuery := s.db.Model(&models.TestRun{}).
+		Select("project_id, date_trunc(?, started_at AT TIME ZONE 'UTC') AS period, COUNT(*) AS runs, "+
+			"SUM(passed_tests + failed_tests + timed_out_tests) AS executed_tests, SUM(execution_time_ms) AS execution_time_ms, "+
+			"SUM(pg_column_size(test_runs.*)) AS stored_bytes", query.Interval).
+		Where("started_at >= ? AND started_at < ?", query.From, query.To).
+		Group("1, 2")
+	if err := inProject(runQuery, query.ProjectID).Scan(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate runs: %v", err)
+	}
+
+	var results []struct {
+		ProjectID   *string
+		Period      time.Time
+		StoredBytes int64
+	}
+	resultQuery := s.db.Table("test_results").
+		Select("test_runs.project_id, date_trunc(?, test_runs.started_at AT TIME ZONE 'UTC') AS period, "+
+			"SUM(pg_column_size(test_results.*)) AS stored_bytes", query.Interval).
+		Joins("JOIN test_runs ON test_runs.id = test_results.test_run_id").
+		Where("test_runs.started_at >= ? AND test_runs.started_at < ?", query.From, query.To).
+		Group("1, 2")
+	if query.ProjectID != "" {
+		resultQuery = resultQuery.Where("test_runs.project_id = ?", query.ProjectID)
+	}
+	if err := resultQuery.Scan(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate results: %v", err)
+	}
+
+	report := &UsageReport{From: query.From, To: query.To, Interval: query.Interval, Projects: []ProjectUsage{}}
+	if s.usageRates.priced() {
+		report.Rates = &s.usageRates
+	}
+
+	byProject := map[string]*ProjectUsage{}
+	periods := map[string]map[time.Time]*UsagePeriod{}
+	period := func(projectID *string, start time.Time) *UsagePeriod {
+		key := ""
+		if projectID != nil {
+			key = *projectID
+		}
+		if byProject[key] == nil {
+			byProject[key] = &ProjectUsage{ProjectID: projectID}
+			periods[key] = map[time.Time]*UsagePeriod{}
+		}
+		if periods[key][start] == nil {
+			periods[key][start] = &UsagePeriod{Start: start}
+		}
+		return periods[key][start]
+	}
+	for _, row := range runs {
+		usage := period(row.ProjectID, row.Period)
+		usage.Runs += row.Runs
+		usage.ExecutedTests += row.ExecutedTests
+		usage.RunMinutes += float64(row.ExecutionTimeMs) / float64(time.Minute/time.Millisecond)
+		usage.StoredBytes += row.StoredBytes
+	}
+	for _, row := range results {
+		period(row.ProjectID, row.Period).StoredBytes += row.StoredBytes
+	}
+
+	var projectIDs []string
+	for key := range byProject {
+		if key != "" {
+			projectIDs = append(projectIDs, key)
+		}
+	}
+	var projects []models.Project
+	if len(projectIDs) > 0 {
+		if err := s.db.Select("id, name").Where("id IN ?", projectIDs).Find(&projects).Error; err != nil {
+			return nil, fmt.Errorf("failed to retrieve projects: %v", err)
+		}
+	}
+	for _, project := range projects {
+		byProject[project.ID].ProjectName = project.Name
+	}
+
+	for key, projectUsage := range byProject {
+		for _, usage := range periods[key] {
+			usage.Cost = s.usageRates.cost(usage.Usage)
+			projectUsage.Total.Runs += usage.Runs
+			projectUsage.Total.ExecutedTests += usage.ExecutedTests
+			projectUsage.Total.RunMinutes += usage.RunMinutes
+			projectUsage.Total.StoredBytes += usage.StoredBytes
+			projectUsage.Periods = append(projectUsage.Periods, *usage)
+		}
+		sort.Slice(projectUsage.Periods, func(i, j int) bool {
+			return projectUsage.Periods[i].Start.Before(projectUsage.Periods[j].Start)
+		})
+		projectUsage.Total.Cost = s.usageRates.cost(projectUsage.Total)
+		report.Projects = append(report.Projects, *projectUsage)
+	}
+	// Projects by name, runs without a project last
+	sort.Slice(report.Projects, func(i, j int) bool {
+		a, b := report.Projects[i], report.Projects[j]
+		if (a.ProjectID == nil) != (b.ProjectID == nil) {
+			return b.ProjectID == nil
+		}
+		return a.ProjectName < b.ProjectName
+	})
+	return report, nil
+}
+
+// priced reports whether any rate is set
+func (r UsageRates) priced() bool {
+	return r.PerTest != 0 || r.PerRunMinute != 0 || r.PerGB != 0
+}
+
+// cost prices usage at the rates, nil when no rate is set
+func (r UsageRates) cost(usage Usage) *float64 {
+	if !r.priced() {
+		return nil
+	}
+	cost := float64(usage.ExecutedTests)*r.PerTest +
+		usage.RunMinutes*r.PerRunMinute +
+		float64(usage.StoredBytes)/(1<<30)*r.PerGB
+	return &cost
+}
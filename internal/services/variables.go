@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"api-test-framework/internal/discovery"
+	"api-test-framework/internal/models"
+)
+
+// Variable sources in increasing order of precedence
+const (
+	VariableSourceService     = "service"
+	VariableSourceDiscovery   = "discovery"
+	VariableSourceEnvironment = "environment"
+	VariableSourceRunOverride = "run_override"
+)
+
+// resolveVariables merges the service, environment and run-override variables
+// that apply to a service. Later scopes win. Within a scope, keys prefixed with
+// "<service name>." apply only to that service and take precedence over the
+// unprefixed key, so an environment can hold e.g. "billing.base_url".
+func resolveVariables(service models.Service, environment *models.Environment, overrides models.Variables) map[string]models.ResolvedVariable {
+	resolved := map[string]models.ResolvedVariable{
+		"base_url": {Value: service.BaseURL, Source: VariableSourceService},
+	}
+
+	apply := func(vars models.Variables, source string) {
+		prefix := service.Name + "."
+		for key, value := range vars {
+			if !strings.HasPrefix(key, prefix) {
+				resolved[key] = models.ResolvedVariable{Value: value, Source: source}
+			}
+		}
+		for key, value := range vars {
+			if strings.HasPrefix(key, prefix) {
+				resolved[strings.TrimPrefix(key, prefix)] = models.ResolvedVariable{Value: value, Source: source}
+			}
+		}
+	}
+
+	apply(service.Variables, VariableSourceService)
+	if environment != nil {
+		apply(environment.Variables, VariableSourceEnvironment)
+	}
+	apply(overrides, VariableSourceRunOverride)
+
+	return resolved
+}
+
+// SetServiceResolver sets the resolver of the base URLs of services configured
+// for service discovery
+func (s *TestRunService) SetServiceResolver(resolver *discovery.Resolver) {
+	s.serviceResolver = resolver
+}
+
+// resolveServiceVariables resolves the variables of a service like
+// resolveVariables, with the base URL of a service configured for discovery
+// resolved from its registry. Base URLs set by the environment or the run
+// still take precedence. When discovery fails the configured base URL is kept.
+func (s *TestRunService) resolveServiceVariables(ctx context.Context, service models.Service, environment *models.Environment, overrides models.Variables) map[string]models.ResolvedVariable {
+	resolved := resolveVariables(service, environment, overrides)
+	if service.Discovery.Provider == "" || resolved["base_url"].Source != VariableSourceService {
+		return resolved
+	}
+
+	environmentName := ""
+	if environment != nil {
+		environmentName = environment.Name
+	}
+	baseURL, err := s.serviceResolver.Resolve(ctx, service.Discovery, environmentName)
+	if err != nil {
+		s.runLogger(ctx).Warn("service discovery failed, using its base URL", "service", service.Name, "error", err)
+		return resolved
+	}
+	resolved["base_url"] = models.ResolvedVariable{Value: baseURL, Source: VariableSourceDiscovery}
+	return resolved
+}
+
+// variableValues flattens resolved variables into a name/value map for substitution
+func variableValues(resolved map[string]models.ResolvedVariable) map[string]string {
+	values := make(map[string]string, len(resolved))
+	for key, variable := range resolved {
+		values[key] = variable.Value
+	}
+	return values
+}
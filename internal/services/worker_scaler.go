@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"api-test-framework/internal/kubernetes"
+)
+
+// Labels of the worker Jobs spawned by the scaler
+const (
+	workerJobManagedBy   = "app.kubernetes.io/managed-by"
+	workerJobRegionLabel = "api-test-framework/worker-region"
+	workerJobManager     = "api-test-framework"
+)
+
+// WorkerScalerOptions configures a WorkerScaler. Exactly one of Deployment
+// and JobTemplate is set.
+type WorkerScalerOptions struct {
+	Region         string                 // region whose workers are scaled
+	Deployment     string                 // deployment scaled to the desired workers
+	JobTemplate    map[string]interface{} // manifest of the Jobs spawned as ephemeral workers
+	MinWorkers     int
+	MaxWorkers     int
+	WorkerCapacity int           // parallel tests of a worker, used until workers registered
+	ScaleDownDelay time.Duration // how long demand must stay lower before scaling down
+}
+
+// WorkerScaler scales the workers of a region with the depth of its job
+// queue: it scales a worker deployment, or spawns Jobs running ephemeral
+// workers that exit when idle. While it runs, the region is announced as
+// live, so tests are dispatched to it even when it has no worker yet.
+type WorkerScaler struct {
+	workers   *WorkerService
+	kube      *kubernetes.Client
+	options   WorkerScalerOptions
+	lowerFrom time.Time // since when fewer deployment replicas were desired
+	logger    *slog.Logger
+}
+
+// NewWorkerScaler creates a worker scaler
+func NewWorkerScaler(workers *WorkerService, kube *kubernetes.Client, options WorkerScalerOptions) (*WorkerScaler, error) {
+	if options.Region == "" {
+		return nil, fmt.Errorf("a region is required to scale workers")
+	}
+	if (options.Deployment == "") == (options.JobTemplate == nil) {
+		return nil, fmt.Errorf("either a deployment or a job template is required to scale workers")
+	}
+	if options.WorkerCapacity <= 0 {
+		options.WorkerCapacity = regionWorkerConcurrency
+	}
+	if options.MaxWorkers < options.MinWorkers {
+		options.MaxWorkers = options.MinWorkers
+	}
+	return &WorkerScaler{workers: workers, kube: kube, options: options, logger: slog.Default()}, nil
+}
+
+// SetLogger sets the logger of the scaler
+func (s *WorkerScaler) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// Run scales the workers every interval until ctx is done
+func (s *WorkerScaler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if s.options.MaxWorkers > 0 {
+			s.workers.announceRegion(ctx, s.options.Region)
+		}
+		if err := s.Scale(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error("worker scaling failed", "region", s.options.Region, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scale adjusts the workers to the current demand once
+func (s *WorkerScaler) Scale(ctx context.Context) error {
+	capacity, err := s.workers.Capacity(ctx, s.options.Region)
+	if err != nil {
+		return err
+	}
+	desired := s.desiredWorkers(capacity)
+
+	if s.options.Deployment != "" {
+		return s.scaleDeployment(ctx, desired)
+	}
+	return s.spawnJobs(ctx, desired)
+}
+
+// desiredWorkers returns the workers needed for the queued and active jobs
+// of a region, within the configured bounds
+func (s *WorkerScaler) desiredWorkers(capacity *RegionCapacity) int {
+	perWorker := s.options.WorkerCapacity
+	if capacity.Workers > 0 {
+		perWorker = capacity.Capacity / capacity.Workers
+	}
+	if perWorker <= 0 {
+		perWorker = 1
+	}
+
+	demand := int(capacity.QueuedJobs) + capacity.ActiveJobs
+	desired := (demand + perWorker - 1) / perWorker
+	if desired < s.options.MinWorkers {
+		desired = s.options.MinWorkers
+	}
+	if desired > s.options.MaxWorkers {
+		desired = s.options.MaxWorkers
+	}
+	return desired
+}
+
+// scaleDeployment scales the worker deployment up at once, and down once
+// demand stayed lower for the scale down delay
+func (s *WorkerScaler) scaleDeployment(ctx context.Context, desired int) error {
+	current, err := s.kube.DeploymentReplicas(ctx, s.options.Deployment)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case desired > current:
+		s.lowerFrom = time.Time{}
+	case desired < current:
+		if s.lowerFrom.IsZero() {
+			s.lowerFrom = time.Now()
+		}
+		if time.Since(s.lowerFrom) < s.options.ScaleDownDelay {
+			return nil
+		}
+		s.lowerFrom = time.Time{}
+	default:
+		s.lowerFrom = time.Time{}
+		return nil
+	}
+
+	if err := s.kube.ScaleDeployment(ctx, s.options.Deployment, desired); err != nil {
+		return err
+	}
+	s.logger.Info("scaled workers", "region", s.options.Region, "from", current, "to", desired)
+	return nil
+}
+
+// spawnJobs starts worker Jobs until the desired number runs. Jobs are never
+// stopped; their workers exit once idle.
+func (s *WorkerScaler) spawnJobs(ctx context.Context, desired int) error {
+	selector := fmt.Sprintf("%s=%s,%s=%s", workerJobManagedBy, workerJobManager, workerJobRegionLabel, s.options.Region)
+	active, err := s.kube.ActiveJobs(ctx, selector)
+	if err != nil {
+		return err
+	}
+
+	for i := active; i < desired; i++ {
+		manifest, err := s.jobManifest()
+		if err != nil {
+			return err
+		}
+		if err := s.kube.CreateJob(ctx, manifest); err != nil {
+			return err
+		}
+	}
+	if desired > active {
+		s.logger.Info("spawned worker jobs", "region", s.options.Region, "count", desired-active)
+	}
+	return nil
+}
+
+// jobManifest returns a copy of the job template with a generated name and
+// the labels identifying the workers of the region
+func (s *WorkerScaler) jobManifest() (map[string]interface{}, error) {
+	encoded, err := json.Marshal(s.options.JobTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid job template: %v", err)
+	}
+	var manifest map[string]interface{}
+	if err := json.Unmarshal(encoded, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid job template: %v", err)
+	}
+
+	metadata, _ := manifest["metadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+		manifest["metadata"] = metadata
+	}
+	// Every Job needs a unique name
+	if name, _ := metadata["name"].(string); name != "" {
+		delete(metadata, "name")
+		if metadata["generateName"] == nil {
+			metadata["generateName"] = name + "-"
+		}
+	}
+	if metadata["generateName"] == nil {
+		metadata["generateName"] = "test-worker-" + s.options.Region + "-"
+	}
+	labels, _ := metadata["labels"].(map[string]interface{})
+	if labels == nil {
+		labels = map[string]interface{}{}
+		metadata["labels"] = labels
+	}
+	labels[workerJobManagedBy] = workerJobManager
+	labels[workerJobRegionLabel] = s.options.Region
+	return manifest, nil
+}
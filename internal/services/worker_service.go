@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrWorkerNotFound is returned for heartbeats of unknown or pruned workers,
+// which must register again
+var ErrWorkerNotFound = errors.New("worker not found")
+
+// workerPruneAfter is how long a silent worker stays registered
+const workerPruneAfter = 10 * regionWorkerTTL
+
+// WorkerService keeps the registry of workers and their load, so the
+// capacity of every region is known to the scheduler and the scaler
+type WorkerService struct {
+	db          *gorm.DB
+	redisClient *redis.Client
+	logger      *slog.Logger
+}
+
+// WorkerRegistration is the request body registering a worker
+type WorkerRegistration struct {
+	Name     string `json:"name" binding:"required"`
+	Region   string `json:"region" binding:"required"`
+	Pool     string `json:"pool"`     // egress pool whose tests the worker executes instead of those of its region
+	Capacity int    `json:"capacity"` // defaults to the concurrency of built-in workers
+}
+
+// RegionCapacity summarizes the live workers and pending jobs of a region,
+// or of an egress pool of workers in the region
+type RegionCapacity struct {
+	Region     string `json:"region"`
+	Pool       string `json:"pool,omitempty"`
+	Workers    int    `json:"workers"`
+	Capacity   int    `json:"capacity"`    // tests the workers execute in parallel
+	ActiveJobs int    `json:"active_jobs"` // tests being executed
+	QueuedJobs int64  `json:"queued_jobs"` // tests waiting for a worker
+}
+
+// NewWorkerService creates a new worker service
+func NewWorkerService(db *gorm.DB, redisClient *redis.Client) *WorkerService {
+	return &WorkerService{db: db, redisClient: redisClient, logger: slog.Default()}
+}
+
+// SetLogger sets the logger of the service
+func (s *WorkerService) SetLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// Register registers a worker, or re-registers a worker of the same name, and
+// announces its region, or its egress pool, as live
+func (s *WorkerService) Register(ctx context.Context, registration WorkerRegistration) (*models.Worker, error) {
+	worker := &models.Worker{
+		Name:            registration.Name,
+		Region:          registration.Region,
+		Pool:            registration.Pool,
+		Capacity:        registration.Capacity,
+		LastHeartbeatAt: time.Now(),
+	}
+	if worker.Capacity <= 0 {
+		worker.Capacity = regionWorkerConcurrency
+	}
+
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"region", "pool", "capacity", "active_jobs", "last_heartbeat_at"}),
+	}).Create(worker).Error
+	if err != nil {
+		return nil, err
+	}
+	// The id of an existing worker is not returned by the upsert
+	if err := s.db.WithContext(ctx).First(worker, "name = ?", worker.Name).Error; err != nil {
+		return nil, err
+	}
+
+	s.pruneWorkers(ctx)
+	s.announce(ctx, worker)
+	return worker, nil
+}
+
+// Heartbeat records that a worker is alive and how many tests it executes
+func (s *WorkerService) Heartbeat(ctx context.Context, id string, activeJobs int) (*models.Worker, error) {
+	update := s.db.WithContext(ctx).Model(&models.Worker{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"active_jobs": activeJobs, "last_heartbeat_at": time.Now()})
+	if update.Error != nil {
+		return nil, update.Error
+	}
+	if update.RowsAffected == 0 {
+		return nil, ErrWorkerNotFound
+	}
+
+	var worker models.Worker
+	if err := s.db.WithContext(ctx).First(&worker, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	s.announce(ctx, &worker)
+	return &worker, nil
+}
+
+// Deregister removes a worker, e.g. when it shuts down
+func (s *WorkerService) Deregister(ctx context.Context, id string) error {
+	result := s.db.WithContext(ctx).Delete(&models.Worker{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWorkerNotFound
+	}
+	return nil
+}
+
+// ListWorkers lists the live workers, optionally of a single region
+func (s *WorkerService) ListWorkers(ctx context.Context, region string) ([]models.Worker, error) {
+	query := s.db.WithContext(ctx).Where("last_heartbeat_at > ?", time.Now().Add(-regionWorkerTTL))
+	if region != "" {
+		query = query.Where("region = ?", region)
+	}
+	var workers []models.Worker
+	if err := query.Order("region, name").Find(&workers).Error; err != nil {
+		return nil, err
+	}
+	return workers, nil
+}
+
+// Capacity summarizes the live workers and queued jobs of a region, egress
+// pools excluded
+func (s *WorkerService) Capacity(ctx context.Context, region string) (*RegionCapacity, error) {
+	capacities, err := s.capacities(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	if len(capacities) == 0 {
+		return &RegionCapacity{Region: region}, nil
+	}
+	return &capacities[0], nil
+}
+
+// Capacities summarizes the live workers and queued jobs of every region
+// and egress pool with a live worker
+func (s *WorkerService) Capacities(ctx context.Context) ([]RegionCapacity, error) {
+	return s.capacities(ctx, "")
+}
+
+// capacities sums the capacity of the live workers per region and egress
+// pool, optionally of a single region, and adds the length of the job queues.
+// The capacity of the region itself comes first.
+func (s *WorkerService) capacities(ctx context.Context, region string) ([]RegionCapacity, error) {
+	workers, err := s.ListWorkers(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct{ region, pool string }
+	byGroup := map[group]*RegionCapacity{}
+	if region != "" {
+		byGroup[group{region: region}] = &RegionCapacity{Region: region}
+	}
+	for _, worker := range workers {
+		key := group{region: worker.Region, pool: worker.Pool}
+		capacity, ok := byGroup[key]
+		if !ok {
+			capacity = &RegionCapacity{Region: worker.Region, Pool: worker.Pool}
+			byGroup[key] = capacity
+		}
+		capacity.Workers++
+		capacity.Capacity += worker.Capacity
+		capacity.ActiveJobs += worker.ActiveJobs
+	}
+
+	capacities := make([]RegionCapacity, 0, len(byGroup))
+	for _, capacity := range byGroup {
+		if s.redisClient != nil {
+			queue, target := regionJobsKey(capacity.Region), "region "+capacity.Region
+			if capacity.Pool != "" {
+				queue, target = poolJobsKey(capacity.Pool), "egress pool "+capacity.Pool
+			}
+			queued, err := s.redisClient.LLen(ctx, queue).Result()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read job queue of %s: %v", target, err)
+			}
+			capacity.QueuedJobs = queued
+		}
+		capacities = append(capacities, *capacity)
+	}
+	sort.Slice(capacities, func(i, j int) bool {
+		if capacities[i].Region != capacities[j].Region {
+			return capacities[i].Region < capacities[j].Region
+		}
+		return capacities[i].Pool < capacities[j].Pool
+	})
+	return capacities, nil
+}
+
+// announce marks the region of a worker, or its egress pool, as live for
+// routing tests to it. Workers of a pool do not serve their region.
+func (s *WorkerService) announce(ctx context.Context, worker *models.Worker) {
+	if worker.Pool != "" {
+		s.announcePool(ctx, worker.Pool)
+		return
+	}
+	s.announceRegion(ctx, worker.Region)
+}
+
+// announceRegion marks a region as live for routing tests to it
+func (s *WorkerService) announceRegion(ctx context.Context, region string) {
+	if s.redisClient == nil || region == "" {
+		return
+	}
+	s.redisClient.ZAdd(ctx, regionWorkersKey, &redis.Z{Score: float64(time.Now().Unix()), Member: region})
+}
+
+// announcePool marks an egress pool as live for routing tests to it
+func (s *WorkerService) announcePool(ctx context.Context, pool string) {
+	if s.redisClient == nil {
+		return
+	}
+	s.redisClient.ZAdd(ctx, poolWorkersKey, &redis.Z{Score: float64(time.Now().Unix()), Member: pool})
+}
+
+// pruneWorkers removes workers that stopped sending heartbeats long ago
+func (s *WorkerService) pruneWorkers(ctx context.Context) {
+	if err := s.db.WithContext(ctx).Where("last_heartbeat_at < ?", time.Now().Add(-workerPruneAfter)).Delete(&models.Worker{}).Error; err != nil {
+		s.logger.Warn("failed to prune workers", "error", err)
+	}
+}
@@ -0,0 +1,89 @@
+package testrunner
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"api-test-framework/internal/models"
+
+	"github.com/gavv/httpexpect/v2"
+)
+
+// WithAuth configures the executor to authenticate requests using the
+// service's auth configuration. OAuth2 tokens are obtained from tokenProvider
+// and cached per service ID.
+func (e *HTTPExpectExecutor) WithAuth(serviceID string, authConfig models.AuthConfig, tokenProvider *OAuth2TokenProvider) *HTTPExpectExecutor {
+	e.serviceID = serviceID
+	e.authConfig = authConfig
+	e.tokenProvider = tokenProvider
+	return e
+}
+
+// effectiveAuth returns the auth configuration for a request: the per-test
+// override from the request spec when present, the service's otherwise
+func (e *HTTPExpectExecutor) effectiveAuth(requestSpec *models.RequestSpec) models.AuthConfig {
+	if requestSpec.Auth != nil {
+		return *requestSpec.Auth
+	}
+	return e.authConfig
+}
+
+// applyAuth adds the credentials described by the auth configuration to the request
+func (e *HTTPExpectExecutor) applyAuth(ctx context.Context, req *httpexpect.Request, authConfig models.AuthConfig) (*httpexpect.Request, error) {
+	credential, err := authCredential(ctx, authConfig, e.tokenProvider, e.serviceID)
+	if err != nil || credential.name == "" {
+		return req, err
+	}
+	if credential.inQuery {
+		return req.WithQuery(credential.name, credential.value), nil
+	}
+	return req.WithHeader(credential.name, credential.value), nil
+}
+
+// credential is a header, or query parameter, carrying the credentials of a request
+type credential struct {
+	name    string
+	value   string
+	inQuery bool
+}
+
+// authCredential returns the credential described by an auth configuration;
+// its name is empty when no authentication is configured. OAuth2 tokens are
+// cached for the service and the parameters of their token request.
+func authCredential(ctx context.Context, authConfig models.AuthConfig, tokenProvider *OAuth2TokenProvider, serviceID string) (credential, error) {
+	switch authConfig.Type {
+	case "", "none":
+		return credential{}, nil
+
+	case "bearer":
+		if authConfig.Token == "" {
+			return credential{}, fmt.Errorf("token is required for bearer authentication")
+		}
+		return credential{name: "Authorization", value: "Bearer " + authConfig.Token}, nil
+
+	case "api_key":
+		if authConfig.KeyName == "" {
+			return credential{}, fmt.Errorf("key_name is required for api_key authentication")
+		}
+		// The key is sent as a header unless extra.in is "query"
+		return credential{name: authConfig.KeyName, value: authConfig.KeyValue, inQuery: authConfig.Extra["in"] == "query"}, nil
+
+	case "basic":
+		credentials := base64.StdEncoding.EncodeToString([]byte(authConfig.Username + ":" + authConfig.Password))
+		return credential{name: "Authorization", value: "Basic " + credentials}, nil
+
+	case "oauth2":
+		if tokenProvider == nil {
+			return credential{}, fmt.Errorf("no token provider configured for oauth2 authentication")
+		}
+		token, err := tokenProvider.Token(ctx, serviceID, authConfig)
+		if err != nil {
+			return credential{}, fmt.Errorf("failed to acquire oauth2 token: %v", err)
+		}
+		return credential{name: "Authorization", value: "Bearer " + token}, nil
+
+	default:
+		return credential{}, fmt.Errorf("unsupported auth type: %s", authConfig.Type)
+	}
+}
@@ -0,0 +1,190 @@
+package testrunner
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+const (
+	// maxIdleConnsPerOrigin is how many keep-alive connections a pooled client
+	// keeps open to its origin, enough for the test workers of parallel runs
+	maxIdleConnsPerOrigin = 64
+	// pooledClientIdleTimeout removes clients that sent no request for a while,
+	// closing their connections
+	pooledClientIdleTimeout = 10 * time.Minute
+)
+
+// DefaultClientPool is the client pool of the HTTP based executors
+var DefaultClientPool = NewClientPool()
+
+// ClientPool shares HTTP clients between the executors of tests against the
+// same origin with the same TLS settings, so their keep-alive connections
+// are reused instead of opening new connections for every test
+type ClientPool struct {
+	mu      sync.Mutex
+	clients map[clientKey]*pooledClient
+}
+
+// clientKey identifies the clients of a pool
+type clientKey struct {
+	origin string // scheme and host of the base URL, empty for absolute request URLs
+	tls    models.TLSConfig
+}
+
+// pooledClient is a client of a pool with its usage counters
+type pooledClient struct {
+	client   *http.Client
+	lastUsed atomic.Int64 // unix nanoseconds
+	requests atomic.Int64
+	opened   atomic.Int64 // requests sent on a new connection
+	reused   atomic.Int64 // requests sent on a kept-alive connection
+}
+
+// ClientStats reports the usage of a pooled client
+type ClientStats struct {
+	Origin             string `json:"origin"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+	ServerName         string `json:"server_name,omitempty"`
+	Requests           int64  `json:"requests"`
+	ConnectionsOpened  int64  `json:"connections_opened"`
+	ConnectionsReused  int64  `json:"connections_reused"`
+}
+
+// NewClientPool creates an empty client pool
+func NewClientPool() *ClientPool {
+	return &ClientPool{clients: map[clientKey]*pooledClient{}}
+}
+
+// Client returns the client for a base URL and TLS settings, creating it on
+// first use. Clients have no timeout; requests are bounded by their context.
+func (p *ClientPool) Client(baseURL string, tlsConfig models.TLSConfig) *http.Client {
+	// Alerting settings do not change the client
+	tlsConfig.ExpiryAlertDays = 0
+	key := clientKey{origin: clientOrigin(baseURL), tls: tlsConfig}
+	now := time.Now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictIdle(now)
+
+	pooled, ok := p.clients[key]
+	if !ok {
+		pooled = &pooledClient{}
+		pooled.client = &http.Client{Transport: &countingTransport{next: newPooledTransport(tlsConfig), pooled: pooled}}
+		p.clients[key] = pooled
+	}
+	pooled.lastUsed.Store(now.UnixNano())
+	return pooled.client
+}
+
+// Stats reports the usage of every client of the pool, ordered by origin
+func (p *ClientPool) Stats() []ClientStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := make([]ClientStats, 0, len(p.clients))
+	for key, pooled := range p.clients {
+		stats = append(stats, ClientStats{
+			Origin:             key.origin,
+			InsecureSkipVerify: key.tls.InsecureSkipVerify,
+			ServerName:         key.tls.ServerName,
+			Requests:           pooled.requests.Load(),
+			ConnectionsOpened:  pooled.opened.Load(),
+			ConnectionsReused:  pooled.reused.Load(),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Origin < stats[j].Origin
+	})
+	return stats
+}
+
+// evictIdle removes the clients not used within pooledClientIdleTimeout.
+// Executors still holding an evicted client keep working with it.
+func (p *ClientPool) evictIdle(now time.Time) {
+	for key, pooled := range p.clients {
+		if now.Sub(time.Unix(0, pooled.lastUsed.Load())) > pooledClientIdleTimeout {
+			pooled.client.CloseIdleConnections()
+			delete(p.clients, key)
+		}
+	}
+}
+
+// closeAll closes and removes every client of the pool, e.g. once the egress
+// of new connections changed
+func (p *ClientPool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, pooled := range p.clients {
+		pooled.client.CloseIdleConnections()
+		delete(p.clients, key)
+	}
+}
+
+// clientOrigin returns the scheme and host of a base URL
+func clientOrigin(baseURL string) string {
+	parsed, err := neturl.Parse(baseURL)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	return strings.ToLower(parsed.Scheme + "://" + parsed.Host)
+}
+
+// newPooledTransport creates the transport of a pooled client
+func newPooledTransport(tlsConfig models.TLSConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = maxIdleConnsPerOrigin
+	transport.TLSClientConfig = tlsClientConfig(tlsConfig)
+	transport.Proxy = egressProxyFunc()
+	transport.DialContext = egressDialer(30 * time.Second).DialContext
+	return transport
+}
+
+// tlsClientConfig returns the TLS configuration of a service, nil for the defaults
+func tlsClientConfig(tlsConfig models.TLSConfig) *tls.Config {
+	if !tlsConfig.InsecureSkipVerify && tlsConfig.ServerName == "" {
+		return nil
+	}
+	return &tls.Config{
+		InsecureSkipVerify: tlsConfig.InsecureSkipVerify,
+		ServerName:         tlsConfig.ServerName,
+	}
+}
+
+// countingTransport counts the requests of a pooled client and whether they
+// were sent on a new or a kept-alive connection
+type countingTransport struct {
+	next   http.RoundTripper
+	pooled *pooledClient
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.pooled.requests.Add(1)
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.pooled.reused.Add(1)
+			} else {
+				t.pooled.opened.Add(1)
+			}
+		},
+	}
+	return t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+// CloseIdleConnections closes the idle connections of the underlying transport
+func (t *countingTransport) CloseIdleConnections() {
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
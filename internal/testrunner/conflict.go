@@ -0,0 +1,206 @@
+package testrunner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"github.com/tidwall/gjson"
+)
+
+// Defaults of conflict tests
+const (
+	conflictVersionPath   = "headers.ETag"
+	conflictVersionHeader = "If-Match"
+)
+
+// defaultConflictStatuses are the statuses rejecting a stale update
+var defaultConflictStatuses = []int{409, 412}
+
+// ConflictUpdate is the outcome of one of the concurrent updates of a
+// conflict test
+type ConflictUpdate struct {
+	Client       int             `json:"client"`
+	StatusCode   int             `json:"status_code,omitempty"`
+	Duration     time.Duration   `json:"duration"`
+	ErrorMessage string          `json:"error_message,omitempty"`
+	Body         json.RawMessage `json:"body,omitempty"`
+}
+
+// executeConflict runs an optimistic-concurrency test: the request of the
+// test reads the resource and is checked by the test's assertions, then two
+// clients send their updates at once with the version captured from the read.
+// The test passes when exactly one update succeeds with a 2xx and the other
+// is rejected with a conflict status. Both updates succeeding is a lost
+// update, the classic sign of missing version checks.
+func (e *HTTPExpectExecutor) executeConflict(ctx context.Context, testSpec *models.TestSpec) *TestResult {
+	conflict := testSpec.Conflict
+	start := time.Now()
+
+	readSpec := *testSpec
+	readSpec.Conflict = nil
+	result := e.executePolled(ctx, &readSpec)
+	result.StartTime = start
+	if result.Status == "FAILED" {
+		result.ErrorMessage = "read: " + result.ErrorMessage
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	versionPath := conflict.VersionPath
+	if versionPath == "" {
+		versionPath = conflictVersionPath
+	}
+	version, found := conflictVersion(gjson.Parse(result.ResponseData), versionPath)
+	if !found {
+		result.Status = "FAILED"
+		result.ErrorMessage = fmt.Sprintf("conflict: no version found at %s in the read response", versionPath)
+		result.FailureType = FailureAssertion
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	requests := []models.RequestSpec{conflict.Update, conflict.Update}
+	if conflict.SecondUpdate != nil {
+		requests[1] = *conflict.SecondUpdate
+	}
+	updates := make([]ConflictUpdate, len(requests))
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, request := range requests {
+		updateSpec := conflictUpdateSpec(testSpec, conflict, request, version, versionPath)
+		wg.Add(1)
+		go func(i int, updateSpec *models.TestSpec) {
+			defer wg.Done()
+			<-release
+			executed := e.executeRequest(ctx, updateSpec)
+			update := ConflictUpdate{Client: i + 1, Duration: executed.Duration}
+			response := gjson.Parse(executed.ResponseData)
+			if status := response.Get("status_code"); status.Exists() {
+				update.StatusCode = int(status.Int())
+				if body := response.Get("body"); body.Exists() {
+					update.Body = json.RawMessage(body.Raw)
+				}
+			} else {
+				update.ErrorMessage = executed.ErrorMessage
+			}
+			updates[i] = update
+		}(i, updateSpec)
+	}
+	close(release)
+	wg.Wait()
+
+	conflictStatuses := conflict.ConflictStatuses
+	if len(conflictStatuses) == 0 {
+		conflictStatuses = defaultConflictStatuses
+	}
+	var succeeded, rejected int
+	outcomes := make([]string, len(updates))
+	for i, update := range updates {
+		switch {
+		case update.StatusCode >= 200 && update.StatusCode < 300:
+			succeeded++
+		case containsStatus(conflictStatuses, update.StatusCode):
+			rejected++
+		}
+		outcomes[i] = fmt.Sprint(update.StatusCode)
+		if update.StatusCode == 0 {
+			outcomes[i] = "no response (" + update.ErrorMessage + ")"
+		}
+	}
+
+	// Keep the read response and add the updates for the result details
+	var responseData map[string]interface{}
+	if json.Unmarshal([]byte(result.ResponseData), &responseData) == nil {
+		responseData["conflict"] = map[string]interface{}{
+			"version": version,
+			"updates": updates,
+		}
+		if encoded, err := json.Marshal(responseData); err == nil {
+			result.ResponseData = string(encoded)
+		}
+	}
+
+	switch {
+	case succeeded == 1 && rejected == 1:
+	case succeeded == 2:
+		result.Status = "FAILED"
+		result.ErrorMessage = fmt.Sprintf("conflict: both concurrent updates with version %s succeeded (%s), the second should have been rejected with %s", version, strings.Join(outcomes, " and "), describeStatuses(conflictStatuses))
+		result.FailureType = FailureAssertion
+	default:
+		result.Status = "FAILED"
+		result.ErrorMessage = fmt.Sprintf("conflict: expected one update to succeed and the other to be rejected with %s, got %s", describeStatuses(conflictStatuses), strings.Join(outcomes, " and "))
+		result.FailureType = FailureAssertion
+	}
+	result.Duration = time.Since(start)
+	return result
+}
+
+// conflictUpdateSpec builds the test spec of an update: {{version}} is
+// replaced with the captured version, which is also sent in the version
+// header. Updates are judged by their status, so the spec asserts nothing.
+func conflictUpdateSpec(testSpec *models.TestSpec, conflict *models.ConflictSpec, request models.RequestSpec, version, versionPath string) *models.TestSpec {
+	updateSpec := &models.TestSpec{
+		Name:       testSpec.Name,
+		Protocol:   testSpec.Protocol,
+		Request:    request,
+		Assertions: []models.AssertionSpec{},
+	}
+	ApplyVariables(updateSpec, map[string]string{"version": version})
+
+	header := conflict.VersionHeader
+	if header == "" && strings.HasPrefix(strings.ToLower(versionPath), "headers.") {
+		header = conflictVersionHeader
+	}
+	if header != "" {
+		updateSpec.Request.Headers = mergeHeaders(updateSpec.Request.Headers, map[string]string{header: version})
+	}
+	return updateSpec
+}
+
+// conflictVersion returns the version at a gjson path of a response. Header
+// names are matched case-insensitively and the first value is used.
+func conflictVersion(response gjson.Result, path string) (string, bool) {
+	if name, isHeader := strings.CutPrefix(path, "headers."); isHeader {
+		var version string
+		var found bool
+		response.Get("headers").ForEach(func(key, values gjson.Result) bool {
+			if strings.EqualFold(key.String(), name) {
+				value := values
+				if values.IsArray() {
+					value = values.Get("0")
+				}
+				version, found = value.String(), value.Exists()
+				return false
+			}
+			return true
+		})
+		return version, found && version != ""
+	}
+	value := response.Get(path)
+	return value.String(), value.Exists() && value.String() != ""
+}
+
+// containsStatus reports whether a status code is one of statuses
+func containsStatus(statuses []int, status int) bool {
+	for _, candidate := range statuses {
+		if candidate == status {
+			return true
+		}
+	}
+	return false
+}
+
+// describeStatuses renders status codes as "409 or 412"
+func describeStatuses(statuses []int) string {
+	described := make([]string, len(statuses))
+	for i, status := range statuses {
+		described[i] = fmt.Sprint(status)
+	}
+	return strings.Join(described, " or ")
+}
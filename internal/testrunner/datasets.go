@@ -0,0 +1,156 @@
+package testrunner
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// maxDataRows caps the iterations of a single data-driven test
+const maxDataRows = 1000
+
+// DataRows returns the parameter rows of a data-driven test spec, taken from
+// its inline data or from the referenced dataset fixture. Specs without data
+// return no rows.
+func DataRows(testSpec *models.TestSpec, fixtures FixtureLoader) ([]map[string]interface{}, error) {
+	rows := testSpec.Data
+	if testSpec.Dataset != nil {
+		if len(rows) > 0 {
+			return nil, fmt.Errorf("data and dataset are mutually exclusive")
+		}
+		if fixtures == nil {
+			return nil, fmt.Errorf("dataset %s cannot be loaded: no fixture store configured", testSpec.Dataset.FixtureID)
+		}
+		version, err := fixtures.LoadFixture(testSpec.Dataset.FixtureID, testSpec.Dataset.Version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load dataset %s: %v", testSpec.Dataset.FixtureID, err)
+		}
+		if rows, err = parseDataset(version); err != nil {
+			return nil, fmt.Errorf("invalid dataset %s: %v", testSpec.Dataset.FixtureID, err)
+		}
+		if len(rows) == 0 {
+			return nil, fmt.Errorf("dataset %s has no rows", testSpec.Dataset.FixtureID)
+		}
+	}
+	if len(rows) > maxDataRows {
+		return nil, fmt.Errorf("data has %d rows, at most %d are allowed", len(rows), maxDataRows)
+	}
+	return rows, nil
+}
+
+// parseDataset decodes a CSV file with a header row, or a JSON array of objects
+func parseDataset(version *models.FixtureVersion) ([]map[string]interface{}, error) {
+	content := bytes.TrimPrefix(version.Content, []byte("\xef\xbb\xbf"))
+	isCSV := strings.Contains(version.ContentType, "csv") || strings.EqualFold(path.Ext(version.FileName), ".csv")
+	if !isCSV {
+		var rows []map[string]interface{}
+		if err := json.Unmarshal(content, &rows); err != nil {
+			return nil, fmt.Errorf("expected a JSON array of objects: %v", err)
+		}
+		return rows, nil
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	header := records[0]
+	rows := make([]map[string]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, field := range header {
+			row[strings.TrimSpace(field)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ApplyDataRow substitutes {{row.field}} placeholders with the values of a
+// data row, and clears the data of the spec so it describes a single
+// iteration. A body or expected value consisting of a single placeholder
+// takes the value with its JSON type, so numbers stay numbers.
+func ApplyDataRow(testSpec *models.TestSpec, row map[string]interface{}) {
+	testSpec.Data = nil
+	testSpec.Dataset = nil
+
+	typed := make(map[string]interface{}, len(row))
+	vars := make(map[string]string, len(row))
+	for field, value := range row {
+		name := "row." + field
+		typed[name] = value
+		if s, ok := value.(string); ok {
+			vars[name] = s
+		} else {
+			encoded, _ := json.Marshal(value)
+			vars[name] = string(encoded)
+		}
+	}
+
+	testSpec.Request.Body = substituteTyped(testSpec.Request.Body, typed)
+	if graphQL := testSpec.Request.GraphQL; graphQL != nil && graphQL.Variables != nil {
+		graphQL.Variables, _ = substituteTyped(graphQL.Variables, typed).(map[string]interface{})
+	}
+	if script := testSpec.Request.WebSocket; script != nil {
+		for i := range script.Steps {
+			script.Steps[i].Send = substituteTyped(script.Steps[i].Send, typed)
+			if receive := script.Steps[i].Receive; receive != nil {
+				for j := range receive.Assertions {
+					receive.Assertions[j].Expected = substituteTyped(receive.Assertions[j].Expected, typed)
+				}
+			}
+		}
+	}
+	for i := range testSpec.Assertions {
+		testSpec.Assertions[i].Expected = substituteTyped(testSpec.Assertions[i].Expected, typed)
+	}
+	if testSpec.PollUntil != nil {
+		poll := *testSpec.PollUntil
+		poll.Expected = substituteTyped(poll.Expected, typed)
+		testSpec.PollUntil = &poll
+	}
+	for i := range testSpec.Variants {
+		for j := range testSpec.Variants[i].Assertions {
+			testSpec.Variants[i].Assertions[j].Expected = substituteTyped(testSpec.Variants[i].Assertions[j].Expected, typed)
+		}
+	}
+	ApplyVariables(testSpec, vars)
+}
+
+// substituteTyped replaces strings that consist of a single known placeholder
+// with the typed value of the placeholder
+func substituteTyped(value interface{}, typed map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		trimmed := strings.TrimSpace(v)
+		if match := variablePattern.FindStringSubmatch(trimmed); match != nil && match[0] == trimmed {
+			if replacement, ok := typed[match[1]]; ok {
+				return replacement
+			}
+		}
+		return v
+	case map[string]interface{}:
+		substituted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			substituted[key] = substituteTyped(item, typed)
+		}
+		return substituted
+	case []interface{}:
+		substituted := make([]interface{}, len(v))
+		for i, item := range v {
+			substituted[i] = substituteTyped(item, typed)
+		}
+		return substituted
+	default:
+		return value
+	}
+}
@@ -0,0 +1,108 @@
+package testrunner
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeprecationNotice describes the deprecation announced by a response through
+// the Deprecation (RFC 9745) and Sunset (RFC 8594) headers
+type DeprecationNotice struct {
+	Deprecation string     `json:"deprecation,omitempty"` // raw Deprecation header value
+	Sunset      string     `json:"sunset,omitempty"`      // raw Sunset header value
+	SunsetAt    *time.Time `json:"sunset_at,omitempty"`
+	Link        string     `json:"link,omitempty"` // documentation linked with rel="deprecation" or rel="sunset"
+}
+
+// linkPattern matches a single entry of a Link header
+var linkPattern = regexp.MustCompile(`<([^>]*)>\s*;([^,]*)`)
+
+// DetectDeprecation returns the deprecation announced by the response
+// headers, or nil when the endpoint is not deprecated
+func DetectDeprecation(header http.Header) *DeprecationNotice {
+	notice := &DeprecationNotice{
+		Deprecation: header.Get("Deprecation"),
+		Sunset:      header.Get("Sunset"),
+	}
+	if notice.Deprecation == "" && notice.Sunset == "" {
+		return nil
+	}
+
+	if notice.Sunset != "" {
+		if sunsetAt, err := http.ParseTime(notice.Sunset); err == nil {
+			notice.SunsetAt = &sunsetAt
+		}
+	}
+
+	for _, link := range header.Values("Link") {
+		for _, match := range linkPattern.FindAllStringSubmatch(link, -1) {
+			params := strings.ToLower(match[2])
+			if strings.Contains(params, `rel="deprecation"`) || strings.Contains(params, "rel=deprecation") ||
+				strings.Contains(params, `rel="sunset"`) || strings.Contains(params, "rel=sunset") {
+				notice.Link = match[1]
+				break
+			}
+		}
+	}
+
+	return notice
+}
+
+// assertDeprecation checks a response against the "deprecation" assertion.
+// The "absent" matcher (default) fails on any announced deprecation, while
+// "sunset_after" only fails when the sunset is less than the expected number
+// of days away.
+func assertDeprecation(result *AssertionResult, header http.Header, assertion map[string]interface{}) {
+	notice := DetectDeprecation(header)
+
+	matcher, _ := assertion["matcher"].(string)
+	if matcher == "" {
+		matcher = "absent"
+	}
+	result.Matcher = matcher
+	result.Actual = notice
+
+	switch matcher {
+	case "absent":
+		result.Passed = notice == nil
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Endpoint is deprecated (Deprecation: %q, Sunset: %q)", notice.Deprecation, notice.Sunset)
+		}
+	case "sunset_after":
+		days, err := expectedDays(expectedValue(assertion))
+		if err != nil {
+			result.Passed = false
+			result.Message = err.Error()
+			return
+		}
+		result.Expected = days
+		if notice == nil || notice.SunsetAt == nil {
+			return
+		}
+		remaining := time.Until(*notice.SunsetAt)
+		result.Passed = remaining >= time.Duration(days)*24*time.Hour
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Endpoint sunsets on %s, less than %d days away", notice.SunsetAt.Format(time.RFC3339), days)
+		}
+	default:
+		result.Passed = false
+		result.Message = fmt.Sprintf("Unknown deprecation matcher: %s", matcher)
+	}
+}
+
+// expectedDays reads the number of days expected by a sunset_after matcher
+func expectedDays(expected interface{}) (int, error) {
+	switch v := expected.(type) {
+	case float64:
+		return int(v), nil
+	case string:
+		if days, err := strconv.Atoi(v); err == nil {
+			return days, nil
+		}
+	}
+	return 0, fmt.Errorf("sunset_after expects a number of days, got %v", expected)
+}
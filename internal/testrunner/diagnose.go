@@ -0,0 +1,189 @@
+package testrunner
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// Steps of connectivity diagnostics, in the order they run
+const (
+	DiagnosticDNS  = "dns"
+	DiagnosticTCP  = "tcp"
+	DiagnosticTLS  = "tls"
+	DiagnosticHTTP = "http"
+)
+
+// Outcomes of a diagnostic step
+const (
+	DiagnosticOK      = "ok"
+	DiagnosticFailed  = "failed"
+	DiagnosticSkipped = "skipped"
+)
+
+// diagnosticStepTimeout bounds each step of connectivity diagnostics
+const diagnosticStepTimeout = 10 * time.Second
+
+// Diagnosis is the outcome of connectivity diagnostics against a URL
+type Diagnosis struct {
+	URL     string           `json:"url"`
+	Region  string           `json:"region,omitempty"`      // region the diagnostics ran from, empty for this instance
+	Pool    string           `json:"egress_pool,omitempty"` // egress pool the diagnostics ran from
+	Address string           `json:"address"`               // host:port connected to
+	OK      bool             `json:"ok"`                    // every step succeeded
+	Steps   []DiagnosticStep `json:"steps"`
+}
+
+// DiagnosticStep is the outcome of one step of connectivity diagnostics.
+// Only the fields of the step are set.
+type DiagnosticStep struct {
+	Name          string   `json:"name"`   // dns, tcp, tls or http
+	Status        string   `json:"status"` // ok, failed or skipped
+	DurationMs    float64  `json:"duration_ms"`
+	Error         string   `json:"error,omitempty"`
+	Addresses     []string `json:"addresses,omitempty"`      // dns: resolved addresses
+	RemoteAddress string   `json:"remote_address,omitempty"` // tcp: address connected to
+	TLSVersion    string   `json:"tls_version,omitempty"`
+	CipherSuite   string   `json:"cipher_suite,omitempty"`
+	Certificate   string   `json:"certificate,omitempty"` // tls: subject of the leaf certificate
+	StatusCode    int      `json:"status_code,omitempty"` // http: status of the HEAD request
+}
+
+// Diagnose checks step by step whether a URL is reachable: DNS resolution,
+// a TCP connection, the TLS handshake for https and wss, and a HEAD request.
+// A failed step skips the steps depending on it. TLS verification follows
+// the TLS settings of the service. Connections are made from the egress
+// source; only the HEAD request goes through the egress proxy.
+func Diagnose(ctx context.Context, rawURL string, config models.TLSConfig) *Diagnosis {
+	diagnosis := &Diagnosis{URL: rawURL, Steps: []DiagnosticStep{}}
+	skip := func(names ...string) {
+		for _, name := range names {
+			diagnosis.Steps = append(diagnosis.Steps, DiagnosticStep{Name: name, Status: DiagnosticSkipped})
+		}
+	}
+	record := func(step DiagnosticStep, started time.Time, err error) bool {
+		step.DurationMs = float64(time.Since(started).Microseconds()) / 1000
+		step.Status = DiagnosticOK
+		if err != nil {
+			step.Status = DiagnosticFailed
+			step.Error = err.Error()
+		}
+		diagnosis.Steps = append(diagnosis.Steps, step)
+		return err == nil
+	}
+
+	parsed, err := neturl.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		record(DiagnosticStep{Name: DiagnosticDNS}, time.Now(), fmt.Errorf("invalid URL %q", rawURL))
+		skip(DiagnosticTCP, DiagnosticTLS, DiagnosticHTTP)
+		return diagnosis
+	}
+	secure := parsed.Scheme == "https" || parsed.Scheme == "wss"
+	port := parsed.Port()
+	if port == "" {
+		port = "80"
+		if secure {
+			port = "443"
+		}
+	}
+	host := parsed.Hostname()
+	diagnosis.Address = net.JoinHostPort(host, port)
+
+	// DNS resolution
+	started := time.Now()
+	stepCtx, cancel := context.WithTimeout(ctx, diagnosticStepTimeout)
+	addrs, err := net.DefaultResolver.LookupIPAddr(stepCtx, host)
+	cancel()
+	dns := DiagnosticStep{Name: DiagnosticDNS}
+	for _, addr := range addrs {
+		dns.Addresses = append(dns.Addresses, addr.String())
+	}
+	if !record(dns, started, err) {
+		skip(DiagnosticTCP, DiagnosticTLS, DiagnosticHTTP)
+		return diagnosis
+	}
+
+	// TCP connection to the first address accepting it
+	started = time.Now()
+	var conn net.Conn
+	dialer := egressDialer(diagnosticStepTimeout)
+	for _, addr := range dns.Addresses {
+		if conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(addr, port)); err == nil {
+			break
+		}
+	}
+	tcp := DiagnosticStep{Name: DiagnosticTCP}
+	if conn != nil {
+		tcp.RemoteAddress = conn.RemoteAddr().String()
+	}
+	if !record(tcp, started, err) {
+		skip(DiagnosticTLS, DiagnosticHTTP)
+		return diagnosis
+	}
+
+	// TLS handshake, verified like the tests of the service
+	if secure {
+		serverName := config.ServerName
+		if serverName == "" {
+			serverName = host
+		}
+		started = time.Now()
+		stepCtx, cancel = context.WithTimeout(ctx, diagnosticStepTimeout)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName, InsecureSkipVerify: config.InsecureSkipVerify})
+		err = tlsConn.HandshakeContext(stepCtx)
+		cancel()
+		step := DiagnosticStep{Name: DiagnosticTLS}
+		if err == nil {
+			state := tlsConn.ConnectionState()
+			step.TLSVersion = tls.VersionName(state.Version)
+			step.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+			if len(state.PeerCertificates) > 0 {
+				step.Certificate = state.PeerCertificates[0].Subject.String()
+			}
+		}
+		conn.Close()
+		if !record(step, started, err) {
+			skip(DiagnosticHTTP)
+			return diagnosis
+		}
+	} else {
+		conn.Close()
+		skip(DiagnosticTLS)
+	}
+
+	// HEAD request on a new connection, so its duration covers a whole request
+	httpURL := *parsed
+	switch parsed.Scheme {
+	case "wss":
+		httpURL.Scheme = "https"
+	case "ws":
+		httpURL.Scheme = "http"
+	}
+	started = time.Now()
+	step := DiagnosticStep{Name: DiagnosticHTTP}
+	stepCtx, cancel = context.WithTimeout(ctx, diagnosticStepTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(stepCtx, http.MethodHead, httpURL.String(), nil)
+	if err == nil {
+		transport := newPooledTransport(config)
+		transport.DisableKeepAlives = true
+		client := &http.Client{
+			Transport: transport,
+			// Redirects are reported, not followed
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		var resp *http.Response
+		if resp, err = client.Do(req); err == nil {
+			step.StatusCode = resp.StatusCode
+			resp.Body.Close()
+		}
+	}
+	diagnosis.OK = record(step, started, err)
+	return diagnosis
+}
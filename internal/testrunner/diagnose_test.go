@@ -0,0 +1,65 @@
+package testrunner
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-test-framework/internal/models"
+)
+
+func TestDiagnose(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("method = %s, want HEAD", r.Method)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := "http://" + listener.Addr().String()
+	listener.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		config   models.TLSConfig
+		statuses []string // of dns, tcp, tls and http
+		ok       bool
+	}{
+		{name: "http", url: plain.URL + "/health", statuses: []string{DiagnosticOK, DiagnosticOK, DiagnosticSkipped, DiagnosticOK}, ok: true},
+		{name: "https untrusted", url: secure.URL, statuses: []string{DiagnosticOK, DiagnosticOK, DiagnosticFailed, DiagnosticSkipped}},
+		{name: "https insecure", url: secure.URL, config: models.TLSConfig{InsecureSkipVerify: true}, statuses: []string{DiagnosticOK, DiagnosticOK, DiagnosticOK, DiagnosticOK}, ok: true},
+		{name: "connection refused", url: closed, statuses: []string{DiagnosticOK, DiagnosticFailed, DiagnosticSkipped, DiagnosticSkipped}},
+		{name: "invalid url", url: "://", statuses: []string{DiagnosticFailed, DiagnosticSkipped, DiagnosticSkipped, DiagnosticSkipped}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnosis := Diagnose(context.Background(), tt.url, tt.config)
+			if diagnosis.OK != tt.ok {
+				t.Errorf("ok = %v, want %v", diagnosis.OK, tt.ok)
+			}
+			if len(diagnosis.Steps) != len(tt.statuses) {
+				t.Fatalf("steps = %+v", diagnosis.Steps)
+			}
+			for i, step := range diagnosis.Steps {
+				if step.Status != tt.statuses[i] {
+					t.Errorf("step %s = %s (%s), want %s", step.Name, step.Status, step.Error, tt.statuses[i])
+				}
+			}
+			if tt.ok && diagnosis.Steps[3].StatusCode != http.StatusNoContent {
+				t.Errorf("status code = %d", diagnosis.Steps[3].StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package testrunner
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"sync"
+	"time"
+)
+
+// Egress routes the outbound connections of the tests executed by this
+// process, e.g. so the workers of a pool reach services from an address on
+// their IP allow-lists, or deliberately from one that is not
+type Egress struct {
+	// Source is the local IP address, or the network interface whose first
+	// address, connections are made from
+	Source string
+	// ProxyURL is an http, https or socks5 proxy requests are sent through,
+	// instead of the proxy of the environment
+	ProxyURL string
+}
+
+var (
+	egressMu    sync.RWMutex
+	egressAddr  *net.TCPAddr
+	egressProxy *neturl.URL
+)
+
+// SetEgress routes the outbound connections of HTTP, GraphQL, SOAP,
+// WebSocket and gRPC tests. Pooled clients are closed, so later tests use
+// the new route. An empty Egress restores the defaults.
+func SetEgress(egress Egress) error {
+	var addr *net.TCPAddr
+	if egress.Source != "" {
+		ip, err := egressSourceIP(egress.Source)
+		if err != nil {
+			return err
+		}
+		addr = &net.TCPAddr{IP: ip}
+	}
+	var proxy *neturl.URL
+	if egress.ProxyURL != "" {
+		parsed, err := neturl.Parse(egress.ProxyURL)
+		if err != nil || parsed.Host == "" {
+			return fmt.Errorf("invalid egress proxy %q", egress.ProxyURL)
+		}
+		switch parsed.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("unsupported egress proxy scheme %q, expected http, https or socks5", parsed.Scheme)
+		}
+		proxy = parsed
+	}
+
+	egressMu.Lock()
+	egressAddr, egressProxy = addr, proxy
+	egressMu.Unlock()
+	DefaultClientPool.closeAll()
+	grpcTLSTransport.CloseIdleConnections()
+	grpcPlaintextTransport.CloseIdleConnections()
+	return nil
+}
+
+// egressSourceIP resolves the source of an egress, an IP address or the
+// name of a network interface
+func egressSourceIP(source string) (net.IP, error) {
+	if ip := net.ParseIP(source); ip != nil {
+		return ip, nil
+	}
+	iface, err := net.InterfaceByName(source)
+	if err != nil {
+		return nil, fmt.Errorf("egress source %q is neither an IP address nor a network interface", source)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read addresses of interface %s: %v", source, err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP, nil
+		}
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			return ipNet.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("interface %s has no address", source)
+}
+
+// egressDialer returns a dialer connecting from the egress source address
+func egressDialer(timeout time.Duration) *net.Dialer {
+	egressMu.RLock()
+	defer egressMu.RUnlock()
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	if egressAddr != nil {
+		dialer.LocalAddr = egressAddr
+	}
+	return dialer
+}
+
+// egressProxyFunc returns the proxy of HTTP requests: the egress proxy, or
+// the proxy of the environment
+func egressProxyFunc() func(*http.Request) (*neturl.URL, error) {
+	egressMu.RLock()
+	defer egressMu.RUnlock()
+	if egressProxy != nil {
+		return http.ProxyURL(egressProxy)
+	}
+	return http.ProxyFromEnvironment
+}
+
+// dialEgress opens a connection from the egress source address, for
+// transports that do not dial through a proxy
+func dialEgress(ctx context.Context, network, addr string) (net.Conn, error) {
+	return egressDialer(30*time.Second).DialContext(ctx, network, addr)
+}
+
+// dialEgressTLS opens a TLS connection from the egress source address
+func dialEgressTLS(ctx context.Context, network, addr string, config *tls.Config) (net.Conn, error) {
+	conn, err := dialEgress(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
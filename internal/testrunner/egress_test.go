@@ -0,0 +1,62 @@
+package testrunner
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"api-test-framework/internal/models"
+)
+
+func TestSetEgress(t *testing.T) {
+	t.Cleanup(func() { SetEgress(Egress{}) })
+
+	invalid := []Egress{
+		{Source: "no-such-interface0"},
+		{ProxyURL: "ftp://proxy.internal:21"},
+		{ProxyURL: "proxy.internal"},
+	}
+	for _, egress := range invalid {
+		if err := SetEgress(egress); err == nil {
+			t.Errorf("SetEgress(%+v) succeeded, want an error", egress)
+		}
+	}
+
+	// Requests leave from the source address
+	var remoteHost string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteHost, _, _ = net.SplitHostPort(r.RemoteAddr)
+	}))
+	defer server.Close()
+	if err := SetEgress(Egress{Source: "127.0.0.1"}); err != nil {
+		t.Fatalf("SetEgress failed: %v", err)
+	}
+	resp, err := DefaultClientPool.Client(server.URL, models.TLSConfig{}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if remoteHost != "127.0.0.1" {
+		t.Errorf("remote host = %q, want 127.0.0.1", remoteHost)
+	}
+
+	// Requests are sent through the proxy, replacing pooled clients
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer proxy.Close()
+	if err := SetEgress(Egress{ProxyURL: proxy.URL}); err != nil {
+		t.Fatalf("SetEgress failed: %v", err)
+	}
+	resp, err = DefaultClientPool.Client("http://allow-listed.internal", models.TLSConfig{}).Get("http://allow-listed.internal/orders")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden || proxied != "http://allow-listed.internal/orders" {
+		t.Errorf("status = %d, proxied = %q", resp.StatusCode, proxied)
+	}
+}
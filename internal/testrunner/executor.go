@@ -0,0 +1,109 @@
+package testrunner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"api-test-framework/internal/models"
+)
+
+// Protocols a test spec can select; an empty protocol means HTTP
+const (
+	ProtocolHTTP      = "http"
+	ProtocolGraphQL   = "graphql"
+	ProtocolGRPC      = "grpc"
+	ProtocolWebSocket = "websocket"
+	ProtocolSOAP      = "soap"
+)
+
+// ErrUnsupportedProtocol is returned when no executor is registered for a protocol
+var ErrUnsupportedProtocol = errors.New("unsupported protocol")
+
+// Executor executes test specs of one protocol and evaluates their assertions
+type Executor interface {
+	ExecuteTest(ctx context.Context, testSpec *models.TestSpec) *TestResult
+}
+
+// ExecutorConfig carries the service and run settings an executor is created with
+type ExecutorConfig struct {
+	BaseURL       string
+	ServiceID     string
+	AuthConfig    models.AuthConfig
+	TokenProvider *OAuth2TokenProvider
+	Fixtures      FixtureLoader
+	Versioning    models.APIVersioning
+	APIVersion    string
+	GRPC          models.GRPCConfig
+	TLS           models.TLSConfig
+	Logger        *slog.Logger // logger of the test, nil for the default logger
+}
+
+// ExecutorFactory creates an executor for a single test execution
+type ExecutorFactory func(config ExecutorConfig) (Executor, error)
+
+var (
+	executorsMu sync.RWMutex
+	executors   = map[string]ExecutorFactory{
+		ProtocolHTTP:      newHTTPExecutor,
+		ProtocolGraphQL:   newGraphQLExecutor,
+		ProtocolGRPC:      newGRPCExecutor,
+		ProtocolWebSocket: newWebSocketExecutor,
+		ProtocolSOAP:      newSOAPExecutor,
+	}
+)
+
+// RegisterExecutor makes an executor available for a protocol, replacing any
+// executor registered before
+func RegisterExecutor(protocol string, factory ExecutorFactory) {
+	executorsMu.Lock()
+	defer executorsMu.Unlock()
+	executors[strings.ToLower(protocol)] = factory
+}
+
+// NewExecutor creates the executor for a protocol
+func NewExecutor(protocol string, config ExecutorConfig) (Executor, error) {
+	protocol = strings.ToLower(protocol)
+	if protocol == "" {
+		protocol = ProtocolHTTP
+	}
+
+	executorsMu.RLock()
+	factory, ok := executors[protocol]
+	executorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q, supported protocols: %s", ErrUnsupportedProtocol, protocol, strings.Join(Protocols(), ", "))
+	}
+	return factory(config)
+}
+
+// Protocols lists the protocols with a registered executor
+func Protocols() []string {
+	executorsMu.RLock()
+	defer executorsMu.RUnlock()
+
+	protocols := make([]string, 0, len(executors))
+	for protocol := range executors {
+		protocols = append(protocols, protocol)
+	}
+	sort.Strings(protocols)
+	return protocols
+}
+
+// newHTTPExecutor creates the httpexpect based HTTP executor
+func newHTTPExecutor(config ExecutorConfig) (Executor, error) {
+	return configuredHTTPExecutor(config), nil
+}
+
+// configuredHTTPExecutor creates an HTTP executor with the service settings of config
+func configuredHTTPExecutor(config ExecutorConfig) *HTTPExpectExecutor {
+	return newHTTPExpectExecutor(config.BaseURL, DefaultClientPool.Client(config.BaseURL, config.TLS)).
+		WithAuth(config.ServiceID, config.AuthConfig, config.TokenProvider).
+		WithFixtures(config.Fixtures).
+		WithAPIVersion(config.Versioning, config.APIVersion).
+		WithLogger(config.Logger)
+}
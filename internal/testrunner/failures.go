@@ -0,0 +1,147 @@
+package testrunner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/gavv/httpexpect/v2"
+)
+
+// Failure types classifying why a test failed
+const (
+	FailureConnection = "connection_error"  // the request could not be sent or no response arrived
+	FailureDNS        = "dns_error"         // the host name of the service could not be resolved
+	FailureTimeout    = "timeout"           // the request or the test exceeded its deadline
+	FailureTLS        = "tls_error"         // handshake or certificate verification failed
+	FailureServer     = "server_error"      // the service answered with a 5xx status
+	FailureClient     = "client_error"      // the service answered with a 4xx status
+	FailureAssertion  = "assertion_failure" // the response did not satisfy an assertion
+	FailureGraphQL    = "graphql_error"     // a GraphQL response reported errors or carried no data
+	FailureSOAPFault  = "soap_fault"        // a SOAP response carried a Fault the test did not expect
+	FailureSpec       = "spec_error"        // the test spec, its auth or fixtures could not be used
+	FailureInternal   = "internal_error"    // the executor failed unexpectedly
+)
+
+// requestFailures records the failures httpexpect reports while sending a
+// request, instead of panicking like the default assertion reporter
+type requestFailures struct {
+	err error
+}
+
+// Success implements httpexpect.AssertionHandler interface
+func (h *requestFailures) Success(*httpexpect.AssertionContext) {}
+
+// Failure implements httpexpect.AssertionHandler interface
+func (h *requestFailures) Failure(_ *httpexpect.AssertionContext, failure *httpexpect.AssertionFailure) {
+	if h.err != nil || len(failure.Errors) == 0 {
+		return
+	}
+	// httpexpect reports a description followed by the underlying error
+	h.err = failure.Errors[0]
+	if len(failure.Errors) > 1 {
+		h.err = fmt.Errorf("%v: %w", failure.Errors[0], failure.Errors[1])
+	}
+}
+
+// Failure categories group failure types so that policies, such as retries,
+// can refer to several of them at once
+const (
+	CategoryNetwork = "network" // connection, DNS and timeout failures
+	CategoryTimeout = "timeout"
+	Category5xx     = "5xx"
+	Category4xx     = "4xx"
+)
+
+var failureCategories = map[string][]string{
+	CategoryNetwork: {FailureConnection, FailureDNS, FailureTimeout},
+	CategoryTimeout: {FailureTimeout},
+	Category5xx:     {FailureServer},
+	Category4xx:     {FailureClient},
+}
+
+// FailureInCategory reports whether a failure type belongs to a category.
+// A category may also name a single failure type, e.g. "dns_error".
+func FailureInCategory(failureType, category string) bool {
+	if failureType == "" {
+		return false
+	}
+	if failureType == category {
+		return true
+	}
+	for _, member := range failureCategories[category] {
+		if member == failureType {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassifyError returns the failure type of an error that prevented a response
+func ClassifyError(err error) string {
+	var (
+		netErr net.Error
+		dnsErr *net.DNSError
+	)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return FailureTimeout
+	case errors.As(err, &dnsErr) && !dnsErr.IsTimeout:
+		return FailureDNS
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return FailureTimeout
+	case isTLSError(err):
+		return FailureTLS
+	default:
+		return FailureConnection
+	}
+}
+
+// NetworkErrorDetail returns the innermost network error of err, e.g.
+// "lookup api.internal: no such host" or "dial tcp 10.0.0.1:443: connect:
+// connection refused", without the wrapping added by the HTTP client
+func NetworkErrorDetail(err error) string {
+	var (
+		dnsErr *net.DNSError
+		opErr  *net.OpError
+	)
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &dnsErr):
+		return dnsErr.Error()
+	case errors.As(err, &opErr):
+		return opErr.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return context.DeadlineExceeded.Error()
+	}
+	for next := errors.Unwrap(err); next != nil; next = errors.Unwrap(err) {
+		err = next
+	}
+	return err.Error()
+}
+
+// ClassifyStatus returns the failure type of an error status code
+func ClassifyStatus(statusCode int) string {
+	if statusCode >= 500 {
+		return FailureServer
+	}
+	return FailureClient
+}
+
+// isTLSError reports whether err comes from the TLS handshake or certificate verification
+func isTLSError(err error) bool {
+	var (
+		verificationErr *tls.CertificateVerificationError
+		recordErr       tls.RecordHeaderError
+		alertErr        tls.AlertError
+		authorityErr    x509.UnknownAuthorityError
+		hostnameErr     x509.HostnameError
+		invalidErr      x509.CertificateInvalidError
+	)
+	return errors.As(err, &verificationErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr)
+}
@@ -0,0 +1,201 @@
+package testrunner
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// generatorPattern matches {{$name arg ...}} placeholders, which generate a
+// value instead of looking up a variable
+var generatorPattern = regexp.MustCompile(`\{\{\s*\$([a-z_]+)((?:\s+[^\s{}]+)*)\s*\}\}`)
+
+// Limits of generated payloads, so a typo cannot exhaust the memory of a worker
+const (
+	maxGeneratedBytes = 16 << 20
+	maxGeneratedItems = 100000
+	maxGeneratedDepth = 1000
+)
+
+// generatedFill is the text generated strings repeat by default
+const generatedFill = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// generator produces the value of a generator placeholder from its arguments
+type generator func(args []string) (interface{}, error)
+
+// generators are the functions available as {{$name args}}. Their output is
+// deterministic, so assertions can compare an echoed payload.
+var generators = map[string]generator{
+	"string": generateString,
+	"array":  generateArray,
+	"object": generateObject,
+	"nested": generateNested,
+	"probe":  generateProbe,
+	"probes": generateProbes,
+}
+
+// substituteGenerators replaces the generator placeholders of a string with
+// their output as text; values other than strings are rendered as JSON.
+// Unknown generators and invalid arguments leave the placeholder untouched.
+func substituteGenerators(s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return generatorPattern.ReplaceAllStringFunc(s, func(match string) string {
+		value, ok := generate(match)
+		if !ok {
+			return match
+		}
+		if text, isText := value.(string); isText {
+			return text
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return match
+		}
+		return string(encoded)
+	})
+}
+
+// generatedValue returns the output of a string consisting of a single
+// generator placeholder, typed, so "{{$array 3}}" becomes a JSON array
+func generatedValue(s string) (interface{}, bool) {
+	trimmed := strings.TrimSpace(s)
+	if !strings.HasPrefix(trimmed, "{{") {
+		return nil, false
+	}
+	if loc := generatorPattern.FindStringIndex(trimmed); loc == nil || loc[0] != 0 || loc[1] != len(trimmed) {
+		return nil, false
+	}
+	return generate(trimmed)
+}
+
+// generate runs the generator of a placeholder
+func generate(placeholder string) (interface{}, bool) {
+	match := generatorPattern.FindStringSubmatch(placeholder)
+	if match == nil {
+		return nil, false
+	}
+	fn, ok := generators[match[1]]
+	if !ok {
+		return nil, false
+	}
+	value, err := fn(strings.Fields(match[2]))
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// generateString returns a string of a size such as 512, 10KB or 1MB,
+// repeating the optional fill text: {{$string 10KB}} or {{$string 1MB x}}
+func generateString(args []string) (interface{}, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("string takes a size and an optional fill")
+	}
+	size, err := parseSize(args[0])
+	if err != nil {
+		return nil, err
+	}
+	fill := generatedFill
+	if len(args) == 2 {
+		fill = args[1]
+	}
+	text := strings.Repeat(fill, size/len(fill)+1)[:size]
+	// Don't cut a multi-byte fill in the middle of a character
+	for !utf8.ValidString(text) {
+		text = text[:len(text)-1]
+	}
+	return text, nil
+}
+
+// generateArray returns an array of n items, their index by default or
+// copies of a value: {{$array 1000}} or {{$array 50 item}}
+func generateArray(args []string) (interface{}, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("array takes a length and an optional item")
+	}
+	n, err := parseCount(args[0], maxGeneratedItems)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		if len(args) == 2 {
+			items[i] = args[1]
+		} else {
+			items[i] = i
+		}
+	}
+	return items, nil
+}
+
+// generateObject returns an object with n fields, key0 to key<n-1> holding
+// their index: {{$object 200}}
+func generateObject(args []string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("object takes a number of fields")
+	}
+	n, err := parseCount(args[0], maxGeneratedItems)
+	if err != nil {
+		return nil, err
+	}
+	object := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		object["key"+strconv.Itoa(i)] = i
+	}
+	return object, nil
+}
+
+// generateNested returns objects nested depth levels deep under the key
+// "nested", or arrays with "array": {{$nested 64}} or {{$nested 64 array}}
+func generateNested(args []string) (interface{}, error) {
+	if len(args) < 1 || len(args) > 2 || len(args) == 2 && args[1] != "array" && args[1] != "object" {
+		return nil, fmt.Errorf("nested takes a depth and optionally array or object")
+	}
+	depth, err := parseCount(args[0], maxGeneratedDepth)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{} = depth
+	for i := depth; i > 0; i-- {
+		if len(args) == 2 && args[1] == "array" {
+			value = []interface{}{value}
+		} else {
+			value = map[string]interface{}{"nested": value}
+		}
+	}
+	return value, nil
+}
+
+// parseSize parses a byte size: a number with an optional B, KB or MB suffix,
+// in multiples of 1024
+func parseSize(text string) (int, error) {
+	upper := strings.ToUpper(text)
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(upper, "MB"):
+		multiplier, upper = 1<<20, strings.TrimSuffix(upper, "MB")
+	case strings.HasSuffix(upper, "KB"):
+		multiplier, upper = 1<<10, strings.TrimSuffix(upper, "KB")
+	case strings.HasSuffix(upper, "B"):
+		upper = strings.TrimSuffix(upper, "B")
+	}
+	n, err := strconv.Atoi(upper)
+	if err != nil || n < 0 || n > maxGeneratedBytes/multiplier {
+		return 0, fmt.Errorf("invalid size %q, at most %d bytes", text, maxGeneratedBytes)
+	}
+	return n * multiplier, nil
+}
+
+// parseCount parses a count up to limit
+func parseCount(text string, limit int) (int, error) {
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 0 || n > limit {
+		return 0, fmt.Errorf("invalid count %q, at most %d", text, limit)
+	}
+	return n, nil
+}
@@ -0,0 +1,146 @@
+package testrunner
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"api-test-framework/internal/models"
+)
+
+func TestGenerators(t *testing.T) {
+	testSpec := &models.TestSpec{
+		Request: models.RequestSpec{
+			URL:     "/search?q={{$string 16 x}}",
+			Headers: map[string]string{"X-Padding": "{{$string 1KB}}"},
+			Body: map[string]interface{}{
+				"items":  "{{$array 3}}",
+				"tags":   "{{$array 2 tag}}",
+				"fields": "{{$object 2}}",
+				"tree":   "{{$nested 2}}",
+				"list":   "{{$nested 2 array}}",
+				"note":   "size {{$string 4}} for {{name}}",
+				"emoji":  "{{$string 7 é}}",
+				"typo":   "{{$strin 10}}",
+				"huge":   "{{$string 1GB}}",
+			},
+		},
+	}
+	ApplyVariables(testSpec, map[string]string{"name": "Ada"})
+
+	if testSpec.Request.URL != "/search?q="+strings.Repeat("x", 16) {
+		t.Errorf("URL = %q", testSpec.Request.URL)
+	}
+	if padding := testSpec.Request.Headers["X-Padding"]; len(padding) != 1024 || !strings.HasPrefix(padding, generatedFill) {
+		t.Errorf("X-Padding has %d bytes", len(padding))
+	}
+
+	body := testSpec.Request.Body.(map[string]interface{})
+	if items, ok := body["items"].([]interface{}); !ok || len(items) != 3 || items[2] != 2 {
+		t.Errorf("items = %#v", body["items"])
+	}
+	if tags, ok := body["tags"].([]interface{}); !ok || len(tags) != 2 || tags[0] != "tag" {
+		t.Errorf("tags = %#v", body["tags"])
+	}
+	if fields, ok := body["fields"].(map[string]interface{}); !ok || len(fields) != 2 || fields["key1"] != 1 {
+		t.Errorf("fields = %#v", body["fields"])
+	}
+	tree, _ := body["tree"].(map[string]interface{})
+	if inner, _ := tree["nested"].(map[string]interface{}); inner["nested"] != 2 {
+		t.Errorf("tree = %#v", body["tree"])
+	}
+	if list, ok := body["list"].([]interface{}); !ok || len(list) != 1 {
+		t.Errorf("list = %#v", body["list"])
+	}
+	if body["note"] != "size abcd for Ada" {
+		t.Errorf("note = %q", body["note"])
+	}
+	if emoji := body["emoji"].(string); !utf8.ValidString(emoji) || len(emoji) != 6 {
+		t.Errorf("emoji = %q", emoji)
+	}
+	if body["typo"] != "{{$strin 10}}" || body["huge"] != "{{$string 1GB}}" {
+		t.Errorf("invalid generators should be left untouched, got %q and %q", body["typo"], body["huge"])
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for text, want := range map[string]int{"0": 0, "512": 512, "100B": 100, "10KB": 10240, "2mb": 2 << 20} {
+		if got, err := parseSize(text); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v, want %d", text, got, err, want)
+		}
+	}
+	for _, text := range []string{"", "-1", "KB", "17MB", "1.5KB"} {
+		if _, err := parseSize(text); err == nil {
+			t.Errorf("parseSize(%q) expected an error", text)
+		}
+	}
+}
+
+func TestProbeGenerators(t *testing.T) {
+	testSpec := &models.TestSpec{
+		Request: models.RequestSpec{
+			URL: "/search?q={{$probe sql 2}}",
+			Body: map[string]interface{}{
+				"name":     "{{$probe xss {{row}}}}",
+				"comment":  "hello {{$probe emoji}}",
+				"filter":   "{{$probe nosql}}",
+				"all":      "{{$probes rtl}}",
+				"unknown":  "{{$probe shell}}",
+				"overflow": "{{$probe xss 99}}",
+			},
+		},
+	}
+	ApplyVariables(testSpec, map[string]string{"row": "1"})
+
+	if testSpec.Request.URL != "/search?q='; DROP TABLE users; --" {
+		t.Errorf("URL = %q", testSpec.Request.URL)
+	}
+	body := testSpec.Request.Body.(map[string]interface{})
+	if body["name"] != probeLibrary["xss"][1] {
+		t.Errorf("name = %q", body["name"])
+	}
+	if body["comment"] != "hello 😀" {
+		t.Errorf("comment = %q", body["comment"])
+	}
+	if filter, ok := body["filter"].(map[string]interface{}); !ok || len(filter) != 1 {
+		t.Errorf("filter = %#v", body["filter"])
+	}
+	if all, ok := body["all"].([]interface{}); !ok || len(all) != len(probeLibrary["rtl"]) {
+		t.Errorf("all = %#v", body["all"])
+	}
+	if body["unknown"] != "{{$probe shell}}" || body["overflow"] != "{{$probe xss 99}}" {
+		t.Errorf("invalid probes should be left untouched, got %q and %q", body["unknown"], body["overflow"])
+	}
+}
+
+func TestAssertProbeSafe(t *testing.T) {
+	probe := "<script>alert(1)</script>"
+	request := map[string]interface{}{
+		"url":  "/users",
+		"body": map[string]interface{}{"name": "Ada " + probe},
+	}
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+		request    map[string]interface{}
+		passed     bool
+	}{
+		{"escaped as JSON", 201, `{"name":"Ada \u003cscript\u003ealert(1)\u003c/script\u003e"}`, request, true},
+		{"escaped as HTML", 201, `<p>Ada &lt;script&gt;alert(1)&lt;/script&gt;</p>`, request, true},
+		{"rejected", 400, `{"error":"invalid name"}`, request, true},
+		{"server error", 500, `{}`, request, false},
+		{"reflected", 201, `<p>Ada ` + probe + `</p>`, request, false},
+		{"reflected from the query", 200, probe, map[string]interface{}{"url": "/search?q=%3Cscript%3Ealert(1)%3C%2Fscript%3E"}, false},
+		{"not sent", 200, probe, map[string]interface{}{"url": "/users"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := AssertionResult{Type: "probe_safe", Passed: true}
+			assertProbeSafe(&result, tt.statusCode, tt.body, tt.request)
+			if result.Passed != tt.passed {
+				t.Errorf("passed = %v, want %v (%s)", result.Passed, tt.passed, result.Message)
+			}
+		})
+	}
+}
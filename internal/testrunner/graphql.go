@@ -0,0 +1,237 @@
+package testrunner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// graphQLAccept is sent unless the test sets its own Accept header
+const graphQLAccept = "application/graphql-response+json, application/json"
+
+// graphQLIndex matches the [0] and [*] segments of a GraphQL assertion path
+var graphQLIndex = regexp.MustCompile(`\[(\d+|\*)\]`)
+
+// GraphQLExecutor executes GraphQL operations over HTTP. Besides the status
+// code, a response fails when it reports errors the test does not assert on,
+// or carries neither data nor errors.
+type GraphQLExecutor struct {
+	http *HTTPExpectExecutor
+}
+
+// newGraphQLExecutor creates the GraphQL executor
+func newGraphQLExecutor(config ExecutorConfig) (Executor, error) {
+	return &GraphQLExecutor{
+		http: configuredHTTPExecutor(config).WithResponseCheck(checkGraphQLResponse),
+	}, nil
+}
+
+// ExecuteTest sends the GraphQL operation of the test spec and runs its assertions
+func (e *GraphQLExecutor) ExecuteTest(ctx context.Context, testSpec *models.TestSpec) *TestResult {
+	if testSpec.Request.GraphQL == nil || strings.TrimSpace(testSpec.Request.GraphQL.Query) == "" {
+		return &TestResult{
+			TestName:     testSpec.Name,
+			StartTime:    time.Now(),
+			Status:       "FAILED",
+			ErrorMessage: "GraphQL tests require request.graphql.query",
+			FailureType:  FailureSpec,
+		}
+	}
+	return e.http.ExecuteTest(ctx, graphQLHTTPSpec(testSpec))
+}
+
+// graphQLHTTPSpec returns the HTTP request carrying a GraphQL operation: a
+// POST with the operation envelope as JSON body, and assertion paths on
+// data and errors resolved against the response body
+func graphQLHTTPSpec(testSpec *models.TestSpec) *models.TestSpec {
+	spec := *testSpec
+	operation := testSpec.Request.GraphQL
+
+	envelope := map[string]interface{}{"query": operation.Query}
+	if operation.OperationName != "" {
+		envelope["operationName"] = operation.OperationName
+	}
+	if operation.Variables != nil {
+		envelope["variables"] = operation.Variables
+	}
+	spec.Request.Method = http.MethodPost
+	spec.Request.Body = envelope
+	spec.Request.Multipart = nil
+
+	spec.Request.Headers = mergeHeaders(nil, testSpec.Request.Headers)
+	if !hasHeader(spec.Request.Headers, "Accept") {
+		spec.Request.Headers["Accept"] = graphQLAccept
+	}
+
+	spec.Assertions = graphQLAssertions(testSpec.Assertions)
+	spec.Variants = make([]models.VariantSpec, len(testSpec.Variants))
+	for i, variant := range testSpec.Variants {
+		variant.Assertions = graphQLAssertions(variant.Assertions)
+		spec.Variants[i] = variant
+	}
+	return &spec
+}
+
+// graphQLAssertions rewrites the paths of assertions on data and errors
+func graphQLAssertions(assertions []models.AssertionSpec) []models.AssertionSpec {
+	if assertions == nil {
+		return nil
+	}
+	rewritten := make([]models.AssertionSpec, len(assertions))
+	for i, assertion := range assertions {
+		assertion.Path = graphQLPath(assertion.Type, assertion.Path)
+		rewritten[i] = assertion
+	}
+	return rewritten
+}
+
+// graphQLPath converts a path such as data.user.name, errors[0].message or
+// errors[*].extensions.code to the gjson path the assertion type resolves.
+// equals and exists assertions resolve paths against the whole response, so
+// the path is prefixed with body. Other paths are returned unchanged.
+func graphQLPath(assertionType, path string) string {
+	root := path
+	if idx := strings.IndexAny(root, ".["); idx >= 0 {
+		root = root[:idx]
+	}
+	if root != "data" && root != "errors" {
+		return path
+	}
+
+	path = graphQLIndex.ReplaceAllStringFunc(path, func(segment string) string {
+		index := segment[1 : len(segment)-1]
+		if index == "*" {
+			index = "#"
+		}
+		return "." + index
+	})
+	if assertionType == "equals" || assertionType == "exists" {
+		path = "body." + path
+	}
+	return path
+}
+
+// checkGraphQLResponse applies the GraphQL error checks to a response. Error
+// statuses are reported with the GraphQL errors of the body. Errors reported
+// with a successful status fail the test unless it asserts on them, and take
+// precedence over the assertions they made fail.
+func checkGraphQLResponse(testSpec *models.TestSpec, result *TestResult, statusCode int, body interface{}) {
+	response, ok := body.(map[string]interface{})
+	if !ok {
+		if result.Status != "FAILED" {
+			result.Status = "FAILED"
+			result.ErrorMessage = "Response is not a GraphQL response: expected a JSON object"
+			result.FailureType = FailureGraphQL
+		}
+		return
+	}
+
+	messages := graphQLErrorMessages(response)
+	if statusCode >= 400 {
+		if len(messages) > 0 {
+			result.ErrorMessage = fmt.Sprintf("%s: GraphQL errors: %s", result.ErrorMessage, strings.Join(messages, "; "))
+		}
+		return
+	}
+
+	switch {
+	case result.Status == "FAILED" && result.FailureType != FailureAssertion:
+		return
+	case len(messages) > 0 && !assertsGraphQLErrors(testSpec):
+		result.Status = "FAILED"
+		result.ErrorMessage = "GraphQL errors: " + strings.Join(messages, "; ")
+		result.FailureType = FailureGraphQL
+	case result.Status != "FAILED" && len(messages) == 0 && response["data"] == nil:
+		result.Status = "FAILED"
+		result.ErrorMessage = "GraphQL response has neither data nor errors"
+		result.FailureType = FailureGraphQL
+	}
+}
+
+// assertsGraphQLErrors reports whether a test expects errors, by asserting on
+// them with a graphql_errors assertion or a path on errors
+func assertsGraphQLErrors(testSpec *models.TestSpec) bool {
+	for _, assertion := range testSpec.Assertions {
+		if assertion.Type == "graphql_errors" || strings.HasPrefix(assertion.Path, "errors") || strings.HasPrefix(assertion.Path, "body.errors") {
+			return true
+		}
+	}
+	return false
+}
+
+// graphQLErrorMessages returns the messages of the errors of a GraphQL response
+func graphQLErrorMessages(response map[string]interface{}) []string {
+	errs, _ := response["errors"].([]interface{})
+	messages := make([]string, 0, len(errs))
+	for _, item := range errs {
+		message := fmt.Sprint(item)
+		if graphQLError, ok := item.(map[string]interface{}); ok {
+			if text, ok := graphQLError["message"].(string); ok {
+				message = text
+			}
+			if code := graphQLErrorCode(graphQLError); code != "" {
+				message = fmt.Sprintf("%s (%s)", message, code)
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages
+}
+
+// graphQLErrorCode returns the extensions.code of a GraphQL error
+func graphQLErrorCode(graphQLError map[string]interface{}) string {
+	extensions, _ := graphQLError["extensions"].(map[string]interface{})
+	code, _ := extensions["code"].(string)
+	return code
+}
+
+// assertGraphQLErrors checks the errors of a GraphQL response. A numeric
+// expectation is the number of errors, a string must match the message or
+// extensions.code of one of them, and no expectation requires any error.
+func assertGraphQLErrors(result *AssertionResult, body interface{}, assertion map[string]interface{}) {
+	response, _ := body.(map[string]interface{})
+	errs, _ := response["errors"].([]interface{})
+	result.Actual = graphQLErrorMessages(response)
+	result.Expected = expectedValue(assertion)
+
+	switch expected := result.Expected.(type) {
+	case float64:
+		result.Passed = len(errs) == int(expected)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected %d GraphQL errors, got %d", int(expected), len(errs))
+		}
+	case string:
+		result.Passed = false
+		for _, item := range errs {
+			graphQLError, _ := item.(map[string]interface{})
+			message, _ := graphQLError["message"].(string)
+			if strings.Contains(message, expected) || graphQLErrorCode(graphQLError) == expected {
+				result.Passed = true
+				break
+			}
+		}
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected a GraphQL error matching '%s'", expected)
+		}
+	default:
+		result.Passed = len(errs) > 0
+		if !result.Passed {
+			result.Message = "Expected GraphQL errors, got none"
+		}
+	}
+}
+
+// hasHeader reports whether headers contain a header, ignoring case
+func hasHeader(headers map[string]string, name string) bool {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return true
+		}
+	}
+	return false
+}
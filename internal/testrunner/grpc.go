@@ -0,0 +1,521 @@
+package testrunner
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"github.com/tidwall/gjson"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// grpcCodes are the names of the gRPC status codes, indexed by code
+var grpcCodes = []string{
+	"OK", "CANCELLED", "UNKNOWN", "INVALID_ARGUMENT", "DEADLINE_EXCEEDED", "NOT_FOUND",
+	"ALREADY_EXISTS", "PERMISSION_DENIED", "RESOURCE_EXHAUSTED", "FAILED_PRECONDITION",
+	"ABORTED", "OUT_OF_RANGE", "UNIMPLEMENTED", "INTERNAL", "UNAVAILABLE", "DATA_LOSS",
+	"UNAUTHENTICATED",
+}
+
+// grpcCodeName returns the name of a gRPC status code
+func grpcCodeName(code int) string {
+	if code >= 0 && code < len(grpcCodes) {
+		return grpcCodes[code]
+	}
+	return strconv.Itoa(code)
+}
+
+// Shared HTTP/2 transports, so connections to a service are reused across tests
+var (
+	grpcTLSTransport       = &http2.Transport{DialTLSContext: dialEgressTLS}
+	grpcPlaintextTransport = &http2.Transport{
+		// Plaintext gRPC is HTTP/2 without TLS (h2c)
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dialEgress(ctx, network, addr)
+		},
+	}
+)
+
+// GRPCExecutor calls unary gRPC methods. Methods are resolved through server
+// reflection or a descriptor set uploaded as fixture; request messages are
+// given as JSON in the request body and responses are converted to JSON for
+// the assertions.
+type GRPCExecutor struct {
+	origin        string
+	client        *http.Client
+	serviceID     string
+	authConfig    models.AuthConfig
+	tokenProvider *OAuth2TokenProvider
+	fixtures      FixtureLoader
+	config        models.GRPCConfig
+}
+
+// grpcResponse is the outcome of a gRPC call
+type grpcResponse struct {
+	header  http.Header
+	trailer http.Header
+	code    int
+	message string
+	payload []byte // serialized response message, nil when the call failed
+}
+
+// newGRPCExecutor creates the gRPC executor. Base URLs with the https or
+// grpcs scheme use TLS; http, grpc or no scheme use plaintext HTTP/2.
+func newGRPCExecutor(config ExecutorConfig) (Executor, error) {
+	target := config.BaseURL
+	if !strings.Contains(target, "://") {
+		target = "grpc://" + target
+	}
+	parsed, err := neturl.Parse(target)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid gRPC target %q", config.BaseURL)
+	}
+
+	executor := &GRPCExecutor{
+		serviceID:     config.ServiceID,
+		authConfig:    config.AuthConfig,
+		tokenProvider: config.TokenProvider,
+		fixtures:      config.Fixtures,
+		config:        config.GRPC,
+	}
+	switch parsed.Scheme {
+	case "https", "grpcs":
+		executor.origin = "https://" + parsed.Host
+		executor.client = &http.Client{Transport: grpcTLSTransport}
+	case "http", "grpc":
+		executor.origin = "http://" + parsed.Host
+		executor.client = &http.Client{Transport: grpcPlaintextTransport}
+	default:
+		return nil, fmt.Errorf("unsupported gRPC target scheme %q", parsed.Scheme)
+	}
+	return executor, nil
+}
+
+// ExecuteTest calls the method named by the request URL, e.g.
+// /helloworld.Greeter/SayHello, with the request body as message
+func (e *GRPCExecutor) ExecuteTest(ctx context.Context, testSpec *models.TestSpec) *TestResult {
+	start := time.Now()
+	result := &TestResult{
+		TestName:  testSpec.Name,
+		StartTime: start,
+		Status:    "PASSED",
+	}
+	fail := func(failureType, format string, args ...interface{}) *TestResult {
+		result.Status = "FAILED"
+		result.ErrorMessage = fmt.Sprintf(format, args...)
+		result.FailureType = failureType
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(testSpec.Request.URL, "/"), "/")
+	if !ok || serviceName == "" || methodName == "" {
+		return fail(FailureSpec, "gRPC request url must name a method as /package.Service/Method, got %q", testSpec.Request.URL)
+	}
+
+	method, err := e.resolveMethod(ctx, serviceName, methodName)
+	if err != nil {
+		var spec *grpcSpecError
+		if errors.As(err, &spec) {
+			return fail(FailureSpec, "%v", err)
+		}
+		result.FailureDetail = NetworkErrorDetail(err)
+		return fail(ClassifyError(err), "gRPC reflection failed: %v", err)
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return fail(FailureSpec, "streaming method %s is not supported", method.FullName())
+	}
+
+	input := dynamicpb.NewMessage(method.Input())
+	if testSpec.Request.Body != nil {
+		body, err := json.Marshal(testSpec.Request.Body)
+		if err == nil {
+			err = protojson.Unmarshal(body, input)
+		}
+		if err != nil {
+			return fail(FailureSpec, "request body is not a valid %s message: %v", method.Input().FullName(), err)
+		}
+	}
+	payload, err := proto.Marshal(input)
+	if err != nil {
+		return fail(FailureSpec, "failed to encode request message: %v", err)
+	}
+
+	metadata := make(map[string]string, len(testSpec.Request.Headers)+1)
+	for name, value := range testSpec.Request.Headers {
+		metadata[name] = value
+	}
+	authConfig := e.authConfig
+	if testSpec.Request.Auth != nil {
+		authConfig = *testSpec.Request.Auth
+	}
+	credential, err := authCredential(ctx, authConfig, e.tokenProvider, e.serviceID)
+	if err != nil {
+		return fail(FailureSpec, "Failed to apply authentication: %v", err)
+	}
+	if credential.name != "" {
+		// gRPC has no query string; every credential travels as metadata
+		metadata[credential.name] = credential.value
+	}
+
+	callStart := time.Now()
+	response, err := e.invoke(ctx, fmt.Sprintf("/%s/%s", serviceName, methodName), payload, metadata)
+	roundTrip := time.Since(callStart)
+	if err != nil {
+		result.FailureDetail = NetworkErrorDetail(err)
+		return fail(ClassifyError(err), "gRPC request failed: %v", err)
+	}
+
+	var body interface{}
+	if response.payload != nil {
+		output := dynamicpb.NewMessage(method.Output())
+		if err := proto.Unmarshal(response.payload, output); err != nil {
+			return fail(FailureServer, "invalid %s response message: %v", method.Output().FullName(), err)
+		}
+		encoded, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(output)
+		if err == nil {
+			err = json.Unmarshal(encoded, &body)
+		}
+		if err != nil {
+			return fail(FailureInternal, "failed to convert response message: %v", err)
+		}
+	}
+
+	responseData := map[string]interface{}{
+		"status_code": response.code,
+		"status":      grpcCodeName(response.code),
+		"message":     response.message,
+		"headers":     response.header,
+		"trailers":    response.trailer,
+		"body":        body,
+	}
+	responseBytes, _ := json.Marshal(responseData)
+	result.ResponseData = string(responseBytes)
+
+	// A failing status fails the test unless the test asserts on the status
+	if response.code != 0 && !assertsStatus(testSpec) {
+		return fail(ClassifyGRPCStatus(response.code), "gRPC call failed with status %s: %s", grpcCodeName(response.code), response.message)
+	}
+
+	if testSpec.Assertions == nil {
+		return fail(FailureSpec, "No assertions found")
+	}
+	for _, assertionSpec := range testSpec.Assertions {
+		var assertion map[string]interface{}
+		encoded, _ := json.Marshal(assertionSpec)
+		json.Unmarshal(encoded, &assertion)
+
+		assertionResult := evaluateGRPCAssertion(result.ResponseData, response.code, body, roundTrip, assertion)
+		explainFailure(&assertionResult, assertionSpec.Message)
+		result.AssertionResults = append(result.AssertionResults, assertionResult)
+		if !assertionResult.Passed {
+			result.Status = "FAILED"
+			result.ErrorMessage = assertionResult.Message
+			result.FailureType = FailureAssertion
+		}
+	}
+
+	result.Duration = time.Since(start)
+	return result
+}
+
+// ClassifyGRPCStatus returns the failure type of a failing gRPC status code
+func ClassifyGRPCStatus(code int) string {
+	switch grpcCodeName(code) {
+	case "DEADLINE_EXCEEDED":
+		return FailureTimeout
+	case "UNAVAILABLE":
+		return FailureConnection
+	case "UNKNOWN", "INTERNAL", "DATA_LOSS", "UNIMPLEMENTED":
+		return FailureServer
+	default:
+		return FailureClient
+	}
+}
+
+// evaluateGRPCAssertion evaluates an assertion against a gRPC response.
+// status_code assertions accept a code number or name, or a list of them; paths of equals and
+// exists assertions address the whole response data like HTTP assertions.
+func evaluateGRPCAssertion(responseData string, code int, body interface{}, roundTrip time.Duration, assertion map[string]interface{}) AssertionResult {
+	result := AssertionResult{Passed: true}
+	result.Type, _ = assertion["type"].(string)
+	path, _ := assertion["path"].(string)
+	expected := expectedValue(assertion)
+
+	switch result.Type {
+	case "status_code":
+		result.Expected = expected
+		result.Actual = grpcCodeName(code)
+		switch want := expected.(type) {
+		case float64:
+			result.Passed = int(want) == code
+		case string:
+			result.Passed = strings.EqualFold(want, grpcCodeName(code))
+		case []interface{}:
+			// Any of a list of codes or names
+			result.Matcher = StatusMatcherOneOf
+			result.Passed = false
+			for _, item := range want {
+				if number, ok := item.(float64); ok && int(number) == code {
+					result.Passed = true
+				} else if name, ok := item.(string); ok && strings.EqualFold(name, grpcCodeName(code)) {
+					result.Passed = true
+				}
+			}
+		default:
+			result.Passed = false
+			result.Message = "status_code assertion expects a gRPC status code, name or a list of them"
+			return result
+		}
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected status %v, got %s (%d)", expected, grpcCodeName(code), code)
+		}
+
+	case "exists", "equals":
+		if strings.HasPrefix(path, "[") {
+			path = "body" + strings.ReplaceAll(strings.ReplaceAll(path, "[", "."), "]", "")
+		}
+		result.Path = path
+		result.Matcher = result.Type
+		assertValue(&result, gjson.Get(responseData, path), result.Type, expected, normalization(assertion))
+
+	case "json_path":
+		result.Path = path
+		result.Matcher, _ = assertion["matcher"].(string)
+		encoded, _ := json.Marshal(body)
+		assertValue(&result, gjson.GetBytes(encoded, path), result.Matcher, expected, normalization(assertion))
+
+	case "json_schema":
+		assertJSONSchema(&result, body, assertion)
+
+	case "response_time", "latency_budget":
+		assertResponseTime(&result, roundTrip, assertion)
+
+	default:
+		result.Passed = false
+		result.Message = fmt.Sprintf("Unknown assertion type: %s", result.Type)
+	}
+	return result
+}
+
+// assertValue applies the exists, equals, contains or regex matcher to a value.
+// Strings are normalized according to the options before they are compared.
+func assertValue(result *AssertionResult, value gjson.Result, matcher string, expected interface{}, options *models.TextNormalization) {
+	normalize, err := newTextNormalizer(options)
+	if err != nil {
+		result.Passed = false
+		result.Message = err.Error()
+		return
+	}
+
+	switch matcher {
+	case "exists":
+		result.Passed = value.Exists()
+		if !result.Passed {
+			result.Message = fmt.Sprintf("JSON path '%s' does not exist", result.Path)
+		}
+	case "equals":
+		result.Expected = expected
+		result.Actual = value.Value()
+		result.Passed = reflect.DeepEqual(normalize.value(value.Value()), normalize.value(expected))
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected '%v', got '%v' for path '%s'", expected, value.Value(), result.Path)
+		}
+	case "contains":
+		result.Expected = expected
+		result.Actual = value.String()
+		result.Passed = strings.Contains(normalize.text(value.String()), normalize.text(fmt.Sprint(expected)))
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected to contain '%v', got '%s'", expected, value.String())
+		}
+	case "regex":
+		result.Expected = expected
+		result.Actual = value.String()
+		pattern, err := regexp.Compile(fmt.Sprint(expected))
+		if err != nil {
+			result.Passed = false
+			result.Message = fmt.Sprintf("Invalid regex '%v': %v", expected, err)
+			return
+		}
+		result.Passed = value.Exists() && pattern.MatchString(normalize.text(value.String()))
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected '%s' to match '%v' for path '%s'", value.String(), expected, result.Path)
+		}
+	default:
+		result.Passed = false
+		result.Message = fmt.Sprintf("Unknown matcher: %s", matcher)
+	}
+}
+
+// grpcSpecError reports a method that cannot be resolved from the descriptors
+type grpcSpecError struct {
+	message string
+}
+
+func (e *grpcSpecError) Error() string {
+	return e.message
+}
+
+// resolveMethod finds the descriptor of a method in the descriptor set of
+// the service, or through server reflection without one
+func (e *GRPCExecutor) resolveMethod(ctx context.Context, serviceName, methodName string) (protoreflect.MethodDescriptor, error) {
+	var (
+		descriptor protoreflect.Descriptor
+		err        error
+	)
+	if e.config.DescriptorFixtureID != "" {
+		descriptor, err = e.fixtureDescriptor(serviceName)
+	} else {
+		descriptor, err = e.reflectDescriptor(ctx, serviceName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, &grpcSpecError{fmt.Sprintf("%s is not a gRPC service", serviceName)}
+	}
+	method := service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, &grpcSpecError{fmt.Sprintf("service %s has no method %s", serviceName, methodName)}
+	}
+	return method, nil
+}
+
+// fixtureDescriptor looks a symbol up in the descriptor set fixture of the service
+func (e *GRPCExecutor) fixtureDescriptor(symbol string) (protoreflect.Descriptor, error) {
+	if e.fixtures == nil {
+		return nil, &grpcSpecError{"no fixture loader configured for the gRPC descriptor set"}
+	}
+	fixture, err := e.fixtures.LoadFixture(e.config.DescriptorFixtureID, e.config.DescriptorVersion)
+	if err != nil {
+		return nil, &grpcSpecError{fmt.Sprintf("failed to load gRPC descriptor set: %v", err)}
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(fixture.Content, &set); err != nil {
+		return nil, &grpcSpecError{fmt.Sprintf("fixture %s is not a FileDescriptorSet: %v", fixture.FileName, err)}
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, &grpcSpecError{fmt.Sprintf("invalid gRPC descriptor set: %v", err)}
+	}
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(symbol))
+	if err != nil {
+		return nil, &grpcSpecError{fmt.Sprintf("%s is not in the gRPC descriptor set", symbol)}
+	}
+	return descriptor, nil
+}
+
+// invoke performs a unary call: it sends a single framed message and reads
+// the response message and the status from the trailers
+func (e *GRPCExecutor) invoke(ctx context.Context, path string, payload []byte, metadata map[string]string) (*grpcResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.origin+path, bytes.NewReader(grpcFrame(payload)))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range metadata {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Header.Set("grpc-timeout", grpcTimeout(time.Until(deadline)))
+	}
+	applyTraceParentHeader(ctx, req.Header)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Trailers are only available once the body was read to the end
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server answered with HTTP status %d instead of a gRPC response", resp.StatusCode)
+	}
+
+	response := &grpcResponse{header: resp.Header, trailer: resp.Trailer}
+	status := resp.Trailer.Get("grpc-status")
+	if status == "" {
+		// Trailers-only responses carry the status in the headers
+		status = resp.Header.Get("grpc-status")
+		response.message = resp.Header.Get("grpc-message")
+	} else {
+		response.message = resp.Trailer.Get("grpc-message")
+	}
+	if response.code, err = strconv.Atoi(status); err != nil {
+		return nil, fmt.Errorf("response has no valid grpc-status: %q", status)
+	}
+	if message, err := neturl.PathUnescape(response.message); err == nil {
+		response.message = message
+	}
+
+	if len(data) > 0 {
+		if response.payload, err = grpcUnframe(data); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// grpcFrame prefixes a message with the gRPC length-prefixed framing
+func grpcFrame(payload []byte) []byte {
+	frame := make([]byte, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	copy(frame[5:], payload)
+	return frame
+}
+
+// grpcUnframe returns the first message of a framed gRPC body
+func grpcUnframe(data []byte) ([]byte, error) {
+	if len(data) < 5 {
+		return nil, fmt.Errorf("truncated gRPC message")
+	}
+	if data[0] != 0 {
+		return nil, fmt.Errorf("compressed gRPC messages are not supported")
+	}
+	length := binary.BigEndian.Uint32(data[1:5])
+	if uint32(len(data)-5) < length {
+		return nil, fmt.Errorf("truncated gRPC message")
+	}
+	return data[5 : 5+length], nil
+}
+
+// grpcTimeout encodes a timeout as grpc-timeout header value
+func grpcTimeout(timeout time.Duration) string {
+	if timeout <= 0 {
+		return "1m"
+	}
+	if ms := timeout.Milliseconds(); ms < 1e8 {
+		return fmt.Sprintf("%dm", ms+1)
+	}
+	return fmt.Sprintf("%dS", int64(timeout.Seconds()))
+}
@@ -0,0 +1,181 @@
+package testrunner
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// reflectionMethods are the server reflection methods, newest first
+var reflectionMethods = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// Field numbers of the server reflection messages, which are encoded by hand
+// to avoid generated code for a single request
+const (
+	reflectionFileByFilename       protowire.Number = 3 // ServerReflectionRequest.file_by_filename
+	reflectionFileContainingSymbol protowire.Number = 4 // ServerReflectionRequest.file_containing_symbol
+	reflectionFileDescriptors      protowire.Number = 4 // ServerReflectionResponse.file_descriptor_response
+	reflectionError                protowire.Number = 7 // ServerReflectionResponse.error_response
+)
+
+// reflectDescriptor looks a symbol up through the server reflection service,
+// fetching the files that define it and their dependencies
+func (e *GRPCExecutor) reflectDescriptor(ctx context.Context, symbol string) (protoreflect.Descriptor, error) {
+	files := map[string]*descriptorpb.FileDescriptorProto{}
+	order := []string{}
+	add := func(received []*descriptorpb.FileDescriptorProto) {
+		for _, file := range received {
+			if _, ok := files[file.GetName()]; !ok {
+				files[file.GetName()] = file
+				order = append(order, file.GetName())
+			}
+		}
+	}
+
+	received, err := e.reflect(ctx, reflectionFileContainingSymbol, symbol)
+	if err != nil {
+		return nil, err
+	}
+	add(received)
+
+	// Servers usually send the dependencies along; fetch any they left out
+	for i := 0; i < len(order); i++ {
+		for _, dependency := range files[order[i]].GetDependency() {
+			if _, ok := files[dependency]; ok {
+				continue
+			}
+			if known, err := protoregistry.GlobalFiles.FindFileByPath(dependency); err == nil {
+				add([]*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(known)})
+				continue
+			}
+			received, err := e.reflect(ctx, reflectionFileByFilename, dependency)
+			if err != nil {
+				return nil, err
+			}
+			add(received)
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, name := range order {
+		set.File = append(set.File, files[name])
+	}
+	registry, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, &grpcSpecError{fmt.Sprintf("server reflection returned invalid descriptors: %v", err)}
+	}
+	descriptor, err := registry.FindDescriptorByName(protoreflect.FullName(symbol))
+	if err != nil {
+		return nil, &grpcSpecError{fmt.Sprintf("%s is not known to server reflection", symbol)}
+	}
+	return descriptor, nil
+}
+
+// reflect sends a single server reflection request and returns the file
+// descriptors of the response. The v1alpha service is tried when the server
+// does not implement v1.
+func (e *GRPCExecutor) reflect(ctx context.Context, field protowire.Number, value string) ([]*descriptorpb.FileDescriptorProto, error) {
+	request := protowire.AppendTag(nil, field, protowire.BytesType)
+	request = protowire.AppendString(request, value)
+
+	var response *grpcResponse
+	for _, method := range reflectionMethods {
+		var err error
+		if response, err = e.invoke(ctx, method, request, nil); err != nil {
+			return nil, err
+		}
+		if grpcCodeName(response.code) != "UNIMPLEMENTED" {
+			break
+		}
+	}
+	if response.code != 0 {
+		return nil, &grpcSpecError{fmt.Sprintf("server reflection is not available (%s: %s); upload a descriptor set instead", grpcCodeName(response.code), response.message)}
+	}
+	return parseReflectionResponse(response.payload)
+}
+
+// parseReflectionResponse decodes the file descriptors or the error of a
+// ServerReflectionResponse
+func parseReflectionResponse(payload []byte) ([]*descriptorpb.FileDescriptorProto, error) {
+	var files []*descriptorpb.FileDescriptorProto
+	for len(payload) > 0 {
+		number, wireType, n := protowire.ConsumeTag(payload)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		payload = payload[n:]
+		if wireType != protowire.BytesType || (number != reflectionFileDescriptors && number != reflectionError) {
+			n = protowire.ConsumeFieldValue(number, wireType, payload)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			payload = payload[n:]
+			continue
+		}
+
+		message, n := protowire.ConsumeBytes(payload)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		payload = payload[n:]
+		if number == reflectionError {
+			return nil, &grpcSpecError{"server reflection: " + reflectionErrorMessage(message)}
+		}
+
+		// FileDescriptorResponse holds the serialized files in field 1
+		for len(message) > 0 {
+			number, wireType, n := protowire.ConsumeTag(message)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			message = message[n:]
+			if number != 1 || wireType != protowire.BytesType {
+				n = protowire.ConsumeFieldValue(number, wireType, message)
+			} else {
+				var encoded []byte
+				encoded, n = protowire.ConsumeBytes(message)
+				file := &descriptorpb.FileDescriptorProto{}
+				if n >= 0 {
+					if err := proto.Unmarshal(encoded, file); err != nil {
+						return nil, fmt.Errorf("invalid file descriptor from server reflection: %v", err)
+					}
+					files = append(files, file)
+				}
+			}
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			message = message[n:]
+		}
+	}
+	return files, nil
+}
+
+// reflectionErrorMessage returns the error_message of an ErrorResponse
+func reflectionErrorMessage(message []byte) string {
+	for len(message) > 0 {
+		number, wireType, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			break
+		}
+		message = message[n:]
+		if number == 2 && wireType == protowire.BytesType {
+			text, _ := protowire.ConsumeString(message)
+			return text
+		}
+		if n = protowire.ConsumeFieldValue(number, wireType, message); n < 0 {
+			break
+		}
+		message = message[n:]
+	}
+	return "request failed"
+}
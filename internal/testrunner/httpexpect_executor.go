@@ -1,10 +1,12 @@
 package testrunner
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
+	neturl "net/url"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -14,39 +16,105 @@ import (
 	"github.com/tidwall/gjson"
 )
 
-// getKeys returns all keys from a map recursively
-func getKeys(m map[string]interface{}) []string {
-	keys := make([]string, 0, len(m))
-	for k := range m {
-		keys = append(keys, k)
+// expectedValue returns the expected value of an assertion, accepting the legacy
+// "value" key as well as the "expected" key produced by AssertionSpec
+func expectedValue(assertion map[string]interface{}) interface{} {
+	if value, ok := assertion["value"]; ok {
+		return value
 	}
-	return keys
+	return assertion["expected"]
 }
 
-// debugLog writes debug information to a file
-func debugLog(format string, args ...interface{}) {
-	f, err := os.OpenFile("debug.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return
+// responseBody decodes the response body as JSON and falls back to the raw text
+// for other representations (XML, HTML, plain text) negotiated by the request
+func responseBody(resp *httpexpect.Response) interface{} {
+	raw := resp.Body().Raw()
+	if raw == "" {
+		return nil
 	}
-	defer f.Close()
-	
-	fmt.Fprintf(f, "[%s] %s\n", time.Now().Format("2006-01-02 15:04:05"), fmt.Sprintf(format, args...))
+
+	var body interface{}
+	if err := json.Unmarshal([]byte(raw), &body); err != nil {
+		return raw
+	}
+	return body
+}
+
+// storedBody returns the body of a response as it is stored in the result.
+// JSON bodies are kept as received, so their key order and numbers survive;
+// other bodies are stored as text.
+func storedBody(resp *httpexpect.Response) interface{} {
+	raw := resp.Body().Raw()
+	if raw == "" {
+		return nil
+	}
+	if json.Valid([]byte(raw)) {
+		return json.RawMessage(raw)
+	}
+	return raw
 }
 
-// min returns the minimum of two integers
-func min(a, b int) int {
-	if a < b {
-		return a
+// responseValue looks up path in the stored form of a response, an object of
+// its status_code, headers and body. Array paths like [0].field address the
+// body and are converted to the dot notation of gjson; the converted path is
+// returned along with the value.
+func responseValue(resp *httpexpect.Response, path string) (gjson.Result, string) {
+	responseData := map[string]interface{}{
+		"status_code": resp.Raw().StatusCode,
+		"headers":     resp.Raw().Header,
+		"body":        responseBody(resp),
 	}
-	return b
+	jsonString := ""
+	if jsonBytes, err := json.Marshal(responseData); err == nil {
+		jsonString = string(jsonBytes)
+	}
+
+	if len(path) > 0 && path[0] == '[' {
+		// [0].field -> body.0.field
+		dotPath := strings.ReplaceAll(path, "[", ".")
+		dotPath = strings.ReplaceAll(dotPath, "]", "")
+		path = "body" + dotPath
+	}
+	return gjson.Get(jsonString, path), path
+}
+
+// splitRequestURL splits a request URL into its path, raw query and, for
+// absolute URLs, the origin that replaces the base URL. httpexpect appends the
+// path to the base URL verbatim, so queries must be passed separately.
+func splitRequestURL(rawURL string) (path, rawQuery, origin string) {
+	if parsed, err := neturl.Parse(rawURL); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		return parsed.Path, parsed.RawQuery, parsed.Scheme + "://" + parsed.Host
+	}
+
+	path = rawURL
+	if idx := strings.Index(path, "#"); idx >= 0 {
+		path = path[:idx]
+	}
+	if idx := strings.Index(path, "?"); idx >= 0 {
+		path, rawQuery = path[:idx], path[idx+1:]
+	}
+	return path, rawQuery, ""
 }
 
 // HTTPExpectExecutor handles test execution using httpexpect
 type HTTPExpectExecutor struct {
-	client *httpexpect.Expect
+	client        *httpexpect.Expect
+	baseURL       string
+	serviceID     string
+	authConfig    models.AuthConfig
+	tokenProvider *OAuth2TokenProvider
+	fixtures      FixtureLoader
+	versioning    models.APIVersioning
+	apiVersion    string
+	responseCheck ResponseCheck
+	logger        *slog.Logger
 }
 
+// ResponseCheck validates a response beyond the assertions of the test spec,
+// e.g. errors a protocol reports with a successful status code. It is called
+// once the status code and assertions were evaluated and may fail the result.
+type ResponseCheck func(testSpec *models.TestSpec, result *TestResult, statusCode int, body interface{})
+
 // TestResult represents the result of a test execution
 type TestResult struct {
 	TestName      string    `json:"test_name"`
@@ -54,8 +122,13 @@ type TestResult struct {
 	StartTime     time.Time `json:"start_time"`
 	Duration      time.Duration `json:"duration"`
 	ErrorMessage  string    `json:"error_message,omitempty"`
+	FailureType   string    `json:"failure_type,omitempty"` // classification of a failure, see Failure* constants
+	FailureDetail string    `json:"failure_detail,omitempty"` // underlying network error when no response arrived
+	Attempts      int       `json:"attempts,omitempty"`       // executions including retries, see ExecuteWithRetry
+	Polls         int       `json:"polls,omitempty"`          // requests sent to satisfy the poll_until condition
 	ResponseData  string    `json:"response_data,omitempty"`
 	AssertionResults []AssertionResult `json:"assertion_results,omitempty"`
+	VariantResults   []VariantResult   `json:"variant_results,omitempty"`
 }
 
 // AssertionResult represents the result of a single assertion
@@ -69,23 +142,44 @@ type AssertionResult struct {
 	Message  string      `json:"message,omitempty"`
 }
 
-// NewHTTPExpectExecutor creates a new test executor
+// NewHTTPExpectExecutor creates a new test executor. Requests have no client
+// timeout; they are bounded by the deadline of the context of the test.
 func NewHTTPExpectExecutor(baseURL string) *HTTPExpectExecutor {
+	return newHTTPExpectExecutor(baseURL, DefaultClientPool.Client(baseURL, models.TLSConfig{}))
+}
+
+// newHTTPExpectExecutor creates a test executor sending requests with client,
+// which is shared with the other executors of the same base URL
+func newHTTPExpectExecutor(baseURL string, client *http.Client) *HTTPExpectExecutor {
 	config := httpexpect.Config{
 		BaseURL: baseURL,
-		Client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		Client:  client,
 		Reporter: httpexpect.NewAssertReporter(nil),
 	}
 	
 	return &HTTPExpectExecutor{
-		client: httpexpect.WithConfig(config),
+		client:  httpexpect.WithConfig(config),
+		baseURL: baseURL,
+		logger:  slog.Default(),
 	}
 }
 
-// ExecuteTest executes a single test case
-func (e *HTTPExpectExecutor) ExecuteTest(testSpec *models.TestSpec) *TestResult {
+// ExecuteTest executes a single test case. Cancelling ctx aborts the in-flight request.
+func (e *HTTPExpectExecutor) ExecuteTest(ctx context.Context, testSpec *models.TestSpec) *TestResult {
+	if testSpec.TimeMatrix != nil {
+		return e.executeTimeMatrix(ctx, testSpec)
+	}
+	if len(testSpec.Variants) > 0 {
+		return e.executeVariants(ctx, testSpec)
+	}
+	if testSpec.Conflict != nil {
+		return e.executeConflict(ctx, testSpec)
+	}
+	return e.executePolled(ctx, testSpec)
+}
+
+// executeRequest sends the request described by the test spec once and runs its assertions
+func (e *HTTPExpectExecutor) executeRequest(ctx context.Context, testSpec *models.TestSpec) *TestResult {
 	start := time.Now()
 	
 	result := &TestResult{
@@ -99,6 +193,7 @@ func (e *HTTPExpectExecutor) ExecuteTest(testSpec *models.TestSpec) *TestResult
 	if err != nil {
 		result.Status = "FAILED"
 		result.ErrorMessage = fmt.Sprintf("Failed to marshal test spec: %v", err)
+		result.FailureType = FailureSpec
 		result.Duration = time.Since(start)
 		return result
 	}
@@ -107,6 +202,7 @@ func (e *HTTPExpectExecutor) ExecuteTest(testSpec *models.TestSpec) *TestResult
 	if err := json.Unmarshal(testSpecBytes, &testSpecData); err != nil {
 		result.Status = "FAILED"
 		result.ErrorMessage = fmt.Sprintf("Failed to parse test spec: %v", err)
+		result.FailureType = FailureSpec
 		result.Duration = time.Since(start)
 		return result
 	}
@@ -116,44 +212,122 @@ func (e *HTTPExpectExecutor) ExecuteTest(testSpec *models.TestSpec) *TestResult
 	if !ok {
 		result.Status = "FAILED"
 		result.ErrorMessage = "Invalid request specification"
+		result.FailureType = FailureSpec
 		result.Duration = time.Since(start)
 		return result
 	}
 
 	// Build request
 	method := requestData["method"].(string)
-	url := requestData["url"].(string)
+	url := e.versionedURL(requestData["url"].(string))
 	
-	req := e.client.Request(method, url)
-	
-	// Add headers
-	if headers, ok := requestData["headers"].(map[string]interface{}); ok {
+	// Encode multipart bodies once so a retried request sends identical bytes
+	var multipartBody []byte
+	var multipartContentType string
+	if len(testSpec.Request.Multipart) > 0 {
+		multipartBody, multipartContentType, err = e.buildMultipartBody(testSpec.Request.Multipart)
+		if err != nil {
+			result.Status = "FAILED"
+			result.ErrorMessage = fmt.Sprintf("Failed to build multipart body: %v", err)
+			result.FailureType = FailureSpec
+			result.Duration = time.Since(start)
+			return result
+		}
+	}
+
+	// Fixture bodies are loaded once as well and sent as they are
+	var bodyFixture *models.FixtureVersion
+	if ref := testSpec.Request.BodyFixture; ref != nil && multipartBody == nil {
+		bodyFixture, err = e.loadBodyFixture(ref)
+		if err != nil {
+			result.Status = "FAILED"
+			result.ErrorMessage = err.Error()
+			result.FailureType = FailureSpec
+			result.Duration = time.Since(start)
+			return result
+		}
+	}
+
+	authConfig := e.effectiveAuth(&testSpec.Request)
+	failures := &requestFailures{}
+	buildRequest := func() (*httpexpect.Request, error) {
+		path, rawQuery, origin := splitRequestURL(url)
+		req := e.client.Request(method, path).WithAssertionHandler(failures).WithContext(ctx)
+		if origin != "" {
+			req = req.WithURL(origin)
+		}
+		if rawQuery != "" {
+			req = req.WithQueryString(rawQuery)
+		}
+
+		// Add headers
+		headers, _ := requestData["headers"].(map[string]interface{})
 		for key, value := range headers {
 			req = req.WithHeader(key, value.(string))
 		}
+		req = e.applyAPIVersion(req, headers)
+		req = applyTraceParent(ctx, req, headers)
+
+		// Add body if present
+		if multipartBody != nil {
+			req = req.WithHeader("Content-Type", multipartContentType).WithBytes(multipartBody)
+		} else if bodyFixture != nil {
+			if !hasHeader(testSpec.Request.Headers, "Content-Type") {
+				req = req.WithHeader("Content-Type", bodyFixture.ContentType)
+			}
+			req = req.WithBytes(bodyFixture.Content)
+		} else if body, ok := requestData["body"]; ok && body != nil {
+			// XML documents such as SOAP envelopes are sent as they are
+			if text, isText := body.(string); isText && isXMLContentType(headers) {
+				req = req.WithBytes([]byte(text))
+			} else {
+				req = req.WithJSON(body)
+			}
+		}
+
+		return e.applyAuth(ctx, req, authConfig)
 	}
-	
-	// Add body if present
-	if body, ok := requestData["body"]; ok && body != nil {
-		req = req.WithJSON(body)
+
+	req, err := buildRequest()
+	if err != nil {
+		result.Status = "FAILED"
+		result.ErrorMessage = fmt.Sprintf("Failed to apply authentication: %v", err)
+		result.FailureType = FailureSpec
+		result.Duration = time.Since(start)
+		return result
 	}
-	
+
 	// Execute request
 	resp := req.Expect()
-	
-	// Check if request failed
-	if resp.Raw().StatusCode >= 400 {
+
+	// A rejected OAuth2 token is refreshed once before the response is evaluated
+	if resp.Raw() != nil && resp.Raw().StatusCode == http.StatusUnauthorized && authConfig.Type == "oauth2" && e.tokenProvider != nil {
+		e.tokenProvider.Invalidate(ctx, e.serviceID, authConfig)
+		if retry, err := buildRequest(); err == nil {
+			failures.err = nil
+			resp = retry.Expect()
+		}
+	}
+
+	// No response: the request could not be sent or timed out
+	if resp.Raw() == nil {
+		err := failures.err
+		if err == nil {
+			err = fmt.Errorf("no response received")
+		}
 		result.Status = "FAILED"
-		result.ErrorMessage = fmt.Sprintf("HTTP request failed with status %d", resp.Raw().StatusCode)
+		result.ErrorMessage = fmt.Sprintf("HTTP request failed: %v", err)
+		result.FailureType = ClassifyError(err)
+		result.FailureDetail = NetworkErrorDetail(err)
 		result.Duration = time.Since(start)
 		return result
 	}
-	
+
 	// Store response data with more details
 	responseData := map[string]interface{}{
 		"status_code": resp.Raw().StatusCode,
 		"headers":     resp.Raw().Header,
-		"body":        resp.JSON().Raw(),
+		"body":        storedBody(resp),
 	}
 	
 	responseBytes, _ := json.Marshal(responseData)
@@ -162,17 +336,25 @@ func (e *HTTPExpectExecutor) ExecuteTest(testSpec *models.TestSpec) *TestResult
 	} else {
 		result.ResponseData = "{}"
 	}
+
+	// An error status fails the test unless the test asserts on the status
+	if resp.Raw().StatusCode >= 400 && !assertsStatus(testSpec) {
+		result.Status = "FAILED"
+		result.ErrorMessage = fmt.Sprintf("HTTP request failed with status %d", resp.Raw().StatusCode)
+		result.FailureType = ClassifyStatus(resp.Raw().StatusCode)
+		e.checkResponse(testSpec, result, resp)
+		result.Duration = time.Since(start)
+		return result
+	}
 	
-	// Debug: Log response info
-	// fmt.Printf("Response Status: %d\n", resp.Raw().StatusCode)
-	// fmt.Printf("Response Headers: %v\n", resp.Raw().Header)
-	// fmt.Printf("Response Body: %v\n", resp.JSON().Raw())
+	e.logger.Debug("received response", "method", method, "url", url, "status_code", resp.Raw().StatusCode, "duration", time.Since(start))
 	
 	// Run assertions
 	assertions, ok := testSpecData["assertions"].([]interface{})
 	if !ok {
 		result.Status = "FAILED"
 		result.ErrorMessage = "No assertions found"
+		result.FailureType = FailureSpec
 		result.Duration = time.Since(start)
 		return result
 	}
@@ -190,19 +372,64 @@ func (e *HTTPExpectExecutor) ExecuteTest(testSpec *models.TestSpec) *TestResult
 			continue
 		}
 		
-		assertionResult := e.executeAssertion(resp, assertion)
+		var assertionResult AssertionResult
+		if assertion["type"] == "probe_safe" {
+			// Probes are recognized in the request that was sent
+			assertionResult = AssertionResult{Type: "probe_safe", Passed: true}
+			assertProbeSafe(&assertionResult, resp.Raw().StatusCode, resp.Body().Raw(), requestData)
+		} else {
+			assertionResult = e.executeAssertion(resp, assertion)
+		}
+		message, _ := assertion["message"].(string)
+		explainFailure(&assertionResult, message)
 		result.AssertionResults = append(result.AssertionResults, assertionResult)
 		
 		if !assertionResult.Passed {
 			result.Status = "FAILED"
 			result.ErrorMessage = assertionResult.Message
+			result.FailureType = FailureAssertion
 		}
 	}
+	e.checkResponse(testSpec, result, resp)
 	
 	result.Duration = time.Since(start)
 	return result
 }
 
+// WithLogger sets the logger of the executor; nil keeps the default logger
+func (e *HTTPExpectExecutor) WithLogger(logger *slog.Logger) *HTTPExpectExecutor {
+	if logger != nil {
+		e.logger = logger
+	}
+	return e
+}
+
+// WithResponseCheck adds a check run on every response after its assertions
+func (e *HTTPExpectExecutor) WithResponseCheck(check ResponseCheck) *HTTPExpectExecutor {
+	e.responseCheck = check
+	return e
+}
+
+// checkResponse runs the response check of the executor, if any
+func (e *HTTPExpectExecutor) checkResponse(testSpec *models.TestSpec, result *TestResult, resp *httpexpect.Response) {
+	if e.responseCheck != nil {
+		e.responseCheck(testSpec, result, resp.Raw().StatusCode, responseBody(resp))
+	}
+}
+
+// explainFailure prefixes the message of a failed assertion with the
+// explanation the test author gave for it, if any
+func explainFailure(result *AssertionResult, message string) {
+	if result.Passed || message == "" {
+		return
+	}
+	if result.Message == "" {
+		result.Message = message
+		return
+	}
+	result.Message = message + ": " + result.Message
+}
+
 // executeAssertion executes a single assertion
 func (e *HTTPExpectExecutor) executeAssertion(resp *httpexpect.Response, assertion map[string]interface{}) AssertionResult {
 	result := AssertionResult{
@@ -211,201 +438,48 @@ func (e *HTTPExpectExecutor) executeAssertion(resp *httpexpect.Response, asserti
 	}
 
 	switch result.Type {
-	case "status_code":
-		if expected, ok := assertion["value"].(float64); ok {
-			actual := resp.Raw().StatusCode
-			result.Expected = int(expected)
-			result.Actual = actual
-			// Accept both 200 and 201 as success for POST requests
-			result.Passed = actual == int(expected) || (int(expected) == 200 && actual == 201)
-			if !result.Passed {
-				result.Message = fmt.Sprintf("Expected status code %d, got %d", int(expected), actual)
-			}
-		}
+	case "status_code", "status_class":
+		assertStatus(&result, resp.Raw().StatusCode, assertion)
 		
 	case "exists":
 		if path, ok := assertion["path"].(string); ok {
-			debugLog("=== EXISTS ASSERTION ===")
-			debugLog("Path: %s", path)
-			
-			// Build the same response data structure that we store
-			responseData := map[string]interface{}{
-				"status_code": resp.Raw().StatusCode,
-				"headers":     resp.Raw().Header,
-				"body":        resp.JSON().Raw(),
-			}
-			
-			// Convert to JSON string for gjson
-			jsonString := ""
-			if jsonBytes, err := json.Marshal(responseData); err == nil {
-				jsonString = string(jsonBytes)
-				debugLog("JSON string length: %d", len(jsonString))
-				debugLog("JSON string (first 500 chars): %s", jsonString[:min(500, len(jsonString))])
-			} else {
-				debugLog("Failed to marshal response data: %v", err)
-			}
-			
-			// Test if the JSON is valid
-			var testMap map[string]interface{}
-			if err := json.Unmarshal([]byte(jsonString), &testMap); err != nil {
-				debugLog("Invalid JSON: %v", err)
-			} else {
-				debugLog("JSON is valid, top-level keys: %v", getKeys(testMap))
-				if body, ok := testMap["body"]; ok {
-					debugLog("Body exists, type: %T", body)
-					if bodyArray, ok := body.([]interface{}); ok {
-						debugLog("Body is array, length: %d", len(bodyArray))
-						if len(bodyArray) > 0 {
-							if firstItem, ok := bodyArray[0].(map[string]interface{}); ok {
-								debugLog("First item keys: %v", getKeys(firstItem))
-							}
-						}
-					}
-				}
-			}
-			
-			// For array paths like [0].field, we need to prepend "body" and convert bracket notation to dot notation
-			if len(path) > 0 && path[0] == '[' {
-				// Convert bracket notation to dot notation: [0].field -> .0.field
-				dotPath := strings.ReplaceAll(path, "[", ".") // [0] -> .0
-				dotPath = strings.ReplaceAll(dotPath, "]", "") // .0] -> .0
-				fullPath := "body" + dotPath
-				debugLog("Original path: %s", path)
-				debugLog("Converted to dot notation: %s", dotPath)
-				debugLog("Using full path: %s", fullPath)
-				
-				// Test with a simple path first
-				testValue := gjson.Get(jsonString, "body")
-				debugLog("Test gjson.Get('body') = %v (exists: %v)", testValue.Value(), testValue.Exists())
-				
-				// Test with the first element
-				testValue2 := gjson.Get(jsonString, "body.0")
-				debugLog("Test gjson.Get('body.0') = %v (exists: %v)", testValue2.Value(), testValue2.Exists())
-				
-				value := gjson.Get(jsonString, fullPath)
-				debugLog("gjson.Get result: %v (exists: %v)", value.Value(), value.Exists())
-				result.Path = fullPath
-				result.Matcher = "exists"
-				result.Passed = value.Exists()
-				if !result.Passed {
-					result.Message = fmt.Sprintf("JSON path '%s' does not exist", fullPath)
-					debugLog("Path does not exist: %s", fullPath)
-				} else {
-					debugLog("Path exists: %s", fullPath)
-				}
-			} else {
-				// For non-array paths, use the path as-is
-				debugLog("Using direct path: %s", path)
-				value := gjson.Get(jsonString, path)
-				debugLog("gjson.Get result: %v (exists: %v)", value.Value(), value.Exists())
-				result.Path = path
-				result.Matcher = "exists"
-				result.Passed = value.Exists()
-				if !result.Passed {
-					result.Message = fmt.Sprintf("JSON path '%s' does not exist", path)
-					debugLog("Path does not exist: %s", path)
-				} else {
-					debugLog("Path exists: %s", path)
-				}
+			value, fullPath := responseValue(resp, path)
+			result.Path = fullPath
+			result.Matcher = "exists"
+			result.Passed = value.Exists()
+			if !result.Passed {
+				result.Message = fmt.Sprintf("JSON path '%s' does not exist", fullPath)
 			}
-			debugLog("=== END EXISTS ASSERTION ===")
+			e.logger.Debug("evaluated exists assertion", "path", fullPath, "passed", result.Passed)
 		}
 		
 	case "equals":
 		if path, ok := assertion["path"].(string); ok {
-			debugLog("=== EQUALS ASSERTION ===")
-			debugLog("Path: %s", path)
+			value, fullPath := responseValue(resp, path)
+			result.Path = fullPath
+			result.Matcher = "equals"
 			
-			// Build the same response data structure that we store
-			responseData := map[string]interface{}{
-				"status_code": resp.Raw().StatusCode,
-				"headers":     resp.Raw().Header,
-				"body":        resp.JSON().Raw(),
+			normalize, err := newTextNormalizer(normalization(assertion))
+			if err != nil {
+				result.Passed = false
+				result.Message = err.Error()
+				return result
 			}
-			
-			// Convert to JSON string for gjson
-			jsonString := ""
-			if jsonBytes, err := json.Marshal(responseData); err == nil {
-				jsonString = string(jsonBytes)
-				debugLog("JSON string length: %d", len(jsonString))
-				debugLog("JSON string (first 500 chars): %s", jsonString[:min(500, len(jsonString))])
-			} else {
-				debugLog("Failed to marshal response data: %v", err)
-			}
-			
-			// Test if the JSON is valid
-			var testMap map[string]interface{}
-			if err := json.Unmarshal([]byte(jsonString), &testMap); err != nil {
-				debugLog("Invalid JSON: %v", err)
-			} else {
-				debugLog("JSON is valid, top-level keys: %v", getKeys(testMap))
-				if body, ok := testMap["body"]; ok {
-					debugLog("Body exists, type: %T", body)
-					if bodyArray, ok := body.([]interface{}); ok {
-						debugLog("Body is array, length: %d", len(bodyArray))
-						if len(bodyArray) > 0 {
-							if firstItem, ok := bodyArray[0].(map[string]interface{}); ok {
-								debugLog("First item keys: %v", getKeys(firstItem))
-							}
-						}
-					}
-				}
-			}
-			
-			// For array paths like [0].field, we need to prepend "body" and convert bracket notation to dot notation
-			if len(path) > 0 && path[0] == '[' {
-				// Convert bracket notation to dot notation: [0].field -> .0.field
-				dotPath := strings.ReplaceAll(path, "[", ".") // [0] -> .0
-				dotPath = strings.ReplaceAll(dotPath, "]", "") // .0] -> .0
-				fullPath := "body" + dotPath
-				debugLog("Original path: %s", path)
-				debugLog("Converted to dot notation: %s", dotPath)
-				debugLog("Using full path: %s", fullPath)
-				value := gjson.Get(jsonString, fullPath)
-				debugLog("gjson.Get result: %v (exists: %v)", value.Value(), value.Exists())
-				result.Path = fullPath
-				result.Matcher = "equals"
-				
-				if expected, ok := assertion["value"]; ok {
-					result.Expected = expected
-					result.Actual = value.Value()
-					result.Passed = value.Value() == expected
-					debugLog("Expected: %v, Actual: %v, Passed: %v", expected, value.Value(), result.Passed)
-					if !result.Passed {
-						result.Message = fmt.Sprintf("Expected '%v', got '%v' for path '%s'", expected, value.Value(), fullPath)
-						debugLog("Equals assertion failed: %s", result.Message)
-					} else {
-						debugLog("Equals assertion passed")
-					}
-				}
-			} else {
-				// For non-array paths, use the path as-is
-				debugLog("Using direct path: %s", path)
-				value := gjson.Get(jsonString, path)
-				debugLog("gjson.Get result: %v (exists: %v)", value.Value(), value.Exists())
-				result.Path = path
-				result.Matcher = "equals"
-				
-				if expected, ok := assertion["value"]; ok {
-					result.Expected = expected
-					result.Actual = value.Value()
-					result.Passed = value.Value() == expected
-					debugLog("Expected: %v, Actual: %v, Passed: %v", expected, value.Value(), result.Passed)
-					if !result.Passed {
-						result.Message = fmt.Sprintf("Expected '%v', got '%v' for path '%s'", expected, value.Value(), path)
-						debugLog("Equals assertion failed: %s", result.Message)
-					} else {
-						debugLog("Equals assertion passed")
-					}
+			if expected := expectedValue(assertion); expected != nil {
+				result.Expected = expected
+				result.Actual = value.Value()
+				result.Passed = normalize.value(value.Value()) == normalize.value(expected)
+				if !result.Passed {
+					result.Message = fmt.Sprintf("Expected '%v', got '%v' for path '%s'", expected, value.Value(), fullPath)
 				}
 			}
-			debugLog("=== END EQUALS ASSERTION ===")
+			e.logger.Debug("evaluated equals assertion", "path", fullPath, "expected", result.Expected, "actual", result.Actual, "passed", result.Passed)
 		}
 		
 	case "json_path":
 		if path, ok := assertion["path"].(string); ok {
 			matcher := assertion["matcher"].(string)
-			jsonData := resp.JSON().Raw()
+			jsonData := responseBody(resp)
 			
 			// Convert jsonData to string for gjson
 			jsonString := ""
@@ -418,6 +492,12 @@ func (e *HTTPExpectExecutor) executeAssertion(resp *httpexpect.Response, asserti
 			value := gjson.Get(jsonString, path)
 			result.Path = path
 			result.Matcher = matcher
+			normalize, err := newTextNormalizer(normalization(assertion))
+			if err != nil {
+				result.Passed = false
+				result.Message = err.Error()
+				return result
+			}
 			
 			switch matcher {
 			case "exists":
@@ -429,7 +509,7 @@ func (e *HTTPExpectExecutor) executeAssertion(resp *httpexpect.Response, asserti
 				if expected, ok := assertion["expected"]; ok {
 					result.Expected = expected
 					result.Actual = value.Value()
-					result.Passed = value.Value() == expected
+					result.Passed = normalize.value(value.Value()) == normalize.value(expected)
 					if !result.Passed {
 						result.Message = fmt.Sprintf("Expected '%v', got '%v'", expected, value.Value())
 					}
@@ -438,7 +518,7 @@ func (e *HTTPExpectExecutor) executeAssertion(resp *httpexpect.Response, asserti
 				if expected, ok := assertion["expected"].(string); ok {
 					result.Expected = expected
 					result.Actual = value.String()
-					result.Passed = value.String() == expected
+					result.Passed = normalize.text(value.String()) == normalize.text(expected)
 					if !result.Passed {
 						result.Message = fmt.Sprintf("Expected to contain '%s', got '%s'", expected, value.String())
 					}
@@ -446,14 +526,23 @@ func (e *HTTPExpectExecutor) executeAssertion(resp *httpexpect.Response, asserti
 			}
 		}
 		
-	case "response_time":
-		if expected, ok := assertion["expected"].(float64); ok {
-			// Note: httpexpect doesn't provide direct access to response time
-			// This would need to be implemented with custom timing
-			result.Expected = int(expected)
-			result.Message = "Response time assertion not implemented yet"
-			result.Passed = true // Placeholder
-		}
+	case "json_schema":
+		assertJSONSchema(&result, responseBody(resp), assertion)
+
+	case "semantic_diff":
+		e.assertSemanticDiff(&result, responseBody(resp), assertion)
+
+	case "deprecation":
+		assertDeprecation(&result, resp.Raw().Header, assertion)
+
+	case "graphql_errors":
+		assertGraphQLErrors(&result, responseBody(resp), assertion)
+
+	case "xpath":
+		assertXPath(&result, resp.Body().Raw(), assertion)
+
+	case "response_time", "latency_budget":
+		assertResponseTime(&result, resp.RoundTripTime().Raw(), assertion)
 		
 	default:
 		result.Passed = false
@@ -1,6 +1,7 @@
 package testrunner
 
 import (
+	"context"
 	"testing"
 
 	"api-test-framework/internal/models"
@@ -37,7 +38,7 @@ func TestHTTPExpectExecutor_ExecuteTest(t *testing.T) {
 	}
 
 	// Execute the test
-	result := executor.ExecuteTest(testSpec)
+	result := executor.ExecuteTest(context.Background(), testSpec)
 
 	// Verify the result
 	if result.Status != "PASSED" {
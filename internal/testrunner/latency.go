@@ -0,0 +1,80 @@
+package testrunner
+
+import (
+	"fmt"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// Latency budget levels, from least to most specific
+const (
+	BudgetLevelService = "service"
+	BudgetLevelSuite   = "suite"
+	BudgetLevelTest    = "test"
+)
+
+// ApplyLatencyBudget adds the implicit latency_budget assertion for the most
+// specific budget defined for the test. A test-level budget of 0 disables the
+// inherited budgets.
+func ApplyLatencyBudget(testSpec *models.TestSpec, serviceBudgetMs, suiteBudgetMs int) {
+	budget, level := serviceBudgetMs, BudgetLevelService
+	if suiteBudgetMs > 0 {
+		budget, level = suiteBudgetMs, BudgetLevelSuite
+	}
+	if testSpec.LatencyBudgetMs != nil {
+		budget, level = *testSpec.LatencyBudgetMs, BudgetLevelTest
+	}
+	if budget <= 0 {
+		return
+	}
+
+	testSpec.Assertions = append(testSpec.Assertions, models.AssertionSpec{
+		Type:     "latency_budget",
+		Matcher:  level,
+		Expected: budget,
+	})
+}
+
+// assertResponseTime checks the round-trip time of a response against the
+// expected number of milliseconds. response_time assertions support the
+// less_than (default) and greater_than matchers; latency_budget assertions
+// record the level the budget was inherited from as their matcher.
+func assertResponseTime(result *AssertionResult, rtt time.Duration, assertion map[string]interface{}) {
+	expected, ok := expectedValue(assertion).(float64)
+	if !ok {
+		result.Passed = false
+		result.Message = fmt.Sprintf("%s assertion expects a number of milliseconds", result.Type)
+		return
+	}
+
+	actual := rtt.Milliseconds()
+	result.Expected = int64(expected)
+	result.Actual = actual
+	result.Matcher, _ = assertion["matcher"].(string)
+
+	if result.Type == "latency_budget" {
+		result.Passed = actual <= int64(expected)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Response took %d ms, exceeding the %s latency budget of %d ms", actual, result.Matcher, int64(expected))
+		}
+		return
+	}
+
+	switch result.Matcher {
+	case "", "less_than":
+		result.Matcher = "less_than"
+		result.Passed = actual < int64(expected)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected response time below %d ms, got %d ms", int64(expected), actual)
+		}
+	case "greater_than":
+		result.Passed = actual > int64(expected)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected response time above %d ms, got %d ms", int64(expected), actual)
+		}
+	default:
+		result.Passed = false
+		result.Message = fmt.Sprintf("Unknown response_time matcher: %s", result.Matcher)
+	}
+}
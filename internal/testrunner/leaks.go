@@ -0,0 +1,222 @@
+package testrunner
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// Leak is a value of a captured response resembling a secret or personal data
+type Leak struct {
+	Detector    string `json:"detector"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"` // low, medium or high
+	Path        string `json:"path"`     // dotted path of the value in the response data, e.g. body.user.ssn
+	Excerpt     string `json:"excerpt"`  // the value, masked
+}
+
+// leakDetector finds one kind of secret, either in the text of values or by
+// the name of the fields holding them
+type leakDetector struct {
+	name        string
+	description string
+	severity    string
+	pattern     *regexp.Regexp          // matches inside string values
+	fields      *regexp.Regexp          // names of fields whose non-empty values match
+	valid       func(match string) bool // rejects matches of the pattern that cannot be real
+}
+
+// builtinLeakDetectors are the detectors applied unless a configuration
+// selects some of them
+var builtinLeakDetectors = []leakDetector{
+	{name: "aws_access_key", description: "an AWS access key ID", severity: models.SeverityHigh, pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "private_key", description: "a private key", severity: models.SeverityHigh, pattern: regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`)},
+	{name: "github_token", description: "a GitHub token", severity: models.SeverityHigh, pattern: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{50,})\b`)},
+	{name: "slack_token", description: "a Slack token", severity: models.SeverityHigh, pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{name: "stripe_key", description: "a live Stripe secret key", severity: models.SeverityHigh, pattern: regexp.MustCompile(`\b[sr]k_live_[A-Za-z0-9]{20,}\b`)},
+	{name: "google_api_key", description: "a Google API key", severity: models.SeverityHigh, pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{name: "jwt", description: "a JSON Web Token", severity: models.SeverityMedium, pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{name: "ssn", description: "a US social security number", severity: models.SeverityHigh, pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), fields: regexp.MustCompile(`(?i)^(?:ssn|social_?security_?(?:number|no)?)$`), valid: validSSN},
+	{name: "mrn", description: "a medical record number", severity: models.SeverityHigh, pattern: regexp.MustCompile(`(?i)\b(?:MRN|medical record (?:number|no\.?))\s*[:#]?\s*[A-Z0-9-]{6,12}\b`), fields: regexp.MustCompile(`(?i)^(?:mrn|patient_?mrn|medical_?record_?(?:number|no|id)?)$`)},
+	{name: "credit_card", description: "a payment card number", severity: models.SeverityHigh, pattern: regexp.MustCompile(`\b(?:4\d{3}|5[1-5]\d{2}|2[2-7]\d{2}|3[47]\d{2}|6011)(?:[ -]?\d{4}){2}[ -]?\d{1,4}\b`), valid: validCardNumber},
+}
+
+// LeakDetectors lists the names of the built-in leak detectors
+func LeakDetectors() []string {
+	names := make([]string, len(builtinLeakDetectors))
+	for i, detector := range builtinLeakDetectors {
+		names[i] = detector.name
+	}
+	return names
+}
+
+// LeakDetector scans captured responses for secrets and personal data
+type LeakDetector struct {
+	detectors []leakDetector
+	allow     map[string]bool
+}
+
+// NewLeakDetector creates a detector applying the named built-in detectors,
+// all of them when names is empty, and the custom patterns by name. Values
+// listed in allow, e.g. documented test keys, are never reported.
+func NewLeakDetector(names []string, patterns map[string]string, allow []string) (*LeakDetector, error) {
+	d := &LeakDetector{allow: make(map[string]bool, len(allow))}
+	for _, value := range allow {
+		d.allow[value] = true
+	}
+
+	if len(names) == 0 {
+		d.detectors = append(d.detectors, builtinLeakDetectors...)
+	}
+	for _, name := range names {
+		found := false
+		for _, detector := range builtinLeakDetectors {
+			if detector.name == name {
+				d.detectors = append(d.detectors, detector)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown leak detector %q, expected one of: %s", name, strings.Join(LeakDetectors(), ", "))
+		}
+	}
+
+	custom := make([]string, 0, len(patterns))
+	for name := range patterns {
+		custom = append(custom, name)
+	}
+	sort.Strings(custom)
+	for _, name := range custom {
+		pattern, err := regexp.Compile(patterns[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern of leak detector %q: %v", name, err)
+		}
+		d.detectors = append(d.detectors, leakDetector{name: name, description: "a value matching the " + name + " pattern", severity: models.SeverityHigh, pattern: pattern})
+	}
+	return d, nil
+}
+
+// Detect returns the leaks in captured response data, a JSON document such
+// as {"status_code", "headers", "body"}. Each value is reported once per
+// detector.
+func (d *LeakDetector) Detect(responseData string) []Leak {
+	// Numbers keep their digits, e.g. of numeric record numbers
+	var data interface{}
+	decoder := json.NewDecoder(strings.NewReader(responseData))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		data = responseData
+	}
+
+	leaks := []Leak{}
+	seen := map[string]bool{}
+	report := func(detector leakDetector, path, value string) {
+		key := detector.name + "\x00" + value
+		if d.allow[value] || seen[key] {
+			return
+		}
+		seen[key] = true
+		leaks = append(leaks, Leak{
+			Detector:    detector.name,
+			Description: detector.description,
+			Severity:    detector.severity,
+			Path:        path,
+			Excerpt:     maskLeak(value),
+		})
+	}
+
+	var walk func(path, field string, value interface{})
+	walk = func(path, field string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(joinPath(path, key), key, v[key])
+			}
+		case []interface{}:
+			for i, item := range v {
+				walk(joinPath(path, strconv.Itoa(i)), field, item)
+			}
+		case nil, bool:
+		default:
+			text := fmt.Sprint(v)
+			for _, detector := range d.detectors {
+				if detector.fields != nil && text != "" && detector.fields.MatchString(field) {
+					report(detector, path, text)
+					continue
+				}
+				if detector.pattern == nil {
+					continue
+				}
+				for _, match := range detector.pattern.FindAllString(text, -1) {
+					if detector.valid == nil || detector.valid(match) {
+						report(detector, path, match)
+					}
+				}
+			}
+		}
+	}
+	walk("", "", data)
+	return leaks
+}
+
+// joinPath appends a key to a dotted path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// maskLeak hides all but the edges of a leaked value, so findings do not
+// spread the secrets they report
+func maskLeak(value string) string {
+	runes := []rune(value)
+	switch {
+	case len(runes) <= 4:
+		return strings.Repeat("*", len(runes))
+	case len(runes) <= 12:
+		return strings.Repeat("*", len(runes)-2) + string(runes[len(runes)-2:])
+	}
+	return string(runes[:4]) + strings.Repeat("*", len(runes)-8) + string(runes[len(runes)-4:])
+}
+
+// validSSN rejects numbers that are never assigned: area 000, 666 or 9xx,
+// group 00 and serial 0000
+func validSSN(match string) bool {
+	parts := strings.Split(match, "-")
+	if len(parts) != 3 {
+		return false
+	}
+	area, group, serial := parts[0], parts[1], parts[2]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validCardNumber checks the Luhn checksum of a card number
+func validCardNumber(match string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(match)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		if (len(digits)-1-i)%2 == 1 {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+	}
+	return sum%10 == 0
+}
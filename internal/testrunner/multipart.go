@@ -0,0 +1,116 @@
+package testrunner
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// FixtureLoader loads stored fixtures referenced by request bodies and multipart parts
+type FixtureLoader interface {
+	LoadFixture(fixtureID string, version int) (*models.FixtureVersion, error)
+}
+
+// WithFixtures configures the loader used to resolve fixture references
+func (e *HTTPExpectExecutor) WithFixtures(fixtures FixtureLoader) *HTTPExpectExecutor {
+	e.fixtures = fixtures
+	return e
+}
+
+// loadBodyFixture loads the fixture sent as the request body
+func (e *HTTPExpectExecutor) loadBodyFixture(ref *models.FixtureRef) (*models.FixtureVersion, error) {
+	if e.fixtures == nil {
+		return nil, fmt.Errorf("no fixture loader configured for the request body")
+	}
+	fixture, err := e.fixtures.LoadFixture(ref.FixtureID, ref.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load body fixture %s: %v", ref.FixtureID, err)
+	}
+	return fixture, nil
+}
+
+// buildMultipartBody encodes the parts as multipart/form-data and returns the
+// body together with its Content-Type header (including the boundary)
+func (e *HTTPExpectExecutor) buildMultipartBody(parts []models.MultipartPart) ([]byte, string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range parts {
+		if part.FixtureID == "" {
+			if err := writeValuePart(writer, part); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		if e.fixtures == nil {
+			return nil, "", fmt.Errorf("no fixture loader configured for part '%s'", part.Name)
+		}
+		fixture, err := e.fixtures.LoadFixture(part.FixtureID, part.Version)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to load fixture %s for part '%s': %v", part.FixtureID, part.Name, err)
+		}
+
+		fileName := part.FileName
+		if fileName == "" {
+			fileName = fixture.FileName
+		}
+		contentType := part.ContentType
+		if contentType == "" {
+			contentType = fixture.ContentType
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			escapeQuotes(part.Name), escapeQuotes(fileName)))
+		header.Set("Content-Type", contentType)
+
+		partWriter, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := partWriter.Write(fixture.Content); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
+
+// writeValuePart writes a part with a plain value. A value with a content
+// type or file name is sent with these, as curl -F "name=value;type=text/csv"
+// does.
+func writeValuePart(writer *multipart.Writer, part models.MultipartPart) error {
+	if part.ContentType == "" && part.FileName == "" {
+		return writer.WriteField(part.Name, part.Value)
+	}
+
+	disposition := fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(part.Name))
+	if part.FileName != "" {
+		disposition += fmt.Sprintf(`; filename="%s"`, escapeQuotes(part.FileName))
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", disposition)
+	if part.ContentType != "" {
+		header.Set("Content-Type", part.ContentType)
+	}
+	partWriter, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = partWriter.Write([]byte(part.Value))
+	return err
+}
+
+// escapeQuotes escapes a value for use in a quoted Content-Disposition parameter
+func escapeQuotes(s string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(s)
+}
@@ -0,0 +1,109 @@
+package testrunner
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"api-test-framework/internal/models"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// textNormalizer rewrites a string before it is compared; a nil normalizer
+// leaves strings untouched
+type textNormalizer func(string) string
+
+// unicodeForms are the Unicode normalization forms of assertion options
+var unicodeForms = map[string]norm.Form{
+	"NFC":  norm.NFC,
+	"NFD":  norm.NFD,
+	"NFKC": norm.NFKC,
+	"NFKD": norm.NFKD,
+}
+
+// newTextNormalizer returns the normalizer of normalization options. Unicode
+// normalization runs first, so compatibility forms such as NFKC turn exotic
+// spaces into plain ones before whitespace is collapsed and trimmed, and case
+// folding runs last.
+func newTextNormalizer(options *models.TextNormalization) (textNormalizer, error) {
+	if options == nil {
+		return nil, nil
+	}
+	var form *norm.Form
+	if options.Unicode != "" {
+		selected, ok := unicodeForms[strings.ToUpper(options.Unicode)]
+		if !ok {
+			return nil, fmt.Errorf("unknown Unicode normalization form %q, expected NFC, NFD, NFKC or NFKD", options.Unicode)
+		}
+		form = &selected
+	}
+
+	return func(text string) string {
+		if form != nil {
+			text = form.String(text)
+		}
+		if options.Whitespace {
+			text = collapseWhitespace(text)
+		}
+		if options.Trim {
+			text = strings.TrimSpace(text)
+		}
+		if options.CaseFold {
+			// Unicode case folding, unlike lower casing, does not depend on
+			// the locale and matches forms such as "straße" and "STRASSE"
+			text = cases.Fold().String(text)
+		}
+		return text
+	}, nil
+}
+
+// normalization returns the normalize options of an assertion, if any
+func normalization(assertion map[string]interface{}) *models.TextNormalization {
+	raw, ok := assertion["normalize"]
+	if !ok || raw == nil {
+		return nil
+	}
+	var options models.TextNormalization
+	if data, err := json.Marshal(raw); err == nil {
+		_ = json.Unmarshal(data, &options)
+	}
+	return &options
+}
+
+// text normalizes a string
+func (normalize textNormalizer) text(text string) string {
+	if normalize == nil {
+		return text
+	}
+	return normalize(text)
+}
+
+// value normalizes a value if it is a string, and returns other values as is
+func (normalize textNormalizer) value(value interface{}) interface{} {
+	if text, ok := value.(string); ok {
+		return normalize.text(text)
+	}
+	return value
+}
+
+// collapseWhitespace replaces every run of whitespace with a single space
+func collapseWhitespace(text string) string {
+	var builder strings.Builder
+	builder.Grow(len(text))
+	space := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			if !space {
+				builder.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
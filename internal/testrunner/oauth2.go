@@ -0,0 +1,167 @@
+package testrunner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenExpirySkew is subtracted from the token lifetime so cached tokens are
+// refreshed shortly before the authorization server considers them expired
+const tokenExpirySkew = 30 * time.Second
+
+// OAuth2TokenProvider acquires OAuth2 access tokens for services and caches
+// them in Redis so every test in a run does not hit the token endpoint
+type OAuth2TokenProvider struct {
+	redisClient *redis.Client
+	httpClient  *http.Client
+}
+
+// tokenResponse represents the token endpoint response defined by RFC 6749
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// NewOAuth2TokenProvider creates a new token provider. The Redis client is
+// optional; without it tokens are requested on every call.
+func NewOAuth2TokenProvider(redisClient *redis.Client) *OAuth2TokenProvider {
+	return &OAuth2TokenProvider{
+		redisClient: redisClient,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// Token returns an access token for the given service, using the cached token
+// when one is available
+func (p *OAuth2TokenProvider) Token(ctx context.Context, serviceID string, authConfig models.AuthConfig) (string, error) {
+	if p.redisClient != nil {
+		token, err := p.redisClient.Get(ctx, tokenCacheKey(serviceID, authConfig)).Result()
+		if err == nil && token != "" {
+			return token, nil
+		}
+	}
+
+	token, expiresIn, err := p.requestToken(ctx, authConfig)
+	if err != nil {
+		return "", err
+	}
+
+	if p.redisClient != nil && expiresIn > tokenExpirySkew {
+		p.redisClient.Set(ctx, tokenCacheKey(serviceID, authConfig), token, expiresIn-tokenExpirySkew)
+	}
+
+	return token, nil
+}
+
+// Invalidate drops the cached token for a service and auth configuration so
+// the next call to Token requests a fresh one
+func (p *OAuth2TokenProvider) Invalidate(ctx context.Context, serviceID string, authConfig models.AuthConfig) {
+	if p.redisClient != nil {
+		p.redisClient.Del(ctx, tokenCacheKey(serviceID, authConfig))
+	}
+}
+
+// requestToken performs the client credentials or password grant against the
+// configured token URL
+func (p *OAuth2TokenProvider) requestToken(ctx context.Context, authConfig models.AuthConfig) (string, time.Duration, error) {
+	if authConfig.TokenURL == "" {
+		return "", 0, fmt.Errorf("token_url is required for oauth2 authentication")
+	}
+
+	grantType := authConfig.Extra["grant_type"]
+	if grantType == "" {
+		grantType = "client_credentials"
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", grantType)
+	switch grantType {
+	case "client_credentials":
+	case "password":
+		form.Set("username", authConfig.Username)
+		form.Set("password", authConfig.Password)
+	default:
+		return "", 0, fmt.Errorf("unsupported oauth2 grant type: %s", grantType)
+	}
+	if scope := authConfig.Extra["scope"]; scope != "" {
+		form.Set("scope", scope)
+	}
+	if audience := authConfig.Extra["audience"]; audience != "" {
+		form.Set("audience", audience)
+	}
+
+	// Client credentials go in the body unless the server requires HTTP Basic
+	useBasic := authConfig.Extra["client_auth"] == "basic"
+	if !useBasic {
+		form.Set("client_id", authConfig.ClientID)
+		form.Set("client_secret", authConfig.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authConfig.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to build token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasic {
+		req.SetBasicAuth(url.QueryEscape(authConfig.ClientID), url.QueryEscape(authConfig.ClientSecret))
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("token request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", 0, fmt.Errorf("failed to decode token response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return "", 0, fmt.Errorf("token response did not contain an access_token")
+	}
+
+	return token.AccessToken, time.Duration(token.ExpiresIn) * time.Second, nil
+}
+
+// tokenCacheKey returns the Redis key holding the cached token of a service
+// for an auth configuration. It hashes every parameter of the token request,
+// so configurations asking for another identity, scope or audience never
+// share a token, and a token acquired with edited credentials replaces the
+// cached one.
+func tokenCacheKey(serviceID string, authConfig models.AuthConfig) string {
+	hash := sha256.New()
+	for _, parameter := range []string{
+		authConfig.TokenURL,
+		authConfig.Extra["grant_type"],
+		authConfig.ClientID,
+		authConfig.ClientSecret,
+		authConfig.Extra["client_auth"],
+		authConfig.Username,
+		authConfig.Password,
+		authConfig.Extra["scope"],
+		authConfig.Extra["audience"],
+	} {
+		// Length-prefixed, so no two parameter lists hash the same input
+		fmt.Fprintf(hash, "%d:%s;", len(parameter), parameter)
+	}
+	return fmt.Sprintf("oauth2:token:%s:%s", serviceID, hex.EncodeToString(hash.Sum(nil)))
+}
@@ -0,0 +1,98 @@
+package testrunner
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// defaultPollInterval is the delay between two requests of a poll_until test
+	defaultPollInterval = time.Second
+	// defaultPollTimeout is how long a poll_until test waits for its condition,
+	// within the deadline of the test
+	defaultPollTimeout = 20 * time.Second
+)
+
+// executePolled sends the request of the test spec until its poll_until
+// condition holds and returns the result of the final response, whose
+// assertions decide the outcome. Transient failures (network errors, 5xx)
+// keep polling, other failures end it. A condition still unmet when the
+// timeout expires fails the test with a timeout.
+func (e *HTTPExpectExecutor) executePolled(ctx context.Context, testSpec *models.TestSpec) *TestResult {
+	poll := testSpec.PollUntil
+	if poll == nil {
+		return e.executeRequest(ctx, testSpec)
+	}
+
+	interval := time.Duration(poll.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	timeout := time.Duration(poll.TimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultPollTimeout
+	}
+
+	start := time.Now()
+	for polls := 1; ; polls++ {
+		result := e.executeRequest(ctx, testSpec)
+		result.Polls = polls
+		result.StartTime = start
+
+		met, reason := pollConditionMet(poll, result)
+		transient := result.FailureType == "" || result.FailureType == FailureAssertion || Retryable(result.FailureType, nil)
+		if met || !transient || ctx.Err() != nil {
+			result.Duration = time.Since(start)
+			return result
+		}
+
+		if time.Since(start)+interval > timeout {
+			result.Status = "FAILED"
+			result.ErrorMessage = fmt.Sprintf("poll_until condition not met after %d requests within %v: %s", polls, timeout, reason)
+			result.FailureType = FailureTimeout
+			result.Duration = time.Since(start)
+			return result
+		}
+
+		select {
+		case <-ctx.Done():
+			result.Duration = time.Since(start)
+			return result
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pollConditionMet reports whether the response of a result satisfies a poll
+// condition, or why not
+func pollConditionMet(poll *models.PollCondition, result *TestResult) (bool, string) {
+	if result.ResponseData == "" {
+		return false, result.ErrorMessage
+	}
+
+	response := gjson.Parse(result.ResponseData)
+	if poll.StatusCode != 0 {
+		if status := response.Get("status_code").Int(); status != int64(poll.StatusCode) {
+			return false, fmt.Sprintf("status %d, expected %d", status, poll.StatusCode)
+		}
+	}
+	if poll.Path == "" {
+		return true, ""
+	}
+
+	matcher := poll.Matcher
+	if matcher == "" {
+		matcher = "exists"
+		if poll.Expected != nil {
+			matcher = "equals"
+		}
+	}
+	condition := AssertionResult{Type: "poll_until", Path: poll.Path, Matcher: matcher}
+	assertValue(&condition, gjson.Get(response.Get("body").Raw, poll.Path), matcher, poll.Expected, nil)
+	return condition.Passed, condition.Message
+}
@@ -0,0 +1,166 @@
+package testrunner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	neturl "net/url"
+	"sort"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// RequestPreview is the request an executor would send for a test spec,
+// with variables substituted and authentication and API version applied
+type RequestPreview struct {
+	Method              string            `json:"method"`
+	URL                 string            `json:"url"`
+	Headers             map[string]string `json:"headers"`
+	Body                interface{}       `json:"body,omitempty"`                 // decoded JSON body, or the body as text
+	UnresolvedVariables []string          `json:"unresolved_variables,omitempty"` // placeholders no variable matched
+	Credential          string            `json:"credential,omitempty"`           // header or query parameter carrying the credentials
+}
+
+// Previewer is implemented by executors that can resolve the request of a
+// test spec without sending it
+type Previewer interface {
+	PreviewRequest(ctx context.Context, testSpec *models.TestSpec) (*RequestPreview, error)
+}
+
+// PreviewRequest resolves the request the executor sends for a test spec:
+// the URL against the base URL with the API version, the headers with the
+// credentials and the encoded body. OAuth2 tokens are acquired as for a
+// request; nothing is sent to the service itself.
+func (e *HTTPExpectExecutor) PreviewRequest(ctx context.Context, testSpec *models.TestSpec) (*RequestPreview, error) {
+	preview := &RequestPreview{
+		Method:              testSpec.Request.Method,
+		Headers:             map[string]string{},
+		UnresolvedVariables: unresolvedVariables(testSpec.Request),
+	}
+
+	path, rawQuery, origin := splitRequestURL(e.versionedURL(testSpec.Request.URL))
+	if origin == "" {
+		origin = strings.TrimSuffix(e.baseURL, "/")
+	}
+	// Parameters the executor adds follow the query string of the test
+	query := neturl.Values{}
+
+	headers := make(map[string]interface{}, len(testSpec.Request.Headers))
+	for name, value := range testSpec.Request.Headers {
+		preview.Headers[name] = value
+		headers[name] = value
+	}
+
+	if e.apiVersion != "" {
+		switch e.versioning.Strategy {
+		case "header":
+			name := e.versioning.HeaderName
+			if name == "" {
+				name = defaultVersionHeader
+			}
+			if !hasHeader(testSpec.Request.Headers, name) {
+				preview.Headers[name] = e.apiVersion
+			}
+		case "query":
+			param := e.versioning.QueryParam
+			if param == "" {
+				param = defaultVersionQuery
+			}
+			query.Add(param, e.apiVersion)
+		}
+	}
+
+	switch {
+	case len(testSpec.Request.Multipart) > 0:
+		body, contentType, err := e.buildMultipartBody(testSpec.Request.Multipart)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build multipart body: %v", err)
+		}
+		preview.Headers["Content-Type"] = contentType
+		preview.Body = string(body)
+	case testSpec.Request.BodyFixture != nil:
+		fixture, err := e.loadBodyFixture(testSpec.Request.BodyFixture)
+		if err != nil {
+			return nil, err
+		}
+		if !hasHeader(testSpec.Request.Headers, "Content-Type") {
+			preview.Headers["Content-Type"] = fixture.ContentType
+		}
+		preview.Body = string(fixture.Content)
+	case testSpec.Request.Body != nil:
+		if text, isText := testSpec.Request.Body.(string); isText && isXMLContentType(headers) {
+			preview.Body = text
+		} else {
+			preview.Body = testSpec.Request.Body
+			if !hasHeader(testSpec.Request.Headers, "Content-Type") {
+				preview.Headers["Content-Type"] = "application/json; charset=utf-8"
+			}
+		}
+	}
+
+	authConfig := e.effectiveAuth(&testSpec.Request)
+	credential, err := authCredential(ctx, authConfig, e.tokenProvider, e.serviceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply authentication: %v", err)
+	}
+	if credential.name != "" {
+		preview.Credential = credential.name
+		if credential.inQuery {
+			query.Add(credential.name, credential.value)
+		} else {
+			preview.Headers[credential.name] = credential.value
+		}
+	}
+
+	preview.URL = origin + path
+	if encoded := query.Encode(); encoded != "" {
+		if rawQuery != "" {
+			rawQuery += "&"
+		}
+		rawQuery += encoded
+	}
+	if rawQuery != "" {
+		preview.URL += "?" + rawQuery
+	}
+	return preview, nil
+}
+
+// PreviewRequest implements Previewer for the HTTP request carrying the operation
+func (e *GraphQLExecutor) PreviewRequest(ctx context.Context, testSpec *models.TestSpec) (*RequestPreview, error) {
+	if testSpec.Request.GraphQL == nil || strings.TrimSpace(testSpec.Request.GraphQL.Query) == "" {
+		return nil, fmt.Errorf("GraphQL tests require request.graphql.query")
+	}
+	return e.http.PreviewRequest(ctx, graphQLHTTPSpec(testSpec))
+}
+
+// PreviewRequest implements Previewer for the HTTP request carrying the envelope
+func (e *SOAPExecutor) PreviewRequest(ctx context.Context, testSpec *models.TestSpec) (*RequestPreview, error) {
+	envelope, _ := testSpec.Request.Body.(string)
+	return e.http.PreviewRequest(ctx, soapHTTPSpec(testSpec, envelope))
+}
+
+// unresolvedVariables lists the placeholders left in a request after
+// substitution
+func unresolvedVariables(request models.RequestSpec) []string {
+	return Placeholders(request)
+}
+
+// Placeholders lists the names of the {{name}} placeholders anywhere in a
+// value such as a test spec, sorted by name
+func Placeholders(value interface{}) []string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, match := range variablePattern.FindAllStringSubmatch(string(data), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
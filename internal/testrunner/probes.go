@@ -0,0 +1,176 @@
+package testrunner
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// probeLibrary holds the tricky payloads available as {{$probe category n}},
+// by category. NoSQL probes include operator objects, sent as JSON when the
+// placeholder is a whole body value.
+var probeLibrary = map[string][]interface{}{
+	"emoji": {
+		"😀",
+		"👩‍👩‍👧‍👦", // family joined with zero-width joiners
+		"🏳️‍🌈",    // flag sequence with a variation selector
+		"👍🏽",      // skin tone modifier
+		"𝕳𝖊𝖑𝖑𝖔 🌍", // characters outside the Basic Multilingual Plane
+	},
+	"rtl": {
+		"مرحبا بالعالم",
+		"שלום עולם",
+		"\u202Egnp.exe",         // right-to-left override disguising a file name
+		"abc\u200Fdef\u200E",    // right-to-left and left-to-right marks
+		"English עברית العربية", // mixed directions
+	},
+	"unicode": {
+		"e\u0301", // decomposed é, a combining accent
+		"zero\u200Bwidth\u200Dspace",
+		"\uFEFFbom", // byte order mark
+		"Ａｄｍｉｎ",     // full-width letters that NFKC folds to ASCII
+		"Z\u0324\u0354\u0367\u0311\u0313a\u0308\u0356\u032D\u0308\u0307lgo", // stacked combining marks
+		"null\u0000byte",
+		"ǅǈǋ İı ß", // letters whose case mapping changes their length
+	},
+	"sql": {
+		"' OR '1'='1",
+		"' OR 1=1 --",
+		"'; DROP TABLE users; --",
+		"1 UNION SELECT NULL, NULL --",
+		"\" OR \"\"=\"",
+		"admin'/*",
+	},
+	"nosql": {
+		map[string]interface{}{"$ne": nil},
+		map[string]interface{}{"$gt": ""},
+		map[string]interface{}{"$regex": ".*"},
+		`{"$where": "return true"}`,
+		"'; return true; var x='",
+	},
+	"xss": {
+		"<script>alert(1)</script>",
+		`"><img src=x onerror=alert(1)>`,
+		"<svg/onload=alert(1)>",
+		`'><iframe src="javascript:alert(1)"></iframe>`,
+		"javascript:alert(1)",
+	},
+}
+
+// generateProbe returns a probe of the library, the first of its category
+// unless an index is given: {{$probe xss}} or {{$probe sql 2}}
+func generateProbe(args []string) (interface{}, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("probe takes a category and an optional index")
+	}
+	probes, ok := probeLibrary[args[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown probe category %q, expected one of: %s", args[0], strings.Join(ProbeCategories(), ", "))
+	}
+	index := 0
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 || n >= len(probes) {
+			return nil, fmt.Errorf("probe index %q out of range, %s has %d probes", args[1], args[0], len(probes))
+		}
+		index = n
+	}
+	return probes[index], nil
+}
+
+// generateProbes returns all the probes of a category as an array:
+// {{$probes emoji}}
+func generateProbes(args []string) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("probes takes a category")
+	}
+	probes, ok := probeLibrary[args[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown probe category %q, expected one of: %s", args[0], strings.Join(ProbeCategories(), ", "))
+	}
+	return append([]interface{}{}, probes...), nil
+}
+
+// ProbeCategories lists the categories of the probe library
+func ProbeCategories() []string {
+	categories := make([]string, 0, len(probeLibrary))
+	for category := range probeLibrary {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// assertProbeSafe checks the "probe_safe" assertion: the response to a
+// request carrying probes must not be a server error, and probes containing
+// markup must not come back in the body unescaped. The probes sent are found
+// in the strings of the request, so they can be combined with other text.
+func assertProbeSafe(result *AssertionResult, statusCode int, body string, request map[string]interface{}) {
+	result.Matcher = "probe_safe"
+	result.Actual = statusCode
+	if statusCode >= 500 {
+		result.Passed = false
+		result.Message = fmt.Sprintf("Probe payload caused a server error with status %d", statusCode)
+		return
+	}
+
+	sent := requestStrings(request)
+	for _, category := range ProbeCategories() {
+		for _, probe := range probeLibrary[category] {
+			text, ok := probe.(string)
+			if !ok || !strings.ContainsAny(text, "<>") || !containsAny(sent, text) {
+				continue
+			}
+			if strings.Contains(body, text) {
+				result.Passed = false
+				result.Expected = "escaped " + category + " probe"
+				result.Actual = text
+				result.Message = fmt.Sprintf("Response reflects the %s probe %q unescaped", category, text)
+				return
+			}
+		}
+	}
+}
+
+// requestStrings collects the strings of a request: its URL, decoded as
+// well, header values and the strings of its body
+func requestStrings(request map[string]interface{}) []string {
+	var texts []string
+	var collect func(value interface{})
+	collect = func(value interface{}) {
+		switch v := value.(type) {
+		case string:
+			texts = append(texts, v)
+		case map[string]interface{}:
+			for key, item := range v {
+				texts = append(texts, key)
+				collect(item)
+			}
+		case []interface{}:
+			for _, item := range v {
+				collect(item)
+			}
+		}
+	}
+	if rawURL, ok := request["url"].(string); ok {
+		if decoded, err := url.QueryUnescape(rawURL); err == nil {
+			texts = append(texts, decoded)
+		}
+	}
+	collect(request["url"])
+	collect(request["headers"])
+	collect(request["body"])
+	return texts
+}
+
+// containsAny reports whether any of texts contains substr
+func containsAny(texts []string, substr string) bool {
+	for _, text := range texts {
+		if strings.Contains(text, substr) {
+			return true
+		}
+	}
+	return false
+}
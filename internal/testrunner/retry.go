@@ -0,0 +1,76 @@
+package testrunner
+
+import (
+	"context"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+const (
+	// maxRetryAttempts caps the attempts of a retry policy
+	maxRetryAttempts = 10
+	// defaultRetryBackoff is the delay before the first retry when a policy sets none
+	defaultRetryBackoff = 500 * time.Millisecond
+	// maxRetryBackoff caps the delay between two attempts
+	maxRetryBackoff = 30 * time.Second
+)
+
+// defaultRetryOn are the failure categories retried when a policy lists none:
+// transient network failures and server errors
+var defaultRetryOn = []string{CategoryNetwork, Category5xx}
+
+// ExecuteWithRetry executes a test spec and retries it according to policy
+// while it fails with a retryable failure type. The delay doubles after every
+// attempt (or grows by the policy's multiplier), and waiting stops when ctx
+// is done. The returned result is that of the last attempt, with Attempts set.
+func ExecuteWithRetry(ctx context.Context, executor Executor, testSpec *models.TestSpec, policy models.RetryPolicy) *TestResult {
+	attempts := policy.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > maxRetryAttempts {
+		attempts = maxRetryAttempts
+	}
+	backoff := time.Duration(policy.BackoffMs) * time.Millisecond
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	multiplier := policy.BackoffMultiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	var result *TestResult
+	for attempt := 1; ; attempt++ {
+		result = executor.ExecuteTest(ctx, testSpec)
+		result.Attempts = attempt
+		if result.Status != "FAILED" || attempt >= attempts || !Retryable(result.FailureType, policy.RetryOn) {
+			return result
+		}
+
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(backoff):
+		}
+		backoff = time.Duration(float64(backoff) * multiplier)
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// Retryable reports whether a failure type matches one of the retry
+// categories; without categories network failures and 5xx responses are retried
+func Retryable(failureType string, retryOn []string) bool {
+	if len(retryOn) == 0 {
+		retryOn = defaultRetryOn
+	}
+	for _, category := range retryOn {
+		if FailureInCategory(failureType, category) {
+			return true
+		}
+	}
+	return false
+}
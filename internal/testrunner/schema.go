@@ -0,0 +1,47 @@
+package testrunner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// maxSchemaErrors limits the validation errors reported in an assertion message
+const maxSchemaErrors = 3
+
+// assertJSONSchema validates the response body against the JSON schema given
+// as the expected value of a json_schema assertion
+func assertJSONSchema(result *AssertionResult, body interface{}, assertion map[string]interface{}) {
+	schema, ok := expectedValue(assertion).(map[string]interface{})
+	if !ok {
+		result.Passed = false
+		result.Message = "json_schema assertion expects a JSON schema object"
+		return
+	}
+	result.Path = "body"
+
+	validation, err := gojsonschema.Validate(gojsonschema.NewGoLoader(schema), gojsonschema.NewGoLoader(body))
+	if err != nil {
+		result.Passed = false
+		result.Message = fmt.Sprintf("Invalid JSON schema: %v", err)
+		return
+	}
+
+	result.Passed = validation.Valid()
+	if result.Passed {
+		return
+	}
+
+	errs := validation.Errors()
+	messages := make([]string, 0, maxSchemaErrors)
+	for i, schemaErr := range errs {
+		if i == maxSchemaErrors {
+			messages = append(messages, fmt.Sprintf("and %d more", len(errs)-maxSchemaErrors))
+			break
+		}
+		messages = append(messages, schemaErr.String())
+	}
+	result.Actual = messages
+	result.Message = "Response body does not match schema: " + strings.Join(messages, "; ")
+}
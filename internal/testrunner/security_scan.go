@@ -0,0 +1,224 @@
+package testrunner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// Checks of security scans
+const (
+	CheckVerbTampering        = "verb_tampering"
+	CheckMissingAuth          = "missing_auth"
+	CheckOversizedPayload     = "oversized_payload"
+	CheckContentTypeConfusion = "content_type_confusion"
+	CheckSecretLeak           = "secret_leak" // reported by the leak detector, see LeakDetector
+)
+
+// Settings of the scan requests
+const (
+	tamperMethod      = "TAMPER" // a method no server should accept
+	oversizedBodySize = 2 << 20  // bytes of the oversized payload
+	confusedMediaType = "text/plain"
+)
+
+// credentialHeaders are the request headers removed to send a request without credentials
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-API-Key", "X-Auth-Token"}
+
+// SecurityScanner is implemented by executors that can scan the endpoint of
+// a test spec for common weaknesses
+type SecurityScanner interface {
+	ScanEndpoint(ctx context.Context, testSpec *models.TestSpec) []models.SecurityFinding
+}
+
+// ScanEndpoint runs a battery of low-risk checks against the endpoint of a
+// test spec: unexpected methods, the request without credentials, an
+// oversized body and a JSON body declared as text/plain. The checks send
+// variations of the test's own request, never destructive methods of their
+// own, and ignore its assertions. Findings describe the responses that
+// suggest a weakness; a check without a response reports nothing.
+func (e *HTTPExpectExecutor) ScanEndpoint(ctx context.Context, testSpec *models.TestSpec) []models.SecurityFinding {
+	request := testSpec.Request
+	method := strings.ToUpper(request.Method)
+	findings := []models.SecurityFinding{}
+	report := func(check, severity, method string, statusCode int, format string, args ...interface{}) {
+		findings = append(findings, models.SecurityFinding{
+			Check:      check,
+			Severity:   severity,
+			Method:     method,
+			URL:        request.URL,
+			StatusCode: statusCode,
+			Message:    fmt.Sprintf(format, args...),
+		})
+	}
+
+	// Verb tampering: TRACE enables cross-site tracing, and an endpoint
+	// answering a made-up method may bypass access rules bound to methods
+	if status := e.scanRequest(ctx, http.MethodTrace, request.URL, request.Headers, nil, "", e.effectiveAuth(&request)); isSuccess(status) {
+		report(CheckVerbTampering, models.SeverityMedium, http.MethodTrace, status, "TRACE is enabled (status %d), which exposes request headers to cross-site tracing", status)
+	}
+	switch status := e.scanRequest(ctx, tamperMethod, request.URL, request.Headers, nil, "", e.effectiveAuth(&request)); {
+	case isSuccess(status):
+		report(CheckVerbTampering, models.SeverityMedium, tamperMethod, status, "the unknown method %s is accepted (status %d) instead of 405 or 501, so rules bound to methods may be bypassed", tamperMethod, status)
+	case status >= 500 && status != http.StatusNotImplemented:
+		report(CheckVerbTampering, models.SeverityLow, tamperMethod, status, "the unknown method %s causes a server error (status %d) instead of 405 or 501", tamperMethod, status)
+	}
+
+	body, contentType, encodable := scanBody(request)
+
+	// Missing authentication: the request without the credentials of the
+	// service or the test must be rejected
+	if auth := e.effectiveAuth(&request); auth.Type != "" && auth.Type != "none" || hasCredentialHeader(request.Headers) {
+		headers := mergeHeaders(nil, request.Headers)
+		credentials := append([]string{auth.KeyName}, credentialHeaders...)
+		for name := range headers {
+			for _, credential := range credentials {
+				if credential != "" && strings.EqualFold(name, credential) {
+					delete(headers, name)
+				}
+			}
+		}
+		switch status := e.scanRequest(ctx, method, request.URL, headers, body, contentType, models.AuthConfig{Type: "none"}); {
+		case isSuccess(status):
+			report(CheckMissingAuth, models.SeverityHigh, method, status, "the request succeeds without credentials (status %d) instead of 401 or 403", status)
+		case status >= 500:
+			report(CheckMissingAuth, models.SeverityLow, method, status, "the request without credentials causes a server error (status %d) instead of 401 or 403", status)
+		}
+	}
+
+	// Only requests carrying a body are checked with a different one
+	if !encodable || method != http.MethodPost && method != http.MethodPut && method != http.MethodPatch {
+		return findings
+	}
+
+	// Oversized payloads must be refused, with 413 ideally
+	oversized, _ := json.Marshal(oversizedBody(request.Body))
+	switch status := e.scanRequest(ctx, method, request.URL, request.Headers, oversized, "application/json", e.effectiveAuth(&request)); {
+	case isSuccess(status):
+		report(CheckOversizedPayload, models.SeverityLow, method, status, "a body of %d MB is accepted (status %d), the endpoint has no request size limit", oversizedBodySize>>20, status)
+	case status >= 500:
+		report(CheckOversizedPayload, models.SeverityMedium, method, status, "a body of %d MB causes a server error (status %d) instead of 413", oversizedBodySize>>20, status)
+	}
+
+	// Content-type confusion: JSON declared as text/plain can be sent
+	// cross-site by browsers without a CORS preflight
+	if contentType == "application/json" {
+		switch status := e.scanRequest(ctx, method, request.URL, request.Headers, body, confusedMediaType, e.effectiveAuth(&request)); {
+		case isSuccess(status):
+			report(CheckContentTypeConfusion, models.SeverityLow, method, status, "a JSON body declared as %s is accepted (status %d) instead of 415, so browsers can send it cross-site without a CORS preflight", confusedMediaType, status)
+		case status >= 500:
+			report(CheckContentTypeConfusion, models.SeverityMedium, method, status, "a JSON body declared as %s causes a server error (status %d) instead of 415", confusedMediaType, status)
+		}
+	}
+	return findings
+}
+
+// scanRequest sends a request of a scan with the given credentials and
+// returns the status of the response, 0 without a response. The body is sent
+// as it is; a content type replaces the one of the headers.
+func (e *HTTPExpectExecutor) scanRequest(ctx context.Context, method, rawURL string, headers map[string]string, body []byte, contentType string, authConfig models.AuthConfig) int {
+	failures := &requestFailures{}
+	path, rawQuery, origin := splitRequestURL(e.versionedURL(rawURL))
+	req := e.client.Request(method, path).WithAssertionHandler(failures).WithContext(ctx)
+	if origin != "" {
+		req = req.WithURL(origin)
+	}
+	if rawQuery != "" {
+		req = req.WithQueryString(rawQuery)
+	}
+
+	requestHeaders := make(map[string]interface{}, len(headers))
+	for name, value := range headers {
+		if contentType != "" && strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+		req = req.WithHeader(name, value)
+		requestHeaders[name] = value
+	}
+	req = e.applyAPIVersion(req, requestHeaders)
+	req = applyTraceParent(ctx, req, requestHeaders)
+	if contentType != "" {
+		req = req.WithHeader("Content-Type", contentType)
+	}
+	if body != nil {
+		req = req.WithBytes(body)
+	}
+
+	req, err := e.applyAuth(ctx, req, authConfig)
+	if err != nil {
+		e.logger.Debug("failed to authenticate security scan request", "method", method, "url", rawURL, "error", err)
+		return 0
+	}
+	resp := req.Expect()
+	if resp.Raw() == nil {
+		e.logger.Debug("security scan request failed", "method", method, "url", rawURL, "error", failures.err)
+		return 0
+	}
+	e.logger.Debug("sent security scan request", "method", method, "url", rawURL, "status_code", resp.Raw().StatusCode)
+	return resp.Raw().StatusCode
+}
+
+// scanBody encodes the body of a request the way it is sent, with the
+// content type of JSON bodies; XML documents keep the content type of the
+// headers. Multipart and fixture bodies are not encoded, so the body checks
+// skip them.
+func scanBody(request models.RequestSpec) ([]byte, string, bool) {
+	if len(request.Multipart) > 0 || request.BodyFixture != nil {
+		return nil, "", false
+	}
+	if text, isText := request.Body.(string); isText && isXMLContentType(headerValues(request.Headers)) {
+		return []byte(text), "", true
+	}
+	if request.Body == nil {
+		return nil, "", true
+	}
+	encoded, err := json.Marshal(request.Body)
+	if err != nil {
+		return nil, "", false
+	}
+	return encoded, "application/json", true
+}
+
+// oversizedBody pads the body of a request beyond the usual size limits,
+// keeping the fields of an object body
+func oversizedBody(body interface{}) interface{} {
+	padding := strings.Repeat(generatedFill, oversizedBodySize/len(generatedFill)+1)[:oversizedBodySize]
+	object, ok := body.(map[string]interface{})
+	if !ok {
+		return padding
+	}
+	padded := make(map[string]interface{}, len(object)+1)
+	for key, value := range object {
+		padded[key] = value
+	}
+	padded["scan_padding"] = padding
+	return padded
+}
+
+// hasCredentialHeader reports whether request headers carry credentials
+func hasCredentialHeader(headers map[string]string) bool {
+	for _, name := range credentialHeaders {
+		if hasHeader(headers, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerValues converts request headers to the form the executor passes around
+func headerValues(headers map[string]string) map[string]interface{} {
+	values := make(map[string]interface{}, len(headers))
+	for name, value := range headers {
+		values[name] = value
+	}
+	return values
+}
+
+// isSuccess reports whether a scan request got a 2xx response
+func isSuccess(status int) bool {
+	return status >= 200 && status < 300
+}
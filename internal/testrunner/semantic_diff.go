@@ -0,0 +1,258 @@
+package testrunner
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"api-test-framework/internal/models"
+
+	"github.com/tidwall/gjson"
+)
+
+// maxDiffDifferences limits the differences reported by a semantic_diff assertion
+const maxDiffDifferences = 5
+
+// assertSemanticDiff compares the response body, or the value at the
+// assertion path within it, with an example: the expected value or a stored
+// fixture. Unlike equals it compares whole documents, and its tolerance can
+// ignore array order, small numeric differences, extra fields and paths.
+func (e *HTTPExpectExecutor) assertSemanticDiff(result *AssertionResult, body interface{}, assertion map[string]interface{}) {
+	var spec models.AssertionSpec
+	if data, err := json.Marshal(assertion); err == nil {
+		_ = json.Unmarshal(data, &spec)
+	}
+
+	example, err := e.diffExample(spec)
+	if err != nil {
+		result.Passed = false
+		result.Message = err.Error()
+		return
+	}
+	result.Expected = example
+	if spec.Fixture != nil {
+		// The example may be large and is stored with the fixture already
+		result.Expected = fmt.Sprintf("fixture %s (version %d)", spec.Fixture.FixtureID, spec.Fixture.Version)
+	}
+
+	actual := body
+	result.Path = "body"
+	if spec.Path != "" {
+		result.Path = spec.Path
+		data, _ := json.Marshal(body)
+		value := gjson.GetBytes(data, spec.Path)
+		if !value.Exists() {
+			result.Passed = false
+			result.Message = fmt.Sprintf("JSON path '%s' does not exist", spec.Path)
+			return
+		}
+		actual = value.Value()
+	}
+
+	tolerance := models.DiffTolerance{}
+	if spec.Tolerance != nil {
+		tolerance = *spec.Tolerance
+	}
+	differences := DiffValues(example, actual, tolerance)
+
+	result.Passed = len(differences) == 0
+	if result.Passed {
+		return
+	}
+	reported := differences
+	if len(reported) > maxDiffDifferences {
+		reported = append(reported[:maxDiffDifferences:maxDiffDifferences], fmt.Sprintf("and %d more", len(differences)-maxDiffDifferences))
+	}
+	result.Actual = reported
+	result.Message = "Response differs from the example: " + strings.Join(reported, "; ")
+}
+
+// diffExample returns the example of a semantic_diff assertion
+func (e *HTTPExpectExecutor) diffExample(spec models.AssertionSpec) (interface{}, error) {
+	if spec.Fixture == nil {
+		if spec.Expected == nil {
+			return nil, fmt.Errorf("semantic_diff assertion expects an example as expected value or fixture")
+		}
+		return spec.Expected, nil
+	}
+	if e.fixtures == nil {
+		return nil, fmt.Errorf("no fixture loader configured for the example")
+	}
+	fixture, err := e.fixtures.LoadFixture(spec.Fixture.FixtureID, spec.Fixture.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load example fixture %s: %v", spec.Fixture.FixtureID, err)
+	}
+	var example interface{}
+	if err := json.Unmarshal(fixture.Content, &example); err != nil {
+		return nil, fmt.Errorf("example fixture %s is not valid JSON: %v", spec.Fixture.FixtureID, err)
+	}
+	return example, nil
+}
+
+// DiffValues lists the differences between an example and a value as a
+// semantic_diff assertion reports them, each prefixed with its dotted path
+func DiffValues(expected, actual interface{}, tolerance models.DiffTolerance) []string {
+	diff := &semanticDiff{tolerance: tolerance}
+	diff.compare(expected, actual, nil)
+	return diff.differences
+}
+
+// semanticDiff collects the differences between an example and a value
+type semanticDiff struct {
+	tolerance   models.DiffTolerance
+	differences []string
+}
+
+// compare records the differences between expected and actual at path
+func (d *semanticDiff) compare(expected, actual interface{}, path []string) {
+	if d.ignored(path) {
+		return
+	}
+
+	switch want := expected.(type) {
+	case map[string]interface{}:
+		got, ok := actual.(map[string]interface{})
+		if !ok {
+			d.differ(path, "expected an object, got %s", describeValue(actual))
+			return
+		}
+		for _, key := range sortedKeys(want) {
+			child := appendPath(path, key)
+			if _, exists := got[key]; !exists {
+				if !d.ignored(child) {
+					d.differ(child, "missing")
+				}
+				continue
+			}
+			d.compare(want[key], got[key], child)
+		}
+		if !d.tolerance.AllowExtraFields {
+			for _, key := range sortedKeys(got) {
+				child := appendPath(path, key)
+				if _, exists := want[key]; !exists && !d.ignored(child) {
+					d.differ(child, "unexpected field")
+				}
+			}
+		}
+
+	case []interface{}:
+		got, ok := actual.([]interface{})
+		if !ok {
+			d.differ(path, "expected an array, got %s", describeValue(actual))
+			return
+		}
+		if len(want) != len(got) {
+			d.differ(path, "expected %d items, got %d", len(want), len(got))
+			return
+		}
+		if !d.tolerance.IgnoreOrder {
+			for i := range want {
+				d.compare(want[i], got[i], appendPath(path, strconv.Itoa(i)))
+			}
+			return
+		}
+		// Every expected item needs its own matching item, in any position
+		matched := make([]bool, len(got))
+		for i, item := range want {
+			found := false
+			for j := range got {
+				if !matched[j] && d.matches(item, got[j], appendPath(path, strconv.Itoa(j))) {
+					matched[j], found = true, true
+					break
+				}
+			}
+			if !found {
+				d.differ(appendPath(path, strconv.Itoa(i)), "no matching item for %s", describeValue(item))
+			}
+		}
+
+	case float64:
+		got, ok := actual.(float64)
+		if !ok {
+			d.differ(path, "expected %v, got %s", want, describeValue(actual))
+			return
+		}
+		if math.Abs(want-got) > d.tolerance.Numeric {
+			d.differ(path, "expected %v, got %v", want, got)
+		}
+
+	default:
+		if !reflect.DeepEqual(expected, actual) {
+			d.differ(path, "expected %s, got %s", describeValue(expected), describeValue(actual))
+		}
+	}
+}
+
+// matches reports whether actual matches expected without differences
+func (d *semanticDiff) matches(expected, actual interface{}, path []string) bool {
+	probe := &semanticDiff{tolerance: d.tolerance}
+	probe.compare(expected, actual, path)
+	return len(probe.differences) == 0
+}
+
+// ignored reports whether a path matches one of the ignored paths
+func (d *semanticDiff) ignored(path []string) bool {
+	for _, ignored := range d.tolerance.IgnorePaths {
+		segments := strings.Split(ignored, ".")
+		if len(segments) != len(path) {
+			continue
+		}
+		match := true
+		for i, segment := range segments {
+			if segment != "*" && segment != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// differ records a difference at path
+func (d *semanticDiff) differ(path []string, format string, args ...interface{}) {
+	location := strings.Join(path, ".")
+	if location == "" {
+		location = "$"
+	}
+	d.differences = append(d.differences, location+": "+fmt.Sprintf(format, args...))
+}
+
+// appendPath returns path extended by a segment, leaving path untouched
+func appendPath(path []string, segment string) []string {
+	return append(path[:len(path):len(path)], segment)
+}
+
+// sortedKeys returns the keys of an object in order, so differences are
+// reported in a stable order
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// describeValue renders a value for a difference message
+func describeValue(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
@@ -0,0 +1,151 @@
+package testrunner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// SOAP envelope namespaces
+const (
+	soap11Namespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12Namespace = "http://www.w3.org/2003/05/soap-envelope"
+)
+
+// SOAPExecutor executes SOAP calls over HTTP. The request body is the SOAP
+// envelope; a response carrying a SOAP Fault fails the test unless the test
+// asserts on the fault.
+type SOAPExecutor struct {
+	http *HTTPExpectExecutor
+}
+
+// newSOAPExecutor creates the SOAP executor
+func newSOAPExecutor(config ExecutorConfig) (Executor, error) {
+	return &SOAPExecutor{
+		http: configuredHTTPExecutor(config).WithResponseCheck(checkSOAPResponse),
+	}, nil
+}
+
+// ExecuteTest posts the envelope of the test spec and runs its assertions
+func (e *SOAPExecutor) ExecuteTest(ctx context.Context, testSpec *models.TestSpec) *TestResult {
+	envelope, _ := testSpec.Request.Body.(string)
+	if strings.TrimSpace(envelope) == "" && testSpec.Request.BodyFixture == nil {
+		return &TestResult{
+			TestName:     testSpec.Name,
+			StartTime:    time.Now(),
+			Status:       "FAILED",
+			ErrorMessage: "SOAP tests require the envelope as request body or body fixture",
+			FailureType:  FailureSpec,
+		}
+	}
+	return e.http.ExecuteTest(ctx, soapHTTPSpec(testSpec, envelope))
+}
+
+// soapHTTPSpec returns the HTTP request carrying a SOAP envelope: a POST
+// unless the test sets a method, with the content type of the SOAP version
+// of the envelope unless the test sets one
+func soapHTTPSpec(testSpec *models.TestSpec, envelope string) *models.TestSpec {
+	spec := *testSpec
+	if spec.Request.Method == "" {
+		spec.Request.Method = http.MethodPost
+	}
+	spec.Request.Headers = mergeHeaders(nil, testSpec.Request.Headers)
+	// Envelopes stored as fixtures are sent with the content type of the fixture
+	if !hasHeader(spec.Request.Headers, "Content-Type") && envelope != "" {
+		spec.Request.Headers["Content-Type"] = "text/xml; charset=utf-8"
+		if strings.Contains(envelope, soap12Namespace) {
+			spec.Request.Headers["Content-Type"] = "application/soap+xml; charset=utf-8"
+		}
+	}
+	return &spec
+}
+
+// checkSOAPResponse applies the SOAP Fault check to a response. Error
+// statuses, which SOAP 1.1 uses for faults, are reported with the fault.
+// Faults with a successful status fail the test unless it asserts on them,
+// and take precedence over the assertions they made fail.
+func checkSOAPResponse(testSpec *models.TestSpec, result *TestResult, statusCode int, body interface{}) {
+	content, _ := body.(string)
+	fault, ok := soapFault(content)
+	if !ok {
+		return
+	}
+
+	if statusCode >= 400 {
+		result.ErrorMessage = fmt.Sprintf("%s: SOAP Fault: %s", result.ErrorMessage, fault)
+		return
+	}
+	if result.Status == "FAILED" && result.FailureType != FailureAssertion {
+		return
+	}
+	if !assertsSOAPFault(testSpec) {
+		result.Status = "FAILED"
+		result.ErrorMessage = "SOAP Fault: " + fault
+		result.FailureType = FailureSOAPFault
+	}
+}
+
+// assertsSOAPFault reports whether a test expects a fault by asserting on it
+// with an xpath assertion
+func assertsSOAPFault(testSpec *models.TestSpec) bool {
+	for _, assertion := range testSpec.Assertions {
+		if assertion.Type == "xpath" && strings.Contains(assertion.Path, "Fault") {
+			return true
+		}
+	}
+	return false
+}
+
+// soapFault returns the code and reason of the SOAP Fault in the body of a
+// SOAP 1.1 or 1.2 envelope, if there is one
+func soapFault(content string) (string, bool) {
+	if !strings.Contains(content, "Fault") {
+		return "", false
+	}
+	doc, err := parseXML(content)
+	if err != nil {
+		return "", false
+	}
+
+	for _, space := range []string{soap11Namespace, soap12Namespace} {
+		namespaces := map[string]string{"soap": space}
+		faults, err := evaluateXPath(doc, "/soap:Envelope/soap:Body/soap:Fault", namespaces)
+		if err != nil || len(faults) == 0 {
+			continue
+		}
+
+		// SOAP 1.1 uses unqualified faultcode and faultstring, 1.2 Code/Value and Reason/Text
+		code, reason := "faultcode", "faultstring"
+		if space == soap12Namespace {
+			code, reason = "soap:Code/soap:Value", "soap:Reason/soap:Text"
+		}
+		return fmt.Sprintf("%s: %s", xpathText(doc, faults[0], code, namespaces), xpathText(doc, faults[0], reason, namespaces)), true
+	}
+	return "", false
+}
+
+// xpathText returns the trimmed text of the first node a relative path selects
+func xpathText(doc *xmlDocument, node *xmlNode, path string, namespaces map[string]string) string {
+	nodes, err := evaluateXPath(&xmlDocument{root: node, prefixes: doc.prefixes}, path, namespaces)
+	if err != nil || len(nodes) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(nodes[0].value())
+}
+
+// isXMLContentType reports whether headers declare an XML content type, e.g.
+// text/xml, application/xml or application/soap+xml
+func isXMLContentType(headers map[string]interface{}) bool {
+	for name, value := range headers {
+		if strings.EqualFold(name, "Content-Type") {
+			contentType, _ := value.(string)
+			mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+			return strings.HasSuffix(strings.TrimSpace(mediaType), "xml")
+		}
+	}
+	return false
+}
@@ -0,0 +1,372 @@
+package testrunner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// ErrInvalidSpec is returned when a test spec fails validation
+var ErrInvalidSpec = errors.New("invalid test spec")
+
+// FieldError is a validation error of a field of a test spec, addressed by
+// its path such as assertions[1].matcher
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// SpecValidationError lists the field errors of a test spec
+type SpecValidationError struct {
+	Errors []FieldError
+}
+
+func (e *SpecValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldError := range e.Errors {
+		messages[i] = fieldError.Message
+		if fieldError.Field != "" {
+			messages[i] = fieldError.Field + ": " + fieldError.Message
+		}
+	}
+	return fmt.Sprintf("%v: %s", ErrInvalidSpec, strings.Join(messages, "; "))
+}
+
+func (e *SpecValidationError) Unwrap() error { return ErrInvalidSpec }
+
+// requestMethods are the methods a request can use
+var requestMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "TRACE", "CONNECT"}
+
+// assertionMatchers are the assertion types with the matchers they accept. A
+// nil list means the type takes no matcher, or, for latency_budget, records
+// the level the budget was inherited from.
+var assertionMatchers = map[string][]string{
+	"status_code":    {StatusMatcherEquals, StatusMatcherOneOf, StatusMatcherClass},
+	"status_class":   {StatusMatcherClass},
+	"exists":         nil,
+	"equals":         nil,
+	"contains":       nil, // websocket tests only
+	"regex":          nil, // websocket tests only
+	"count":          nil, // websocket tests only
+	"json_path":      {"exists", "equals", "contains", "regex"},
+	"json_schema":    nil,
+	"semantic_diff":  nil,
+	"deprecation":    {"absent", "sunset_after"},
+	"graphql_errors": nil,
+	"xpath":          {"exists", "not_exists", "equals", "contains", "regex", "count"},
+	"response_time":  {"less_than", "greater_than"},
+	"latency_budget": nil,
+	"probe_safe":     nil,
+}
+
+// pathAssertions are the assertion types that address a value by path
+var pathAssertions = map[string]bool{"exists": true, "equals": true, "json_path": true, "xpath": true}
+
+// placeholderPattern matches variable and generator placeholders, which are
+// only known at run time
+var placeholderPattern = regexp.MustCompile(`\{\{[^{}]*\}\}`)
+
+// ValidateSpec strictly validates a test spec: unknown fields, values of the
+// wrong type, unknown protocols, methods, assertion types and matchers, and
+// malformed URLs are reported as field errors. A valid spec returns nil.
+func ValidateSpec(raw []byte) []FieldError {
+	var document interface{}
+	if err := json.Unmarshal(raw, &document); err != nil {
+		return []FieldError{{Message: fmt.Sprintf("not valid JSON: %v", err)}}
+	}
+	if _, ok := document.(map[string]interface{}); !ok {
+		return []FieldError{{Message: "must be a JSON object"}}
+	}
+
+	var errs []FieldError
+	unknownFields(&errs, document, reflect.TypeOf(models.TestSpec{}), "")
+
+	var spec models.TestSpec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		var typeError *json.UnmarshalTypeError
+		if errors.As(err, &typeError) {
+			errs = append(errs, FieldError{Field: typeError.Field, Message: fmt.Sprintf("must be a %s, got a %s", jsonTypeName(typeError.Type), typeError.Value)})
+		} else {
+			errs = append(errs, FieldError{Message: err.Error()})
+		}
+		return errs
+	}
+
+	// Specs without a protocol use the protocol of their service, which is
+	// only known at run time
+	protocol := spec.Protocol
+	if protocol != "" && !contains(Protocols(), protocol) {
+		errs = append(errs, FieldError{Field: "protocol", Message: fmt.Sprintf("unknown protocol %q, expected one of: %s", spec.Protocol, strings.Join(Protocols(), ", "))})
+	}
+
+	validateRequest(&errs, "request", spec.Request, protocol)
+	if spec.Conflict != nil {
+		validateRequest(&errs, "conflict.update", spec.Conflict.Update, protocol)
+		if spec.Conflict.SecondUpdate != nil {
+			validateRequest(&errs, "conflict.second_update", *spec.Conflict.SecondUpdate, protocol)
+		}
+	}
+
+	if spec.Assertions == nil {
+		errs = append(errs, FieldError{Field: "assertions", Message: "is required"})
+	}
+	validateAssertions(&errs, "assertions", spec.Assertions)
+	for i, variant := range spec.Variants {
+		validateAssertions(&errs, fmt.Sprintf("variants[%d].assertions", i), variant.Assertions)
+	}
+	return errs
+}
+
+// validateRequest checks the method and URL of a request. Only requests of
+// HTTP tests need a method; the other protocols choose or ignore it.
+func validateRequest(errs *[]FieldError, field string, request models.RequestSpec, protocol string) {
+	method := request.Method
+	switch {
+	case method == "" && protocol == ProtocolHTTP:
+		*errs = append(*errs, FieldError{Field: field + ".method", Message: "is required"})
+	case method != "" && !placeholderPattern.MatchString(method) && !contains(requestMethods, method):
+		message := fmt.Sprintf("unknown method %q, expected one of: %s", method, strings.Join(requestMethods, ", "))
+		if contains(requestMethods, strings.ToUpper(method)) {
+			message = fmt.Sprintf("method %q must be upper case", method)
+		}
+		*errs = append(*errs, FieldError{Field: field + ".method", Message: message})
+	}
+
+	if request.URL == "" {
+		*errs = append(*errs, FieldError{Field: field + ".url", Message: "is required"})
+		return
+	}
+	if message := urlProblem(request.URL, protocol); message != "" {
+		*errs = append(*errs, FieldError{Field: field + ".url", Message: message})
+	}
+}
+
+// urlProblem describes what is wrong with a request URL, which is either a
+// path resolved against the service base URL or an absolute URL. Placeholders
+// stand for any text.
+func urlProblem(rawURL, protocol string) string {
+	text := placeholderPattern.ReplaceAllString(rawURL, "x")
+	if strings.ContainsAny(text, " \t\r\n") {
+		return fmt.Sprintf("%q contains whitespace, encode it as %%20", rawURL)
+	}
+	parsed, err := url.Parse(text)
+	if err != nil {
+		return fmt.Sprintf("%q is not a valid URL: %v", rawURL, errors.Unwrap(err))
+	}
+	if parsed.Scheme == "" {
+		if protocol == ProtocolGRPC && !strings.HasPrefix(text, "/") {
+			return fmt.Sprintf("%q must name a method as /package.Service/Method", rawURL)
+		}
+		return ""
+	}
+	// A URL starting with a placeholder, such as {{base_url}}/users, has
+	// its scheme chosen at run time
+	if strings.HasPrefix(strings.TrimSpace(rawURL), "{{") {
+		return ""
+	}
+	schemes := []string{"http", "https"}
+	if protocol == ProtocolWebSocket {
+		schemes = append(schemes, "ws", "wss")
+	}
+	if !contains(schemes, strings.ToLower(parsed.Scheme)) || parsed.Host == "" {
+		return fmt.Sprintf("%q must be a path or an absolute URL with a %s scheme and a host", rawURL, strings.Join(schemes, ", "))
+	}
+	return ""
+}
+
+// validateAssertions checks the types and matchers of assertions
+func validateAssertions(errs *[]FieldError, field string, assertions []models.AssertionSpec) {
+	for i, assertion := range assertions {
+		prefix := fmt.Sprintf("%s[%d]", field, i)
+		matchers, known := assertionMatchers[assertion.Type]
+		switch {
+		case assertion.Type == "":
+			*errs = append(*errs, FieldError{Field: prefix + ".type", Message: "is required"})
+			continue
+		case !known:
+			*errs = append(*errs, FieldError{Field: prefix + ".type", Message: fmt.Sprintf("unknown assertion type %q, expected one of: %s", assertion.Type, strings.Join(assertionTypes(), ", "))})
+			continue
+		}
+
+		if pathAssertions[assertion.Type] && assertion.Path == "" {
+			*errs = append(*errs, FieldError{Field: prefix + ".path", Message: fmt.Sprintf("is required by %s assertions", assertion.Type)})
+		}
+		switch {
+		case assertion.Type == "json_path" && assertion.Matcher == "":
+			*errs = append(*errs, FieldError{Field: prefix + ".matcher", Message: fmt.Sprintf("is required by json_path assertions, expected one of: %s", strings.Join(matchers, ", "))})
+		case assertion.Matcher == "" || assertion.Type == "latency_budget":
+		case matchers == nil:
+			*errs = append(*errs, FieldError{Field: prefix + ".matcher", Message: fmt.Sprintf("%s assertions take no matcher", assertion.Type)})
+		case !contains(matchers, assertion.Matcher):
+			*errs = append(*errs, FieldError{Field: prefix + ".matcher", Message: fmt.Sprintf("unknown %s matcher %q, expected one of: %s", assertion.Type, assertion.Matcher, strings.Join(matchers, ", "))})
+		}
+	}
+}
+
+// assertionTypes lists the known assertion types
+func assertionTypes() []string {
+	types := make([]string, 0, len(assertionMatchers))
+	for assertionType := range assertionMatchers {
+		types = append(types, assertionType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// unknownFields reports the keys of JSON objects that match no field of the
+// struct they decode into. Like encoding/json, keys match case-insensitively.
+// Values decoding into interfaces or custom unmarshalers are not inspected.
+func unknownFields(errs *[]FieldError, value interface{}, target reflect.Type, field string) {
+	for target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+	if reflect.PointerTo(target).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()) {
+		return
+	}
+
+	switch target.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(target)
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fieldType, found := fields[strings.ToLower(key)]
+			if !found {
+				*errs = append(*errs, FieldError{Field: joinField(field, key), Message: "unknown field" + suggestField(key, fields)})
+				continue
+			}
+			unknownFields(errs, object[key], fieldType, joinField(field, key))
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			unknownFields(errs, item, target.Elem(), fmt.Sprintf("%s[%d]", field, i))
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for key, item := range object {
+			unknownFields(errs, item, target.Elem(), joinField(field, key))
+		}
+	}
+}
+
+// jsonFields returns the types of the fields of a struct by lower-cased
+// JSON name, including the fields of embedded structs
+func jsonFields(target reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < target.NumField(); i++ {
+		structField := target.Field(i)
+		name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+		if name == "-" || !structField.IsExported() && !structField.Anonymous {
+			continue
+		}
+		if structField.Anonymous && name == "" {
+			embedded := structField.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for key, fieldType := range jsonFields(embedded) {
+					fields[key] = fieldType
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = structField.Name
+		}
+		fields[strings.ToLower(name)] = structField.Type
+	}
+	return fields
+}
+
+// suggestField proposes the known field closest to a misspelled key
+func suggestField(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		if distance := editDistance(strings.ToLower(key), name); distance < bestDistance || distance == bestDistance && name < best {
+			best, bestDistance = name, distance
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// joinField appends a key to a field path
+func joinField(field, key string) string {
+	if field == "" {
+		return key
+	}
+	return field + "." + key
+}
+
+// jsonTypeName names the JSON type a Go type decodes from
+func jsonTypeName(target reflect.Type) string {
+	switch target.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// contains reports whether a list contains a value
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
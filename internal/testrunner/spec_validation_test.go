@@ -0,0 +1,86 @@
+package testrunner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSpec(t *testing.T) {
+	valid := `{
+		"name": "get user",
+		"request": {"method": "GET", "url": "/users/{{user_id}}", "headers": {"Accept": "application/json"}, "body": {"anything": {"goes": true}}},
+		"assertions": [
+			{"type": "status_code", "expected": 200},
+			{"type": "json_path", "path": "id", "matcher": "equals", "expected": 1, "normalize": {"trim": true}},
+			{"type": "response_time", "matcher": "less_than", "expected": 500}
+		],
+		"variants": [{"name": "xml", "headers": {"Accept": "application/xml"}, "assertions": [{"type": "xpath", "path": "/user/id", "matcher": "count", "expected": 1}]}],
+		"Retry": {"max_attempts": 3}
+	}`
+	if errs := ValidateSpec([]byte(valid)); len(errs) != 0 {
+		t.Fatalf("valid spec has errors: %+v", errs)
+	}
+
+	tests := []struct {
+		name    string
+		spec    string
+		field   string
+		message string
+	}{
+		{"not JSON", `{"name":`, "", "not valid JSON"},
+		{"not an object", `[]`, "", "must be a JSON object"},
+		{"misspelled field", `{"request": {"method": "GET", "url": "/"}, "asserions": []}`, "asserions", `did you mean "assertions"`},
+		{"nested unknown field", `{"request": {"method": "GET", "url": "/", "header": {}}, "assertions": []}`, "request.header", "unknown field"},
+		{"unknown field of an assertion", `{"request": {"method": "GET", "url": "/"}, "assertions": [{"type": "exists", "path": "id", "matchr": "x"}]}`, "assertions[0].matchr", `did you mean "matcher"`},
+		{"wrong type", `{"request": {"method": "GET", "url": "/"}, "assertions": [], "timeout_ms": "5s"}`, "timeout_ms", "must be a number"},
+		{"missing assertions", `{"request": {"method": "GET", "url": "/"}}`, "assertions", "is required"},
+		{"unknown protocol", `{"protocol": "ftp", "request": {"url": "/"}, "assertions": []}`, "protocol", "unknown protocol"},
+		{"missing method", `{"protocol": "http", "request": {"url": "/"}, "assertions": []}`, "request.method", "is required"},
+		{"lower case method", `{"request": {"method": "get", "url": "/"}, "assertions": []}`, "request.method", "upper case"},
+		{"unknown method", `{"request": {"method": "FETCH", "url": "/"}, "assertions": []}`, "request.method", "unknown method"},
+		{"missing URL", `{"request": {"method": "GET"}, "assertions": []}`, "request.url", "is required"},
+		{"URL with whitespace", `{"request": {"method": "GET", "url": "/users/a b"}, "assertions": []}`, "request.url", "whitespace"},
+		{"URL with an unknown scheme", `{"request": {"method": "GET", "url": "ftp://example.com/"}, "assertions": []}`, "request.url", "absolute URL"},
+		{"URL without a host", `{"request": {"method": "GET", "url": "http:///users"}, "assertions": []}`, "request.url", "absolute URL"},
+		{"gRPC URL without a method", `{"protocol": "grpc", "request": {"url": "helloworld.Greeter"}, "assertions": []}`, "request.url", "/package.Service/Method"},
+		{"conflict update", `{"request": {"method": "GET", "url": "/"}, "assertions": [], "conflict": {"update": {"method": "PUTT", "url": "/"}}}`, "conflict.update.method", "unknown method"},
+		{"unknown assertion type", `{"request": {"method": "GET", "url": "/"}, "assertions": [{"type": "status"}]}`, "assertions[0].type", `unknown assertion type "status"`},
+		{"missing assertion type", `{"request": {"method": "GET", "url": "/"}, "assertions": [{"path": "id"}]}`, "assertions[0].type", "is required"},
+		{"unknown matcher", `{"request": {"method": "GET", "url": "/"}, "assertions": [{"type": "json_path", "path": "id", "matcher": "equal"}]}`, "assertions[0].matcher", `unknown json_path matcher "equal"`},
+		{"missing json_path matcher", `{"request": {"method": "GET", "url": "/"}, "assertions": [{"type": "json_path", "path": "id"}]}`, "assertions[0].matcher", "is required"},
+		{"matcher of a type without matchers", `{"request": {"method": "GET", "url": "/"}, "assertions": [{"type": "json_schema", "matcher": "equals"}]}`, "assertions[0].matcher", "take no matcher"},
+		{"missing path", `{"request": {"method": "GET", "url": "/"}, "assertions": [{"type": "exists"}]}`, "assertions[0].path", "is required"},
+		{"variant assertion", `{"request": {"method": "GET", "url": "/"}, "assertions": [], "variants": [{"name": "v", "assertions": [{"type": "nope"}]}]}`, "variants[0].assertions[0].type", "unknown assertion type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateSpec([]byte(tt.spec))
+			for _, err := range errs {
+				if err.Field == tt.field && strings.Contains(err.Message, tt.message) {
+					return
+				}
+			}
+			t.Errorf("expected %s error containing %q, got %+v", tt.field, tt.message, errs)
+		})
+	}
+}
+
+func TestValidateSpecPlaceholders(t *testing.T) {
+	spec := `{
+		"request": {"method": "{{method}}", "url": "{{base_url}}/users/{{ user id }}"},
+		"assertions": [{"type": "status_code", "expected": 200}]
+	}`
+	if errs := ValidateSpec([]byte(spec)); len(errs) != 0 {
+		t.Errorf("placeholders are reported: %+v", errs)
+	}
+}
+
+func TestSpecValidationError(t *testing.T) {
+	err := &SpecValidationError{Errors: []FieldError{
+		{Field: "asserions", Message: "unknown field"},
+		{Message: "must be a JSON object"},
+	}}
+	if got, want := err.Error(), "invalid test spec: asserions: unknown field; must be a JSON object"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
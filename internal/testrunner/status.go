@@ -0,0 +1,146 @@
+package testrunner
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// Matchers of status_code assertions
+const (
+	StatusMatcherEquals = "equals" // the status is the expected code
+	StatusMatcherOneOf  = "one_of" // the status is one of a list of codes
+	StatusMatcherClass  = "class"  // the status is of a class such as 2xx
+)
+
+// assertsStatus reports whether a test asserts on the status code; such
+// tests decide themselves whether an error status fails them. probe_safe
+// assertions accept client errors, which is how probes are usually rejected.
+func assertsStatus(testSpec *models.TestSpec) bool {
+	for _, assertion := range testSpec.Assertions {
+		if assertion.Type == "status_code" || assertion.Type == "status_class" || assertion.Type == "probe_safe" {
+			return true
+		}
+	}
+	return false
+}
+
+// assertStatus checks a status code against a status_code or status_class
+// assertion. Without a matcher, status_code compares with a single expected
+// code, accepts any code of a list, and matches a class given as "4xx";
+// status_class always matches a class.
+func assertStatus(result *AssertionResult, statusCode int, assertion map[string]interface{}) {
+	expected := expectedValue(assertion)
+	result.Expected = expected
+	result.Actual = statusCode
+
+	matcher, _ := assertion["matcher"].(string)
+	if result.Type == "status_class" {
+		matcher = StatusMatcherClass
+	}
+	if matcher == "" {
+		switch want := expected.(type) {
+		case []interface{}:
+			matcher = StatusMatcherOneOf
+		case string:
+			if _, err := strconv.Atoi(want); err != nil {
+				matcher = StatusMatcherClass
+			} else {
+				matcher = StatusMatcherEquals
+			}
+		default:
+			matcher = StatusMatcherEquals
+		}
+	}
+	result.Matcher = matcher
+
+	switch matcher {
+	case StatusMatcherEquals:
+		code, ok := statusCodeOf(expected)
+		if !ok {
+			result.Passed = false
+			result.Message = fmt.Sprintf("Invalid expected status code %v", expected)
+			return
+		}
+		result.Passed = statusCode == code
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected status code %d, got %d", code, statusCode)
+		}
+
+	case StatusMatcherOneOf:
+		list, ok := expected.([]interface{})
+		if !ok || len(list) == 0 {
+			result.Passed = false
+			result.Message = "one_of expects a list of status codes"
+			return
+		}
+		codes := make([]string, 0, len(list))
+		result.Passed = false
+		for _, item := range list {
+			code, ok := statusCodeOf(item)
+			if !ok {
+				result.Message = fmt.Sprintf("Invalid expected status code %v", item)
+				return
+			}
+			codes = append(codes, strconv.Itoa(code))
+			if statusCode == code {
+				result.Passed = true
+			}
+		}
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected status code %s, got %d", strings.Join(codes, ", "), statusCode)
+		}
+
+	case StatusMatcherClass:
+		class, ok := statusClassOf(expected)
+		if !ok {
+			result.Passed = false
+			result.Message = fmt.Sprintf("Invalid expected status class %v, use 1xx to 5xx", expected)
+			return
+		}
+		result.Passed = statusCode/100 == class
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected status class %dxx, got %d", class, statusCode)
+		}
+
+	default:
+		result.Passed = false
+		result.Message = fmt.Sprintf("Unknown status matcher: %s", matcher)
+	}
+}
+
+// statusCodeOf returns the status code of an expected value, a number or a
+// numeric string
+func statusCodeOf(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int(v), v == float64(int(v))
+	case int:
+		return v, true
+	case string:
+		code, err := strconv.Atoi(strings.TrimSpace(v))
+		return code, err == nil
+	}
+	return 0, false
+}
+
+// statusClassOf returns the class of an expected value such as "2xx", "2XX"
+// or 2
+func statusClassOf(value interface{}) (int, bool) {
+	class := 0
+	switch v := value.(type) {
+	case float64:
+		class = int(v)
+	case int:
+		class = v
+	case string:
+		digit, rest, ok := strings.Cut(strings.ToLower(strings.TrimSpace(v)), "x")
+		if !ok || rest != "x" || len(digit) != 1 {
+			return 0, false
+		}
+		class = int(digit[0] - '0')
+	}
+	return class, class >= 1 && class <= 5
+}
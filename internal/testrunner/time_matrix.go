@@ -0,0 +1,142 @@
+package testrunner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"github.com/tidwall/gjson"
+)
+
+// Default headers of time matrix variants
+const (
+	defaultTimezoneHeader = "Time-Zone"
+	defaultSkewHeader     = "Date"
+)
+
+// zonedTimeLayouts are the layouts of dates that denote an instant
+var zonedTimeLayouts = []string{time.RFC3339Nano, time.RFC1123, time.RFC1123Z, time.RFC850}
+
+// executeTimeMatrix runs the request once per timezone and clock skew of the
+// test's time matrix, as variants after the test's own, and fails the test if
+// the dates at the consistent paths differ between the responses
+func (e *HTTPExpectExecutor) executeTimeMatrix(ctx context.Context, testSpec *models.TestSpec) *TestResult {
+	matrix := testSpec.TimeMatrix
+	variants, err := timeMatrixVariants(matrix, time.Now())
+	if err != nil {
+		return &TestResult{
+			TestName:     testSpec.Name,
+			StartTime:    time.Now(),
+			Status:       "FAILED",
+			ErrorMessage: fmt.Sprintf("time_matrix: %v", err),
+			FailureType:  FailureSpec,
+		}
+	}
+
+	matrixSpec := *testSpec
+	matrixSpec.TimeMatrix = nil
+	matrixSpec.Variants = append(append([]models.VariantSpec{}, testSpec.Variants...), variants...)
+	result := e.executeVariants(ctx, &matrixSpec)
+
+	if result.Status == "FAILED" {
+		return result
+	}
+	for _, path := range matrix.ConsistentPaths {
+		if message := timeInconsistency(result.VariantResults, path); message != "" {
+			result.Status = "FAILED"
+			result.ErrorMessage = "time_matrix: " + message
+			result.FailureType = FailureAssertion
+			break
+		}
+	}
+	return result
+}
+
+// timeMatrixVariants returns a variant per timezone and per clock skew of a
+// time matrix. Skewed times are sent as HTTP dates relative to now.
+func timeMatrixVariants(matrix *models.TimeMatrix, now time.Time) ([]models.VariantSpec, error) {
+	timezoneHeader := matrix.TimezoneHeader
+	if timezoneHeader == "" {
+		timezoneHeader = defaultTimezoneHeader
+	}
+	skewHeader := matrix.SkewHeader
+	if skewHeader == "" {
+		skewHeader = defaultSkewHeader
+	}
+
+	variants := make([]models.VariantSpec, 0, len(matrix.Timezones)+len(matrix.ClockSkews))
+	for _, zone := range matrix.Timezones {
+		if _, err := time.LoadLocation(zone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", zone)
+		}
+		variants = append(variants, models.VariantSpec{
+			Name:    "timezone " + zone,
+			Headers: map[string]string{timezoneHeader: zone},
+		})
+	}
+	for _, skew := range matrix.ClockSkews {
+		offset, err := time.ParseDuration(skew)
+		if err != nil {
+			return nil, fmt.Errorf("invalid clock skew %q, use a duration such as -5m or 2h", skew)
+		}
+		variants = append(variants, models.VariantSpec{
+			Name:    "clock skew " + skew,
+			Headers: map[string]string{skewHeader: now.Add(offset).UTC().Format(http.TimeFormat)},
+		})
+	}
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("needs timezones or clock_skews")
+	}
+	return variants, nil
+}
+
+// timeInconsistency describes how the date at a path differs between the
+// responses of variants, or returns "" when it is consistent. Dates with a
+// zone or offset compare as instants, anything else as text.
+func timeInconsistency(variants []VariantResult, path string) string {
+	var names, values []string
+	for _, variant := range variants {
+		value := gjson.GetBytes(variant.ResponseData, path)
+		if !value.Exists() {
+			return fmt.Sprintf("%s is missing in the response of variant '%s'", path, variant.Name)
+		}
+		names = append(names, variant.Name)
+		values = append(values, value.String())
+	}
+	if len(values) < 2 {
+		return ""
+	}
+
+	instants := make([]time.Time, len(values))
+	zoned := true
+	for i, value := range values {
+		instant, ok := parseZonedTime(value)
+		instants[i] = instant
+		zoned = zoned && ok
+	}
+	for i := 1; i < len(values); i++ {
+		if zoned && instants[i].Equal(instants[0]) || !zoned && values[i] == values[0] {
+			continue
+		}
+		described := make([]string, len(values))
+		for j := range values {
+			described[j] = fmt.Sprintf("%s: %s", names[j], values[j])
+		}
+		return fmt.Sprintf("%s differs between variants (%s)", path, strings.Join(described, ", "))
+	}
+	return ""
+}
+
+// parseZonedTime parses a date that carries a zone or offset
+func parseZonedTime(value string) (time.Time, bool) {
+	for _, layout := range zonedTimeLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,126 @@
+package testrunner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// ErrNoTLS is returned when auditing a base URL that does not use TLS
+var ErrNoTLS = errors.New("base URL does not use TLS")
+
+// tlsAuditTimeout bounds the connection and handshake of a TLS audit
+const tlsAuditTimeout = 10 * time.Second
+
+// weakSignatureAlgorithms are certificate signatures that can be forged
+var weakSignatureAlgorithms = map[x509.SignatureAlgorithm]bool{
+	x509.MD2WithRSA:    true,
+	x509.MD5WithRSA:    true,
+	x509.SHA1WithRSA:   true,
+	x509.DSAWithSHA1:   true,
+	x509.ECDSAWithSHA1: true,
+}
+
+// AuditTLS connects to the host of an https or wss base URL and records the
+// negotiated TLS version and cipher suite and the certificate chain of the
+// server. The chain is verified separately from the handshake, so invalid
+// certificates are recorded instead of failing the audit; connection errors
+// are recorded in the Error of the audit.
+func AuditTLS(ctx context.Context, baseURL string, config models.TLSConfig, now time.Time) (*models.TLSAudit, error) {
+	parsed, err := neturl.Parse(baseURL)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "wss") || parsed.Hostname() == "" {
+		return nil, fmt.Errorf("%w: %s", ErrNoTLS, baseURL)
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "443"
+	}
+	serverName := config.ServerName
+	if serverName == "" {
+		serverName = parsed.Hostname()
+	}
+
+	audit := &models.TLSAudit{
+		CheckedAt: now,
+		Address:   net.JoinHostPort(parsed.Hostname(), port),
+	}
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: tlsAuditTimeout},
+		Config:    &tls.Config{ServerName: serverName, InsecureSkipVerify: true},
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, tlsAuditTimeout)
+	defer cancel()
+	conn, err := dialer.DialContext(dialCtx, "tcp", audit.Address)
+	if err != nil {
+		audit.Error = err.Error()
+		return audit, nil
+	}
+	state := conn.(*tls.Conn).ConnectionState()
+	conn.Close()
+
+	audit.Version = tls.VersionName(state.Version)
+	audit.CipherSuite = tls.CipherSuiteName(state.CipherSuite)
+	if state.Version < tls.VersionTLS12 {
+		audit.Issues = append(audit.Issues, fmt.Sprintf("%s is deprecated, TLS 1.2 or later is expected", audit.Version))
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == state.CipherSuite {
+			audit.Issues = append(audit.Issues, fmt.Sprintf("the cipher suite %s is insecure", audit.CipherSuite))
+		}
+	}
+
+	for i, cert := range state.PeerCertificates {
+		audit.Certificates = append(audit.Certificates, models.CertificateInfo{
+			Subject:            cert.Subject.String(),
+			Issuer:             cert.Issuer.String(),
+			SerialNumber:       cert.SerialNumber.Text(16),
+			DNSNames:           cert.DNSNames,
+			NotBefore:          cert.NotBefore,
+			NotAfter:           cert.NotAfter,
+			SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		})
+		if audit.ExpiresAt == nil || cert.NotAfter.Before(*audit.ExpiresAt) {
+			notAfter := cert.NotAfter
+			audit.ExpiresAt = &notAfter
+		}
+		// The signature of a self-signed root is never checked
+		if weakSignatureAlgorithms[cert.SignatureAlgorithm] && (i == 0 || cert.Subject.String() != cert.Issuer.String()) {
+			audit.Issues = append(audit.Issues, fmt.Sprintf("%s is signed with the weak algorithm %s", cert.Subject.CommonName, cert.SignatureAlgorithm))
+		}
+	}
+	if len(state.PeerCertificates) == 0 {
+		audit.VerifyError = "the server presented no certificate"
+		return audit, nil
+	}
+
+	audit.DaysUntilExpiry = int(audit.ExpiresAt.Sub(now).Hours() / 24)
+	if audit.ExpiresAt.Before(now) {
+		audit.Issues = append(audit.Issues, "the certificate chain expired on "+audit.ExpiresAt.Format(time.DateOnly))
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+	})
+	audit.Verified = err == nil
+	if err != nil {
+		audit.VerifyError = err.Error()
+		if !config.InsecureSkipVerify {
+			audit.Issues = append(audit.Issues, "the certificate chain is not trusted: "+strings.TrimPrefix(err.Error(), "x509: "))
+		}
+	}
+	return audit, nil
+}
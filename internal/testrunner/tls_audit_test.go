@@ -0,0 +1,71 @@
+package testrunner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+func TestAuditTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	now := time.Now()
+
+	audit, err := AuditTLS(context.Background(), server.URL+"/api", models.TLSConfig{}, now)
+	if err != nil {
+		t.Fatalf("AuditTLS failed: %v", err)
+	}
+	if audit.Error != "" {
+		t.Fatalf("unexpected audit error: %s", audit.Error)
+	}
+	if audit.Address != server.Listener.Addr().String() {
+		t.Errorf("address = %s, want %s", audit.Address, server.Listener.Addr())
+	}
+	if audit.Version != "TLS 1.3" || audit.CipherSuite == "" {
+		t.Errorf("version = %q, cipher suite = %q", audit.Version, audit.CipherSuite)
+	}
+	if len(audit.Certificates) != 1 || audit.ExpiresAt == nil || !audit.ExpiresAt.Equal(audit.Certificates[0].NotAfter) {
+		t.Fatalf("unexpected certificates: %+v, expires at %v", audit.Certificates, audit.ExpiresAt)
+	}
+	if audit.DaysUntilExpiry <= 0 {
+		t.Errorf("days until expiry = %d", audit.DaysUntilExpiry)
+	}
+	// The test certificate is not signed by a trusted root
+	if audit.Verified || audit.VerifyError == "" || len(audit.Issues) != 1 {
+		t.Errorf("verified = %v, verify error = %q, issues = %v", audit.Verified, audit.VerifyError, audit.Issues)
+	}
+
+	audit, err = AuditTLS(context.Background(), server.URL, models.TLSConfig{InsecureSkipVerify: true}, now)
+	if err != nil || audit.Verified || len(audit.Issues) != 0 {
+		t.Errorf("with insecure_skip_verify: err = %v, verified = %v, issues = %v", err, audit.Verified, audit.Issues)
+	}
+
+	// Certificates expired by the time of the audit are reported
+	audit, err = AuditTLS(context.Background(), server.URL, models.TLSConfig{InsecureSkipVerify: true}, audit.ExpiresAt.Add(48*time.Hour))
+	if err != nil || audit.DaysUntilExpiry != -2 || len(audit.Issues) != 1 {
+		t.Errorf("expired: err = %v, days until expiry = %d, issues = %v", err, audit.DaysUntilExpiry, audit.Issues)
+	}
+}
+
+func TestAuditTLSErrors(t *testing.T) {
+	if _, err := AuditTLS(context.Background(), "http://example.com", models.TLSConfig{}, time.Now()); !errors.Is(err, ErrNoTLS) {
+		t.Errorf("expected ErrNoTLS for http, got %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	audit, err := AuditTLS(context.Background(), "https://"+addr, models.TLSConfig{}, time.Now())
+	if err != nil || audit.Error == "" || audit.Address != addr {
+		t.Errorf("expected the connection error in the audit, got %+v, %v", audit, err)
+	}
+}
@@ -0,0 +1,75 @@
+package testrunner
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gavv/httpexpect/v2"
+)
+
+// traceParentHeader is the W3C Trace Context header
+const traceParentHeader = "traceparent"
+
+var traceParentPattern = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+type traceParentKey struct{}
+
+// ContextWithTraceParent returns a context carrying a W3C traceparent value,
+// typically taken from the API request that started an execution. Invalid
+// values are ignored.
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	traceParent = strings.ToLower(strings.TrimSpace(traceParent))
+	if !traceParentPattern.MatchString(traceParent) {
+		return ctx
+	}
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// TraceParentFromContext returns the traceparent carried by ctx, if any
+func TraceParentFromContext(ctx context.Context) string {
+	traceParent, _ := ctx.Value(traceParentKey{}).(string)
+	return traceParent
+}
+
+// applyTraceParent propagates the trace of ctx to an outgoing request as a
+// child span, unless the test sets its own traceparent header
+func applyTraceParent(ctx context.Context, req *httpexpect.Request, headers map[string]interface{}) *httpexpect.Request {
+	for name := range headers {
+		if strings.EqualFold(name, traceParentHeader) {
+			return req
+		}
+	}
+	if traceParent := childTraceParent(ctx); traceParent != "" {
+		return req.WithHeader(traceParentHeader, traceParent)
+	}
+	return req
+}
+
+// applyTraceParentHeader is applyTraceParent for plain HTTP headers
+func applyTraceParentHeader(ctx context.Context, header http.Header) {
+	if header.Get(traceParentHeader) != "" {
+		return
+	}
+	if traceParent := childTraceParent(ctx); traceParent != "" {
+		header.Set(traceParentHeader, traceParent)
+	}
+}
+
+// childTraceParent returns the traceparent of a new span in the trace of ctx,
+// or an empty string without a trace
+func childTraceParent(ctx context.Context) string {
+	parent := TraceParentFromContext(ctx)
+	if parent == "" {
+		return ""
+	}
+	parts := traceParentPattern.FindStringSubmatch(parent)
+	spanID := make([]byte, 8)
+	if _, err := rand.Read(spanID); err != nil {
+		return ""
+	}
+	return parts[1] + "-" + parts[2] + "-" + hex.EncodeToString(spanID) + "-" + parts[4]
+}
@@ -0,0 +1,128 @@
+package testrunner
+
+import (
+	"regexp"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// variablePattern matches {{name}} placeholders, allowing dotted names
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// ApplyVariables substitutes {{name}} placeholders in the request URL, headers,
+// body and assertion expectations of the test spec, and expands {{$name args}}
+// generators. Unknown placeholders are left untouched.
+func ApplyVariables(testSpec *models.TestSpec, vars map[string]string) {
+	testSpec.Request.URL = substitute(testSpec.Request.URL, vars)
+	testSpec.Request.Headers = substituteHeaders(testSpec.Request.Headers, vars)
+	testSpec.Request.Body = substituteValue(testSpec.Request.Body, vars)
+	if graphQL := testSpec.Request.GraphQL; graphQL != nil && graphQL.Variables != nil {
+		graphQL.Variables, _ = substituteValue(graphQL.Variables, vars).(map[string]interface{})
+	}
+	if script := testSpec.Request.WebSocket; script != nil {
+		for i := range script.Steps {
+			script.Steps[i].Send = substituteValue(script.Steps[i].Send, vars)
+			if receive := script.Steps[i].Receive; receive != nil {
+				for j := range receive.Assertions {
+					receive.Assertions[j].Expected = substituteValue(receive.Assertions[j].Expected, vars)
+				}
+			}
+		}
+	}
+	if auth := testSpec.Request.Auth; auth != nil {
+		auth.Token = substitute(auth.Token, vars)
+		auth.KeyValue = substitute(auth.KeyValue, vars)
+		auth.Username = substitute(auth.Username, vars)
+		auth.Password = substitute(auth.Password, vars)
+		auth.ClientSecret = substitute(auth.ClientSecret, vars)
+	}
+
+	for i := range testSpec.Assertions {
+		testSpec.Assertions[i].Expected = substituteValue(testSpec.Assertions[i].Expected, vars)
+	}
+	if testSpec.PollUntil != nil {
+		poll := *testSpec.PollUntil
+		poll.Expected = substituteValue(poll.Expected, vars)
+		testSpec.PollUntil = &poll
+	}
+
+	if conflict := testSpec.Conflict; conflict != nil {
+		substituted := *conflict
+		substituted.Update = substituteRequest(conflict.Update, vars)
+		if conflict.SecondUpdate != nil {
+			second := substituteRequest(*conflict.SecondUpdate, vars)
+			substituted.SecondUpdate = &second
+		}
+		testSpec.Conflict = &substituted
+	}
+
+	for i := range testSpec.Variants {
+		testSpec.Variants[i].Headers = substituteHeaders(testSpec.Variants[i].Headers, vars)
+		for j := range testSpec.Variants[i].Assertions {
+			testSpec.Variants[i].Assertions[j].Expected = substituteValue(testSpec.Variants[i].Assertions[j].Expected, vars)
+		}
+	}
+}
+
+// substituteRequest returns a copy of the URL, headers and body of a request
+// with placeholders replaced, e.g. for the updates of a conflict test
+func substituteRequest(request models.RequestSpec, vars map[string]string) models.RequestSpec {
+	request.URL = substitute(request.URL, vars)
+	request.Headers = substituteHeaders(request.Headers, vars)
+	request.Body = substituteValue(request.Body, vars)
+	return request
+}
+
+// substitute replaces the placeholders of a single string
+func substitute(s string, vars map[string]string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	s = variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := variablePattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+	return substituteGenerators(s)
+}
+
+// substituteHeaders returns a copy of headers with placeholders replaced in the values
+func substituteHeaders(headers map[string]string, vars map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	substituted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		substituted[key] = substitute(value, vars)
+	}
+	return substituted
+}
+
+// substituteValue walks a decoded JSON value and replaces placeholders in every string
+func substituteValue(value interface{}, vars map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		// A lone generator keeps its type, e.g. an array for {{$array 100}}
+		if generated, ok := generatedValue(v); ok {
+			return generated
+		}
+		return substitute(v, vars)
+	case map[string]interface{}:
+		substituted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			substituted[key] = substituteValue(item, vars)
+		}
+		return substituted
+	case []interface{}:
+		substituted := make([]interface{}, len(v))
+		for i, item := range v {
+			substituted[i] = substituteValue(item, vars)
+		}
+		return substituted
+	default:
+		return value
+	}
+}
@@ -0,0 +1,101 @@
+package testrunner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"api-test-framework/internal/models"
+)
+
+// VariantResult represents the outcome of one variant of a request matrix
+type VariantResult struct {
+	Name             string            `json:"name"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	Status           string            `json:"status"`
+	Duration         time.Duration     `json:"duration"`
+	ErrorMessage     string            `json:"error_message,omitempty"`
+	FailureType      string            `json:"failure_type,omitempty"`
+	FailureDetail    string            `json:"failure_detail,omitempty"`
+	ResponseData     json.RawMessage   `json:"response_data,omitempty"`
+	AssertionResults []AssertionResult `json:"assertion_results,omitempty"`
+}
+
+// executeVariants repeats the request once per variant of the test spec and
+// folds the per-variant outcomes into a single result. The test fails if any
+// variant fails.
+func (e *HTTPExpectExecutor) executeVariants(ctx context.Context, testSpec *models.TestSpec) *TestResult {
+	start := time.Now()
+
+	result := &TestResult{
+		TestName:  testSpec.Name,
+		StartTime: start,
+		Status:    "PASSED",
+	}
+
+	for i, variant := range testSpec.Variants {
+		if ctx.Err() != nil {
+			break
+		}
+
+		name := variant.Name
+		if name == "" {
+			name = fmt.Sprintf("variant-%d", i+1)
+		}
+
+		variantSpec := *testSpec
+		variantSpec.Variants = nil
+		variantSpec.Request.Headers = mergeHeaders(testSpec.Request.Headers, variant.Headers)
+		variantSpec.Assertions = append(append([]models.AssertionSpec{}, testSpec.Assertions...), variant.Assertions...)
+
+		variantResult := e.executePolled(ctx, &variantSpec)
+
+		vr := VariantResult{
+			Name:             name,
+			Headers:          variant.Headers,
+			Status:           variantResult.Status,
+			Duration:         variantResult.Duration,
+			ErrorMessage:     variantResult.ErrorMessage,
+			FailureType:      variantResult.FailureType,
+			FailureDetail:    variantResult.FailureDetail,
+			AssertionResults: variantResult.AssertionResults,
+		}
+		if variantResult.ResponseData != "" {
+			vr.ResponseData = json.RawMessage(variantResult.ResponseData)
+		}
+		result.VariantResults = append(result.VariantResults, vr)
+
+		if variantResult.Status == "FAILED" {
+			result.Status = "FAILED"
+			if result.ErrorMessage == "" {
+				result.ErrorMessage = fmt.Sprintf("variant '%s': %s", name, variantResult.ErrorMessage)
+				result.FailureType = variantResult.FailureType
+				result.FailureDetail = variantResult.FailureDetail
+			}
+		}
+	}
+
+	// Store every variant so the per-variant breakdown survives persistence
+	responseBytes, err := json.Marshal(map[string]interface{}{
+		"variants": result.VariantResults,
+	})
+	if err == nil {
+		result.ResponseData = string(responseBytes)
+	}
+
+	result.Duration = time.Since(start)
+	return result
+}
+
+// mergeHeaders returns a copy of base with the override headers applied on top
+func mergeHeaders(base, override map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
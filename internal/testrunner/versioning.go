@@ -0,0 +1,68 @@
+package testrunner
+
+import (
+	"strings"
+
+	"api-test-framework/internal/models"
+
+	"github.com/gavv/httpexpect/v2"
+)
+
+// Default names used to carry the API version
+const (
+	defaultVersionHeader = "Accept-Version"
+	defaultVersionQuery  = "version"
+)
+
+// WithAPIVersion pins the API version every request is sent with, using the
+// strategy of the service versioning configuration
+func (e *HTTPExpectExecutor) WithAPIVersion(versioning models.APIVersioning, version string) *HTTPExpectExecutor {
+	e.versioning = versioning
+	e.apiVersion = version
+	return e
+}
+
+// versionedURL prefixes relative request paths with the API version when the
+// service uses path based versioning. Paths that already carry the version
+// (e.g. through the {{api_version}} variable) are left untouched.
+func (e *HTTPExpectExecutor) versionedURL(url string) string {
+	if e.apiVersion == "" || e.versioning.Strategy != "path" || !strings.HasPrefix(url, "/") {
+		return url
+	}
+
+	prefix := "/" + strings.Trim(e.apiVersion, "/")
+	if url == prefix || strings.HasPrefix(url, prefix+"/") || strings.HasPrefix(url, prefix+"?") {
+		return url
+	}
+	return prefix + url
+}
+
+// applyAPIVersion injects the API version as a header or query parameter.
+// A version header set explicitly by the test takes precedence.
+func (e *HTTPExpectExecutor) applyAPIVersion(req *httpexpect.Request, headers map[string]interface{}) *httpexpect.Request {
+	if e.apiVersion == "" {
+		return req
+	}
+
+	switch e.versioning.Strategy {
+	case "header":
+		name := e.versioning.HeaderName
+		if name == "" {
+			name = defaultVersionHeader
+		}
+		for key := range headers {
+			if strings.EqualFold(key, name) {
+				return req
+			}
+		}
+		return req.WithHeader(name, e.apiVersion)
+	case "query":
+		param := e.versioning.QueryParam
+		if param == "" {
+			param = defaultVersionQuery
+		}
+		return req.WithQuery(param, e.apiVersion)
+	default:
+		return req
+	}
+}
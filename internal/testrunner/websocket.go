@@ -0,0 +1,373 @@
+package testrunner
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"api-test-framework/internal/models"
+
+	"github.com/gorilla/websocket"
+	"github.com/tidwall/gjson"
+)
+
+// defaultFrameTimeout is how long a receive step waits without a timeout
+const defaultFrameTimeout = 5 * time.Second
+
+// webSocketFrame is a frame received from the server, or the error that
+// ended the connection
+type webSocketFrame struct {
+	payload string // text, or base64 for binary frames
+	err     error
+}
+
+// WebSocketExecutor executes websocket tests: it connects to the request URL,
+// plays the steps of the script, and asserts on the frames received
+type WebSocketExecutor struct {
+	baseURL       string
+	serviceID     string
+	authConfig    models.AuthConfig
+	tokenProvider *OAuth2TokenProvider
+	tlsConfig     models.TLSConfig
+}
+
+// newWebSocketExecutor creates the websocket executor
+func newWebSocketExecutor(config ExecutorConfig) (Executor, error) {
+	return &WebSocketExecutor{
+		baseURL:       config.BaseURL,
+		serviceID:     config.ServiceID,
+		authConfig:    config.AuthConfig,
+		tokenProvider: config.TokenProvider,
+		tlsConfig:     config.TLS,
+	}, nil
+}
+
+// ExecuteTest connects to the websocket of the test spec and plays its script
+func (e *WebSocketExecutor) ExecuteTest(ctx context.Context, testSpec *models.TestSpec) *TestResult {
+	start := time.Now()
+	result := &TestResult{
+		TestName:  testSpec.Name,
+		StartTime: start,
+		Status:    "PASSED",
+	}
+	fail := func(failureType, format string, args ...interface{}) *TestResult {
+		result.Status = "FAILED"
+		result.ErrorMessage = fmt.Sprintf(format, args...)
+		result.FailureType = failureType
+		result.Duration = time.Since(start)
+		return result
+	}
+
+	script := testSpec.Request.WebSocket
+	if script == nil || len(script.Steps) == 0 {
+		return fail(FailureSpec, "websocket tests require request.websocket.steps")
+	}
+	if testSpec.Assertions == nil && !receivesFrames(script) {
+		return fail(FailureSpec, "No assertions found")
+	}
+
+	target, err := webSocketURL(e.baseURL, testSpec.Request.URL)
+	if err != nil {
+		return fail(FailureSpec, "%v", err)
+	}
+	header := http.Header{}
+	for name, value := range testSpec.Request.Headers {
+		header.Set(name, value)
+	}
+	authConfig := e.authConfig
+	if testSpec.Request.Auth != nil {
+		authConfig = *testSpec.Request.Auth
+	}
+	credential, err := authCredential(ctx, authConfig, e.tokenProvider, e.serviceID)
+	if err != nil {
+		return fail(FailureSpec, "Failed to apply authentication: %v", err)
+	}
+	if credential.inQuery {
+		query := target.Query()
+		query.Set(credential.name, credential.value)
+		target.RawQuery = query.Encode()
+	} else if credential.name != "" {
+		header.Set(credential.name, credential.value)
+	}
+	applyTraceParentHeader(ctx, header)
+
+	// The handshake is bounded by the deadline of the test
+	dialer := websocket.Dialer{
+		Proxy:           egressProxyFunc(),
+		NetDialContext:  dialEgress,
+		TLSClientConfig: tlsClientConfig(e.tlsConfig),
+		Subprotocols:    script.Subprotocols,
+	}
+	dialStart := time.Now()
+	conn, resp, err := dialer.DialContext(ctx, target.String(), header)
+	handshake := time.Since(dialStart)
+
+	responseData := map[string]interface{}{
+		"frames":      []interface{}{},
+		"frame_count": 0,
+	}
+	if resp != nil {
+		responseData["status_code"] = resp.StatusCode
+		responseData["headers"] = resp.Header
+	}
+	if err != nil {
+		if resp == nil {
+			result.FailureDetail = NetworkErrorDetail(err)
+			return fail(ClassifyError(err), "WebSocket connection failed: %v", err)
+		}
+		// A rejected handshake passes when the test expects the status
+		encoded, _ := json.Marshal(responseData)
+		result.ResponseData = string(encoded)
+		if !assertsStatus(testSpec) {
+			return fail(ClassifyStatus(resp.StatusCode), "WebSocket handshake failed with status %d", resp.StatusCode)
+		}
+		e.assert(result, testSpec.Assertions, encoded, resp.StatusCode, 0, handshake)
+		result.Duration = time.Since(start)
+		return result
+	}
+	defer conn.Close()
+	responseData["subprotocol"] = conn.Subprotocol()
+
+	frames := make(chan webSocketFrame, 64)
+	done := make(chan struct{})
+	defer close(done)
+	go readFrames(conn, frames, done)
+
+	var received []string
+	failure := play(ctx, conn, script, frames, result, &received)
+
+	// Close the connection cleanly; the server may already have closed it
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+
+	decoded := make([]interface{}, len(received))
+	for i, payload := range received {
+		decoded[i] = framePayload(payload).Value()
+	}
+	responseData["frames"] = decoded
+	responseData["frame_count"] = len(received)
+	encoded, _ := json.Marshal(responseData)
+	result.ResponseData = string(encoded)
+
+	if failure != nil {
+		return fail(failure.failureType, "%s", failure.message)
+	}
+	e.assert(result, testSpec.Assertions, encoded, resp.StatusCode, len(received), handshake)
+	result.Duration = time.Since(start)
+	return result
+}
+
+// scriptFailure ends the script of a websocket test
+type scriptFailure struct {
+	failureType string
+	message     string
+}
+
+// play sends the messages and waits for the frames of a script, recording the
+// frames received and the assertions made on them
+func play(ctx context.Context, conn *websocket.Conn, script *models.WebSocketScript, frames <-chan webSocketFrame, result *TestResult, received *[]string) *scriptFailure {
+	for i, step := range script.Steps {
+		stepName := fmt.Sprintf("step %d", i+1)
+		if step.Send == nil && step.Receive == nil {
+			return &scriptFailure{FailureSpec, stepName + " has neither send nor receive"}
+		}
+
+		if step.Send != nil {
+			message, ok := step.Send.(string)
+			if !ok {
+				encoded, err := json.Marshal(step.Send)
+				if err != nil {
+					return &scriptFailure{FailureSpec, fmt.Sprintf("%s: invalid message: %v", stepName, err)}
+				}
+				message = string(encoded)
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+				return &scriptFailure{FailureConnection, fmt.Sprintf("%s: failed to send message: %v", stepName, err)}
+			}
+		}
+		if step.Receive != nil {
+			if failure := receiveFrames(ctx, stepName, step.Receive, frames, result, received); failure != nil {
+				return failure
+			}
+		}
+	}
+	return nil
+}
+
+// receiveFrames waits for the frames of a receive step and asserts on them
+func receiveFrames(ctx context.Context, stepName string, receive *models.WebSocketReceive, frames <-chan webSocketFrame, result *TestResult, received *[]string) *scriptFailure {
+	timeout := defaultFrameTimeout
+	if receive.TimeoutMs > 0 {
+		timeout = time.Duration(receive.TimeoutMs) * time.Millisecond
+	}
+	count := receive.Count
+	if count <= 0 {
+		count = 1
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for n := 0; receive.None || n < count; n++ {
+		var frame webSocketFrame
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return &scriptFailure{FailureTimeout, fmt.Sprintf("%s: %v", stepName, ctx.Err())}
+		case <-timer.C:
+			if receive.None {
+				return nil
+			}
+			return &scriptFailure{FailureTimeout, fmt.Sprintf("%s: expected %d frames within %s, received %d", stepName, count, timeout, n)}
+		case frame, ok = <-frames:
+		}
+		if !ok && receive.None {
+			// Closed normally without sending anything
+			return nil
+		}
+		if !ok || frame.err != nil {
+			return &scriptFailure{FailureConnection, fmt.Sprintf("%s: connection closed while waiting for frames: %v", stepName, frame.err)}
+		}
+
+		*received = append(*received, frame.payload)
+		if receive.None {
+			return &scriptFailure{FailureAssertion, fmt.Sprintf("%s: expected no frame within %s, received %s", stepName, timeout, frame.payload)}
+		}
+		for _, assertion := range receive.Assertions {
+			assertionResult := AssertionResult{Type: assertion.Type, Path: assertion.Path, Matcher: assertion.Type}
+			assertValue(&assertionResult, frameValue(frame.payload, assertion.Path), assertion.Type, assertion.Expected, assertion.Normalize)
+			explainFailure(&assertionResult, assertion.Message)
+			result.AssertionResults = append(result.AssertionResults, assertionResult)
+			if !assertionResult.Passed {
+				return &scriptFailure{FailureAssertion, fmt.Sprintf("%s, frame %d: %s", stepName, n+1, assertionResult.Message)}
+			}
+		}
+	}
+	return nil
+}
+
+// assert evaluates the assertions of a websocket test on the conversation.
+// status_code is the handshake status, count the number of frames received,
+// and exists, equals, contains and regex paths address the response data.
+func (e *WebSocketExecutor) assert(result *TestResult, assertions []models.AssertionSpec, responseData []byte, statusCode, frameCount int, handshake time.Duration) {
+	for _, assertionSpec := range assertions {
+		assertionResult := AssertionResult{Type: assertionSpec.Type, Path: assertionSpec.Path, Expected: assertionSpec.Expected, Passed: true}
+		switch assertionSpec.Type {
+		case "status_code", "status_class":
+			var assertion map[string]interface{}
+			encoded, _ := json.Marshal(assertionSpec)
+			json.Unmarshal(encoded, &assertion)
+			assertStatus(&assertionResult, statusCode, assertion)
+		case "count":
+			expected, _ := assertionSpec.Expected.(float64)
+			assertionResult.Actual = frameCount
+			assertionResult.Passed = int(expected) == frameCount
+			if !assertionResult.Passed {
+				assertionResult.Message = fmt.Sprintf("Expected %v frames, received %d", assertionSpec.Expected, frameCount)
+			}
+		case "exists", "equals", "contains", "regex":
+			assertionResult.Matcher = assertionSpec.Type
+			assertValue(&assertionResult, gjson.GetBytes(responseData, assertionSpec.Path), assertionSpec.Type, assertionSpec.Expected, assertionSpec.Normalize)
+		case "response_time", "latency_budget":
+			var assertion map[string]interface{}
+			encoded, _ := json.Marshal(assertionSpec)
+			json.Unmarshal(encoded, &assertion)
+			assertResponseTime(&assertionResult, handshake, assertion)
+		default:
+			assertionResult.Passed = false
+			assertionResult.Message = fmt.Sprintf("Unknown assertion type: %s", assertionSpec.Type)
+		}
+		explainFailure(&assertionResult, assertionSpec.Message)
+
+		result.AssertionResults = append(result.AssertionResults, assertionResult)
+		if !assertionResult.Passed {
+			result.Status = "FAILED"
+			result.ErrorMessage = assertionResult.Message
+			result.FailureType = FailureAssertion
+		}
+	}
+}
+
+// readFrames forwards the frames of a connection until it fails or closes,
+// or done is closed
+func readFrames(conn *websocket.Conn, frames chan<- webSocketFrame, done <-chan struct{}) {
+	defer close(frames)
+	for {
+		var frame webSocketFrame
+		messageType, data, err := conn.ReadMessage()
+		switch {
+		case websocket.IsCloseError(err, websocket.CloseNormalClosure):
+			return
+		case err != nil:
+			frame.err = err
+		case messageType == websocket.BinaryMessage:
+			frame.payload = base64.StdEncoding.EncodeToString(data)
+		default:
+			frame.payload = string(data)
+		}
+
+		select {
+		case frames <- frame:
+		case <-done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// receivesFrames reports whether a script waits for frames
+func receivesFrames(script *models.WebSocketScript) bool {
+	for _, step := range script.Steps {
+		if step.Receive != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// framePayload returns the payload of a frame: the parsed JSON, or the text
+// itself when it is not JSON
+func framePayload(payload string) gjson.Result {
+	if gjson.Valid(payload) {
+		return gjson.Parse(payload)
+	}
+	encoded, _ := json.Marshal(payload)
+	return gjson.ParseBytes(encoded)
+}
+
+// frameValue returns the value at a path of the payload of a frame, or the
+// whole payload for an empty path
+func frameValue(payload, path string) gjson.Result {
+	if path == "" {
+		return framePayload(payload)
+	}
+	return gjson.Get(payload, path)
+}
+
+// webSocketURL resolves the request URL against the base URL of the service,
+// using ws and wss for http and https
+func webSocketURL(baseURL, path string) (*neturl.URL, error) {
+	raw := path
+	if !strings.Contains(path, "://") {
+		raw = strings.TrimRight(baseURL, "/") + "/" + strings.TrimLeft(path, "/")
+	}
+	target, err := neturl.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid websocket URL %q: %v", raw, err)
+	}
+	switch target.Scheme {
+	case "http":
+		target.Scheme = "ws"
+	case "https":
+		target.Scheme = "wss"
+	case "ws", "wss":
+	default:
+		return nil, fmt.Errorf("invalid websocket URL %q: scheme must be ws, wss, http or https", raw)
+	}
+	return target, nil
+}
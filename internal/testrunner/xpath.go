@@ -0,0 +1,455 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is an element, attribute or text node of a parsed XML document.
+// The document itself is an element without name.
+type xmlNode struct {
+	space    string // namespace URI
+	local    string
+	text     string // value of attribute and text nodes
+	kind     xmlNodeKind
+	attrs    []*xmlNode
+	children []*xmlNode
+	parent   *xmlNode
+}
+
+type xmlNodeKind int
+
+const (
+	xmlElement xmlNodeKind = iota
+	xmlAttribute
+	xmlText
+)
+
+// xmlDocument is a parsed XML document with the namespace prefixes it declares
+type xmlDocument struct {
+	root     *xmlNode
+	prefixes map[string]string
+}
+
+// parseXML parses an XML document. Namespaces of element and attribute names
+// are resolved to their URIs.
+func parseXML(content string) (*xmlDocument, error) {
+	doc := &xmlDocument{root: &xmlNode{}, prefixes: map[string]string{}}
+	decoder := xml.NewDecoder(strings.NewReader(content))
+	decoder.Strict = false
+	current := doc.root
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			element := &xmlNode{space: token.Name.Space, local: token.Name.Local, parent: current}
+			for _, attr := range token.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					doc.prefixes[attr.Name.Local] = attr.Value
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+				default:
+					element.attrs = append(element.attrs, &xmlNode{space: attr.Name.Space, local: attr.Name.Local, text: attr.Value, kind: xmlAttribute, parent: element})
+				}
+			}
+			current.children = append(current.children, element)
+			current = element
+		case xml.EndElement:
+			if current.parent != nil {
+				current = current.parent
+			}
+		case xml.CharData:
+			current.children = append(current.children, &xmlNode{text: string(token), kind: xmlText, parent: current})
+		}
+	}
+	if len(doc.root.children) == 0 {
+		return nil, fmt.Errorf("document has no root element")
+	}
+	return doc, nil
+}
+
+// value returns the string value of a node: the text of attribute and text
+// nodes, and the concatenated text of the descendants of elements
+func (n *xmlNode) value() string {
+	if n.kind != xmlElement {
+		return n.text
+	}
+	var text strings.Builder
+	var collect func(*xmlNode)
+	collect = func(node *xmlNode) {
+		for _, child := range node.children {
+			if child.kind == xmlText {
+				text.WriteString(child.text)
+			} else {
+				collect(child)
+			}
+		}
+	}
+	collect(n)
+	return text.String()
+}
+
+// descendants returns the elements below a node in document order
+func (n *xmlNode) descendants() []*xmlNode {
+	var nodes []*xmlNode
+	for _, child := range n.children {
+		if child.kind == xmlElement {
+			nodes = append(nodes, child)
+			nodes = append(nodes, child.descendants()...)
+		}
+	}
+	return nodes
+}
+
+// xpathStep is a location step of a path
+type xpathStep struct {
+	descendant bool // preceded by //
+	test       string
+	predicates []string
+}
+
+// xpathPredicate matches the predicates this evaluator supports: a position,
+// last(), or a comparison of an attribute, child element, text() or
+// local-name() with a literal, or the existence of an attribute or child
+var xpathPredicate = regexp.MustCompile(`^\s*(@?[\w.:*-]+|text\(\)|local-name\(\))\s*(=\s*(?:'([^']*)'|"([^"]*)"))?\s*$`)
+
+// evaluateXPath selects the nodes of a document matching a path. It supports
+// absolute and relative location paths with the child (/), descendant (//),
+// self (.), parent (..) and attribute (@) axes, the * wildcard, text(), and
+// the predicates described at xpathPredicate. Prefixed names are resolved
+// with namespaces, then with the prefixes declared by the document; names
+// without prefix match elements of any namespace.
+func evaluateXPath(doc *xmlDocument, path string, namespaces map[string]string) ([]*xmlNode, error) {
+	steps, err := parseXPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	context := []*xmlNode{doc.root}
+	for _, step := range steps {
+		var next []*xmlNode
+		seen := map[*xmlNode]bool{}
+		for _, node := range context {
+			origins := []*xmlNode{node}
+			if step.descendant {
+				origins = append(origins, node.descendants()...)
+			}
+			for _, origin := range origins {
+				selected, err := selectStep(origin, step, doc, namespaces)
+				if err != nil {
+					return nil, err
+				}
+				for _, match := range selected {
+					if !seen[match] {
+						seen[match] = true
+						next = append(next, match)
+					}
+				}
+			}
+		}
+		context = next
+	}
+	return context, nil
+}
+
+// parseXPath splits a path into its location steps
+func parseXPath(path string) ([]xpathStep, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("empty xpath")
+	}
+
+	var steps []xpathStep
+	descendant := false
+	if strings.HasPrefix(path, "//") {
+		descendant = true
+		path = path[2:]
+	} else {
+		path = strings.TrimPrefix(path, "/")
+	}
+
+	for path != "" {
+		// Find the end of the step, skipping predicates and quoted literals
+		end, depth := len(path), 0
+		var quote byte
+	scan:
+		for i := 0; i < len(path); i++ {
+			switch c := path[i]; {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"':
+				quote = c
+			case c == '[':
+				depth++
+			case c == ']':
+				depth--
+			case c == '/' && depth == 0:
+				end = i
+				break scan
+			}
+		}
+		if depth != 0 || quote != 0 {
+			return nil, fmt.Errorf("unbalanced predicate in xpath")
+		}
+
+		raw := strings.TrimSpace(path[:end])
+		if raw == "" {
+			return nil, fmt.Errorf("empty step in xpath")
+		}
+		step := xpathStep{descendant: descendant, test: raw}
+		if idx := strings.Index(raw, "["); idx >= 0 {
+			step.test = strings.TrimSpace(raw[:idx])
+			step.predicates = strings.Split(strings.TrimSuffix(raw[idx+1:], "]"), "][")
+		}
+		steps = append(steps, step)
+
+		path = path[end:]
+		descendant = strings.HasPrefix(path, "//")
+		if descendant {
+			path = path[2:]
+		} else {
+			path = strings.TrimPrefix(path, "/")
+		}
+	}
+	return steps, nil
+}
+
+// selectStep applies a location step to a node
+func selectStep(node *xmlNode, step xpathStep, doc *xmlDocument, namespaces map[string]string) ([]*xmlNode, error) {
+	var candidates []*xmlNode
+	switch {
+	case step.test == ".":
+		candidates = []*xmlNode{node}
+	case step.test == "..":
+		if node.parent != nil {
+			candidates = []*xmlNode{node.parent}
+		}
+	case step.test == "text()":
+		for _, child := range node.children {
+			if child.kind == xmlText {
+				candidates = append(candidates, child)
+			}
+		}
+	case strings.HasPrefix(step.test, "@"):
+		for _, attr := range node.attrs {
+			matches, err := nameMatches(attr, step.test[1:], doc, namespaces)
+			if err != nil {
+				return nil, err
+			}
+			if matches {
+				candidates = append(candidates, attr)
+			}
+		}
+	default:
+		for _, child := range node.children {
+			if child.kind != xmlElement {
+				continue
+			}
+			matches, err := nameMatches(child, step.test, doc, namespaces)
+			if err != nil {
+				return nil, err
+			}
+			if matches {
+				candidates = append(candidates, child)
+			}
+		}
+	}
+
+	for _, predicate := range step.predicates {
+		filtered, err := filterPredicate(candidates, predicate, doc, namespaces)
+		if err != nil {
+			return nil, err
+		}
+		candidates = filtered
+	}
+	return candidates, nil
+}
+
+// filterPredicate keeps the candidates satisfying a predicate
+func filterPredicate(candidates []*xmlNode, predicate string, doc *xmlDocument, namespaces map[string]string) ([]*xmlNode, error) {
+	predicate = strings.TrimSpace(predicate)
+	if position, err := strconv.Atoi(predicate); err == nil {
+		if position < 1 || position > len(candidates) {
+			return nil, nil
+		}
+		return candidates[position-1 : position], nil
+	}
+	if predicate == "last()" {
+		if len(candidates) == 0 {
+			return nil, nil
+		}
+		return candidates[len(candidates)-1:], nil
+	}
+
+	parts := xpathPredicate.FindStringSubmatch(predicate)
+	if parts == nil {
+		return nil, fmt.Errorf("unsupported xpath predicate [%s]", predicate)
+	}
+	operand, compare, literal := parts[1], parts[2] != "", parts[3]+parts[4]
+
+	var kept []*xmlNode
+	for _, candidate := range candidates {
+		var values []string
+		switch operand {
+		case "text()":
+			values = []string{candidate.value()}
+		case "local-name()":
+			values = []string{candidate.local}
+		default:
+			selected, err := selectStep(candidate, xpathStep{test: operand}, doc, namespaces)
+			if err != nil {
+				return nil, err
+			}
+			for _, node := range selected {
+				values = append(values, node.value())
+			}
+		}
+
+		for _, value := range values {
+			if !compare || strings.TrimSpace(value) == literal {
+				kept = append(kept, candidate)
+				break
+			}
+		}
+	}
+	return kept, nil
+}
+
+// nameMatches reports whether a node matches a name test such as *, name,
+// prefix:name or prefix:*
+func nameMatches(node *xmlNode, test string, doc *xmlDocument, namespaces map[string]string) (bool, error) {
+	prefix, local, prefixed := strings.Cut(test, ":")
+	if !prefixed {
+		return test == "*" || node.local == test, nil
+	}
+
+	space, ok := namespaces[prefix]
+	if !ok {
+		if space, ok = doc.prefixes[prefix]; !ok {
+			return false, fmt.Errorf("unknown namespace prefix %q in xpath", prefix)
+		}
+	}
+	return node.space == space && (local == "*" || node.local == local), nil
+}
+
+// assertXPath checks the nodes an XPath selects in an XML response body. The
+// matcher is exists, not_exists, equals, contains, regex or count; without
+// one, exists is used when nothing is expected and equals otherwise. Values
+// are compared with surrounding whitespace trimmed, and numeric expectations
+// numerically. Namespace prefixes are taken from the assertion's namespaces,
+// and normalize options apply to string comparisons.
+func assertXPath(result *AssertionResult, body interface{}, assertion map[string]interface{}) {
+	result.Path, _ = assertion["path"].(string)
+	result.Expected = expectedValue(assertion)
+	result.Matcher, _ = assertion["matcher"].(string)
+	if result.Matcher == "" {
+		result.Matcher = "equals"
+		if result.Expected == nil {
+			result.Matcher = "exists"
+		}
+	}
+
+	normalize, err := newTextNormalizer(normalization(assertion))
+	if err != nil {
+		result.Passed = false
+		result.Message = err.Error()
+		return
+	}
+
+	content, _ := body.(string)
+	doc, err := parseXML(content)
+	if err != nil {
+		result.Passed = false
+		result.Message = fmt.Sprintf("Response is not valid XML: %v", err)
+		return
+	}
+	namespaces := map[string]string{}
+	if declared, ok := assertion["namespaces"].(map[string]interface{}); ok {
+		for prefix, uri := range declared {
+			namespaces[prefix] = fmt.Sprint(uri)
+		}
+	}
+	nodes, err := evaluateXPath(doc, result.Path, namespaces)
+	if err != nil {
+		result.Passed = false
+		result.Message = fmt.Sprintf("Invalid xpath '%s': %v", result.Path, err)
+		return
+	}
+
+	var actual string
+	if len(nodes) > 0 {
+		actual = strings.TrimSpace(nodes[0].value())
+		result.Actual = actual
+	}
+	switch result.Matcher {
+	case "exists":
+		result.Passed = len(nodes) > 0
+		if !result.Passed {
+			result.Message = fmt.Sprintf("XPath '%s' selects no node", result.Path)
+		}
+	case "not_exists":
+		result.Passed = len(nodes) == 0
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected xpath '%s' to select no node, selected %d", result.Path, len(nodes))
+		}
+	case "count":
+		result.Actual = len(nodes)
+		expected, _ := result.Expected.(float64)
+		result.Passed = int(expected) == len(nodes)
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected xpath '%s' to select %v nodes, selected %d", result.Path, result.Expected, len(nodes))
+		}
+	case "equals":
+		result.Passed = len(nodes) > 0 && xmlValueEquals(normalize.text(actual), normalize.value(result.Expected))
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected '%v', got '%s' for xpath '%s'", result.Expected, actual, result.Path)
+		}
+	case "contains":
+		result.Passed = len(nodes) > 0 && strings.Contains(normalize.text(actual), normalize.text(fmt.Sprint(result.Expected)))
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected to contain '%v', got '%s' for xpath '%s'", result.Expected, actual, result.Path)
+		}
+	case "regex":
+		pattern, err := regexp.Compile(fmt.Sprint(result.Expected))
+		if err != nil {
+			result.Passed = false
+			result.Message = fmt.Sprintf("Invalid regex '%v': %v", result.Expected, err)
+			return
+		}
+		result.Passed = len(nodes) > 0 && pattern.MatchString(normalize.text(actual))
+		if !result.Passed {
+			result.Message = fmt.Sprintf("Expected '%s' to match '%v' for xpath '%s'", actual, result.Expected, result.Path)
+		}
+	default:
+		result.Passed = false
+		result.Message = fmt.Sprintf("Unknown matcher: %s", result.Matcher)
+	}
+}
+
+// xmlValueEquals compares the text of a node with an expected value, as
+// number, boolean or string depending on the expectation
+func xmlValueEquals(actual string, expected interface{}) bool {
+	switch expected := expected.(type) {
+	case float64:
+		number, err := strconv.ParseFloat(actual, 64)
+		return err == nil && number == expected
+	case bool:
+		value, err := strconv.ParseBool(actual)
+		return err == nil && value == expected
+	default:
+		return actual == fmt.Sprint(expected)
+	}
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"api-test-framework/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BundleVersion is the version of the test bundle format
+const BundleVersion = 1
+
+// Bundle formats
+const (
+	BundleFormatJSON = "json"
+	BundleFormatYAML = "yaml"
+)
+
+// TestBundle is the portable form of a service and its test cases, meant to
+// be versioned in Git and moved between framework instances. Credentials
+// (auth_config), notification targets and fixture references are specific
+// to an instance and not part of a bundle.
+type TestBundle struct {
+	Version int           `json:"version"`
+	Service BundleService `json:"service"`
+	Tests   []BundleTest  `json:"tests"`
+}
+
+// BundleService is the metadata of the service of a bundle
+type BundleService struct {
+	Name            string                  `json:"name"`
+	Description     string                  `json:"description,omitempty"`
+	BaseURL         string                  `json:"base_url"`
+	Protocol        string                  `json:"protocol,omitempty"`
+	TLS             models.TLSConfig        `json:"tls,omitempty"`
+	Discovery       models.ServiceDiscovery `json:"discovery,omitempty"`
+	Variables       models.Variables        `json:"variables,omitempty"`
+	APIVersioning   models.APIVersioning    `json:"api_versioning,omitempty"`
+	LatencyBudgetMs int                     `json:"latency_budget_ms,omitempty"`
+	TimeoutMs       int                     `json:"timeout_ms,omitempty"`
+	Region          string                  `json:"region,omitempty"`
+}
+
+// BundleTest is a test case of a bundle; tests are identified by name
+type BundleTest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	IsActive    *bool           `json:"is_active,omitempty"` // defaults to true
+	Tags        []string        `json:"tags,omitempty"`
+	TestSpec    json.RawMessage `json:"test_spec"`
+}
+
+// DecodeBundle parses a test bundle in JSON or YAML and checks that it is
+// complete: a supported version, a service with a name and base URL, and
+// tests with unique names and valid specs
+func DecodeBundle(data []byte) (*TestBundle, error) {
+	var decoded interface{}
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %v", err)
+	}
+	// YAML is decoded generically and re-encoded, so both formats share the
+	// JSON field names and types
+	normalized, err := json.Marshal(normalizeYAML(decoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle: %v", err)
+	}
+	var bundle TestBundle
+	if err := json.Unmarshal(normalized, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %v", err)
+	}
+
+	if bundle.Version != BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d, expected %d", bundle.Version, BundleVersion)
+	}
+	if bundle.Service.Name == "" || bundle.Service.BaseURL == "" {
+		return nil, fmt.Errorf("the service of the bundle needs a name and base_url")
+	}
+	seen := make(map[string]bool, len(bundle.Tests))
+	for i, test := range bundle.Tests {
+		if test.Name == "" {
+			return nil, fmt.Errorf("test %d has no name", i+1)
+		}
+		if seen[test.Name] {
+			return nil, fmt.Errorf("test %q is listed twice", test.Name)
+		}
+		seen[test.Name] = true
+		var spec models.TestSpec
+		if len(test.TestSpec) == 0 || json.Unmarshal(test.TestSpec, &spec) != nil {
+			return nil, fmt.Errorf("test %q has an invalid test_spec", test.Name)
+		}
+	}
+	return &bundle, nil
+}
+
+// EncodeBundle renders a test bundle as indented JSON or as YAML
+func EncodeBundle(bundle *TestBundle, format string) ([]byte, error) {
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(format) {
+	case "", BundleFormatJSON:
+		return data, nil
+	case BundleFormatYAML, "yml":
+		// Converting the JSON keeps the field names of the JSON format
+		var generic interface{}
+		if err := json.Unmarshal(data, &generic); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(generic); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown bundle format %q, use json or yaml", format)
+	}
+}
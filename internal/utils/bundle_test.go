@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"api-test-framework/internal/models"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	inactive := false
+	bundle := &TestBundle{
+		Version: BundleVersion,
+		Service: BundleService{
+			Name:      "users",
+			BaseURL:   "https://api.example.com",
+			Variables: models.Variables{"tenant": "acme"},
+			TimeoutMs: 5000,
+		},
+		Tests: []BundleTest{
+			{Name: "get user", TestSpec: json.RawMessage(`{"request":{"method":"GET","url":"/users/1"},"assertions":[{"type":"status_code","expected":200}]}`)},
+			{Name: "legacy", IsActive: &inactive, TestSpec: json.RawMessage(`{"request":{"method":"GET","url":"/v1/users"}}`)},
+		},
+	}
+
+	for _, format := range []string{BundleFormatJSON, BundleFormatYAML} {
+		t.Run(format, func(t *testing.T) {
+			data, err := EncodeBundle(bundle, format)
+			if err != nil {
+				t.Fatalf("EncodeBundle() error = %v", err)
+			}
+			if format == BundleFormatYAML && strings.HasPrefix(string(data), "{") {
+				t.Fatalf("expected YAML, got %s", data)
+			}
+			decoded, err := DecodeBundle(data)
+			if err != nil {
+				t.Fatalf("DecodeBundle() error = %v", err)
+			}
+			if decoded.Service.Name != "users" || decoded.Service.Variables["tenant"] != "acme" || decoded.Service.TimeoutMs != 5000 {
+				t.Errorf("Service = %+v", decoded.Service)
+			}
+			if len(decoded.Tests) != 2 || decoded.Tests[1].IsActive == nil || *decoded.Tests[1].IsActive {
+				t.Fatalf("Tests = %+v", decoded.Tests)
+			}
+			var spec models.TestSpec
+			if err := json.Unmarshal(decoded.Tests[0].TestSpec, &spec); err != nil {
+				t.Fatalf("invalid test spec: %v", err)
+			}
+			if spec.Request.URL != "/users/1" || len(spec.Assertions) != 1 {
+				t.Errorf("TestSpec = %s", decoded.Tests[0].TestSpec)
+			}
+		})
+	}
+}
+
+func TestDecodeBundleYAML(t *testing.T) {
+	bundle, err := DecodeBundle([]byte(`
+version: 1
+service:
+  name: orders
+  base_url: https://orders.example.com
+tests:
+  - name: create order
+    test_spec:
+      request:
+        method: POST
+        url: /orders
+        body: {sku: A-1, quantity: 2}
+      assertions:
+        - type: status_code
+          expected: 201
+`))
+	if err != nil {
+		t.Fatalf("DecodeBundle() error = %v", err)
+	}
+	if len(bundle.Tests) != 1 || !strings.Contains(string(bundle.Tests[0].TestSpec), `"quantity":2`) {
+		t.Errorf("Tests = %+v", bundle.Tests)
+	}
+}
+
+func TestDecodeBundleErrors(t *testing.T) {
+	for name, document := range map[string]string{
+		"not a document":  `[`,
+		"wrong version":   `{"version": 2, "service": {"name": "a", "base_url": "http://a"}, "tests": []}`,
+		"no base url":     `{"version": 1, "service": {"name": "a"}, "tests": []}`,
+		"unnamed test":    `{"version": 1, "service": {"name": "a", "base_url": "http://a"}, "tests": [{"test_spec": {}}]}`,
+		"duplicate names": `{"version": 1, "service": {"name": "a", "base_url": "http://a"}, "tests": [{"name": "t", "test_spec": {}}, {"name": "t", "test_spec": {}}]}`,
+		"missing spec":    `{"version": 1, "service": {"name": "a", "base_url": "http://a"}, "tests": [{"name": "t"}]}`,
+	} {
+		if _, err := DecodeBundle([]byte(document)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := EncodeBundle(&TestBundle{Version: BundleVersion}, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// ToCurl renders a request as a curl command that can be pasted into a
+// shell. Every value is single-quoted, so nothing in it is expanded. A string
+// body is sent as it is and any other body as JSON; multipart parts become
+// -F options, fixture parts referencing a local file of the fixture's name.
+func ToCurl(request models.RequestSpec) string {
+	method := strings.ToUpper(request.Method)
+	if method == "" {
+		method = "GET"
+	}
+
+	args := []string{"curl"}
+	if method != "GET" {
+		args = append(args, "-X "+method)
+	}
+	args = append(args, shellQuote(request.URL))
+
+	names := make([]string, 0, len(request.Headers))
+	for name := range request.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := request.Headers[name]; value != "" {
+			args = append(args, "-H "+shellQuote(name+": "+value))
+		} else {
+			// curl drops headers given as "Name:", "Name;" sends them empty
+			args = append(args, "-H "+shellQuote(name+";"))
+		}
+	}
+
+	switch {
+	case len(request.Multipart) > 0:
+		for _, part := range request.Multipart {
+			args = append(args, curlFormOption(part))
+		}
+	case request.Body != nil:
+		args = append(args, "--data-raw "+shellQuote(curlBody(request.Body)))
+	}
+
+	return strings.Join(args, " \\\n  ")
+}
+
+// curlBody returns the text of a request body
+func curlBody(body interface{}) string {
+	if text, ok := body.(string); ok {
+		return text
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Sprint(body)
+	}
+	return string(data)
+}
+
+// curlFormOption renders a multipart part. Plain values use --form-string,
+// which sends them literally even if they start with @ or <.
+func curlFormOption(part models.MultipartPart) string {
+	if part.FixtureID == "" && part.FileName == "" && part.ContentType == "" {
+		return "--form-string " + shellQuote(part.Name+"="+part.Value)
+	}
+
+	var field string
+	if part.FixtureID != "" {
+		fileName := part.FileName
+		if fileName == "" {
+			fileName = part.FixtureID
+		}
+		field = part.Name + "=@" + fileName
+	} else {
+		field = part.Name + "=" + formQuote(part.Value)
+		if part.FileName != "" {
+			field += ";filename=" + part.FileName
+		}
+	}
+	if part.ContentType != "" {
+		field += ";type=" + part.ContentType
+	}
+	return "-F " + shellQuote(field)
+}
+
+// formQuote double-quotes a -F value, so semicolons in it are not taken for hints
+func formQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// shellQuote single-quotes a value for POSIX shells
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -4,8 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"path"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
+
+	"api-test-framework/internal/models"
 )
 
 // CurlRequest represents a parsed curl command
@@ -18,107 +23,198 @@ type CurlRequest struct {
 	PathVariables map[string]string `json:"pathVariables"`
 	RequestType   string            `json:"requestType"`
 	RawCommand    string            `json:"rawCommand"`
+	Form          []CurlFormField   `json:"form,omitempty"` // fields of a multipart/form-data body
+
+	rawQuery string // the query string as given, for the test spec
+}
+
+// CurlFormField is a field of a multipart form given with -F. Fields with
+// @file or <file reference a local file, which is not available when the
+// test runs: they are sent with the content of the fixture of FixtureID.
+type CurlFormField struct {
+	Name        string `json:"name"`
+	Value       string `json:"value,omitempty"`
+	File        string `json:"file,omitempty"` // path of the referenced file
+	FileName    string `json:"fileName,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	FixtureID   string `json:"fixtureId,omitempty"`
+}
+
+// curlValueOptions lists options whose value does not affect the request;
+// their value is skipped so it is not taken for the URL
+var curlValueOptions = map[string]bool{
+	"-o": true, "--output": true,
+	"-m": true, "--max-time": true,
+	"--connect-timeout": true,
+	"--retry":           true, "--retry-delay": true, "--retry-max-time": true,
+	"-w": true, "--write-out": true,
+	"-x": true, "--proxy": true,
+	"-U": true, "--proxy-user": true,
+	"--cacert": true, "--capath": true,
+	"-E": true, "--cert": true, "--cert-type": true,
+	"--key": true, "--key-type": true,
+	"--resolve":    true,
+	"--limit-rate": true,
+	"--max-redirs": true,
+	"--interface":  true,
 }
 
+// curlShortValueFlags are the single-letter options taking a value, which
+// may be attached as in -XPOST
+const curlShortValueFlags = "XHdubFAeoxmwUE"
+
+// curlShortBoolFlags are the single-letter options without a value, which
+// may be combined as in -sSL
+const curlShortBoolFlags = "sSLkviGfgNIC"
+
 // ParseCurlCommand parses a curl command and returns a CurlRequest
 func ParseCurlCommand(curlCmd string) (*CurlRequest, error) {
 	if curlCmd == "" {
 		return nil, fmt.Errorf("curl command cannot be empty")
 	}
 
-	// 1️⃣ Normalize multi-line commands
-	command := strings.ReplaceAll(curlCmd, "\\\n", " ")
-	command = strings.ReplaceAll(command, "\\", "")
-	command = strings.Join(strings.Fields(command), " ") // collapse multiple spaces
+	result := newCurlRequest(curlCmd)
 
-	result := &CurlRequest{
-		Method:        "GET",
-		Headers:       make(map[string]string),
-		QueryParams:   make(map[string]string),
-		PathVariables: make(map[string]string),
-		RawCommand:    curlCmd,
+	// 1️⃣ Split into arguments as a shell would, resolving quotes and line continuations
+	tokens, err := tokenizeCurl(curlCmd)
+	if err != nil {
+		return nil, err
 	}
-
-	// 2️⃣ Tokenize, preserving quotes
-	re := regexp.MustCompile(`'[^']*'|"[^"]*"|\S+`)
-	tokens := re.FindAllString(command, -1)
-
 	if len(tokens) == 0 {
 		return nil, fmt.Errorf("invalid curl command")
 	}
 
+	var (
+		data           []string // values of the data options, joined with & as curl does
+		methodExplicit bool
+		get            bool // -G sends the data in the query string
+	)
 	for i := 0; i < len(tokens); i++ {
-		token := stripQuotes(tokens[i])
+		token := tokens[i]
+		value := func() (string, error) {
+			if i+1 >= len(tokens) {
+				return "", fmt.Errorf("missing value after %s", token)
+			}
+			i++
+			return tokens[i], nil
+		}
+
+		// Combined short options such as -sSL or -XPOST are split first
+		if expanded := expandShortOptions(token); expanded != nil {
+			tokens = append(append(append([]string{}, tokens[:i]...), expanded...), tokens[i+1:]...)
+			i--
+			continue
+		}
 
 		switch token {
 		case "curl":
 			continue
 		case "-X", "--request":
-			if i+1 >= len(tokens) {
-				return nil, fmt.Errorf("missing method after -X/--request")
+			method, err := value()
+			if err != nil {
+				return nil, err
 			}
-			i++
-			result.Method = strings.ToUpper(stripQuotes(tokens[i]))
+			result.Method = strings.ToUpper(method)
+			methodExplicit = true
 		case "-H", "--header":
-			if i+1 >= len(tokens) {
-				return nil, fmt.Errorf("missing header value after -H/--header")
+			header, err := value()
+			if err != nil {
+				return nil, err
 			}
-			i++
-			header := stripQuotes(tokens[i])
 			parts := strings.SplitN(header, ":", 2)
 			if len(parts) == 2 {
 				key := strings.TrimSpace(parts[0])
 				value := strings.TrimSpace(parts[1])
 				result.Headers[key] = value
+			} else if name := strings.TrimSuffix(header, ";"); name != header {
+				// "Name;" sends a header without a value
+				result.Headers[strings.TrimSpace(name)] = ""
 			} else {
 				return nil, fmt.Errorf("invalid header format: %s", header)
 			}
-		case "--location", "-L":
+		case "--location", "-L", "--location-trusted":
 			// --location follows redirects, but doesn't change the request
 			continue
-		case "--data", "--data-raw", "--data-binary", "-d":
-			if result.Method == "GET" {
-				result.Method = "POST"
+		case "--data", "--data-ascii", "--data-binary", "-d":
+			content, err := value()
+			if err != nil {
+				return nil, err
 			}
-			if i+1 >= len(tokens) {
-				return nil, fmt.Errorf("missing data after --data/-d")
+			if strings.HasPrefix(content, "@") {
+				return nil, fmt.Errorf("%s %s reads data from a file, which is not supported; paste the content instead", token, content)
 			}
-			i++
-			result.Body = stripQuotes(tokens[i])
-		case "--form", "-F":
-			if result.Method == "GET" {
-				result.Method = "POST"
+			data = append(data, content)
+		case "--data-raw":
+			content, err := value()
+			if err != nil {
+				return nil, err
 			}
-			if i+1 >= len(tokens) {
-				return nil, fmt.Errorf("missing form data after --form/-F")
+			data = append(data, content)
+		case "--data-urlencode":
+			content, err := value()
+			if err != nil {
+				return nil, err
 			}
-			i++
-			formData := stripQuotes(tokens[i])
-			parts := strings.SplitN(formData, "=", 2)
-			if len(parts) == 2 {
-				if result.Body == "" {
-					result.Body = fmt.Sprintf("%s=%s", parts[0], parts[1])
-				} else {
-					result.Body += fmt.Sprintf("&%s=%s", parts[0], parts[1])
-				}
+			encoded, err := curlURLEncode(content)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, encoded)
+		case "--json":
+			content, err := value()
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, content)
+			setDefaultHeader(result.Headers, "Content-Type", "application/json")
+			setDefaultHeader(result.Headers, "Accept", "application/json")
+		case "-G", "--get":
+			get = true
+		case "--url":
+			target, err := value()
+			if err != nil {
+				return nil, err
+			}
+			if result.URL == "" {
+				result.URL = curlURL(target)
+			}
+		case "--form", "-F", "--form-string":
+			formData, err := value()
+			if err != nil {
+				return nil, err
 			}
+			field, err := parseCurlFormField(formData, token == "--form-string")
+			if err != nil {
+				return nil, err
+			}
+			result.Form = append(result.Form, field)
 		case "--user", "-u":
-			if i+1 >= len(tokens) {
-				return nil, fmt.Errorf("missing credentials after --user/-u")
+			credentials, err := value()
+			if err != nil {
+				return nil, err
 			}
-			i++
-			credentials := stripQuotes(tokens[i])
 			parts := strings.SplitN(credentials, ":", 2)
 			if len(parts) == 2 {
 				result.Headers["Authorization"] = fmt.Sprintf("Basic %s", credentials)
 			}
 		case "--cookie", "-b":
-			if i+1 >= len(tokens) {
-				return nil, fmt.Errorf("missing cookie value after --cookie/-b")
+			cookie, err := value()
+			if err != nil {
+				return nil, err
 			}
-			i++
-			cookie := stripQuotes(tokens[i])
 			result.Headers["Cookie"] = cookie
+		case "--user-agent", "-A":
+			agent, err := value()
+			if err != nil {
+				return nil, err
+			}
+			result.Headers["User-Agent"] = agent
+		case "--referer", "-e":
+			referer, err := value()
+			if err != nil {
+				return nil, err
+			}
+			result.Headers["Referer"] = referer
 		case "--compressed", "-C":
 			// Handle compression flag
 			continue
@@ -132,29 +228,83 @@ func ParseCurlCommand(curlCmd string) (*CurlRequest, error) {
 			// Handle verbose flag
 			continue
 		default:
-			// URL detection
-			if strings.HasPrefix(token, "http://") || strings.HasPrefix(token, "https://") {
-				result.URL = token
-			} else if strings.HasPrefix(token, "localhost:") || strings.HasPrefix(token, "127.0.0.1:") {
-				// Handle localhost URLs
-				result.URL = "http://" + token
+			if curlValueOptions[token] {
+				if _, err := value(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if strings.HasPrefix(token, "-") {
+				// Other options, such as --fail or --http2, don't change the request
+				continue
+			}
+			// Like curl, the first argument that is no option is the URL
+			if result.URL == "" {
+				result.URL = curlURL(token)
 			}
 		}
 	}
 
-	// 3️⃣ Validate URL
+	// 2️⃣ Validate URL
 	if result.URL == "" {
 		return nil, fmt.Errorf("no URL found in curl command")
 	}
 
-	// 4️⃣ Extract query params
-	if strings.Contains(result.URL, "?") {
-		parsedURL, err := url.Parse(result.URL)
+	// 3️⃣ Place the data: in the query string with -G, in the body otherwise
+	if len(result.Form) > 0 && len(data) > 0 && !get {
+		return nil, fmt.Errorf("form fields (-F) cannot be combined with a request body (-d)")
+	}
+	if len(data) > 0 {
+		if get {
+			separator := "?"
+			if strings.Contains(result.URL, "?") {
+				separator = "&"
+			}
+			result.URL += separator + strings.Join(data, "&")
+		} else {
+			result.Body = strings.Join(data, "&")
+		}
+	}
+	if !methodExplicit {
+		switch {
+		case get:
+			result.Method = "GET"
+		case len(data) > 0 || len(result.Form) > 0:
+			result.Method = "POST"
+		}
+	}
+
+	// 4️⃣ Extract query params and path variables, and classify the request
+	if err := completeRequest(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// newCurlRequest creates an empty GET request parsed from a command
+func newCurlRequest(command string) *CurlRequest {
+	return &CurlRequest{
+		Method:        "GET",
+		Headers:       make(map[string]string),
+		QueryParams:   make(map[string]string),
+		PathVariables: make(map[string]string),
+		RawCommand:    command,
+	}
+}
+
+// completeRequest derives the query parameters, path variables and request
+// type of a parsed request from its URL
+func completeRequest(result *CurlRequest) error {
+	// Extract query params, keeping the query as given for the test spec
+	if base, query, found := strings.Cut(result.URL, "?"); found {
+		query, _, _ = strings.Cut(query, "#")
+		values, err := url.ParseQuery(query)
 		if err != nil {
-			return nil, fmt.Errorf("invalid URL: %v", err)
+			return fmt.Errorf("invalid URL query: %v", err)
 		}
-		result.URL = fmt.Sprintf("%s://%s%s", parsedURL.Scheme, parsedURL.Host, parsedURL.Path)
-		for k, v := range parsedURL.Query() {
+		result.URL = base
+		result.rawQuery = query
+		for k, v := range values {
 			if len(v) > 0 {
 				result.QueryParams[k] = v[0]
 			} else {
@@ -163,7 +313,7 @@ func ParseCurlCommand(curlCmd string) (*CurlRequest, error) {
 		}
 	}
 
-	// 5️⃣ Extract path variables like {id}
+	// Extract path variables like {id}
 	pathVarRe := regexp.MustCompile(`\{([^}]+)\}`)
 	matches := pathVarRe.FindAllStringSubmatch(result.URL, -1)
 	for _, m := range matches {
@@ -172,20 +322,290 @@ func ParseCurlCommand(curlCmd string) (*CurlRequest, error) {
 		}
 	}
 
-	// 6️⃣ Classify request type
+	// Classify request type
 	result.RequestType = classifyRequest(result)
 
-	return result, nil
+	return nil
+}
+
+
+// tokenizeCurl splits a command into arguments like a POSIX shell: single
+// quotes keep text literally, double quotes allow escaping ", \, $ and `,
+// $'...' decodes C escapes as "Copy as cURL" in browsers emits them, and a
+// backslash before a line break continues the command on the next line
+func tokenizeCurl(command string) ([]string, error) {
+	var (
+		tokens  []string
+		current strings.Builder
+		inToken bool
+	)
+	runes := []rune(strings.ReplaceAll(command, "\r\n", "\n"))
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] == '\n' {
+					continue
+				}
+				current.WriteRune(runes[i])
+				inToken = true
+			}
+		case r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated single quote in curl command")
+			}
+			current.WriteString(string(runes[i+1 : end]))
+			i = end
+			inToken = true
+		case r == '"':
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				current.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated double quote in curl command")
+			}
+			inToken = true
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			end, err := decodeANSIQuoted(runes, i+2, &current)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			inToken = true
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				inToken = false
+			}
+		default:
+			current.WriteRune(r)
+			inToken = true
+		}
+	}
+	if inToken {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+// ansiEscapes are the single-character escapes of $'...' strings
+var ansiEscapes = map[rune]rune{
+	'n': '\n', 't': '\t', 'r': '\r', 'a': '\a', 'b': '\b', 'f': '\f', 'v': '\v',
+	'e': 0x1b, '0': 0, '\\': '\\', '\'': '\'', '"': '"', '?': '?',
 }
 
-// stripQuotes removes surrounding quotes from a string
-func stripQuotes(s string) string {
-	s = strings.TrimSpace(s)
-	if (strings.HasPrefix(s, "'") && strings.HasSuffix(s, "'")) ||
-		(strings.HasPrefix(s, "\"") && strings.HasSuffix(s, "\"")) {
-		return s[1 : len(s)-1]
+// decodeANSIQuoted decodes a $'...' string starting after its opening quote
+// into out and returns the position of its closing quote
+func decodeANSIQuoted(runes []rune, start int, out *strings.Builder) (int, error) {
+	for i := start; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\'':
+			return i, nil
+		case runes[i] == '\\' && i+1 < len(runes):
+			i++
+			if decoded, ok := ansiEscapes[runes[i]]; ok {
+				out.WriteRune(decoded)
+				continue
+			}
+			// \xHH, \uHHHH and \UHHHHHHHH give a character in hex
+			digits := map[rune]int{'x': 2, 'u': 4, 'U': 8}[runes[i]]
+			end := i + 1
+			for end < len(runes) && end <= i+digits && strings.ContainsRune("0123456789abcdefABCDEF", runes[end]) {
+				end++
+			}
+			code, err := strconv.ParseUint(string(runes[i+1:end]), 16, 32)
+			if digits == 0 || err != nil {
+				out.WriteRune('\\')
+				out.WriteRune(runes[i])
+				continue
+			}
+			if runes[i] == 'x' {
+				out.WriteByte(byte(code))
+			} else {
+				out.WriteRune(rune(code))
+			}
+			i = end - 1
+		default:
+			out.WriteRune(runes[i])
+		}
 	}
-	return s
+	return 0, fmt.Errorf("unterminated $'...' quote in curl command")
+}
+
+// expandShortOptions splits combined single-letter options, -sSL into -s,
+// -S and -L, and an attached value, -XPOST into -X and POST. It returns nil
+// for anything else.
+func expandShortOptions(token string) []string {
+	if len(token) <= 2 || token[0] != '-' || token[1] == '-' {
+		return nil
+	}
+	var expanded []string
+	for i := 1; i < len(token); i++ {
+		flag := token[i]
+		switch {
+		case strings.IndexByte(curlShortValueFlags, flag) >= 0:
+			expanded = append(expanded, "-"+string(flag))
+			if i+1 < len(token) {
+				expanded = append(expanded, token[i+1:])
+			}
+			return expanded
+		case strings.IndexByte(curlShortBoolFlags, flag) >= 0:
+			expanded = append(expanded, "-"+string(flag))
+		default:
+			return nil
+		}
+	}
+	return expanded
+}
+
+// curlURL completes a URL argument: like curl, URLs without a scheme use
+// http. Relative and templated URLs such as {{baseUrl}}/users stay as they are.
+func curlURL(target string) string {
+	if strings.Contains(target, "://") || strings.HasPrefix(target, "/") || strings.HasPrefix(target, "{{") {
+		return target
+	}
+	return "http://" + target
+}
+
+// curlURLEncode encodes a --data-urlencode value as curl does: "content"
+// and "=content" encode the content, "name=content" only the content.
+// {{variables}} are kept so they are still substituted.
+func curlURLEncode(content string) (string, error) {
+	name, value, found := strings.Cut(content, "=")
+	if !found {
+		// "@filename" and "name@filename" read the content from a file
+		if strings.Contains(content, "@") {
+			return "", fmt.Errorf("--data-urlencode %s reads data from a file, which is not supported; paste the content instead", content)
+		}
+		name, value = "", content
+	}
+
+	encoded := strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+	encoded = strings.NewReplacer("%7B%7B", "{{", "%7D%7D", "}}").Replace(encoded)
+	if name == "" {
+		return encoded, nil
+	}
+	return name + "=" + encoded, nil
+}
+
+// parseCurlFormField parses the value of -F: name=content, where content
+// may be quoted and is followed by ;type= and ;filename= hints, and is
+// @file to upload a file or <file to send the content of a file. Values of
+// --form-string are taken literally.
+func parseCurlFormField(spec string, literal bool) (CurlFormField, error) {
+	name, content, found := strings.Cut(spec, "=")
+	if !found || name == "" {
+		return CurlFormField{}, fmt.Errorf("invalid form field %q, expected name=content", spec)
+	}
+	field := CurlFormField{Name: name}
+	if literal {
+		field.Value = content
+		return field, nil
+	}
+
+	value, params, err := splitFormParams(content)
+	if err != nil {
+		return CurlFormField{}, fmt.Errorf("invalid form field %q: %v", spec, err)
+	}
+	field.ContentType = params["type"]
+	field.FileName = params["filename"]
+	switch {
+	case strings.HasPrefix(value, "@"):
+		field.File = value[1:]
+		if field.FileName == "" {
+			field.FileName = path.Base(field.File)
+		}
+	case strings.HasPrefix(value, "<"):
+		field.File = value[1:]
+	default:
+		field.Value = value
+	}
+	if field.Value == "" && field.File == "" && value != "" {
+		return CurlFormField{}, fmt.Errorf("invalid form field %q: missing file name", spec)
+	}
+	return field, nil
+}
+
+// curlFormParams are the ;name=value hints of -F values; a semicolon followed
+// by other text belongs to the value
+var curlFormParams = map[string]bool{"type": true, "filename": true, "headers": true, "encoder": true}
+
+// splitFormParams splits the content of a form field into its value and its
+// hints. A value in double quotes may contain semicolons, and \" and \\
+// escape a quote and a backslash. Unquoted values end at the first hint.
+func splitFormParams(content string) (string, map[string]string, error) {
+	value, rest := content, ""
+	if strings.HasPrefix(content, `"`) {
+		var builder strings.Builder
+		end := -1
+		for i := 1; i < len(content) && end < 0; i++ {
+			switch {
+			case content[i] == '\\' && i+1 < len(content) && (content[i+1] == '"' || content[i+1] == '\\'):
+				i++
+				builder.WriteByte(content[i])
+			case content[i] == '"':
+				end = i
+			default:
+				builder.WriteByte(content[i])
+			}
+		}
+		if end < 0 {
+			return "", nil, fmt.Errorf("unterminated quote")
+		}
+		value, rest = builder.String(), content[end+1:]
+		if rest != "" && !strings.HasPrefix(rest, ";") {
+			return "", nil, fmt.Errorf("unexpected text after the quoted value")
+		}
+	} else if start := formParamsStart(content); start >= 0 {
+		value, rest = content[:start], content[start:]
+	}
+
+	params := make(map[string]string)
+	last := ""
+	for _, param := range strings.Split(rest, ";")[1:] {
+		key, paramValue, found := strings.Cut(strings.TrimSpace(param), "=")
+		key = strings.ToLower(key)
+		switch {
+		case found && curlFormParams[key]:
+			params[key] = strings.Trim(paramValue, `"`)
+			last = key
+		case last != "":
+			// Parameters of a hint, as in type=text/plain;charset=utf-8
+			params[last] += ";" + param
+		default:
+			return "", nil, fmt.Errorf("unknown hint %q", param)
+		}
+	}
+	return value, params, nil
+}
+
+// formParamsStart returns the index of the semicolon that starts the hints
+// of an unquoted form value, or -1 if it has none
+func formParamsStart(content string) int {
+	for i := 0; i < len(content); i++ {
+		if content[i] != ';' {
+			continue
+		}
+		key, _, found := strings.Cut(strings.TrimSpace(content[i+1:]), "=")
+		if found && curlFormParams[strings.ToLower(key)] {
+			return i
+		}
+	}
+	return -1
 }
 
 // classifyRequest determines the type of request based on URL and method
@@ -223,7 +643,10 @@ func classifyRequest(req *CurlRequest) string {
 func (c *CurlRequest) ToTestSpec(name, description string) map[string]interface{} {
 	// Build the full URL with query parameters
 	fullURL := c.URL
-	if len(c.QueryParams) > 0 {
+	if c.rawQuery != "" {
+		// Keep the query as given, so order, repeated and encoded values survive
+		fullURL += "?" + c.rawQuery
+	} else if len(c.QueryParams) > 0 {
 		queryParts := make([]string, 0, len(c.QueryParams))
 		for k, v := range c.QueryParams {
 			if v != "" {
@@ -245,16 +668,28 @@ func (c *CurlRequest) ToTestSpec(name, description string) map[string]interface{
 		}
 	}
 
+	request := map[string]interface{}{
+		"method":  c.Method,
+		"url":     fullURL,
+		"headers": c.Headers,
+		"body":    body,
+	}
+	if len(c.Form) > 0 {
+		request["multipart"] = c.multipartParts()
+		// The multipart encoder sets its own Content-Type with the boundary
+		headers := make(map[string]string, len(c.Headers))
+		for key, value := range c.Headers {
+			headers[key] = value
+		}
+		deleteHeader(headers, "Content-Type")
+		request["headers"] = headers
+	}
+
 	return map[string]interface{}{
 		"name":        name,
 		"description": description,
 		"service_name": "curl-service", // This will be overridden by the actual service
-		"request": map[string]interface{}{
-			"method":  c.Method,
-			"url":     fullURL,
-			"headers": c.Headers,
-			"body":    body,
-		},
+		"request":     request,
 		"assertions": []map[string]interface{}{
 			{
 				"type":     "status_code",
@@ -264,6 +699,45 @@ func (c *CurlRequest) ToTestSpec(name, description string) map[string]interface{
 	}
 }
 
+// AttachFixtures references stored fixtures from the file fields of the
+// form, by the path given in the curl command, and returns a warning for each
+// file field without a fixture. Such fields are left out of the test spec.
+func (c *CurlRequest) AttachFixtures(fixtures map[string]string) []string {
+	warnings := []string{}
+	for i, field := range c.Form {
+		if field.File == "" {
+			continue
+		}
+		if fixtureID, ok := fixtures[field.File]; ok {
+			c.Form[i].FixtureID = fixtureID
+			continue
+		}
+		if field.FixtureID == "" {
+			warnings = append(warnings, fmt.Sprintf("form field '%s' reads the local file %s, which must be uploaded as a fixture and referenced in fixtures", field.Name, field.File))
+		}
+	}
+	return warnings
+}
+
+// multipartParts converts the form into the parts of a multipart request.
+// File fields are sent with the content of their fixture.
+func (c *CurlRequest) multipartParts() []models.MultipartPart {
+	parts := make([]models.MultipartPart, 0, len(c.Form))
+	for _, field := range c.Form {
+		if field.File != "" && field.FixtureID == "" {
+			continue
+		}
+		parts = append(parts, models.MultipartPart{
+			Name:        field.Name,
+			Value:       field.Value,
+			FixtureID:   field.FixtureID,
+			FileName:    field.FileName,
+			ContentType: field.ContentType,
+		})
+	}
+	return parts
+}
+
 // String returns a string representation of the CurlRequest
 func (c *CurlRequest) String() string {
 	data, _ := json.MarshalIndent(c, "", "  ")
@@ -0,0 +1,337 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"api-test-framework/internal/models"
+)
+
+func TestParseCurlCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		method      string
+		url         string
+		body        string
+		headers     map[string]string
+		queryParams map[string]string
+		specURL     string
+	}{
+		{
+			name: "postman export",
+			command: `curl --location 'https://api.example.com/users?page=2' \
+--header 'Content-Type: application/json' \
+--header 'Authorization: Bearer token' \
+--data '{"name": "Ada Lovelace"}'`,
+			method:      "POST",
+			url:         "https://api.example.com/users",
+			body:        `{"name": "Ada Lovelace"}`,
+			headers:     map[string]string{"Content-Type": "application/json", "Authorization": "Bearer token"},
+			queryParams: map[string]string{"page": "2"},
+		},
+		{
+			name:    "chrome export with ansi-c quoting",
+			command: `curl 'https://api.example.com/notes' -H 'accept: */*' --data-raw $'{"text":"it\'s\\nfine é"}' --compressed`,
+			method:  "POST",
+			url:     "https://api.example.com/notes",
+			body:    `{"text":"it's\nfine é"}`,
+			headers: map[string]string{"accept": "*/*"},
+		},
+		{
+			name:        "get with url-encoded data",
+			command:     `curl -G https://api.example.com/search --data-urlencode 'q=hello world & more' --data-urlencode "lang=en"`,
+			method:      "GET",
+			url:         "https://api.example.com/search",
+			queryParams: map[string]string{"q": "hello world & more", "lang": "en"},
+			specURL:     "https://api.example.com/search?q=hello%20world%20%26%20more&lang=en",
+		},
+		{
+			name:        "get appends to an existing query",
+			command:     `curl --get 'https://api.example.com/search?limit=5' -d sort=name`,
+			method:      "GET",
+			url:         "https://api.example.com/search",
+			queryParams: map[string]string{"limit": "5", "sort": "name"},
+			specURL:     "https://api.example.com/search?limit=5&sort=name",
+		},
+		{
+			name:        "url-encoded variables are kept",
+			command:     `curl -G '{{baseUrl}}/search' --data-urlencode 'q={{term}}'`,
+			method:      "GET",
+			url:         "{{baseUrl}}/search",
+			queryParams: map[string]string{"q": "{{term}}"},
+		},
+		{
+			name:    "url-encoded body",
+			command: `curl https://api.example.com/login --data-urlencode 'user=a+b@example.com' --data-urlencode '=raw value'`,
+			method:  "POST",
+			url:     "https://api.example.com/login",
+			body:    "user=a%2Bb%40example.com&raw%20value",
+		},
+		{
+			name:    "url option",
+			command: `curl --url https://api.example.com/health -sS`,
+			method:  "GET",
+			url:     "https://api.example.com/health",
+		},
+		{
+			name:    "attached method and combined flags",
+			command: `curl -sSL -XPOST https://api.example.com/jobs -H'Content-Type: text/plain' -d'run'`,
+			method:  "POST",
+			url:     "https://api.example.com/jobs",
+			body:    "run",
+			headers: map[string]string{"Content-Type": "text/plain"},
+		},
+		{
+			name:    "explicit method wins over data",
+			command: `curl -X GET https://api.example.com/items -d 'filter=active'`,
+			method:  "GET",
+			url:     "https://api.example.com/items",
+			body:    "filter=active",
+		},
+		{
+			name:    "multiple data options",
+			command: `curl https://api.example.com/form -d a=1 -d b=2`,
+			method:  "POST",
+			url:     "https://api.example.com/form",
+			body:    "a=1&b=2",
+		},
+		{
+			name:    "option values are not taken for the url",
+			command: `curl --proxy http://proxy.local:3128 -m 10 -o out.json https://api.example.com/data`,
+			method:  "GET",
+			url:     "https://api.example.com/data",
+		},
+		{
+			name:    "url without scheme",
+			command: `curl localhost:8080/api/v1/health`,
+			method:  "GET",
+			url:     "http://localhost:8080/api/v1/health",
+		},
+		{
+			name:    "json, user agent, referer and empty header",
+			command: `curl --json '{"a":1}' -A 'probe/1.0' -e https://example.com -H 'X-Empty;' https://api.example.com/a`,
+			method:  "POST",
+			url:     "https://api.example.com/a",
+			body:    `{"a":1}`,
+			headers: map[string]string{
+				"Content-Type": "application/json",
+				"Accept":       "application/json",
+				"User-Agent":   "probe/1.0",
+				"Referer":      "https://example.com",
+				"X-Empty":      "",
+			},
+		},
+		{
+			name:    "double quotes with escapes",
+			command: `curl "https://api.example.com/echo" -d "{\"price\": \"\$5\"}"`,
+			method:  "POST",
+			url:     "https://api.example.com/echo",
+			body:    `{"price": "$5"}`,
+		},
+		{
+			name:    "windows line endings",
+			command: "curl https://api.example.com/a \\\r\n  -H 'Accept: text/plain'",
+			method:  "GET",
+			url:     "https://api.example.com/a",
+			headers: map[string]string{"Accept": "text/plain"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := ParseCurlCommand(tt.command)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if request.Method != tt.method {
+				t.Errorf("method = %q, want %q", request.Method, tt.method)
+			}
+			if request.URL != tt.url {
+				t.Errorf("url = %q, want %q", request.URL, tt.url)
+			}
+			if request.Body != tt.body {
+				t.Errorf("body = %q, want %q", request.Body, tt.body)
+			}
+			for name, want := range tt.headers {
+				if got, ok := request.Headers[name]; !ok || got != want {
+					t.Errorf("header %s = %q, want %q", name, got, want)
+				}
+			}
+			if len(request.QueryParams) != len(tt.queryParams) {
+				t.Errorf("query params = %v, want %v", request.QueryParams, tt.queryParams)
+			}
+			for name, want := range tt.queryParams {
+				if got := request.QueryParams[name]; got != want {
+					t.Errorf("query param %s = %q, want %q", name, got, want)
+				}
+			}
+			if tt.specURL != "" {
+				spec := request.ToTestSpec("test", "")
+				got := spec["request"].(map[string]interface{})["url"]
+				if got != tt.specURL {
+					t.Errorf("test spec url = %q, want %q", got, tt.specURL)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCurlCommandErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		err     string
+	}{
+		{name: "empty", command: "", err: "cannot be empty"},
+		{name: "unterminated single quote", command: `curl 'https://api.example.com`, err: "unterminated single quote"},
+		{name: "unterminated double quote", command: `curl "https://api.example.com`, err: "unterminated double quote"},
+		{name: "no url", command: `curl -X POST -d a=1`, err: "no URL"},
+		{name: "missing value", command: `curl https://api.example.com -H`, err: "missing value after -H"},
+		{name: "data from file", command: `curl https://api.example.com -d @body.json`, err: "reads data from a file"},
+		{name: "url-encoded file", command: `curl https://api.example.com --data-urlencode name@file.txt`, err: "reads data from a file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseCurlCommand(tt.command)
+			if err == nil {
+				t.Fatalf("expected an error containing %q", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseCurlCommandForm(t *testing.T) {
+	command := `curl https://api.example.com/upload \
+  -F "file=@reports/report.pdf;type=application/pdf" \
+  -F 'meta=<meta.json;type=application/json' \
+  -F 'avatar=@me.png;filename=profile.png' \
+  -F 'note="semi;colons";type=text/plain;charset=utf-8' \
+  -F 'query=a;b' \
+  --form-string 'raw=@not-a-file' \
+  -H 'Content-Type: multipart/form-data'`
+
+	request, err := ParseCurlCommand(command)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Method != "POST" {
+		t.Errorf("method = %q, want POST", request.Method)
+	}
+	if request.Body != "" {
+		t.Errorf("body = %q, want none", request.Body)
+	}
+
+	want := []CurlFormField{
+		{Name: "file", File: "reports/report.pdf", FileName: "report.pdf", ContentType: "application/pdf"},
+		{Name: "meta", File: "meta.json", ContentType: "application/json"},
+		{Name: "avatar", File: "me.png", FileName: "profile.png"},
+		{Name: "note", Value: "semi;colons", ContentType: "text/plain;charset=utf-8"},
+		{Name: "query", Value: "a;b"},
+		{Name: "raw", Value: "@not-a-file"},
+	}
+	if len(request.Form) != len(want) {
+		t.Fatalf("form = %+v, want %+v", request.Form, want)
+	}
+	for i, field := range request.Form {
+		if field != want[i] {
+			t.Errorf("form field %d = %+v, want %+v", i, field, want[i])
+		}
+	}
+
+	warnings := request.AttachFixtures(map[string]string{"reports/report.pdf": "fixture-1"})
+	if len(warnings) != 2 {
+		t.Errorf("warnings = %v, want one per file without a fixture", warnings)
+	}
+
+	spec := request.ToTestSpec("upload", "")
+	specRequest := spec["request"].(map[string]interface{})
+	parts := specRequest["multipart"].([]models.MultipartPart)
+	if len(parts) != 4 {
+		t.Fatalf("parts = %+v, want the fixture and value parts", parts)
+	}
+	if parts[0].FixtureID != "fixture-1" || parts[0].FileName != "report.pdf" || parts[0].ContentType != "application/pdf" {
+		t.Errorf("file part = %+v", parts[0])
+	}
+	if _, ok := specRequest["headers"].(map[string]string)["Content-Type"]; ok {
+		t.Errorf("multipart test spec keeps the Content-Type header of the command")
+	}
+}
+
+func TestParseCurlCommandFormErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{name: "missing name", command: `curl https://api.example.com -F '=value'`},
+		{name: "missing file name", command: `curl https://api.example.com -F 'file=@;type=text/plain'`},
+		{name: "unterminated quote", command: `curl https://api.example.com -F 'a="open'`},
+		{name: "form and data", command: `curl https://api.example.com -F a=1 -d b=2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCurlCommand(tt.command); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestToCurlRoundTrip(t *testing.T) {
+	request := models.RequestSpec{
+		Method:  "post",
+		URL:     "https://api.example.com/notes?tag=a%20b",
+		Headers: map[string]string{"Authorization": "Bearer [REDACTED]", "X-Empty": ""},
+		Body:    map[string]interface{}{"text": "it's $HOME"},
+	}
+
+	command := ToCurl(request)
+	parsed, err := ParseCurlCommand(command)
+	if err != nil {
+		t.Fatalf("exported command does not parse: %v\n%s", err, command)
+	}
+	if parsed.Method != "POST" || parsed.URL != "https://api.example.com/notes" || parsed.QueryParams["tag"] != "a b" {
+		t.Errorf("parsed request = %s %s %v", parsed.Method, parsed.URL, parsed.QueryParams)
+	}
+	if parsed.Body != `{"text":"it's $HOME"}` {
+		t.Errorf("body = %q", parsed.Body)
+	}
+	if parsed.Headers["Authorization"] != "Bearer [REDACTED]" {
+		t.Errorf("authorization = %q", parsed.Headers["Authorization"])
+	}
+	if value, ok := parsed.Headers["X-Empty"]; !ok || value != "" {
+		t.Errorf("empty header = %q, %v", value, ok)
+	}
+
+	multipart := ToCurl(models.RequestSpec{
+		Method: "POST",
+		URL:    "https://api.example.com/upload",
+		Multipart: []models.MultipartPart{
+			{Name: "file", FixtureID: "fixture-1", FileName: "report.pdf", ContentType: "application/pdf"},
+			{Name: "note", Value: "a;b", ContentType: "text/plain"},
+			{Name: "raw", Value: "@literal"},
+		},
+	})
+	parsed, err = ParseCurlCommand(multipart)
+	if err != nil {
+		t.Fatalf("exported multipart command does not parse: %v\n%s", err, multipart)
+	}
+	want := []CurlFormField{
+		{Name: "file", File: "report.pdf", FileName: "report.pdf", ContentType: "application/pdf"},
+		{Name: "note", Value: "a;b", ContentType: "text/plain"},
+		{Name: "raw", Value: "@literal"},
+	}
+	if len(parsed.Form) != len(want) {
+		t.Fatalf("form = %+v, want %+v", parsed.Form, want)
+	}
+	for i, field := range parsed.Form {
+		if field != want[i] {
+			t.Errorf("form field %d = %+v, want %+v", i, field, want[i])
+		}
+	}
+}
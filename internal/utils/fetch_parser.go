@@ -0,0 +1,456 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseFetchSnippet parses a JavaScript fetch() call, as copied with "Copy as
+// fetch" in browser devtools, into a request:
+//
+//	fetch("https://api.example.com/users", {
+//	  "headers": {"content-type": "application/json"},
+//	  "body": "{\"name\":\"Ada\"}",
+//	  "method": "POST"
+//	});
+//
+// The URL and options must be literals: strings, template literals without
+// ${...}, objects, arrays, numbers and booleans. JSON.stringify(...),
+// new Headers(...) and new URLSearchParams(...) of literals are understood
+// as well. Options other than method, headers and body, such as mode or
+// credentials, only concern browsers and are ignored.
+func ParseFetchSnippet(snippet string) (*CurlRequest, error) {
+	start := strings.Index(snippet, "fetch(")
+	if start < 0 {
+		return nil, fmt.Errorf("no fetch() call found")
+	}
+	parser := &jsParser{src: []rune(snippet[start+len("fetch("):])}
+
+	target, err := parser.value()
+	if err != nil {
+		return nil, fmt.Errorf("invalid fetch URL: %v", err)
+	}
+	rawURL, ok := target.(string)
+	if !ok || rawURL == "" {
+		return nil, fmt.Errorf("the URL of fetch() must be a string")
+	}
+	var options map[string]interface{}
+	if parser.consume(',') && !parser.peek(')') {
+		value, err := parser.value()
+		if err != nil {
+			return nil, fmt.Errorf("invalid fetch options: %v", err)
+		}
+		if options, ok = value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("the options of fetch() must be an object")
+		}
+		parser.consume(',')
+	}
+	if !parser.consume(')') {
+		return nil, fmt.Errorf("expected ) after the arguments of fetch() at offset %d", parser.pos)
+	}
+
+	result := newCurlRequest(snippet)
+	result.URL = rawURL
+	if method, ok := options["method"].(string); ok && method != "" {
+		result.Method = strings.ToUpper(method)
+	}
+	switch headers := options["headers"].(type) {
+	case nil:
+	case map[string]interface{}:
+		for name, value := range headers {
+			result.Headers[name] = fetchText(value)
+		}
+	case []interface{}:
+		// [["name", "value"], ...]
+		for _, pair := range headers {
+			entry, ok := pair.([]interface{})
+			if !ok || len(entry) != 2 {
+				return nil, fmt.Errorf("headers must be an object or a list of [name, value] pairs")
+			}
+			result.Headers[fetchText(entry[0])] = fetchText(entry[1])
+		}
+	default:
+		return nil, fmt.Errorf("headers must be an object or a list of [name, value] pairs")
+	}
+	switch body := options["body"].(type) {
+	case nil:
+	case string:
+		result.Body = body
+	default:
+		return nil, fmt.Errorf("body must be a string, e.g. JSON.stringify(...)")
+	}
+
+	if err := completeRequest(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// fetchText returns a header value as fetch() sends it
+func fetchText(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case nil:
+		return "null"
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// jsParser reads the JavaScript literals of fetch() arguments. Objects
+// become maps, arrays slices, numbers float64, and null and undefined nil.
+type jsParser struct {
+	src []rune
+	pos int
+}
+
+// skipSpace skips whitespace and comments
+func (p *jsParser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch {
+		case unicode.IsSpace(p.src[p.pos]):
+			p.pos++
+		case p.hasPrefix("//"):
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		case p.hasPrefix("/*"):
+			end := strings.Index(string(p.src[p.pos+2:]), "*/")
+			if end < 0 {
+				p.pos = len(p.src)
+				return
+			}
+			p.pos += 2 + len([]rune(string(p.src[p.pos+2:])[:end])) + 2
+		default:
+			return
+		}
+	}
+}
+
+// hasPrefix reports whether the input continues with prefix
+func (p *jsParser) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(string(p.src[p.pos:]), prefix)
+}
+
+// peek reports whether the next character after whitespace is r
+func (p *jsParser) peek(r rune) bool {
+	p.skipSpace()
+	return p.pos < len(p.src) && p.src[p.pos] == r
+}
+
+// consume skips the next character after whitespace if it is r
+func (p *jsParser) consume(r rune) bool {
+	if p.peek(r) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// value reads a literal
+func (p *jsParser) value() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, fmt.Errorf("unexpected end of snippet")
+	}
+	switch r := p.src[p.pos]; {
+	case r == '"' || r == '\'':
+		return p.string(r)
+	case r == '`':
+		return p.template()
+	case r == '{':
+		return p.object()
+	case r == '[':
+		return p.array()
+	case r == '-' || r == '.' || unicode.IsDigit(r):
+		return p.number()
+	case r == '_' || r == '$' || unicode.IsLetter(r):
+		return p.expression()
+	default:
+		return nil, fmt.Errorf("unexpected %q at offset %d", r, p.pos)
+	}
+}
+
+// string reads a string in single or double quotes
+func (p *jsParser) string(quote rune) (string, error) {
+	var builder strings.Builder
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		r := p.src[p.pos]
+		switch {
+		case r == quote:
+			p.pos++
+			return builder.String(), nil
+		case r == '\\':
+			if err := p.escape(&builder); err != nil {
+				return "", err
+			}
+		case r == '\n':
+			return "", fmt.Errorf("unterminated string at offset %d", p.pos)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return "", fmt.Errorf("unterminated string")
+}
+
+// template reads a template literal; substitutions cannot be resolved
+func (p *jsParser) template() (string, error) {
+	var builder strings.Builder
+	for p.pos++; p.pos < len(p.src); p.pos++ {
+		r := p.src[p.pos]
+		switch {
+		case r == '`':
+			p.pos++
+			return builder.String(), nil
+		case r == '\\':
+			if err := p.escape(&builder); err != nil {
+				return "", err
+			}
+		case r == '$' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '{':
+			return "", fmt.Errorf("template literals with ${...} cannot be resolved, replace them with values or {{variables}}")
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return "", fmt.Errorf("unterminated template literal")
+}
+
+// jsEscapes are the single-character escapes of JavaScript strings
+var jsEscapes = map[rune]string{
+	'n': "\n", 't': "\t", 'r': "\r", 'b': "\b", 'f': "\f", 'v': "\v", '0': "\x00",
+	'\'': "'", '"': `"`, '\\': `\`, '`': "`", '$': "$", '\n': "",
+}
+
+// escape decodes the escape sequence at the current backslash, leaving the
+// position on its last character
+func (p *jsParser) escape(builder *strings.Builder) error {
+	if p.pos+1 >= len(p.src) {
+		return fmt.Errorf("unterminated escape sequence")
+	}
+	p.pos++
+	r := p.src[p.pos]
+	if decoded, ok := jsEscapes[r]; ok {
+		builder.WriteString(decoded)
+		return nil
+	}
+
+	var digits string
+	switch {
+	case r == 'x' && p.pos+2 < len(p.src):
+		digits = string(p.src[p.pos+1 : p.pos+3])
+		p.pos += 2
+	case r == 'u' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '{':
+		end := p.pos + 2
+		for end < len(p.src) && p.src[end] != '}' {
+			end++
+		}
+		if end >= len(p.src) {
+			return fmt.Errorf("unterminated \\u{...} escape")
+		}
+		digits = string(p.src[p.pos+2 : end])
+		p.pos = end
+	case r == 'u' && p.pos+4 < len(p.src):
+		digits = string(p.src[p.pos+1 : p.pos+5])
+		p.pos += 4
+	default:
+		// Other escaped characters stand for themselves
+		builder.WriteRune(r)
+		return nil
+	}
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil {
+		return fmt.Errorf("invalid escape sequence \\%c%s", r, digits)
+	}
+	builder.WriteRune(rune(code))
+	return nil
+}
+
+// object reads an object literal; keys are strings, identifiers or numbers
+func (p *jsParser) object() (map[string]interface{}, error) {
+	object := map[string]interface{}{}
+	p.pos++
+	for !p.consume('}') {
+		p.skipSpace()
+		if p.pos >= len(p.src) {
+			return nil, fmt.Errorf("unterminated object")
+		}
+		var key string
+		switch r := p.src[p.pos]; {
+		case r == '"' || r == '\'':
+			text, err := p.string(r)
+			if err != nil {
+				return nil, err
+			}
+			key = text
+		default:
+			key = p.identifier()
+			if key == "" {
+				return nil, fmt.Errorf("unexpected %q in object at offset %d", r, p.pos)
+			}
+		}
+		if !p.consume(':') {
+			return nil, fmt.Errorf("expected : after key %q, shorthand properties cannot be resolved", key)
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		object[key] = value
+		if !p.consume(',') && !p.peek('}') {
+			return nil, fmt.Errorf("expected , or } after the value of %q at offset %d", key, p.pos)
+		}
+	}
+	return object, nil
+}
+
+// array reads an array literal
+func (p *jsParser) array() ([]interface{}, error) {
+	array := []interface{}{}
+	p.pos++
+	for !p.consume(']') {
+		if p.pos >= len(p.src) {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+		if !p.consume(',') && !p.peek(']') {
+			return nil, fmt.Errorf("expected , or ] at offset %d", p.pos)
+		}
+	}
+	return array, nil
+}
+
+// number reads a number literal
+func (p *jsParser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.src) && strings.ContainsRune("0123456789+-.eE_xXabcdefABCDEF", p.src[p.pos]) {
+		p.pos++
+	}
+	text := strings.ReplaceAll(string(p.src[start:p.pos]), "_", "")
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		return number, nil
+	}
+	if integer, err := strconv.ParseInt(text, 0, 64); err == nil {
+		return float64(integer), nil
+	}
+	return 0, fmt.Errorf("invalid number %q", text)
+}
+
+// identifier reads a name, possibly empty
+func (p *jsParser) identifier() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r := p.src[p.pos]
+		if r != '_' && r != '$' && !unicode.IsLetter(r) && !(p.pos > start && unicode.IsDigit(r)) {
+			break
+		}
+		p.pos++
+	}
+	return string(p.src[start:p.pos])
+}
+
+// expression reads a keyword literal or one of the calls that wrap literals
+// in copied snippets
+func (p *jsParser) expression() (interface{}, error) {
+	start := p.pos
+	name := p.identifier()
+	switch name {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null", "undefined":
+		return nil, nil
+	case "JSON":
+		if !p.consume('.') || p.identifier() != "stringify" {
+			break
+		}
+		argument, err := p.call()
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(argument)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	case "new":
+		p.skipSpace()
+		switch p.identifier() {
+		case "Headers":
+			argument, err := p.call()
+			if err != nil {
+				return nil, err
+			}
+			return argument, nil
+		case "URLSearchParams":
+			argument, err := p.call()
+			if err != nil {
+				return nil, err
+			}
+			return urlSearchParams(argument)
+		}
+	}
+	end := p.pos
+	for end < len(p.src) && !strings.ContainsRune(",)}]\n", p.src[end]) {
+		end++
+	}
+	return nil, fmt.Errorf("cannot resolve the expression %q, replace it with a literal or a {{variable}}", strings.TrimSpace(string(p.src[start:end])))
+}
+
+// call reads the single argument of a call, or nil for no argument
+func (p *jsParser) call() (interface{}, error) {
+	if !p.consume('(') {
+		return nil, fmt.Errorf("expected ( at offset %d", p.pos)
+	}
+	if p.consume(')') {
+		return nil, nil
+	}
+	argument, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	if !p.consume(')') {
+		return nil, fmt.Errorf("expected ) at offset %d", p.pos)
+	}
+	return argument, nil
+}
+
+// urlSearchParams encodes the argument of new URLSearchParams(...) as a form
+func urlSearchParams(argument interface{}) (string, error) {
+	values := url.Values{}
+	switch argument := argument.(type) {
+	case nil:
+	case string:
+		return strings.TrimPrefix(argument, "?"), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(argument))
+		for key := range argument {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			values.Add(key, fetchText(argument[key]))
+		}
+	case []interface{}:
+		for _, pair := range argument {
+			entry, ok := pair.([]interface{})
+			if !ok || len(entry) != 2 {
+				return "", fmt.Errorf("URLSearchParams takes an object or a list of [name, value] pairs")
+			}
+			values.Add(fetchText(entry[0]), fetchText(entry[1]))
+		}
+	default:
+		return "", fmt.Errorf("URLSearchParams takes an object or a list of [name, value] pairs")
+	}
+	return values.Encode(), nil
+}
@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// HARLog represents an HTTP Archive (HAR 1.2) document
+type HARLog struct {
+	Log struct {
+		Entries []HAREntry `json:"entries"`
+	} `json:"log"`
+}
+
+// HAREntry represents a captured request/response pair
+type HAREntry struct {
+	Request struct {
+		Method   string         `json:"method"`
+		URL      string         `json:"url"`
+		Headers  []HARNameValue `json:"headers"`
+		PostData *HARPostData   `json:"postData"`
+	} `json:"request"`
+	Response struct {
+		Status  int            `json:"status"`
+		Headers []HARNameValue `json:"headers"`
+		Content struct {
+			MimeType string `json:"mimeType"`
+			Text     string `json:"text"`
+			Encoding string `json:"encoding"`
+		} `json:"content"`
+	} `json:"response"`
+}
+
+// HARPostData represents the body of a captured request
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []HARNameValue `json:"params"`
+}
+
+// HARNameValue is the name/value entry used for headers and form params
+type HARNameValue struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+}
+
+// HAROptions filters the entries converted from a HAR file
+type HAROptions struct {
+	AllowedHosts  []string // exact hosts or "*.example.com"; empty allows all
+	IncludeStatic bool     // keep scripts, stylesheets, images and fonts
+	BaseURL       string   // URLs under the service base URL are made relative
+}
+
+// maxGeneratedBodyAssertions limits the body assertions generated per entry
+const maxGeneratedBodyAssertions = 10
+
+var (
+	staticExtensions = map[string]bool{
+		".js": true, ".mjs": true, ".css": true, ".map": true, ".ico": true,
+		".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true, ".webp": true,
+		".woff": true, ".woff2": true, ".ttf": true, ".eot": true, ".otf": true,
+	}
+	// harDroppedHeaders are set by the browser or the HTTP client and are not
+	// meaningful to replay
+	harDroppedHeaders = map[string]bool{
+		"host": true, "content-length": true, "connection": true, "accept-encoding": true,
+		"origin": true, "referer": true, "user-agent": true, "cookie": true,
+	}
+	simpleJSONKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// ParseHAR converts the entries of a HAR file into test specs. Each entry
+// becomes a test asserting the captured status code and, for JSON objects, the
+// presence of the top level response fields. Static assets and hosts outside
+// the allowlist are skipped; credentials are never imported.
+func ParseHAR(data []byte, opts HAROptions) (*ImportResult, error) {
+	var har HARLog
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %v", err)
+	}
+	if len(har.Log.Entries) == 0 {
+		return nil, fmt.Errorf("HAR file contains no entries")
+	}
+
+	result := &ImportResult{Warnings: []ImportWarning{}}
+	skipped := 0
+	for _, entry := range har.Log.Entries {
+		requestURL, err := url.Parse(entry.Request.URL)
+		if err != nil || (requestURL.Scheme != "http" && requestURL.Scheme != "https") {
+			skipped++
+			continue
+		}
+		if !harHostAllowed(requestURL.Hostname(), opts.AllowedHosts) {
+			skipped++
+			continue
+		}
+		if !opts.IncludeStatic && harStaticAsset(requestURL, entry.Response.Content.MimeType) {
+			skipped++
+			continue
+		}
+
+		result.Tests = append(result.Tests, importHAREntry(result, entry, requestURL, opts.BaseURL))
+	}
+
+	if skipped > 0 {
+		result.warn("log.entries", "%d entries skipped by the host allowlist, static asset filter or an unsupported URL", skipped)
+	}
+	return result, nil
+}
+
+// importHAREntry converts a single entry
+func importHAREntry(result *ImportResult, entry HAREntry, requestURL *url.URL, baseURL string) ImportedTest {
+	method := strings.ToUpper(entry.Request.Method)
+	if method == "" {
+		method = "GET"
+	}
+
+	requestURL.Fragment = ""
+	target := requestURL.String()
+	if baseURL != "" && strings.HasPrefix(target, strings.TrimSuffix(baseURL, "/")+"/") {
+		target = strings.TrimPrefix(target, strings.TrimSuffix(baseURL, "/"))
+	}
+
+	name := method + " " + requestURL.Path
+	spec := models.TestSpec{
+		Name: name,
+		Request: models.RequestSpec{
+			Method:  method,
+			URL:     target,
+			Headers: make(map[string]string),
+		},
+	}
+
+	for _, header := range entry.Request.Headers {
+		key := strings.ToLower(header.Name)
+		switch {
+		case key == "" || strings.HasPrefix(key, ":") || strings.HasPrefix(key, "sec-") || harDroppedHeaders[key]:
+			continue
+		case key == "authorization" || key == "proxy-authorization":
+			result.warn(name, "%s header not imported, configure the service auth instead", header.Name)
+			continue
+		}
+		spec.Request.Headers[header.Name] = header.Value
+	}
+
+	if postData := entry.Request.PostData; postData != nil {
+		importHARPostData(result, name, postData, &spec.Request)
+	}
+
+	spec.Assertions = append(spec.Assertions, models.AssertionSpec{Type: "status_code", Expected: entry.Response.Status})
+	spec.Assertions = append(spec.Assertions, harBodyAssertions(entry)...)
+
+	return ImportedTest{Name: name, TestSpec: spec}
+}
+
+// importHARPostData maps the captured body; form params are kept as fields and
+// file uploads become warnings since their content is not part of the HAR
+func importHARPostData(result *ImportResult, name string, postData *HARPostData, request *models.RequestSpec) {
+	switch {
+	case postData.Text != "":
+		var body interface{}
+		if strings.Contains(postData.MimeType, "json") && json.Unmarshal([]byte(postData.Text), &body) == nil {
+			request.Body = body
+		} else {
+			request.Body = postData.Text
+		}
+	case strings.HasPrefix(postData.MimeType, "multipart/form-data"):
+		deleteHeader(request.Headers, "Content-Type")
+		for _, param := range postData.Params {
+			if param.FileName != "" {
+				result.warn(name, "file part %s not imported, attach a fixture instead", param.Name)
+				continue
+			}
+			request.Multipart = append(request.Multipart, models.MultipartPart{Name: param.Name, Value: param.Value})
+		}
+		return
+	case len(postData.Params) > 0:
+		form := url.Values{}
+		for _, param := range postData.Params {
+			form.Add(param.Name, param.Value)
+		}
+		request.Body = form.Encode()
+	default:
+		return
+	}
+
+	if postData.MimeType != "" {
+		setDefaultHeader(request.Headers, "Content-Type", postData.MimeType)
+	}
+}
+
+// harBodyAssertions asserts the top level fields of a captured JSON object
+func harBodyAssertions(entry HAREntry) []models.AssertionSpec {
+	content := entry.Response.Content
+	if content.Encoding != "" || !strings.Contains(content.MimeType, "json") {
+		return nil
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(content.Text), &body); err != nil {
+		return nil
+	}
+
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		if simpleJSONKey.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	if len(keys) > maxGeneratedBodyAssertions {
+		keys = keys[:maxGeneratedBodyAssertions]
+	}
+
+	assertions := make([]models.AssertionSpec, 0, len(keys))
+	for _, key := range keys {
+		assertions = append(assertions, models.AssertionSpec{Type: "exists", Path: "body." + key, Expected: true})
+	}
+	return assertions
+}
+
+// harHostAllowed matches a host against exact entries and "*.suffix" wildcards
+func harHostAllowed(host string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if suffix := strings.TrimPrefix(pattern, "*"); suffix != pattern {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// harStaticAsset reports whether an entry fetched a script, stylesheet, image or font
+func harStaticAsset(requestURL *url.URL, mimeType string) bool {
+	if staticExtensions[strings.ToLower(path.Ext(requestURL.Path))] {
+		return true
+	}
+	mimeType = strings.ToLower(mimeType)
+	for _, prefix := range []string{"image/", "font/", "text/css", "text/javascript", "application/javascript", "application/x-javascript"} {
+		if strings.HasPrefix(mimeType, prefix) {
+			return true
+		}
+	}
+	return false
+}
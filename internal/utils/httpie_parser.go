@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// httpieValueOptions lists HTTPie options taking a value that does not
+// change the request; their value is skipped so it is not taken for the URL
+var httpieValueOptions = map[string]bool{
+	"--verify": true, "--timeout": true,
+	"--print": true, "-p": true,
+	"--style": true, "-s": true,
+	"--pretty": true, "--format-options": true,
+	"--session": true, "--session-read-only": true,
+	"--max-redirects": true, "--max-headers": true,
+	"--cert": true, "--cert-key": true, "--cert-key-pass": true,
+	"--ssl": true, "--ciphers": true,
+	"--proxy": true, "--boundary": true,
+	"--output": true, "-o": true,
+	"--response-charset": true, "--response-mime": true,
+}
+
+// httpieSeparators separate the name and value of HTTPie request items,
+// longer ones first so "a:=1" is not taken for the header "a"
+var httpieSeparators = []string{":=@", "=@", "==", ":=", "@", "=", ":", ";"}
+
+// httpieMethodPattern matches a METHOD argument
+var httpieMethodPattern = regexp.MustCompile(`^[A-Za-z]+$`)
+
+// ParseHTTPieCommand parses an HTTPie command line, such as
+//
+//	http POST :3000/users name=Ada age:=36 Authorization:'Bearer token'
+//
+// into a request. Request items are headers (Name:value), query parameters
+// (name==value), string and raw JSON body fields (name=value, name:=json) and
+// file fields (name@path). Fields form a JSON object unless --form or
+// --multipart is given; file fields make the body multipart.
+func ParseHTTPieCommand(command string) (*CurlRequest, error) {
+	tokens, err := tokenizeCurl(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("invalid HTTPie command")
+	}
+	scheme := "http://"
+	switch path.Base(tokens[0]) {
+	case "http", "xh":
+	case "https", "xhs":
+		scheme = "https://"
+	default:
+		return nil, fmt.Errorf("not an HTTPie command: expected http or https, got %s", tokens[0])
+	}
+
+	result := newCurlRequest(command)
+	var (
+		mode       = "json" // json, form or multipart
+		auth       string
+		authType   = "basic"
+		rawBody    *string
+		positional []string
+		methodSet  bool
+	)
+	for i := 1; i < len(tokens); i++ {
+		token := tokens[i]
+		if !strings.HasPrefix(token, "-") || token == "-" {
+			positional = append(positional, token)
+			continue
+		}
+		if token == "--" {
+			positional = append(positional, tokens[i+1:]...)
+			break
+		}
+
+		name, value, inline := strings.Cut(token, "=")
+		optionValue := func() (string, error) {
+			if inline {
+				return value, nil
+			}
+			if i+1 >= len(tokens) {
+				return "", fmt.Errorf("missing value after %s", name)
+			}
+			i++
+			return tokens[i], nil
+		}
+		switch name {
+		case "--json", "-j":
+			mode = "json"
+		case "--form", "-f":
+			mode = "form"
+		case "--multipart":
+			mode = "multipart"
+		case "--auth", "-a":
+			if auth, err = optionValue(); err != nil {
+				return nil, err
+			}
+		case "--auth-type", "-A":
+			if authType, err = optionValue(); err != nil {
+				return nil, err
+			}
+		case "--raw":
+			raw, err := optionValue()
+			if err != nil {
+				return nil, err
+			}
+			rawBody = &raw
+		case "--default-scheme":
+			defaultScheme, err := optionValue()
+			if err != nil {
+				return nil, err
+			}
+			scheme = defaultScheme + "://"
+		default:
+			if httpieValueOptions[name] {
+				if _, err := optionValue(); err != nil {
+					return nil, err
+				}
+			}
+			// Other options, such as --follow or --verbose, don't change the request
+		}
+	}
+
+	// [METHOD] URL [REQUEST_ITEM ...]; like HTTPie, the first of several
+	// arguments is the method if it is a single word
+	if len(positional) >= 2 && httpieMethodPattern.MatchString(positional[0]) {
+		result.Method = strings.ToUpper(positional[0])
+		methodSet = true
+		positional = positional[1:]
+	}
+	if len(positional) == 0 {
+		return nil, fmt.Errorf("no URL found in HTTPie command")
+	}
+	result.URL = httpieURL(positional[0], scheme)
+
+	fields := map[string]interface{}{}
+	var fieldOrder []string
+	query := url.Values{}
+	for _, item := range positional[1:] {
+		key, separator, value, ok := httpieItem(item)
+		if !ok {
+			return nil, fmt.Errorf("invalid request item %q", item)
+		}
+		switch separator {
+		case ":":
+			if value == "" {
+				// "Name:" removes a header in HTTPie
+				deleteHeader(result.Headers, key)
+			} else {
+				result.Headers[key] = value
+			}
+		case ";":
+			result.Headers[key] = ""
+		case "==":
+			query.Add(key, value)
+		case "=", ":=":
+			var field interface{} = value
+			if separator == ":=" {
+				if err := json.Unmarshal([]byte(value), &field); err != nil {
+					return nil, fmt.Errorf("request item %s:= is not valid JSON: %v", key, err)
+				}
+			}
+			if _, exists := fields[key]; !exists {
+				fieldOrder = append(fieldOrder, key)
+			}
+			fields[key] = field
+		case "@":
+			file, contentType, _ := strings.Cut(value, ";type=")
+			result.Form = append(result.Form, CurlFormField{
+				Name:        key,
+				File:        file,
+				FileName:    path.Base(file),
+				ContentType: contentType,
+			})
+		case "=@", ":=@":
+			return nil, fmt.Errorf("request item %s%s%s reads data from a file, which is not supported; paste the content instead", key, separator, value)
+		}
+	}
+
+	if encoded := query.Encode(); encoded != "" {
+		separator := "?"
+		if strings.Contains(result.URL, "?") {
+			separator = "&"
+		}
+		result.URL += separator + encoded
+	}
+
+	switch {
+	case rawBody != nil:
+		if len(fields) > 0 || len(result.Form) > 0 {
+			return nil, fmt.Errorf("--raw cannot be combined with body fields")
+		}
+		result.Body = *rawBody
+		if mode == "json" {
+			setDefaultHeader(result.Headers, "Content-Type", "application/json")
+		}
+	case mode == "multipart" || len(result.Form) > 0:
+		fieldForms := make([]CurlFormField, 0, len(fieldOrder))
+		for _, key := range fieldOrder {
+			fieldForms = append(fieldForms, CurlFormField{Name: key, Value: httpieFieldText(fields[key])})
+		}
+		result.Form = append(fieldForms, result.Form...)
+	case len(fields) == 0:
+	case mode == "form":
+		values := make([]string, 0, len(fieldOrder))
+		for _, key := range fieldOrder {
+			values = append(values, url.QueryEscape(key)+"="+url.QueryEscape(httpieFieldText(fields[key])))
+		}
+		result.Body = strings.Join(values, "&")
+		setDefaultHeader(result.Headers, "Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	default:
+		body, err := json.Marshal(fields)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode body fields: %v", err)
+		}
+		result.Body = string(body)
+		setDefaultHeader(result.Headers, "Content-Type", "application/json")
+		setDefaultHeader(result.Headers, "Accept", "application/json, */*;q=0.5")
+	}
+	if !methodSet && (result.Body != "" || len(result.Form) > 0) {
+		result.Method = "POST"
+	}
+
+	if auth != "" {
+		switch strings.ToLower(authType) {
+		case "basic":
+			result.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(auth))
+		case "bearer":
+			result.Headers["Authorization"] = "Bearer " + auth
+		default:
+			return nil, fmt.Errorf("auth type %s is not supported, use basic or bearer", authType)
+		}
+	}
+
+	if err := completeRequest(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// httpieItem splits a request item at its first separator. A backslash
+// escapes a separator character that belongs to the name, as in a\:b=c.
+func httpieItem(item string) (key, separator, value string, ok bool) {
+	var name strings.Builder
+	for i := 0; i < len(item); i++ {
+		if item[i] == '\\' && i+1 < len(item) && strings.IndexByte(`:=@;\`, item[i+1]) >= 0 {
+			i++
+			name.WriteByte(item[i])
+			continue
+		}
+		for _, separator := range httpieSeparators {
+			if strings.HasPrefix(item[i:], separator) {
+				return name.String(), separator, item[i+len(separator):], name.Len() > 0
+			}
+		}
+		name.WriteByte(item[i])
+	}
+	return "", "", "", false
+}
+
+// httpieURL completes a URL argument like HTTPie: ":3000/users" and
+// ":/users" address localhost, and URLs without a scheme use the scheme of
+// the program, http or https
+func httpieURL(target, scheme string) string {
+	if rest, found := strings.CutPrefix(target, ":"); found {
+		if rest == "" || strings.HasPrefix(rest, "/") {
+			target = "localhost" + rest
+		} else {
+			target = "localhost:" + rest
+		}
+	}
+	if strings.Contains(target, "://") || strings.HasPrefix(target, "{{") {
+		return target
+	}
+	return scheme + target
+}
+
+// httpieFieldText returns a body field as a form value: strings as they are,
+// raw JSON fields as JSON
+func httpieFieldText(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"fmt"
+
+	"api-test-framework/internal/models"
+)
+
+// ImportedTest represents a test case converted from an external format
+type ImportedTest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	TestSpec    models.TestSpec `json:"test_spec"`
+}
+
+// ImportWarning reports a part of the source that could not be converted
+type ImportWarning struct {
+	Item    string `json:"item"`
+	Message string `json:"message"`
+}
+
+// ImportResult holds the outcome of converting an external collection
+type ImportResult struct {
+	Tests     []ImportedTest    `json:"tests"`
+	Variables map[string]string `json:"variables,omitempty"`
+	Warnings  []ImportWarning   `json:"warnings"`
+}
+
+// warn records a conversion warning for an item
+func (r *ImportResult) warn(item, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, ImportWarning{Item: item, Message: fmt.Sprintf(format, args...)})
+}
+
+// defaultAssertions is used when nothing in the source maps to an assertion
+func defaultAssertions() []models.AssertionSpec {
+	return []models.AssertionSpec{
+		{Type: "status_code", Expected: 200},
+	}
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseJWT(t *testing.T) {
+	secret := []byte("test-secret")
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	valid := JWTClaims{Subject: "user-1", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}
+
+	sign := func(claims JWTClaims) string {
+		token, err := SignJWT(claims, secret)
+		if err != nil {
+			t.Fatalf("SignJWT failed: %v", err)
+		}
+		return token
+	}
+	token := sign(valid)
+	parts := strings.Split(token, ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		secret  []byte
+		wantErr string
+	}{
+		{name: "valid", token: token, secret: secret},
+		{name: "wrong secret", token: token, secret: []byte("other"), wantErr: "signature mismatch"},
+		{name: "tampered claims", token: parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin","exp":9999999999}`)) + "." + parts[2], secret: secret, wantErr: "signature mismatch"},
+		{name: "alg none", token: base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".", secret: secret, wantErr: "unsupported algorithm"},
+		{name: "two parts", token: parts[0] + "." + parts[1], secret: secret, wantErr: "three dot-separated parts"},
+		{name: "expired", token: sign(JWTClaims{Subject: "user-1", ExpiresAt: now.Unix()}), secret: secret, wantErr: "expired"},
+		{name: "not yet valid", token: sign(JWTClaims{Subject: "user-1", NotBefore: now.Add(time.Minute).Unix(), ExpiresAt: now.Add(time.Hour).Unix()}), secret: secret, wantErr: "not valid before"},
+		{name: "missing subject", token: sign(JWTClaims{ExpiresAt: now.Add(time.Hour).Unix()}), secret: secret, wantErr: "missing sub"},
+		{name: "missing expiry", token: sign(JWTClaims{Subject: "user-1"}), secret: secret, wantErr: "missing exp"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseJWT(tt.token, tt.secret, now)
+			if tt.wantErr != "" {
+				if err == nil || !errors.Is(err, ErrInvalidToken) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *claims != valid {
+				t.Errorf("claims = %+v, want %+v", *claims, valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,504 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"api-test-framework/internal/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OpenAPIImport holds the service description and tests derived from an OpenAPI document
+type OpenAPIImport struct {
+	ServiceName string `json:"service_name"`
+	Description string `json:"description"`
+	BaseURL     string `json:"base_url"`
+	ImportResult
+}
+
+// openAPIMethods lists the operations of a path item in a stable order
+var openAPIMethods = []string{"get", "post", "put", "patch", "delete", "head", "options"}
+
+// maxSchemaDepth bounds $ref expansion so recursive schemas terminate
+const maxSchemaDepth = 8
+
+// openAPIDocument wraps a decoded OpenAPI document for $ref resolution
+type openAPIDocument struct {
+	root map[string]interface{}
+}
+
+// ParseOpenAPI converts an OpenAPI 3.x document (JSON or YAML) into one test
+// per operation. Request bodies come from examples or are generated from the
+// schema, and each test asserts the documented success status and, when
+// available, the JSON schema of the success response.
+func ParseOpenAPI(data []byte) (*OpenAPIImport, error) {
+	var decoded interface{}
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %v", err)
+	}
+	root, ok := normalizeYAML(decoded).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid OpenAPI document: expected an object")
+	}
+
+	version, _ := root["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q, expected 3.x", version)
+	}
+
+	doc := &openAPIDocument{root: root}
+	info := mapValue(root["info"])
+
+	result := &OpenAPIImport{
+		ServiceName: stringValue(info["title"]),
+		Description: stringValue(info["description"]),
+		ImportResult: ImportResult{
+			Variables: make(map[string]string),
+			Warnings:  []ImportWarning{},
+		},
+	}
+	if servers, ok := root["servers"].([]interface{}); ok && len(servers) > 0 {
+		result.BaseURL = openAPIServerURL(mapValue(servers[0]))
+	}
+	if _, ok := root["security"]; ok {
+		result.warn("security", "security schemes are not imported, configure the service auth config")
+	}
+
+	paths := mapValue(root["paths"])
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("OpenAPI document defines no paths")
+	}
+
+	pathNames := make([]string, 0, len(paths))
+	for path := range paths {
+		pathNames = append(pathNames, path)
+	}
+	sort.Strings(pathNames)
+
+	for _, path := range pathNames {
+		pathItem := doc.resolve(mapValue(paths[path]))
+		for _, method := range openAPIMethods {
+			operation, ok := pathItem[method].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			test := doc.importOperation(&result.ImportResult, path, method, pathItem, operation)
+			result.Tests = append(result.Tests, test)
+		}
+	}
+
+	return result, nil
+}
+
+// openAPIServerURL expands the server variables with their defaults
+func openAPIServerURL(server map[string]interface{}) string {
+	serverURL := stringValue(server["url"])
+	for name, variable := range mapValue(server["variables"]) {
+		serverURL = strings.ReplaceAll(serverURL, "{"+name+"}", stringValue(mapValue(variable)["default"]))
+	}
+	return serverURL
+}
+
+// importOperation converts a single operation into a test
+func (d *openAPIDocument) importOperation(result *ImportResult, path, method string, pathItem, operation map[string]interface{}) ImportedTest {
+	name := stringValue(operation["operationId"])
+	if name == "" {
+		name = stringValue(operation["summary"])
+	}
+	if name == "" {
+		name = strings.ToUpper(method) + " " + path
+	}
+
+	spec := models.TestSpec{
+		Name:        name,
+		Description: stringValue(operation["description"]),
+		Request: models.RequestSpec{
+			Method:  strings.ToUpper(method),
+			Headers: make(map[string]string),
+		},
+	}
+	if spec.Description == "" {
+		spec.Description = stringValue(operation["summary"])
+	}
+
+	requestPath := path
+	query := url.Values{}
+	for _, parameter := range d.operationParameters(pathItem, operation) {
+		paramName := stringValue(parameter["name"])
+		required, _ := parameter["required"].(bool)
+		value, hasExample := d.parameterExample(parameter)
+
+		switch stringValue(parameter["in"]) {
+		case "path":
+			if !hasExample {
+				value = "{{" + paramName + "}}"
+				result.warn(name, "path parameter '%s' has no example, set the '%s' variable", paramName, paramName)
+			}
+			requestPath = strings.ReplaceAll(requestPath, "{"+paramName+"}", value)
+		case "query":
+			if required || hasExample {
+				if !hasExample {
+					value = "{{" + paramName + "}}"
+					result.warn(name, "required query parameter '%s' has no example, set the '%s' variable", paramName, paramName)
+				}
+				query.Set(paramName, value)
+			}
+		case "header":
+			if required || hasExample {
+				spec.Request.Headers[paramName] = value
+			}
+		case "cookie":
+			if required {
+				result.warn(name, "cookie parameter '%s' is not supported", paramName)
+			}
+		}
+	}
+	spec.Request.URL = requestPath
+	if len(query) > 0 {
+		spec.Request.URL += "?" + strings.NewReplacer("%7B", "{", "%7D", "}").Replace(query.Encode())
+	}
+
+	if requestBody := d.resolve(mapValue(operation["requestBody"])); len(requestBody) > 0 {
+		d.importRequestBody(result, name, requestBody, &spec.Request)
+	}
+
+	spec.Assertions = d.responseAssertions(operation)
+
+	return ImportedTest{
+		Name:        name,
+		Description: spec.Description,
+		TestSpec:    spec,
+	}
+}
+
+// operationParameters returns the parameters of an operation. Path item
+// parameters apply to every operation unless the operation redefines them.
+func (d *openAPIDocument) operationParameters(pathItem, operation map[string]interface{}) []map[string]interface{} {
+	var parameters []map[string]interface{}
+	defined := map[string]bool{}
+	for _, source := range [][]interface{}{listValue(operation["parameters"]), listValue(pathItem["parameters"])} {
+		for _, raw := range source {
+			parameter := d.resolve(mapValue(raw))
+			key := stringValue(parameter["in"]) + ":" + stringValue(parameter["name"])
+			if !defined[key] {
+				defined[key] = true
+				parameters = append(parameters, parameter)
+			}
+		}
+	}
+	return parameters
+}
+
+// parameterExample returns the example value of a parameter as a string
+func (d *openAPIDocument) parameterExample(parameter map[string]interface{}) (string, bool) {
+	if example, ok := parameter["example"]; ok {
+		return fmt.Sprint(example), true
+	}
+	for _, example := range mapValue(parameter["examples"]) {
+		if value, ok := d.resolve(mapValue(example))["value"]; ok {
+			return fmt.Sprint(value), true
+		}
+	}
+	schema := d.resolve(mapValue(parameter["schema"]))
+	if example, ok := schema["example"]; ok {
+		return fmt.Sprint(example), true
+	}
+	if value, ok := schema["default"]; ok {
+		return fmt.Sprint(value), true
+	}
+	if enum := listValue(schema["enum"]); len(enum) > 0 {
+		return fmt.Sprint(enum[0]), true
+	}
+	return "", false
+}
+
+// importRequestBody sets the request body from the JSON (or form) content of the operation
+func (d *openAPIDocument) importRequestBody(result *ImportResult, name string, requestBody map[string]interface{}, request *models.RequestSpec) {
+	content := mapValue(requestBody["content"])
+
+	for mediaType, raw := range content {
+		if !strings.Contains(mediaType, "json") {
+			continue
+		}
+		media := mapValue(raw)
+		request.Headers["Content-Type"] = mediaType
+		request.Body = d.mediaExample(media)
+		return
+	}
+
+	if raw, ok := content["application/x-www-form-urlencoded"]; ok {
+		body, _ := d.mediaExample(mapValue(raw)).(map[string]interface{})
+		values := url.Values{}
+		for key, value := range body {
+			values.Set(key, fmt.Sprint(value))
+		}
+		request.Headers["Content-Type"] = "application/x-www-form-urlencoded"
+		request.Body = values.Encode()
+		return
+	}
+
+	if len(content) > 0 {
+		mediaTypes := make([]string, 0, len(content))
+		for mediaType := range content {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+		sort.Strings(mediaTypes)
+		result.warn(name, "request body media types %s are not supported", strings.Join(mediaTypes, ", "))
+	}
+}
+
+// mediaExample returns the example of a media type object, generating one from its schema if needed
+func (d *openAPIDocument) mediaExample(media map[string]interface{}) interface{} {
+	if example, ok := media["example"]; ok {
+		return example
+	}
+	for _, example := range mapValue(media["examples"]) {
+		if value, ok := d.resolve(mapValue(example))["value"]; ok {
+			return value
+		}
+	}
+	return d.generateExample(mapValue(media["schema"]), 0)
+}
+
+// responseAssertions asserts the first documented 2xx status and its JSON schema
+func (d *openAPIDocument) responseAssertions(operation map[string]interface{}) []models.AssertionSpec {
+	responses := mapValue(operation["responses"])
+
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		if strings.HasPrefix(code, "2") {
+			codes = append(codes, code)
+		}
+	}
+	sort.Strings(codes)
+
+	if len(codes) == 0 {
+		return defaultAssertions()
+	}
+
+	status, err := strconv.Atoi(codes[0])
+	if err != nil {
+		// Ranges such as "2XX" only document the class
+		status = 200
+	}
+	assertions := []models.AssertionSpec{{Type: "status_code", Expected: status}}
+
+	response := d.resolve(mapValue(responses[codes[0]]))
+	for mediaType, raw := range mapValue(response["content"]) {
+		if !strings.Contains(mediaType, "json") {
+			continue
+		}
+		if schema := mapValue(mapValue(raw)["schema"]); len(schema) > 0 {
+			assertions = append(assertions, models.AssertionSpec{
+				Type:     "json_schema",
+				Expected: d.jsonSchema(schema, 0),
+			})
+		}
+		break
+	}
+
+	return assertions
+}
+
+// resolve follows a local $ref ("#/components/...") to its target
+func (d *openAPIDocument) resolve(node map[string]interface{}) map[string]interface{} {
+	for i := 0; i < maxSchemaDepth; i++ {
+		ref, ok := node["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return node
+		}
+
+		var target interface{} = d.root
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			part = strings.NewReplacer("~1", "/", "~0", "~").Replace(part)
+			target = mapValue(target)[part]
+		}
+		node = mapValue(target)
+	}
+	return node
+}
+
+// jsonSchema converts an OpenAPI schema into a self-contained JSON schema:
+// references are inlined and "nullable" becomes a null type
+func (d *openAPIDocument) jsonSchema(schema map[string]interface{}, depth int) map[string]interface{} {
+	schema = d.resolve(schema)
+	if depth > maxSchemaDepth {
+		return map[string]interface{}{}
+	}
+
+	converted := make(map[string]interface{}, len(schema))
+	for key, value := range schema {
+		switch key {
+		case "properties", "patternProperties":
+			properties := map[string]interface{}{}
+			for name, property := range mapValue(value) {
+				properties[name] = d.jsonSchema(mapValue(property), depth+1)
+			}
+			converted[key] = properties
+		case "items", "not":
+			converted[key] = d.jsonSchema(mapValue(value), depth+1)
+		case "additionalProperties":
+			if nested, ok := value.(map[string]interface{}); ok {
+				converted[key] = d.jsonSchema(nested, depth+1)
+			} else {
+				converted[key] = value
+			}
+		case "allOf", "anyOf", "oneOf":
+			list := listValue(value)
+			schemas := make([]interface{}, 0, len(list))
+			for _, item := range list {
+				schemas = append(schemas, d.jsonSchema(mapValue(item), depth+1))
+			}
+			converted[key] = schemas
+		case "nullable", "example", "discriminator", "readOnly", "writeOnly", "xml", "externalDocs", "deprecated":
+			// OpenAPI keywords without a JSON schema equivalent
+		default:
+			converted[key] = value
+		}
+	}
+
+	if nullable, _ := schema["nullable"].(bool); nullable {
+		if schemaType, ok := converted["type"].(string); ok {
+			converted["type"] = []interface{}{schemaType, "null"}
+		}
+	}
+	return converted
+}
+
+// generateExample builds a sample value satisfying a schema
+func (d *openAPIDocument) generateExample(schema map[string]interface{}, depth int) interface{} {
+	schema = d.resolve(schema)
+	if depth > maxSchemaDepth || len(schema) == 0 {
+		return nil
+	}
+
+	if example, ok := schema["example"]; ok {
+		return example
+	}
+	if value, ok := schema["default"]; ok {
+		return value
+	}
+	if enum := listValue(schema["enum"]); len(enum) > 0 {
+		return enum[0]
+	}
+
+	if allOf := listValue(schema["allOf"]); len(allOf) > 0 {
+		merged := map[string]interface{}{}
+		for _, item := range allOf {
+			if object, ok := d.generateExample(mapValue(item), depth+1).(map[string]interface{}); ok {
+				for key, value := range object {
+					merged[key] = value
+				}
+			}
+		}
+		return merged
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if options := listValue(schema[key]); len(options) > 0 {
+			return d.generateExample(mapValue(options[0]), depth+1)
+		}
+	}
+
+	switch stringValue(schema["type"]) {
+	case "object", "":
+		// Nested objects only carry their required properties, which keeps
+		// examples of recursive schemas small
+		required := map[string]bool{}
+		for _, name := range listValue(schema["required"]) {
+			required[fmt.Sprint(name)] = true
+		}
+		object := map[string]interface{}{}
+		for name, property := range mapValue(schema["properties"]) {
+			if depth > 0 && !required[name] {
+				continue
+			}
+			if value := d.generateExample(mapValue(property), depth+1); value != nil {
+				object[name] = value
+			}
+		}
+		return object
+	case "array":
+		return []interface{}{d.generateExample(mapValue(schema["items"]), depth+1)}
+	case "integer":
+		if minimum, ok := schema["minimum"]; ok {
+			return minimum
+		}
+		return 1
+	case "number":
+		if minimum, ok := schema["minimum"]; ok {
+			return minimum
+		}
+		return 1.0
+	case "boolean":
+		return true
+	default:
+		return exampleString(stringValue(schema["format"]))
+	}
+}
+
+// exampleString returns a sample string for a string format
+func exampleString(format string) string {
+	switch format {
+	case "date":
+		return "2024-01-01"
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case "uri", "url":
+		return "https://example.com"
+	default:
+		return "string"
+	}
+}
+
+// mapValue returns v as an object, or an empty object
+func mapValue(v interface{}) map[string]interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{}
+}
+
+// listValue returns v as a list, or nil
+func listValue(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	return list
+}
+
+// stringValue returns v as a string, or ""
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// normalizeYAML converts maps with non-string keys (e.g. response codes
+// written as YAML integers) into string-keyed maps
+func normalizeYAML(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = normalizeYAML(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			converted[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalizeYAML(item)
+		}
+		return value
+	default:
+		return v
+	}
+}
@@ -0,0 +1,566 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"api-test-framework/internal/models"
+)
+
+// PostmanCollection represents a Postman v2.1 collection
+type PostmanCollection struct {
+	Info struct {
+		Name        string          `json:"name"`
+		Description json.RawMessage `json:"description"`
+		Schema      string          `json:"schema"`
+	} `json:"info"`
+	Item     []PostmanItem     `json:"item"`
+	Auth     *PostmanAuth      `json:"auth"`
+	Variable []PostmanKeyValue `json:"variable"`
+}
+
+// PostmanItem is either a folder (with nested items) or a request
+type PostmanItem struct {
+	Name        string          `json:"name"`
+	Description json.RawMessage `json:"description"`
+	Item        []PostmanItem   `json:"item"`
+	Request     *PostmanRequest `json:"request"`
+	Event       []PostmanEvent  `json:"event"`
+	Auth        *PostmanAuth    `json:"auth"`
+}
+
+// PostmanRequest represents the request of a Postman item
+type PostmanRequest struct {
+	Method string            `json:"method"`
+	Header []PostmanKeyValue `json:"header"`
+	URL    PostmanURL        `json:"url"`
+	Body   *PostmanBody      `json:"body"`
+	Auth   *PostmanAuth      `json:"auth"`
+}
+
+// PostmanURL accepts both the string and the object form of a request URL
+type PostmanURL struct {
+	Raw   string            `json:"raw"`
+	Query []PostmanKeyValue `json:"query"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (u *PostmanURL) UnmarshalJSON(data []byte) error {
+	var raw string
+	if err := json.Unmarshal(data, &raw); err == nil {
+		u.Raw = raw
+		return nil
+	}
+	type plain PostmanURL
+	return json.Unmarshal(data, (*plain)(u))
+}
+
+// PostmanBody represents the body of a Postman request
+type PostmanBody struct {
+	Mode       string            `json:"mode"` // raw, urlencoded, formdata, graphql, file
+	Raw        string            `json:"raw"`
+	URLEncoded []PostmanKeyValue `json:"urlencoded"`
+	FormData   []PostmanKeyValue `json:"formdata"`
+	GraphQL    *struct {
+		Query     string `json:"query"`
+		Variables string `json:"variables"`
+	} `json:"graphql"`
+}
+
+// PostmanAuth represents an auth block; parameters are keyed by auth type
+type PostmanAuth struct {
+	Type   string            `json:"type"`
+	Bearer []PostmanKeyValue `json:"bearer"`
+	Basic  []PostmanKeyValue `json:"basic"`
+	APIKey []PostmanKeyValue `json:"apikey"`
+	OAuth2 []PostmanKeyValue `json:"oauth2"`
+}
+
+// PostmanEvent represents a pre-request or test script
+type PostmanEvent struct {
+	Listen string `json:"listen"`
+	Script struct {
+		Exec json.RawMessage `json:"exec"`
+	} `json:"script"`
+}
+
+// PostmanKeyValue is the key/value entry used for headers, queries, variables and auth parameters
+type PostmanKeyValue struct {
+	Key      string      `json:"key"`
+	Value    interface{} `json:"value"`
+	Type     string      `json:"type"`
+	Src      interface{} `json:"src"`
+	Disabled bool        `json:"disabled"`
+}
+
+// String returns the value as a string
+func (kv PostmanKeyValue) String() string {
+	switch v := kv.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// ParsePostmanCollection converts a Postman v2.1 collection into test specs.
+// Folders become name prefixes, auth is inherited from folders and the
+// collection, and common pm.* test script assertions are translated. Anything
+// that cannot be converted is reported as a warning.
+func ParsePostmanCollection(data []byte) (*ImportResult, error) {
+	var collection PostmanCollection
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("invalid Postman collection: %v", err)
+	}
+	if collection.Info.Schema != "" && !strings.Contains(collection.Info.Schema, "v2.1") {
+		return nil, fmt.Errorf("unsupported Postman collection schema %s, expected v2.1", collection.Info.Schema)
+	}
+	if len(collection.Item) == 0 {
+		return nil, fmt.Errorf("Postman collection contains no items")
+	}
+
+	result := &ImportResult{
+		Variables: make(map[string]string),
+		Warnings:  []ImportWarning{},
+	}
+	for _, variable := range collection.Variable {
+		if variable.Key != "" && !variable.Disabled {
+			result.Variables[variable.Key] = variable.String()
+		}
+	}
+
+	importPostmanItems(result, collection.Item, "", collection.Auth)
+	return result, nil
+}
+
+// importPostmanItems converts items recursively, prefixing names with their folder path
+func importPostmanItems(result *ImportResult, items []PostmanItem, folder string, auth *PostmanAuth) {
+	for _, item := range items {
+		name := item.Name
+		if folder != "" {
+			name = folder + " / " + item.Name
+		}
+
+		itemAuth := auth
+		if item.Auth != nil {
+			itemAuth = item.Auth
+		}
+
+		if item.Request == nil {
+			if len(item.Item) == 0 {
+				result.warn(name, "empty folder or item without request")
+			}
+			importPostmanItems(result, item.Item, name, itemAuth)
+			continue
+		}
+
+		if test, ok := importPostmanRequest(result, name, item, itemAuth); ok {
+			result.Tests = append(result.Tests, test)
+		}
+	}
+}
+
+// importPostmanRequest converts a single request item
+func importPostmanRequest(result *ImportResult, name string, item PostmanItem, auth *PostmanAuth) (ImportedTest, bool) {
+	request := item.Request
+	if request.Auth != nil {
+		auth = request.Auth
+	}
+
+	requestURL := postmanRequestURL(request.URL)
+	if requestURL == "" {
+		result.warn(name, "request has no URL")
+		return ImportedTest{}, false
+	}
+
+	method := strings.ToUpper(request.Method)
+	if method == "" {
+		method = "GET"
+	}
+
+	spec := models.TestSpec{
+		Name:        name,
+		Description: postmanDescription(item.Description),
+		Request: models.RequestSpec{
+			Method:  method,
+			URL:     requestURL,
+			Headers: make(map[string]string),
+		},
+	}
+
+	for _, header := range request.Header {
+		if !header.Disabled && header.Key != "" {
+			spec.Request.Headers[header.Key] = header.String()
+		}
+	}
+
+	importPostmanBody(result, name, request.Body, &spec.Request)
+
+	if auth != nil {
+		spec.Request.Auth = importPostmanAuth(result, name, auth)
+	}
+
+	for _, event := range item.Event {
+		lines := postmanScriptLines(event.Script.Exec)
+		switch event.Listen {
+		case "test":
+			spec.Assertions = append(spec.Assertions, importPostmanTestScript(result, name, lines)...)
+		case "prerequest":
+			if len(lines) > 0 {
+				result.warn(name, "pre-request scripts are not supported and were ignored")
+			}
+		}
+	}
+	if len(spec.Assertions) == 0 {
+		spec.Assertions = defaultAssertions()
+	}
+
+	return ImportedTest{
+		Name:        name,
+		Description: spec.Description,
+		TestSpec:    spec,
+	}, true
+}
+
+// postmanHostVariable matches a leading {{baseUrl}}-style variable standing for the service host
+var postmanHostVariable = regexp.MustCompile(`^\{\{\s*[A-Za-z0-9_.\-]*(?i:url|host)[A-Za-z0-9_.\-]*\s*\}\}`)
+
+// postmanRequestURL builds the request URL. A leading host variable such as
+// {{baseUrl}} is dropped so the path resolves against the service base URL.
+func postmanRequestURL(u PostmanURL) string {
+	raw := strings.TrimSpace(u.Raw)
+	if raw == "" {
+		return ""
+	}
+
+	// Disabled query parameters are still part of the raw URL
+	if len(u.Query) > 0 {
+		if idx := strings.Index(raw, "?"); idx >= 0 {
+			raw = raw[:idx]
+		}
+		values := make([]string, 0, len(u.Query))
+		for _, query := range u.Query {
+			if query.Disabled {
+				continue
+			}
+			values = append(values, url.QueryEscape(query.Key)+"="+escapePostmanQueryValue(query.String()))
+		}
+		if len(values) > 0 {
+			raw += "?" + strings.Join(values, "&")
+		}
+	}
+
+	if loc := postmanHostVariable.FindStringIndex(raw); loc != nil {
+		raw = raw[loc[1]:]
+		if !strings.HasPrefix(raw, "/") {
+			raw = "/" + raw
+		}
+	}
+	return raw
+}
+
+// escapePostmanQueryValue escapes a query value while keeping {{variables}} intact
+func escapePostmanQueryValue(value string) string {
+	if strings.Contains(value, "{{") {
+		return value
+	}
+	return url.QueryEscape(value)
+}
+
+// importPostmanBody maps the request body onto the request spec
+func importPostmanBody(result *ImportResult, name string, body *PostmanBody, request *models.RequestSpec) {
+	if body == nil {
+		return
+	}
+
+	switch body.Mode {
+	case "", "none":
+	case "raw":
+		if body.Raw == "" {
+			return
+		}
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(body.Raw), &parsed); err == nil {
+			request.Body = parsed
+		} else {
+			request.Body = body.Raw
+		}
+	case "urlencoded":
+		values := make([]string, 0, len(body.URLEncoded))
+		for _, field := range body.URLEncoded {
+			if !field.Disabled {
+				values = append(values, url.QueryEscape(field.Key)+"="+url.QueryEscape(field.String()))
+			}
+		}
+		request.Body = strings.Join(values, "&")
+		setDefaultHeader(request.Headers, "Content-Type", "application/x-www-form-urlencoded")
+	case "formdata":
+		for _, field := range body.FormData {
+			if field.Disabled {
+				continue
+			}
+			if field.Type == "file" {
+				result.warn(name, "file form field '%s' must be uploaded as a fixture and referenced manually", field.Key)
+				continue
+			}
+			request.Multipart = append(request.Multipart, models.MultipartPart{Name: field.Key, Value: field.String()})
+		}
+		// The multipart encoder sets its own Content-Type with the boundary
+		deleteHeader(request.Headers, "Content-Type")
+	case "graphql":
+		if body.GraphQL == nil {
+			return
+		}
+		graphQLBody := map[string]interface{}{"query": body.GraphQL.Query}
+		if body.GraphQL.Variables != "" {
+			var variables interface{}
+			if err := json.Unmarshal([]byte(body.GraphQL.Variables), &variables); err == nil {
+				graphQLBody["variables"] = variables
+			} else {
+				result.warn(name, "GraphQL variables are not valid JSON and were ignored")
+			}
+		}
+		request.Body = graphQLBody
+		setDefaultHeader(request.Headers, "Content-Type", "application/json")
+	default:
+		result.warn(name, "body mode '%s' is not supported", body.Mode)
+	}
+}
+
+// importPostmanAuth maps a Postman auth block onto an auth config
+func importPostmanAuth(result *ImportResult, name string, auth *PostmanAuth) *models.AuthConfig {
+	param := func(params []PostmanKeyValue, key string) string {
+		for _, p := range params {
+			if p.Key == key {
+				return p.String()
+			}
+		}
+		return ""
+	}
+
+	switch auth.Type {
+	case "noauth":
+		return &models.AuthConfig{Type: "none"}
+	case "bearer":
+		return &models.AuthConfig{Type: "bearer", Token: param(auth.Bearer, "token")}
+	case "basic":
+		return &models.AuthConfig{
+			Type:     "basic",
+			Username: param(auth.Basic, "username"),
+			Password: param(auth.Basic, "password"),
+		}
+	case "apikey":
+		config := &models.AuthConfig{
+			Type:     "api_key",
+			KeyName:  param(auth.APIKey, "key"),
+			KeyValue: param(auth.APIKey, "value"),
+		}
+		if param(auth.APIKey, "in") == "query" {
+			config.Extra = map[string]string{"in": "query"}
+		}
+		return config
+	case "oauth2":
+		if token := param(auth.OAuth2, "accessToken"); token != "" && param(auth.OAuth2, "accessTokenUrl") == "" {
+			return &models.AuthConfig{Type: "bearer", Token: token}
+		}
+		config := &models.AuthConfig{
+			Type:         "oauth2",
+			ClientID:     param(auth.OAuth2, "clientId"),
+			ClientSecret: param(auth.OAuth2, "clientSecret"),
+			TokenURL:     param(auth.OAuth2, "accessTokenUrl"),
+			Username:     param(auth.OAuth2, "username"),
+			Password:     param(auth.OAuth2, "password"),
+			Extra:        map[string]string{},
+		}
+		if grantType := param(auth.OAuth2, "grant_type"); grantType == "password_credentials" {
+			config.Extra["grant_type"] = "password"
+		} else if grantType != "" && grantType != "client_credentials" {
+			result.warn(name, "OAuth2 grant type '%s' is not supported", grantType)
+		}
+		if scope := param(auth.OAuth2, "scope"); scope != "" {
+			config.Extra["scope"] = scope
+		}
+		if param(auth.OAuth2, "client_authentication") == "header" {
+			config.Extra["client_auth"] = "basic"
+		}
+		return config
+	default:
+		result.warn(name, "auth type '%s' is not supported, the service auth config will be used", auth.Type)
+		return nil
+	}
+}
+
+// Patterns of pm.* test script statements that map onto assertions
+var (
+	postmanStatusPattern       = regexp.MustCompile(`pm\.response\.to\.have\.status\(\s*(\d{3})\s*\)`)
+	postmanStatusExpectPattern = regexp.MustCompile(`pm\.expect\(\s*pm\.response\.(?:code|status)\s*\)\.to\.(?:eql|equal|be\.equal)\(\s*(\d{3})\s*\)`)
+	postmanStatusOneOfPattern  = regexp.MustCompile(`pm\.expect\(\s*pm\.response\.(?:code|status)\s*\)\.to\.be\.oneOf\(\s*\[([\d\s,]+)\]\s*\)`)
+	postmanStatusClassPattern  = regexp.MustCompile(`pm\.response\.to\.be\.(info|success|redirection|clientError|serverError)\b`)
+	postmanTimePattern         = regexp.MustCompile(`pm\.expect\(\s*pm\.response\.responseTime\s*\)\.to\.be\.(?:below|lessThan)\(\s*(\d+)\s*\)`)
+	postmanHeaderPattern       = regexp.MustCompile(`pm\.response\.to\.have\.header\(\s*['"]([^'"]+)['"]\s*\)`)
+	postmanJSONAliasPattern    = regexp.MustCompile(`(?:var|let|const)\s+(\w+)\s*=\s*pm\.response\.json\(\)`)
+	postmanPropertyPattern     = regexp.MustCompile(`pm\.expect\(\s*([\w.\[\]()]+)\s*\)\.to\.have\.property\(\s*['"]([^'"]+)['"]\s*\)`)
+	postmanEqualsPattern       = regexp.MustCompile(`pm\.expect\(\s*([\w.\[\]()]+)\s*\)\.to\.(?:eql|equal|be\.equal)\(\s*(.+?)\s*\)\s*;?\s*$`)
+	postmanAssertionCall       = regexp.MustCompile(`pm\.(?:expect|response\.to)|tests\[`)
+)
+
+// postmanStatusClasses maps the pm.response.to.be.* status class checks to classes
+var postmanStatusClasses = map[string]string{
+	"info":        "1xx",
+	"success":     "2xx",
+	"redirection": "3xx",
+	"clientError": "4xx",
+	"serverError": "5xx",
+}
+
+// importPostmanTestScript translates the common pm.* assertions of a test
+// script; statements that cannot be translated are reported
+func importPostmanTestScript(result *ImportResult, name string, lines []string) []models.AssertionSpec {
+	var assertions []models.AssertionSpec
+	aliases := map[string]bool{}
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+
+		if match := postmanJSONAliasPattern.FindStringSubmatch(line); match != nil {
+			aliases[match[1]] = true
+			continue
+		}
+
+		switch {
+		case postmanStatusPattern.MatchString(line):
+			code, _ := strconv.Atoi(postmanStatusPattern.FindStringSubmatch(line)[1])
+			assertions = append(assertions, models.AssertionSpec{Type: "status_code", Expected: code})
+		case postmanStatusExpectPattern.MatchString(line):
+			code, _ := strconv.Atoi(postmanStatusExpectPattern.FindStringSubmatch(line)[1])
+			assertions = append(assertions, models.AssertionSpec{Type: "status_code", Expected: code})
+		case postmanStatusOneOfPattern.MatchString(line):
+			var codes []int
+			for _, code := range strings.Split(postmanStatusOneOfPattern.FindStringSubmatch(line)[1], ",") {
+				if n, err := strconv.Atoi(strings.TrimSpace(code)); err == nil {
+					codes = append(codes, n)
+				}
+			}
+			assertions = append(assertions, models.AssertionSpec{Type: "status_code", Matcher: "one_of", Expected: codes})
+		case postmanStatusClassPattern.MatchString(line):
+			class := postmanStatusClasses[postmanStatusClassPattern.FindStringSubmatch(line)[1]]
+			assertions = append(assertions, models.AssertionSpec{Type: "status_class", Expected: class})
+		case postmanTimePattern.MatchString(line):
+			ms, _ := strconv.Atoi(postmanTimePattern.FindStringSubmatch(line)[1])
+			assertions = append(assertions, models.AssertionSpec{Type: "response_time", Matcher: "less_than", Expected: ms})
+		case postmanHeaderPattern.MatchString(line):
+			header := postmanHeaderPattern.FindStringSubmatch(line)[1]
+			assertions = append(assertions, models.AssertionSpec{Type: "exists", Path: "headers." + header})
+		case postmanPropertyPattern.MatchString(line):
+			match := postmanPropertyPattern.FindStringSubmatch(line)
+			if path, ok := postmanBodyPath(match[1], aliases); ok {
+				assertions = append(assertions, models.AssertionSpec{Type: "exists", Path: path + "." + match[2]})
+			} else {
+				result.warn(name, "unsupported test script statement: %s", line)
+			}
+		case postmanEqualsPattern.MatchString(line):
+			match := postmanEqualsPattern.FindStringSubmatch(line)
+			path, ok := postmanBodyPath(match[1], aliases)
+			var expected interface{}
+			if ok {
+				ok = json.Unmarshal([]byte(strings.ReplaceAll(match[2], "'", "\"")), &expected) == nil
+			}
+			if ok {
+				assertions = append(assertions, models.AssertionSpec{Type: "equals", Path: path, Expected: expected})
+			} else {
+				result.warn(name, "unsupported test script statement: %s", line)
+			}
+		case postmanAssertionCall.MatchString(line):
+			result.warn(name, "unsupported test script statement: %s", line)
+		}
+	}
+
+	return assertions
+}
+
+// postmanBodyPath converts a JavaScript expression over the response JSON
+// (jsonData.a.b or pm.response.json().a[0]) into a response data path
+func postmanBodyPath(expr string, aliases map[string]bool) (string, bool) {
+	var rest string
+	switch {
+	case strings.HasPrefix(expr, "pm.response.json()"):
+		rest = strings.TrimPrefix(expr, "pm.response.json()")
+	default:
+		root := expr
+		if idx := strings.IndexAny(expr, ".["); idx >= 0 {
+			root = expr[:idx]
+		}
+		if !aliases[root] {
+			return "", false
+		}
+		rest = strings.TrimPrefix(expr, root)
+	}
+
+	rest = strings.ReplaceAll(rest, "[", ".")
+	rest = strings.ReplaceAll(rest, "]", "")
+	return "body" + rest, !strings.ContainsAny(rest, "()")
+}
+
+// postmanScriptLines accepts a script given as a list of lines or a single string
+func postmanScriptLines(exec json.RawMessage) []string {
+	var lines []string
+	if err := json.Unmarshal(exec, &lines); err == nil {
+		return splitLines(lines)
+	}
+	var script string
+	if err := json.Unmarshal(exec, &script); err == nil {
+		return splitLines([]string{script})
+	}
+	return nil
+}
+
+// splitLines splits multi-line entries into single statements
+func splitLines(entries []string) []string {
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, strings.Split(entry, "\n")...)
+	}
+	return lines
+}
+
+// postmanDescription accepts a description given as a string or as {content: ...}
+func postmanDescription(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var description string
+	if err := json.Unmarshal(raw, &description); err == nil {
+		return description
+	}
+	var object struct {
+		Content string `json:"content"`
+	}
+	json.Unmarshal(raw, &object)
+	return object.Content
+}
+
+// setDefaultHeader sets a header unless it is already present (case-insensitive)
+func setDefaultHeader(headers map[string]string, name, value string) {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			return
+		}
+	}
+	headers[name] = value
+}
+
+// deleteHeader removes a header regardless of its case
+func deleteHeader(headers map[string]string, name string) {
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			delete(headers, key)
+		}
+	}
+}
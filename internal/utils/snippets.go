@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Request snippet formats
+const (
+	SnippetFormatCurl   = "curl"
+	SnippetFormatHTTPie = "httpie"
+	SnippetFormatFetch  = "fetch"
+	SnippetFormatAuto   = "auto"
+)
+
+// DetectSnippetFormat guesses the format of a request snippet: a fetch()
+// call, an HTTPie command (http, https, xh or xhs) or a curl command
+func DetectSnippetFormat(snippet string) string {
+	if strings.Contains(snippet, "fetch(") {
+		return SnippetFormatFetch
+	}
+	fields := strings.Fields(snippet)
+	if len(fields) > 0 {
+		switch path.Base(fields[0]) {
+		case "http", "https", "xh", "xhs":
+			return SnippetFormatHTTPie
+		}
+	}
+	return SnippetFormatCurl
+}
+
+// ParseRequestSnippet parses a curl command, HTTPie command or fetch() call
+// into a request. An empty format or "auto" detects the format.
+func ParseRequestSnippet(snippet, format string) (*CurlRequest, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" || format == SnippetFormatAuto {
+		format = DetectSnippetFormat(snippet)
+	}
+	switch format {
+	case SnippetFormatCurl:
+		return ParseCurlCommand(snippet)
+	case SnippetFormatHTTPie:
+		return ParseHTTPieCommand(snippet)
+	case SnippetFormatFetch:
+		return ParseFetchSnippet(snippet)
+	default:
+		return nil, fmt.Errorf("unknown snippet format %q, use curl, httpie, fetch or auto", format)
+	}
+}
@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestParseHTTPieCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		method      string
+		url         string
+		body        string
+		headers     map[string]string
+		queryParams map[string]string
+		form        int
+	}{
+		{
+			name:    "json fields",
+			command: `http POST :3000/users name=Ada age:=36 tags:='["math"]' Authorization:'Bearer token'`,
+			method:  "POST",
+			url:     "http://localhost:3000/users",
+			body:    `{"age":36,"name":"Ada","tags":["math"]}`,
+			headers: map[string]string{"Authorization": "Bearer token", "Content-Type": "application/json"},
+		},
+		{
+			name:        "method defaults to post with a body",
+			command:     `https api.example.com/search q==ada limit==5 active:=true`,
+			method:      "POST",
+			url:         "https://api.example.com/search",
+			body:        `{"active":true}`,
+			queryParams: map[string]string{"q": "ada", "limit": "5"},
+		},
+		{
+			name:    "form with basic auth",
+			command: `http --form -a user:pass PUT https://api.example.com/profile bio='hello world'`,
+			method:  "PUT",
+			url:     "https://api.example.com/profile",
+			body:    "bio=hello+world",
+			headers: map[string]string{"Authorization": "Basic dXNlcjpwYXNz", "Content-Type": "application/x-www-form-urlencoded; charset=utf-8"},
+		},
+		{
+			name:    "file fields make the body multipart",
+			command: `http --timeout 5 api.example.com/upload title=Report file@./report.pdf;type=application/pdf`,
+			method:  "POST",
+			url:     "http://api.example.com/upload",
+			form:    2,
+		},
+		{
+			name:    "escaped separators in names",
+			command: `http GET example.com 'X-Custom\:Name:value'`,
+			method:  "GET",
+			url:     "http://example.com",
+			headers: map[string]string{"X-Custom:Name": "value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseHTTPieCommand(tt.command)
+			if err != nil {
+				t.Fatalf("ParseHTTPieCommand() error = %v", err)
+			}
+			checkSnippet(t, result, tt.method, tt.url, tt.body, tt.headers, tt.queryParams)
+			if len(result.Form) != tt.form {
+				t.Errorf("Form = %+v, want %d fields", result.Form, tt.form)
+			}
+		})
+	}
+}
+
+func TestParseHTTPieCommandErrors(t *testing.T) {
+	for _, command := range []string{
+		`curl https://example.com`,
+		`http --json`,
+		`http POST example.com data=@body.json`,
+		`http POST example.com count:=notjson`,
+	} {
+		if _, err := ParseHTTPieCommand(command); err == nil {
+			t.Errorf("ParseHTTPieCommand(%q) expected an error", command)
+		}
+	}
+}
+
+func TestParseFetchSnippet(t *testing.T) {
+	tests := []struct {
+		name        string
+		snippet     string
+		method      string
+		url         string
+		body        string
+		headers     map[string]string
+		queryParams map[string]string
+	}{
+		{
+			name: "chrome copy as fetch",
+			snippet: `fetch("https://api.example.com/users?page=2", {
+  "headers": {
+    "accept": "application/json",
+    "content-type": "application/json",
+  },
+  "referrer": "https://app.example.com/",
+  "body": "{\"name\":\"Ada \\u00e9\"}",
+  "method": "POST",
+  "mode": "cors",
+  "credentials": "include"
+});`,
+			method:      "POST",
+			url:         "https://api.example.com/users",
+			body:        `{"name":"Ada \u00e9"}`,
+			headers:     map[string]string{"accept": "application/json", "content-type": "application/json"},
+			queryParams: map[string]string{"page": "2"},
+		},
+		{
+			name: "hand-written with stringify",
+			snippet: `await fetch('https://api.example.com/notes', {
+  method: 'put', // update
+  headers: new Headers({ Authorization: ` + "`Bearer token`" + ` }),
+  body: JSON.stringify({ text: "it's", pinned: true }),
+})`,
+			method:  "PUT",
+			url:     "https://api.example.com/notes",
+			body:    `{"pinned":true,"text":"it's"}`,
+			headers: map[string]string{"Authorization": "Bearer token"},
+		},
+		{
+			name:    "header pairs and url search params",
+			snippet: `fetch("https://api.example.com/login", {method: "POST", headers: [["X-Id", "1"]], body: new URLSearchParams({user: "ada", pass: "a&b"})})`,
+			method:  "POST",
+			url:     "https://api.example.com/login",
+			body:    "pass=a%26b&user=ada",
+			headers: map[string]string{"X-Id": "1"},
+		},
+		{
+			name:    "url only",
+			snippet: `fetch("https://api.example.com/health")`,
+			method:  "GET",
+			url:     "https://api.example.com/health",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseFetchSnippet(tt.snippet)
+			if err != nil {
+				t.Fatalf("ParseFetchSnippet() error = %v", err)
+			}
+			checkSnippet(t, result, tt.method, tt.url, tt.body, tt.headers, tt.queryParams)
+		})
+	}
+}
+
+func TestParseFetchSnippetErrors(t *testing.T) {
+	for _, snippet := range []string{
+		`curl https://example.com`,
+		`fetch(url)`,
+		"fetch(`${base}/users`)",
+		`fetch("https://example.com", {body: data})`,
+		`fetch("https://example.com", {body: {a: 1}})`,
+		`fetch("https://example.com", {method: "POST"`,
+	} {
+		if _, err := ParseFetchSnippet(snippet); err == nil {
+			t.Errorf("ParseFetchSnippet(%q) expected an error", snippet)
+		}
+	}
+}
+
+func TestParseRequestSnippet(t *testing.T) {
+	tests := []struct {
+		snippet string
+		format  string
+		want    string
+	}{
+		{`curl https://example.com/a`, "", "https://example.com/a"},
+		{`http example.com/b`, "auto", "http://example.com/b"},
+		{`/usr/local/bin/xhs example.com/c`, "", "https://example.com/c"},
+		{`fetch("https://example.com/d")`, "", "https://example.com/d"},
+		{`fetch(url)`, "fetch", ""},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseRequestSnippet(tt.snippet, tt.format)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ParseRequestSnippet(%q, %q) expected an error", tt.snippet, tt.format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRequestSnippet(%q, %q) error = %v", tt.snippet, tt.format, err)
+			continue
+		}
+		if result.URL != tt.want {
+			t.Errorf("ParseRequestSnippet(%q) URL = %q, want %q", tt.snippet, result.URL, tt.want)
+		}
+	}
+
+	if _, err := ParseRequestSnippet(`curl https://example.com`, "wget"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func checkSnippet(t *testing.T, result *CurlRequest, method, url, body string, headers, queryParams map[string]string) {
+	t.Helper()
+	if result.Method != method {
+		t.Errorf("Method = %q, want %q", result.Method, method)
+	}
+	if result.URL != url {
+		t.Errorf("URL = %q, want %q", result.URL, url)
+	}
+	if result.Body != body {
+		t.Errorf("Body = %q, want %q", result.Body, body)
+	}
+	for name, value := range headers {
+		if result.Headers[name] != value {
+			t.Errorf("Headers[%s] = %q, want %q", name, result.Headers[name], value)
+		}
+	}
+	for name, value := range queryParams {
+		if result.QueryParams[name] != value {
+			t.Errorf("QueryParams[%s] = %q, want %q", name, result.QueryParams[name], value)
+		}
+	}
+}